@@ -0,0 +1,166 @@
+// benchlog drives the logger at a configurable rate and concurrency and
+// reports throughput, allocations and drop rates.
+/*
+ * 日志压测工具，用于在目标机器上选择encoder、flush间隔以及队列长度，例如：
+ *     benchlog -dir /data/log/bench -encoder json -concurrency 16 -rate 200000 -duration 30s
+ *     benchlog -dir /data/log/bench -deadline 5ms -queue 1000 -flush 100ms
+ *     benchlog -dir /data/log/bench -queue 100 -overflow drop-oldest
+ * -deadline大于0时通过WriteCtx写入，超过期限的记录计为丢弃；否则通过Trace/Error写入，队列满时阻塞
+ */
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+func main() {
+	dir := flag.String("dir", os.TempDir(), "log directory")
+	encoder := flag.String("encoder", "text", "record encoder: text or json")
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "number of writer goroutines")
+	rate := flag.Int("rate", 0, "total records per second, 0 means unlimited")
+	duration := flag.Duration("duration", 10*time.Second, "how long to write")
+	size := flag.Int("size", 128, "message size in bytes")
+	level := flag.String("level", "trace", "level to write: debug, trace, warn or error")
+	deadline := flag.Duration("deadline", 0, "write through WriteCtx with this deadline, records missing it count as dropped")
+	flush := flag.Duration("flush", 0, "flush interval, 0 keeps the logger default")
+	queue := flag.Int("queue", 0, "write queue size, 0 keeps the logger default")
+	spool := flag.String("spool", "", "spool directory, empty disables spooling")
+	overflow := flag.String("overflow", "block", "full write queue policy: block, drop-oldest or drop-newest")
+	flag.Parse()
+
+	opts := []logger.Option{logger.WithFlushInterval(*flush), logger.WithQueueSize(*queue)}
+	switch *encoder {
+	case "text":
+	case "json":
+		opts = append(opts, logger.WithEncoder(logger.JSONEncoder{}))
+	default:
+		fmt.Fprintln(os.Stderr, "unknown encoder:", *encoder)
+		os.Exit(2)
+	}
+	if *spool != "" {
+		opts = append(opts, logger.WithSpool(*spool, 0))
+	}
+	policy, err := logger.ParseOverflowPolicy(*overflow)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	opts = append(opts, logger.WithOverflowPolicy(policy))
+	if err := os.MkdirAll(*dir, 0777); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	l, err := logger.NewLogger(filepath.Join(*dir, "benchlog"), "bench", "", opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	write, err := writer(l, *level, *deadline)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	msg := strings.Repeat("x", *size)
+	var attempted, rejected int64
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	stop := start.Add(*duration)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			var interval time.Duration
+			if *rate > 0 {
+				interval = time.Duration(int64(time.Second) * int64(*concurrency) / int64(*rate))
+			}
+			next := time.Now()
+			for seq := 0; time.Now().Before(stop); seq++ {
+				if interval > 0 {
+					if wait := time.Until(next); wait > 0 {
+						time.Sleep(wait)
+					}
+					next = next.Add(interval)
+				}
+				atomic.AddInt64(&attempted, 1)
+				if write(id, seq, msg) != nil {
+					atomic.AddInt64(&rejected, 1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	produced := time.Since(start)
+	l.Flush()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	stats := l.Stats()
+	l.Close()
+
+	written := attempted - rejected
+	fmt.Printf("encoder=%s concurrency=%d rate=%d size=%d deadline=%v flush=%v queue=%d spool=%t overflow=%s\n",
+		*encoder, *concurrency, *rate, *size, *deadline, *flush, *queue, *spool != "", policy)
+	fmt.Printf("records: attempted=%d written=%d dropped=%d (%.3f%%) failed_writes=%d errors=%d dropped_buffers=%d\n",
+		attempted, written, rejected, percent(rejected, attempted), stats.FailedWrites, stats.Errors, stats.DroppedBuffers)
+	fmt.Printf("throughput: %.0f records/s produced in %v, %.0f records/s on disk in %v\n",
+		float64(attempted)/produced.Seconds(), produced.Round(time.Millisecond),
+		float64(written)/elapsed.Seconds(), elapsed.Round(time.Millisecond))
+	if attempted > 0 {
+		fmt.Printf("allocations: %.1f allocs/record, %.0f bytes/record, %d GC cycles\n",
+			float64(after.Mallocs-before.Mallocs)/float64(attempted),
+			float64(after.TotalAlloc-before.TotalAlloc)/float64(attempted),
+			after.NumGC-before.NumGC)
+	}
+}
+
+/*
+ * 根据参数选择写入方式
+ * @return 写入函数，返回非nil表示记录被丢弃
+ */
+func writer(l *logger.Logger, level string, deadline time.Duration) (func(id, seq int, msg string) error, error) {
+	if deadline > 0 {
+		return func(id, seq int, msg string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), deadline)
+			defer cancel()
+			return l.WriteCtx(ctx, level, msg, logger.Fields{"worker": id, "seq": seq})
+		}, nil
+	}
+	var log func(args ...interface{})
+	switch level {
+	case "debug":
+		log = l.Debug
+	case "trace":
+		log = l.Trace
+	case "warn":
+		log = l.Warn
+	case "error":
+		log = l.Error
+	default:
+		return nil, fmt.Errorf("unknown level: %s", level)
+	}
+	return func(id, seq int, msg string) error {
+		log(id, seq, msg)
+		return nil
+	}, nil
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,124 @@
+// legion-new generates a runnable service skeleton wired to the
+// nano-legion utilities.
+/*
+ * 服务脚手架生成工具，生成的项目包含日志、配置、pid文件、启动信息、健康检查、计数器、
+ * 日志级别/切分/维护模式管理接口以及优雅退出，例如：
+ *     legion-new -name order-api -dir $GOPATH/src/example.com/order-api
+ * 生成的文件已经存在时不会覆盖，直接报错退出
+ */
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// namePattern 服务名称，用于日志文件名以及pid文件名
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// skeleton 生成的文件：文件名 -> 模板
+var skeleton = []struct {
+	name string
+	text string
+}{
+	{"main.go", mainTemplate},
+	{"config.go", configTemplate},
+	{filepath.Join("conf", "{{.Name}}.json"), configFileTemplate},
+}
+
+// params 模板参数
+type params struct {
+	Name      string // 服务名称
+	Listen    string // 业务端口
+	AdminPort string // 管理端口
+}
+
+func main() {
+	name := flag.String("name", "", "service name, lower case letters, digits and dashes")
+	dir := flag.String("dir", "", "output directory, defaults to ./<name>")
+	listen := flag.String("listen", ":8080", "default service listen address")
+	admin := flag.String("admin", "127.0.0.1:8081", "default admin listen address")
+	flag.Parse()
+
+	if !namePattern.MatchString(*name) {
+		fmt.Fprintln(os.Stderr, "invalid service name:", *name)
+		os.Exit(2)
+	}
+	if *dir == "" {
+		*dir = *name
+	}
+	p := params{Name: *name, Listen: *listen, AdminPort: *admin}
+	if err := generate(*dir, p); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("generated", *dir)
+}
+
+/*
+ * 生成所有文件，任何一个文件已经存在时不生成任何文件
+ * @param dir：输出目录
+ * @param p：模板参数
+ * @return 渲染或者写入失败时返回error
+ */
+func generate(dir string, p params) error {
+	files := make(map[string][]byte, len(skeleton))
+	var names []string
+	for _, file := range skeleton {
+		rendered, err := render(file.name, p)
+		if err != nil {
+			return err
+		}
+		name := string(rendered)
+		content, err := render(file.text, p)
+		if err != nil {
+			return fmt.Errorf("render %s: %w", name, err)
+		}
+		if strings.HasSuffix(name, ".go") {
+			formatted, err := format.Source(content)
+			if err != nil {
+				return fmt.Errorf("format %s: %w", name, err)
+			}
+			content = formatted
+		}
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		}
+		files[path] = content
+		names = append(names, path)
+	}
+
+	for _, path := range names {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, files[path], 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * 渲染模板
+ */
+func render(text string, p params) ([]byte, error) {
+	tmpl, err := template.New("").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
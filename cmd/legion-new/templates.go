@@ -0,0 +1,185 @@
+package main
+
+// mainTemplate 生成的main.go
+const mainTemplate = `// {{.Name}} service, generated by legion-new.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/lucifinil-long/nano-legion/utilities/config"
+	"github.com/lucifinil-long/nano-legion/utilities/counter"
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+	"github.com/lucifinil-long/nano-legion/utilities/maintenance"
+	"github.com/lucifinil-long/nano-legion/utilities/process"
+)
+
+func main() {
+	configFile := flag.String("config", "conf/{{.Name}}.json", "configuration file")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(int(process.ExitConfig))
+	}
+	store := config.NewStore(cfg)
+
+	// 日志
+	if err := os.MkdirAll(cfg.LogDir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(int(process.ExitConfig))
+	}
+	log, err := logger.NewLogger(filepath.Join(cfg.LogDir, "{{.Name}}"), "", filepath.Join(cfg.LogDir, "backup"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(int(process.ExitConfig))
+	}
+	log.SetLevel(cfg.LogLevel)
+	process.SetExitLogger(log)
+	process.OnExit(log.ReopenOnSignal())
+
+	// pid文件以及上一次运行的检查
+	pm := process.CheckPreviousRun(process.PostMortemOptions{PidFile: cfg.PidFile})
+	if pm.Abnormal {
+		log.Warn("previous run ended abnormally", pm.Reason, pm.PreviousPid, pm.Detail)
+	}
+	if err := process.SavePid(cfg.PidFile); err != nil {
+		process.Exit(process.ExitFailure, err.Error())
+	}
+	process.OnExit(func() { process.RemovePid(cfg.PidFile) })
+	process.LogStartupBanner(log, cfg)
+
+	// 业务接口
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		counter.Inc("requests")
+		fmt.Fprintln(w, store.Load().Greeting)
+	})
+	server := &http.Server{Addr: cfg.Listen, Handler: maintenance.Middleware(mux)}
+
+	// 管理接口：健康检查、计数器、日志级别、日志切分以及维护模式
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if maintenance.Enabled() {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	adminMux.Handle("/metrics", counter.Default().Handler())
+	adminMux.Handle("/admin/log/level", log.LevelHandler())
+	adminMux.Handle("/admin/log/rotate", log.RotateHandler())
+	adminMux.Handle("/admin/maintenance", maintenance.Handler())
+	admin := &http.Server{Addr: cfg.AdminListen, Handler: adminMux}
+
+	for _, srv := range []*http.Server{server, admin} {
+		srv := srv
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				process.Exit(process.ExitUnavailable, "listen "+srv.Addr+": "+err.Error())
+			}
+		}()
+	}
+	process.ShutdownTimeout = cfg.ShutdownTimeout.Duration
+	process.OnShutdown(admin.Shutdown)
+	process.OnShutdown(server.Shutdown)
+	log.Trace("{{.Name}} started", cfg.Listen, cfg.AdminListen)
+
+	// 优雅退出：收到SIGINT/SIGTERM之后先关闭业务接口，再关闭管理接口
+	process.Exit(process.WaitForShutdown(), "shutdown")
+}
+
+`
+
+// configTemplate 生成的config.go
+const configTemplate = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Config is the configuration of {{.Name}}
+type Config struct {
+	Listen          string   ` + "`json:\"listen\"`" + `
+	AdminListen     string   ` + "`json:\"admin_listen\"`" + `
+	LogDir          string   ` + "`json:\"log_dir\"`" + `
+	LogLevel        int      ` + "`json:\"log_level\"`" + `
+	PidFile         string   ` + "`json:\"pid_file\"`" + `
+	ShutdownTimeout Duration ` + "`json:\"shutdown_timeout\"`" + `
+	Greeting        string   ` + "`json:\"greeting\"`" + `
+}
+
+// Duration is a time.Duration written as "10s" in the configuration file
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+/*
+ * 默认配置
+ */
+func defaultConfig() *Config {
+	return &Config{
+		Listen:          "{{.Listen}}",
+		AdminListen:     "{{.AdminPort}}",
+		LogDir:          "log",
+		LogLevel:        1,
+		PidFile:         "run/{{.Name}}.pid",
+		ShutdownTimeout: Duration{10 * time.Second},
+		Greeting:        "hello from {{.Name}}",
+	}
+}
+
+/*
+ * 读取配置文件，文件中没有的配置项使用默认值
+ */
+func loadConfig(filename string) (*Config, error) {
+	cfg := defaultConfig()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", filename, err)
+	}
+	if err := json.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", filename, err)
+	}
+	return cfg, nil
+}
+`
+
+// configFileTemplate 生成的配置文件
+const configFileTemplate = `{
+  "listen": "{{.Listen}}",
+  "admin_listen": "{{.AdminPort}}",
+  "log_dir": "log",
+  "log_level": 1,
+  "pid_file": "run/{{.Name}}.pid",
+  "shutdown_timeout": "10s",
+  "greeting": "hello from {{.Name}}"
+}
+`
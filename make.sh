@@ -36,6 +36,8 @@ goimports -w=true ./
 
 echo "build tools"
 $GOROOT/bin/go install github.com/lucifinil-long/nano-legion/vendor/github.com/golang/protobuf/protoc-gen-go
+$GOROOT/bin/go install github.com/lucifinil-long/nano-legion/cmd/benchlog
+$GOROOT/bin/go install github.com/lucifinil-long/nano-legion/cmd/legion-new
 
 echo "building administrative center and agent..."
 
@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// ResultSuccess means the action succeeded
+	ResultSuccess = "success"
+	// ResultFailure means the action failed
+	ResultFailure = "failure"
+	// ResultDenied means the action was rejected by authorization
+	ResultDenied = "denied"
+)
+
+// Event is a who-did-what audit record
+type Event struct {
+	Time   time.Time         `json:"time"`
+	Actor  string            `json:"actor"`            // 操作者，例如用户名或者服务名
+	Action string            `json:"action"`           // 操作，例如 config.update
+	Target string            `json:"target"`           // 操作对象
+	Result string            `json:"result"`           // 操作结果：success/failure/denied
+	Source string            `json:"source,omitempty"` // 操作来源，例如客户端IP
+	Detail map[string]string `json:"detail,omitempty"` // 补充信息
+}
+
+// Sink persists audit events
+type Sink interface {
+	Record(event *Event) error
+}
+
+// Filter selects events in a query
+/*
+ * 查询条件，字段为空表示不限制
+ */
+type Filter struct {
+	Since  time.Time
+	Until  time.Time
+	Actor  string
+	Action string
+	Target string
+	Result string
+	Limit  int // 最多返回的条数，0表示不限制
+}
+
+// Match reports whether event satisfies the filter
+func (f *Filter) Match(event *Event) bool {
+	if !f.Since.IsZero() && event.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !event.Time.Before(f.Until) {
+		return false
+	}
+	if f.Actor != "" && event.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && event.Action != f.Action {
+		return false
+	}
+	if f.Target != "" && event.Target != f.Target {
+		return false
+	}
+	if f.Result != "" && event.Result != f.Result {
+		return false
+	}
+	return true
+}
+
+// Querier is implemented by sinks which support querying
+type Querier interface {
+	Query(filter Filter) ([]*Event, error)
+}
+
+// ErrNoSink is returned when the auditor has no sink configured
+var ErrNoSink = errors.New("audit: no sink configured")
+
+// Auditor records events to a primary durable sink and optional secondary sinks
+/*
+ * 审计记录器
+ * 事件同步写入主sink，写入失败时返回错误，调用方应当据此拒绝或者回滚操作；
+ * 次要sink(例如数据库)写入失败只通过错误回调通知，不影响返回值
+ */
+type Auditor struct {
+	sync.RWMutex
+	primary   Sink
+	secondary []Sink
+	onError   func(sink Sink, event *Event, err error)
+}
+
+// NewAuditor creates an auditor with the primary sink
+func NewAuditor(primary Sink) *Auditor {
+	return &Auditor{primary: primary}
+}
+
+// AddSink adds a secondary sink
+func (a *Auditor) AddSink(sink Sink) {
+	a.Lock()
+	a.secondary = append(a.secondary, sink)
+	a.Unlock()
+}
+
+// SetErrorHandler sets the callback of secondary sink failures
+func (a *Auditor) SetErrorHandler(f func(sink Sink, event *Event, err error)) {
+	a.Lock()
+	a.onError = f
+	a.Unlock()
+}
+
+// Record writes event to all sinks
+/*
+ * 记录审计事件，Time为零值时自动填充当前时间
+ * @param event：审计事件
+ * @return 主sink写入失败时返回error
+ */
+func (a *Auditor) Record(event *Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	a.RLock()
+	primary, secondary, onError := a.primary, a.secondary, a.onError
+	a.RUnlock()
+
+	if primary == nil {
+		return ErrNoSink
+	}
+	if err := primary.Record(event); err != nil {
+		return err
+	}
+	for _, sink := range secondary {
+		if err := sink.Record(event); err != nil && onError != nil {
+			onError(sink, event, err)
+		}
+	}
+	return nil
+}
+
+// Log is a shortcut of Record
+func (a *Auditor) Log(actor, action, target, result string, detail map[string]string) error {
+	return a.Record(&Event{Actor: actor, Action: action, Target: target, Result: result, Detail: detail})
+}
+
+// Query queries events through the primary sink
+/*
+ * 通过主sink查询审计事件
+ * @return 主sink不支持查询时返回error
+ */
+func (a *Auditor) Query(filter Filter) ([]*Event, error) {
+	a.RLock()
+	primary := a.primary
+	a.RUnlock()
+	querier, ok := primary.(Querier)
+	if !ok {
+		return nil, errors.New("audit: primary sink does not support query")
+	}
+	return querier.Query(filter)
+}
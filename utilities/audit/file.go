@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileSink appends events as JSON lines and fsyncs every record
+/*
+ * 文件审计sink，每条事件一行JSON，写入后立即fsync，保证Record返回时事件已经落盘
+ */
+type FileSink struct {
+	sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileSink opens (or creates) the audit file
+/*
+ * 打开审计文件，目录不存在时自动创建
+ * @param path：审计文件路径
+ * @return 成功返回(*FileSink, nil)；否则返回(nil, error)
+ */
+func NewFileSink(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{path: path, file: file}, nil
+}
+
+// Record implements Sink
+func (sink *FileSink) Record(event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	sink.Lock()
+	defer sink.Unlock()
+	if _, err = sink.file.Write(line); err != nil {
+		return err
+	}
+	return sink.file.Sync()
+}
+
+// Query implements Querier by scanning the audit file
+func (sink *FileSink) Query(filter Filter) ([]*Event, error) {
+	file, err := os.Open(sink.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []*Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		event := &Event{}
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			// 跳过损坏的行，例如进程崩溃时写了一半的记录
+			continue
+		}
+		if !filter.Match(event) {
+			continue
+		}
+		events = append(events, event)
+		if filter.Limit > 0 && len(events) >= filter.Limit {
+			break
+		}
+	}
+	return events, scanner.Err()
+}
+
+// Close closes the audit file
+func (sink *FileSink) Close() error {
+	sink.Lock()
+	defer sink.Unlock()
+	return sink.file.Close()
+}
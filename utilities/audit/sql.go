@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tableNamePattern 表名只允许字母数字下划线，避免拼接SQL时注入
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// SQLSink stores events into a database table
+/*
+ * 数据库审计sink，表结构：
+ *   CREATE TABLE audit_log (
+ *     time BIGINT, actor VARCHAR(128), action VARCHAR(128), target VARCHAR(256),
+ *     result VARCHAR(16), source VARCHAR(64), detail TEXT
+ *   )
+ * time保存为unix毫秒；占位符风格由placeholder决定(mysql/sqlite使用"?"，postgres使用"$")
+ */
+type SQLSink struct {
+	db          *sql.DB
+	table       string
+	placeholder string
+}
+
+// NewSQLSink creates a database sink
+/*
+ * 创建数据库审计sink
+ * @param db：数据库连接，驱动由调用方引入
+ * @param table：表名
+ * @param placeholder："?"或者"$"
+ * @return 表名非法时返回error
+ */
+func NewSQLSink(db *sql.DB, table, placeholder string) (*SQLSink, error) {
+	if !tableNamePattern.MatchString(table) {
+		return nil, errors.New("audit: invalid table name " + table)
+	}
+	if placeholder != "$" {
+		placeholder = "?"
+	}
+	return &SQLSink{db: db, table: table, placeholder: placeholder}, nil
+}
+
+// Record implements Sink
+func (sink *SQLSink) Record(event *Event) error {
+	detail, err := json.Marshal(event.Detail)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO " + sink.table + " (time, actor, action, target, result, source, detail) VALUES (" +
+		sink.placeholders(7) + ")"
+	_, err = sink.db.Exec(query, event.Time.UnixNano()/int64(time.Millisecond), event.Actor, event.Action,
+		event.Target, event.Result, event.Source, string(detail))
+	return err
+}
+
+// Query implements Querier
+func (sink *SQLSink) Query(filter Filter) ([]*Event, error) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.Replace(cond, "?", sink.placeholderAt(len(args)), 1))
+	}
+	if !filter.Since.IsZero() {
+		add("time >= ?", filter.Since.UnixNano()/int64(time.Millisecond))
+	}
+	if !filter.Until.IsZero() {
+		add("time < ?", filter.Until.UnixNano()/int64(time.Millisecond))
+	}
+	if filter.Actor != "" {
+		add("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = ?", filter.Action)
+	}
+	if filter.Target != "" {
+		add("target = ?", filter.Target)
+	}
+	if filter.Result != "" {
+		add("result = ?", filter.Result)
+	}
+
+	query := "SELECT time, actor, action, target, result, source, detail FROM " + sink.table
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY time"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT " + sink.placeholderAt(len(args))
+	}
+
+	rows, err := sink.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var millis int64
+		var detail string
+		event := &Event{}
+		if err = rows.Scan(&millis, &event.Actor, &event.Action, &event.Target, &event.Result, &event.Source, &detail); err != nil {
+			return nil, err
+		}
+		event.Time = time.Unix(0, millis*int64(time.Millisecond))
+		if detail != "" && detail != "null" {
+			json.Unmarshal([]byte(detail), &event.Detail)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (sink *SQLSink) placeholders(n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = sink.placeholderAt(i + 1)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (sink *SQLSink) placeholderAt(i int) string {
+	if sink.placeholder == "$" {
+		return "$" + strconv.Itoa(i)
+	}
+	return "?"
+}
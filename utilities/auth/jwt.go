@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned when a token is malformed or its signature does not match
+	ErrInvalidToken = errors.New("auth: invalid token")
+	// ErrTokenExpired is returned when a token is expired or not valid yet
+	ErrTokenExpired = errors.New("auth: token expired")
+	// ErrUnknownKey is returned when the token is signed by an unknown key
+	ErrUnknownKey = errors.New("auth: unknown signing key")
+	// ErrWeakKey is returned by AddKey when an HMAC secret is shorter than the hash output
+	ErrWeakKey = errors.New("auth: hmac secret shorter than hash size")
+)
+
+// Claims are the JWT claims
+/*
+ * JWT声明，标准字段之外的自定义字段放在Extra中
+ */
+type Claims struct {
+	Subject   string                 `json:"sub,omitempty"`
+	Issuer    string                 `json:"iss,omitempty"`
+	Audience  string                 `json:"aud,omitempty"`
+	ExpiresAt int64                  `json:"exp,omitempty"`
+	NotBefore int64                  `json:"nbf,omitempty"`
+	IssuedAt  int64                  `json:"iat,omitempty"`
+	ID        string                 `json:"jti,omitempty"`
+	Extra     map[string]interface{} `json:"ext,omitempty"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// signingKey JWT签名密钥
+type signingKey struct {
+	id     string
+	alg    string
+	secret []byte
+}
+
+// KeySet holds HMAC keys used to sign and verify JWTs
+/*
+ * JWT密钥集合，支持密钥轮换：
+ * 新签发的token使用当前密钥签名，并在header中写入kid；
+ * 验证时根据kid查找密钥，轮换之后旧密钥在Retire之前仍然可以验证已签发的token
+ */
+type KeySet struct {
+	sync.RWMutex
+	keys    map[string]*signingKey
+	current string
+	leeway  time.Duration
+	nowFunc func() time.Time
+}
+
+// NewKeySet creates a key set with leeway tolerated for exp/nbf checks
+/*
+ * 创建密钥集合
+ * @param leeway：验证exp/nbf时允许的时钟误差
+ * @return 密钥集合
+ */
+func NewKeySet(leeway time.Duration) *KeySet {
+	return &KeySet{keys: make(map[string]*signingKey), leeway: leeway, nowFunc: time.Now}
+}
+
+// AddKey adds a verification key; alg is one of HS256/HS384/HS512
+/*
+ * 添加密钥，添加的第一个密钥自动成为当前签名密钥
+ * @param id：密钥id，写入token header的kid
+ * @param alg：签名算法，HS256/HS384/HS512
+ * @param secret：密钥，长度不能小于算法的哈希长度(RFC 7518 3.2)，即HS256至少32字节、HS384至少48字节、HS512至少64字节
+ * @return 算法不支持时返回error；密钥过短时返回ErrWeakKey
+ */
+func (ks *KeySet) AddKey(id, alg string, secret []byte) error {
+	h := hashFunc(alg)
+	if h == nil {
+		return errors.New("auth: unsupported algorithm " + alg)
+	}
+	if len(secret) < h().Size() {
+		return ErrWeakKey
+	}
+	ks.Lock()
+	defer ks.Unlock()
+	ks.keys[id] = &signingKey{id: id, alg: alg, secret: append([]byte(nil), secret...)}
+	if ks.current == "" {
+		ks.current = id
+	}
+	return nil
+}
+
+// Rotate makes the key with given id the signing key
+/*
+ * 切换当前签名密钥，旧密钥保留用于验证
+ * @param id：新的签名密钥id，必须已经通过AddKey添加
+ * @return 密钥不存在时返回ErrUnknownKey
+ */
+func (ks *KeySet) Rotate(id string) error {
+	ks.Lock()
+	defer ks.Unlock()
+	if _, ok := ks.keys[id]; !ok {
+		return ErrUnknownKey
+	}
+	ks.current = id
+	return nil
+}
+
+// Retire removes a key so tokens signed by it are no longer accepted
+func (ks *KeySet) Retire(id string) {
+	ks.Lock()
+	defer ks.Unlock()
+	if id == ks.current {
+		return
+	}
+	delete(ks.keys, id)
+}
+
+// Sign issues a token for claims using the current key
+/*
+ * 使用当前密钥签发token，IssuedAt为0时自动填充当前时间
+ * @param claims：声明
+ * @return 成功返回(token, nil)；否则返回("", error)
+ */
+func (ks *KeySet) Sign(claims Claims) (string, error) {
+	ks.RLock()
+	key := ks.keys[ks.current]
+	now := ks.nowFunc()
+	ks.RUnlock()
+	if key == nil {
+		return "", ErrUnknownKey
+	}
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = now.Unix()
+	}
+
+	header, err := json.Marshal(jwtHeader{Alg: key.alg, Typ: "JWT", Kid: key.id})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encodeSegment(header) + "." + encodeSegment(payload)
+	return signingInput + "." + encodeSegment(sign(key, signingInput)), nil
+}
+
+// Issue signs a token for subject valid for ttl
+func (ks *KeySet) Issue(subject string, ttl time.Duration, extra map[string]interface{}) (string, error) {
+	ks.RLock()
+	now := ks.nowFunc()
+	ks.RUnlock()
+	return ks.Sign(Claims{Subject: subject, IssuedAt: now.Unix(), ExpiresAt: now.Add(ttl).Unix(), Extra: extra})
+}
+
+// Verify checks the signature and validity window of token
+/*
+ * 验证token签名以及有效期
+ * @param token：待验证的token
+ * @return 成功返回(*Claims, nil)；否则返回(nil, error)
+ */
+func (ks *KeySet) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	headerBytes, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var header jwtHeader
+	if err = json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	ks.RLock()
+	key := ks.keys[header.Kid]
+	if header.Kid == "" {
+		key = ks.keys[ks.current]
+	}
+	now := ks.nowFunc()
+	ks.RUnlock()
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	// 算法必须与密钥一致，防止alg替换攻击
+	if header.Alg != key.alg {
+		return nil, ErrInvalidToken
+	}
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if subtle.ConstantTimeCompare(signature, sign(key, parts[0]+"."+parts[1])) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := &Claims{}
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt != 0 && now.After(time.Unix(claims.ExpiresAt, 0).Add(ks.leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(ks.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+func hashFunc(alg string) func() hash.Hash {
+	switch alg {
+	case "HS256":
+		return sha256.New
+	case "HS384":
+		return sha512.New384
+	case "HS512":
+		return sha512.New
+	}
+	return nil
+}
+
+func sign(key *signingKey, signingInput string) []byte {
+	mac := hmac.New(hashFunc(key.alg), key.secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	claimsKey contextKey = iota
+	sessionKey
+)
+
+// ClaimsFromContext returns the JWT claims stored by JWTMiddleware
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+// SessionFromContext returns the session stored by SessionMiddleware
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionKey).(*Session)
+	return session, ok
+}
+
+// JWTMiddleware authenticates requests carrying "Authorization: Bearer <jwt>"
+/*
+ * JWT认证中间件，验证失败返回401，验证成功后可以通过ClaimsFromContext获取声明
+ * @param ks：密钥集合
+ * @return 中间件
+ */
+func JWTMiddleware(ks *KeySet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				unauthorized(w)
+				return
+			}
+			claims, err := ks.Verify(token)
+			if err != nil {
+				unauthorized(w)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+		})
+	}
+}
+
+// SessionMiddleware authenticates requests by an opaque token in a cookie or bearer header
+/*
+ * 会话认证中间件，优先读取名为cookieName的cookie，其次读取Bearer token
+ * 验证成功后可以通过SessionFromContext获取会话
+ * @param sessions：会话管理对象
+ * @param cookieName：保存token的cookie名称，为空时只读取Bearer token
+ * @return 中间件
+ */
+func SessionMiddleware(sessions *Sessions, cookieName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := ""
+			if cookieName != "" {
+				if cookie, err := r.Cookie(cookieName); err == nil {
+					token = cookie.Value
+				}
+			}
+			if token == "" {
+				token = bearerToken(r)
+			}
+			if token == "" {
+				unauthorized(w)
+				return
+			}
+			session, err := sessions.Validate(token)
+			if err != nil {
+				unauthorized(w)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey, session)))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="nano-legion"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/cache"
+)
+
+// ErrSessionNotFound is returned when an opaque token is unknown or expired
+var ErrSessionNotFound = errors.New("auth: session not found")
+
+// Session is the server side state of an opaque token
+type Session struct {
+	Token     string
+	Subject   string
+	Values    map[string]string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// SessionStore persists sessions
+/*
+ * 会话存储接口，可以使用内存、缓存或者KV服务实现
+ */
+type SessionStore interface {
+	Save(session *Session) error
+	Load(token string) (*Session, error)
+	Delete(token string) error
+}
+
+// Sessions issues and validates opaque session tokens
+/*
+ * 不透明会话token管理，token本身不携带信息，所有状态保存在SessionStore中
+ */
+type Sessions struct {
+	store   SessionStore
+	ttl     time.Duration
+	sliding bool // 是否在每次验证成功后延长有效期
+}
+
+// NewSessions creates a session manager
+/*
+ * 创建会话管理对象
+ * @param store：会话存储
+ * @param ttl：会话有效期
+ * @param sliding：为true时每次验证成功都会将有效期延长ttl
+ * @return 会话管理对象
+ */
+func NewSessions(store SessionStore, ttl time.Duration, sliding bool) *Sessions {
+	return &Sessions{store: store, ttl: ttl, sliding: sliding}
+}
+
+// Create starts a new session for subject
+func (s *Sessions) Create(subject string, values map[string]string) (*Session, error) {
+	token, err := NewOpaqueToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &Session{Token: token, Subject: subject, Values: values, CreatedAt: now, ExpiresAt: now.Add(s.ttl)}
+	if err = s.store.Save(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Validate returns the session of token if it is still valid
+func (s *Sessions) Validate(token string) (*Session, error) {
+	session, err := s.store.Load(token)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
+		s.store.Delete(token)
+		return nil, ErrSessionNotFound
+	}
+	if s.sliding {
+		session.ExpiresAt = now.Add(s.ttl)
+		if err = s.store.Save(session); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// Revoke deletes the session of token
+func (s *Sessions) Revoke(token string) error {
+	return s.store.Delete(token)
+}
+
+// NewOpaqueToken generates a random URL-safe token of n random bytes
+func NewOpaqueToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// MemoryStore is an in-process SessionStore
+/*
+ * 内存会话存储，过期会话在读取时以及后台定期清理时删除
+ * 进程重启后会话全部失效，多实例部署时需要使用共享存储
+ */
+type MemoryStore struct {
+	sync.RWMutex
+	sessions map[string]Session
+	stop     chan struct{}
+}
+
+// NewMemoryStore creates a memory store which purges expired sessions every interval
+func NewMemoryStore(purgeInterval time.Duration) *MemoryStore {
+	store := &MemoryStore{sessions: make(map[string]Session), stop: make(chan struct{})}
+	if purgeInterval > 0 {
+		go store.purge(purgeInterval)
+	}
+	return store
+}
+
+// Save implements SessionStore
+func (store *MemoryStore) Save(session *Session) error {
+	store.Lock()
+	store.sessions[session.Token] = *session
+	store.Unlock()
+	return nil
+}
+
+// Load implements SessionStore
+func (store *MemoryStore) Load(token string) (*Session, error) {
+	store.RLock()
+	session, ok := store.sessions[token]
+	store.RUnlock()
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// Delete implements SessionStore
+func (store *MemoryStore) Delete(token string) error {
+	store.Lock()
+	delete(store.sessions, token)
+	store.Unlock()
+	return nil
+}
+
+// Close stops the purge goroutine
+func (store *MemoryStore) Close() {
+	select {
+	case <-store.stop:
+	default:
+		close(store.stop)
+	}
+}
+
+func (store *MemoryStore) purge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-store.stop:
+			return
+		case now := <-ticker.C:
+			store.Lock()
+			for token, session := range store.sessions {
+				if now.After(session.ExpiresAt) {
+					delete(store.sessions, token)
+				}
+			}
+			store.Unlock()
+		}
+	}
+}
+
+// CacheStore is a SessionStore backed by a cache.Cache
+/*
+ * 基于cache.Cache的会话存储，每个会话在缓存中的有效期与ExpiresAt一致，过期会话由缓存在访问以及后台清理时删除
+ * 缓存设置MaxEntries时会话数量超过上限后淘汰最近最少使用的会话，被淘汰的会话验证时返回ErrSessionNotFound
+ * 与MemoryStore相同，进程重启后会话全部失效，例如：
+ *     store := auth.NewCacheStore(cache.New(cache.Options[string, auth.Session]{MaxEntries: 100000, CleanupInterval: time.Minute}))
+ *     sessions := auth.NewSessions(store, 30*time.Minute, true)
+ */
+type CacheStore struct {
+	cache *cache.Cache[string, Session]
+}
+
+// NewCacheStore creates a session store on top of c
+/*
+ * 创建基于缓存的会话存储，缓存的默认TTL不起作用，每个会话使用自己的ExpiresAt
+ * @param c：缓存，由调用方创建以及Close
+ * @return 会话存储
+ */
+func NewCacheStore(c *cache.Cache[string, Session]) *CacheStore {
+	return &CacheStore{cache: c}
+}
+
+// Save implements SessionStore
+func (store *CacheStore) Save(session *Session) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		// 已经过期的会话不写入，同时删除旧的会话
+		store.cache.Delete(session.Token)
+		return nil
+	}
+	store.cache.SetWithTTL(session.Token, *session, ttl)
+	return nil
+}
+
+// Load implements SessionStore
+func (store *CacheStore) Load(token string) (*Session, error) {
+	session, ok := store.cache.Get(token)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// Delete implements SessionStore
+func (store *CacheStore) Delete(token string) error {
+	store.cache.Delete(token)
+	return nil
+}
@@ -0,0 +1,161 @@
+package batch
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Add after the batcher is closed
+var ErrClosed = errors.New("batch: batcher closed")
+
+// Options configures flush triggers, a zero value disables the trigger
+/*
+ * 批量触发条件，任意一个条件满足时触发flush，取值为0表示不使用该条件
+ */
+type Options struct {
+	MaxItems int           // 批量中的条数达到MaxItems时flush
+	MaxBytes int           // 批量中的字节数达到MaxBytes时flush，需要提供size函数
+	Interval time.Duration // 距离上一次flush超过Interval时flush
+}
+
+// Batcher accumulates items and hands them to the flush callback in batches
+/*
+ * 批量写入工具
+ * flush回调串行执行，回调返回之前不会开始下一次flush；
+ * 数量/字节触发的flush在调用Add的协程中执行，时间触发的flush在后台协程中执行
+ */
+type Batcher[T any] struct {
+	opts  Options
+	size  func(T) int
+	flush func([]T)
+
+	lock    sync.Mutex
+	items   []T
+	bytes   int
+	closed  bool
+	flushMu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a batcher
+/*
+ * 创建批量写入对象
+ * @param flush：处理批量数据的回调，回调返回后批量切片不再被batcher使用
+ * @param opts：触发条件
+ * @param size：计算单条数据字节数的函数，不使用MaxBytes时可以为nil
+ * @return 批量写入对象
+ */
+func New[T any](flush func([]T), opts Options, size func(T) int) *Batcher[T] {
+	b := &Batcher[T]{
+		opts:  opts,
+		size:  size,
+		flush: flush,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if opts.Interval > 0 {
+		go b.tick()
+	} else {
+		close(b.done)
+	}
+	return b
+}
+
+// Add appends an item, flushing synchronously if a size trigger fires
+/*
+ * 添加一条数据，达到数量或者字节数阈值时在当前协程中flush
+ * @param item：数据
+ * @return 已经关闭时返回ErrClosed
+ */
+func (b *Batcher[T]) Add(item T) error {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return ErrClosed
+	}
+	b.items = append(b.items, item)
+	if b.size != nil {
+		b.bytes += b.size(item)
+	}
+	full := (b.opts.MaxItems > 0 && len(b.items) >= b.opts.MaxItems) ||
+		(b.opts.MaxBytes > 0 && b.bytes >= b.opts.MaxBytes)
+	b.lock.Unlock()
+
+	if full {
+		b.Flush()
+	}
+	return nil
+}
+
+// Len returns the number of pending items
+func (b *Batcher[T]) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.items)
+}
+
+// Flush hands all pending items to the callback immediately
+/*
+ * 立即flush当前批量
+ * 取出批量与执行回调都在flushMu保护下进行，保证批量按照添加顺序交给回调
+ */
+func (b *Batcher[T]) Flush() {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	b.lock.Lock()
+	items := b.take()
+	b.lock.Unlock()
+	if items != nil {
+		b.flush(items)
+	}
+}
+
+// Close stops the timer and drains the pending items
+/*
+ * 关闭batcher，停止后台协程并flush剩余数据，返回时所有数据都已经交给回调处理
+ * 重复调用是安全的
+ */
+func (b *Batcher[T]) Close() {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		<-b.done
+		return
+	}
+	b.closed = true
+	b.lock.Unlock()
+
+	close(b.stop)
+	<-b.done
+	b.Flush()
+}
+
+/*
+ * 取出当前批量，调用方需要持有lock
+ */
+func (b *Batcher[T]) take() []T {
+	if len(b.items) == 0 {
+		return nil
+	}
+	items := b.items
+	b.items = nil
+	b.bytes = 0
+	return items
+}
+
+func (b *Batcher[T]) tick() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.Flush()
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EvictReason tells why an entry left the cache
+type EvictReason int
+
+const (
+	// EvictExpired the entry reached its TTL
+	EvictExpired EvictReason = iota
+	// EvictCapacity the entry was the least recently used one when MaxEntries or MaxSize was exceeded
+	EvictCapacity
+	// EvictDeleted the entry was removed by Delete, replaced by Set, or dropped by Clear
+	EvictDeleted
+)
+
+// String returns the name of the reason
+func (reason EvictReason) String() string {
+	switch reason {
+	case EvictExpired:
+		return "expired"
+	case EvictCapacity:
+		return "capacity"
+	case EvictDeleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("EvictReason(%d)", int(reason))
+}
+
+// Options configures a Cache, a zero value is an unbounded cache without expiry
+type Options[K comparable, V any] struct {
+	MaxEntries      int              // 最多条目数，超过时淘汰最近最少使用的条目，<=0时不限制
+	MaxSize         int64            // 全部条目Size之和的上限，超过时淘汰最近最少使用的条目，<=0时不限制
+	Size            func(K, V) int64 // 计算单个条目的大小，使用MaxSize时必须设置
+	TTL             time.Duration    // Set以及GetOrLoad写入条目的默认有效期，<=0时不过期
+	CleanupInterval time.Duration    // 后台清理过期条目的间隔，<=0时只在访问时清理，过期条目在被访问或者淘汰之前仍占用内存
+
+	// OnEvict 条目离开缓存时调用，在缓存的锁之外执行，可以访问缓存
+	OnEvict func(key K, value V, reason EvictReason)
+}
+
+// Stats is a snapshot of the cache counters
+type Stats struct {
+	Entries     int    // 当前条目数
+	Size        int64  // 当前条目Size之和
+	Hits        uint64 // Get以及GetOrLoad命中次数
+	Misses      uint64 // 未命中次数，包括已经过期的条目
+	Loads       uint64 // GetOrLoad调用loader的次数
+	LoadErrors  uint64 // loader返回error的次数
+	Evictions   uint64 // 因为容量淘汰的条目数
+	Expirations uint64 // 过期删除的条目数
+}
+
+// HitRate returns hits / (hits + misses), 0 without lookups
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// entry 缓存条目，保存在LRU链表中，链表头部为最近使用的条目
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	size    int64
+	expires time.Time // 为零值时不过期
+}
+
+// evicted 等待回调OnEvict的条目
+type evicted[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// call 正在执行的loader，同一个key的并发GetOrLoad共用结果
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Cache is a concurrent LRU cache with per entry TTL
+/*
+ * 并发安全的内存缓存：每个条目有独立的有效期，超过条目数或者总大小时按照LRU淘汰
+ * 不再使用时调用Close停止后台清理
+ */
+type Cache[K comparable, V any] struct {
+	opts Options[K, V]
+
+	lock    sync.Mutex
+	items   map[K]*list.Element
+	lru     *list.List
+	size    int64
+	loading map[K]*call[V]
+	stats   Stats
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a cache
+/*
+ * 创建缓存，例如：
+ *     users := cache.New(cache.Options[int64, *User]{MaxEntries: 10000, TTL: 5 * time.Minute})
+ * @param opts：配置
+ * @return 缓存
+ */
+func New[K comparable, V any](opts Options[K, V]) *Cache[K, V] {
+	if opts.MaxSize > 0 && opts.Size == nil {
+		panic("cache: MaxSize requires Size")
+	}
+	c := &Cache[K, V]{
+		opts:    opts,
+		items:   make(map[K]*list.Element),
+		lru:     list.New(),
+		loading: make(map[K]*call[V]),
+		stop:    make(chan struct{}),
+	}
+	if opts.CleanupInterval > 0 {
+		go c.cleanupLoop()
+	}
+	return c
+}
+
+// Get returns the value of key if present and not expired
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	var removed []evicted[K, V]
+	c.lock.Lock()
+	value, ok := c.get(key, time.Now(), &removed)
+	c.lock.Unlock()
+	c.notify(removed)
+	return value, ok
+}
+
+/*
+ * 查找条目并更新LRU以及统计，过期的条目删除，需要持有锁
+ */
+func (c *Cache[K, V]) get(key K, now time.Time, removed *[]evicted[K, V]) (V, bool) {
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry[K, V])
+		if e.expires.IsZero() || now.Before(e.expires) {
+			c.lru.MoveToFront(elem)
+			c.stats.Hits++
+			return e.value, true
+		}
+		c.stats.Expirations++
+		*removed = append(*removed, c.remove(elem, EvictExpired))
+	}
+	c.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+// Set stores value under key with the default TTL
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.opts.TTL)
+}
+
+// SetWithTTL stores value under key, expiring after ttl
+/*
+ * 写入条目，已经存在时替换(旧值以EvictDeleted回调)，之后按照容量淘汰
+ * 单个条目的Size超过MaxSize时不写入，旧值同样被删除
+ * @param key：键
+ * @param value：值
+ * @param ttl：有效期，<=0时不过期
+ */
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	var removed []evicted[K, V]
+	c.lock.Lock()
+	c.set(key, value, ttl, &removed)
+	c.lock.Unlock()
+	c.notify(removed)
+}
+
+/*
+ * 写入条目并按照容量淘汰，需要持有锁
+ */
+func (c *Cache[K, V]) set(key K, value V, ttl time.Duration, removed *[]evicted[K, V]) {
+	if elem, ok := c.items[key]; ok {
+		*removed = append(*removed, c.remove(elem, EvictDeleted))
+	}
+	e := &entry[K, V]{key: key, value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	if c.opts.Size != nil {
+		e.size = c.opts.Size(key, value)
+		if c.opts.MaxSize > 0 && e.size > c.opts.MaxSize {
+			return
+		}
+	}
+	c.items[key] = c.lru.PushFront(e)
+	c.size += e.size
+	for (c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries) ||
+		(c.opts.MaxSize > 0 && c.size > c.opts.MaxSize) {
+		c.stats.Evictions++
+		*removed = append(*removed, c.remove(c.lru.Back(), EvictCapacity))
+	}
+}
+
+// GetOrLoad returns the cached value of key, calling loader once for concurrent misses
+/*
+ * 读取缓存，未命中时调用loader加载并以默认TTL写入；同一个key的并发调用只执行一次loader，其余调用等待结果
+ * loader返回error时不写入缓存，等待中的调用得到同一个error
+ * @param key：键
+ * @param loader：加载函数，在缓存的锁之外执行
+ * @return (值, loader的error)
+ */
+func (c *Cache[K, V]) GetOrLoad(key K, loader func(key K) (V, error)) (V, error) {
+	var removed []evicted[K, V]
+	c.lock.Lock()
+	if value, ok := c.get(key, time.Now(), &removed); ok {
+		c.lock.Unlock()
+		c.notify(removed)
+		return value, nil
+	}
+	if pending, ok := c.loading[key]; ok {
+		c.lock.Unlock()
+		c.notify(removed)
+		<-pending.done
+		return pending.value, pending.err
+	}
+	pending := &call[V]{done: make(chan struct{})}
+	c.loading[key] = pending
+	c.stats.Loads++
+	c.lock.Unlock()
+	c.notify(removed)
+	removed = nil
+
+	func() {
+		// loader panic时仍然唤醒等待中的调用
+		defer func() {
+			if r := recover(); r != nil {
+				pending.err = fmt.Errorf("cache: loader panic: %v", r)
+			}
+		}()
+		pending.value, pending.err = loader(key)
+	}()
+
+	c.lock.Lock()
+	delete(c.loading, key)
+	if pending.err != nil {
+		c.stats.LoadErrors++
+	} else {
+		c.set(key, pending.value, c.opts.TTL, &removed)
+	}
+	c.lock.Unlock()
+	close(pending.done)
+	c.notify(removed)
+	return pending.value, pending.err
+}
+
+// Delete removes key, reporting whether it was present
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.lock.Lock()
+	elem, ok := c.items[key]
+	var removed evicted[K, V]
+	if ok {
+		removed = c.remove(elem, EvictDeleted)
+	}
+	c.lock.Unlock()
+	if ok {
+		c.notify([]evicted[K, V]{removed})
+	}
+	return ok
+}
+
+// Clear removes all entries
+func (c *Cache[K, V]) Clear() {
+	c.lock.Lock()
+	removed := make([]evicted[K, V], 0, c.lru.Len())
+	for elem := c.lru.Back(); elem != nil; elem = c.lru.Back() {
+		removed = append(removed, c.remove(elem, EvictDeleted))
+	}
+	c.lock.Unlock()
+	c.notify(removed)
+}
+
+// Len returns the number of entries, including expired ones not yet cleaned up
+func (c *Cache[K, V]) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *Cache[K, V]) Stats() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Size = c.size
+	return stats
+}
+
+// Cleanup removes all expired entries
+func (c *Cache[K, V]) Cleanup() {
+	now := time.Now()
+	var removed []evicted[K, V]
+	c.lock.Lock()
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if e := elem.Value.(*entry[K, V]); !e.expires.IsZero() && !now.Before(e.expires) {
+			c.stats.Expirations++
+			removed = append(removed, c.remove(elem, EvictExpired))
+		}
+		elem = prev
+	}
+	c.lock.Unlock()
+	c.notify(removed)
+}
+
+// Close stops the background cleanup, the cache stays usable
+func (c *Cache[K, V]) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+/*
+ * 定期清理过期条目
+ */
+func (c *Cache[K, V]) cleanupLoop() {
+	ticker := time.NewTicker(c.opts.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Cleanup()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+/*
+ * 从链表以及索引中删除条目，需要持有锁
+ */
+func (c *Cache[K, V]) remove(elem *list.Element, reason EvictReason) evicted[K, V] {
+	e := c.lru.Remove(elem).(*entry[K, V])
+	delete(c.items, e.key)
+	c.size -= e.size
+	return evicted[K, V]{key: e.key, value: e.value, reason: reason}
+}
+
+/*
+ * 在锁之外回调OnEvict
+ */
+func (c *Cache[K, V]) notify(removed []evicted[K, V]) {
+	if c.opts.OnEvict == nil {
+		return
+	}
+	for _, r := range removed {
+		c.opts.OnEvict(r.key, r.value, r.reason)
+	}
+}
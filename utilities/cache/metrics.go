@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"github.com/lucifinil-long/nano-legion/utilities/metrics"
+)
+
+// RegisterMetrics exports the cache stats to reg under cache_<name>_*
+/*
+ * 将缓存统计注册到指标注册表，指标在读取时从Stats计算：
+ *   cache_<name>_entries            当前条目数
+ *   cache_<name>_size               当前条目Size之和
+ *   cache_<name>_hits_total         命中次数
+ *   cache_<name>_misses_total       未命中次数
+ *   cache_<name>_loads_total        loader调用次数
+ *   cache_<name>_load_errors_total  loader失败次数
+ *   cache_<name>_evictions_total    因为容量淘汰的条目数
+ *   cache_<name>_expirations_total  过期删除的条目数
+ * 命中率可以在Prometheus中由hits_total以及misses_total计算
+ * @param reg：指标注册表，为nil时使用metrics.Default()
+ * @param name：缓存名称，只能包含字母、数字以及下划线
+ */
+func (c *Cache[K, V]) RegisterMetrics(reg *metrics.Registry, name string) {
+	if reg == nil {
+		reg = metrics.Default()
+	}
+	prefix := "cache_" + name + "_"
+	reg.GaugeFunc(prefix+"entries", "Entries in the cache.", func() float64 {
+		return float64(c.Stats().Entries)
+	})
+	reg.GaugeFunc(prefix+"size", "Total size of the entries.", func() float64 {
+		return float64(c.Stats().Size)
+	})
+	counters := []struct {
+		name, help string
+		value      func(Stats) uint64
+	}{
+		{"hits_total", "Lookups that found a live entry.", func(s Stats) uint64 { return s.Hits }},
+		{"misses_total", "Lookups that found no live entry.", func(s Stats) uint64 { return s.Misses }},
+		{"loads_total", "Loader calls made by GetOrLoad.", func(s Stats) uint64 { return s.Loads }},
+		{"load_errors_total", "Loader calls that returned an error.", func(s Stats) uint64 { return s.LoadErrors }},
+		{"evictions_total", "Entries evicted by the size limits.", func(s Stats) uint64 { return s.Evictions }},
+		{"expirations_total", "Entries removed after their TTL.", func(s Stats) uint64 { return s.Expirations }},
+	}
+	for _, counter := range counters {
+		value := counter.value
+		reg.CounterFunc(prefix+counter.name, counter.help, func() uint64 {
+			return value(c.Stats())
+		})
+	}
+}
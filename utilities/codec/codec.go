@@ -0,0 +1,129 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// maxPooledBuffer 放回缓冲池的缓冲区最大容量，超过时丢弃，避免偶尔的大消息长期占用内存
+const maxPooledBuffer = 64 * 1024
+
+// ErrUnsupportedType is returned when a value cannot be encoded or decoded by a codec
+var ErrUnsupportedType = errors.New("codec: unsupported type")
+
+// Codec encodes and decodes payloads of log shipping and RPC
+/*
+ * 编解码接口，实现需要可以在多个协程中同时使用
+ * Marshal返回的切片由调用方持有；Append将编码结果追加到dst之后，用于调用方自行复用缓冲区
+ */
+type Codec interface {
+	Name() string        // 编码名称，用于协商以及Lookup，例如msgpack
+	ContentType() string // HTTP请求使用的Content-Type
+	Marshal(v interface{}) ([]byte, error)
+	Append(dst []byte, v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// 内置的编码
+var (
+	JSON     Codec = jsonCodec{}
+	Msgpack  Codec = msgpackCodec{}
+	Protobuf Codec = protobufCodec{}
+)
+
+// 已注册的编码，按照名称索引
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Codec{
+		JSON.Name():     JSON,
+		Msgpack.Name():  Msgpack,
+		Protobuf.Name(): Protobuf,
+	}
+)
+
+// 编码使用的临时缓冲区
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+// Register adds a codec, replacing a registered codec with the same name
+/*
+ * 注册编码，用于Lookup以及按照名称协商编码，内置json/msgpack/protobuf
+ * @param c：编码
+ */
+func Register(c Codec) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[c.Name()] = c
+}
+
+// Lookup returns the codec registered with the name
+/*
+ * 按照名称查找编码
+ * @param name：编码名称
+ * @return (编码, 是否存在)
+ */
+func Lookup(name string) (Codec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[name]
+	return c, ok
+}
+
+// Names returns the names of the registered codecs in sorted order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+ * 使用缓冲池中的缓冲区编码，返回长度刚好的副本，编码过程中缓冲区扩容不会产生额外的分配
+ * @param appendFn：将编码结果追加到缓冲区
+ */
+func marshalPooled(v interface{}, appendFn func(dst []byte, v interface{}) ([]byte, error)) ([]byte, error) {
+	bufp := bufPool.Get().(*[]byte)
+	buf, err := appendFn((*bufp)[:0], v)
+	var out []byte
+	if err == nil {
+		out = make([]byte, len(buf))
+		copy(out, buf)
+	}
+	if cap(buf) <= maxPooledBuffer {
+		*bufp = buf[:0]
+		bufPool.Put(bufp)
+	}
+	return out, err
+}
+
+// jsonCodec encoding/json编码，用于协商时与二进制编码统一处理
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string        { return "json" }
+func (jsonCodec) ContentType() string { return "application/json" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Append(dst []byte, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, data...), nil
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
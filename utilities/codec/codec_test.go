@@ -0,0 +1,237 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+type sample struct {
+	Time    time.Time         `json:"time" msgpack:"time"`
+	Level   string            `json:"level" msgpack:"level"`
+	Caller  string            `json:"caller,omitempty" msgpack:"caller,omitempty"`
+	Message string            `json:"msg" msgpack:"msg"`
+	Latency float64           `json:"latency" msgpack:"latency"`
+	Status  int               `json:"status" msgpack:"status"`
+	Tags    []string          `json:"tags" msgpack:"tags"`
+	Fields  map[string]string `json:"fields" msgpack:"fields"`
+	Raw     []byte            `json:"raw" msgpack:"raw"`
+	Skipped string            `json:"-" msgpack:"-"`
+}
+
+func newSample() sample {
+	return sample{
+		Time:    time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC),
+		Level:   "warn",
+		Caller:  "github.com/lucifinil-long/nano-legion/utilities/rpc/client.go,42:rpc.(*Client).Call",
+		Message: "upstream slow|order-service|retrying",
+		Latency: 1.25,
+		Status:  503,
+		Tags:    []string{"rpc", "retry"},
+		Fields:  map[string]string{"request_id": "01HXAMPLE", "user": "42", "region": "eu-west-1"},
+		Raw:     []byte{0, 1, 2, 0xff},
+	}
+}
+
+func newRecord() *LogRecord {
+	s := newSample()
+	return &LogRecord{
+		Time:    s.Time.UnixNano(),
+		Level:   s.Level,
+		Caller:  s.Caller,
+		Message: s.Message,
+		Fields:  s.Fields,
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	in := newSample()
+	in.Skipped = "not encoded"
+	data, err := Msgpack.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var out sample
+	if err := Msgpack.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !out.Time.Equal(in.Time) {
+		t.Errorf("time: got %v, want %v", out.Time, in.Time)
+	}
+	out.Time, in.Time, in.Skipped = time.Time{}, time.Time{}, ""
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestMsgpackScalars(t *testing.T) {
+	values := []interface{}{
+		nil, true, false,
+		int64(0), int64(127), int64(128), int64(-1), int64(-32), int64(-33), int64(-129), int64(math.MinInt16 - 1),
+		int64(math.MaxUint16 + 1), int64(math.MinInt64), int64(math.MaxInt64), uint64(math.MaxUint64),
+		1.5, "", "short", string(make([]byte, 40)), string(make([]byte, 300)), string(make([]byte, 70000)),
+		[]byte{}, make([]byte, 300),
+		[]interface{}{int64(1), "a", []interface{}{}},
+		map[string]interface{}{"a": int64(1), "b": map[string]interface{}{"c": nil}},
+	}
+	for _, in := range values {
+		data, err := Msgpack.Marshal(in)
+		if err != nil {
+			t.Fatalf("Marshal(%T): %v", in, err)
+		}
+		var out interface{}
+		if err := Msgpack.Unmarshal(data, &out); err != nil {
+			t.Fatalf("Unmarshal(%T): %v", in, err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("round trip of %T: got %v", in, out)
+		}
+	}
+
+	for _, in := range []time.Time{time.Unix(1700000000, 0), time.Unix(1700000000, 5), time.Unix(-5, 7), time.Unix(1<<35, 1)} {
+		data := AppendTime(nil, in)
+		out, rest, err := ReadMsgpack(data)
+		if err != nil || len(rest) != 0 || !out.(time.Time).Equal(in) {
+			t.Errorf("time %v: got %v, %d bytes left, %v", in, out, len(rest), err)
+		}
+	}
+}
+
+func TestMsgpackTruncated(t *testing.T) {
+	data, _ := Msgpack.Marshal(newSample())
+	for i := 0; i < len(data); i++ {
+		var out interface{}
+		if err := Msgpack.Unmarshal(data[:i], &out); err == nil {
+			t.Fatalf("no error for %d of %d bytes", i, len(data))
+		}
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	in := newRecord()
+	data, err := Protobuf.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var out LogRecord
+	if err := Protobuf.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(&out, in) {
+		t.Errorf("got %v, want %v", &out, in)
+	}
+	if _, err := Protobuf.Marshal(newSample()); err != ErrUnsupportedType {
+		t.Errorf("Marshal of a non-message: %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"json", "msgpack", "protobuf"} {
+		if c, ok := Lookup(name); !ok || c.Name() != name {
+			t.Errorf("Lookup(%q) = %v, %v", name, c, ok)
+		}
+	}
+}
+
+func TestLogRecordAppendProto(t *testing.T) {
+	r := newRecord()
+	r.Fields = map[string]string{"request_id": "01HXAMPLE"}
+	fast, _ := r.AppendProto(nil)
+	p := proto.NewBuffer(nil)
+	if err := p.Marshal(r); err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+	if !bytes.Equal(fast, p.Bytes()) {
+		t.Errorf("AppendProto differs from proto.Marshal:\n%x\n%x", fast, p.Bytes())
+	}
+}
+
+func BenchmarkMarshalJSON(b *testing.B) {
+	s := newSample()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalMsgpack(b *testing.B) {
+	s := newSample()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Msgpack.Marshal(s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendMsgpack(b *testing.B) {
+	s := newSample()
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = Msgpack.Append(buf[:0], s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalJSONRecord(b *testing.B) {
+	r := newRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalProtobuf(b *testing.B) {
+	r := newRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Protobuf.Marshal(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalJSON(b *testing.B) {
+	data, _ := json.Marshal(newSample())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s sample
+		if err := json.Unmarshal(data, &s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalMsgpack(b *testing.B) {
+	data, _ := Msgpack.Marshal(newSample())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s sample
+		if err := Msgpack.Unmarshal(data, &s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalProtobuf(b *testing.B) {
+	data, _ := Protobuf.Marshal(newRecord())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var r LogRecord
+		if err := Protobuf.Unmarshal(data, &r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
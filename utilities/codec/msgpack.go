@@ -0,0 +1,938 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// msgpack的类型标记
+const (
+	mpNil     = 0xc0
+	mpFalse   = 0xc2
+	mpTrue    = 0xc3
+	mpBin8    = 0xc4
+	mpBin16   = 0xc5
+	mpBin32   = 0xc6
+	mpExt8    = 0xc7
+	mpExt16   = 0xc8
+	mpExt32   = 0xc9
+	mpFloat32 = 0xca
+	mpFloat64 = 0xcb
+	mpUint8   = 0xcc
+	mpUint16  = 0xcd
+	mpUint32  = 0xce
+	mpUint64  = 0xcf
+	mpInt8    = 0xd0
+	mpInt16   = 0xd1
+	mpInt32   = 0xd2
+	mpInt64   = 0xd3
+	mpFixExt1 = 0xd4
+	mpFixExt4 = 0xd6
+	mpFixExt8 = 0xd7
+	mpStr8    = 0xd9
+	mpStr16   = 0xda
+	mpStr32   = 0xdb
+	mpArray16 = 0xdc
+	mpArray32 = 0xdd
+	mpMap16   = 0xde
+	mpMap32   = 0xdf
+
+	mpTimeExt = -1 // 时间戳扩展类型
+)
+
+// ErrShortBuffer is returned when msgpack data ends in the middle of a value
+var ErrShortBuffer = errors.New("codec: msgpack data truncated")
+
+// MsgpackAppender is implemented by types encoding themselves as msgpack
+type MsgpackAppender interface {
+	AppendMsgpack(dst []byte) ([]byte, error)
+}
+
+// Ext is a msgpack extension value other than timestamps
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// msgpackCodec 不依赖第三方库的msgpack编码
+/*
+ * 编码支持基本类型、string、[]byte、time.Time(时间戳扩展)、error/fmt.Stringer(字符串)、
+ * 切片、数组、map以及结构体(导出字段，可以通过`msgpack:"name,omitempty"`或者`msgpack:"-"`指定)，
+ * 以及实现了MsgpackAppender的类型
+ * 解码到*interface{}时整数为int64(超过int64范围时为uint64)，浮点数为float64，map为map[string]interface{}，数组为[]interface{}；
+ * 解码到其他类型时按照编码的规则赋值
+ */
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string        { return "msgpack" }
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return marshalPooled(v, AppendMsgpack)
+}
+
+func (msgpackCodec) Append(dst []byte, v interface{}) ([]byte, error) {
+	return AppendMsgpack(dst, v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	var rest []byte
+	var err error
+	if p, ok := v.(*interface{}); ok {
+		*p, rest, err = ReadMsgpack(data)
+	} else {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return ErrUnsupportedType
+		}
+		rest, err = decode(rv.Elem(), data)
+	}
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("codec: %d trailing bytes after msgpack value", len(rest))
+	}
+	return nil
+}
+
+// AppendMsgpack appends the msgpack encoding of v to dst
+/*
+ * 将v编码为msgpack追加到dst，支持的类型参考Msgpack
+ * @return (追加之后的切片, error)，类型不支持时返回ErrUnsupportedType
+ */
+func AppendMsgpack(dst []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(dst, mpNil), nil
+	case bool:
+		return AppendBool(dst, v), nil
+	case int:
+		return AppendInt(dst, int64(v)), nil
+	case int8:
+		return AppendInt(dst, int64(v)), nil
+	case int16:
+		return AppendInt(dst, int64(v)), nil
+	case int32:
+		return AppendInt(dst, int64(v)), nil
+	case int64:
+		return AppendInt(dst, v), nil
+	case uint:
+		return AppendUint(dst, uint64(v)), nil
+	case uint8:
+		return AppendUint(dst, uint64(v)), nil
+	case uint16:
+		return AppendUint(dst, uint64(v)), nil
+	case uint32:
+		return AppendUint(dst, uint64(v)), nil
+	case uint64:
+		return AppendUint(dst, v), nil
+	case float32:
+		return AppendFloat32(dst, v), nil
+	case float64:
+		return AppendFloat64(dst, v), nil
+	case string:
+		return AppendString(dst, v), nil
+	case []byte:
+		return AppendBytes(dst, v), nil
+	case time.Time:
+		return AppendTime(dst, v), nil
+	case time.Duration:
+		return AppendInt(dst, int64(v)), nil
+	case MsgpackAppender:
+		return v.AppendMsgpack(dst)
+	case error:
+		return AppendString(dst, v.Error()), nil
+	case fmt.Stringer:
+		return AppendString(dst, v.String()), nil
+	case map[string]interface{}:
+		dst = AppendMapHeader(dst, len(v))
+		var err error
+		for key, value := range v {
+			dst = AppendString(dst, key)
+			if dst, err = AppendMsgpack(dst, value); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	case map[string]string:
+		dst = AppendMapHeader(dst, len(v))
+		for key, value := range v {
+			dst = AppendString(dst, key)
+			dst = AppendString(dst, value)
+		}
+		return dst, nil
+	case []interface{}:
+		dst = AppendArrayHeader(dst, len(v))
+		var err error
+		for _, value := range v {
+			if dst, err = AppendMsgpack(dst, value); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	case []string:
+		dst = AppendArrayHeader(dst, len(v))
+		for _, value := range v {
+			dst = AppendString(dst, value)
+		}
+		return dst, nil
+	}
+	return appendReflect(dst, reflect.ValueOf(v))
+}
+
+// AppendBool appends a msgpack bool
+func AppendBool(dst []byte, v bool) []byte {
+	if v {
+		return append(dst, mpTrue)
+	}
+	return append(dst, mpFalse)
+}
+
+// AppendInt appends a msgpack integer in its shortest form
+func AppendInt(dst []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return AppendUint(dst, uint64(v))
+	case v >= -32:
+		return append(dst, byte(v))
+	case v >= math.MinInt8:
+		return append(dst, mpInt8, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(dst, mpInt16), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(dst, mpInt32), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(dst, mpInt64), uint64(v))
+}
+
+// AppendUint appends a msgpack unsigned integer in its shortest form
+func AppendUint(dst []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(dst, byte(v))
+	case v <= math.MaxUint8:
+		return append(dst, mpUint8, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, mpUint16), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, mpUint32), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(dst, mpUint64), v)
+}
+
+// AppendFloat32 appends a msgpack float32
+func AppendFloat32(dst []byte, v float32) []byte {
+	return binary.BigEndian.AppendUint32(append(dst, mpFloat32), math.Float32bits(v))
+}
+
+// AppendFloat64 appends a msgpack float64
+func AppendFloat64(dst []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(dst, mpFloat64), math.Float64bits(v))
+}
+
+// AppendString appends a msgpack str
+func AppendString(dst []byte, v string) []byte {
+	n := len(v)
+	switch {
+	case n < 32:
+		dst = append(dst, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		dst = append(dst, mpStr8, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, mpStr16), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, mpStr32), uint32(n))
+	}
+	return append(dst, v...)
+}
+
+// AppendBytes appends a msgpack bin
+func AppendBytes(dst []byte, v []byte) []byte {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		dst = append(dst, mpBin8, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, mpBin16), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, mpBin32), uint32(n))
+	}
+	return append(dst, v...)
+}
+
+// AppendTime appends a msgpack timestamp extension
+func AppendTime(dst []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		dst = append(dst, mpFixExt4, byte(mpTimeExt&0xff))
+		return binary.BigEndian.AppendUint32(dst, uint32(sec))
+	case sec >= 0 && sec>>34 == 0:
+		dst = append(dst, mpFixExt8, byte(mpTimeExt&0xff))
+		return binary.BigEndian.AppendUint64(dst, nsec<<34|uint64(sec))
+	}
+	dst = append(dst, mpExt8, 12, byte(mpTimeExt&0xff))
+	dst = binary.BigEndian.AppendUint32(dst, uint32(nsec))
+	return binary.BigEndian.AppendUint64(dst, uint64(sec))
+}
+
+// AppendArrayHeader appends the header of a msgpack array of n elements
+func AppendArrayHeader(dst []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(dst, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, mpArray16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(dst, mpArray32), uint32(n))
+}
+
+// AppendMapHeader appends the header of a msgpack map of n pairs
+func AppendMapHeader(dst []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(dst, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, mpMap16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(dst, mpMap32), uint32(n))
+}
+
+// structField 结构体中参与编码的字段
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// 结构体字段缓存，按照类型索引，值为[]structField
+var structFields sync.Map
+
+/*
+ * 通过反射编码类型断言之外的值
+ */
+func appendReflect(dst []byte, rv reflect.Value) ([]byte, error) {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return append(dst, mpNil), nil
+		}
+		return AppendMsgpack(dst, rv.Elem().Interface())
+	case reflect.Bool:
+		return AppendBool(dst, rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return AppendInt(dst, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return AppendUint(dst, rv.Uint()), nil
+	case reflect.Float32:
+		return AppendFloat32(dst, float32(rv.Float())), nil
+	case reflect.Float64:
+		return AppendFloat64(dst, rv.Float()), nil
+	case reflect.String:
+		return AppendString(dst, rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return append(dst, mpNil), nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if rv.Kind() == reflect.Slice {
+				return AppendBytes(dst, rv.Bytes()), nil
+			}
+			data := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(data), rv)
+			return AppendBytes(dst, data), nil
+		}
+		dst = AppendArrayHeader(dst, rv.Len())
+		var err error
+		for i := 0; i < rv.Len(); i++ {
+			if dst, err = AppendMsgpack(dst, rv.Index(i).Interface()); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return append(dst, mpNil), nil
+		}
+		dst = AppendMapHeader(dst, rv.Len())
+		var err error
+		iter := rv.MapRange()
+		for iter.Next() {
+			if dst, err = AppendMsgpack(dst, iter.Key().Interface()); err != nil {
+				return dst, err
+			}
+			if dst, err = AppendMsgpack(dst, iter.Value().Interface()); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	case reflect.Struct:
+		fields := fieldsOf(rv.Type())
+		n := 0
+		for _, field := range fields {
+			if !field.omitEmpty || !rv.FieldByIndex(field.index).IsZero() {
+				n++
+			}
+		}
+		dst = AppendMapHeader(dst, n)
+		var err error
+		for _, field := range fields {
+			value := rv.FieldByIndex(field.index)
+			if field.omitEmpty && value.IsZero() {
+				continue
+			}
+			dst = AppendString(dst, field.name)
+			if dst, err = AppendMsgpack(dst, value.Interface()); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	}
+	return dst, ErrUnsupportedType
+}
+
+/*
+ * 获取结构体参与编码的字段，不展开匿名字段
+ */
+func fieldsOf(t reflect.Type) []structField {
+	if cached, ok := structFields.Load(t); ok {
+		return cached.([]structField)
+	}
+	fields := make([]structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		field := structField{name: f.Name, index: f.Index}
+		if tag, ok := f.Tag.Lookup("msgpack"); ok {
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name != "" {
+				field.name = name
+			}
+			field.omitEmpty = opts == "omitempty"
+		}
+		fields = append(fields, field)
+	}
+	structFields.Store(t, fields)
+	return fields
+}
+
+// ReadMsgpack decodes the first msgpack value of data
+/*
+ * 解码data开头的一个msgpack值，可以用于逐个读取连续写入的多个值
+ * @return (值, 剩余的数据, error)，值的类型参考Msgpack
+ */
+func ReadMsgpack(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, data, ErrShortBuffer
+	}
+	c, data := data[0], data[1:]
+	switch {
+	case c <= 0x7f:
+		return int64(c), data, nil
+	case c >= 0xe0:
+		return int64(int8(c)), data, nil
+	case c&0xe0 == 0xa0:
+		return readString(data, int(c&0x1f))
+	case c&0xf0 == 0x90:
+		return readArray(data, int(c&0x0f))
+	case c&0xf0 == 0x80:
+		return readMap(data, int(c&0x0f))
+	}
+	switch c {
+	case mpNil:
+		return nil, data, nil
+	case mpFalse:
+		return false, data, nil
+	case mpTrue:
+		return true, data, nil
+	case mpUint8, mpUint16, mpUint32, mpUint64:
+		size := 1 << (c - mpUint8)
+		if len(data) < size {
+			return nil, data, ErrShortBuffer
+		}
+		v := readUint(data[:size])
+		if v > math.MaxInt64 {
+			return v, data[size:], nil
+		}
+		return int64(v), data[size:], nil
+	case mpInt8, mpInt16, mpInt32, mpInt64:
+		size := 1 << (c - mpInt8)
+		if len(data) < size {
+			return nil, data, ErrShortBuffer
+		}
+		v := readUint(data[:size])
+		shift := 64 - 8*uint(size)
+		return int64(v<<shift) >> shift, data[size:], nil
+	case mpFloat32:
+		if len(data) < 4 {
+			return nil, data, ErrShortBuffer
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), data[4:], nil
+	case mpFloat64:
+		if len(data) < 8 {
+			return nil, data, ErrShortBuffer
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), data[8:], nil
+	case mpStr8, mpStr16, mpStr32:
+		n, rest, err := readLength(data, 1<<(c-mpStr8))
+		if err != nil {
+			return nil, data, err
+		}
+		return readString(rest, n)
+	case mpBin8, mpBin16, mpBin32:
+		n, rest, err := readLength(data, 1<<(c-mpBin8))
+		if err != nil {
+			return nil, data, err
+		}
+		if len(rest) < n {
+			return nil, data, ErrShortBuffer
+		}
+		data := make([]byte, n)
+		copy(data, rest)
+		return data, rest[n:], nil
+	case mpArray16, mpArray32:
+		n, rest, err := readLength(data, 2<<(c-mpArray16))
+		if err != nil {
+			return nil, data, err
+		}
+		return readArray(rest, n)
+	case mpMap16, mpMap32:
+		n, rest, err := readLength(data, 2<<(c-mpMap16))
+		if err != nil {
+			return nil, data, err
+		}
+		return readMap(rest, n)
+	case mpFixExt1, mpFixExt1 + 1, mpFixExt4, mpFixExt8, mpFixExt8 + 1:
+		return readExt(data, 1<<(c-mpFixExt1))
+	case mpExt8, mpExt16, mpExt32:
+		n, rest, err := readLength(data, 1<<(c-mpExt8))
+		if err != nil {
+			return nil, data, err
+		}
+		return readExt(rest, n)
+	}
+	return nil, data, fmt.Errorf("codec: invalid msgpack type 0x%02x", c)
+}
+
+/*
+ * 读取size字节的大端无符号整数
+ */
+func readUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+/*
+ * 读取size字节的长度
+ */
+func readLength(data []byte, size int) (int, []byte, error) {
+	if len(data) < size {
+		return 0, data, ErrShortBuffer
+	}
+	n := readUint(data[:size])
+	if n > uint64(math.MaxInt32) {
+		return 0, data, ErrShortBuffer
+	}
+	return int(n), data[size:], nil
+}
+
+func readString(data []byte, n int) (interface{}, []byte, error) {
+	if len(data) < n {
+		return nil, data, ErrShortBuffer
+	}
+	return string(data[:n]), data[n:], nil
+}
+
+func readArray(data []byte, n int) (interface{}, []byte, error) {
+	// 每个元素至少一个字节，避免损坏的长度导致过大的分配
+	if len(data) < n {
+		return nil, data, ErrShortBuffer
+	}
+	values := make([]interface{}, n)
+	var err error
+	for i := range values {
+		if values[i], data, err = ReadMsgpack(data); err != nil {
+			return nil, data, err
+		}
+	}
+	return values, data, nil
+}
+
+func readMap(data []byte, n int) (interface{}, []byte, error) {
+	if len(data) < 2*n {
+		return nil, data, ErrShortBuffer
+	}
+	values := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		var key, value interface{}
+		var err error
+		if key, data, err = ReadMsgpack(data); err != nil {
+			return nil, data, err
+		}
+		if value, data, err = ReadMsgpack(data); err != nil {
+			return nil, data, err
+		}
+		switch key := key.(type) {
+		case string:
+			values[key] = value
+		case []byte:
+			values[string(key)] = value
+		default:
+			values[fmt.Sprint(key)] = value
+		}
+	}
+	return values, data, nil
+}
+
+/*
+ * 读取扩展类型，时间戳解码为time.Time，其他类型解码为Ext
+ */
+func readExt(data []byte, n int) (interface{}, []byte, error) {
+	if len(data) < n+1 {
+		return nil, data, ErrShortBuffer
+	}
+	typ, body, rest := int8(data[0]), data[1:n+1], data[n+1:]
+	if typ != mpTimeExt {
+		return Ext{Type: typ, Data: append([]byte(nil), body...)}, rest, nil
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(body)), 0), rest, nil
+	case 8:
+		v := binary.BigEndian.Uint64(body)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), rest, nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(body)
+		return time.Unix(int64(binary.BigEndian.Uint64(body[4:])), int64(nsec)), rest, nil
+	}
+	return nil, rest, fmt.Errorf("codec: invalid msgpack timestamp length %d", n)
+}
+
+// 赋值时特殊处理的类型
+var timeType = reflect.TypeOf(time.Time{})
+
+/*
+ * 将data开头的msgpack值直接解码到rv，字符串、数组、map以及结构体不经过interface{}中转
+ * @return (剩余的数据, error)
+ */
+func decode(rv reflect.Value, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, ErrShortBuffer
+	}
+	if data[0] == mpNil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return data[1:], nil
+	}
+	if rv.Type() != timeType {
+		switch rv.Kind() {
+		case reflect.Ptr:
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			return decode(rv.Elem(), data)
+		case reflect.String:
+			if raw, rest, ok := readRaw(data); ok {
+				rv.SetString(string(raw))
+				return rest, nil
+			}
+		case reflect.Slice:
+			if rv.Type().Elem().Kind() == reflect.Uint8 {
+				if raw, rest, ok := readRaw(data); ok {
+					rv.SetBytes(append([]byte{}, raw...))
+					return rest, nil
+				}
+				break
+			}
+			if n, rest, ok := readHeader(data, 0x90, mpArray16); ok {
+				if len(rest) < n {
+					return data, ErrShortBuffer
+				}
+				slice := reflect.MakeSlice(rv.Type(), n, n)
+				var err error
+				for i := 0; i < n; i++ {
+					if rest, err = decode(slice.Index(i), rest); err != nil {
+						return rest, err
+					}
+				}
+				rv.Set(slice)
+				return rest, nil
+			}
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				break
+			}
+			if n, rest, ok := readHeader(data, 0x80, mpMap16); ok {
+				if len(rest) < 2*n {
+					return data, ErrShortBuffer
+				}
+				m := reflect.MakeMapWithSize(rv.Type(), n)
+				elemType := rv.Type().Elem()
+				for i := 0; i < n; i++ {
+					key, next, ok := readRaw(rest)
+					if !ok {
+						return rest, fmt.Errorf("codec: msgpack map key is not a string")
+					}
+					elem := reflect.New(elemType).Elem()
+					var err error
+					if rest, err = decode(elem, next); err != nil {
+						return rest, err
+					}
+					m.SetMapIndex(reflect.ValueOf(string(key)).Convert(rv.Type().Key()), elem)
+				}
+				rv.Set(m)
+				return rest, nil
+			}
+		case reflect.Struct:
+			if n, rest, ok := readHeader(data, 0x80, mpMap16); ok {
+				fields := fieldsOf(rv.Type())
+				for i := 0; i < n; i++ {
+					key, next, ok := readRaw(rest)
+					if !ok {
+						return rest, fmt.Errorf("codec: msgpack map key is not a string")
+					}
+					var err error
+					rest = next
+					found := false
+					for _, field := range fields {
+						if field.name == string(key) {
+							found = true
+							rest, err = decode(rv.FieldByIndex(field.index), rest)
+							break
+						}
+					}
+					if !found {
+						_, rest, err = ReadMsgpack(rest)
+					}
+					if err != nil {
+						return rest, err
+					}
+				}
+				return rest, nil
+			}
+		}
+	}
+	value, rest, err := ReadMsgpack(data)
+	if err != nil {
+		return rest, err
+	}
+	return rest, assign(rv, value)
+}
+
+/*
+ * 读取str或者bin的内容，不复制
+ * @return (内容, 剩余的数据, 是否为str或者bin)
+ */
+func readRaw(data []byte) ([]byte, []byte, bool) {
+	if len(data) == 0 {
+		return nil, data, false
+	}
+	c := data[0]
+	var n int
+	var rest []byte
+	var err error
+	switch {
+	case c&0xe0 == 0xa0:
+		n, rest = int(c&0x1f), data[1:]
+	case c >= mpStr8 && c <= mpStr32:
+		n, rest, err = readLength(data[1:], 1<<(c-mpStr8))
+	case c >= mpBin8 && c <= mpBin32:
+		n, rest, err = readLength(data[1:], 1<<(c-mpBin8))
+	default:
+		return nil, data, false
+	}
+	if err != nil || len(rest) < n {
+		return nil, data, false
+	}
+	return rest[:n], rest[n:], true
+}
+
+/*
+ * 读取数组或者map的元素个数
+ * @param fix：fixarray/fixmap的标记
+ * @param first：array16/map16的标记，之后一个标记为array32/map32
+ * @return (元素个数, 剩余的数据, 是否为该类型)
+ */
+func readHeader(data []byte, fix, first byte) (int, []byte, bool) {
+	if len(data) == 0 {
+		return 0, data, false
+	}
+	switch c := data[0]; {
+	case c&0xf0 == fix:
+		return int(c & 0x0f), data[1:], true
+	case c == first || c == first+1:
+		n, rest, err := readLength(data[1:], 2<<(c-first))
+		return n, rest, err == nil
+	}
+	return 0, data, false
+}
+
+/*
+ * 将ReadMsgpack解码的值赋给rv
+ */
+func assign(rv reflect.Value, value interface{}) error {
+	if value == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	if rv.Type() == timeType {
+		t, ok := value.(time.Time)
+		if !ok {
+			return mismatch(rv, value)
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return mismatch(rv, value)
+		}
+		rv.Set(reflect.ValueOf(value))
+		return nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return assign(rv.Elem(), value)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return mismatch(rv, value)
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(int64)
+		if !ok || rv.OverflowInt(n) {
+			return mismatch(rv, value)
+		}
+		rv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch v := value.(type) {
+		case int64:
+			if v < 0 {
+				return mismatch(rv, value)
+			}
+			n = uint64(v)
+		case uint64:
+			n = v
+		default:
+			return mismatch(rv, value)
+		}
+		if rv.OverflowUint(n) {
+			return mismatch(rv, value)
+		}
+		rv.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch v := value.(type) {
+		case float64:
+			rv.SetFloat(v)
+		case int64:
+			rv.SetFloat(float64(v))
+		case uint64:
+			rv.SetFloat(float64(v))
+		default:
+			return mismatch(rv, value)
+		}
+		return nil
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			rv.SetString(v)
+		case []byte:
+			rv.SetString(string(v))
+		default:
+			return mismatch(rv, value)
+		}
+		return nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			switch v := value.(type) {
+			case []byte:
+				rv.SetBytes(v)
+				return nil
+			case string:
+				rv.SetBytes([]byte(v))
+				return nil
+			}
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			return mismatch(rv, value)
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := assign(slice.Index(i), v); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	case reflect.Array:
+		values, ok := value.([]interface{})
+		if !ok || len(values) != rv.Len() {
+			if data, isBytes := value.([]byte); isBytes && len(data) == rv.Len() && rv.Type().Elem().Kind() == reflect.Uint8 {
+				reflect.Copy(rv, reflect.ValueOf(data))
+				return nil
+			}
+			return mismatch(rv, value)
+		}
+		for i, v := range values {
+			if err := assign(rv.Index(i), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		values, ok := value.(map[string]interface{})
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return mismatch(rv, value)
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(values))
+		for key, v := range values {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := assign(elem, v); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+		}
+		rv.Set(m)
+		return nil
+	case reflect.Struct:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch(rv, value)
+		}
+		for _, field := range fieldsOf(rv.Type()) {
+			if v, ok := values[field.name]; ok {
+				if err := assign(rv.FieldByIndex(field.index), v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return mismatch(rv, value)
+}
+
+func mismatch(rv reflect.Value, value interface{}) error {
+	return fmt.Errorf("codec: cannot decode msgpack %T into %s", value, rv.Type())
+}
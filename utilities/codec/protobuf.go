@@ -0,0 +1,89 @@
+package codec
+
+import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// protobuf编解码使用的proto.Buffer，编码时保留内部的缓冲区，解码时只复用Buffer本身
+var (
+	protoPool = sync.Pool{
+		New: func() interface{} {
+			return proto.NewBuffer(make([]byte, 0, 1024))
+		},
+	}
+	protoDecodePool = sync.Pool{
+		New: func() interface{} {
+			return proto.NewBuffer(nil)
+		},
+	}
+)
+
+// ProtoAppender is implemented by messages encoding themselves without reflection, such as LogRecord
+type ProtoAppender interface {
+	AppendProto(dst []byte) ([]byte, error)
+}
+
+// protobufCodec 使用缓冲池的protobuf编码，只支持proto.Message，实现了ProtoAppender的消息直接追加
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string        { return "protobuf" }
+func (protobufCodec) ContentType() string { return "application/x-protobuf" }
+
+func (c protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	return c.encode(nil, v, true)
+}
+
+func (c protobufCodec) Append(dst []byte, v interface{}) ([]byte, error) {
+	return c.encode(dst, v, false)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return ErrUnsupportedType
+	}
+	msg.Reset()
+	p := protoDecodePool.Get().(*proto.Buffer)
+	p.SetBuf(data)
+	err := p.Unmarshal(msg)
+	// 不保留调用方的数据
+	p.SetBuf(nil)
+	protoDecodePool.Put(p)
+	return err
+}
+
+/*
+ * 在缓冲池的proto.Buffer中编码之后复制出来，避免proto.Marshal每次从空切片开始扩容
+ * @param exact：为true时返回长度刚好的新切片，否则追加到dst
+ */
+func (protobufCodec) encode(dst []byte, v interface{}, exact bool) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return dst, ErrUnsupportedType
+	}
+	if appender, ok := msg.(ProtoAppender); ok {
+		if exact {
+			return marshalPooled(v, func(dst []byte, _ interface{}) ([]byte, error) {
+				return appender.AppendProto(dst)
+			})
+		}
+		return appender.AppendProto(dst)
+	}
+	p := protoPool.Get().(*proto.Buffer)
+	p.Reset()
+	err := p.Marshal(msg)
+	if err == nil {
+		if exact {
+			dst = make([]byte, len(p.Bytes()))
+			copy(dst, p.Bytes())
+		} else {
+			dst = append(dst, p.Bytes()...)
+		}
+	}
+	if cap(p.Bytes()) <= maxPooledBuffer {
+		protoPool.Put(p)
+	}
+	return dst, err
+}
@@ -0,0 +1,91 @@
+package codec
+
+import (
+	"encoding/binary"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// protobuf wire type
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// LogRecord is the protobuf message of a shipped log record, defined in record.proto
+/*
+ * 日志记录的protobuf消息，logger.ProtoEncoder编码为该消息，收集端使用record.proto生成对应语言的代码解码
+ * 附加字段的值统一转换为字符串
+ */
+type LogRecord struct {
+	Time    int64             `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+	Level   string            `protobuf:"bytes,2,opt,name=level,proto3" json:"level,omitempty"`
+	Logger  string            `protobuf:"bytes,3,opt,name=logger,proto3" json:"logger,omitempty"`
+	Caller  string            `protobuf:"bytes,4,opt,name=caller,proto3" json:"caller,omitempty"`
+	Message string            `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	Suffix  string            `protobuf:"bytes,6,opt,name=suffix,proto3" json:"suffix,omitempty"`
+	Stack   string            `protobuf:"bytes,7,opt,name=stack,proto3" json:"stack,omitempty"`
+	Fields  map[string]string `protobuf:"bytes,8,rep,name=fields" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+// Reset implements proto.Message
+func (m *LogRecord) Reset() { *m = LogRecord{} }
+
+// String implements proto.Message
+func (m *LogRecord) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*LogRecord) ProtoMessage() {}
+
+// AppendProto appends the wire encoding of the record to dst without reflection
+/*
+ * 不经过反射直接编码，Protobuf编码LogRecord时使用，结果与proto.Marshal相同(map的顺序除外)
+ */
+func (m *LogRecord) AppendProto(dst []byte) ([]byte, error) {
+	if m.Time != 0 {
+		dst = binary.AppendUvarint(dst, 1<<3|wireVarint)
+		dst = binary.AppendUvarint(dst, uint64(m.Time))
+	}
+	dst = appendProtoString(dst, 2, m.Level)
+	dst = appendProtoString(dst, 3, m.Logger)
+	dst = appendProtoString(dst, 4, m.Caller)
+	dst = appendProtoString(dst, 5, m.Message)
+	dst = appendProtoString(dst, 6, m.Suffix)
+	dst = appendProtoString(dst, 7, m.Stack)
+	for key, value := range m.Fields {
+		// map的每一项为一个key=1、value=2的消息
+		size := 1 + uvarintSize(len(key)) + len(key) + 1 + uvarintSize(len(value)) + len(value)
+		dst = binary.AppendUvarint(dst, 8<<3|wireBytes)
+		dst = binary.AppendUvarint(dst, uint64(size))
+		dst = binary.AppendUvarint(dst, 1<<3|wireBytes)
+		dst = binary.AppendUvarint(dst, uint64(len(key)))
+		dst = append(dst, key...)
+		dst = binary.AppendUvarint(dst, 2<<3|wireBytes)
+		dst = binary.AppendUvarint(dst, uint64(len(value)))
+		dst = append(dst, value...)
+	}
+	return dst, nil
+}
+
+/*
+ * 追加proto3的string字段，空字符串省略
+ */
+func appendProtoString(dst []byte, field uint64, s string) []byte {
+	if s == "" {
+		return dst
+	}
+	dst = binary.AppendUvarint(dst, field<<3|wireBytes)
+	dst = binary.AppendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+/*
+ * varint编码的字节数
+ */
+func uvarintSize(n int) int {
+	size := 1
+	for ; n >= 0x80; n >>= 7 {
+		size++
+	}
+	return size
+}
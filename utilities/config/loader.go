@@ -0,0 +1,608 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/process"
+)
+
+// confDirName 项目root下的配置目录
+const confDirName = "conf"
+
+// LoadOptions configures Load
+type LoadOptions struct {
+	File      string        // 配置文件(JSON)，相对路径时在项目conf目录(GetProjectRootDir()/conf)中查找，为空时不读取文件
+	FileFlag  string        // 指定配置文件的命令行参数名称，例如config，默认值为File；为空时不注册
+	Optional  bool          // 配置文件不存在时使用默认值，不返回error
+	EnvPrefix string        // 环境变量前缀，例如SAVER，字段log.dir对应SAVER_LOG_DIR；为空时不读取环境变量
+	Flags     *flag.FlagSet // 注册配置项命令行参数的FlagSet，参数名称为字段路径，例如-log.dir；为nil时不解析命令行参数
+	Args      []string      // 命令行参数，为nil时使用os.Args[1:]
+}
+
+// Validator is implemented by configuration structs that check themselves after loading
+type Validator interface {
+	Validate() error
+}
+
+// Load fills cfg from defaults, a configuration file, environment variables and flags
+/*
+ * 加载配置，优先级从低到高依次为：
+ *   1. cfg中已有的值以及字段的default标签(只填充零值字段)，例如 `json:"listen" default:":8080"`
+ *   2. 配置文件，JSON格式，文件中出现未知的字段时返回error，time.Duration可以写为"10s"
+ *   3. 环境变量，名称为 EnvPrefix_路径，路径中的.替换为_并转为大写；也可以通过env标签指定完整名称
+ *   4. 命令行参数，只有显式指定的参数生效，帮助信息取字段的comment标签
+ * 字段名称与WriteTemplate相同，依次取yaml标签、json标签以及字段名；标签为"-"以及未导出的字段不处理
+ * 环境变量以及命令行参数中，切片按照逗号分隔，map以及结构体使用JSON
+ * 加载之后按照validate标签以及Validator接口校验，参考Validate
+ * 例如:
+ *     cfg := &Config{}
+ *     err := config.Load(config.LoadOptions{File: "saver.json", FileFlag: "config", EnvPrefix: "SAVER", Flags: flag.CommandLine}, cfg)
+ * @param opts：加载选项
+ * @param cfg：配置结构体指针
+ * @return 失败时返回包含字段路径的error；命令行参数为-h时返回flag.ErrHelp
+ */
+func Load(opts LoadOptions, cfg interface{}) error {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Ptr || root.IsNil() || root.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: load requires a struct pointer, got %T", cfg)
+	}
+	fields := collectFields(root.Elem(), "", nil)
+	for _, f := range fields {
+		if err := f.applyDefault(); err != nil {
+			return err
+		}
+	}
+
+	file := opts.File
+	if opts.Flags != nil {
+		if opts.FileFlag != "" {
+			opts.Flags.StringVar(&file, opts.FileFlag, opts.File, "configuration file")
+		}
+		for _, f := range fields {
+			opts.Flags.Var(&flagValue{field: f}, f.path, f.usage)
+		}
+		args := opts.Args
+		if args == nil {
+			args = os.Args[1:]
+		}
+		// 先解析参数获得配置文件路径，参数的值在文件以及环境变量之后重新应用
+		if err := opts.Flags.Parse(args); err != nil {
+			return err
+		}
+	}
+
+	if file != "" {
+		if err := loadFile(resolveFile(file), root.Elem(), opts.Optional); err != nil {
+			return err
+		}
+	}
+	if opts.EnvPrefix != "" {
+		for _, f := range fields {
+			name := f.envName(opts.EnvPrefix)
+			if value, ok := os.LookupEnv(name); ok {
+				if err := f.set(value); err != nil {
+					return fmt.Errorf("config: env %s: %w", name, err)
+				}
+			}
+		}
+	}
+	if opts.Flags != nil {
+		var flagErr error
+		opts.Flags.Visit(func(fl *flag.Flag) {
+			if v, ok := fl.Value.(*flagValue); ok && flagErr == nil && v.set {
+				if err := v.field.set(v.value); err != nil {
+					flagErr = fmt.Errorf("config: flag -%s: %w", fl.Name, err)
+				}
+			}
+		})
+		if flagErr != nil {
+			return flagErr
+		}
+	}
+	return Validate(cfg)
+}
+
+// Validate checks the validate tags of cfg and calls Validator implementations
+/*
+ * 校验配置，validate标签支持以下规则，多个规则用逗号分隔，例如 `validate:"required,min=1,max=65535"`：
+ *   - required：不能为零值(空字符串、0、空切片等)
+ *   - min=N、max=N：数值的范围，字符串、切片以及map的长度范围；time.Duration可以写为min=1s
+ *   - oneof=a|b|c：取值只能是其中之一
+ * 字段校验之后依次调用实现了Validator接口的嵌套结构体以及cfg本身的Validate
+ * @param cfg：配置结构体或者其指针
+ * @return 第一个不满足的规则，error中包含字段路径
+ */
+func Validate(cfg interface{}) error {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("config: validate requires a struct, got %T", cfg)
+	}
+	for _, f := range collectFields(v, "", nil) {
+		if err := f.validate(); err != nil {
+			return err
+		}
+	}
+	return callValidators(v, "")
+}
+
+// configField 配置结构体中的一个叶子字段
+type configField struct {
+	path  string // 字段路径，例如log.dir
+	value reflect.Value
+	tag   reflect.StructTag
+	usage string
+}
+
+/*
+ * 收集结构体的叶子字段(标量、切片、map以及time.Duration)，nil的结构体指针分配零值之后展开
+ * @param v：结构体
+ * @param prefix：路径前缀
+ * @param path：正在展开的结构体类型，避免自引用类型无限展开
+ */
+func collectFields(v reflect.Value, prefix string, path map[reflect.Type]bool) []configField {
+	if path == nil {
+		path = make(map[reflect.Type]bool)
+	}
+	t := v.Type()
+	path[t] = true
+	defer delete(path, t)
+	var fields []configField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if path[fv.Type().Elem()] || !fv.CanSet() {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if field.Anonymous && name == "" {
+			if fv.Kind() == reflect.Struct {
+				fields = append(fields, collectFields(fv, prefix, path)...)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPath := strings.ToLower(name)
+		if prefix != "" {
+			fieldPath = prefix + "." + fieldPath
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType && !path[fv.Type()] {
+			fields = append(fields, collectFields(fv, fieldPath, path)...)
+			continue
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		fields = append(fields, configField{path: fieldPath, value: fv, tag: field.Tag, usage: field.Tag.Get("comment")})
+	}
+	return fields
+}
+
+/*
+ * 字段为零值时使用default标签
+ */
+func (f configField) applyDefault() error {
+	def, ok := f.tag.Lookup("default")
+	if !ok || !f.value.IsZero() {
+		return nil
+	}
+	if err := f.set(def); err != nil {
+		return fmt.Errorf("config: default of %s: %w", f.path, err)
+	}
+	return nil
+}
+
+/*
+ * 环境变量名称，env标签优先
+ */
+func (f configField) envName(prefix string) string {
+	if name := f.tag.Get("env"); name != "" {
+		return name
+	}
+	return strings.ToUpper(prefix + "_" + strings.NewReplacer(".", "_", "-", "_").Replace(f.path))
+}
+
+/*
+ * 将字符串转换为字段类型并赋值
+ */
+func (f configField) set(text string) error {
+	return setString(f.value, text)
+}
+
+/*
+ * 字符串赋值：标量直接解析，time.Duration使用time.ParseDuration，[]string等切片按照逗号分隔，map以及结构体使用JSON
+ */
+func setString(v reflect.Value, text string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if strings.HasPrefix(strings.TrimSpace(text), "[") {
+			return setJSON(v, []byte(text))
+		}
+		var parts []string
+		if text != "" {
+			parts = strings.Split(text, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setString(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setString(elem.Elem(), text); err != nil {
+			return err
+		}
+		v.Set(elem)
+	default:
+		return setJSON(v, []byte(text))
+	}
+	return nil
+}
+
+/*
+ * 使用JSON解析赋值，time.Duration可以写为字符串
+ */
+func setJSON(v reflect.Value, data []byte) error {
+	if v.Type() == durationType {
+		var text string
+		if err := json.Unmarshal(data, &text); err == nil {
+			return setString(v, text)
+		}
+	}
+	target := reflect.New(v.Type())
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target.Interface()); err != nil {
+		return err
+	}
+	v.Set(target.Elem())
+	return nil
+}
+
+/*
+ * 按照validate标签校验字段
+ */
+func (f configField) validate() error {
+	rules := f.tag.Get("validate")
+	if rules == "" {
+		return nil
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		var err error
+		switch name {
+		case "required":
+			if f.value.IsZero() {
+				err = errors.New("is required")
+			}
+		case "min", "max":
+			err = checkRange(f.value, name, arg)
+		case "oneof":
+			err = checkOneOf(f.value, arg)
+		default:
+			err = fmt.Errorf("unknown rule %q", rule)
+		}
+		if err != nil {
+			return fmt.Errorf("config: %s %w", f.path, err)
+		}
+	}
+	return nil
+}
+
+/*
+ * 检查min/max，字符串、切片以及map比较长度
+ */
+func checkRange(v reflect.Value, rule, arg string) error {
+	var actual, limit float64
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return fmt.Errorf("bad rule %s=%s: %w", rule, arg, err)
+		}
+		actual, limit = float64(v.Int()), float64(d)
+	default:
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("bad rule %s=%s: %w", rule, arg, err)
+		}
+		limit = n
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			actual = float64(v.Len())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			actual = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			actual = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			actual = v.Float()
+		default:
+			return fmt.Errorf("rule %s does not apply to %s", rule, v.Kind())
+		}
+	}
+	if rule == "min" && actual < limit {
+		return fmt.Errorf("must be at least %s", arg)
+	}
+	if rule == "max" && actual > limit {
+		return fmt.Errorf("must be at most %s", arg)
+	}
+	return nil
+}
+
+/*
+ * 检查oneof
+ */
+func checkOneOf(v reflect.Value, arg string) error {
+	actual := fmt.Sprint(v.Interface())
+	if v.Type() == durationType {
+		actual = time.Duration(v.Int()).String()
+	}
+	for _, option := range strings.Split(arg, "|") {
+		if actual == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s, got %q", strings.Replace(arg, "|", ", ", -1), actual)
+}
+
+/*
+ * 由内向外调用嵌套结构体以及自身的Validate
+ */
+func callValidators(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() != reflect.Struct || fv.Type() == durationType {
+			continue
+		}
+		name, _ := fieldName(field)
+		if name == "" {
+			name = field.Name
+		}
+		if err := callValidators(fv, prefix+strings.ToLower(name)+"."); err != nil {
+			return err
+		}
+	}
+	var target interface{}
+	if v.CanAddr() {
+		target = v.Addr().Interface()
+	} else {
+		target = v.Interface()
+	}
+	if validator, ok := target.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			if prefix == "" {
+				return fmt.Errorf("config: %w", err)
+			}
+			return fmt.Errorf("config: %s %w", strings.TrimSuffix(prefix, "."), err)
+		}
+	}
+	return nil
+}
+
+/*
+ * 配置文件路径：相对路径在项目conf目录中查找，项目root与GetProjectRootDir相同；当前目录中存在时优先使用当前目录
+ */
+func resolveFile(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	if _, err := os.Stat(file); err == nil {
+		return file
+	}
+	binDir, err := process.GetProcessBinaryDir()
+	if err != nil {
+		return file
+	}
+	return filepath.Join(binDir, "..", confDirName, file)
+}
+
+/*
+ * 读取JSON配置文件并合并到结构体，文件中没有出现的字段保持原值
+ */
+func loadFile(file string, v reflect.Value, optional bool) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("config: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("config: parse %s: %w", file, err)
+	}
+	if err := mergeMap(v, raw, ""); err != nil {
+		return fmt.Errorf("config: %s: %w", file, err)
+	}
+	return nil
+}
+
+/*
+ * 将JSON对象合并到结构体，按照字段名称匹配(大小写不敏感)，未知的key返回error
+ */
+func mergeMap(v reflect.Value, raw map[string]interface{}, prefix string) error {
+	for key, value := range raw {
+		fv, name, ok := lookupField(v, key)
+		if !ok {
+			return fmt.Errorf("unknown field %s%s", prefix, key)
+		}
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if value == nil {
+				fv.Set(reflect.Zero(fv.Type()))
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if nested, isMap := value.(map[string]interface{}); isMap && fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			if err := mergeMap(fv, nested, prefix+name+"."); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err == nil {
+			err = setJSON(fv, data)
+		}
+		if err != nil {
+			return fmt.Errorf("field %s%s: %w", prefix, name, err)
+		}
+	}
+	return nil
+}
+
+/*
+ * 按照配置文件中的key查找字段，匿名结构体字段展开查找
+ * @return (字段, 字段名称, 是否找到)
+ */
+func lookupField(v reflect.Value, key string) (reflect.Value, string, bool) {
+	t := v.Type()
+	var fallback reflect.Value
+	fallbackName := ""
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			inner := fv
+			if inner.Kind() == reflect.Ptr {
+				if inner.IsNil() {
+					continue
+				}
+				inner = inner.Elem()
+			}
+			if inner.Kind() == reflect.Struct {
+				if found, foundName, ok := lookupField(inner, key); ok {
+					return found, foundName, true
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if name == key {
+			return fv, name, true
+		}
+		if !fallback.IsValid() && strings.EqualFold(name, key) {
+			fallback, fallbackName = fv, name
+		}
+	}
+	return fallback, fallbackName, fallback.IsValid()
+}
+
+// flagValue 配置项对应的命令行参数，解析时只记录，在文件以及环境变量之后应用
+type flagValue struct {
+	field configField
+	value string
+	set   bool
+}
+
+// String implements flag.Value
+func (v *flagValue) String() string {
+	if v == nil || !v.field.value.IsValid() {
+		return ""
+	}
+	if v.field.value.Type() == durationType {
+		return time.Duration(v.field.value.Int()).String()
+	}
+	return fmt.Sprint(v.field.value.Interface())
+}
+
+// Set implements flag.Value
+func (v *flagValue) Set(text string) error {
+	if err := setString(reflect.New(v.field.value.Type()).Elem(), text); err != nil {
+		return err
+	}
+	v.value, v.set = text, true
+	return nil
+}
+
+// IsBoolFlag lets bool fields be given as -name without a value
+func (v *flagValue) IsBoolFlag() bool {
+	return v.field.value.Kind() == reflect.Bool
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds the current configuration behind an atomic pointer
+/*
+ * 读多写少的配置存储
+ * Load不加锁，适合在热路径中频繁调用；热更新时通过Store整体替换配置对象，读取方要么看到旧配置要么看到新配置
+ * Load返回的配置对象被多个协程共享，调用方不能修改，更新配置时需要复制一份再Store
+ */
+type Store[T any] struct {
+	current atomic.Pointer[T]
+
+	// updateLock 保证替换配置与通知订阅者按照相同的顺序进行
+	updateLock sync.Mutex
+	subLock    sync.Mutex
+	subs       map[int]func(old, new *T)
+	nextID     int
+}
+
+// NewStore creates a store holding initial
+/*
+ * 创建配置存储
+ * @param initial：初始配置，可以为nil
+ * @return 配置存储对象
+ */
+func NewStore[T any](initial *T) *Store[T] {
+	s := &Store[T]{subs: make(map[int]func(old, new *T))}
+	s.current.Store(initial)
+	return s
+}
+
+// Load returns the current configuration without locking
+/*
+ * 获取当前配置，返回的对象只读
+ */
+func (s *Store[T]) Load() *T {
+	return s.current.Load()
+}
+
+// Store replaces the configuration and notifies the subscribers
+/*
+ * 替换当前配置，替换之后在当前协程中按照订阅顺序通知所有订阅者，通知完成之后返回
+ * 并发调用时按照替换顺序依次通知，订阅者中不能再调用Store，否则会死锁
+ * @param cfg：新的配置
+ */
+func (s *Store[T]) Store(cfg *T) {
+	s.updateLock.Lock()
+	defer s.updateLock.Unlock()
+	old := s.current.Swap(cfg)
+	for _, fn := range s.subscribers() {
+		fn(old, cfg)
+	}
+}
+
+// Subscribe registers fn to be called after every Store
+/*
+ * 订阅配置变化，例如根据新配置调整日志级别或者连接池大小
+ * @param fn：回调，参数为替换前后的配置
+ * @return 取消订阅的函数，重复调用是安全的
+ */
+func (s *Store[T]) Subscribe(fn func(old, new *T)) (cancel func()) {
+	s.subLock.Lock()
+	id := s.nextID
+	s.nextID++
+	s.subs[id] = fn
+	s.subLock.Unlock()
+	return func() {
+		s.subLock.Lock()
+		delete(s.subs, id)
+		s.subLock.Unlock()
+	}
+}
+
+/*
+ * 按照订阅顺序获取所有订阅者的快照
+ */
+func (s *Store[T]) subscribers() []func(old, new *T) {
+	s.subLock.Lock()
+	defer s.subLock.Unlock()
+	ids := make([]int, 0, len(s.subs))
+	for id := range s.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	fns := make([]func(old, new *T), 0, len(ids))
+	for _, id := range ids {
+		fns = append(fns, s.subs[id])
+	}
+	return fns
+}
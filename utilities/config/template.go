@@ -0,0 +1,222 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TemplateCommand 生成配置模板的子命令名称
+const TemplateCommand = "config-template"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// WriteTemplate writes defaults as a commented YAML configuration file
+/*
+ * 根据配置结构体以及默认值生成带注释的YAML配置模板
+ * 字段名称依次取yaml标签、json标签以及字段名；标签为"-"以及未导出的字段不输出
+ * 字段的comment标签输出为字段上方的注释，例如 `yaml:"listen" comment:"监听地址"`
+ * 没有标签名称的匿名结构体字段展开到上一层；nil指针按照零值输出，保证模板中包含所有字段
+ * @param w：输出
+ * @param defaults：填充了默认值的配置对象(struct或者其指针)
+ * @return 写入失败或者defaults不是struct时返回error
+ */
+func WriteTemplate(w io.Writer, defaults interface{}) error {
+	v := reflect.ValueOf(defaults)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+		} else {
+			v = v.Elem()
+		}
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("config: template requires a struct, got %s", v.Kind())
+	}
+	out := bufio.NewWriter(w)
+	writeStruct(out, v, 0, map[reflect.Type]bool{})
+	return out.Flush()
+}
+
+// RunTemplateCommand handles the config-template subcommand
+/*
+ * 处理生成配置模板的子命令，在main函数解析参数之前调用：
+ *   if handled, err := config.RunTemplateCommand(os.Args[1:], DefaultConfig()); handled { ... }
+ * 命令格式为 config-template [-o 文件]，不指定-o时输出到标准输出；-o指定的文件已经存在时返回error，不会覆盖
+ * @param args：命令行参数(不含程序名)
+ * @param defaults：填充了默认值的配置对象
+ * @return (是否为生成模板的子命令, error)，返回true时调用方应当直接退出
+ */
+func RunTemplateCommand(args []string, defaults interface{}) (bool, error) {
+	if len(args) == 0 || args[0] != TemplateCommand {
+		return false, nil
+	}
+	flags := flag.NewFlagSet(TemplateCommand, flag.ContinueOnError)
+	output := flags.String("o", "", "output file, stdout if empty")
+	if err := flags.Parse(args[1:]); err != nil {
+		return true, err
+	}
+	if *output == "" {
+		return true, WriteTemplate(os.Stdout, defaults)
+	}
+	file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return true, err
+	}
+	if err = WriteTemplate(file, defaults); err != nil {
+		file.Close()
+		return true, err
+	}
+	return true, file.Close()
+}
+
+/*
+ * 输出结构体的所有字段
+ * @param out：输出
+ * @param v：结构体
+ * @param depth：缩进层级
+ * @param path：正在输出的结构体类型，自引用类型的nil指针输出为null，避免无限展开
+ */
+func writeStruct(out *bufio.Writer, v reflect.Value, depth int, path map[reflect.Type]bool) {
+	t := v.Type()
+	path[t] = true
+	defer delete(path, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			if fv = indirect(fv, path); fv.Kind() == reflect.Struct {
+				writeStruct(out, fv, depth, path)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if comment := field.Tag.Get("comment"); comment != "" {
+			for _, line := range strings.Split(comment, "\n") {
+				writeIndent(out, depth)
+				out.WriteString("# " + line + "\n")
+			}
+		}
+		writeIndent(out, depth)
+		out.WriteString(name + ":")
+		writeValue(out, fv, depth, path)
+	}
+}
+
+/*
+ * 输出字段的值，标量输出在同一行，结构体、数组以及map在下一行缩进输出
+ */
+func writeValue(out *bufio.Writer, v reflect.Value, depth int, path map[reflect.Type]bool) {
+	v = indirect(v, path)
+	if !v.IsValid() {
+		out.WriteString(" null\n")
+		return
+	}
+	switch {
+	case v.Type() == durationType:
+		out.WriteString(" " + strconv.Quote(time.Duration(v.Int()).String()) + "\n")
+	case v.Kind() == reflect.Struct:
+		out.WriteString("\n")
+		writeStruct(out, v, depth+1, path)
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		if v.Len() == 0 {
+			out.WriteString(" []\n")
+			return
+		}
+		out.WriteString("\n")
+		for i := 0; i < v.Len(); i++ {
+			writeIndent(out, depth+1)
+			out.WriteString("-")
+			writeValue(out, v.Index(i), depth+1, path)
+		}
+	case v.Kind() == reflect.Map:
+		if v.Len() == 0 {
+			out.WriteString(" {}\n")
+			return
+		}
+		out.WriteString("\n")
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			writeIndent(out, depth+1)
+			out.WriteString(scalar(key) + ":")
+			writeValue(out, v.MapIndex(key), depth+1, path)
+		}
+	default:
+		out.WriteString(" " + scalar(v) + "\n")
+	}
+}
+
+/*
+ * 标量的YAML表示，字符串统一使用双引号
+ */
+func scalar(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	}
+	return strconv.Quote(fmt.Sprint(v.Interface()))
+}
+
+/*
+ * 获取字段在配置文件中的名称
+ * @return (名称, 是否输出)，名称为空表示没有通过标签指定
+ */
+func fieldName(field reflect.StructField) (string, bool) {
+	for _, key := range []string{"yaml", "json"} {
+		tag, ok := field.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return "", false
+		}
+		return name, true
+	}
+	return "", true
+}
+
+/*
+ * 解开指针以及interface，nil指针使用零值，nil interface以及指向path中类型的nil指针返回无效值
+ */
+func indirect(v reflect.Value, path map[reflect.Type]bool) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if !v.IsNil() {
+			v = v.Elem()
+		} else if v.Kind() == reflect.Ptr && !path[v.Type().Elem()] {
+			v = reflect.Zero(v.Type().Elem())
+		} else {
+			return reflect.Value{}
+		}
+	}
+	return v
+}
+
+/*
+ * 输出缩进，每层两个空格
+ */
+func writeIndent(out *bufio.Writer, depth int) {
+	out.WriteString(strings.Repeat("  ", depth))
+}
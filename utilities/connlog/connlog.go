@@ -0,0 +1,186 @@
+package connlog
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// defaultResolveTimeout 反向解析对端主机名的默认超时时间
+const defaultResolveTimeout = 2 * time.Second
+
+// Resolver resolves a peer address to host names, *net.Resolver satisfies it
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// Options configures the connection logger
+type Options struct {
+	Resolver       Resolver      // 反向解析对端主机名，为nil时使用net.DefaultResolver，可以替换为带缓存的实现
+	ResolveTimeout time.Duration // 反向解析超时时间，默认2秒
+	DisableResolve bool          // 不解析对端主机名
+}
+
+// ConnLogger writes one audit record per closed connection
+/*
+ * 连接审计日志，连接关闭时输出一条记录到独立的日志文件，格式为：
+ * 时间|conn|remote=...|local=...[|tls_version=...|tls_cipher=...|tls_server_name=...]|duration=...|bytes_in=...|bytes_out=...|peer_host=...|后缀信息
+ * 日志文件通过logger.Write写入，与其他日志文件一样按小时/大小独立切分
+ * 对端主机名在连接建立时异步解析，关闭连接不会等待解析完成
+ */
+type ConnLogger struct {
+	logger   *logger.Logger
+	filename string
+	options  Options
+}
+
+// New creates a connection logger writing to filename through l
+/*
+ * 创建连接审计日志对象
+ * @param l：日志对象
+ * @param filename：审计日志文件名
+ * @param options：配置
+ * @return 连接审计日志对象
+ */
+func New(l *logger.Logger, filename string, options Options) *ConnLogger {
+	if options.Resolver == nil {
+		options.Resolver = net.DefaultResolver
+	}
+	if options.ResolveTimeout <= 0 {
+		options.ResolveTimeout = defaultResolveTimeout
+	}
+	return &ConnLogger{logger: l, filename: filename, options: options}
+}
+
+// Wrap returns a conn that is logged when closed
+/*
+ * 包装连接，连接关闭时输出审计记录，对于*tls.Conn会记录协商的TLS版本以及加密套件
+ * @param conn：原始连接
+ * @return 包装后的连接
+ */
+func (cl *ConnLogger) Wrap(conn net.Conn) net.Conn {
+	c := &loggedConn{
+		Conn:     conn,
+		owner:    cl,
+		start:    time.Now(),
+		resolved: make(chan struct{}),
+	}
+	if cl.options.DisableResolve {
+		close(c.resolved)
+	} else {
+		go c.resolve()
+	}
+	return c
+}
+
+// Listener wraps ln so every accepted connection is logged
+func (cl *ConnLogger) Listener(ln net.Listener) net.Listener {
+	return &loggedListener{Listener: ln, owner: cl}
+}
+
+// loggedListener 对Accept的连接进行包装
+type loggedListener struct {
+	net.Listener
+	owner *ConnLogger
+}
+
+func (ln *loggedListener) Accept() (net.Conn, error) {
+	conn, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return ln.owner.Wrap(conn), nil
+}
+
+// loggedConn 记录读写字节数，关闭时输出审计记录
+type loggedConn struct {
+	net.Conn
+	owner     *ConnLogger
+	start     time.Time
+	bytesIn   int64
+	bytesOut  int64
+	peerHost  string        // 反向解析的主机名，resolved关闭之后可读
+	resolved  chan struct{} // 解析完成时关闭
+	closeOnce sync.Once
+}
+
+func (c *loggedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.bytesIn, int64(n))
+	return n, err
+}
+
+func (c *loggedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.bytesOut, int64(n))
+	return n, err
+}
+
+// Close closes the connection and logs it once
+func (c *loggedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		record := c.record(time.Since(c.start))
+		go func() {
+			<-c.resolved
+			record = append(record, "peer_host="+c.peerHost)
+			c.owner.logger.Write(c.owner.filename, true, record...)
+		}()
+	})
+	return err
+}
+
+/*
+ * 反向解析对端主机名
+ */
+func (c *loggedConn) resolve() {
+	defer close(c.resolved)
+	host, _, err := net.SplitHostPort(c.Conn.RemoteAddr().String())
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.owner.options.ResolveTimeout)
+	defer cancel()
+	if names, err := c.owner.options.Resolver.LookupAddr(ctx, host); err == nil && len(names) > 0 {
+		c.peerHost = strings.TrimSuffix(names[0], ".")
+	}
+}
+
+/*
+ * 生成审计记录，对端主机名在解析完成后追加
+ * @param duration：连接持续时间
+ * @return 日志参数
+ */
+func (c *loggedConn) record(duration time.Duration) []interface{} {
+	record := []interface{}{
+		"conn",
+		"remote=" + addrString(c.Conn.RemoteAddr()),
+		"local=" + addrString(c.Conn.LocalAddr()),
+	}
+	if tlsConn, ok := c.Conn.(*tls.Conn); ok {
+		if state := tlsConn.ConnectionState(); state.HandshakeComplete {
+			record = append(record,
+				"tls_version="+tls.VersionName(state.Version),
+				"tls_cipher="+tls.CipherSuiteName(state.CipherSuite),
+				"tls_server_name="+state.ServerName)
+		}
+	}
+	return append(record,
+		"duration="+duration.String(),
+		"bytes_in="+strconv.FormatInt(atomic.LoadInt64(&c.bytesIn), 10),
+		"bytes_out="+strconv.FormatInt(atomic.LoadInt64(&c.bytesOut), 10))
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
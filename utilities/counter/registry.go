@@ -0,0 +1,101 @@
+package counter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Registry holds named rolling counters
+/*
+ * 滚动计数器注册表，通常每个进程使用Default()返回的全局注册表
+ */
+type Registry struct {
+	sync.RWMutex
+	counters map[string]*Rolling
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{counters: make(map[string]*Rolling)}
+}
+
+// Get returns the counter with name, creating it if needed
+func (reg *Registry) Get(name string) *Rolling {
+	reg.RLock()
+	r, ok := reg.counters[name]
+	reg.RUnlock()
+	if ok {
+		return r
+	}
+
+	reg.Lock()
+	defer reg.Unlock()
+	if r, ok = reg.counters[name]; !ok {
+		r = NewRolling()
+		reg.counters[name] = r
+	}
+	return r
+}
+
+// Add adds n to the named counter
+func (reg *Registry) Add(name string, n int64) {
+	reg.Get(name).Add(n)
+}
+
+// Snapshot returns snapshots of all counters
+func (reg *Registry) Snapshot() map[string]Snapshot {
+	reg.RLock()
+	names := make([]string, 0, len(reg.counters))
+	for name := range reg.counters {
+		names = append(names, name)
+	}
+	reg.RUnlock()
+	sort.Strings(names)
+
+	snapshots := make(map[string]Snapshot, len(names))
+	for _, name := range names {
+		snapshots[name] = reg.Get(name).Snapshot()
+	}
+	return snapshots
+}
+
+// Handler exports all counters as JSON for the status endpoint
+/*
+ * 状态接口，GET返回所有计数器的快照
+ * 参数brief=1时不输出按分钟/小时的明细
+ */
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshots := reg.Snapshot()
+		if r.FormValue("brief") == "1" {
+			for name, snapshot := range snapshots {
+				snapshot.Minutes, snapshot.Hours = nil, nil
+				snapshots[name] = snapshot
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(snapshots)
+	})
+}
+
+// defaultRegistry 进程级别的全局注册表
+var defaultRegistry = NewRegistry()
+
+// Default returns the process wide registry
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Add adds n to the named counter of the default registry
+func Add(name string, n int64) {
+	defaultRegistry.Add(name, n)
+}
+
+// Inc adds one to the named counter of the default registry
+func Inc(name string) {
+	defaultRegistry.Add(name, 1)
+}
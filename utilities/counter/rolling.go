@@ -0,0 +1,140 @@
+package counter
+
+import (
+	"sync"
+	"time"
+)
+
+// Window is a fixed-size ring of time buckets
+/*
+ * 滑动时间窗口计数，使用定长环形数组存储，每个桶对应width长度的时间段
+ * 例如 width=1分钟、size=60 表示最近一小时按分钟的计数
+ */
+type Window struct {
+	width   time.Duration
+	buckets []int64
+	start   int64 // 最新桶的开始时间(以width为单位的序号)
+	head    int   // 最新桶在环形数组中的位置
+}
+
+func newWindow(width time.Duration, size int, now time.Time) *Window {
+	return &Window{
+		width:   width,
+		buckets: make([]int64, size),
+		start:   now.UnixNano() / int64(width),
+	}
+}
+
+/*
+ * 将窗口推进到now所在的桶，过期的桶清零
+ */
+func (w *Window) advance(now time.Time) {
+	current := now.UnixNano() / int64(w.width)
+	steps := current - w.start
+	if steps <= 0 {
+		return
+	}
+	if steps > int64(len(w.buckets)) {
+		steps = int64(len(w.buckets))
+	}
+	for i := int64(0); i < steps; i++ {
+		w.head = (w.head + 1) % len(w.buckets)
+		w.buckets[w.head] = 0
+	}
+	w.start = current
+}
+
+func (w *Window) add(n int64) {
+	w.buckets[w.head] += n
+}
+
+/*
+ * 从旧到新返回所有桶的计数
+ */
+func (w *Window) values() []int64 {
+	size := len(w.buckets)
+	values := make([]int64, size)
+	for i := 0; i < size; i++ {
+		values[i] = w.buckets[(w.head+1+i)%size]
+	}
+	return values
+}
+
+func (w *Window) sum() int64 {
+	var total int64
+	for _, v := range w.buckets {
+		total += v
+	}
+	return total
+}
+
+// Rolling counts events per minute over the last hour and per hour over the last day
+/*
+ * 滚动计数器，同时维护最近60分钟(按分钟)以及最近24小时(按小时)的计数
+ * 内存占用固定为84个int64
+ */
+type Rolling struct {
+	sync.Mutex
+	total   int64
+	minutes *Window
+	hours   *Window
+	nowFunc func() time.Time
+}
+
+// NewRolling creates a rolling counter
+func NewRolling() *Rolling {
+	now := time.Now()
+	return &Rolling{
+		minutes: newWindow(time.Minute, 60, now),
+		hours:   newWindow(time.Hour, 24, now),
+		nowFunc: time.Now,
+	}
+}
+
+// Add adds n to the counter
+func (r *Rolling) Add(n int64) {
+	r.Lock()
+	now := r.nowFunc()
+	r.minutes.advance(now)
+	r.hours.advance(now)
+	r.minutes.add(n)
+	r.hours.add(n)
+	r.total += n
+	r.Unlock()
+}
+
+// Inc adds one to the counter
+func (r *Rolling) Inc() {
+	r.Add(1)
+}
+
+// Snapshot is a point-in-time copy of a rolling counter
+type Snapshot struct {
+	Total     int64   `json:"total"`      // 进程启动以来的总数
+	LastMin   int64   `json:"last_min"`   // 最近一分钟(当前分钟)的计数
+	LastHour  int64   `json:"last_hour"`  // 最近60分钟的计数
+	LastDay   int64   `json:"last_day"`   // 最近24小时的计数
+	Minutes   []int64 `json:"minutes"`    // 最近60分钟按分钟的计数，从旧到新
+	Hours     []int64 `json:"hours"`      // 最近24小时按小时的计数，从旧到新
+	PerSecond float64 `json:"per_second"` // 最近60分钟的平均每秒计数
+}
+
+// Snapshot returns the current values
+func (r *Rolling) Snapshot() Snapshot {
+	r.Lock()
+	defer r.Unlock()
+	now := r.nowFunc()
+	r.minutes.advance(now)
+	r.hours.advance(now)
+	minutes := r.minutes.values()
+	lastHour := r.minutes.sum()
+	return Snapshot{
+		Total:     r.total,
+		LastMin:   minutes[len(minutes)-1],
+		LastHour:  lastHour,
+		LastDay:   r.hours.sum(),
+		Minutes:   minutes,
+		Hours:     r.hours.values(),
+		PerSecond: float64(lastHour) / time.Hour.Seconds(),
+	}
+}
@@ -0,0 +1,58 @@
+package fileutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AtomicWriteFile writes data to filename so that readers see either the old or the new content
+/*
+ * 原子写文件：写入同目录下的临时文件，fsync之后重命名为目标文件，再fsync所在目录，
+ * 进程崩溃或者断电时目标文件要么是旧内容，要么是完整的新内容，不会出现写了一半的文件
+ * @param filename：目标文件
+ * @param data：文件内容
+ * @param perm：文件权限
+ * @return 失败时删除临时文件并返回error
+ */
+func AtomicWriteFile(filename string, data []byte, perm os.FileMode) error {
+	return AtomicWriteFunc(filename, perm, func(w io.Writer) error {
+		_, err := io.Copy(w, bytes.NewReader(data))
+		return err
+	})
+}
+
+// AtomicWriteFunc is AtomicWriteFile for content produced by write
+/*
+ * 原子写文件，内容由write写入，适合较大的内容或者流式生成的内容，例如复制文件、导出快照
+ * @param filename：目标文件
+ * @param perm：文件权限
+ * @param write：写入内容，返回error时放弃写入，目标文件保持不变
+ * @return 失败时删除临时文件并返回error
+ */
+func AtomicWriteFunc(filename string, perm os.FileMode, write func(w io.Writer) error) error {
+	dir := filepath.Dir(filename)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if err = write(tmp); err == nil {
+		if err = tmp.Chmod(perm); err == nil {
+			err = tmp.Sync()
+		}
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return syncDir(dir)
+}
@@ -0,0 +1,76 @@
+package fileutil
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned by VerifyChecksum when the checksum differs
+var ErrChecksumMismatch = errors.New("fileutil: checksum mismatch")
+
+// hashes 支持的校验算法
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+}
+
+// Checksum returns the hex encoded checksum of path
+/*
+ * 计算文件校验值，流式读取，不会把整个文件读入内存
+ * @param path：文件路径
+ * @param algorithm：md5、sha1、sha256、sha512或者crc32，不区分大小写
+ * @return (小写十六进制的校验值, error)
+ */
+func Checksum(path, algorithm string) (string, error) {
+	newHash, ok := hashes[strings.ToLower(algorithm)]
+	if !ok {
+		return "", fmt.Errorf("fileutil: unknown checksum algorithm %q", algorithm)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("checksum %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SHA256 returns the hex encoded SHA-256 of path
+func SHA256(path string) (string, error) {
+	return Checksum(path, "sha256")
+}
+
+// VerifyChecksum checks path against the expected hex checksum
+/*
+ * 校验文件，例如下载的升级包
+ * @param path：文件路径
+ * @param algorithm：算法，与Checksum相同
+ * @param expected：期望的十六进制校验值，不区分大小写
+ * @return 不一致时返回包装了ErrChecksumMismatch的error
+ */
+func VerifyChecksum(path, algorithm, expected string) error {
+	sum, err := Checksum(path, algorithm)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, strings.TrimSpace(expected)) {
+		return fmt.Errorf("verify %s: %w: %s %s, expected %s", path, ErrChecksumMismatch, algorithm, sum, expected)
+	}
+	return nil
+}
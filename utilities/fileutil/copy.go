@@ -0,0 +1,99 @@
+package fileutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CopyFile copies the content and permission bits of src to dst
+/*
+ * 复制文件，保留权限位以及修改时间；目标文件通过AtomicWriteFunc写入，复制过程中不会出现不完整的目标文件
+ * @param src：源文件，符号链接时复制链接指向的文件
+ * @param dst：目标文件，已经存在时覆盖
+ * @return 失败时返回error
+ */
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("copy %s: not a regular file", src)
+	}
+	err = AtomicWriteFunc(dst, info.Mode().Perm(), func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// CopyDir recursively copies the directory src to dst
+/*
+ * 递归复制目录，保留文件以及目录的权限位，符号链接按照链接本身复制
+ * 目标目录已经存在时合并，同名文件被覆盖；不支持设备文件、管道等特殊文件
+ * @param src：源目录
+ * @param dst：目标目录
+ * @return 失败时返回error，已经复制的文件保留
+ */
+func CopyDir(src, dst string) error {
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if absDst == absSrc || strings.HasPrefix(absDst, absSrc+string(filepath.Separator)) {
+		return fmt.Errorf("copy %s: destination %s is inside the source", src, dst)
+	}
+	// 目录复制完成之后再设置权限，只读目录中的文件也可以复制
+	type dirMode struct {
+		path string
+		mode os.FileMode
+	}
+	var dirs []dirMode
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			dirs = append(dirs, dirMode{path: target, mode: mode.Perm()})
+			return os.MkdirAll(target, 0700)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		case mode.IsRegular():
+			return CopyFile(path, target)
+		default:
+			return fmt.Errorf("copy %s: unsupported file type %s", path, mode.Type())
+		}
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if chmodErr := os.Chmod(dirs[i].path, dirs[i].mode); err == nil {
+			err = chmodErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package fileutil
+
+import (
+	"os"
+)
+
+/*
+ * fsync目录，使重命名以及新建的目录项落盘
+ */
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,8 @@
+package fileutil
+
+/*
+ * windows下目录不能打开fsync，重命名由文件系统日志保证
+ */
+func syncDir(dir string) error {
+	return nil
+}
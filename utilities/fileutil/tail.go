@@ -0,0 +1,219 @@
+package fileutil
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// tailBlock Tail从文件末尾向前读取的块大小
+const tailBlock = 32 * 1024
+
+// defaultPollInterval Follow没有新数据时的检查间隔
+const defaultPollInterval = 200 * time.Millisecond
+
+// Tail returns the last n lines of path, without their line endings
+/*
+ * 读取文件最后n行，从文件末尾按块向前读取，不会读取整个文件
+ * 行尾的\n以及\r\n被去掉，文件最后一行没有换行符时同样作为一行返回
+ * @param path：文件路径
+ * @param n：行数，<=0时返回空
+ * @return (按文件顺序排列的行, error)
+ */
+func Tail(path string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	offset := info.Size()
+	// 读取到n+1个换行符即可确定最后n行的起点(文件末尾的换行符不计)
+	for offset > 0 && bytes.Count(bytes.TrimSuffix(data, []byte{'\n'}), []byte{'\n'}) < n {
+		size := int64(tailBlock)
+		if size > offset {
+			size = offset
+		}
+		offset -= size
+		block := make([]byte, size)
+		if _, err := f.ReadAt(block, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(block, data...)
+	}
+
+	data = bytes.TrimSuffix(data, []byte{'\n'})
+	if len(data) == 0 && offset == 0 {
+		return nil, nil
+	}
+	lines := bytes.Split(data, []byte{'\n'})
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = string(bytes.TrimSuffix(line, []byte{'\r'}))
+	}
+	return result, nil
+}
+
+// FollowOptions configures Follow
+type FollowOptions struct {
+	FromStart    bool          // 从文件开头读取，默认从当前末尾开始
+	PollInterval time.Duration // 没有新数据时的检查间隔，<=0时为200ms
+}
+
+// Follower reads a file as it grows, like tail -F
+/*
+ * 持续读取文件新增的内容，读到末尾时等待新数据而不是返回io.EOF，例如：
+ *     f, err := fileutil.Follow("/data/logs/saver-error.log", fileutil.FollowOptions{})
+ *     scanner := bufio.NewScanner(f)
+ *     for scanner.Scan() { ... }
+ * 文件被切分(重命名之后创建同名新文件)时读完旧文件剩余的内容再切换到新文件，文件被截断时从头读取
+ * 在其他协程中调用Close之后Read返回io.EOF
+ */
+type Follower struct {
+	path     string
+	interval time.Duration
+	file     *os.File
+	offset   int64
+
+	lock   sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// Follow opens path for following
+/*
+ * 打开文件持续读取，文件不存在时等待文件创建
+ * @param path：文件路径
+ * @param opts：选项
+ * @return (*Follower, error)，打开文件失败(文件不存在除外)时返回error
+ */
+func Follow(path string, opts FollowOptions) (*Follower, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	f := &Follower{path: path, interval: opts.PollInterval, done: make(chan struct{})}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+		return nil, err
+	}
+	f.file = file
+	if !opts.FromStart {
+		if f.offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Read implements io.Reader, blocking until new data is written or Close is called
+func (f *Follower) Read(p []byte) (int, error) {
+	for {
+		f.lock.Lock()
+		closed, file := f.closed, f.file
+		f.lock.Unlock()
+		if closed {
+			return 0, io.EOF
+		}
+		if file != nil {
+			n, err := file.Read(p)
+			f.offset += int64(n)
+			if n > 0 {
+				return n, nil
+			}
+			if err != nil && err != io.EOF {
+				if f.isClosed() {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+		}
+		// 已经读到末尾，先检查是否切分或者截断，读完旧文件剩余内容之后再切换
+		if f.reopen(file) {
+			continue
+		}
+		select {
+		case <-f.done:
+			return 0, io.EOF
+		case <-time.After(f.interval):
+		}
+	}
+}
+
+/*
+ * 检查文件是否被切分、截断或者创建
+ * @param current：正在读取的文件，可以为nil
+ * @return 需要立即重新读取时返回true
+ */
+func (f *Follower) reopen(current *os.File) bool {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false
+	}
+	if current != nil {
+		stat, err := current.Stat()
+		if err == nil && os.SameFile(info, stat) {
+			if info.Size() < f.offset {
+				// 文件被截断，从头读取
+				f.offset, _ = current.Seek(0, io.SeekStart)
+				return true
+			}
+			return false
+		}
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		file.Close()
+		return false
+	}
+	if current != nil {
+		current.Close()
+	}
+	f.file, f.offset = file, 0
+	return true
+}
+
+/*
+ * 是否已经Close
+ */
+func (f *Follower) isClosed() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.closed
+}
+
+// Close stops following, a blocked Read returns io.EOF
+func (f *Follower) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	close(f.done)
+	if f.file != nil {
+		return f.file.Close()
+	}
+	return nil
+}
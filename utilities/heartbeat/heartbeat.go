@@ -0,0 +1,236 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultGroup is the default multicast group address
+	DefaultGroup = "239.255.77.77:17777"
+	// maxBeatSize 单个心跳包的最大字节数
+	maxBeatSize = 8192
+)
+
+// Beat is a heartbeat announcement of a process
+type Beat struct {
+	Service  string            `json:"service"`
+	Instance string            `json:"instance"` // 实例id，同一服务内唯一，默认 hostname:pid
+	Host     string            `json:"host"`
+	Pid      int               `json:"pid"`
+	Addr     string            `json:"addr,omitempty"` // 服务地址
+	Status   string            `json:"status"`         // 健康状态，例如 ok/degraded/draining
+	Seq      uint64            `json:"seq"`
+	Time     time.Time         `json:"time"`
+	Meta     map[string]string `json:"meta,omitempty"`
+}
+
+// Transport delivers heartbeats between processes
+/*
+ * 心跳传输接口，默认使用UDP组播，也可以接入服务发现后端
+ */
+type Transport interface {
+	// Publish 发送心跳
+	Publish(beat *Beat) error
+	// Subscribe 持续接收心跳直到Close，每收到一个心跳调用一次handler
+	Subscribe(handler func(beat *Beat)) error
+	Close() error
+}
+
+// Multicast is a UDP multicast transport
+type Multicast struct {
+	group *net.UDPAddr
+	send  *net.UDPConn
+	recv  *net.UDPConn
+	lock  sync.Mutex
+}
+
+// NewMulticast creates a multicast transport
+/*
+ * 创建UDP组播传输
+ * @param group：组播地址，为空时使用DefaultGroup
+ * @param ifname：接收组播使用的网卡名称，为空时由系统选择
+ * @return 成功返回(*Multicast, nil)；否则返回(nil, error)
+ */
+func NewMulticast(group, ifname string) (*Multicast, error) {
+	if group == "" {
+		group = DefaultGroup
+	}
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, err
+	}
+	var ifi *net.Interface
+	if ifname != "" {
+		if ifi, err = net.InterfaceByName(ifname); err != nil {
+			return nil, err
+		}
+	}
+	send, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := net.ListenMulticastUDP("udp4", ifi, addr)
+	if err != nil {
+		send.Close()
+		return nil, err
+	}
+	recv.SetReadBuffer(1 << 20)
+	return &Multicast{group: addr, send: send, recv: recv}, nil
+}
+
+// Publish implements Transport
+func (m *Multicast) Publish(beat *Beat) error {
+	payload, err := json.Marshal(beat)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxBeatSize {
+		return errors.New("heartbeat: beat too large")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, err = m.send.Write(payload)
+	return err
+}
+
+// Subscribe implements Transport
+func (m *Multicast) Subscribe(handler func(beat *Beat)) error {
+	buf := make([]byte, maxBeatSize)
+	for {
+		n, _, err := m.recv.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		beat := &Beat{}
+		if err = json.Unmarshal(buf[:n], beat); err != nil {
+			// 忽略非心跳数据
+			continue
+		}
+		handler(beat)
+	}
+}
+
+// Close implements Transport
+func (m *Multicast) Close() error {
+	m.send.Close()
+	return m.recv.Close()
+}
+
+// Announcer periodically publishes the heartbeat of this process
+type Announcer struct {
+	transport Transport
+	interval  time.Duration
+	beat      Beat
+	lock      sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewAnnouncer creates an announcer of service
+/*
+ * 创建心跳发送对象
+ * @param transport：心跳传输
+ * @param service：服务名
+ * @param addr：服务地址，可以为空
+ * @param interval：发送间隔
+ * @return 心跳发送对象
+ */
+func NewAnnouncer(transport Transport, service, addr string, interval time.Duration) *Announcer {
+	hostname, _ := os.Hostname()
+	pid := os.Getpid()
+	return &Announcer{
+		transport: transport,
+		interval:  interval,
+		beat: Beat{
+			Service:  service,
+			Instance: hostname + ":" + strconv.Itoa(pid),
+			Host:     hostname,
+			Pid:      pid,
+			Addr:     addr,
+			Status:   "ok",
+		},
+	}
+}
+
+// SetStatus changes the announced health status
+func (a *Announcer) SetStatus(status string) {
+	a.lock.Lock()
+	a.beat.Status = status
+	a.lock.Unlock()
+}
+
+// SetMeta sets an announced metadata item
+func (a *Announcer) SetMeta(key, value string) {
+	a.lock.Lock()
+	if a.beat.Meta == nil {
+		a.beat.Meta = make(map[string]string)
+	}
+	a.beat.Meta[key] = value
+	a.lock.Unlock()
+}
+
+// Start publishes heartbeats in background until Stop
+func (a *Announcer) Start() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.stop != nil {
+		return
+	}
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.loop(a.stop, a.done)
+}
+
+// Stop stops publishing and announces a final "stopped" beat
+func (a *Announcer) Stop() {
+	a.lock.Lock()
+	stop, done := a.stop, a.done
+	a.stop, a.done = nil, nil
+	a.lock.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	a.SetStatus("stopped")
+	a.publish()
+}
+
+func (a *Announcer) loop(stop, done chan struct{}) {
+	defer close(done)
+	a.publish()
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			a.publish()
+		}
+	}
+}
+
+func (a *Announcer) publish() error {
+	a.lock.Lock()
+	a.beat.Seq++
+	a.beat.Time = time.Now()
+	beat := a.beat
+	if a.beat.Meta != nil {
+		beat.Meta = make(map[string]string, len(a.beat.Meta))
+		for k, v := range a.beat.Meta {
+			beat.Meta[k] = v
+		}
+	}
+	a.lock.Unlock()
+	return a.transport.Publish(&beat)
+}
@@ -0,0 +1,142 @@
+package heartbeat
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Peer is the liveness state of a remote process
+type Peer struct {
+	Beat
+	LastSeen time.Time `json:"last_seen"`
+	Alive    bool      `json:"alive"`
+}
+
+// Tracker records received heartbeats and reports peer liveness
+/*
+ * 节点存活跟踪
+ * 超过staleAfter没有收到心跳的节点视为失联，超过forgetAfter的节点被删除
+ * 收到status为stopped的心跳时节点立即视为失联
+ */
+type Tracker struct {
+	sync.RWMutex
+	peers       map[string]*Peer
+	staleAfter  time.Duration
+	forgetAfter time.Duration
+	onChange    func(peer Peer)
+	nowFunc     func() time.Time
+}
+
+// NewTracker creates a tracker
+/*
+ * 创建节点跟踪对象
+ * @param staleAfter：判定失联的时间，一般为心跳间隔的3倍
+ * @param forgetAfter：删除节点的时间，0表示不删除
+ * @return 节点跟踪对象
+ */
+func NewTracker(staleAfter, forgetAfter time.Duration) *Tracker {
+	return &Tracker{
+		peers:       make(map[string]*Peer),
+		staleAfter:  staleAfter,
+		forgetAfter: forgetAfter,
+		nowFunc:     time.Now,
+	}
+}
+
+// OnChange registers a callback invoked when a peer becomes alive or stale
+func (t *Tracker) OnChange(f func(peer Peer)) {
+	t.Lock()
+	t.onChange = f
+	t.Unlock()
+}
+
+// Observe records a received heartbeat
+func (t *Tracker) Observe(beat *Beat) {
+	key := beat.Service + "/" + beat.Instance
+	t.Lock()
+	peer, ok := t.peers[key]
+	if ok && beat.Seq != 0 && beat.Seq < peer.Seq && beat.Pid == peer.Pid {
+		// 乱序到达的旧心跳
+		t.Unlock()
+		return
+	}
+	wasAlive := ok && peer.Alive
+	if !ok {
+		peer = &Peer{}
+		t.peers[key] = peer
+	}
+	peer.Beat = *beat
+	peer.LastSeen = t.nowFunc()
+	peer.Alive = beat.Status != "stopped"
+	changed := wasAlive != peer.Alive
+	current := *peer
+	onChange := t.onChange
+	t.Unlock()
+
+	if changed && onChange != nil {
+		onChange(current)
+	}
+}
+
+// Run receives heartbeats from transport until it is closed
+func (t *Tracker) Run(transport Transport) error {
+	return transport.Subscribe(t.Observe)
+}
+
+// Check marks peers without recent heartbeats as stale, call it periodically
+func (t *Tracker) Check() {
+	now := t.nowFunc()
+	var changed []Peer
+	t.Lock()
+	for key, peer := range t.peers {
+		elapsed := now.Sub(peer.LastSeen)
+		if t.forgetAfter > 0 && elapsed > t.forgetAfter {
+			delete(t.peers, key)
+			continue
+		}
+		if peer.Alive && elapsed > t.staleAfter {
+			peer.Alive = false
+			changed = append(changed, *peer)
+		}
+	}
+	onChange := t.onChange
+	t.Unlock()
+
+	if onChange != nil {
+		for _, peer := range changed {
+			onChange(peer)
+		}
+	}
+}
+
+// Peers returns all known peers of service, or all services if service is empty
+func (t *Tracker) Peers(service string) []Peer {
+	t.Check()
+	t.RLock()
+	peers := make([]Peer, 0, len(t.peers))
+	for _, peer := range t.peers {
+		if service == "" || peer.Service == service {
+			peers = append(peers, *peer)
+		}
+	}
+	t.RUnlock()
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].Service != peers[j].Service {
+			return peers[i].Service < peers[j].Service
+		}
+		return peers[i].Instance < peers[j].Instance
+	})
+	return peers
+}
+
+// Alive returns alive peers of service
+func (t *Tracker) Alive(service string) []Peer {
+	var alive []Peer
+	for _, peer := range t.Peers(service) {
+		if peer.Alive {
+			alive = append(alive, peer)
+		}
+	}
+	return alive
+}
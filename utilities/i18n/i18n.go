@@ -0,0 +1,301 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// LocaleZH is simplified Chinese
+	LocaleZH = "zh-CN"
+	// LocaleEN is English
+	LocaleEN = "en-US"
+	// DefaultLocale is used when no locale is configured
+	DefaultLocale = LocaleZH
+)
+
+// placeholderPattern 模板中的参数占位符，格式为{name}
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// Params are named parameters of a message template
+type Params map[string]interface{}
+
+// Catalog holds message templates of all locales
+/*
+ * 消息目录，按照 locale -> key -> 模板 存储
+ * 模板使用{name}作为参数占位符，例如 "订单{order_id}支付失败"
+ * 查找消息时依次尝试：指定locale、locale的语言部分(如zh)、fallback locale，都找不到时返回key本身
+ */
+type Catalog struct {
+	sync.RWMutex
+	messages map[string]map[string]string
+	locale   string // 当前locale
+	fallback string // 找不到消息时使用的locale
+}
+
+// NewCatalog creates an empty catalog
+/*
+ * 创建消息目录
+ * @param locale：当前locale，为空时使用DefaultLocale
+ * @return 消息目录
+ */
+func NewCatalog(locale string) *Catalog {
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	return &Catalog{
+		messages: make(map[string]map[string]string),
+		locale:   normalizeLocale(locale),
+		fallback: LocaleEN,
+	}
+}
+
+// SetLocale changes the current locale
+func (c *Catalog) SetLocale(locale string) {
+	c.Lock()
+	c.locale = normalizeLocale(locale)
+	c.Unlock()
+}
+
+// Locale returns the current locale
+func (c *Catalog) Locale() string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.locale
+}
+
+// SetFallback changes the locale used when a message is missing
+func (c *Catalog) SetFallback(locale string) {
+	c.Lock()
+	c.fallback = normalizeLocale(locale)
+	c.Unlock()
+}
+
+// Add registers message templates of a locale
+/*
+ * 注册消息模板，已经存在的key会被覆盖
+ * @param locale：消息所属locale
+ * @param messages：key -> 模板
+ */
+func (c *Catalog) Add(locale string, messages map[string]string) {
+	locale = normalizeLocale(locale)
+	c.Lock()
+	defer c.Unlock()
+	catalog, ok := c.messages[locale]
+	if !ok {
+		catalog = make(map[string]string, len(messages))
+		c.messages[locale] = catalog
+	}
+	for key, template := range messages {
+		catalog[key] = template
+	}
+}
+
+// LoadFile loads message templates of a locale from a JSON file
+/*
+ * 从JSON文件加载消息模板，文件内容为 {"key": "模板"}
+ * @param locale：消息所属locale
+ * @param path：文件路径
+ * @return 失败时返回error
+ */
+func (c *Catalog) LoadFile(locale, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	messages := make(map[string]string)
+	if err = json.Unmarshal(content, &messages); err != nil {
+		return fmt.Errorf("i18n: parse %s: %v", path, err)
+	}
+	c.Add(locale, messages)
+	return nil
+}
+
+// LoadDir loads every <locale>.json file under dir
+/*
+ * 加载目录下所有以locale命名的JSON文件，例如 conf/i18n/zh-CN.json、conf/i18n/en-US.json
+ * @param dir：目录路径
+ * @return 失败时返回error
+ */
+func (c *Catalog) LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		locale := strings.TrimSuffix(f.Name(), ".json")
+		if err = c.LoadFile(locale, filepath.Join(dir, f.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// T translates key in the current locale
+/*
+ * 使用当前locale生成消息
+ * @param key：消息key
+ * @param params：模板参数，可以为nil
+ * @return 生成的消息
+ */
+func (c *Catalog) T(key string, params Params) string {
+	return c.TL(c.Locale(), key, params)
+}
+
+// TL translates key in the given locale
+/*
+ * 使用指定locale生成消息
+ * @param locale：locale
+ * @param key：消息key
+ * @param params：模板参数，可以为nil
+ * @return 生成的消息；所有候选locale都找不到时返回key
+ */
+func (c *Catalog) TL(locale, key string, params Params) string {
+	template, ok := c.lookup(normalizeLocale(locale), key)
+	if !ok {
+		return key
+	}
+	return render(template, params)
+}
+
+// Error creates an error whose message is translated in the current locale
+/*
+ * 生成本地化的错误对象，Key()返回消息key便于程序判断
+ * @param key：消息key
+ * @param params：模板参数，可以为nil
+ * @return 错误对象
+ */
+func (c *Catalog) Error(key string, params Params) *Error {
+	return &Error{key: key, params: params, msg: c.T(key, params)}
+}
+
+func (c *Catalog) lookup(locale, key string) (string, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, c.fallback)
+	for _, candidate := range candidates {
+		if template, ok := c.messages[candidate][key]; ok {
+			return template, true
+		}
+	}
+	// 语言相同地区不同时也可以使用，例如zh-TW找不到时使用zh-CN
+	if i := strings.Index(locale, "-"); i > 0 {
+		for candidate, catalog := range c.messages {
+			if strings.HasPrefix(candidate, locale[:i+1]) {
+				if template, ok := catalog[key]; ok {
+					return template, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+/*
+ * 替换模板中的参数，不存在的参数保留原样
+ */
+func render(template string, params Params) string {
+	if len(params) == 0 {
+		return template
+	}
+	return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if value, ok := params[placeholder[1:len(placeholder)-1]]; ok {
+			return fmt.Sprint(value)
+		}
+		return placeholder
+	})
+}
+
+/*
+ * 统一locale写法：zh_CN.UTF-8 -> zh-CN
+ */
+func normalizeLocale(locale string) string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	locale = strings.Replace(locale, "_", "-", -1)
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i])
+	}
+	return strings.Join(parts, "-")
+}
+
+// LocaleFromEnv returns the locale configured by LC_ALL/LC_MESSAGES/LANG
+/*
+ * 从环境变量获取locale，依次检查LC_ALL、LC_MESSAGES、LANG
+ * @return 获取到的locale；都没有设置或者为C/POSIX时返回DefaultLocale
+ */
+func LocaleFromEnv() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" || value == "C" || value == "POSIX" {
+			continue
+		}
+		return normalizeLocale(value)
+	}
+	return DefaultLocale
+}
+
+// Error is an error whose message has been translated
+type Error struct {
+	key    string
+	params Params
+	msg    string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Key returns the message key of the error
+func (e *Error) Key() string {
+	return e.key
+}
+
+// Params returns the template params of the error
+func (e *Error) Params() Params {
+	return e.params
+}
+
+// defaultCatalog 包级别默认消息目录
+var defaultCatalog = NewCatalog(DefaultLocale)
+
+// Default returns the package level catalog
+func Default() *Catalog {
+	return defaultCatalog
+}
+
+// SetLocale changes the locale of the default catalog
+func SetLocale(locale string) {
+	defaultCatalog.SetLocale(locale)
+}
+
+// Add registers messages into the default catalog
+func Add(locale string, messages map[string]string) {
+	defaultCatalog.Add(locale, messages)
+}
+
+// T translates key with the default catalog
+func T(key string, params Params) string {
+	return defaultCatalog.T(key, params)
+}
+
+// NewError creates a translated error with the default catalog
+func NewError(key string, params Params) *Error {
+	return defaultCatalog.Error(key, params)
+}
@@ -0,0 +1,125 @@
+package idgen
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/netutil"
+)
+
+// 64位ID的组成：1位符号位(始终为0) + 41位毫秒时间戳 + 10位worker + 12位序号
+const (
+	WorkerBits   = 10
+	SequenceBits = 12
+	MaxWorker    = 1<<WorkerBits - 1
+	maxSequence  = 1<<SequenceBits - 1
+	timeShift    = WorkerBits + SequenceBits
+)
+
+// Epoch is the time of timestamp zero, IDs stay positive for about 69 years after it
+var Epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Generator produces sortable 64-bit IDs, snowflake style
+/*
+ * 64位ID生成器，同一个worker生成的ID严格递增，不同worker之间按照毫秒大致有序
+ * 每毫秒最多4096个ID，超过时借用下一毫秒；系统时间回拨时继续使用上一次的时间，不会生成重复ID
+ * 同时运行的进程必须使用不同的worker，否则可能重复
+ */
+type Generator struct {
+	lock     sync.Mutex
+	worker   int64
+	last     int64 // 上一次使用的毫秒时间戳(相对Epoch)
+	sequence int64
+}
+
+// NewGenerator creates a generator for worker
+/*
+ * 创建ID生成器
+ * @param worker：worker编号，0~MaxWorker，可以由WorkerFromIP或者WorkerFromPID得到
+ * @return worker超出范围时返回error
+ */
+func NewGenerator(worker int64) (*Generator, error) {
+	if worker < 0 || worker > MaxWorker {
+		return nil, fmt.Errorf("idgen: worker %d out of range 0-%d", worker, MaxWorker)
+	}
+	return &Generator{worker: worker}, nil
+}
+
+// Next returns the next ID
+func (g *Generator) Next() int64 {
+	now := time.Since(Epoch).Milliseconds()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if now > g.last {
+		g.last, g.sequence = now, 0
+	} else if g.sequence < maxSequence {
+		// 同一毫秒或者时间回拨，沿用上一次的时间
+		g.sequence++
+	} else {
+		g.last, g.sequence = g.last+1, 0
+	}
+	return g.last<<timeShift | g.worker<<SequenceBits | g.sequence
+}
+
+// Worker returns the worker of the generator
+func (g *Generator) Worker() int64 {
+	return g.worker
+}
+
+// Parse splits an ID into its time, worker and sequence
+func Parse(id int64) (t time.Time, worker, sequence int64) {
+	t = Epoch.Add(time.Duration(id>>timeShift) * time.Millisecond)
+	return t, id >> SequenceBits & MaxWorker, id & maxSequence
+}
+
+// WorkerFromIP derives a worker from the low bits of the inner IPv4 address
+/*
+ * 使用内网IPv4地址(与logger.GetInnerIp相同)的低10位作为worker，
+ * 同一个/22网段内的机器worker不同，适合每台机器只运行一个实例的部署
+ * @return 没有可用地址时返回error
+ */
+func WorkerFromIP() (int64, error) {
+	ip := net.ParseIP(netutil.InnerIP()).To4()
+	if ip == nil {
+		return 0, errors.New("idgen: no inner IPv4 address")
+	}
+	return (int64(ip[2])<<8 | int64(ip[3])) & MaxWorker, nil
+}
+
+// WorkerFromPID derives a worker from the low bits of the process id
+/*
+ * 使用进程id的低10位作为worker，适合单机多实例的部署，pid相差1024的整数倍时会冲突
+ */
+func WorkerFromPID() int64 {
+	return int64(os.Getpid()) & MaxWorker
+}
+
+var (
+	defaultOnce      sync.Once
+	defaultGenerator *Generator
+)
+
+// Default returns the process wide generator
+/*
+ * 全局ID生成器，第一次使用时创建，worker取WorkerFromIP，没有内网地址时取WorkerFromPID
+ * 需要其他worker时使用NewGenerator
+ */
+func Default() *Generator {
+	defaultOnce.Do(func() {
+		worker, err := WorkerFromIP()
+		if err != nil {
+			worker = WorkerFromPID()
+		}
+		defaultGenerator, _ = NewGenerator(worker)
+	})
+	return defaultGenerator
+}
+
+// Next returns the next ID of the default generator
+func Next() int64 {
+	return Default().Next()
+}
@@ -0,0 +1,58 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// crockford ULID使用的Crockford base32字母表，不包含I、L、O、U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a 26 character ULID: 48-bit millisecond time followed by 80 random bits
+/*
+ * 生成ULID，按照字符串排序即为按照生成时间(毫秒)排序，不需要分配worker，适合作为日志以及请求的关联id
+ * 同一毫秒内生成的ULID之间的顺序是随机的
+ */
+func NewULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	rand.Read(b[6:])
+
+	// 128位按照5位一组编码为26个字符，最高的2位补0
+	var out [26]byte
+	hi := uint64(b[0])<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32 |
+		uint64(b[4])<<24 | uint64(b[5])<<16 | uint64(b[6])<<8 | uint64(b[7])
+	lo := uint64(b[8])<<56 | uint64(b[9])<<48 | uint64(b[10])<<40 | uint64(b[11])<<32 |
+		uint64(b[12])<<24 | uint64(b[13])<<16 | uint64(b[14])<<8 | uint64(b[15])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// NewUUID returns a random RFC 4122 version 4 UUID
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // 版本4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122变体
+
+	var out [36]byte
+	hex.Encode(out[0:8], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:])
+}
@@ -0,0 +1,276 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogFormat is the format of the records written by AccessLog
+type AccessLogFormat int
+
+const (
+	// AccessCombined is the Apache/Nginx combined log format
+	AccessCombined AccessLogFormat = iota
+	// AccessCommon is the Common Log Format
+	AccessCommon
+	// AccessJSON writes one JSON object per request
+	AccessJSON
+)
+
+// clfTimeFormat 访问日志中的时间格式，例如10/Oct/2000:13:55:36 -0700
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// defaultAccessChannel 访问日志默认的通道名称
+const defaultAccessChannel = "access"
+
+// AccessLogConfig configures the AccessLog middleware
+type AccessLogConfig struct {
+	Format     AccessLogFormat // 记录格式，默认combined
+	Channel    string          // 写入的通道名称，默认access，即filename-access.log
+	TrustProxy bool            // 使用X-Forwarded-For/X-Real-IP中的客户端地址，只在前面有可信的反向代理时开启
+	Latency    bool            // combined/common格式在末尾追加处理时间(秒，精确到毫秒)，与nginx的$request_time相同；JSON格式总是记录
+}
+
+// accessRecord 一次请求的访问记录，同时作为JSON格式的输出
+type accessRecord struct {
+	Time      string  `json:"time"`
+	RemoteIP  string  `json:"remote_ip"`
+	User      string  `json:"user,omitempty"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	Latency   float64 `json:"latency_ms"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	Host      string  `json:"host,omitempty"`
+}
+
+// AccessLog returns an HTTP middleware writing one record per request to a channel
+/*
+ * 访问日志中间件，每个请求处理完成之后写入一条记录，例如：
+ *     mux := http.NewServeMux()
+ *     http.ListenAndServe(":8080", logger.AccessLog(AccessLogConfig{})(mux))
+ * combined格式： 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://www.example.com/" "Mozilla/4.08"
+ * 记录写入Channel(config.Channel)，按照原样写入，不经过编码器、hook以及sink
+ * 处理函数panic时记录状态码500之后继续panic
+ * @param config：访问日志配置
+ * @return 中间件
+ */
+func (logger *Logger) AccessLog(config AccessLogConfig) func(http.Handler) http.Handler {
+	if config.Channel == "" {
+		config.Channel = defaultAccessChannel
+	}
+	channel := logger.Channel(config.Channel)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := logger.now()
+			rec := &accessWriter{ResponseWriter: w}
+			defer func() {
+				if err := recover(); err != nil {
+					if rec.status == 0 {
+						rec.status = http.StatusInternalServerError
+					}
+					channel.writeLine(config.format(r, rec, start))
+					panic(err)
+				}
+				channel.writeLine(config.format(r, rec, start))
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+/*
+ * 按照配置的格式生成一条访问记录
+ */
+func (config *AccessLogConfig) format(r *http.Request, rec *accessWriter, start time.Time) string {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	record := accessRecord{
+		Time:      start.Format(clfTimeFormat),
+		RemoteIP:  remoteIP(r, config.TrustProxy),
+		User:      requestUser(r),
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Status:    status,
+		Bytes:     rec.bytes,
+		Latency:   float64(time.Since(start).Microseconds()) / 1000,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		Host:      r.Host,
+	}
+	if record.URI == "" {
+		record.URI = r.URL.RequestURI()
+	}
+
+	if config.Format == AccessJSON {
+		record.Time = start.Format(time.RFC3339Nano)
+		b, _ := json.Marshal(&record)
+		return string(b)
+	}
+	var b strings.Builder
+	b.WriteString(record.RemoteIP)
+	b.WriteString(" - ")
+	b.WriteString(clfField(record.User))
+	b.WriteString(" [")
+	b.WriteString(record.Time)
+	b.WriteString("] \"")
+	b.WriteString(clfEscape(record.Method + " " + record.URI + " " + record.Proto))
+	b.WriteString("\" ")
+	b.WriteString(strconv.Itoa(record.Status))
+	b.WriteByte(' ')
+	if record.Bytes == 0 {
+		b.WriteByte('-')
+	} else {
+		b.WriteString(strconv.FormatInt(record.Bytes, 10))
+	}
+	if config.Format == AccessCombined {
+		b.WriteString(" \"")
+		b.WriteString(clfEscape(clfField(record.Referer)))
+		b.WriteString("\" \"")
+		b.WriteString(clfEscape(clfField(record.UserAgent)))
+		b.WriteByte('"')
+	}
+	if config.Latency {
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(record.Latency/1000, 'f', 3, 64))
+	}
+	return b.String()
+}
+
+/*
+ * 获取客户端地址，trustProxy时优先使用X-Forwarded-For中的第一个地址以及X-Real-IP
+ */
+func remoteIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			if i := strings.IndexByte(forwarded, ','); i >= 0 {
+				forwarded = forwarded[:i]
+			}
+			if ip := strings.TrimSpace(forwarded); ip != "" {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+/*
+ * 获取basic认证或者URL中的用户名
+ */
+func requestUser(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+	if r.URL != nil && r.URL.User != nil {
+		return r.URL.User.Username()
+	}
+	return ""
+}
+
+/*
+ * 空字段输出为"-"
+ */
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+/*
+ * 转义双引号、反斜杠以及控制字符，与nginx的转义方式相同，避免一条记录被拆成多行
+ */
+func clfEscape(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' || c < 0x20 || c == 0x7f {
+			return clfEscapeSlow(s)
+		}
+	}
+	return s
+}
+
+/*
+ * 逐字节转义
+ */
+func clfEscapeSlow(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' || c == '\\' || c < 0x20 || c == 0x7f {
+			b.WriteString(`\x`)
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// accessWriter 记录响应状态码以及长度
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code, informational responses other than 101 are skipped
+func (w *accessWriter) WriteHeader(status int) {
+	if w.status == 0 && (status >= http.StatusOK || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the response size
+func (w *accessWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher when the underlying writer does
+func (w *accessWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the underlying writer does
+func (w *accessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("logger: response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter, used by http.ResponseController
+func (w *accessWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
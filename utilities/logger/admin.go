@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLevelBody LevelHandler以及DebugHandler请求内容的最大长度
+const maxLevelBody = 256
+
+// RotateHandler returns an http.Handler which rotates all log files on POST
+/*
+ * 返回强制切分日志的管理接口，挂载到管理端口后使用POST请求触发切分
+ * 例如: curl -X POST http://127.0.0.1:8080/admin/log/rotate
+ */
+func (logger *Logger) RotateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		logger.Rotate()
+		w.Write([]byte("rotated\n"))
+	})
+}
+
+// LevelHandler returns an http.Handler which reads the level on GET and changes it on PUT
+/*
+ * 返回运行时调整记录级别的管理接口，与SetLevel/SetMinLevel可以并发使用
+ * GET返回当前记录级别名称；PUT的请求内容为级别名称，all表示记录所有级别
+ * 例如: curl http://127.0.0.1:8080/admin/log/level
+ *       curl -X PUT -d debug http://127.0.0.1:8080/admin/log/level
+ */
+func (logger *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxLevelBody))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			name := strings.TrimSpace(string(body))
+			if err := logger.SetLevelString(name); err != nil {
+				http.Error(w, "unknown level "+strconv.Quote(name), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name, severity := logger.MinLevel()
+		if name == "" {
+			name = strconv.Itoa(severity)
+		}
+		w.Write([]byte(name + "\n"))
+	})
+}
+
+// DebugHandler returns an http.Handler which switches debug features of a module at run time
+/*
+ * 返回运行时调整模块调试开关的管理接口，参数与SetDebugToggle对应，可以放在URL或者表单中：
+ * module为模块名称，为空表示所有日志对象；caller/stack/hexdump取值为on/off/default；level为模块级别；ttl为生效时间，例如10m
+ * GET返回JSON格式的所有开关；PUT/POST设置开关；DELETE恢复module的开关
+ * 例如: curl -X PUT 'http://127.0.0.1:8080/admin/log/debug?module=rpc&caller=on&stack=on&level=debug&ttl=10m'
+ *       curl -X DELETE 'http://127.0.0.1:8080/admin/log/debug?module=rpc'
+ */
+func (logger *Logger) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost, http.MethodDelete:
+			r.Body = http.MaxBytesReader(w, r.Body, maxLevelBody)
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			module := strings.TrimSpace(r.Form.Get("module"))
+			if r.Method == http.MethodDelete {
+				logger.ResetDebugToggle(module)
+				break
+			}
+			toggle, ttl, err := parseDebugForm(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := logger.SetDebugToggle(module, toggle, ttl); err != nil {
+				http.Error(w, "unknown level "+strconv.Quote(toggle.Level), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(logger.DebugToggles())
+	})
+}
+
+/*
+ * 解析DebugHandler的请求参数
+ */
+func parseDebugForm(r *http.Request) (DebugToggle, time.Duration, error) {
+	var toggle DebugToggle
+	for _, item := range []struct {
+		name  string
+		value *Toggle
+	}{{"caller", &toggle.Caller}, {"stack", &toggle.Stack}, {"hexdump", &toggle.HexDump}} {
+		value, err := ParseToggle(r.Form.Get(item.name))
+		if err != nil {
+			return toggle, 0, errors.New("invalid " + item.name + " " + strconv.Quote(r.Form.Get(item.name)))
+		}
+		*item.value = value
+	}
+	toggle.Level = strings.TrimSpace(r.Form.Get("level"))
+	var ttl time.Duration
+	if value := r.Form.Get("ttl"); value != "" {
+		var err error
+		if ttl, err = time.ParseDuration(value); err != nil {
+			return toggle, 0, errors.New("invalid ttl " + strconv.Quote(value))
+		}
+	}
+	return toggle, ttl, nil
+}
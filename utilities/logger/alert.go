@@ -0,0 +1,160 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// AlertRule fires when more than Threshold matching records occur within Window
+/*
+ * 日志告警规则
+ * 匹配条件：级别(为空表示所有级别)、包含子串Contains、匹配正则Pattern，条件之间为"与"的关系
+ * 触发条件：Window时间内匹配的记录数超过Threshold
+ * 触发后在Cooldown时间内不会再次触发，Cooldown为0时使用Window
+ */
+type AlertRule struct {
+	Name      string
+	Level     string
+	Contains  string
+	Pattern   *regexp.Regexp
+	Threshold int
+	Window    time.Duration
+	Cooldown  time.Duration
+}
+
+// Alert is fired when an alert rule is triggered
+type Alert struct {
+	Rule   string
+	Level  string
+	Count  int           // 窗口内匹配的记录数
+	Window time.Duration // 规则窗口
+	Sample string        // 最后一条匹配的记录
+	Time   time.Time
+}
+
+// alertState 规则运行状态
+type alertState struct {
+	rule      AlertRule
+	contains  []byte
+	hits      []time.Time // 窗口内匹配记录的时间，最多保留Threshold+1条
+	lastFired time.Time
+}
+
+// alertEngine 告警规则引擎，在flush协程中对写入文件的每条记录进行匹配
+type alertEngine struct {
+	sync.Mutex
+	rules  []*alertState
+	notify func(Alert)
+}
+
+// AddAlertRule registers an alert rule evaluated on flushed records
+/*
+ * 添加告警规则，规则在flush协程写入文件时对每条记录进行匹配，不影响写日志的调用方
+ * @param rule：告警规则
+ * @return 规则不合法时返回error
+ */
+func (logger *Logger) AddAlertRule(rule AlertRule) error {
+	if rule.Name == "" || rule.Threshold <= 0 || rule.Window <= 0 {
+		return errors.New("logger: alert rule requires name, threshold and window")
+	}
+	if rule.Level != "" && !logger.hasLevel(rule.Level) {
+		return ErrUnknownLevel
+	}
+	if rule.Cooldown <= 0 {
+		rule.Cooldown = rule.Window
+	}
+	logger.alerts.Lock()
+	logger.alerts.rules = append(logger.alerts.rules, &alertState{rule: rule, contains: []byte(rule.Contains)})
+	logger.alerts.Unlock()
+	return nil
+}
+
+// SetAlertNotifier sets the callback invoked when a rule fires
+/*
+ * 设置告警通知回调，回调在独立的协程中执行
+ * @param f：通知回调，例如发送短信、邮件或者调用告警平台接口
+ */
+func (logger *Logger) SetAlertNotifier(f func(Alert)) {
+	logger.alerts.Lock()
+	logger.alerts.notify = f
+	logger.alerts.Unlock()
+}
+
+/*
+ * 对一批写入文件的内容逐条匹配告警规则
+ * @param level：内容所属的日志级别，自定义文件为空
+ * @param content：flush的内容，每行一条记录
+ */
+func (engine *alertEngine) evaluate(level string, content []byte) {
+	if engine == nil {
+		return
+	}
+	engine.Lock()
+	defer engine.Unlock()
+	if len(engine.rules) == 0 || engine.notify == nil {
+		return
+	}
+
+	now := time.Now()
+	for len(content) > 0 {
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i], content[i+1:]
+		} else {
+			content = nil
+		}
+		for _, state := range engine.rules {
+			if state.match(level, line) {
+				engine.hit(state, line, now)
+			}
+		}
+	}
+}
+
+func (state *alertState) match(level string, line []byte) bool {
+	if state.rule.Level != "" && state.rule.Level != level {
+		return false
+	}
+	if len(state.contains) > 0 && !bytes.Contains(line, state.contains) {
+		return false
+	}
+	if state.rule.Pattern != nil && !state.rule.Pattern.Match(line) {
+		return false
+	}
+	return true
+}
+
+/*
+ * 记录一次匹配，窗口内匹配数超过阈值时触发告警
+ */
+func (engine *alertEngine) hit(state *alertState, line []byte, now time.Time) {
+	cutoff := now.Add(-state.rule.Window)
+	hits := state.hits[:0]
+	for _, t := range state.hits {
+		if t.After(cutoff) {
+			hits = append(hits, t)
+		}
+	}
+	state.hits = append(hits, now)
+	if len(state.hits) > state.rule.Threshold+1 {
+		state.hits = state.hits[len(state.hits)-state.rule.Threshold-1:]
+	}
+
+	if len(state.hits) <= state.rule.Threshold || now.Sub(state.lastFired) < state.rule.Cooldown {
+		return
+	}
+	state.lastFired = now
+	alert := Alert{
+		Rule:   state.rule.Name,
+		Level:  state.rule.Level,
+		Count:  len(state.hits),
+		Window: state.rule.Window,
+		Sample: string(line),
+		Time:   now,
+	}
+	state.hits = state.hits[:0]
+	go engine.notify(alert)
+}
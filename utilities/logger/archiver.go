@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveQueueSize 等待压缩以及备份的任务队列长度
+const archiveQueueSize = 16
+
+// archiveTask 切分文件的后续处理，由归档协程按照提交顺序执行
+type archiveTask struct {
+	compress  string        // 需要压缩的切分文件，为空表示不压缩
+	rotated   string        // 刚切分出来的文件，不为空时执行切分回调
+	source    string        // 切分之前的文件名，即当前日志文件名
+	hour      time.Time     // 需要备份的时间段，零值表示不备份
+	files     []string      // 提交时已经存在的切分文件，之后切分出来的文件不会被移动
+	backupDir string        // 提交时的备份目录，Relocate之后仍然备份到原来的目录
+	done      chan struct{} // 不为nil时在任务完成之后关闭
+}
+
+// backupRequest 通过LoggerBackup提交的备份请求
+type backupRequest struct {
+	hour time.Time
+	done chan struct{}
+}
+
+/*
+ * 切分文件的重命名以及新文件的创建只在FlushBufferQueue协程中进行，
+ * 切分之后的压缩以及移动到备份目录只在归档协程中按照顺序进行：
+ * 同一个时间段的压缩一定在备份之前完成，不同时间段的备份不会并发，也不会与写入协程同时操作同一个文件
+ * FlushBufferQueue退出时关闭任务队列，归档协程处理完剩余的任务之后退出
+ */
+func (logger *LoggerInfo) runArchiver() {
+	defer close(logger.archiverDone)
+	for task := range logger.archiveQueue {
+		rotated := task.rotated
+		if task.compress != "" {
+			logger.throttleArchive(fileSize(task.compress))
+			if err := compressFile(task.compress); err != nil {
+				logger.reporter.report("Rotate.Compress", err)
+			} else if rotated == task.compress {
+				rotated += gzipSuffix
+			}
+		}
+		if rotated != "" {
+			logger.rotateHooks.notify(logger.reporter, task.source, rotated)
+		}
+		if !task.hour.IsZero() {
+			logger.backupHour(task)
+		}
+		if task.done != nil {
+			close(task.done)
+		}
+	}
+}
+
+/*
+ * 压缩之前等待节流，队列积压超过一半时不再等待，避免写入协程提交任务时被阻塞
+ * @param size：待压缩文件的大小
+ */
+func (logger *LoggerInfo) throttleArchive(size int64) {
+	if len(logger.archiveQueue) < archiveQueueSize/2 {
+		waitThrottle(logger.throttle, logger.closeChan, size)
+	}
+}
+
+/*
+ * 提交切分文件的后续处理，只能在FlushBufferQueue协程中调用
+ * CompressOnRotate模式下压缩切分文件；注册了切分回调时在压缩之后执行回调
+ * @param filename：切分后的文件名
+ */
+func (logger *LoggerInfo) afterRotate(filename string) {
+	task := archiveTask{}
+	if logger.compression == CompressOnRotate {
+		task.compress = filename
+	}
+	if logger.rotateHooks.active() {
+		task.rotated, task.source = filename, logger.filename
+	}
+	if task.compress == "" && task.rotated == "" {
+		return
+	}
+	logger.archiveQueue <- task
+}
+
+/*
+ * 提交时间段的备份任务，只能在FlushBufferQueue协程中调用，备份的文件以及备份目录取提交时的值
+ * @param hour：需要备份的时间段
+ * @param done：任务完成之后关闭，可以为nil
+ */
+func (logger *LoggerInfo) scheduleBackup(hour time.Time, done chan struct{}) {
+	if logger.backupDir == "" {
+		if done != nil {
+			close(done)
+		}
+		return
+	}
+	logger.archiveQueue <- archiveTask{
+		hour:      hour,
+		files:     logger.rotatedFilesOf(hour),
+		backupDir: logger.backupDir,
+		done:      done,
+	}
+}
+
+/*
+ * 获取时间段内已经切分出来的文件，压缩文件以及正在压缩的文件按照原文件名返回
+ * @param prefix：切分文件名前缀，例如saver-error.log.2014091010
+ * @return 切分文件名
+ */
+func rotatedFiles(prefix string) []string {
+	matches, _ := filepath.Glob(prefix + "*")
+	files := make([]string, 0, len(matches))
+	seen := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimSuffix(match, gzipTmpSuffix), gzipSuffix)
+		if rest := name[len(prefix):]; rest != "" && (rest[0] != '.' || strings.Trim(rest[1:], "0123456789") != "") {
+			// 其他时间段或者其他日志文件
+			continue
+		}
+		if !seen[name] {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+/*
+ * 将时间段内的切分文件移动到备份目录
+ * backupDir -> /data/servers/log/saver/trace/2014-09-10/*.log
+ */
+func (logger *LoggerInfo) backupHour(task archiveTask) {
+	key := task.hour.Format(DATEFORMAT)
+	if logger.backupSub != "" {
+		// 文件名模板包含目录时保留目录，避免不同级别的同名文件冲突
+		key += "/" + filepath.ToSlash(logger.backupSub)
+	}
+	backupDir := filepath.Join(task.backupDir, filepath.FromSlash(key))
+	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
+		mkdirAll(backupDir, logger.dirMode, logger.owner)
+	}
+	/* backup filename like saver-error.log.2014091010 and saver-error.log.2014091010.{0/1...} */
+	for _, file := range task.files {
+		logger.backupFile(file, backupDir, key)
+	}
+}
@@ -0,0 +1,231 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// auditTailSize 打开已有的审计文件时读取的末尾长度，用于接续上一条记录的hash
+const auditTailSize = 64 * KB
+
+// auditMaxLine 校验时单条记录的最大长度
+const auditMaxLine = 16 * MB
+
+// ErrAuditChainBroken is wrapped by the error VerifyAuditFile returns for a tampered file
+var ErrAuditChainBroken = errors.New("logger: audit hash chain broken")
+
+// AuditConfig configures an AuditWriter
+type AuditConfig struct {
+	Path     string      // 审计文件路径，已经存在时追加并接续hash链
+	Key      []byte      // HMAC密钥，不能为空
+	FileMode os.FileMode // 创建文件的权限，默认0600
+	Sync     bool        // 每次写入之后调用fsync
+}
+
+// AuditWriter is a sink writing records into a tamper-evident audit file
+/*
+ * 审计日志输出，每条记录之后追加一个tab以及HMAC-SHA256的十六进制值：
+ *     记录内容\t hex(HMAC(key, 上一条记录的hash || 记录内容))
+ * 第一条记录的上一条hash为空；修改、删除或者插入任意一条记录都会导致之后第一条记录的校验失败，通过VerifyAuditFile检查
+ * 审计文件不参与切分和备份，通常为审计记录注册单独的级别并只输出到该sink，例如：
+ *     logger.RegisterLevel("audit", SeverityError+1)
+ *     w, err := NewAuditWriter(AuditConfig{Path: "/data/audit/saver.log", Key: key})
+ *     logger.AddSink("audit", w, "audit")
+ */
+type AuditWriter struct {
+	mu     sync.Mutex
+	config AuditConfig
+	file   *os.File
+	prev   []byte // 上一条记录的hash
+}
+
+// AuditBreak describes the first record failing verification
+type AuditBreak struct {
+	Line   int    // 行号，从1开始
+	Reason string // 失败原因
+}
+
+// Error implements error
+func (b *AuditBreak) Error() string {
+	return fmt.Sprintf("%v: line %d: %s", ErrAuditChainBroken, b.Line, b.Reason)
+}
+
+// Unwrap returns ErrAuditChainBroken
+func (b *AuditBreak) Unwrap() error {
+	return ErrAuditChainBroken
+}
+
+// NewAuditWriter opens the audit file described by config
+/*
+ * 打开或者创建审计文件，文件已经存在时读取最后一条记录的hash，之后的记录接在原有的链后面
+ * @param config：审计配置
+ * @return 密钥为空、打开文件失败或者最后一条记录格式错误时返回error
+ */
+func NewAuditWriter(config AuditConfig) (*AuditWriter, error) {
+	if len(config.Key) == 0 {
+		return nil, errors.New("logger: audit key required")
+	}
+	if config.FileMode == 0 {
+		config.FileMode = 0600
+	}
+	file, err := os.OpenFile(config.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, config.FileMode)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := lastAuditHash(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("logger: audit file %s: %w", config.Path, err)
+	}
+	return &AuditWriter{config: config, file: file, prev: prev}, nil
+}
+
+// Write implements io.Writer, each line of p is chained and written as one record
+func (w *AuditWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	var buf bytes.Buffer
+	prev := w.prev
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line, p = p[:i], p[i+1:]
+		} else {
+			p = nil
+		}
+		if len(line) == 0 {
+			continue
+		}
+		prev = auditHash(w.config.Key, prev, line)
+		buf.Write(line)
+		buf.WriteByte('\t')
+		buf.WriteString(hex.EncodeToString(prev))
+		buf.WriteByte('\n')
+	}
+	if _, err := w.file.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	w.prev = prev
+	if w.config.Sync {
+		if err := w.file.Sync(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Close closes the audit file
+func (w *AuditWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// VerifyAuditFile checks the hash chain of an audit file written by AuditWriter
+/*
+ * 从头校验审计文件的hash链
+ * @param path：审计文件路径
+ * @param key：写入时使用的HMAC密钥
+ * @return (校验通过的记录数, error)；链断开时error为*AuditBreak，可以通过errors.Is(err, ErrAuditChainBroken)判断
+ */
+func VerifyAuditFile(path string, key []byte) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*KB), int(auditMaxLine))
+	var prev []byte
+	lines := 0
+	for scanner.Scan() {
+		record, sum, err := splitAuditLine(scanner.Bytes())
+		if err != nil {
+			return lines, &AuditBreak{Line: lines + 1, Reason: err.Error()}
+		}
+		expected := auditHash(key, prev, record)
+		if !hmac.Equal(sum, expected) {
+			return lines, &AuditBreak{Line: lines + 1, Reason: "hash mismatch"}
+		}
+		prev = expected
+		lines++
+	}
+	return lines, scanner.Err()
+}
+
+/*
+ * 计算记录的hash：HMAC-SHA256(key, prev || record)
+ */
+func auditHash(key, prev, record []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(prev)
+	mac.Write(record)
+	return mac.Sum(nil)
+}
+
+/*
+ * 将审计文件中的一行拆分为记录内容以及hash
+ */
+func splitAuditLine(line []byte) (record, sum []byte, err error) {
+	i := bytes.LastIndexByte(line, '\t')
+	if i < 0 {
+		return nil, nil, errors.New("missing hash")
+	}
+	sum, err = hex.DecodeString(string(line[i+1:]))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, nil, errors.New("malformed hash")
+	}
+	return line[:i], sum, nil
+}
+
+/*
+ * 读取审计文件最后一条记录的hash，空文件返回nil
+ * 最后一行没有换行时说明上次写入不完整，返回error，需要人工处理
+ */
+func lastAuditHash(file *os.File) ([]byte, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+	if size == 0 {
+		return nil, nil
+	}
+	offset := size - auditTailSize
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, size-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if tail[len(tail)-1] != '\n' {
+		return nil, errors.New("last record is incomplete")
+	}
+	tail = tail[:len(tail)-1]
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	} else if offset > 0 {
+		return nil, errors.New("last record is too long")
+	}
+	_, sum, err := splitAuditLine(tail)
+	return sum, err
+}
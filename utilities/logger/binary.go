@@ -0,0 +1,170 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/lucifinil-long/nano-legion/utilities/codec"
+)
+
+// binaryHeaderSize 二进制记录的长度前缀字节数
+const binaryHeaderSize = 4
+
+// MsgpackEncoder encodes entries as length-prefixed msgpack maps for binary sinks
+/*
+ * msgpack编码，字段与JSONEncoder相同：schema、time(msgpack时间戳)、level、logger、caller、msg、suffix、stack以及平铺的附加字段
+ * 每条记录为4字节大端长度前缀加一个msgpack map，不以换行符结尾，只用于AddEncodedSink添加的二进制sink，
+ * 例如ShipConfig.Binary或者KafkaConfig.Binary为true的ShipWriter/KafkaWriter；不要作为日志文件的编码器
+ * 比JSONEncoder体积更小，附加字段保留数值以及布尔类型，例如：
+ *   w, err := logger.NewShipWriter(logger.ShipConfig{Address: "10.0.0.1:5170", Binary: true})
+ *   log.AddEncodedSink("ship", w, logger.MsgpackEncoder{})
+ */
+type MsgpackEncoder struct{}
+
+// ProtoEncoder encodes entries as length-prefixed codec.LogRecord protobuf messages
+/*
+ * protobuf编码，消息定义参考codec/record.proto，附加字段的值转换为字符串
+ * 与MsgpackEncoder相同，每条记录带4字节大端长度前缀，只用于二进制sink
+ */
+type ProtoEncoder struct{}
+
+// Encode implements Encoder
+func (encoder MsgpackEncoder) Encode(entry *Entry) []byte {
+	buf := make([]byte, binaryHeaderSize, 128+16*(len(entry.Args)+len(entry.Fields)))
+	buf = encoder.appendEntry(buf, entry)
+	binary.BigEndian.PutUint32(buf, uint32(len(buf)-binaryHeaderSize))
+	return buf
+}
+
+/*
+ * 将msgpack map追加到buf
+ */
+func (MsgpackEncoder) appendEntry(buf []byte, entry *Entry) []byte {
+	withTime := entry.TimeLayout != TimeNone
+	withSuffix := entry.WithSuffix && entry.Suffix != ""
+	n := 2 + len(entry.Fields) // schema、msg以及附加字段
+	for _, present := range []bool{withTime, entry.Level != "", entry.Name != "", entry.Caller != "", withSuffix, len(entry.Stack) > 0} {
+		if present {
+			n++
+		}
+	}
+	buf = codec.AppendMapHeader(buf, n)
+	buf = codec.AppendString(buf, "schema")
+	buf = codec.AppendInt(buf, JSONSchemaVersion)
+	if withTime {
+		buf = codec.AppendString(buf, "time")
+		buf = codec.AppendTime(buf, entry.Time)
+	}
+	if entry.Level != "" {
+		buf = codec.AppendString(buf, "level")
+		buf = codec.AppendString(buf, entry.Level)
+	}
+	if entry.Name != "" {
+		buf = codec.AppendString(buf, "logger")
+		buf = codec.AppendString(buf, entry.Name)
+	}
+	if entry.Caller != "" {
+		buf = codec.AppendString(buf, "caller")
+		buf = codec.AppendString(buf, entry.Caller)
+	}
+
+	msg := getScratch()
+	*msg = appendMessage(*msg, entry.Args)
+	buf = codec.AppendString(buf, "msg")
+	buf = codec.AppendString(buf, string(*msg))
+	putScratch(msg)
+
+	if withSuffix {
+		buf = codec.AppendString(buf, "suffix")
+		buf = codec.AppendString(buf, entry.Suffix)
+	}
+	if len(entry.Stack) > 0 {
+		buf = codec.AppendString(buf, "stack")
+		buf = codec.AppendString(buf, formatStack(entry.Stack))
+	}
+	for _, key := range sortedFieldKeys(entry.Fields) {
+		name := key
+		if jsonReservedKeys[key] || (key == "logger" && entry.Name != "") {
+			name = "fields." + key
+		}
+		buf = codec.AppendString(buf, name)
+		mark := len(buf)
+		var err error
+		if buf, err = codec.AppendMsgpack(buf, entry.Fields[key]); err != nil {
+			buf = codec.AppendString(buf[:mark], string(appendArg(nil, entry.Fields[key])))
+		}
+	}
+	return buf
+}
+
+// Encode implements Encoder
+func (ProtoEncoder) Encode(entry *Entry) []byte {
+	record := codec.LogRecord{
+		Level:  entry.Level,
+		Logger: entry.Name,
+		Caller: entry.Caller,
+	}
+	if entry.TimeLayout != TimeNone {
+		record.Time = entry.Time.UnixNano()
+	}
+	msg := getScratch()
+	*msg = appendMessage(*msg, entry.Args)
+	record.Message = string(*msg)
+	putScratch(msg)
+	if entry.WithSuffix {
+		record.Suffix = entry.Suffix
+	}
+	if len(entry.Stack) > 0 {
+		record.Stack = formatStack(entry.Stack)
+	}
+	if len(entry.Fields) > 0 {
+		record.Fields = make(map[string]string, len(entry.Fields))
+		for key, value := range entry.Fields {
+			record.Fields[key] = string(appendArg(nil, value))
+		}
+	}
+	buf := make([]byte, binaryHeaderSize, 128+16*(len(entry.Args)+len(entry.Fields)))
+	buf, err := codec.Protobuf.Append(buf, &record)
+	if err != nil {
+		return nil
+	}
+	binary.BigEndian.PutUint32(buf, uint32(len(buf)-binaryHeaderSize))
+	return buf
+}
+
+/*
+ * 所有参数以"|"连接，与JSONEncoder的msg相同
+ */
+func appendMessage(buf []byte, args []interface{}) []byte {
+	for i, arg := range args {
+		if i > 0 {
+			buf = append(buf, '|')
+		}
+		buf = appendArg(buf, arg)
+	}
+	return buf
+}
+
+/*
+ * 从一批记录中取出第一条记录，用于sink按照记录发送
+ * @param batch：一批记录，文本记录以换行符分隔，二进制记录带4字节大端长度前缀
+ * @param binaryRecords：是否为MsgpackEncoder/ProtoEncoder编码的二进制记录
+ * @return (记录内容，不含换行符以及长度前缀, 剩余的记录)；长度前缀不完整时丢弃剩余内容
+ */
+func nextRecord(batch []byte, binaryRecords bool) ([]byte, []byte) {
+	if !binaryRecords {
+		if i := bytes.IndexByte(batch, '\n'); i >= 0 {
+			return batch[:i], batch[i+1:]
+		}
+		return batch, nil
+	}
+	if len(batch) < binaryHeaderSize {
+		return nil, nil
+	}
+	n := int(binary.BigEndian.Uint32(batch))
+	batch = batch[binaryHeaderSize:]
+	if n > len(batch) {
+		return batch, nil
+	}
+	return batch[:n], batch[n:]
+}
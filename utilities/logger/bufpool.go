@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer 放回pool的buffer容量上限，突发写入撑大的buffer直接丢弃，避免长期占用内存
+const maxPooledBuffer = 1 * MB
+
+// bufferPool 所有日志文件共用的buffer池，flush协程写完之后放回，减少每个flush间隔一次的分配
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+/*
+ * 从pool中获取一个空buffer
+ * @param size：初始容量，pool中的buffer容量不足时扩容
+ */
+func getBuffer(size int) *bytes.Buffer {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	if buffer.Cap() < size {
+		buffer.Grow(size)
+	}
+	return buffer
+}
+
+/*
+ * 将不再使用的buffer放回pool，调用之后不能再访问buffer的内容
+ */
+func putBuffer(buffer *bytes.Buffer) {
+	if buffer == nil || int64(buffer.Cap()) > maxPooledBuffer {
+		return
+	}
+	buffer.Reset()
+	bufferPool.Put(buffer)
+}
+
+// maxPooledScratch 放回pool的编码buffer容量上限
+const maxPooledScratch = 64 * KB
+
+// scratchPool 编码单条记录时使用的临时buffer，编码结果复制之后放回
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		scratch := make([]byte, 0, 512)
+		return &scratch
+	},
+}
+
+// appendEncoder is implemented by the built-in encoders to encode into a pooled buffer
+type appendEncoder interface {
+	appendEntry(buf []byte, entry *Entry) []byte
+}
+
+/*
+ * 从pool中获取一个空的编码buffer
+ */
+func getScratch() *[]byte {
+	scratch := scratchPool.Get().(*[]byte)
+	*scratch = (*scratch)[:0]
+	return scratch
+}
+
+/*
+ * 将编码buffer放回pool，调用之后不能再访问其中的内容
+ */
+func putScratch(scratch *[]byte) {
+	if int64(cap(*scratch)) > maxPooledScratch {
+		return
+	}
+	scratchPool.Put(scratch)
+}
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// benchRecord 基准测试写入buffer的一条记录
+const benchRecord = "2024-05-06 07:08:09.123|rpc/client.go,42|upstream slow|503|1.25|request_id=01HXAMPLE\n"
+
+// 保存基准测试的结果，避免编译器把没有逃逸的buffer分配在栈上
+var (
+	benchBuffer *bytes.Buffer
+	benchString string
+)
+
+/*
+ * 模拟一个flush周期：写满默认大小的buffer，交给写入协程之后回收
+ */
+func BenchmarkFlushBuffer(b *testing.B) {
+	records := int(defaultBufferSize) / len(benchRecord)
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buffer := getBuffer(int(defaultBufferSize))
+			for n := 0; n < records; n++ {
+				buffer.WriteString(benchRecord)
+			}
+			putBuffer(buffer)
+		}
+	})
+	b.Run("alloc", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buffer := bytes.NewBuffer(make([]byte, 0, defaultBufferSize))
+			for n := 0; n < records; n++ {
+				buffer.WriteString(benchRecord)
+			}
+			benchBuffer = buffer
+		}
+	})
+}
+
+/*
+ * LoggerBuffer写入记录并交给写入队列，队列另一端写完之后放回pool，与WriteBufferToQueue/FlushBufferQueue相同
+ */
+func BenchmarkLoggerBufferQueue(b *testing.B) {
+	buffer := newLoggerBuffer(0)
+	queue := make(chan queuedBuffer, 1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for n := 0; n < 16; n++ {
+			buffer.WriteString(benchRecord)
+		}
+		buffer.WriteBuffer(queue)
+		putBuffer((<-queue).buffer)
+	}
+}
+
+/*
+ * 编码单条记录：pool中的编码buffer与每次分配新buffer
+ */
+func BenchmarkScratch(b *testing.B) {
+	entry := &Entry{Time: time.Now(), Level: "warn", Args: benchArgs, Fields: Fields{"request_id": "01HXAMPLE"}}
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			scratch := getScratch()
+			*scratch = TextEncoder{}.appendEntry(*scratch, entry)
+			benchString = string(*scratch)
+			putScratch(scratch)
+		}
+	})
+	b.Run("alloc", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchString = string(TextEncoder{}.Encode(entry))
+		}
+	})
+}
+
+/*
+ * 多个协程同时写日志的完整流程，每条记录的分配次数
+ */
+func BenchmarkLogParallel(b *testing.B) {
+	log, err := NewDiscardLogger()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer log.Close()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			log.Warn("upstream slow", 503, 1.25)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 调用位置的路径截取依据，第一次使用时初始化
+var (
+	callerPrefixOnce sync.Once
+	callerPrefixes   []string // 模块路径以及依赖模块路径，按照长度倒序
+)
+
+// WithCaller records the call site for the given levels
+/*
+ * 设置记录调用位置的级别，默认只有debug以及trace记录调用位置
+ * 不传参数表示所有级别都不记录；Fatal/Panic始终记录调用位置
+ * 获取调用位置需要runtime.Caller，调用频繁的级别开启后会有明显的性能开销
+ * @param levels：记录调用位置的级别，包括自定义级别
+ */
+func WithCaller(levels ...string) Option {
+	return func(logger *Logger) {
+		logger.callers = make(map[string]bool, len(levels))
+		for _, level := range levels {
+			logger.callers[level] = true
+		}
+	}
+}
+
+// AddCallerSkip returns a child logger skipping n more frames when recording the call site
+/*
+ * 返回调用位置多跳过n层调用的子日志对象，用于在日志对象外再封装一层的包，使调用位置指向封装函数的调用方
+ * 与父对象共享日志文件以及配置，可以多次调用累加
+ * @param n：额外跳过的调用层数
+ */
+func (logger *Logger) AddCallerSkip(n int) *Logger {
+	child := *logger
+	child.callerSkip += n
+	return &child
+}
+
+/*
+ * 级别需要记录调用位置时获取调用位置
+ * @param level：级别
+ * @param skip：需要跳过的调用层数，0表示callerAt的调用方，AddCallerSkip的层数会额外跳过
+ * @return 调用位置，不需要记录或者获取失败时为空
+ */
+func (logger *Logger) callerAt(level string, skip int) string {
+	if !logger.captureCaller(level) {
+		return ""
+	}
+	return caller(skip + 1 + logger.callerSkip)
+}
+
+/*
+ * 按照WithCaller的配置判断级别是否需要记录调用位置，未通过WithCaller设置时只有debug以及trace记录
+ */
+func (logger *logCore) captureCaller(level string) bool {
+	if logger.callers == nil {
+		return level == "debug" || level == "trace"
+	}
+	return logger.callers[level]
+}
+
+/*
+ * 获取调用位置，格式为 文件,行号:函数名
+ * @param skip：需要跳过的调用层数，0表示caller的调用方
+ * @return 调用位置，获取失败时为空
+ */
+func caller(skip int) string {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	funcName := ""
+	if funcObj := runtime.FuncForPC(pc); funcObj != nil {
+		funcName = funcObj.Name()
+	}
+	return formatCaller(file, line, funcName)
+}
+
+/*
+ * 格式化调用位置
+ */
+func formatCaller(file string, line int, funcName string) string {
+	return trimCallerPath(file) + "," + strconv.Itoa(line) + ":" + funcName
+}
+
+/*
+ * 截取调用位置的文件路径：
+ * 1. 路径中包含模块路径(主模块或者依赖模块，-trimpath编译时即为开头)时从模块路径开始截取，例如github.com/a/b/pkg/x.go
+ * 2. GOPATH编译时从src/开始截取，与原有格式保持一致
+ * 3. 都不满足时保留最后一级目录以及文件名，例如pkg/x.go
+ */
+func trimCallerPath(file string) string {
+	callerPrefixOnce.Do(loadCallerPrefixes)
+	for _, prefix := range callerPrefixes {
+		if i := strings.Index(file, prefix); i >= 0 && (i == 0 || file[i-1] == '/') {
+			return file[i:]
+		}
+	}
+	if i := strings.Index(file, "src/"); i >= 0 {
+		return file[i:]
+	}
+	if i := strings.LastIndexByte(file, '/'); i > 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			return file[j+1:]
+		}
+	}
+	return file
+}
+
+/*
+ * 从编译信息中读取模块路径，GOPATH编译时没有模块信息
+ */
+func loadCallerPrefixes() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	paths := []string{info.Main.Path}
+	for _, dep := range info.Deps {
+		paths = append(paths, dep.Path)
+	}
+	for _, path := range paths {
+		// 只使用带域名的模块路径，避免"main"之类的短路径误匹配
+		if strings.Contains(path, ".") && strings.Contains(path, "/") {
+			callerPrefixes = append(callerPrefixes, path+"/")
+		}
+	}
+	// 嵌套模块优先匹配更长的路径
+	sort.Slice(callerPrefixes, func(i, j int) bool {
+		return len(callerPrefixes[i]) > len(callerPrefixes[j])
+	})
+}
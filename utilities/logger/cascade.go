@@ -0,0 +1,67 @@
+package logger
+
+// cascadeQueueSize 每个日志文件接收高级别文件转发内容的队列长度
+const cascadeQueueSize = 64
+
+// WithCascade duplicates records into the files of lower-severity levels
+/*
+ * 级联写入：高级别的记录同时写入低级别的日志文件，例如error的记录也会出现在warn、trace以及debug文件中，
+ * 排查问题时只需要tail一个文件即可看到所有更严重的记录
+ * 开启后TextEncoder的每条记录带有级别标记，例如 时间|[ERROR]|...，用于在低级别文件中区分；JSONEncoder本身带有level字段
+ * 转发在高级别文件写入之后进行，低级别文件中的顺序按照写入批次，不保证与本级别的记录严格按时间排列
+ * 转发的内容只写入文件，不会重复输出到sink，也不会触发低级别的告警规则；被SetLevel过滤的级别文件同样会收到转发
+ * 单文件模式以及租户日志文件不做级联
+ * @param levels：接收高级别记录的级别，包括自定义级别，为空表示所有级别
+ */
+func WithCascade(levels ...string) Option {
+	return func(logger *Logger) {
+		logger.cascade = make(map[string]bool, len(levels))
+		for _, level := range levels {
+			logger.cascade[level] = true
+		}
+	}
+}
+
+/*
+ * 重新计算每个级别日志文件需要转发的低级别日志文件，调用方需要持有写锁或者在NewLogger中调用
+ */
+func (logger *logCore) updateCascade() {
+	if logger.cascade == nil || logger.singleFile {
+		return
+	}
+	for level, severity := range logger.levels {
+		source := logger.logMap[level]
+		if source == nil {
+			continue
+		}
+		var targets []*LoggerInfo
+		for target, targetSeverity := range logger.levels {
+			if targetSeverity >= severity || len(logger.cascade) > 0 && !logger.cascade[target] {
+				continue
+			}
+			if loggerInfo := logger.logMap[target]; loggerInfo != nil && loggerInfo != source {
+				targets = append(targets, loggerInfo)
+			}
+		}
+		source.cascadeTo.Store(targets)
+	}
+}
+
+/*
+ * 将写入文件的内容转发给低级别的日志文件，只能在FlushBufferQueue协程中调用
+ * 低级别文件已经关闭时丢弃
+ */
+func (logger *LoggerInfo) forwardCascade(content []byte) {
+	targets, _ := logger.cascadeTo.Load().([]*LoggerInfo)
+	if len(targets) == 0 {
+		return
+	}
+	// content在写入之后会放回buffer池，转发时需要复制
+	forwarded := append([]byte(nil), content...)
+	for _, target := range targets {
+		select {
+		case target.cascadeQueue <- forwarded:
+		case <-target.flusherDone:
+		}
+	}
+}
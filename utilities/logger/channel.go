@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// channelKeyPrefix 通道在logMap中的key前缀，与级别名称以及文件名区分
+const channelKeyPrefix = "channel:"
+
+// Channel is a named log file beside the level files, e.g. an access log
+/*
+ * 命名通道：与级别日志文件并列的独立文件，文件名为 filename-通道名.log，例如saver-access.log，设置WithFileNameTemplate时按照模板命名
+ * 与级别文件使用相同的切分、备份、压缩、Reopen以及Relocate，Logger.Close时一起关闭
+ * 通道中的记录不带级别，不输出到sink，也不受记录级别影响；通过WithFields创建的子对象的字段同样会附加
+ * 文件在第一次写入时创建，创建失败通过错误回调上报，记录丢弃
+ */
+type Channel struct {
+	logger *Logger
+	name   string
+}
+
+// Channel returns the named channel, creating its file on first write
+/*
+ * 获取命名通道，例如：
+ *     access := logger.Channel("access")
+ *     access.Write(r.RemoteAddr, r.Method, r.URL.Path, status)
+ * 同一个名称的通道共用一个文件，可以多次调用
+ * @param name：通道名称，不能为空、不能包含路径分隔符，也不能与已注册的级别同名
+ * @return 通道
+ */
+func (logger *Logger) Channel(name string) *Channel {
+	return &Channel{logger: logger, name: name}
+}
+
+// Name returns the name of the channel
+func (c *Channel) Name() string {
+	return c.name
+}
+
+// Write writes a record made of args to the channel file
+/*
+ * 写入一条记录，参数与级别函数相同，不附加后缀信息
+ * @param args：写入的内容
+ */
+func (c *Channel) Write(args ...interface{}) {
+	loggerInfo := c.info()
+	if loggerInfo == nil {
+		return
+	}
+	if content := c.logger.encode("", "", false, args, nil); content != "" {
+		c.logger.writeChannel(c.name, loggerInfo, content)
+	}
+}
+
+/*
+ * 写入已经格式化好的一行，不经过编码器以及hook，用于访问日志等自带格式的记录
+ */
+func (c *Channel) writeLine(line string) {
+	if loggerInfo := c.info(); loggerInfo != nil {
+		c.logger.writeChannel(c.name, loggerInfo, line+"\n")
+	}
+}
+
+// Writef formats a record and writes it to the channel file
+func (c *Channel) Writef(format string, args ...interface{}) {
+	c.Write(fmt.Sprintf(format, args...))
+}
+
+// Flush writes the buffered records of the channel to disk
+func (c *Channel) Flush() {
+	if loggerInfo := c.lookup(); loggerInfo != nil {
+		loggerInfo.Flush()
+	}
+}
+
+// Rotate forces the channel file to be rotated and backed up immediately
+func (c *Channel) Rotate() {
+	if loggerInfo := c.lookup(); loggerInfo != nil {
+		loggerInfo.Rotate()
+	}
+}
+
+// Close flushes and closes the channel file
+/*
+ * 写入剩余记录并关闭通道文件，之后再次写入时重新创建文件
+ */
+func (c *Channel) Close() {
+	key := channelKeyPrefix + c.name
+	c.logger.Lock()
+	loggerInfo := c.logger.logMap[key]
+	delete(c.logger.logMap, key)
+	c.logger.Unlock()
+	if loggerInfo != nil {
+		loggerInfo.Close()
+	}
+}
+
+/*
+ * 获取已经创建的通道文件，没有创建时返回nil
+ */
+func (c *Channel) lookup() *LoggerInfo {
+	c.logger.RLock()
+	defer c.logger.RUnlock()
+	return c.logger.logMap[channelKeyPrefix+c.name]
+}
+
+/*
+ * 获取通道文件，没有创建时创建，失败时上报并返回nil
+ */
+func (c *Channel) info() *LoggerInfo {
+	if loggerInfo := c.lookup(); loggerInfo != nil {
+		return loggerInfo
+	}
+	logger := c.logger
+	key := channelKeyPrefix + c.name
+	logger.Lock()
+	defer logger.Unlock()
+	if loggerInfo := logger.logMap[key]; loggerInfo != nil {
+		return loggerInfo
+	}
+	if err := logger.checkChannelName(c.name); err != nil {
+		logger.reporter.report("Channel.Create", err)
+		return nil
+	}
+	loggerInfo, err := logger.startFileInfo(logger.levelFile(logger.filename, c.name), "", logger.backupDir)
+	if err != nil {
+		logger.reporter.report("Channel.Create", err)
+		return nil
+	}
+	logger.logMap[key] = loggerInfo
+	return loggerInfo
+}
+
+/*
+ * 检查通道名称，调用方需要持有锁
+ */
+func (logger *logCore) checkChannelName(name string) error {
+	if name == "" {
+		return errors.New("logger: empty channel name")
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("logger: channel name %q contains a path separator", name)
+	}
+	if _, ok := logger.levels[name]; ok {
+		return fmt.Errorf("logger: channel name %q is a level", name)
+	}
+	return nil
+}
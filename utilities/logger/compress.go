@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Compression selects when rotated log files are gzip compressed
+type Compression int
+
+const (
+	// CompressNone keeps rotated files uncompressed (default)
+	CompressNone Compression = iota
+	// CompressOnRotate compresses a file as soon as it is rotated
+	CompressOnRotate
+	// CompressOnBackup compresses files after they are moved to the backup directory
+	CompressOnBackup
+)
+
+// gzipSuffix 压缩文件后缀
+const gzipSuffix = ".gz"
+
+// gzipTmpSuffix 压缩过程中的临时文件后缀，压缩完成后重命名为.gz
+const gzipTmpSuffix = ".gz.tmp"
+
+// WithCompression gzips rotated log files in the background
+/*
+ * 开启切分文件的gzip压缩，压缩在独立协程中进行，不影响日志写入
+ * 压缩先写入.gz.tmp临时文件并fsync，重命名为.gz之后才删除原文件，
+ * 进程在压缩过程中退出时原文件保留，残留的临时文件在下次启动时删除
+ * @param mode：CompressOnRotate切分后立即压缩；CompressOnBackup移动到备份目录之后压缩
+ */
+func WithCompression(mode Compression) Option {
+	return func(logger *Logger) {
+		logger.compression = mode
+	}
+}
+
+/*
+ * 删除上次进程退出时残留的压缩临时文件
+ * @param filename：日志文件名
+ */
+func removePartialArchives(filename string) {
+	matches, _ := filepath.Glob(filename + ".*" + gzipTmpSuffix)
+	for _, match := range matches {
+		os.Remove(match)
+	}
+}
+
+/*
+ * 将文件压缩为 path.gz 并删除原文件
+ * @param path：待压缩文件
+ * @return 失败时返回error，原文件保留
+ */
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := path + gzipTmpSuffix
+	dst, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		if err = zw.Close(); err == nil {
+			err = dst.Sync()
+		}
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = renameFile(tmpPath, path+gzipSuffix)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Remove(path)
+}
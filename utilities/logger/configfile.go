@@ -0,0 +1,548 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrConfigFormat is returned for configuration files in a format that is not supported
+var ErrConfigFormat = errors.New("logger: unsupported config file format, only JSON is supported")
+
+// defaultConfigCheckInterval 配置文件的默认检查间隔
+const defaultConfigCheckInterval = 10 * time.Second
+
+// Duration is a time.Duration written as "10s" in the configuration file
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// FileConfig describes a Logger in a configuration file, see NewFromConfig
+/*
+ * 日志配置文件的内容，零值字段使用默认值，例如：
+ * {
+ *     "filename": "/data/log/saver/saver",
+ *     "backup_dir": "/data/log/saver/backup",
+ *     "level": "warn",
+ *     "modules": {"rpc": "debug"},
+ *     "encoder": "json",
+ *     "rotation": {"schedule": "daily", "max_size": 1073741824},
+ *     "compression": "rotate",
+ *     "sampling": {"every": 100, "interval": "1m"},
+ *     "sinks": [{"type": "syslog", "network": "udp", "address": "127.0.0.1:514", "levels": ["error"]},
+ *               {"type": "console", "encoder": "text"}],
+ *     "reload_interval": "10s"
+ * }
+ * Level、Modules以及Sampling的Every/Rate/Burst可以热更新，其他字段修改之后需要重启
+ */
+type FileConfig struct {
+	Filename       string            `json:"filename"`        // 日志文件名前缀，必填
+	Suffix         string            `json:"suffix"`          // 每条记录追加的后缀信息
+	BackupDir      string            `json:"backup_dir"`      // 备份目录，为空表示不备份
+	Level          string            `json:"level"`           // 最低记录级别，默认all
+	Modules        map[string]string `json:"modules"`         // 模块 -> 级别，参考SetModuleLevel
+	Encoder        string            `json:"encoder"`         // text或者json，默认text
+	SingleFile     bool              `json:"single_file"`     // 所有级别写入同一个文件
+	NameTemplate   string            `json:"name_template"`   // 级别以及通道文件的文件名模板，参考WithFileNameTemplate
+	Rotation       RotationConfig    `json:"rotation"`        // 切分策略
+	Compression    string            `json:"compression"`     // none、rotate或者backup，默认none
+	Retention      *RetentionConfig  `json:"retention"`       // 备份清理，为空表示不清理
+	Sampling       *SamplingFile     `json:"sampling"`        // 抽样以及限流，为空表示不开启
+	Sinks          []SinkConfig      `json:"sinks"`           // 额外的输出
+	Tuning         TuningConfig      `json:"tuning"`          // 调优参数
+	ReloadInterval Duration          `json:"reload_interval"` // 检查配置文件修改的间隔，0表示不热更新
+}
+
+// RotationConfig is the rotation section of FileConfig, see RotationPolicy
+type RotationConfig struct {
+	Schedule string `json:"schedule"`  // hourly、daily或者never，默认hourly
+	MaxSize  int64  `json:"max_size"`  // 按大小切分的阈值(字节)
+	MaxFiles int    `json:"max_files"` // 每个周期内按大小切分保留的文件数
+}
+
+// RetentionConfig is the retention section of FileConfig, see RetentionPolicy
+type RetentionConfig struct {
+	MaxAge     Duration `json:"max_age"`     // 备份保留时间
+	MaxSize    int64    `json:"max_size"`    // 所有备份的总大小上限(字节)
+	Interval   Duration `json:"interval"`    // 检查间隔
+	ArchiveDir string   `json:"archive_dir"` // 归档目录
+}
+
+// SamplingFile is the sampling section of FileConfig, see SamplingConfig
+type SamplingFile struct {
+	Every    int      `json:"every"`
+	Rate     float64  `json:"rate"`
+	Burst    int      `json:"burst"`
+	Interval Duration `json:"interval"`
+	Levels   []string `json:"levels"`
+}
+
+// SinkConfig describes an additional output in FileConfig
+type SinkConfig struct {
+	Type    string   `json:"type"`    // console、syslog或者ship
+	Name    string   `json:"name"`    // sink名称，默认为Type
+	Network string   `json:"network"` // syslog以及ship的网络类型
+	Address string   `json:"address"` // syslog以及ship的地址
+	Levels  []string `json:"levels"`  // 接收的级别，为空表示所有级别
+	Color   string   `json:"color"`   // console的颜色模式：auto、always或者never
+	Format  string   `json:"format"`  // syslog的消息格式：rfc3164或者rfc5424
+	Tag     string   `json:"tag"`     // syslog的程序名
+	Encoder string   `json:"encoder"` // sink单独使用的编码方式：text或者json，为空时与日志文件相同
+}
+
+// TuningConfig is the tuning section of FileConfig, see Config
+type TuningConfig struct {
+	FlushInterval  Duration `json:"flush_interval"`
+	BufferSize     int      `json:"buffer_size"`
+	QueueSize      int      `json:"queue_size"`
+	Overflow       string   `json:"overflow"`  // block、drop-oldest或者drop-newest
+	FileMode       string   `json:"file_mode"` // 八进制，例如"0644"
+	DirMode        string   `json:"dir_mode"`  // 八进制，例如"0755"
+	CreateDirs     bool     `json:"create_dirs"`
+	SyncEveryWrite bool     `json:"sync_every_write"`
+	SlowFlush      Duration `json:"slow_flush"`      // 超过该时间上报ErrSlowFlush，参考WithSlowFlushThreshold
+	MaxRecordSize  int      `json:"max_record_size"` // 单条记录的最大字节数，参考WithMaxRecordSize
+	RecordPolicy   string   `json:"record_policy"`   // truncate、split或者drop
+}
+
+// LoadConfigFile reads and validates a logger configuration file
+/*
+ * 读取日志配置文件，目前只支持JSON(.json)，不认识的字段返回error，避免拼写错误被忽略
+ * @param path：配置文件路径
+ * @return (配置, error)
+ */
+func LoadConfigFile(path string) (*FileConfig, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrConfigFormat, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	config := &FileConfig{}
+	if err = decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("logger: parse %s: %w", path, err)
+	}
+	if config.Filename == "" {
+		return nil, fmt.Errorf("logger: %s: filename is required", path)
+	}
+	return config, nil
+}
+
+// NewFromConfig creates a Logger described by a configuration file
+/*
+ * 按照配置文件创建日志对象，reload_interval大于0时定期检查文件修改并热更新级别以及抽样，Close时停止
+ * 配置文件中创建的sink在Close时关闭
+ * @param path：配置文件路径，参考FileConfig
+ * @param opts：额外的选项，在配置文件的选项之后应用，例如WithHook
+ * @return 成功则返回(*Logger, nil)；否则返回(nil, error)
+ */
+func NewFromConfig(path string, opts ...Option) (*Logger, error) {
+	config, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	configOpts, err := config.Options()
+	if err != nil {
+		return nil, err
+	}
+	closers, err := config.addSinks(&configOpts)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := NewLogger(config.Filename, config.Suffix, config.BackupDir, append(configOpts, opts...)...)
+	if err != nil {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, err
+	}
+	logger.closers = closers
+	if err = logger.applyLevels(config, nil); err != nil {
+		logger.Close()
+		return nil, err
+	}
+	if config.ReloadInterval.Duration > 0 {
+		logger.WatchConfig(path, config.ReloadInterval.Duration)
+	}
+	return logger, nil
+}
+
+// Options converts the configuration into Options, except sinks and levels
+/*
+ * 将配置转换为NewLogger的选项，便于与代码中的选项组合使用
+ * sink需要建立连接，级别可能是自定义级别，两者由NewFromConfig在创建时处理
+ * @return 取值不合法时返回error
+ */
+func (config *FileConfig) Options() ([]Option, error) {
+	var opts []Option
+	switch strings.ToLower(config.Encoder) {
+	case "", "text":
+	case "json":
+		opts = append(opts, WithEncoder(JSONEncoder{}))
+	default:
+		return nil, fmt.Errorf("logger: unknown encoder %q", config.Encoder)
+	}
+	if config.SingleFile {
+		opts = append(opts, WithSingleFile())
+	}
+	if config.NameTemplate != "" {
+		if err := checkNameTemplate(config.NameTemplate); err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithFileNameTemplate(config.NameTemplate))
+	}
+
+	policy := RotationPolicy{MaxSize: config.Rotation.MaxSize, MaxFiles: config.Rotation.MaxFiles}
+	switch strings.ToLower(config.Rotation.Schedule) {
+	case "", "hourly":
+		policy.Schedule = RotateHourly
+	case "daily":
+		policy.Schedule = RotateDaily
+	case "never":
+		policy.Schedule = RotateNever
+	default:
+		return nil, fmt.Errorf("logger: unknown rotation schedule %q", config.Rotation.Schedule)
+	}
+	opts = append(opts, WithRotation(policy))
+
+	switch strings.ToLower(config.Compression) {
+	case "", "none":
+	case "rotate":
+		opts = append(opts, WithCompression(CompressOnRotate))
+	case "backup":
+		opts = append(opts, WithCompression(CompressOnBackup))
+	default:
+		return nil, fmt.Errorf("logger: unknown compression %q", config.Compression)
+	}
+
+	if r := config.Retention; r != nil {
+		opts = append(opts, WithRetention(RetentionPolicy{
+			MaxAge:     r.MaxAge.Duration,
+			MaxSize:    r.MaxSize,
+			Interval:   r.Interval.Duration,
+			ArchiveDir: r.ArchiveDir,
+		}))
+	}
+	if s := config.Sampling; s != nil {
+		opts = append(opts, WithSampling(s.samplingConfig()))
+	}
+
+	tuning := config.Tuning
+	fileMode, err := parseFileMode(tuning.FileMode)
+	if err != nil {
+		return nil, err
+	}
+	dirMode, err := parseFileMode(tuning.DirMode)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, WithConfig(Config{
+		FlushInterval:  tuning.FlushInterval.Duration,
+		BufferSize:     tuning.BufferSize,
+		QueueSize:      tuning.QueueSize,
+		FileMode:       fileMode,
+		DirMode:        dirMode,
+		SyncEveryWrite: tuning.SyncEveryWrite,
+		CreateDirs:     tuning.CreateDirs,
+	}))
+	if tuning.Overflow != "" {
+		overflow, err := ParseOverflowPolicy(tuning.Overflow)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithOverflowPolicy(overflow))
+	}
+	if tuning.SlowFlush.Duration > 0 {
+		opts = append(opts, WithSlowFlushThreshold(tuning.SlowFlush.Duration))
+	}
+	if tuning.MaxRecordSize > 0 {
+		policy := RecordTruncate
+		if tuning.RecordPolicy != "" {
+			if policy, err = ParseRecordPolicy(tuning.RecordPolicy); err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, WithMaxRecordSize(tuning.MaxRecordSize, policy))
+	}
+	return opts, nil
+}
+
+/*
+ * 创建配置文件中的sink，以选项的形式追加到opts
+ * @return (Close时需要关闭的sink, error)，失败时已经创建的sink会被关闭
+ */
+func (config *FileConfig) addSinks(opts *[]Option) ([]io.Closer, error) {
+	var closers []io.Closer
+	fail := func(err error) ([]io.Closer, error) {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, err
+	}
+	for _, sc := range config.Sinks {
+		var w io.Writer
+		switch strings.ToLower(sc.Type) {
+		case "console":
+			mode := ColorAuto
+			switch strings.ToLower(sc.Color) {
+			case "", "auto":
+			case "always":
+				mode = ColorAlways
+			case "never":
+				mode = ColorNever
+			default:
+				return fail(fmt.Errorf("logger: unknown console color %q", sc.Color))
+			}
+			w = NewConsoleWriter(mode)
+		case "syslog":
+			format := SyslogRFC3164
+			switch strings.ToLower(sc.Format) {
+			case "", "rfc3164":
+			case "rfc5424":
+				format = SyslogRFC5424
+			default:
+				return fail(fmt.Errorf("logger: unknown syslog format %q", sc.Format))
+			}
+			writer, err := NewSyslogWriter(SyslogConfig{Network: sc.Network, Address: sc.Address, Format: format, Tag: sc.Tag})
+			if err != nil {
+				return fail(err)
+			}
+			closers = append(closers, writer)
+			w = writer
+		case "ship":
+			writer, err := NewShipWriter(ShipConfig{Network: sc.Network, Address: sc.Address})
+			if err != nil {
+				return fail(err)
+			}
+			closers = append(closers, writer)
+			w = writer
+		default:
+			return fail(fmt.Errorf("logger: unknown sink type %q", sc.Type))
+		}
+		var encoder Encoder
+		switch strings.ToLower(sc.Encoder) {
+		case "":
+		case "text":
+			encoder = TextEncoder{LevelTag: true}
+		case "json":
+			encoder = JSONEncoder{}
+		default:
+			return fail(fmt.Errorf("logger: unknown sink encoder %q", sc.Encoder))
+		}
+		name := sc.Name
+		if name == "" {
+			name = strings.ToLower(sc.Type)
+		}
+		*opts = append(*opts, withSink(name, w, encoder, sc.Levels))
+	}
+	return closers, nil
+}
+
+/*
+ * 在创建时添加sink，与WithConsole相同
+ */
+func withSink(name string, w io.Writer, encoder Encoder, levels []string) Option {
+	return func(logger *Logger) {
+		logger.sinks = append(logger.sinks, newSink(name, w, encoder, levels))
+	}
+}
+
+/*
+ * 按照配置设置记录级别以及模块级别
+ * @param config：新的配置
+ * @param previous：之前的配置，其中有而新配置中没有的模块恢复为全局级别，可以为nil
+ */
+func (logger *Logger) applyLevels(config, previous *FileConfig) error {
+	level := config.Level
+	if level == "" {
+		level = levelAll
+	}
+	if err := logger.SetLevelString(level); err != nil {
+		return fmt.Errorf("logger: level %q: %w", level, err)
+	}
+	for module, level := range config.Modules {
+		if err := logger.SetModuleLevel(module, level); err != nil {
+			return fmt.Errorf("logger: module %s level %q: %w", module, level, err)
+		}
+	}
+	if previous != nil {
+		for module := range previous.Modules {
+			if _, ok := config.Modules[module]; !ok {
+				logger.ResetModuleLevel(module)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ * 转换为SamplingConfig
+ */
+func (s *SamplingFile) samplingConfig() SamplingConfig {
+	return SamplingConfig{Every: s.Every, Rate: s.Rate, Burst: s.Burst, Interval: s.Interval.Duration, Levels: s.Levels}
+}
+
+/*
+ * 解析八进制的文件权限，为空时返回0
+ */
+func parseFileMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("logger: invalid file mode %q", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// configWatcher 配置文件热更新
+type configWatcher struct {
+	path     string
+	interval time.Duration
+	modTime  time.Time
+	size     int64
+	current  *FileConfig // 当前生效的配置
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// WatchConfig reloads levels and sampling from a configuration file when it changes
+/*
+ * 定期检查配置文件的修改时间以及大小，修改之后重新读取并应用：
+ * 1. level以及modules立即生效，配置中删除的模块恢复为全局级别
+ * 2. sampling的every、rate以及burst立即生效，开启或者关闭抽样、修改interval以及levels需要重启
+ * 其他字段的修改不会生效，通过错误回调上报"ConfigWatcher.Restart"；文件读取或者解析失败时保持原配置并上报错误
+ * 已经在监听时先停止原来的监听；Close时停止
+ * @param path：配置文件路径
+ * @param interval：检查间隔，<=0时使用默认的10秒
+ * @return 停止监听的函数
+ */
+func (logger *Logger) WatchConfig(path string, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultConfigCheckInterval
+	}
+	w := &configWatcher{path: path, interval: interval, stop: make(chan struct{}), done: make(chan struct{})}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime, w.size = info.ModTime(), info.Size()
+	}
+	w.current, _ = LoadConfigFile(path)
+
+	logger.Lock()
+	previous := logger.watcher
+	logger.watcher = w
+	logger.Unlock()
+	if previous != nil {
+		previous.close()
+	}
+	go logger.runConfigWatcher(w)
+	return w.close
+}
+
+/*
+ * 检查配置文件修改，直到停止
+ */
+func (logger *Logger) runConfigWatcher(w *configWatcher) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			logger.checkConfig(w)
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+/*
+ * 配置文件修改之后重新读取并应用可以热更新的字段
+ */
+func (logger *Logger) checkConfig(w *configWatcher) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		logger.reporter.report("ConfigWatcher.Stat", err)
+		return
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	config, err := LoadConfigFile(w.path)
+	if err != nil {
+		logger.reporter.report("ConfigWatcher.Load", err)
+		return
+	}
+	if err = logger.applyLevels(config, w.current); err != nil {
+		logger.reporter.report("ConfigWatcher.Level", err)
+	}
+	if logger.sampler != nil && config.Sampling != nil {
+		logger.sampler.reconfigure(config.Sampling.samplingConfig())
+	}
+	if w.current != nil && !reloadable(w.current, config) {
+		logger.reporter.report("ConfigWatcher.Restart", errors.New("logger: "+w.path+" changed fields that only take effect after a restart"))
+	}
+	w.current = config
+}
+
+/*
+ * 判断两份配置是否只有可以热更新的字段不同
+ */
+func reloadable(old, new *FileConfig) bool {
+	strip := func(config *FileConfig) FileConfig {
+		stripped := *config
+		stripped.Level, stripped.Modules = "", nil
+		if config.Sampling != nil {
+			sampling := *config.Sampling
+			sampling.Every, sampling.Rate, sampling.Burst = 0, 0, 0
+			stripped.Sampling = &sampling
+		}
+		return stripped
+	}
+	return reflect.DeepEqual(strip(old), strip(new))
+}
+
+/*
+ * 停止监听并等待退出，重复调用是安全的
+ */
+func (w *configWatcher) close() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
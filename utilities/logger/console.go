@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// ColorMode controls ANSI colors of console output
+type ColorMode int
+
+const (
+	// ColorAuto colors output only when it is a terminal and NO_COLOR is not set
+	ColorAuto ColorMode = iota
+	// ColorAlways always colors output
+	ColorAlways
+	// ColorNever never colors output
+	ColorNever
+)
+
+// ANSI颜色
+const (
+	colorReset   = "\x1b[0m"
+	colorGray    = "\x1b[90m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorRed     = "\x1b[31m"
+	colorBoldRed = "\x1b[1;31m"
+)
+
+// LevelWriter is implemented by sinks that need the level of the records
+/*
+ * sink实现该接口时使用WriteLevel代替Write，用于按照级别选择输出或者格式
+ */
+type LevelWriter interface {
+	WriteLevel(level string, severity int, p []byte) (int, error)
+}
+
+// ConsoleWriter writes records to stdout/stderr, colorized by level
+/*
+ * 终端输出：严重程度低于warn的级别输出到标准输出，warn及以上输出到标准错误
+ * 开启颜色时每条记录按照级别着色：debug灰色、trace绿色、warn黄色、error红色、更高级别红色加粗
+ */
+type ConsoleWriter struct {
+	mu          sync.Mutex
+	stdout      io.Writer
+	stderr      io.Writer
+	colorStdout bool
+	colorStderr bool
+}
+
+// NewConsoleWriter creates a console writer on os.Stdout and os.Stderr
+/*
+ * 创建终端输出
+ * @param mode：颜色模式，ColorAuto时分别检查标准输出以及标准错误是否为终端
+ * @return 终端输出
+ */
+func NewConsoleWriter(mode ColorMode) *ConsoleWriter {
+	return &ConsoleWriter{
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
+		colorStdout: useColor(mode, os.Stdout),
+		colorStderr: useColor(mode, os.Stderr),
+	}
+}
+
+// WithConsole adds a console sink for every level
+/*
+ * 将所有级别的日志输出到终端，用于本地开发，通常与WithoutFiles一起使用
+ * @param mode：颜色模式
+ */
+func WithConsole(mode ColorMode) Option {
+	return func(logger *Logger) {
+		logger.sinks = append(logger.sinks, &sink{name: "console", writer: NewConsoleWriter(mode)})
+	}
+}
+
+// WithConsoleEncoder adds a console sink for every level using its own encoder
+/*
+ * 与WithConsole相同，终端输出使用单独的编码器，例如日志文件为JSON格式时终端输出文本：
+ *     logger.WithEncoder(logger.JSONEncoder{}), logger.WithConsoleEncoder(logger.ColorAuto, logger.TextEncoder{LevelTag: true})
+ * 参考AddEncodedSink
+ * @param mode：颜色模式
+ * @param encoder：终端输出的编码器，为nil时与日志文件相同
+ */
+func WithConsoleEncoder(mode ColorMode, encoder Encoder) Option {
+	return func(logger *Logger) {
+		logger.sinks = append(logger.sinks, newSink("console", NewConsoleWriter(mode), encoder, nil))
+	}
+}
+
+// WithoutFiles disables the log files, records only go to sinks
+/*
+ * 不写日志文件，记录只输出到sink(例如WithConsole)，切分、备份以及压缩都不会进行
+ */
+func WithoutFiles() Option {
+	return func(logger *Logger) {
+		logger.noFiles = true
+	}
+}
+
+// Write writes p to stdout without color
+func (w *ConsoleWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stdout.Write(p)
+}
+
+// WriteLevel implements LevelWriter
+func (w *ConsoleWriter) WriteLevel(level string, severity int, p []byte) (int, error) {
+	out, color := w.stdout, w.colorStdout
+	if severity >= SeverityWarn {
+		out, color = w.stderr, w.colorStderr
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !color {
+		return out.Write(p)
+	}
+	n := len(p)
+	code := levelColor(severity)
+	buf := make([]byte, 0, len(p)+16*bytes.Count(p, []byte{'\n'}))
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i]
+		}
+		buf = append(buf, code...)
+		buf = append(buf, line...)
+		buf = append(buf, colorReset...)
+		if len(line) < len(p) {
+			buf = append(buf, '\n')
+			p = p[len(line)+1:]
+		} else {
+			p = nil
+		}
+	}
+	if _, err := out.Write(buf); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+/*
+ * 根据严重程度选择颜色
+ */
+func levelColor(severity int) string {
+	switch {
+	case severity < SeverityTrace:
+		return colorGray
+	case severity < SeverityWarn:
+		return colorGreen
+	case severity < SeverityError:
+		return colorYellow
+	case severity == SeverityError:
+		return colorRed
+	default:
+		return colorBoldRed
+	}
+}
+
+/*
+ * 判断是否需要输出颜色
+ * @param mode：颜色模式
+ * @param f：输出文件
+ */
+func useColor(mode ColorMode, f *os.File) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"context"
+)
+
+// ContextExtractor extracts correlation fields such as trace_id from a context
+/*
+ * 从context中提取需要附加到日志记录的字段，例如从OpenTelemetry的span中提取trace_id
+ * 会在每次WithContext/WriteCtx时调用，实现需要保证并发安全并且足够快
+ */
+type ContextExtractor func(ctx context.Context) Fields
+
+// contextKey context中保存日志相关数据的key
+type contextKey int
+
+const (
+	loggerContextKey contextKey = iota // 保存*Logger
+	fieldsContextKey                   // 保存Fields
+)
+
+// WithContextExtractor sets the extractor applied by WithContext, FromContext and WriteCtx
+/*
+ * 设置context字段提取函数，提取的字段与ContextWithFields保存的字段合并，重名时以提取函数的结果为准
+ * @param extractor：提取函数
+ */
+func WithContextExtractor(extractor ContextExtractor) Option {
+	return func(logger *Logger) {
+		logger.extractor = extractor
+	}
+}
+
+// ContextWithFields returns a context carrying fields attached to records logged with it
+/*
+ * 在context中保存需要附加到日志记录的字段，一般在请求入口处保存request_id/trace_id，例如：
+ *     ctx = logger.ContextWithFields(ctx, logger.Fields{"request_id": id})
+ *     log.WithContext(ctx).Error("payment failed")
+ * ctx中已经有字段时合并，重名时覆盖原有的值
+ * @param ctx：父context
+ * @param fields：附加字段
+ * @return 新的context
+ */
+func ContextWithFields(ctx context.Context, fields Fields) context.Context {
+	existing, _ := ctx.Value(fieldsContextKey).(Fields)
+	return context.WithValue(ctx, fieldsContextKey, mergeFields(existing, fields))
+}
+
+// NewContext returns a context carrying logger
+/*
+ * 在context中保存日志对象，之后通过FromContext获取
+ * @param ctx：父context
+ * @param logger：日志对象
+ * @return 新的context
+ */
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, logger)
+}
+
+// FromContext returns the logger stored in ctx with the context fields attached
+/*
+ * 获取NewContext保存的日志对象，返回的子日志对象携带ctx中的字段
+ * @param ctx：context
+ * @return 日志对象，ctx中没有保存日志对象时返回nil
+ */
+func FromContext(ctx context.Context) *Logger {
+	logger, _ := ctx.Value(loggerContextKey).(*Logger)
+	if logger == nil {
+		return nil
+	}
+	return logger.WithContext(ctx)
+}
+
+// WithContext returns a child logger attaching the fields of ctx to every record
+/*
+ * 创建携带ctx中字段的子日志对象，字段来自ContextWithFields以及WithContextExtractor设置的提取函数
+ * @param ctx：context
+ * @return 子日志对象，ctx中没有字段时返回logger本身
+ */
+func (logger *Logger) WithContext(ctx context.Context) *Logger {
+	fields := logger.contextFields(ctx)
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.WithFields(fields)
+}
+
+/*
+ * 获取ctx中需要附加到日志记录的字段
+ */
+func (logger *Logger) contextFields(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsContextKey).(Fields)
+	if logger.extractor != nil {
+		fields = mergeFields(fields, logger.extractor(ctx))
+	}
+	return fields
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/maintenance"
+)
+
+// controlShutdownTimeout ServeControl停止时等待请求完成的时间
+const controlShutdownTimeout = 5 * time.Second
+
+// ControlHandler is an extra handler mounted on the control socket, see ServeControl
+type ControlHandler struct {
+	Pattern string       // http.ServeMux的路径
+	Handler http.Handler // 处理请求的handler
+}
+
+// ServeControl serves the admin handlers on a unix control socket
+/*
+ * 在unix socket上提供管理接口，不需要额外开放端口，只有能访问socket文件的用户可以调用：
+ *     /log/rotate    参考RotateHandler
+ *     /log/level     参考LevelHandler
+ *     /log/debug     参考DebugHandler
+ *     /maintenance   参考maintenance.Handler
+ * 例如: curl --unix-socket /run/app/log.sock -X PUT 'http://localhost/log/debug?module=rpc&caller=on&ttl=10m'
+ *      curl --unix-socket /run/app/log.sock -X PUT 'http://localhost/maintenance?enabled=true&reason=backup'
+ * socket文件权限为0600；文件已经存在并且是socket时先删除(上一次进程异常退出遗留)，不是socket时返回错误
+ * @param path：socket文件路径
+ * @param handlers：额外挂载的管理接口，例如worker pool的容量调整，路径与上面的接口相同时覆盖
+ * @return (停止函数, error)，停止函数关闭监听并删除socket文件；监听失败返回error
+ */
+func (logger *Logger) ServeControl(path string, handlers ...ControlHandler) (stop func() error, err error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, &os.PathError{Op: "listen", Path: path, Err: os.ErrExist}
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	routes := map[string]http.Handler{
+		"/log/rotate":  logger.RotateHandler(),
+		"/log/level":   logger.LevelHandler(),
+		"/log/debug":   logger.DebugHandler(),
+		"/maintenance": maintenance.Handler(),
+	}
+	for _, h := range handlers {
+		routes[h.Pattern] = h.Handler
+	}
+	mux := http.NewServeMux()
+	for pattern, handler := range routes {
+		mux.Handle(pattern, handler)
+	}
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: controlShutdownTimeout}
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.reporter.report("control", err)
+		}
+	}()
+
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), controlShutdownTimeout)
+		defer cancel()
+		err := server.Shutdown(ctx)
+		os.Remove(path)
+		return err
+	}, nil
+}
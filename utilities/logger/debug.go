@@ -0,0 +1,352 @@
+package logger
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxHexDump HexDump最多输出的字节数，超过的部分截断
+const maxHexDump = 4096
+
+// ErrUnknownToggle is returned when a debug switch value is not on, off or default
+var ErrUnknownToggle = errors.New("logger: unknown toggle value")
+
+// Toggle is the state of a runtime debug switch
+type Toggle int8
+
+// 调试开关的取值
+const (
+	ToggleDefault Toggle = iota // 使用启动时的配置
+	ToggleOn                    // 开启
+	ToggleOff                   // 关闭
+)
+
+// String returns default, on or off
+func (t Toggle) String() string {
+	switch t {
+	case ToggleOn:
+		return "on"
+	case ToggleOff:
+		return "off"
+	default:
+		return "default"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (t Toggle) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (t *Toggle) UnmarshalText(text []byte) error {
+	toggle, err := ParseToggle(string(text))
+	if err != nil {
+		return err
+	}
+	*t = toggle
+	return nil
+}
+
+// ParseToggle parses on/off/default, also accepting true/false/1/0
+/*
+ * 解析调试开关的取值，不区分大小写
+ * @param value：on/true/1表示开启，off/false/0表示关闭，default或者空表示使用启动时的配置
+ * @return 其他取值返回ErrUnknownToggle
+ */
+func ParseToggle(value string) (Toggle, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "default":
+		return ToggleDefault, nil
+	case "on", "true", "1":
+		return ToggleOn, nil
+	case "off", "false", "0":
+		return ToggleOff, nil
+	}
+	return ToggleDefault, ErrUnknownToggle
+}
+
+// DebugToggle holds the runtime debug switches of a module
+type DebugToggle struct {
+	Caller  Toggle    `json:"caller"`            // on表示所有级别记录调用位置，off表示都不记录(Fatal/Panic除外)
+	Stack   Toggle    `json:"stack"`             // on表示所有级别附带调用栈，off表示都不附带
+	HexDump Toggle    `json:"hexdump"`           // on表示HexDump输出内容，默认不输出
+	Level   string    `json:"level,omitempty"`   // 模块的记录级别，为空表示不修改，取值与SetModuleLevel相同
+	Expires time.Time `json:"expires,omitempty"` // 自动恢复的时间，为零表示一直生效
+}
+
+// debugEntry 模块的调试开关以及开启之前的记录级别
+type debugEntry struct {
+	toggle    DebugToggle
+	prevLevel int         // 设置Level之前的严重程度
+	hadLevel  bool        // 设置Level之前模块是否单独设置了级别
+	timer     *time.Timer // 到期之后自动恢复，nil表示一直生效
+}
+
+// debugToggles 运行时调试开关，写入时加锁，读取时通过current无锁访问
+type debugToggles struct {
+	mu      sync.Mutex
+	entries map[string]*debugEntry
+	current atomic.Value // map[string]DebugToggle，每次修改时替换
+}
+
+// SetDebugToggle switches expensive logging features of a module at run time
+/*
+ * 运行时按照模块开启或者关闭调用位置、调用栈、hex dump以及debug级别，只在排查问题期间承担这部分开销，例如：
+ *     logger.SetDebugToggle("rpc", DebugToggle{Caller: ToggleOn, Stack: ToggleOn, Level: "debug"}, 10*time.Minute)
+ * 与SetModuleLevel相同，模块没有设置时使用上级模块的开关，例如rpc.client使用rpc的开关；模块名称为空表示所有日志对象
+ * 再次设置同一个模块时替换原来的开关；设置了Level时，恢复之后模块的记录级别还原为第一次设置之前的级别
+ * 也可以通过DebugHandler或者ServeControl在运行时修改
+ * @param module：模块名称，与Named的名称相同
+ * @param toggle：调试开关，Expires由ttl计算，传入的值不生效
+ * @param ttl：生效时间，到期之后自动恢复，<=0表示一直生效直到ResetDebugToggle
+ * @return 级别不存在返回ErrUnknownLevel
+ */
+func (logger *Logger) SetDebugToggle(module string, toggle DebugToggle, ttl time.Duration) error {
+	toggles := &logger.toggles
+	toggles.mu.Lock()
+	defer toggles.mu.Unlock()
+
+	entry := toggles.entries[module]
+	if entry == nil {
+		entry = &debugEntry{}
+	} else if entry.timer != nil {
+		entry.timer.Stop()
+		entry.timer = nil
+	}
+	if err := logger.applyDebugLevel(module, entry, toggle.Level); err != nil {
+		return err
+	}
+	toggle.Expires = time.Time{}
+	if ttl > 0 {
+		toggle.Expires = time.Now().Add(ttl)
+		expires := toggle.Expires
+		entry.timer = time.AfterFunc(ttl, func() {
+			logger.expireDebugToggle(module, expires)
+		})
+	}
+	entry.toggle = toggle
+	if toggles.entries == nil {
+		toggles.entries = make(map[string]*debugEntry)
+	}
+	toggles.entries[module] = entry
+	toggles.publish()
+	return nil
+}
+
+// ResetDebugToggle removes the switches set by SetDebugToggle for a module
+/*
+ * 恢复模块的调试开关，设置过Level时还原模块的记录级别
+ * @param module：模块名称，为空表示所有日志对象的开关
+ */
+func (logger *Logger) ResetDebugToggle(module string) {
+	toggles := &logger.toggles
+	toggles.mu.Lock()
+	defer toggles.mu.Unlock()
+	logger.removeDebugToggle(module)
+}
+
+// DebugToggles returns the switches set by SetDebugToggle, keyed by module name
+func (logger *Logger) DebugToggles() map[string]DebugToggle {
+	current, _ := logger.toggles.current.Load().(map[string]DebugToggle)
+	result := make(map[string]DebugToggle, len(current))
+	for module, toggle := range current {
+		result[module] = toggle
+	}
+	return result
+}
+
+// HexDumpEnabled reports whether HexDump writes anything for this logger
+/*
+ * 判断当前模块是否开启了hex dump，用于在准备较大的数据之前提前判断
+ */
+func (logger *Logger) HexDumpEnabled() bool {
+	return logger.debugToggle(func(toggle DebugToggle) Toggle { return toggle.HexDump }) == ToggleOn
+}
+
+// HexDump writes a hex dump of data when hex dumps are switched on for the module
+/*
+ * 写入数据的hex dump，格式与encoding/hex.Dump相同，最多输出4096字节
+ * 只有通过SetDebugToggle为当前模块(或者上级模块)开启HexDump时才会写入，平时只有一次原子读取的开销
+ * @param level：级别名称
+ * @param msg：说明内容
+ * @param data：输出的数据
+ * @return 级别不存在返回ErrUnknownLevel；日志文件创建失败返回error
+ */
+func (logger *Logger) HexDump(level, msg string, data []byte) error {
+	if !logger.HexDumpEnabled() {
+		return nil
+	}
+	loggerInfo, enabled, err := logger.levelInfo(level)
+	if err != nil || !enabled {
+		return err
+	}
+	size := "len=" + strconv.Itoa(len(data))
+	if len(data) > maxHexDump {
+		data = data[:maxHexDump]
+		size += " truncated"
+	}
+	args := []interface{}{msg, size, strings.TrimSuffix(hex.Dump(data), "\n")}
+	if loggerInfo = logger.route(level, loggerInfo, nil); loggerInfo == nil {
+		return nil
+	}
+	logger.write(loggerInfo, level, logger.encode(level, logger.callerAt(level, 1), true, args, nil))
+	return nil
+}
+
+/*
+ * 判断级别是否需要记录调用位置，运行时开关优先于WithCaller的配置
+ */
+func (logger *Logger) captureCaller(level string) bool {
+	switch logger.debugToggle(func(toggle DebugToggle) Toggle { return toggle.Caller }) {
+	case ToggleOn:
+		return true
+	case ToggleOff:
+		return false
+	}
+	return logger.logCore.captureCaller(level)
+}
+
+/*
+ * 按照运行时开关判断级别是否需要记录调用栈
+ * @return (调用栈层数, 是否记录)
+ */
+func (logger *Logger) stackDepth(level string) (int, bool) {
+	switch logger.debugToggle(func(toggle DebugToggle) Toggle { return toggle.Stack }) {
+	case ToggleOn:
+		if logger.stack != nil {
+			return logger.stack.depth, true
+		}
+		return defaultStackDepth, true
+	case ToggleOff:
+		return 0, false
+	}
+	if logger.stack == nil || !logger.stack.levels[level] {
+		return 0, false
+	}
+	return logger.stack.depth, true
+}
+
+/*
+ * 从当前模块开始逐级向上查找第一个不是默认值的开关，最后查找所有日志对象的开关
+ * @param pick：选择开关中的一项
+ */
+func (logger *Logger) debugToggle(pick func(DebugToggle) Toggle) Toggle {
+	current, _ := logger.toggles.current.Load().(map[string]DebugToggle)
+	if len(current) == 0 {
+		return ToggleDefault
+	}
+	for name := logger.name; name != ""; {
+		if toggle, ok := current[name]; ok {
+			if value := pick(toggle); value != ToggleDefault {
+				return value
+			}
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return pick(current[""])
+}
+
+/*
+ * 设置模块的记录级别，第一次设置时保存原来的级别；level为空时还原，需要持有toggles.mu
+ */
+func (logger *Logger) applyDebugLevel(module string, entry *debugEntry, level string) error {
+	logger.Lock()
+	defer logger.Unlock()
+	if level == "" {
+		if entry.toggle.Level != "" {
+			logger.restoreDebugLevel(module, entry)
+		}
+		return nil
+	}
+	severity, ok := logger.levels[level]
+	if !ok {
+		if level != levelAll {
+			return ErrUnknownLevel
+		}
+		severity = 0
+	}
+	if entry.toggle.Level == "" {
+		if module == "" {
+			entry.prevLevel, entry.hadLevel = logger.minSeverity, true
+		} else {
+			entry.prevLevel, entry.hadLevel = logger.modules[module]
+		}
+	}
+	if module == "" {
+		logger.minSeverity = severity
+		return nil
+	}
+	if logger.modules == nil {
+		logger.modules = make(map[string]int)
+	}
+	logger.modules[module] = severity
+	return nil
+}
+
+/*
+ * 还原模块设置Level之前的记录级别，需要持有写锁
+ */
+func (logger *Logger) restoreDebugLevel(module string, entry *debugEntry) {
+	switch {
+	case module == "":
+		logger.minSeverity = entry.prevLevel
+	case entry.hadLevel:
+		logger.modules[module] = entry.prevLevel
+	default:
+		delete(logger.modules, module)
+	}
+}
+
+/*
+ * 删除模块的开关并还原记录级别，需要持有toggles.mu
+ */
+func (logger *Logger) removeDebugToggle(module string) {
+	toggles := &logger.toggles
+	entry := toggles.entries[module]
+	if entry == nil {
+		return
+	}
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	if entry.toggle.Level != "" {
+		logger.Lock()
+		logger.restoreDebugLevel(module, entry)
+		logger.Unlock()
+	}
+	delete(toggles.entries, module)
+	toggles.publish()
+}
+
+/*
+ * 开关到期时恢复，期间重新设置过的开关不受影响
+ */
+func (logger *Logger) expireDebugToggle(module string, expires time.Time) {
+	toggles := &logger.toggles
+	toggles.mu.Lock()
+	defer toggles.mu.Unlock()
+	if entry := toggles.entries[module]; entry != nil && entry.toggle.Expires.Equal(expires) {
+		logger.removeDebugToggle(module)
+	}
+}
+
+/*
+ * 发布开关的快照，需要持有mu
+ */
+func (toggles *debugToggles) publish() {
+	current := make(map[string]DebugToggle, len(toggles.entries))
+	for module, entry := range toggles.entries {
+		current[module] = entry.toggle
+	}
+	toggles.current.Store(current)
+}
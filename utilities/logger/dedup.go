@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// RepeatedField is the field carrying the repeat count of a collapsed record
+const RepeatedField = "repeated"
+
+// deduper 连续重复消息的合并状态
+type deduper struct {
+	sync.Mutex
+	window time.Duration
+	states map[string]*dedupState // 每个级别最近一条消息
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// dedupState 一个级别最近一条消息以及重复次数
+type dedupState struct {
+	loggerInfo *LoggerInfo
+	key        []byte        // 用于判断相同消息的内容
+	args       []interface{} // 第一条记录的参数，用于输出合并记录
+	fields     Fields        // 第一条记录的附加字段
+	start      time.Time     // 第一条记录的时间
+	repeated   int           // 之后被合并的次数
+}
+
+// repeatedRecord 待输出的合并记录，在锁之外写入，避免写入(例如WriteSync的fsync)阻塞其他协程的日志调用
+type repeatedRecord struct {
+	loggerInfo *LoggerInfo
+	level      string
+	args       []interface{}
+	fields     Fields
+}
+
+// WithDedup collapses consecutive identical messages within window into one record
+/*
+ * 开启连续重复消息合并，用于减少重试风暴等场景下的噪音，与WithSampling可以同时使用(先合并再抽样)
+ * 同一级别的相同消息第一次出现时正常写入，之后window之内连续出现的相同消息不再写入，只累计次数；
+ * 出现不同的消息或者超过window时输出一条合并记录，内容与第一条相同，附加字段repeated为合并的次数
+ * 相同消息的判断依据为(级别, 消息内容)：Debug/Trace/Warn/Error/Log为全部参数，*f函数为格式化之后的内容，
+ * WriteCtx以及slog为消息内容，不比较附加字段；Fatal/Panic以及通过Write写入的自定义文件不受影响
+ * @param window：合并的时间窗口，<=0时不开启
+ */
+func WithDedup(window time.Duration) Option {
+	return func(logger *Logger) {
+		if window <= 0 {
+			logger.deduper = nil
+			return
+		}
+		logger.deduper = &deduper{
+			window: window,
+			states: make(map[string]*dedupState),
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+	}
+}
+
+/*
+ * 判断记录是否需要写入，与上一条相同时只累计次数
+ * @param loggerInfo：记录写入的LoggerInfo
+ * @param level：级别
+ * @param args：日志内容
+ * @param fields：附加字段，可以为nil
+ * @return 需要写入时返回true
+ */
+func (logger *Logger) dedupe(loggerInfo *LoggerInfo, level string, args []interface{}, fields Fields) bool {
+	d := logger.deduper
+	if d == nil {
+		return true
+	}
+	key := argsKey(args)
+	now := time.Now()
+	var pending *repeatedRecord
+	d.Lock()
+	if state := d.states[level]; state != nil {
+		if state.loggerInfo == loggerInfo && bytes.Equal(state.key, key) && now.Sub(state.start) < d.window {
+			state.repeated++
+			d.Unlock()
+			return false
+		}
+		pending = state.takeRepeated(level)
+	}
+	d.states[level] = &dedupState{
+		loggerInfo: loggerInfo,
+		key:        key,
+		args:       append([]interface{}(nil), args...),
+		fields:     fields,
+		start:      now,
+	}
+	d.Unlock()
+	logger.writeRepeated(pending)
+	return true
+}
+
+/*
+ * 取出合并记录并清零次数，调用方需要持有锁
+ * @return 没有被合并的记录时返回nil
+ */
+func (state *dedupState) takeRepeated(level string) *repeatedRecord {
+	if state.repeated == 0 {
+		return nil
+	}
+	fields := make(Fields, len(state.fields)+1)
+	for key, value := range state.fields {
+		fields[key] = value
+	}
+	fields[RepeatedField] = state.repeated
+	state.repeated = 0
+	return &repeatedRecord{loggerInfo: state.loggerInfo, level: level, args: state.args, fields: fields}
+}
+
+/*
+ * 输出合并记录，调用方不能持有锁
+ * @param record：合并记录，为nil时不输出
+ */
+func (logger *Logger) writeRepeated(record *repeatedRecord) {
+	if record == nil {
+		return
+	}
+	logger.write(record.loggerInfo, record.level, logger.encode(record.level, "", true, record.args, record.fields))
+}
+
+/*
+ * 输出超过时间窗口的合并记录
+ * @param all：为true时输出所有合并记录，用于Close
+ */
+func (logger *Logger) flushRepeated(all bool) {
+	d := logger.deduper
+	now := time.Now()
+	var pending []*repeatedRecord
+	d.Lock()
+	for level, state := range d.states {
+		if all || now.Sub(state.start) >= d.window {
+			if record := state.takeRepeated(level); record != nil {
+				pending = append(pending, record)
+			}
+			delete(d.states, level)
+		}
+	}
+	d.Unlock()
+	for _, record := range pending {
+		logger.writeRepeated(record)
+	}
+}
+
+/*
+ * 合并协程，定期输出超过时间窗口的合并记录，避免重复的消息停止之后合并记录迟迟不输出
+ */
+func (logger *Logger) runDedup() {
+	d := logger.deduper
+	defer close(d.done)
+	ticker := time.NewTicker(d.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			logger.flushRepeated(false)
+		case <-d.stop:
+			logger.flushRepeated(true)
+			return
+		}
+	}
+}
+
+/*
+ * 停止合并协程并输出剩余的合并记录，Close时在关闭日志文件之前调用
+ */
+func (d *deduper) close() {
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+	<-d.done
+}
@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// diagChannel 诊断信息写入的通道
+const diagChannel = "diag"
+
+// DumpDiagnostics writes a goroutine dump, memory stats and Stats to the diag channel
+/*
+ * 将所有协程的调用栈、内存统计以及Stats写入diag通道(文件名为 filename-diag.log)，用于排查线上进程卡住等问题，不需要挂调试器
+ * 整个诊断信息作为一条记录同步写入并fsync，返回时已经落盘；每次调用都会短暂暂停所有协程(runtime.Stack)
+ * @param reason：触发原因，写入记录的第一行，例如signal=user defined signal 1
+ * @return 通道文件创建失败返回ErrWALUnavailable；写入失败返回对应的error
+ */
+func (logger *Logger) DumpDiagnostics(reason string) error {
+	loggerInfo := logger.Channel(diagChannel).info()
+	if loggerInfo == nil {
+		return ErrWALUnavailable
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	stats, err := json.MarshalIndent(logger.Stats(), "", "  ")
+	if err != nil {
+		stats = []byte(err.Error())
+	}
+
+	var b strings.Builder
+	b.WriteString(logger.encode("", "", false, []interface{}{"diagnostics", reason, "goroutines=" + fmt.Sprint(runtime.NumGoroutine())}, nil))
+	b.WriteString("=== goroutines ===\n")
+	b.Write(goroutineDump())
+	b.WriteString("\n=== memstats ===\n")
+	for _, item := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"alloc", memStats.Alloc},
+		{"total_alloc", memStats.TotalAlloc},
+		{"sys", memStats.Sys},
+		{"heap_alloc", memStats.HeapAlloc},
+		{"heap_inuse", memStats.HeapInuse},
+		{"heap_idle", memStats.HeapIdle},
+		{"heap_released", memStats.HeapReleased},
+		{"heap_objects", memStats.HeapObjects},
+		{"stack_inuse", memStats.StackInuse},
+		{"mallocs", memStats.Mallocs},
+		{"frees", memStats.Frees},
+		{"num_gc", memStats.NumGC},
+		{"next_gc", memStats.NextGC},
+		{"pause_total", time.Duration(memStats.PauseTotalNs)},
+		{"last_gc", time.Unix(0, int64(memStats.LastGC)).Format(jsonTimeFormat)},
+		{"gc_cpu_fraction", memStats.GCCPUFraction},
+	} {
+		fmt.Fprintf(&b, "%s=%v\n", item.name, item.value)
+	}
+	b.WriteString("=== logger stats ===\n")
+	b.Write(stats)
+	b.WriteString("\n=== end ===\n")
+	return loggerInfo.WriteSync(b.String())
+}
+
+// DiagnosticsOnSignal dumps diagnostics to the diag channel whenever one of sigs is received
+/*
+ * 监听信号，收到信号时调用DumpDiagnostics，失败时通过错误处理函数上报，例如 kill -USR1 <pid>
+ * @param sigs：监听的信号，为空时监听SIGUSR1；windows下没有SIGUSR1，需要指定信号，否则不监听
+ * @return 停止监听的函数，Close之前调用
+ */
+func (logger *Logger) DiagnosticsOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = defaultDiagSignals
+	}
+	if len(sigs) == 0 {
+		// signal.Notify不指定信号时会接收所有信号
+		return func() {}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				if err := logger.DumpDiagnostics("signal=" + sig.String()); err != nil {
+					logger.reporter.report("DumpDiagnostics", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+/*
+ * 获取所有协程的调用栈，buffer不够时翻倍重试
+ */
+func goroutineDump() []byte {
+	buf := make([]byte, 64*KB)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
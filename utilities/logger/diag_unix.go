@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultDiagSignals DiagnosticsOnSignal默认监听的信号
+var defaultDiagSignals = []os.Signal{syscall.SIGUSR1}
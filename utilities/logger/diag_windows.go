@@ -0,0 +1,8 @@
+package logger
+
+import (
+	"os"
+)
+
+// defaultDiagSignals windows下没有SIGUSR1，DiagnosticsOnSignal需要指定信号
+var defaultDiagSignals []os.Signal
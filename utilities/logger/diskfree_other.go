@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package logger
+
+import (
+	"errors"
+	"syscall"
+)
+
+/*
+ * 其他系统的Statfs_t字段各不相同，可用空间未知，磁盘空间检查不生效
+ */
+func diskFree(dir string) (int64, error) {
+	return 0, errDiskFreeUnknown
+}
+
+/*
+ * 判断写入失败是否由于磁盘空间不足
+ */
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
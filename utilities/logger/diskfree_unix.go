@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package logger
+
+import (
+	"errors"
+	"syscall"
+)
+
+/*
+ * 获取目录所在分区非特权用户可用的空间
+ * @param dir：目录
+ * @return (可用字节数, error)
+ */
+func diskFree(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+/*
+ * 判断写入失败是否由于磁盘空间不足
+ */
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
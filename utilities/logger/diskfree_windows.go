@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	errorHandleDiskFull syscall.Errno = 39
+	errorDiskFull       syscall.Errno = 112
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+/*
+ * 获取目录所在分区当前用户可用的空间
+ * @param dir：目录
+ * @return (可用字节数, error)
+ */
+func diskFree(dir string) (int64, error) {
+	path, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	if r, _, err := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&free)), 0, 0); r == 0 {
+		return 0, err
+	}
+	return int64(free), nil
+}
+
+/*
+ * 判断写入失败是否由于磁盘空间不足
+ */
+func isNoSpace(err error) bool {
+	var errno syscall.Errno
+	return errors.As(err, &errno) && (errno == errorDiskFull || errno == errorHandleDiskFull)
+}
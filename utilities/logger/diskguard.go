@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultDiskCheckInterval 磁盘空间的默认检查间隔
+const defaultDiskCheckInterval = 10 * time.Second
+
+// ErrDiskLow is reported through the error handler when the log volume runs low on space
+var ErrDiskLow = errors.New("logger: log volume is low on disk space")
+
+// errDiskFreeUnknown 系统不支持获取可用空间，磁盘空间检查不生效
+var errDiskFreeUnknown = errors.New("logger: free disk space unknown on this platform, disk guard disabled")
+
+// DiskGuardConfig configures the free space check of the log volume
+type DiskGuardConfig struct {
+	MinFree  int64         // 可用空间下限(字节)，低于该值时开始清理以及降级
+	Interval time.Duration // 检查间隔，默认10秒；写入返回空间不足时立即检查
+}
+
+// diskGuard 日志分区的可用空间检查
+type diskGuard struct {
+	config   DiskGuardConfig
+	low      int32 // 是否处于空间不足的降级状态，原子操作访问
+	saved    int   // 降级之前的最低严重程度，只在检查协程中访问
+	wakeup   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// WithDiskGuard watches free space on the log volume and degrades when it runs low
+/*
+ * 定期检查日志文件所在分区的可用空间，低于MinFree时：
+ * 1. 不考虑保留策略，从最旧的日期开始清理备份目录(当天的目录除外)，配置了WithRetention时遵循其ArchiveDir
+ * 2. 清理之后仍然不足时只记录error及以上级别的日志
+ * 3. 通过错误回调上报ErrDiskLow
+ * 可用空间恢复到MinFree的1.25倍以上时恢复原来的记录级别，降级期间通过SetLevel等修改过级别时不再恢复
+ * 只支持linux、darwin、freebsd以及windows，其他系统无法获取可用空间，上报一次错误之后不再检查
+ * @param config：检查配置，MinFree<=0时不开启
+ */
+func WithDiskGuard(config DiskGuardConfig) Option {
+	return func(logger *Logger) {
+		if config.MinFree <= 0 {
+			return
+		}
+		if config.Interval <= 0 {
+			config.Interval = defaultDiskCheckInterval
+		}
+		logger.diskGuard = &diskGuard{
+			config: config,
+			wakeup: make(chan struct{}, 1),
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+	}
+}
+
+// DiskLow reports whether the logger is degraded because the log volume is low on space
+func (logger *Logger) DiskLow() bool {
+	return logger.diskGuard != nil && atomic.LoadInt32(&logger.diskGuard.low) == 1
+}
+
+/*
+ * 定期检查可用空间，直到close
+ */
+func (logger *logCore) runDiskGuard() {
+	g := logger.diskGuard
+	defer close(g.done)
+	ticker := time.NewTicker(g.config.Interval)
+	defer ticker.Stop()
+	if !logger.checkDisk() {
+		return
+	}
+	for {
+		select {
+		case <-ticker.C:
+			logger.checkDisk()
+		case <-g.wakeup:
+			logger.checkDisk()
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+/*
+ * 检查一次可用空间，根据结果清理备份、降级或者恢复
+ * @return 系统不支持获取可用空间时返回false，不再检查
+ */
+func (logger *logCore) checkDisk() bool {
+	g := logger.diskGuard
+	logger.RLock()
+	dir, backupDir := filepath.Dir(logger.filename), logger.backupDir
+	logger.RUnlock()
+
+	free, err := diskFree(dir)
+	if err == errDiskFreeUnknown {
+		logger.reporter.report("DiskGuard.Check", err)
+		return false
+	}
+	if err != nil {
+		logger.reporter.report("DiskGuard.Check", err)
+		return true
+	}
+	if atomic.LoadInt32(&g.low) == 1 {
+		if free >= g.config.MinFree+g.config.MinFree/4 {
+			logger.restoreLevel()
+		}
+		return true
+	}
+	if free >= g.config.MinFree {
+		return true
+	}
+
+	freed := logger.purgeBackups(backupDir, func() bool {
+		free, err = diskFree(dir)
+		return err == nil && free >= g.config.MinFree
+	})
+	if err == nil && free >= g.config.MinFree {
+		logger.reporter.report("DiskGuard.Cleanup", fmt.Errorf("%w: %s, freed %d bytes of backups", ErrDiskLow, dir, freed))
+		return true
+	}
+	logger.degradeLevel()
+	logger.reporter.report("DiskGuard.Degrade", fmt.Errorf("%w: %s has %d bytes free, only error logs are written", ErrDiskLow, dir, free))
+	return true
+}
+
+/*
+ * 紧急清理备份目录，配置了WithRetention时使用其清理策略
+ * @return 清理的字节数
+ */
+func (logger *logCore) purgeBackups(backupDir string, enough func() bool) int64 {
+	if backupDir == "" {
+		return 0
+	}
+	manager := logger.retention
+	if manager == nil {
+		manager = NewRetentionManager(backupDir, RetentionPolicy{})
+		manager.report = logger.reporter.report
+	}
+	freed, err := manager.purge(enough)
+	if err != nil {
+		logger.reporter.report("DiskGuard.Cleanup", err)
+	}
+	return freed
+}
+
+/*
+ * 降级为只记录error及以上级别
+ */
+func (logger *logCore) degradeLevel() {
+	g := logger.diskGuard
+	logger.Lock()
+	defer logger.Unlock()
+	g.saved = logger.minSeverity
+	if logger.minSeverity < SeverityError {
+		logger.minSeverity = SeverityError
+	}
+	atomic.StoreInt32(&g.low, 1)
+}
+
+/*
+ * 恢复降级之前的记录级别，降级期间级别被修改过时保持不变
+ */
+func (logger *logCore) restoreLevel() {
+	g := logger.diskGuard
+	logger.Lock()
+	defer logger.Unlock()
+	if g.saved < SeverityError && logger.minSeverity == SeverityError {
+		logger.minSeverity = g.saved
+	}
+	atomic.StoreInt32(&g.low, 0)
+}
+
+/*
+ * 写入返回空间不足时通知立即检查，不阻塞写入协程
+ */
+func (g *diskGuard) wake() {
+	select {
+	case g.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+/*
+ * 停止检查协程并等待退出
+ */
+func (g *diskGuard) close() {
+	g.stopOnce.Do(func() {
+		close(g.stop)
+	})
+	<-g.done
+}
@@ -0,0 +1,401 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonTimeFormat JSON日志中的时间格式(ISO8601，带时区)
+const jsonTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Entry is a log record handed to the encoder
+type Entry struct {
+	Time       time.Time
+	TimeLayout string        // 时间格式，为空时使用编码器的默认格式，参考WithTimeFormat
+	Level      string        // 日志级别，通过Write写入的自定义文件为空
+	Name       string        // 模块名称，参考Named
+	Caller     string        // 调用位置，未记录时为空
+	Args       []interface{} // 日志内容
+	Fields     Fields        // 附加字段
+	Suffix     string        // 后缀信息
+	WithSuffix bool          // 是否输出后缀信息
+	Stack      []StackFrame  // 调用栈，参考WithStackTrace以及Recover，未记录时为nil
+}
+
+// Encoder serializes an entry into bytes written to the log file
+/*
+ * 日志编码接口，返回的内容需要以换行符结尾；MsgpackEncoder/ProtoEncoder等二进制编码器例外，只能用于二进制sink
+ * 编码器会被多个协程同时调用，实现需要保证并发安全
+ */
+type Encoder interface {
+	Encode(entry *Entry) []byte
+}
+
+// TextEncoder encodes entries as pipe-delimited text, the default format
+/*
+ * 文本编码，格式为 时间|[模块名称]|调用位置|参数1|参数2...|key=value...|stack=调用栈|后缀信息\n
+ * 不是模块子日志对象时省略模块名称，没有记录调用位置时省略，没有调用栈时省略，不输出后缀时省略
+ * 参数以及字段中的换行符转义为\n/\r，非法UTF-8字符替换为U+FFFD，保证一条记录只占一行；参数中的"|"不做转义
+ * LevelTag为true时在时间之后输出级别标记，例如 时间|[WARN]|调用位置|...，用于多个级别写入同一个文件
+ * 时间格式可以通过WithTimeFormat修改，TimeNone时省略时间以及其后的分隔符
+ */
+type TextEncoder struct {
+	LevelTag bool // 输出级别标记
+}
+
+// Encode implements Encoder
+func (encoder TextEncoder) Encode(entry *Entry) []byte {
+	buf := make([]byte, 0, len(datetimeFormat)+len(entry.Level)+len(entry.Name)+len(entry.Caller)+len(entry.Suffix)+16*(len(entry.Args)+len(entry.Fields))+7)
+	return encoder.appendEntry(buf, entry)
+}
+
+/*
+ * 将编码结果追加到buf，Encode以及使用pool中buffer的编码共用
+ */
+func (encoder TextEncoder) appendEntry(buf []byte, entry *Entry) []byte {
+	start := len(buf)
+	buf, stamped := appendEntryTime(buf, entry, datetimeFormat, false)
+	if encoder.LevelTag && entry.Level != "" {
+		buf = append(buf, "|["...)
+		buf = append(buf, strings.ToUpper(entry.Level)...)
+		buf = append(buf, ']')
+	}
+	if entry.Name != "" {
+		buf = append(buf, "|["...)
+		start := len(buf)
+		buf = append(buf, entry.Name...)
+		buf = sanitizeText(buf, start)
+		buf = append(buf, ']')
+	}
+	if entry.Caller != "" {
+		buf = append(buf, '|')
+		buf = append(buf, entry.Caller...)
+	}
+	for _, arg := range entry.Args {
+		buf = append(buf, '|')
+		buf = appendTextArg(buf, arg)
+	}
+	for _, key := range sortedFieldKeys(entry.Fields) {
+		buf = append(buf, '|')
+		start := len(buf)
+		buf = append(buf, key...)
+		buf = sanitizeText(buf, start)
+		buf = append(buf, '=')
+		buf = appendTextArg(buf, entry.Fields[key])
+	}
+	if len(entry.Stack) > 0 {
+		buf = append(buf, "|stack="...)
+		start := len(buf)
+		buf = appendStackCompact(buf, entry.Stack)
+		buf = sanitizeText(buf, start)
+	}
+	if entry.WithSuffix {
+		buf = append(buf, '|')
+		buf = append(buf, entry.Suffix...)
+	}
+	if !stamped && len(buf) > start && buf[start] == '|' {
+		// 不输出时间时去掉开头的分隔符
+		buf = append(buf[:start], buf[start+1:]...)
+	}
+	return append(buf, '\n')
+}
+
+// JSONEncoder encodes each entry as a single-line JSON object
+/*
+ * JSON编码，每条记录一行，便于ELK等系统采集，格式为：
+ * {"schema":1,"time":"...","level":"error","logger":"storage","caller":"...","msg":"a|b","suffix":"...","stack":"...","key":value...}
+ * schema为记录格式版本(JSONSchemaVersion)，记录格式变化时递增，历史记录可以使用SchemaMigrator升级
+ * logger为模块名称，不是模块子日志对象时不输出；msg为所有参数以"|"连接的结果
+ * 附加字段平铺输出，与保留字段(包括输出了的logger)重名时增加"fields."前缀
+ * WithTimeFormat为TimeEpochMillis时time为数字，为TimeNone时不输出time
+ */
+type JSONEncoder struct{}
+
+// jsonReservedKeys JSON编码中的保留字段
+var jsonReservedKeys = map[string]bool{"schema": true, "time": true, "level": true, "caller": true, "msg": true, "suffix": true, "stack": true}
+
+// Encode implements Encoder
+func (encoder JSONEncoder) Encode(entry *Entry) []byte {
+	return encoder.appendEntry(make([]byte, 0, 128+16*(len(entry.Args)+len(entry.Fields))), entry)
+}
+
+/*
+ * 将编码结果追加到buf，Encode以及使用pool中buffer的编码共用
+ */
+func (JSONEncoder) appendEntry(buf []byte, entry *Entry) []byte {
+	buf = append(buf, `{"schema":`...)
+	buf = strconv.AppendInt(buf, JSONSchemaVersion, 10)
+	if entry.TimeLayout != TimeNone {
+		buf = append(buf, `,"time":`...)
+		buf, _ = appendEntryTime(buf, entry, jsonTimeFormat, true)
+	}
+	if entry.Level != "" {
+		buf = append(buf, `,"level":`...)
+		buf = appendJSONString(buf, entry.Level)
+	}
+	if entry.Name != "" {
+		buf = append(buf, `,"logger":`...)
+		buf = appendJSONString(buf, entry.Name)
+	}
+	if entry.Caller != "" {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJSONString(buf, entry.Caller)
+	}
+
+	msg := getScratch()
+	for i, arg := range entry.Args {
+		if i > 0 {
+			*msg = append(*msg, '|')
+		}
+		*msg = appendArg(*msg, arg)
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONBytes(buf, *msg)
+	putScratch(msg)
+
+	if entry.WithSuffix && entry.Suffix != "" {
+		buf = append(buf, `,"suffix":`...)
+		buf = appendJSONString(buf, entry.Suffix)
+	}
+	if len(entry.Stack) > 0 {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJSONString(buf, formatStack(entry.Stack))
+	}
+	for _, key := range sortedFieldKeys(entry.Fields) {
+		name := key
+		if jsonReservedKeys[key] || (key == "logger" && entry.Name != "") {
+			name = "fields." + key
+		}
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, name)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, entry.Fields[key])
+	}
+	return append(buf, '}', '\n')
+}
+
+/*
+ * 将值以JSON格式追加到buf，常见类型直接追加，其余类型使用encoding/json
+ */
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	switch value := v.(type) {
+	case nil:
+		return append(buf, "null"...)
+	case string:
+		return appendJSONString(buf, value)
+	case bool:
+		return strconv.AppendBool(buf, value)
+	case int:
+		return strconv.AppendInt(buf, int64(value), 10)
+	case int64:
+		return strconv.AppendInt(buf, value, 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(value), 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(value), 10)
+	case uint64:
+		return strconv.AppendUint(buf, value, 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(value), 10)
+	case float64:
+		return appendJSONFloat(buf, value, 64)
+	case float32:
+		return appendJSONFloat(buf, float64(value), 32)
+	case time.Time:
+		buf = append(buf, '"')
+		buf = value.AppendFormat(buf, jsonTimeFormat)
+		return append(buf, '"')
+	case time.Duration:
+		return appendJSONString(buf, value.String())
+	case error:
+		return appendJSONString(buf, safeString(v, value.Error))
+	case fmt.Stringer:
+		return appendJSONString(buf, safeString(v, value.String))
+	}
+	if encoded, err := json.Marshal(v); err == nil {
+		return append(buf, encoded...)
+	}
+	return appendJSONString(buf, fmt.Sprintf("%v", v))
+}
+
+func appendJSONFloat(buf []byte, f float64, bitSize int) []byte {
+	// JSON不支持NaN以及Inf，以字符串输出
+	if f != f || f > 1.7976931348623157e308 || f < -1.7976931348623157e308 {
+		return appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, bitSize))
+	}
+	return strconv.AppendFloat(buf, f, 'g', -1, bitSize)
+}
+
+// hexDigits JSON转义使用的十六进制字符
+const hexDigits = "0123456789abcdef"
+
+/*
+ * 将字符串转义后以JSON字符串的形式追加到buf，非法UTF-8字符替换为U+FFFD
+ */
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, `�`...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(buf, '"')
+}
+
+/*
+ * 与appendJSONString相同，参数为[]byte，避免转换为string时的内存分配
+ */
+func appendJSONBytes(buf []byte, s []byte) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, `�`...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(buf, '"')
+}
+
+// Option configures a Logger at creation time
+type Option func(*Logger)
+
+// WithEncoder sets the encoder used for every record of the logger
+/*
+ * 设置日志编码方式，例如WithEncoder(JSONEncoder{})输出JSON格式日志
+ * @param encoder：编码器，为nil时使用默认的TextEncoder
+ */
+func WithEncoder(encoder Encoder) Option {
+	return func(logger *Logger) {
+		if encoder == nil {
+			encoder = TextEncoder{}
+		}
+		logger.encoder = encoder
+	}
+}
+
+// WithFlushInterval sets how often buffered records are handed to the write queue
+/*
+ * 设置buffer写入队列的间隔，默认1秒，间隔越短日志落盘越及时，fsync次数越多
+ * @param d：间隔，<=0时使用默认值
+ */
+func WithFlushInterval(d time.Duration) Option {
+	return func(logger *Logger) {
+		logger.flushEvery = d
+	}
+}
+
+// WithQueueSize sets the capacity of the write queue of every log file
+/*
+ * 设置每个日志文件写入队列的长度，默认50000，队列满时写入方阻塞(或者写入溢出文件、丢弃buffer，参考WithSpool、WithOverflowPolicy)
+ * @param n：队列长度，<=0时使用默认值
+ */
+func WithQueueSize(n int) Option {
+	return func(logger *Logger) {
+		logger.queueSize = n
+	}
+}
+
+/*
+ * 构建日志记录并使用logger的编码器编码
+ * @param level：日志级别，自定义文件为空
+ * @param caller：调用位置，可以为空
+ * @param suffix：是否输出后缀信息
+ * @param args：日志内容
+ * @param fields：本条记录的附加字段，可以为nil，与logger携带的字段合并后输出
+ * @return 编码后的日志记录，被hook丢弃时返回空字符串
+ */
+func (logger *Logger) encode(level, caller string, suffix bool, args []interface{}, fields Fields) string {
+	return logger.encodeStack(level, caller, suffix, args, fields, logger.stackAt(level))
+}
+
+/*
+ * 与encode相同，使用调用方获取的调用栈，用于Recover
+ * @param stack：调用栈，可以为nil
+ */
+func (logger *Logger) encodeStack(level, caller string, suffix bool, args []interface{}, fields Fields, stack []StackFrame) string {
+	entry := Entry{
+		Time:       logger.now(),
+		TimeLayout: logger.timeLayout,
+		Level:      level,
+		Name:       logger.name,
+		Caller:     caller,
+		Args:       args,
+		Fields:     mergeFields(logger.fields, fields),
+		Suffix:     logger.suffixInfo,
+		WithSuffix: suffix,
+		Stack:      stack,
+	}
+	entry, ok := logger.runHooks(entry)
+	if !ok {
+		return ""
+	}
+	encoder, ok := logger.encoder.(appendEncoder)
+	if !ok {
+		content := logger.encoder.Encode(&entry)
+		if logger.maxRecord > 0 && len(content) > logger.maxRecord {
+			return logger.limitRecord(&entry, len(content))
+		}
+		logger.encodeSinks(&entry, content)
+		return string(content)
+	}
+	// 内置编码器写入pool中的buffer，转换为string时复制一次
+	scratch := getScratch()
+	*scratch = encoder.appendEntry(*scratch, &entry)
+	if size := len(*scratch); logger.maxRecord > 0 && size > logger.maxRecord {
+		putScratch(scratch)
+		return logger.limitRecord(&entry, size)
+	}
+	logger.encodeSinks(&entry, *scratch)
+	content := string(*scratch)
+	putScratch(scratch)
+	return content
+}
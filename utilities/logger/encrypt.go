@@ -0,0 +1,265 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// encryptMagic 加密分块的起始标记
+const encryptMagic = "NLE1"
+
+// maxEncryptedChunk 解密时单个分块的最大长度，超过时认为数据损坏
+const maxEncryptedChunk = 256 * MB
+
+// ErrEncryptedFormat is returned when decrypting data that is not an encrypted log stream
+var ErrEncryptedFormat = errors.New("logger: malformed encrypted log stream")
+
+// EncryptionConfig configures the AES-GCM encryption of log output
+/*
+ * 密钥由调用方直接提供(Key)或者通过KeyFunc从KMS获取，KeyFunc不为nil时忽略Key以及KeyID
+ * 密钥长度为16、24或者32字节，分别对应AES-128、AES-192以及AES-256
+ */
+type EncryptionConfig struct {
+	Key     []byte                                       // 密钥
+	KeyID   string                                       // 密钥标识，写入每个分块，解密时用于查找密钥，最长255字节
+	KeyFunc func() (keyID string, key []byte, err error) // 获取密钥，创建时调用一次
+}
+
+// KeyLookup returns the key for a key ID when decrypting
+type KeyLookup func(keyID string) ([]byte, error)
+
+/*
+ * 分块加密，每次写入的内容加密为一个独立的分块：
+ *     "NLE1" | keyID长度(1字节) | keyID | nonce(12字节) | 密文长度(4字节，大端) | 密文
+ * 分块头部作为附加数据参与认证，分块之间互不依赖，追加写入以及切分之后的文件都可以单独解密
+ */
+type sealer struct {
+	aead   cipher.AEAD
+	header []byte // magic、keyID长度以及keyID
+}
+
+/*
+ * 根据配置创建sealer
+ */
+func newSealer(config EncryptionConfig) (*sealer, error) {
+	keyID, key := config.KeyID, config.Key
+	if config.KeyFunc != nil {
+		var err error
+		if keyID, key, err = config.KeyFunc(); err != nil {
+			return nil, fmt.Errorf("logger: encryption key: %w", err)
+		}
+	}
+	if len(keyID) > 255 {
+		return nil, errors.New("logger: encryption key id longer than 255 bytes")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, len(encryptMagic)+1+len(keyID))
+	header = append(header, encryptMagic...)
+	header = append(header, byte(len(keyID)))
+	header = append(header, keyID...)
+	return &sealer{aead: aead, header: header}, nil
+}
+
+/*
+ * 创建AES-GCM
+ */
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("logger: encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+/*
+ * 将p加密为一个分块
+ */
+func (s *sealer) seal(p []byte) ([]byte, error) {
+	nonceSize := s.aead.NonceSize()
+	out := make([]byte, len(s.header)+nonceSize+4, len(s.header)+nonceSize+4+len(p)+s.aead.Overhead())
+	copy(out, s.header)
+	nonce := out[len(s.header) : len(s.header)+nonceSize]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(out[len(s.header)+nonceSize:], uint32(len(p)+s.aead.Overhead()))
+	return s.aead.Seal(out, nonce, p, s.header), nil
+}
+
+// WithEncryption encrypts the log files with AES-GCM
+/*
+ * 日志文件中写入的是加密分块，每次flush为一个分块；sink、告警以及hook仍然使用明文
+ * 切分、备份以及压缩不受影响，压缩加密之后的文件基本没有效果，建议不同时开启
+ * 写入队列的溢出文件(WithSpool)不加密
+ * 查看日志通过NewDecryptReader解密，压缩过的文件先通过gzip.NewReader解压
+ * @param config：加密配置，密钥错误时NewLogger返回error
+ */
+func WithEncryption(config EncryptionConfig) Option {
+	return func(logger *Logger) {
+		s, err := newSealer(config)
+		if err != nil {
+			logger.initErr = err
+			return
+		}
+		logger.sealer = s
+	}
+}
+
+// EncryptWriter is a sink encrypting each write with AES-GCM before passing it on
+type EncryptWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	sealer *sealer
+}
+
+// NewEncryptWriter wraps w so that everything written to it is encrypted
+/*
+ * 创建加密输出，通过AddSink添加到Logger，例如加密之后写入单独的文件：
+ *   file, _ := os.OpenFile("/data/secure/saver.log.enc", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+ *   w, err := NewEncryptWriter(file, EncryptionConfig{Key: key, KeyID: "2024-01"})
+ *   logger.AddSink("secure", w, "error")
+ * @param w：加密之后的输出，Close时如果实现了io.Closer也会关闭
+ * @param config：加密配置
+ * @return 密钥错误时返回error
+ */
+func NewEncryptWriter(w io.Writer, config EncryptionConfig) (*EncryptWriter, error) {
+	s, err := newSealer(config)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptWriter{w: w, sealer: s}, nil
+}
+
+// Write implements io.Writer, p is written as one encrypted chunk
+func (w *EncryptWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	chunk, err := w.sealer.seal(p)
+	if err != nil {
+		return 0, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.w.Write(chunk); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the underlying writer if it is an io.Closer
+func (w *EncryptWriter) Close() error {
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// DecryptReader streams the plaintext of data written by WithEncryption or EncryptWriter
+type DecryptReader struct {
+	r       *bufio.Reader
+	lookup  KeyLookup
+	aeads   map[string]cipher.AEAD // 按keyID缓存
+	pending []byte                 // 已经解密但是还没有读取的内容
+	err     error
+}
+
+// NewDecryptReader returns a reader decrypting the chunks read from r
+/*
+ * 创建解密读取，例如查看切分之后的文件：
+ *   file, _ := os.Open("saver-error.log.2014091010")
+ *   r := NewDecryptReader(file, func(string) ([]byte, error) { return key, nil })
+ *   io.Copy(os.Stdout, r)
+ * 每个分块读取时都会校验，数据被修改或者密钥错误时Read返回error；文件末尾不完整的分块返回io.ErrUnexpectedEOF
+ * @param r：加密数据
+ * @param lookup：根据分块中的keyID查找密钥，每个keyID只调用一次
+ * @return 解密读取
+ */
+func NewDecryptReader(r io.Reader, lookup KeyLookup) *DecryptReader {
+	return &DecryptReader{r: bufio.NewReader(r), lookup: lookup, aeads: make(map[string]cipher.AEAD)}
+}
+
+// Read implements io.Reader
+func (d *DecryptReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.pending, d.err = d.next()
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+/*
+ * 读取并解密下一个分块
+ */
+func (d *DecryptReader) next() ([]byte, error) {
+	var prefix [len(encryptMagic) + 1]byte
+	if _, err := io.ReadFull(d.r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	if !bytes.Equal(prefix[:len(encryptMagic)], []byte(encryptMagic)) {
+		return nil, ErrEncryptedFormat
+	}
+	keyID := make([]byte, prefix[len(encryptMagic)])
+	if _, err := io.ReadFull(d.r, keyID); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	aead, err := d.aead(string(keyID))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize()+4)
+	if _, err := io.ReadFull(d.r, nonce); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	size := binary.BigEndian.Uint32(nonce[aead.NonceSize():])
+	if int64(size) > maxEncryptedChunk {
+		return nil, ErrEncryptedFormat
+	}
+	ciphertext := make([]byte, size)
+	if _, err := io.ReadFull(d.r, ciphertext); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	header := append(prefix[:], keyID...)
+	plaintext, err := aead.Open(ciphertext[:0], nonce[:aead.NonceSize()], ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("logger: decrypt chunk with key %q: %w", keyID, err)
+	}
+	return plaintext, nil
+}
+
+/*
+ * 获取keyID对应的AES-GCM，第一次使用时通过lookup查找密钥
+ */
+func (d *DecryptReader) aead(keyID string) (cipher.AEAD, error) {
+	if aead, ok := d.aeads[keyID]; ok {
+		return aead, nil
+	}
+	key, err := d.lookup(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("logger: lookup key %q: %w", keyID, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	d.aeads[keyID] = aead
+	return aead, nil
+}
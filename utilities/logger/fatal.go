@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+)
+
+// Fatal writes an error record, flushes every log file and exits with status 1
+/*
+ * 写入错误日志，同步flush所有日志文件之后调用os.Exit(1)
+ * 不受SetLevel影响，保证退出原因一定写入硬盘；JSON格式中level为fatal
+ * @param args：写入的具体内容数组
+ */
+func (logger *Logger) Fatal(args ...interface{}) {
+	logger.writeFinal("fatal", args)
+	os.Exit(1)
+}
+
+// Fatalf is Fatal with fmt.Sprintf formatting
+func (logger *Logger) Fatalf(format string, args ...interface{}) {
+	logger.writeFinal("fatal", []interface{}{fmt.Sprintf(format, args...)})
+	os.Exit(1)
+}
+
+// Panic writes an error record, flushes every log file and panics with the message
+/*
+ * 写入错误日志，同步flush所有日志文件之后panic，panic的值为fmt.Sprint(args...)
+ * 不受SetLevel影响；JSON格式中level为panic
+ * @param args：写入的具体内容数组
+ */
+func (logger *Logger) Panic(args ...interface{}) {
+	logger.writeFinal("panic", args)
+	panic(fmt.Sprint(args...))
+}
+
+// Panicf is Panic with fmt.Sprintf formatting
+func (logger *Logger) Panicf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logger.writeFinal("panic", []interface{}{msg})
+	panic(msg)
+}
+
+/*
+ * 将记录写入error日志文件并同步flush所有日志文件
+ * @param level：记录中的级别名称
+ * @param args：日志内容
+ */
+func (logger *Logger) writeFinal(level string, args []interface{}) {
+	logger.RLock()
+	loggerInfo := logger.logMap["error"]
+	logger.RUnlock()
+	if loggerInfo = logger.route("error", loggerInfo, nil); loggerInfo != nil {
+		logger.reporter.record(level)
+		loggerInfo.Write(logger.encode(level, caller(2+logger.callerSkip), true, args, nil))
+	}
+	logger.Flush()
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"sort"
+)
+
+// Fields are key/value pairs attached to a log record
+type Fields map[string]interface{}
+
+// WithFields returns a child logger that attaches fields to every record
+/*
+ * 创建携带固定字段的子日志对象，例如：
+ *     logger.WithFields(logger.Fields{"order_id": id}).Error("payment failed")
+ * 文本格式中字段按照key排序以key=value的形式输出在日志内容之后，JSON格式中平铺为独立的key
+ * 子日志对象与父对象共享日志文件、级别以及其他配置，对子对象调用SetLevel/Close等函数同样作用于父对象
+ * @param fields：附加字段，与父对象字段重名时覆盖父对象的值
+ * @return 子日志对象
+ */
+func (logger *Logger) WithFields(fields Fields) *Logger {
+	child := *logger
+	child.fields = mergeFields(logger.fields, fields)
+	return &child
+}
+
+// WithField returns a child logger that attaches a single field to every record
+func (logger *Logger) WithField(key string, value interface{}) *Logger {
+	return logger.WithFields(Fields{key: value})
+}
+
+/*
+ * 合并字段，不修改参数
+ * @param base：原有字段
+ * @param extra：新增字段，重名时覆盖base中的值
+ * @return 合并后的字段，两者都为空时返回nil
+ */
+func mergeFields(base, extra Fields) Fields {
+	if len(extra) == 0 {
+		return base
+	}
+	if len(base) == 0 {
+		return extra
+	}
+	merged := make(Fields, len(base)+len(extra))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range extra {
+		merged[key] = value
+	}
+	return merged
+}
+
+/*
+ * 获取排序后的字段key，保证输出稳定
+ */
+func sortedFieldKeys(fields Fields) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// slowFlushReportInterval 同一个日志文件两次慢写入告警之间的最小间隔
+const slowFlushReportInterval = 10 * time.Second
+
+// flushLatencyBuckets 写入延迟直方图的分桶上限
+var flushLatencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// ErrSlowFlush is reported through the error handler when a buffer takes too long to reach the disk
+var ErrSlowFlush = errors.New("logger: slow flush")
+
+// LatencyHistogram is a snapshot of the enqueue-to-disk latency of flushed buffers
+type LatencyHistogram struct {
+	Buckets []time.Duration // 分桶上限
+	Counts  []uint64        // 每个分桶的数量(不累计)，最后一个为超过所有上限的数量，长度为len(Buckets)+1
+	Count   uint64          // 总数
+	Sum     time.Duration   // 总延迟
+}
+
+// queuedBuffer 写入队列中的buffer以及进入队列的时间
+type queuedBuffer struct {
+	buffer   *bytes.Buffer
+	enqueued time.Time
+}
+
+// latencyHistogram 写入延迟直方图，原子操作访问
+type latencyHistogram struct {
+	counts [len(flushLatencyBuckets) + 1]uint64
+	count  uint64
+	sum    int64 // 纳秒
+}
+
+// WithSlowFlushThreshold reports ErrSlowFlush when a buffer waits longer than d to be written
+/*
+ * 记录每个buffer从进入写入队列到写入文件并fsync完成的时间，超过d时通过错误回调上报ErrSlowFlush，
+ * 用于在写入队列满之前发现fsync或者文件系统成为瓶颈
+ * 同一个日志文件10秒内最多上报一次，期间的次数累计在下一次上报中；Stats.SlowFlushes记录所有超过阈值的次数
+ * 延迟直方图(Stats.FlushLatency)不需要该选项也会记录，同步写入以及溢出文件重放的数据不计入
+ * @param d：阈值，<=0表示不上报
+ */
+func WithSlowFlushThreshold(d time.Duration) Option {
+	return func(logger *Logger) {
+		logger.slowFlush = d
+	}
+}
+
+/*
+ * 生成进入写入队列的buffer，调用方需要持有bufferLock
+ */
+func (logger *LoggerBuffer) queued() queuedBuffer {
+	return queuedBuffer{buffer: logger.bufferContent, enqueued: time.Now()}
+}
+
+/*
+ * 写入队列中的一个buffer并记录延迟，只能在FlushBufferQueue协程中调用
+ */
+func (logger *LoggerInfo) flushQueued(q queuedBuffer) {
+	logger.flushBuffer(q.buffer.Bytes())
+	putBuffer(q.buffer)
+
+	latency := time.Since(q.enqueued)
+	logger.reporter.latency.observe(latency)
+	if logger.slowFlush <= 0 || latency <= logger.slowFlush {
+		return
+	}
+	atomic.AddUint64(&logger.reporter.slowFlushes, 1)
+	logger.slowSuppressed++
+	if now := time.Now(); now.Sub(logger.slowReported) >= slowFlushReportInterval {
+		logger.reporter.report("FlushBufferQueue.Slow", fmt.Errorf("%w: %s took %v from queue to disk (threshold %v, %d slow flushes since last report, queue %d/%d)",
+			ErrSlowFlush, logger.filename, latency, logger.slowFlush, logger.slowSuppressed, len(logger.bufferQueue), cap(logger.bufferQueue)))
+		logger.slowReported = now
+		logger.slowSuppressed = 0
+	}
+}
+
+/*
+ * 记录一次延迟
+ */
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(flushLatencyBuckets) && d > flushLatencyBuckets[i] {
+		i++
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+/*
+ * 获取直方图快照
+ */
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	snapshot := LatencyHistogram{
+		Buckets: append([]time.Duration(nil), flushLatencyBuckets[:]...),
+		Counts:  make([]uint64, len(h.counts)),
+		Count:   atomic.LoadUint64(&h.count),
+		Sum:     time.Duration(atomic.LoadInt64(&h.sum)),
+	}
+	for i := range h.counts {
+		snapshot.Counts[i] = atomic.LoadUint64(&h.counts[i])
+	}
+	return snapshot
+}
@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// datetimeFormat 日志记录中的时间格式
+const datetimeFormat = "2006-01-02 15:04:05.000"
+
+// floatPrecision 浮点数输出的小数位数，-1表示使用能精确表示该值的最少位数
+var floatPrecision int32 = -1
+
+// SetFloatPrecision sets the number of digits after the decimal point for float args
+/*
+ * 设置浮点数参数输出的小数位数
+ * @param prec：小数位数，-1表示使用能精确表示该值的最少位数(默认)
+ */
+func SetFloatPrecision(prec int) {
+	if prec < -1 {
+		prec = -1
+	}
+	atomic.StoreInt32(&floatPrecision, int32(prec))
+}
+
+/*
+ * 将单个参数追加到buf中
+ * 常见类型使用strconv直接追加，避免fmt.Sprintf的反射以及内存分配；[]byte按照字符串输出
+ * error优先于fmt.Stringer，同时实现两者的类型输出Error()的内容
+ * @param buf：目标buffer
+ * @param arg：参数
+ * @return 追加后的buffer
+ */
+func appendArg(buf []byte, arg interface{}) []byte {
+	switch v := arg.(type) {
+	case nil:
+		return append(buf, "<nil>"...)
+	case string:
+		return append(buf, strings.TrimRight(v, "\n")...)
+	case []byte:
+		return append(buf, bytes.TrimRight(v, "\n")...)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case float64:
+		return strconv.AppendFloat(buf, v, 'f', int(atomic.LoadInt32(&floatPrecision)), 64)
+	case float32:
+		return strconv.AppendFloat(buf, float64(v), 'f', int(atomic.LoadInt32(&floatPrecision)), 32)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case time.Time:
+		return v.AppendFormat(buf, datetimeFormat)
+	case time.Duration:
+		return append(buf, v.String()...)
+	case error:
+		return append(buf, strings.TrimRight(safeString(arg, v.Error), "\n")...)
+	case fmt.Stringer:
+		return append(buf, safeString(arg, v.String)...)
+	default:
+		return append(buf, fmt.Sprintf("%v", arg)...)
+	}
+}
+
+/*
+ * 调用Error/String方法，方法panic时(例如nil指针接收者)交给fmt处理，
+ * 输出与fmt一致的<nil>或者%!v(PANIC=...)，避免写日志导致调用方panic
+ * @param arg：参数
+ * @param method：arg的Error或者String方法
+ * @return 字符串形式
+ */
+func safeString(arg interface{}, method func() string) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = fmt.Sprintf("%v", arg)
+		}
+	}()
+	return method()
+}
+
+/*
+ * 追加参数并转义其中的换行符，非法UTF-8字符替换为U+FFFD，保证文本格式中一条记录只占一行
+ * @param buf：目标buffer
+ * @param arg：参数
+ * @return 追加后的buffer
+ */
+func appendTextArg(buf []byte, arg interface{}) []byte {
+	start := len(buf)
+	buf = appendArg(buf, arg)
+	return sanitizeText(buf, start)
+}
+
+/*
+ * 处理buf[start:]中的换行符以及非法UTF-8字符，没有需要处理的内容时不分配内存
+ * @param buf：目标buffer
+ * @param start：需要处理的开始位置
+ * @return 处理后的buffer
+ */
+func sanitizeText(buf []byte, start int) []byte {
+	segment := buf[start:]
+	if cleanText(segment) {
+		return buf
+	}
+	escaped := make([]byte, 0, len(segment)+8)
+	for len(segment) > 0 {
+		r, size := utf8.DecodeRune(segment)
+		switch {
+		case r == '\n':
+			escaped = append(escaped, '\\', 'n')
+		case r == '\r':
+			escaped = append(escaped, '\\', 'r')
+		case r == utf8.RuneError && size == 1:
+			escaped = append(escaped, "\ufffd"...)
+		default:
+			escaped = append(escaped, segment[:size]...)
+		}
+		segment = segment[size:]
+	}
+	return append(buf[:start], escaped...)
+}
+
+/*
+ * 判断内容中是否没有换行符以及非法UTF-8字符，一次遍历完成，ASCII字符不需要解码
+ */
+func cleanText(segment []byte) bool {
+	for i := 0; i < len(segment); {
+		c := segment[i]
+		if c < utf8.RuneSelf {
+			if c == '\n' || c == '\r' {
+				return false
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(segment[i:])
+		if r == utf8.RuneError && size == 1 {
+			return false
+		}
+		i += size
+	}
+	return true
+}
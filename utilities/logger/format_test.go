@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// panicStringer String方法panic的参数
+type panicStringer struct{ name *string }
+
+func (s *panicStringer) String() string { return *s.name }
+
+func TestAppendDatetime(t *testing.T) {
+	base := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	for _, ts := range []time.Time{
+		base, base.Add(999 * time.Millisecond), base.Add(time.Second + 7*time.Millisecond),
+		base.UTC(), base.Add(time.Second).UTC(), base.Add(-time.Nanosecond), time.Unix(-1, 5e8),
+	} {
+		want := ts.Format(datetimeFormat)
+		if got := string(appendDatetime(nil, ts)); got != want {
+			t.Errorf("appendDatetime(%v) = %q, want %q", ts, got, want)
+		}
+	}
+}
+
+func FuzzFormat(f *testing.F) {
+	f.Add("hello", []byte("world"), int64(42), 1.5, "request_id", "01HX")
+	f.Add("line1\nline2\r\n", []byte{0xff, 0xfe, '\n'}, int64(-1), -0.0, "msg", "a|b")
+	f.Add("", []byte(nil), int64(0), math.Inf(1), "", "\x00 ")
+	f.Fuzz(func(t *testing.T, s string, b []byte, i int64, fl float64, key, value string) {
+		args := []interface{}{s, b, i, fl, errors.New(s), &panicStringer{}}
+
+		line := Format(true, "suffix", args...)
+		if strings.IndexByte(line, '\n') != len(line)-1 {
+			t.Fatalf("Format: not a single line: %q", line)
+		}
+		if !utf8.ValidString(line) {
+			t.Fatalf("Format: invalid UTF-8: %q", line)
+		}
+		if stamp, _, _ := strings.Cut(line, "|"); len(stamp) != len(datetimeFormat) {
+			t.Fatalf("Format: bad time %q in %q", stamp, line)
+		}
+		if !strings.HasSuffix(line, "|suffix\n") {
+			t.Fatalf("Format: suffix missing: %q", line)
+		}
+
+		entry := &Entry{Time: time.Now(), Level: "warn", Args: args, Fields: Fields{key: value}}
+		text := TextEncoder{LevelTag: true}.Encode(entry)
+		if bytes.IndexByte(text, '\n') != len(text)-1 || !utf8.Valid(text) {
+			t.Fatalf("TextEncoder: %q", text)
+		}
+
+		encoded := JSONEncoder{}.Encode(entry)
+		if bytes.IndexByte(encoded, '\n') != len(encoded)-1 {
+			t.Fatalf("JSONEncoder: not a single line: %q", encoded)
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(encoded, &record); err != nil {
+			t.Fatalf("JSONEncoder: %v: %q", err, encoded)
+		}
+		// 参数末尾的换行符被去掉
+		want := strings.TrimRight(s, "\n") + "|"
+		if msg, _ := record["msg"].(string); utf8.ValidString(s) && !strings.HasPrefix(msg, want) {
+			t.Fatalf("JSONEncoder: msg %q does not start with %q", msg, want)
+		}
+	})
+}
+
+/*
+ * 改为pool buffer之前的Format实现，作为基准测试的对照：每个参数拼接一次字符串，只有int、string、int64不经过fmt
+ */
+func formatBaseline(suffix bool, suffixInfo string, args ...interface{}) string {
+	var content string
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case int:
+			content = content + "|" + strconv.Itoa(v)
+		case string:
+			content = content + "|" + strings.TrimRight(v, "\n")
+		case int64:
+			content = content + "|" + strconv.FormatInt(v, 10)
+		default:
+			content = content + "|" + fmt.Sprintf("%v", arg)
+		}
+	}
+	datetime := time.Now().Format(datetimeFormat)
+	if suffix {
+		return datetime + content + "|" + suffixInfo + "\n"
+	}
+	return datetime + content + "\n"
+}
+
+// benchArgs 基准测试使用的参数，包含常见类型
+var benchArgs = []interface{}{
+	"upstream slow", 503, int64(1714979289123), 1.25, true,
+	errors.New("connection reset by peer"), []byte("order-service"), 250 * time.Millisecond,
+}
+
+func BenchmarkFormat(b *testing.B) {
+	for _, bench := range []struct {
+		name   string
+		format func(bool, string, ...interface{}) string
+	}{
+		{"pooled", Format},
+		{"baseline", formatBaseline},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bench.format(true, "10.0.0.1", benchArgs...)
+			}
+		})
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	fields := Fields{"request_id": "01HXAMPLE", "user": 42, "latency": 1.25}
+	for _, bench := range []struct {
+		name    string
+		encoder Encoder
+		fields  Fields
+	}{
+		{"text", TextEncoder{}, nil},
+		{"text-fields", TextEncoder{}, fields},
+		{"json", JSONEncoder{}, nil},
+		{"json-fields", JSONEncoder{}, fields},
+		{"msgpack-fields", MsgpackEncoder{}, fields},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			log, err := NewDiscardLogger(WithEncoder(bench.encoder))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer log.Close()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				log.encode("warn", "", false, benchArgs, bench.fields)
+			}
+		})
+	}
+}
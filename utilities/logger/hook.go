@@ -0,0 +1,75 @@
+package logger
+
+// Hook inspects or rewrites a record before it is encoded
+/*
+ * 日志记录编码之前的处理函数，可以补充字段(主机名等)、脱敏、统计或者过滤记录
+ * 返回修改后的记录以及是否继续写入，返回false时丢弃该记录，之后的hook不再执行
+ * entry.Fields以及entry.Args可能与其他记录共享，修改字段使用entry.SetField，修改参数时先复制
+ * hook在写日志的协程中同步执行，需要保证并发安全，不能阻塞，也不能通过同一个Logger写日志
+ */
+type Hook func(entry Entry) (Entry, bool)
+
+// hook 已注册的处理函数
+type hook struct {
+	fn     Hook
+	levels map[string]bool // 为空表示所有记录，包括通过Write写入的自定义文件
+}
+
+// WithHook registers a hook at creation time, see AddHook
+func WithHook(fn Hook, levels ...string) Option {
+	return func(logger *Logger) {
+		logger.AddHook(fn, levels...)
+	}
+}
+
+// AddHook registers a hook run before records of the given levels are encoded
+/*
+ * 注册记录处理函数，所有hook按照注册顺序依次执行，全局hook与级别hook之间同样按照注册顺序
+ * Fatal/Panic同样经过hook；抽样以及重复消息合并在hook之前进行
+ * @param fn：处理函数
+ * @param levels：生效的级别，为空表示所有记录
+ */
+func (logger *Logger) AddHook(fn Hook, levels ...string) {
+	h := &hook{fn: fn}
+	if len(levels) > 0 {
+		h.levels = make(map[string]bool, len(levels))
+		for _, level := range levels {
+			h.levels[level] = true
+		}
+	}
+	logger.Lock()
+	defer logger.Unlock()
+	hooks, _ := logger.hooks.Load().([]*hook)
+	updated := make([]*hook, len(hooks), len(hooks)+1)
+	copy(updated, hooks)
+	logger.hooks.Store(append(updated, h))
+}
+
+// SetField sets a field of the entry without modifying maps shared with other records
+func (entry *Entry) SetField(key string, value interface{}) {
+	fields := make(Fields, len(entry.Fields)+1)
+	for k, v := range entry.Fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	entry.Fields = fields
+}
+
+/*
+ * 按照注册顺序执行hook
+ * @param entry：日志记录
+ * @return (处理之后的记录, 是否继续写入)
+ */
+func (logger *logCore) runHooks(entry Entry) (Entry, bool) {
+	hooks, _ := logger.hooks.Load().([]*hook)
+	for _, h := range hooks {
+		if len(h.levels) > 0 && !h.levels[entry.Level] {
+			continue
+		}
+		var ok bool
+		if entry, ok = h.fn(entry); !ok {
+			return entry, false
+		}
+	}
+	return entry, true
+}
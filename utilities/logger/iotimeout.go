@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIOTimeout is returned when a file operation does not finish before the io timeout
+var ErrIOTimeout = errors.New("logger: file io timeout")
+
+// ioRequest is a file operation executed by the io worker goroutine
+type ioRequest struct {
+	fn       func() error
+	done     chan error
+	filename string
+}
+
+// SetIOTimeout sets the timeout of file writes for all log files
+/*
+ * 设置日志文件写入超时时间，适用于日志目录挂载在NFS/CIFS等网络文件系统上的场景
+ * 设置超时后文件写入在独立的io协程中执行，超时后flush协程不再等待，避免被卡住的文件系统拖死
+ * @param d：超时时间，0表示不限制(默认)
+ */
+func (logger *Logger) SetIOTimeout(d time.Duration) {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.ioTimeout = d
+	for _, loggerInfo := range logger.logMap {
+		loggerInfo.SetIOTimeout(d)
+	}
+}
+
+// Stalled reports whether any log file is currently stalled on io
+/*
+ * 检查是否有日志文件的写入卡住
+ * @return 返回true表示存在卡住的日志文件
+ */
+func (logger *Logger) Stalled() bool {
+	logger.RLock()
+	defer logger.RUnlock()
+	for _, loggerInfo := range logger.logMap {
+		if loggerInfo.Stalled() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIOTimeout sets the timeout of file writes
+func (logger *LoggerInfo) SetIOTimeout(d time.Duration) {
+	atomic.StoreInt64(&logger.ioTimeout, int64(d))
+}
+
+// Stalled reports whether the file is stalled on io
+/*
+ * 检查日志文件写入是否卡住
+ * @return 返回true表示上一次超时的写入还没有完成
+ */
+func (logger *LoggerInfo) Stalled() bool {
+	return atomic.LoadInt64(&logger.stalledSince) != 0
+}
+
+/*
+ * 执行文件操作，设置了超时时间时在io协程中执行并等待结果
+ * io协程只有一个，上一次超时的操作没有完成之前，后续的操作同样会超时
+ * @param fn：文件操作
+ * @return 文件操作返回的错误，超时返回ErrIOTimeout
+ */
+func (logger *LoggerInfo) doIO(fn func() error) error {
+	timeout := time.Duration(atomic.LoadInt64(&logger.ioTimeout))
+	if timeout <= 0 {
+		return fn()
+	}
+	logger.ioWorkerOnce.Do(func() {
+		go logger.ioWorker()
+	})
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	req := ioRequest{fn: fn, done: make(chan error, 1), filename: logger.filename}
+	select {
+	case logger.ioQueue <- req:
+	case <-timer.C:
+		logger.markStalled()
+		return ErrIOTimeout
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-timer.C:
+		logger.markStalled()
+		return ErrIOTimeout
+	}
+}
+
+/*
+ * io协程，串行执行文件操作，FlushBufferQueue协程退出时关闭ioQueue，io协程执行完剩余的操作之后退出
+ */
+func (logger *LoggerInfo) ioWorker() {
+	for req := range logger.ioQueue {
+		req.done <- req.fn()
+		if since := atomic.SwapInt64(&logger.stalledSince, 0); since != 0 {
+			logger.reporter.report("ioWorker", errors.New(req.filename+" recovered after stalled for "+time.Since(time.Unix(0, since)).String()))
+		}
+	}
+}
+
+/*
+ * 记录文件写入卡住，只在第一次卡住时输出
+ */
+func (logger *LoggerInfo) markStalled() {
+	if atomic.CompareAndSwapInt64(&logger.stalledSince, 0, time.Now().UnixNano()) {
+		logger.reporter.report("doIO", errors.New(logger.filename+" stalled: "+ErrIOTimeout.Error()))
+	}
+}
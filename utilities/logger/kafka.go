@@ -0,0 +1,333 @@
+package logger
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KafkaWriter默认参数
+const (
+	defaultKafkaQueue     = 10000
+	defaultKafkaBatchSize = 500
+	defaultKafkaLinger    = 100 * time.Millisecond
+)
+
+// ErrKafkaQueueFull is returned when a record is dropped because the send queue is full
+var ErrKafkaQueueFull = errors.New("logger: kafka queue full, record dropped")
+
+// KafkaMessage is a log record published to kafka
+type KafkaMessage struct {
+	Topic   string
+	Key     []byte
+	Value   []byte            // 一条编码后的日志记录，不含换行符
+	Headers map[string]string // 消息头，包含level
+}
+
+// KafkaProducer publishes messages to kafka, implemented by an adapter over the kafka client in use
+/*
+ * kafka生产者接口，由调用方基于所使用的客户端(sarama、kafka-go等)实现
+ * Produce同步发送一批消息，全部确认之后返回；返回error表示这一批没有全部发送成功
+ * Produce只会在KafkaWriter的发送协程中串行调用
+ */
+type KafkaProducer interface {
+	Produce(messages []KafkaMessage) error
+	Close() error
+}
+
+// KafkaConfig configures a kafka sink
+type KafkaConfig struct {
+	Producer     KafkaProducer
+	Topic        string                                   // 所有级别共用的topic，LevelTopics中没有的级别使用该topic
+	LevelTopics  map[string]string                        // 按照级别指定topic，例如{"error": "app-error"}
+	KeyFunc      func(level string, record []byte) []byte // 生成消息key，为nil时不设置key
+	QueueSize    int                                      // 发送队列长度(条数)，默认10000，队列满时丢弃记录
+	BatchSize    int                                      // 每批最大条数，默认500
+	Linger       time.Duration                            // 凑批的最长等待时间，默认100ms
+	DrainTimeout time.Duration                            // Close时等待剩余记录发送的最长时间，0表示一直等待
+	Binary       bool                                     // 记录由MsgpackEncoder/ProtoEncoder编码，按照长度前缀切分，消息内容不含长度前缀
+	PackRecords  int                                      // 每条消息最多合并的记录数，默认1表示每条记录一条消息
+	Compression  []string                                 // 按照优先顺序排列的压缩算法，使用第一个已注册的算法压缩消息内容，为空表示不压缩
+}
+
+// KafkaWriter is a sink publishing records to kafka asynchronously
+/*
+ * kafka输出：WriteLevel只将记录放入发送队列，发送协程按照BatchSize/Linger凑批之后调用Producer.Produce
+ * 每条记录一条消息，消息头level为日志级别，单个topic时可以根据消息头或者JSON记录中的level字段区分级别
+ * PackRecords大于1时同一topic、同一级别的连续记录合并为一条消息，消息头records为记录条数，
+ * 文本记录以换行符分隔，二进制记录(Binary)保留4字节长度前缀；消息key使用第一条记录的key
+ * 开启Compression时压缩消息内容，消息头content-encoding为算法名称；kafka客户端自带的批量压缩效果更好时不需要开启，
+ * 只在客户端不支持所需的算法或者需要端到端压缩时使用，合并记录可以提高压缩率
+ * 发送失败以及队列满丢弃的记录通过Logger的错误回调上报，并计入Stats
+ */
+type KafkaWriter struct {
+	config   KafkaConfig
+	reporter *reporter
+	codec    Compressor // 消息内容的压缩算法，nil表示不压缩
+	queue    chan KafkaMessage
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	closeErr error
+}
+
+// AddKafkaSink publishes records of the given levels to kafka
+/*
+ * 添加kafka输出，错误通过该Logger的错误回调上报，例如：
+ *   w, err := logger.AddKafkaSink("kafka", KafkaConfig{Producer: producer, Topic: "app-log"}, "warn", "error")
+ *   ...
+ *   logger.Close()
+ *   w.Close()
+ * @param name：sink名称，用于RemoveSink
+ * @param config：kafka配置，Producer以及Topic/LevelTopics必须提供
+ * @param levels：接收的日志级别，为空表示所有级别
+ * @return 配置不合法、Compression中没有已注册的算法或者名称已经存在时返回error
+ */
+func (logger *Logger) AddKafkaSink(name string, config KafkaConfig, levels ...string) (*KafkaWriter, error) {
+	if config.Producer == nil {
+		return nil, errors.New("logger: kafka producer required")
+	}
+	if config.Topic == "" && len(config.LevelTopics) == 0 {
+		return nil, errors.New("logger: kafka topic required")
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultKafkaQueue
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultKafkaBatchSize
+	}
+	if config.Linger <= 0 {
+		config.Linger = defaultKafkaLinger
+	}
+	codec, err := negotiateCompression(config.Compression, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &KafkaWriter{
+		config:   config,
+		reporter: logger.reporter,
+		codec:    codec,
+		queue:    make(chan KafkaMessage, config.QueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := logger.AddSink(name, w, levels...); err != nil {
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write implements io.Writer, records are published without a level
+func (w *KafkaWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel("", 0, p)
+}
+
+// WriteLevel implements LevelWriter
+func (w *KafkaWriter) WriteLevel(level string, severity int, p []byte) (int, error) {
+	select {
+	case <-w.stop:
+		return 0, ErrClosed
+	default:
+	}
+	topic := w.config.Topic
+	if t, ok := w.config.LevelTopics[level]; ok {
+		topic = t
+	}
+	if topic == "" {
+		return len(p), nil
+	}
+	n := len(p)
+	for len(p) > 0 {
+		var record []byte
+		record, p = nextRecord(p, w.config.Binary)
+		if len(record) == 0 {
+			continue
+		}
+		msg := KafkaMessage{
+			Topic:   topic,
+			Value:   append([]byte(nil), record...),
+			Headers: map[string]string{"level": level},
+		}
+		if w.config.KeyFunc != nil {
+			msg.Key = w.config.KeyFunc(level, msg.Value)
+		}
+		select {
+		case w.queue <- msg:
+		default:
+			w.reporter.drop()
+			return 0, ErrKafkaQueueFull
+		}
+	}
+	return n, nil
+}
+
+// Close publishes the queued records and closes the producer
+/*
+ * 发送队列中剩余的记录之后关闭Producer，超过DrainTimeout时放弃剩余记录，重复调用是安全的
+ * 需要在RemoveSink或者Logger.Close之后调用，之后写入的记录返回ErrClosed
+ * @return Producer.Close的结果
+ */
+func (w *KafkaWriter) Close() error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+		w.closeErr = w.config.Producer.Close()
+	})
+	<-w.done
+	return w.closeErr
+}
+
+/*
+ * 发送协程：凑够BatchSize条或者等待Linger之后发送一批
+ */
+func (w *KafkaWriter) run() {
+	defer close(w.done)
+	batch := make([]KafkaMessage, 0, w.config.BatchSize)
+	linger := time.NewTimer(w.config.Linger)
+	linger.Stop()
+	for {
+		select {
+		case msg := <-w.queue:
+			if len(batch) == 0 {
+				linger.Reset(w.config.Linger)
+			}
+			if batch = append(batch, msg); len(batch) >= w.config.BatchSize {
+				linger.Stop()
+				batch = w.produce(batch)
+			}
+		case <-linger.C:
+			batch = w.produce(batch)
+		case <-w.stop:
+			linger.Stop()
+			w.drain(batch)
+			return
+		}
+	}
+}
+
+/*
+ * Close时发送剩余的记录
+ * @param batch：尚未发送的一批
+ */
+func (w *KafkaWriter) drain(batch []KafkaMessage) {
+	var deadline <-chan time.Time
+	if w.config.DrainTimeout > 0 {
+		timer := time.NewTimer(w.config.DrainTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case <-deadline:
+			w.abandon(len(batch) + len(w.queue))
+			return
+		default:
+		}
+	fill:
+		for len(batch) < w.config.BatchSize {
+			select {
+			case msg := <-w.queue:
+				batch = append(batch, msg)
+			default:
+				break fill
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+		batch = w.produce(batch)
+	}
+}
+
+/*
+ * 发送一批消息，失败时上报错误并计入丢弃数
+ * @return 清空之后的batch，可以继续复用
+ */
+func (w *KafkaWriter) produce(batch []KafkaMessage) []KafkaMessage {
+	if len(batch) == 0 {
+		return batch
+	}
+	messages, records := w.pack(batch), len(batch)
+	if err := w.config.Producer.Produce(messages); err != nil {
+		w.reporter.writeFailed("Kafka.Produce", err)
+		for i := 0; i < records; i++ {
+			w.reporter.drop()
+		}
+	}
+	for i := range batch {
+		batch[i] = KafkaMessage{}
+	}
+	return batch[:0]
+}
+
+/*
+ * 开启PackRecords时合并同一topic、同一级别的连续记录，开启压缩时压缩消息内容
+ * @return 发送的消息，不修改batch中的内容
+ */
+func (w *KafkaWriter) pack(batch []KafkaMessage) []KafkaMessage {
+	if w.config.PackRecords <= 1 && w.codec == nil {
+		return batch
+	}
+	messages := make([]KafkaMessage, 0, len(batch))
+	for i := 0; i < len(batch); {
+		first := batch[i]
+		msg := KafkaMessage{Topic: first.Topic, Key: first.Key, Value: first.Value, Headers: make(map[string]string, len(first.Headers)+2)}
+		for key, value := range first.Headers {
+			msg.Headers[key] = value
+		}
+		j := i + 1
+		if w.config.PackRecords > 1 {
+			for j < len(batch) && j-i < w.config.PackRecords && batch[j].Topic == first.Topic && batch[j].Headers["level"] == first.Headers["level"] {
+				j++
+			}
+			size := 0
+			for _, record := range batch[i:j] {
+				size += len(record.Value) + binaryHeaderSize
+			}
+			value := make([]byte, 0, size)
+			for _, record := range batch[i:j] {
+				value = w.appendRecord(value, record.Value)
+			}
+			msg.Value = value
+			msg.Headers["records"] = strconv.Itoa(j - i)
+		}
+		if w.codec != nil {
+			if compressed, err := compressBatch(w.codec, nil, msg.Value); err != nil {
+				w.reporter.report("Kafka.Compress", err)
+			} else {
+				msg.Value = compressed
+				msg.Headers["content-encoding"] = w.codec.Name()
+			}
+		}
+		messages = append(messages, msg)
+		i = j
+	}
+	return messages
+}
+
+/*
+ * 追加合并消息中的一条记录，文本记录以换行符结尾，二进制记录带长度前缀
+ */
+func (w *KafkaWriter) appendRecord(value, record []byte) []byte {
+	if !w.config.Binary {
+		value = append(value, record...)
+		return append(value, '\n')
+	}
+	value = binary.BigEndian.AppendUint32(value, uint32(len(record)))
+	return append(value, record...)
+}
+
+/*
+ * 记录Close超时放弃的记录
+ */
+func (w *KafkaWriter) abandon(n int) {
+	for i := 0; i < n; i++ {
+		w.reporter.drop()
+	}
+	if n > 0 {
+		w.reporter.report("Kafka.Close", errors.New("logger: kafka drain timed out, records dropped"))
+	}
+}
@@ -0,0 +1,248 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 内置日志级别的严重程度，自定义级别可以插入到它们之间，例如info取250
+const (
+	SeverityDebug = 100
+	SeverityTrace = 200
+	SeverityWarn  = 300
+	SeverityError = 400
+)
+
+// levelAll 表示记录所有级别
+const levelAll = "all"
+
+// DefaultLevelEnv is the environment variable read by WithLevelFromEnv when no name is given
+const DefaultLevelEnv = "NANO_LOG_LEVEL"
+
+// ErrLevelExists is returned when registering a level name that is already in use
+var ErrLevelExists = errors.New("logger: level already registered")
+
+/*
+ * 内置日志级别及其严重程度
+ */
+func builtinLevels() map[string]int {
+	return map[string]int{
+		"debug": SeverityDebug,
+		"trace": SeverityTrace,
+		"warn":  SeverityWarn,
+		"error": SeverityError,
+	}
+}
+
+// RegisterLevel registers a custom level written to its own file
+/*
+ * 注册自定义日志级别，例如info/fatal/audit
+ * 日志文件为 filename-level.log，在第一次写入时才会创建
+ * @param name：级别名称，不能为空，不能包含路径分隔符
+ * @param severity：严重程度，用于SetLevel/SetMinLevel过滤，参考SeverityDebug等内置级别的取值
+ * @return 名称不合法或者与已经创建的通道同名返回error；名称已经存在返回ErrLevelExists
+ */
+func (logger *Logger) RegisterLevel(name string, severity int) error {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return errors.New("logger: invalid level name " + name)
+	}
+	logger.Lock()
+	defer logger.Unlock()
+	if _, ok := logger.levels[name]; ok {
+		return ErrLevelExists
+	}
+	if _, ok := logger.logMap[channelKeyPrefix+name]; ok {
+		// 与通道使用同一个文件
+		return errors.New("logger: level name " + name + " is used by a channel")
+	}
+	logger.levels[name] = severity
+	return nil
+}
+
+// ParseLevel returns the severity of a built-in level name
+/*
+ * 解析内置级别名称，大小写不敏感，前后的空白会被忽略
+ * 例如: ParseLevel("WARN")返回(SeverityWarn, nil)；ParseLevel("all")返回(0, nil)
+ * 自定义级别需要通过Logger.SetLevelString设置
+ * @param name：级别名称，all表示记录所有级别
+ * @return (严重程度, error)，名称不是内置级别时返回ErrUnknownLevel
+ */
+func ParseLevel(name string) (int, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == levelAll {
+		return 0, nil
+	}
+	if severity, ok := builtinLevels()[name]; ok {
+		return severity, nil
+	}
+	return 0, ErrUnknownLevel
+}
+
+// SetLevelString sets the minimum level by name, "all" records every level
+/*
+ * 按照级别名称设置记录级别，与SetMinLevel相同，但是大小写不敏感，并且支持all
+ * 已经注册了名为all的自定义级别时all表示该级别
+ * @param name：级别名称，内置级别以及自定义级别均可
+ * @return 级别不存在返回ErrUnknownLevel
+ */
+func (logger *Logger) SetLevelString(name string) error {
+	logger.Lock()
+	defer logger.Unlock()
+	return logger.setLevelString(name)
+}
+
+// WithLevelFromEnv initializes the minimum level from an environment variable
+/*
+ * 使用环境变量设置初始记录级别，例如NANO_LOG_LEVEL=debug，取值参考SetLevelString
+ * 环境变量不存在或者为空时保持默认级别；取值不是已知级别时NewLogger返回ErrUnknownLevel
+ * 只能识别内置级别，按照环境变量设置自定义级别时在RegisterLevel之后调用SetLevelString
+ * @param name：环境变量名称，为空时使用DefaultLevelEnv
+ */
+func WithLevelFromEnv(name string) Option {
+	return func(logger *Logger) {
+		if name == "" {
+			name = DefaultLevelEnv
+		}
+		value := strings.TrimSpace(os.Getenv(name))
+		if value == "" {
+			return
+		}
+		if err := logger.setLevelString(value); err != nil {
+			logger.initErr = fmt.Errorf("%s=%q: %w", name, value, err)
+		}
+	}
+}
+
+/*
+ * 按照名称设置记录级别，调用方需要持有写锁
+ */
+func (logger *logCore) setLevelString(name string) error {
+	name = strings.TrimSpace(name)
+	severity, ok := logger.levels[name]
+	if !ok {
+		severity, ok = logger.levels[strings.ToLower(name)]
+	}
+	if !ok {
+		if !strings.EqualFold(name, levelAll) {
+			return ErrUnknownLevel
+		}
+		severity = 0
+	}
+	logger.minSeverity = severity
+	return nil
+}
+
+// Levels returns the registered levels and their severities
+func (logger *Logger) Levels() map[string]int {
+	logger.RLock()
+	defer logger.RUnlock()
+	levels := make(map[string]int, len(logger.levels))
+	for name, severity := range logger.levels {
+		levels[name] = severity
+	}
+	return levels
+}
+
+// SetMinLevel only records levels at least as severe as the named level
+/*
+ * 按照级别名称设置记录级别，严重程度低于该级别的日志不再记录
+ * @param name：级别名称
+ * @return 级别不存在返回ErrUnknownLevel
+ */
+func (logger *Logger) SetMinLevel(name string) error {
+	logger.Lock()
+	defer logger.Unlock()
+	severity, ok := logger.levels[name]
+	if !ok {
+		return ErrUnknownLevel
+	}
+	logger.minSeverity = severity
+	return nil
+}
+
+// MinLevel returns the name and severity of the current minimum level
+/*
+ * 获取当前记录级别
+ * @return (级别名称, 严重程度)，记录所有级别时返回("all", 0)；严重程度没有对应的级别时名称为空
+ */
+func (logger *Logger) MinLevel() (string, int) {
+	logger.RLock()
+	defer logger.RUnlock()
+	if logger.minSeverity <= 0 {
+		return levelAll, 0
+	}
+	for name, severity := range logger.levels {
+		if severity == logger.minSeverity {
+			return name, severity
+		}
+	}
+	return "", logger.minSeverity
+}
+
+// Log writes a record to the given level, including custom levels
+/*
+ * 写入指定级别的日志，用于自定义级别，内置级别同样可用
+ * @param level：级别名称
+ * @param args：写入的具体内容数组
+ * @return 级别不存在返回ErrUnknownLevel；日志文件创建失败返回error
+ */
+func (logger *Logger) Log(level string, args ...interface{}) error {
+	loggerInfo, enabled, err := logger.levelInfo(level)
+	if err != nil || !enabled {
+		return err
+	}
+	if loggerInfo = logger.route(level, loggerInfo, nil); loggerInfo == nil || !logger.dedupe(loggerInfo, level, args, nil) || !logger.sampleArgs(level, args) {
+		return nil
+	}
+	logger.write(loggerInfo, level, logger.encode(level, logger.callerAt(level, 1), true, args, nil))
+	return nil
+}
+
+/*
+ * 检查级别是否已经注册
+ */
+func (logger *Logger) hasLevel(level string) bool {
+	logger.RLock()
+	defer logger.RUnlock()
+	_, ok := logger.levels[level]
+	return ok
+}
+
+/*
+ * 获取级别对应的LoggerInfo，自定义级别的日志文件在第一次使用时创建
+ * @param level：级别名称
+ * @return (LoggerInfo, 当前是否需要记录该级别, error)，不需要记录时不会创建文件
+ */
+func (logger *Logger) levelInfo(level string) (*LoggerInfo, bool, error) {
+	logger.RLock()
+	_, ok := logger.levels[level]
+	loggerInfo := logger.logMap[level]
+	enabled := logger.CheckLevel(level)
+	logger.RUnlock()
+	if !ok {
+		return nil, false, ErrUnknownLevel
+	}
+	if !enabled || loggerInfo != nil {
+		return loggerInfo, enabled, nil
+	}
+
+	logger.Lock()
+	defer logger.Unlock()
+	if loggerInfo = logger.logMap[level]; loggerInfo != nil {
+		return loggerInfo, true, nil
+	}
+	if logger.singleFile {
+		// 单文件模式下自定义级别同样写入共用的文件
+		loggerInfo = logger.logMap[logLevel[0]]
+	} else {
+		var err error
+		if loggerInfo, err = logger.startLoggerInfo(logger.filename, level, logger.backupDir); err != nil {
+			return nil, false, err
+		}
+	}
+	logger.logMap[level] = loggerInfo
+	logger.updateCascade()
+	return loggerInfo, true, nil
+}
@@ -3,14 +3,15 @@ package logger
 import (
 	"bytes"
 	"fmt"
-	"net"
+	"io"
 	"os"
 	"path/filepath"
-	"runtime"
-	"strconv"
-	"strings"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/netutil"
 )
 
 const (
@@ -20,30 +21,135 @@ const (
 	HOURFORMAT = "2006010215"
 )
 
+// logLevel 内置日志级别，按照严重程度从低到高排列，SetLevel的参数为其下标
 var logLevel = [4]string{"debug", "trace", "warn", "error"}
 
 // Logger is logger struct
 /*
  * 	默认日志文件级别包括debug/trace/warn/error
+ *  通过WithFields创建的子日志对象与父对象共享日志文件以及配置，只是额外携带固定字段
  */
 type Logger struct {
-	logMap     map[string]*LoggerInfo
-	suffixInfo string
-	logLevel   int // 需要记录的日志级别
+	*logCore
+	fields     Fields // 每条记录都会附带的字段，只读
+	callerSkip int    // 获取调用位置时额外跳过的层数，参考AddCallerSkip
+	name       string // 模块名称，参考Named
+}
+
+// logCore 日志对象共享的状态
+type logCore struct {
+	logMap       map[string]*LoggerInfo
+	filename     string // 级别日志文件名前缀
+	backupDir    string // 日志备份目录
+	suffixInfo   string
+	levels       map[string]int    // 已注册的日志级别及其严重程度
+	minSeverity  int               // 需要记录的最低严重程度
+	ioTimeout    time.Duration     // 文件写入超时时间，0表示不限制
+	alerts       *alertEngine      // 日志告警规则
+	reporter     *reporter         // 内部错误回调以及计数
+	encoder      Encoder           // 日志编码方式，默认为TextEncoder
+	spoolDir     string            // 溢出文件目录，为空表示不开启
+	spoolSize    int64             // 溢出文件大小上限
+	tenancy      *tenancy          // 租户日志隔离，nil表示不开启
+	rotation     RotationPolicy    // 日志切分策略
+	compression  Compression       // 切分文件的压缩方式
+	retention    *RetentionManager // 备份清理，nil表示不开启
+	uploader     *backupUploader   // 备份上传，nil表示不开启
+	throttle     Throttle          // 后台任务节流，nil表示不开启
+	wals         *walSet           // 通道的预写日志，nil表示不开启
+	diskGuard    *diskGuard        // 磁盘空间检查，nil表示不开启
+	createDirs   bool              // 自动创建日志文件所在目录
+	nameTemplate string            // 级别文件以及通道文件的文件名模板，为空表示默认的命名，参考WithFileNameTemplate
+	owner        *fileOwner        // 新建文件以及目录的属主，nil表示不修改
+	sealer       *sealer           // 日志文件加密，nil表示不加密
+	slowFlush    time.Duration     // 慢写入告警阈值，0表示不告警
+	modules      map[string]int    // 模块单独设置的最低严重程度，参考SetModuleLevel
+	initErr      error             // 选项中的配置错误，NewLogger返回该错误
+	watcher      *configWatcher    // 配置文件热更新，参考WatchConfig
+	closers      []io.Closer       // 配置文件中创建的sink，Close时关闭
+	flushEvery   time.Duration     // buffer写入队列的间隔，0表示默认值
+	queueSize    int               // 写入队列长度，0表示默认值
+	sinks        []*sink           // 额外的输出
+	encodedSinks atomic.Value      // []*sink，使用单独编码器的sink，参考AddEncodedSink
+	noFiles      bool              // 不写日志文件，只输出到sink
+	singleFile   bool              // 所有级别写入同一个文件
+	extractor    ContextExtractor  // 从context中提取附加字段
+	sampler      *sampler          // 日志抽样以及限流，nil表示不开启
+	deduper      *deduper          // 连续重复消息合并，nil表示不开启
+	overflow     OverflowPolicy    // 写入队列满时的处理方式
+	maxRecord    int               // 单条记录的最大字节数，0表示不限制
+	recordPolicy RecordPolicy      // 超过maxRecord时的处理方式
+	bufferSize   int               // buffer的初始容量，0表示默认值
+	fileMode     os.FileMode       // 日志文件权限，0表示默认值
+	dirMode      os.FileMode       // 目录权限，0表示默认值
+	syncWrites   bool              // 每条记录立即进入写入队列
+	syncLevels   map[string]bool   // 同步写入的级别，参考WithSyncLevels
+	timeLayout   string            // 记录的时间格式，为空表示编码器的默认格式
+	utc          bool              // 记录的时间使用UTC
+	callers      map[string]bool   // 记录调用位置的级别，nil表示默认的debug以及trace
+	stack        *stackTrace       // 记录调用栈的级别，nil表示不记录
+	cascade      map[string]bool   // 接收高级别记录的级别，nil表示不开启，为空表示所有级别
+	hooks        atomic.Value      // []*hook，编码之前执行的处理函数
+	rotateHooks  *rotateHooks      // 切分以及备份完成之后的回调，参考OnRotate
+	toggles      debugToggles      // 运行时按照模块开启的调试功能，参考SetDebugToggle
 	sync.RWMutex
 }
 
 // LoggerInfo is logger info struct
 type LoggerInfo struct {
 	filename       string
+	level          string // 日志级别，通过Write写入的自定义文件为空
 	bufferInfoLock sync.RWMutex
 	buffer         *LoggerBuffer
-	bufferQueue    chan LoggerBuffer
+	bufferQueue    chan queuedBuffer
+	rotateQueue    chan chan struct{}
+	relocateQueue  chan relocateRequest
+	flushQueue     chan chan struct{}
+	syncQueue      chan syncRequest
+	cascadeQueue   chan []byte // 高级别日志文件转发的内容，参考WithCascade
+	backupQueue    chan backupRequest
+	archiveQueue   chan archiveTask
+	closeChan      chan struct{} // Close时关闭，通知写入协程退出
+	writerDone     chan struct{} // WriteBufferToQueue退出时关闭
+	flusherDone    chan struct{} // FlushBufferQueue退出时关闭
+	archiverDone   chan struct{} // runArchiver退出时关闭
+	closeOnce      sync.Once
+	closed         bool // 是否已经关闭，受bufferInfoLock保护
 	fsyncInterval  time.Duration
 	hour           time.Time
 	fileOrder      int
 	logFile        *os.File
 	backupDir      string
+	ioTimeout      int64 // 文件写入超时时间(纳秒)，原子操作访问
+	ioQueue        chan ioRequest
+	ioWorkerOnce   sync.Once
+	alerts         *alertEngine
+	reporter       *reporter
+	stalledSince   int64           // 文件写入卡住的开始时间(unix纳秒)，0表示未卡住
+	spool          *spool          // 写入队列满时使用的溢出文件，nil表示不开启
+	overflow       OverflowPolicy  // 写入队列满时的处理方式
+	fileMode       os.FileMode     // 日志文件权限
+	dirMode        os.FileMode     // 备份目录权限
+	syncWrites     bool            // 每条记录立即进入写入队列
+	rotation       RotationPolicy  // 日志切分策略
+	compression    Compression     // 切分文件的压缩方式
+	throttle       Throttle        // 压缩之前的节流，nil表示不开启
+	sinks          atomic.Value    // []*sink，除日志文件之外的输出
+	severity       int             // 级别的严重程度，传给LevelWriter
+	noFile         bool            // 不写日志文件，写入os.DevNull
+	combined       bool            // 所有级别共用的日志文件
+	naming         string          // 切分文件名模板，包含{date}以及{seq}，为空表示 filename.时间段.序号
+	backupSub      string          // 备份时日期目录下的子目录，与文件名模板中的目录相同
+	diskGuard      *diskGuard      // 写入返回空间不足时通知检查
+	createDirs     bool            // 创建文件时自动创建所在目录
+	owner          *fileOwner      // 新建文件以及目录的属主
+	sealer         *sealer         // 写入文件之前加密，nil表示不加密
+	slowFlush      time.Duration   // 慢写入告警阈值，0表示不告警
+	slowReported   time.Time       // 上一次慢写入告警的时间，只在FlushBufferQueue协程中访问
+	slowSuppressed uint64          // 上一次告警之后的慢写入次数，只在FlushBufferQueue协程中访问
+	cascadeTo      atomic.Value    // []*LoggerInfo，开启WithCascade时同时写入的低级别日志文件
+	rotateHooks    *rotateHooks    // 切分以及备份完成之后的回调
+	uploader       *backupUploader // 备份之后上传，nil表示不开启
 }
 
 const (
@@ -59,12 +165,14 @@ const (
 	maxFileSize       = 2 * GB
 	maxFileCount      = 10
 	defaultBufferSize = 2 * KB
+	defaultQueueSize  = 50000
 )
 
 // LoggerBuffer is logger buffer struct
 type LoggerBuffer struct {
 	bufferLock    sync.RWMutex
 	bufferContent *bytes.Buffer
+	size          int // 重新分配时的初始容量
 }
 
 // NewLogger creates new logger object
@@ -74,82 +182,223 @@ type LoggerBuffer struct {
  * @param filename: 日志文件名
  * @param suffix: 每条日志记录可能会追加的信息
  * @param backupDir: 日志备份目录
+ * @param opts: 可选配置，例如WithEncoder
  * @return 成功则返回(*Logger, nil)；否则返回 (nil, error)
  */
-func NewLogger(filename, suffix, backupDir string) (*Logger, error) {
-	var err error
-	var loggerInfo *LoggerInfo
-	logMap := make(map[string]*LoggerInfo)
-	for _, level := range logLevel {
-		if loggerInfo, err = newLoggerInfo(filename, level); err != nil {
+func NewLogger(filename, suffix, backupDir string, opts ...Option) (*Logger, error) {
+	logger := &Logger{logCore: &logCore{
+		logMap:      make(map[string]*LoggerInfo),
+		filename:    filename,
+		backupDir:   backupDir,
+		suffixInfo:  suffix,
+		levels:      builtinLevels(),
+		alerts:      &alertEngine{},
+		reporter:    &reporter{},
+		encoder:     TextEncoder{},
+		rotateHooks: &rotateHooks{},
+	}}
+	for _, opt := range opts {
+		opt(logger)
+	}
+	if logger.initErr != nil {
+		return nil, logger.initErr
+	}
+	logger.storeEncodedSinks()
+	if encoder, ok := logger.encoder.(TextEncoder); ok && logger.cascade != nil && !logger.singleFile {
+		// 低级别文件中包含高级别的记录，需要级别标记区分
+		encoder.LevelTag = true
+		logger.encoder = encoder
+	}
+	if logger.singleFile {
+		if err := logger.startSingleFile(); err != nil {
 			return nil, err
 		}
-
-		loggerInfo.backupDir = backupDir
-		go loggerInfo.WriteBufferToQueue()
-		go loggerInfo.FlushBufferQueue()
-		logMap[level] = loggerInfo
+	} else {
+		for _, level := range logLevel {
+			loggerInfo, err := logger.startLoggerInfo(filename, level, backupDir)
+			if err != nil {
+				return nil, err
+			}
+			logger.logMap[level] = loggerInfo
+		}
+		logger.updateCascade()
+	}
+	if logger.tenancy != nil {
+		go logger.tenancy.run(logger)
+	}
+	if logger.retention != nil {
+		logger.retention.SetThrottle(logger.throttle)
+		logger.retention.Start()
+	}
+	if logger.uploader != nil {
+		logger.uploader.throttle = logger.throttle
+	}
+	if logger.sampler != nil {
+		go logger.runSampler()
+	}
+	if logger.deduper != nil {
+		go logger.runDedup()
+	}
+	if logger.diskGuard != nil {
+		go logger.runDiskGuard()
+	}
+	if logger.wals != nil {
+		logger.startWAL()
 	}
-
-	logger := &Logger{logMap: logMap, suffixInfo: suffix}
 	return logger, nil
 }
 
 /*
  * 写日志，根据filename重新创建一个LoggerInfo，主要是针对自定义文件
+ * 文件名按原样使用，不备份；新代码建议使用Channel，与级别文件的命名、备份以及关闭方式一致
  * @param filename：文件名
  * @param suffix：是否需要后缀信息
  * @param args：写入的内容
  */
 func (logger *Logger) Write(filename string, suffix bool, args ...interface{}) {
-	var loggerInfo *LoggerInfo
-	var err error
-	var Ok bool
-	// 不存在需要重新初始化一下
-	logger.Lock()
-	defer logger.Unlock()
-	if loggerInfo, Ok = logger.logMap[filename]; !Ok {
-		if loggerInfo, err = newLoggerInfo(filename, ""); err != nil {
-			println("[NewLoggerInfo] Write : " + err.Error())
-			return
+	logger.RLock()
+	loggerInfo := logger.logMap[filename]
+	logger.RUnlock()
+	if loggerInfo == nil {
+		// 不存在需要重新初始化一下
+		logger.Lock()
+		if loggerInfo = logger.logMap[filename]; loggerInfo == nil {
+			var err error
+			if loggerInfo, err = logger.startLoggerInfo(filename, "", ""); err != nil {
+				logger.Unlock()
+				logger.reporter.report("Write.NewLoggerInfo", err)
+				return
+			}
+			logger.logMap[filename] = loggerInfo
+		}
+		logger.Unlock()
+	}
+	loggerInfo.Write(logger.encode("", "", suffix, args, nil))
+}
+
+// Rotate forces all log files to be rotated and backed up immediately
+/*
+ * 立即切分并备份所有日志文件，包括通过Write写入的自定义文件
+ * 适用于收集诊断信息之前，或者日志文件在整点之前异常增长的场景
+ */
+func (logger *Logger) Rotate() {
+	for _, loggerInfo := range logger.infos() {
+		loggerInfo.Rotate()
+	}
+}
+
+// Flush writes all buffered content of every log file to disk
+/*
+ * 将所有日志文件buffer中的数据立即写入硬盘，阻塞直到完成
+ */
+func (logger *Logger) Flush() {
+	for _, loggerInfo := range logger.infos() {
+		loggerInfo.Flush()
+	}
+}
+
+// Close flushes all log files and stops the writer goroutines
+/*
+ * 进程退出之前调用，写入所有剩余日志并关闭文件，结束所有写入协程
+ * 关闭之后写入的日志会被丢弃
+ */
+func (logger *Logger) Close() {
+	if logger.tenancy != nil {
+		logger.tenancy.close()
+	}
+	if logger.retention != nil {
+		logger.retention.Stop()
+	}
+	if logger.diskGuard != nil {
+		logger.diskGuard.close()
+	}
+	if logger.deduper != nil {
+		logger.deduper.close()
+	}
+	if logger.sampler != nil {
+		logger.sampler.close()
+	}
+	logger.RLock()
+	watcher := logger.watcher
+	logger.RUnlock()
+	if watcher != nil {
+		watcher.close()
+	}
+	if logger.wals != nil {
+		logger.closeWAL()
+	}
+	for _, loggerInfo := range logger.infos() {
+		loggerInfo.Close()
+	}
+	if logger.uploader != nil {
+		// 日志文件全部关闭之后不会再有新的备份文件
+		logger.uploader.close()
+	}
+	for _, c := range logger.closers {
+		c.Close()
+	}
+}
+
+/*
+ * 获取所有LoggerInfo的快照，避免在持有锁的情况下执行阻塞操作
+ * 单文件模式下多个级别共用的LoggerInfo只返回一次
+ */
+func (logger *Logger) infos() []*LoggerInfo {
+	logger.RLock()
+	defer logger.RUnlock()
+	return logger.uniqueInfos()
+}
+
+/*
+ * 获取所有不重复的LoggerInfo，按照严重程度从高到低排列，调用方需要持有锁
+ * 高级别文件先flush以及关闭，开启WithCascade时转发的内容可以在低级别文件flush时一起写入
+ */
+func (logger *logCore) uniqueInfos() []*LoggerInfo {
+	seen := make(map[*LoggerInfo]bool, len(logger.logMap))
+	infos := make([]*LoggerInfo, 0, len(logger.logMap))
+	for _, loggerInfo := range logger.logMap {
+		if !seen[loggerInfo] {
+			seen[loggerInfo] = true
+			infos = append(infos, loggerInfo)
 		}
-		go loggerInfo.WriteBufferToQueue()
-		go loggerInfo.FlushBufferQueue()
-		logger.logMap[filename] = loggerInfo
 	}
-	loggerInfo.Write(Format(suffix, logger.suffixInfo, args...))
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].severity > infos[j].severity
+	})
+	return infos
 }
 
+// SetLevel sets the minimum level by index of debug/trace/warn/error, see also SetLevelString
 /*
- * 设置记录级别
- * @param l：记录级别，0最低，所有日志都记录，3表示只记录error日志
+ * 按照下标设置记录级别，下标对应debug(0)/trace(1)/warn(2)/error(3)，下标越大记录的越少
+ * @param l：记录级别，0最低，所有日志都记录，3表示只记录error日志，>=4时内置级别都不记录
+ *          严重程度不低于对应内置级别的自定义级别同样会被记录，按名称设置请使用SetMinLevel
  */
 func (logger *Logger) SetLevel(l int) {
 	logger.Lock()
 	defer logger.Unlock()
-	if l > len(logLevel) {
-		logger.logLevel = len(logLevel)
-	} else {
-		logger.logLevel = l
+	switch {
+	case l <= 0:
+		logger.minSeverity = 0
+	case l >= len(logLevel):
+		logger.minSeverity = SeverityError + 1
+	default:
+		logger.minSeverity = logger.levels[logLevel[l]]
 	}
 }
 
 /*
- * 检查记录级别
+ * 检查记录级别，调用方需要持有读锁，模块子日志对象按照模块级别检查
  * @param logType：需要检查的日志类别
  * @return 返回true表示当前需要记录该级别日志类型的日志；否则不需要
  */
 func (logger *Logger) CheckLevel(logType string) bool {
-	if logger.logLevel <= 0 {
+	minSeverity := logger.minLevel()
+	if minSeverity <= 0 {
 		return true
 	}
-	logSet := logLevel[logger.logLevel:]
-	for _, v := range logSet {
-		if logType == v {
-			return true
-		}
-	}
-	return false
+	severity, ok := logger.levels[logType]
+	return ok && severity >= minSeverity
 }
 
 /*
@@ -164,19 +413,10 @@ func (logger *Logger) Debug(args ...interface{}) {
 	if !d {
 		return
 	}
-
-	pc, file, line, ok := runtime.Caller(1)
-	if ok {
-		funcName := ""
-		if funcObj := runtime.FuncForPC(pc); funcObj != nil {
-			funcName = funcObj.Name()
-		}
-		file = file[strings.Index(file, "src/"):]
-		content := []interface{}{fmt.Sprintf("%v,%v:%v", file, line, funcName)}
-		args = append(content, args...)
+	if loggerInfo = logger.route("debug", loggerInfo, nil); loggerInfo == nil || !logger.dedupe(loggerInfo, "debug", args, nil) || !logger.sampleArgs("debug", args) {
+		return
 	}
-
-	loggerInfo.Write(Format(true, logger.suffixInfo, args...))
+	logger.write(loggerInfo, "debug", logger.encode("debug", logger.callerAt("debug", 1), true, args, nil))
 }
 
 func (logger *Logger) Trace(args ...interface{}) {
@@ -187,18 +427,10 @@ func (logger *Logger) Trace(args ...interface{}) {
 	if !d {
 		return
 	}
-
-	pc, file, line, ok := runtime.Caller(1)
-	if ok {
-		funcName := ""
-		if funcObj := runtime.FuncForPC(pc); funcObj != nil {
-			funcName = funcObj.Name()
-		}
-		file = file[strings.Index(file, "src/"):]
-		content := []interface{}{fmt.Sprintf("%v,%v:%v", file, line, funcName)}
-		args = append(content, args...)
+	if loggerInfo = logger.route("trace", loggerInfo, nil); loggerInfo == nil || !logger.dedupe(loggerInfo, "trace", args, nil) || !logger.sampleArgs("trace", args) {
+		return
 	}
-	loggerInfo.Write(Format(true, logger.suffixInfo, args...))
+	logger.write(loggerInfo, "trace", logger.encode("trace", logger.callerAt("trace", 1), true, args, nil))
 }
 
 func (logger *Logger) Warn(args ...interface{}) {
@@ -209,7 +441,10 @@ func (logger *Logger) Warn(args ...interface{}) {
 	if !d {
 		return
 	}
-	loggerInfo.Write(Format(true, logger.suffixInfo, args...))
+	if loggerInfo = logger.route("warn", loggerInfo, nil); loggerInfo == nil || !logger.dedupe(loggerInfo, "warn", args, nil) || !logger.sampleArgs("warn", args) {
+		return
+	}
+	logger.write(loggerInfo, "warn", logger.encode("warn", logger.callerAt("warn", 1), true, args, nil))
 }
 
 func (logger *Logger) Error(args ...interface{}) {
@@ -220,43 +455,202 @@ func (logger *Logger) Error(args ...interface{}) {
 	if !d {
 		return
 	}
-	loggerInfo.Write(Format(true, logger.suffixInfo, args...))
+	if loggerInfo = logger.route("error", loggerInfo, nil); loggerInfo == nil || !logger.dedupe(loggerInfo, "error", args, nil) || !logger.sampleArgs("error", args) {
+		return
+	}
+	logger.write(loggerInfo, "error", logger.encode("error", logger.callerAt("error", 1), true, args, nil))
+}
+
+/*
+ * 以下四个函数按照format格式化之后写入不同的日志类型
+ * 格式化在级别检查之后进行，未开启的级别不会调用fmt.Sprintf
+ * @param format：格式，与fmt.Sprintf相同
+ * @param args：格式化参数
+ */
+func (logger *Logger) Debugf(format string, args ...interface{}) {
+	if loggerInfo := logger.enabled("debug"); loggerInfo != nil {
+		logger.writef(loggerInfo, "debug", logger.callerAt("debug", 1), format, args)
+	}
+}
+
+func (logger *Logger) Tracef(format string, args ...interface{}) {
+	if loggerInfo := logger.enabled("trace"); loggerInfo != nil {
+		logger.writef(loggerInfo, "trace", logger.callerAt("trace", 1), format, args)
+	}
+}
+
+func (logger *Logger) Warnf(format string, args ...interface{}) {
+	if loggerInfo := logger.enabled("warn"); loggerInfo != nil {
+		logger.writef(loggerInfo, "warn", logger.callerAt("warn", 1), format, args)
+	}
+}
+
+func (logger *Logger) Errorf(format string, args ...interface{}) {
+	if loggerInfo := logger.enabled("error"); loggerInfo != nil {
+		logger.writef(loggerInfo, "error", logger.callerAt("error", 1), format, args)
+	}
+}
+
+/*
+ * 格式化之后写入，经过重复消息合并以及抽样
+ */
+func (logger *Logger) writef(loggerInfo *LoggerInfo, level, at, format string, args []interface{}) {
+	content := []interface{}{fmt.Sprintf(format, args...)}
+	if !logger.dedupe(loggerInfo, level, content, nil) || !logger.sampleMessage(level, format) {
+		return
+	}
+	logger.write(loggerInfo, level, logger.encode(level, at, true, content, nil))
+}
+
+/*
+ * 检查内置级别是否需要记录
+ * @param level：内置日志级别
+ * @return 需要记录时返回写入的LoggerInfo；否则返回nil
+ */
+func (logger *Logger) enabled(level string) *LoggerInfo {
+	logger.RLock()
+	loggerInfo := logger.logMap[level]
+	d := logger.CheckLevel(level)
+	logger.RUnlock()
+	if !d {
+		return nil
+	}
+	return logger.route(level, loggerInfo, nil)
 }
 
 /*
  * 构建一个LoggerInfo对象
- * @param filename：日志文件名信息
+ * @param filename：日志文件名
  * @param level：日志级别
+ * @param noFile：不写日志文件
+ * @param fileMode：日志文件权限
+ * @param owner：日志文件属主，为nil时不修改
  * @return 成功则返回(*LoggerInfo, nil)；否则返回(nil, error)
  */
-func newLoggerInfo(filename, level string) (*LoggerInfo, error) {
+func newLoggerInfo(filename, level string, noFile bool, fileMode os.FileMode, owner *fileOwner) (*LoggerInfo, error) {
 	var err error
 	loggerInfo := &LoggerInfo{
-		bufferQueue:   make(chan LoggerBuffer, 50000),
+		bufferQueue:   make(chan queuedBuffer, defaultQueueSize),
+		rotateQueue:   make(chan chan struct{}),
+		relocateQueue: make(chan relocateRequest),
+		flushQueue:    make(chan chan struct{}),
+		syncQueue:     make(chan syncRequest),
+		cascadeQueue:  make(chan []byte, cascadeQueueSize),
+		backupQueue:   make(chan backupRequest),
+		archiveQueue:  make(chan archiveTask, archiveQueueSize),
+		closeChan:     make(chan struct{}),
+		writerDone:    make(chan struct{}),
+		flusherDone:   make(chan struct{}),
+		archiverDone:  make(chan struct{}),
+		ioQueue:       make(chan ioRequest),
 		fsyncInterval: time.Second,
 		buffer:        NewLoggerBuffer(),
+		noFile:        noFile,
+		fileMode:      fileMode,
+		owner:         owner,
+		dirMode:       defaultDirMode,
 		fileOrder:     0,
 		backupDir:     "",
 	}
 
-	t, _ := time.Parse(HOURFORMAT, time.Now().Format(HOURFORMAT))
+	t, _ := time.Parse(HOURFORMAT, rotationClock().Format(HOURFORMAT))
 	loggerInfo.hour = t
 
-	// 直接调用write写日志的文件名，用原始的文件名
-	if len(level) == 0 {
-		loggerInfo.filename = filename
-	} else {
-		loggerInfo.filename = filename + "-" + level + ".log"
-	}
+	loggerInfo.level = level
+	loggerInfo.filename = filename
 
 	err = loggerInfo.CreateFile()
 	if err != nil {
-		println("[NewLogger] openfile error : " + err.Error())
+		if loggerInfo.logFile != nil {
+			loggerInfo.logFile.Close()
+		}
 		return nil, err
 	}
 	return loggerInfo, nil
 }
 
+/*
+ * 创建LoggerInfo并启动写入协程，按照logger的配置设置告警、溢出文件以及写入超时
+ * 调用方需要持有写锁
+ * @param filename：级别文件为NewLogger的filename(租户文件为租户目录下的同名文件)，按照级别以及文件名模板生成文件名；自定义文件为完整的文件名
+ * @param level：日志级别，自定义文件为空
+ * @param backupDir：备份目录，为空表示不备份
+ * @return 成功则返回(*LoggerInfo, nil)；否则返回(nil, error)
+ */
+func (logger *logCore) startLoggerInfo(filename, level, backupDir string) (*LoggerInfo, error) {
+	if level == "" {
+		// 直接调用Write写日志的自定义文件，用原始的文件名
+		return logger.startFileInfo(fileNaming{filename: filename}, "", backupDir)
+	}
+	return logger.startFileInfo(logger.levelFile(filename, level), level, backupDir)
+}
+
+/*
+ * 创建LoggerInfo并启动写入协程，调用方需要持有写锁
+ * @param naming：文件名、切分文件名模板以及模板中的子目录，参考levelFile
+ * @param level：日志级别，通道以及自定义文件为空
+ * @param backupDir：备份目录，为空表示不备份
+ * @return 成功则返回(*LoggerInfo, nil)；否则返回(nil, error)
+ */
+func (logger *logCore) startFileInfo(naming fileNaming, level, backupDir string) (*LoggerInfo, error) {
+	filename := naming.filename
+	// 模板包含目录时按需创建级别以及通道的子目录
+	createDirs := logger.createDirs || naming.subDir != ""
+	if createDirs && !logger.noFiles {
+		if err := mkdirAll(filepath.Dir(filename), logger.logDirMode(), logger.owner); err != nil {
+			return nil, err
+		}
+	}
+	loggerInfo, err := newLoggerInfo(filename, level, logger.noFiles, logger.logFileMode(), logger.owner)
+	if err != nil {
+		return nil, err
+	}
+	loggerInfo.backupDir = backupDir
+	loggerInfo.naming = naming.rotated
+	loggerInfo.backupSub = naming.subDir
+	loggerInfo.rotation = logger.rotation
+	if logger.flushEvery > 0 {
+		loggerInfo.fsyncInterval = logger.flushEvery
+	}
+	if logger.queueSize > 0 {
+		loggerInfo.bufferQueue = make(chan queuedBuffer, logger.queueSize)
+	}
+	loggerInfo.overflow = logger.overflow
+	loggerInfo.dirMode = logger.logDirMode()
+	loggerInfo.createDirs = createDirs
+	loggerInfo.syncWrites = logger.syncWrites
+	loggerInfo.buffer = newLoggerBuffer(logger.bufferSize)
+	loggerInfo.compression = logger.compression
+	loggerInfo.throttle = logger.throttle
+	if logger.compression != CompressNone {
+		loggerInfo.removePartialArchives()
+	}
+	if period := logger.rotation.period(rotationClock()); !period.IsZero() {
+		// 不按时间切分时沿用创建时的小时，作为按大小切分的文件名
+		loggerInfo.hour = period
+	}
+	loggerInfo.alerts = logger.alerts
+	loggerInfo.reporter = logger.reporter
+	loggerInfo.rotateHooks = logger.rotateHooks
+	loggerInfo.uploader = logger.uploader
+	loggerInfo.diskGuard = logger.diskGuard
+	loggerInfo.sealer = logger.sealer
+	loggerInfo.slowFlush = logger.slowFlush
+	loggerInfo.severity = logger.levels[level]
+	loggerInfo.setSinks(logger.sinks)
+	if logger.spoolDir != "" {
+		if loggerInfo.spool, err = openSpool(logger.spoolDir, loggerInfo.filename, logger.spoolSize, logger.reporter); err != nil {
+			loggerInfo.logFile.Close()
+			return nil, err
+		}
+	}
+	loggerInfo.SetIOTimeout(logger.ioTimeout)
+	go loggerInfo.WriteBufferToQueue()
+	go loggerInfo.FlushBufferQueue()
+	go loggerInfo.runArchiver()
+	return loggerInfo, nil
+}
+
 /*
  * 获取文件大小，如果文件不存在则重新创建文件
  * 则文件指针指向错误，重新open一下文件
@@ -271,11 +665,24 @@ func (logger *LoggerInfo) FileSize() (int64, error) {
 }
 
 /*
- * 创建文件
+ * 创建文件，开启WithCreateDirs时先创建所在目录
+ * 修改属主失败时文件仍然可以写入，只返回error
  */
 func (this *LoggerInfo) CreateFile() error {
 	var err error
-	this.logFile, err = os.OpenFile(this.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0777)
+	if this.noFile {
+		this.logFile, err = os.OpenFile(os.DevNull, os.O_WRONLY|os.O_APPEND|os.O_CREATE, this.fileMode)
+		return err
+	}
+	if this.createDirs {
+		if err = mkdirAll(filepath.Dir(this.filename), this.dirMode, this.owner); err != nil {
+			return err
+		}
+	}
+	var file *os.File
+	if file, err = openLogFile(this.filename, this.fileMode, this.owner); file != nil {
+		this.logFile = file
+	}
 	return err
 }
 
@@ -283,7 +690,10 @@ func (this *LoggerInfo) CreateFile() error {
  * 判断文件是否需要切分
  */
 func (logger *LoggerInfo) NeedSplit() (split bool, backup bool) {
-	t, _ := time.Parse(HOURFORMAT, time.Now().Format(HOURFORMAT))
+	if logger.noFile {
+		return false, false
+	}
+	t := logger.rotation.period(rotationClock())
 	if t.After(logger.hour) {
 		return false, true
 	} else {
@@ -294,19 +704,20 @@ func (logger *LoggerInfo) NeedSplit() (split bool, backup bool) {
 		 */
 		if size, err := logger.FileSize(); err != nil {
 			if os.IsNotExist(err) {
-				/* 文件不存在，重新创建文件 */
-				println("[NeedSplit] FileSize: " + err.Error())
+				/* 文件不存在，关闭已经被删除的文件之后重新创建 */
+				logger.reporter.report("NeedSplit.FileSize", err)
+				logger.logFile.Close()
 				if err = logger.CreateFile(); err != nil {
-					println("[NeedSplit] CreateFile : " + err.Error())
+					logger.reporter.report("NeedSplit.CreateFile", err)
 				}
 				return false, false
 			} else {
 				/* 如果不是文件不存在错误，不做处理*/
-				println("[NeedSplit] FileSize: " + err.Error())
+				logger.reporter.report("NeedSplit.FileSize", err)
 				return false, false
 			}
 		} else {
-			if size > maxFileSize {
+			if maxSize := logger.rotation.maxSize(); maxSize > 0 && size > maxSize {
 				return true, false
 			}
 		}
@@ -317,194 +728,394 @@ func (logger *LoggerInfo) NeedSplit() (split bool, backup bool) {
 
 func (logger *LoggerInfo) Write(content string) {
 	logger.bufferInfoLock.Lock()
-	logger.buffer.WriteString(content)
+	closed := logger.closed
+	if !closed {
+		logger.buffer.WriteString(content)
+	} else {
+		logger.reporter.drop()
+	}
 	logger.bufferInfoLock.Unlock()
+	if logger.syncWrites && !closed {
+		logger.enqueueBuffer()
+	}
 }
 
 /*
  * 将buffer中的数据写到队列中等待flush协程写入到硬盘
+ * Close时退出，退出前将buffer中剩余的数据写入队列
  */
 func (logger *LoggerInfo) WriteBufferToQueue() {
 	ticker := time.NewTicker(logger.fsyncInterval)
 	defer ticker.Stop()
 	for {
-		<-ticker.C
-		logger.bufferInfoLock.RLock()
+		select {
+		case <-ticker.C:
+			logger.enqueueBuffer()
+		case <-logger.closeChan:
+			logger.enqueueBuffer()
+			close(logger.writerDone)
+			return
+		}
+	}
+}
+
+/*
+ * 将当前buffer中的数据写入队列
+ */
+func (logger *LoggerInfo) enqueueBuffer() {
+	logger.bufferInfoLock.RLock()
+	switch {
+	case logger.spool != nil:
+		logger.buffer.writeBufferOrSpool(logger.bufferQueue, logger.spool, logger.overflow, logger.reporter)
+	case logger.overflow != OverflowBlock:
+		logger.buffer.writeBufferWithPolicy(logger.bufferQueue, logger.overflow, logger.reporter)
+	default:
 		logger.buffer.WriteBuffer(logger.bufferQueue)
-		logger.bufferInfoLock.RUnlock()
 	}
+	logger.bufferInfoLock.RUnlock()
 }
 
 /*
- * 将buffer中的数据flush到硬盘
+ * 将buffer中的数据flush到硬盘，日志文件的切分、重命名以及重新创建只在该协程中进行
+ * Close时在写入队列中剩余的数据之后关闭文件，等待归档协程处理完切分文件之后退出
  */
 func (logger *LoggerInfo) FlushBufferQueue() {
 	for {
 		select {
 		case buffer := <-logger.bufferQueue:
-			/* 需要做文件切分 */
-			isSplit, isBackup := logger.NeedSplit()
-			if isSplit {
-				logger.logFile.Close()
-				newFilename := logger.filename + "." + logger.hour.Format(HOURFORMAT) + "." + strconv.Itoa(logger.fileOrder%maxFileCount)
-				_, fileErr := os.Stat(newFilename)
-				if fileErr == nil {
-					os.Remove(newFilename)
-				}
-				err := os.Rename(logger.filename, newFilename)
-				if err != nil {
-					println("[FlushBufferQueue] Rename : " + err.Error())
-				}
-				if err = logger.CreateFile(); err != nil {
-					println("[FlushBufferQueue] CreateFile : " + err.Error())
-				}
+			logger.flushQueued(buffer)
+			logger.replaySpool()
 
-				logger.fileOrder++
-				if isBackup {
-					logger.fileOrder = 0
-					go logger.LoggerBackup(logger.hour)
-					logger.hour, _ = time.Parse(HOURFORMAT, time.Now().Format(HOURFORMAT))
-				}
-			} else {
-				if isBackup {
-					logger.logFile.Close()
-
-					var newFilename string
-					if logger.fileOrder == 0 {
-						newFilename = logger.filename + "." + logger.hour.Format(HOURFORMAT)
-					} else {
-						newFilename = logger.filename + "." + logger.hour.Format(HOURFORMAT) + "." + strconv.Itoa(logger.fileOrder%maxFileCount)
-					}
-
-					_, fileErr := os.Stat(newFilename)
-					if fileErr == nil {
-						os.Remove(newFilename)
-					}
-					err := os.Rename(logger.filename, newFilename)
-					if err != nil {
-						println("[FlushBufferQueue] Rename : " + err.Error())
-					}
-					if err = logger.CreateFile(); err != nil {
-						println("[FlushBufferQueue] CreateFile : " + err.Error())
-					}
-
-					logger.fileOrder = 0
-					go logger.LoggerBackup(logger.hour)
-					logger.hour, _ = time.Parse(HOURFORMAT, time.Now().Format(HOURFORMAT))
-				}
+		case <-logger.spoolReady():
+			logger.replaySpool()
+
+		case done := <-logger.rotateQueue:
+			logger.forceRotate()
+			close(done)
+
+		case req := <-logger.backupQueue:
+			logger.scheduleBackup(req.hour, req.done)
+
+		case req := <-logger.relocateQueue:
+			logger.switchFile(req)
+
+		case done := <-logger.flushQueue:
+			logger.drainQueue()
+			close(done)
+
+		case req := <-logger.syncQueue:
+			logger.drainQueue()
+			req.done <- logger.flushBuffer(req.content)
+
+		case content := <-logger.cascadeQueue:
+			logger.writeFile(content)
+
+		case <-logger.writerDone:
+			logger.drainQueue()
+			if logger.spool != nil {
+				logger.spool.close()
 			}
+			logger.logFile.Close()
+			/* doIO只在本协程中调用，之后不会再有io请求，关闭队列让io协程退出 */
+			close(logger.ioQueue)
+			close(logger.archiveQueue)
+			<-logger.archiverDone
+			close(logger.flusherDone)
+			return
+		}
+	}
+}
 
-			/* 写失败的话尝试再写一次 */
-			if _, err := logger.logFile.Write(buffer.bufferContent.Bytes()); err != nil {
-				println("[FlushBufferQueue] File.Write : " + err.Error())
-				logger.logFile.Write(buffer.bufferContent.Bytes())
+/*
+ * 将队列以及溢出文件中已有的数据全部写入文件，只能在FlushBufferQueue协程中调用
+ */
+func (logger *LoggerInfo) drainQueue() {
+	for {
+		select {
+		case buffer := <-logger.bufferQueue:
+			logger.flushQueued(buffer)
+		case content := <-logger.cascadeQueue:
+			logger.writeFile(content)
+		default:
+			logger.replaySpool()
+			if len(logger.bufferQueue) == 0 {
+				return
 			}
-			logger.logFile.Sync()
+		}
+	}
+}
 
+/*
+ * 将一个buffer的内容写入文件以及sink，检查告警规则，开启WithCascade时转发给低级别的日志文件
+ * @return 写入或者fsync失败时返回error，写入失败已经上报
+ */
+func (logger *LoggerInfo) flushBuffer(content []byte) error {
+	err := logger.writeFile(content)
+	logger.writeSinks(content)
+	logger.alerts.evaluate(logger.level, content)
+	logger.forwardCascade(content)
+	return err
+}
+
+/*
+ * 将内容写入文件，必要时先切分文件
+ * @return 加密、写入或者fsync失败时返回error，失败已经上报
+ */
+func (logger *LoggerInfo) writeFile(content []byte) error {
+	/* 需要做文件切分 */
+	isSplit, isBackup := logger.NeedSplit()
+	if isSplit {
+		logger.archive(logger.rotatedName(logger.hour, logger.fileOrder%logger.rotation.maxFiles()))
+
+		logger.fileOrder++
+		if isBackup {
+			logger.fileOrder = 0
+			logger.scheduleBackup(logger.hour, nil)
+			logger.hour = logger.rotation.period(rotationClock())
+		}
+	} else {
+		if isBackup {
+			seq := -1
+			if logger.fileOrder != 0 {
+				seq = logger.fileOrder % logger.rotation.maxFiles()
+			}
+			logger.archive(logger.rotatedName(logger.hour, seq))
+
+			logger.fileOrder = 0
+			logger.scheduleBackup(logger.hour, nil)
+			logger.hour = logger.rotation.period(rotationClock())
+		}
+	}
+
+	/* 写失败的话尝试再写一次，写超时不重试，避免文件系统恢复后内容重复 */
+	logFile, data := logger.logFile, content
+	var err error
+	if logger.sealer != nil && !logger.noFile {
+		if data, err = logger.sealer.seal(content); err != nil {
+			logger.reporter.writeFailed("FlushBufferQueue.Encrypt", err)
+			return err
+		}
+	} else if atomic.LoadInt64(&logger.ioTimeout) > 0 {
+		/* 写超时之后io协程仍然持有data，而content所在的buffer会被放回pool重用，需要复制一份 */
+		data = append([]byte(nil), content...)
+	}
+	err = logger.doIO(func() error {
+		_, err := logFile.Write(data)
+		return err
+	})
+	if err != nil {
+		if err != ErrIOTimeout {
+			_, err = logFile.Write(data)
 		}
+		if err != nil {
+			logger.reporter.writeFailed("FlushBufferQueue.Write", err)
+			if logger.diskGuard != nil && isNoSpace(err) {
+				logger.diskGuard.wake()
+			}
+		}
+	}
+	if err == nil {
+		logger.reporter.flush(len(content))
 	}
+	if syncErr := logger.doIO(logFile.Sync); err == nil {
+		err = syncErr
+	}
+	return err
 }
 
+// Flush writes all buffered content to disk
 /*
- * 错误日志备份
- * backupDir 待备份的目录
- * os中没有mv的函数，只能先rename，后remove
- * backupDir -> /data/servers/log/saver/trace/2014-09-10/*.log
+ * 将buffer以及队列中的数据立即写入文件并fsync，阻塞直到写入完成
+ * Close之后调用直接返回
  */
-func (logger *LoggerInfo) LoggerBackup(hour time.Time) {
-	var oldFile string   //待备份文件
-	var newFile string   //需要备份的新文件
-	var backupDir string //备份的路径
+func (logger *LoggerInfo) Flush() {
+	select {
+	case <-logger.closeChan:
+		return
+	default:
+	}
+	logger.enqueueBuffer()
+	done := make(chan struct{})
+	select {
+	case logger.flushQueue <- done:
+		<-done
+	case <-logger.flusherDone:
+	}
+}
+
+// Close flushes buffered content and stops the writer goroutines
+/*
+ * 写入所有剩余数据，关闭文件并结束WriteBufferToQueue/FlushBufferQueue协程，阻塞直到完成
+ * 关闭之后写入的数据会被丢弃，重复调用是安全的
+ */
+func (logger *LoggerInfo) Close() {
+	logger.closeOnce.Do(func() {
+		logger.bufferInfoLock.Lock()
+		logger.closed = true
+		logger.bufferInfoLock.Unlock()
+		close(logger.closeChan)
+	})
+	<-logger.flusherDone
+}
 
-	if logger.backupDir == "" {
+/*
+ * 关闭当前日志文件，将其重命名为newFilename，然后重新创建日志文件
+ * @param newFilename：切分后的文件名
+ */
+func (logger *LoggerInfo) archive(newFilename string) {
+	logger.logFile.Close()
+	_, fileErr := os.Stat(newFilename)
+	if fileErr == nil {
+		os.Remove(newFilename)
+	}
+	err := renameFile(logger.filename, newFilename)
+	if err != nil {
+		logger.reporter.report("FlushBufferQueue.Rename", err)
+	} else {
+		logger.reporter.rotate()
+		logger.afterRotate(newFilename)
+	}
+	if err = logger.CreateFile(); err != nil {
+		logger.reporter.report("FlushBufferQueue.CreateFile", err)
+	}
+}
+
+/*
+ * 立即切分当前日志文件并备份当前小时已切分的文件
+ * 只能在FlushBufferQueue协程中调用，保证与正常的切分流程不会并发
+ * fileOrder不会重置，避免与已经备份的同名文件冲突
+ */
+func (logger *LoggerInfo) forceRotate() {
+	if size, err := logger.FileSize(); err != nil || size == 0 {
+		// 文件为空或者状态异常时不需要切分
 		return
 	}
-	backupDir = filepath.Join(logger.backupDir, hour.Format(DATEFORMAT))
-	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
-		os.MkdirAll(backupDir, 0777)
+	logger.archive(logger.rotatedName(logger.hour, logger.fileOrder%logger.rotation.maxFiles()))
+	logger.fileOrder++
+	logger.scheduleBackup(logger.hour, nil)
+}
+
+// Rotate forces the log file to be rotated and backed up immediately
+/*
+ * 立即切分并备份日志文件，阻塞直到切分完成
+ * 切分前尚未进入队列的buffer内容会写入新的日志文件
+ */
+func (logger *LoggerInfo) Rotate() {
+	done := make(chan struct{})
+	select {
+	case logger.rotateQueue <- done:
+		<-done
+	case <-logger.flusherDone:
 	}
+}
 
-	/* backup filename like saver-error.log.2014-09-10*/
-	oldFile = logger.filename + "." + hour.Format(HOURFORMAT)
-	if stat, err := os.Stat(oldFile); err == nil {
-		newFile = filepath.Join(backupDir, stat.Name())
-		if err := os.Rename(oldFile, newFile); err != nil {
-			println("[LoggerBackup] os.Rename:" + err.Error())
-		}
+// LoggerBackup moves the rotated files of hour into the backup directory
+/*
+ * 错误日志备份，由归档协程在已经提交的压缩以及备份任务之后执行，阻塞直到完成
+ * os中没有mv的函数，只能先rename，后remove
+ * @param hour：需要备份的时间段
+ */
+func (logger *LoggerInfo) LoggerBackup(hour time.Time) {
+	done := make(chan struct{})
+	select {
+	case logger.backupQueue <- backupRequest{hour: hour, done: done}:
+		<-done
+	case <-logger.flusherDone:
 	}
+}
 
-	/* backup filename like saver-error.log.2014-09-10.{0/1...} */
-	for i := 0; i < maxFileCount; i++ {
-		oldFile = logger.filename + "." + hour.Format(HOURFORMAT) + "." + strconv.Itoa(i)
-		if stat, err := os.Stat(oldFile); err == nil {
-			newFile = filepath.Join(backupDir, stat.Name())
-			if err := os.Rename(oldFile, newFile); err != nil {
-				println("[LoggerBackup] os.Rename:" + err.Error())
+/*
+ * 将切分文件以及其压缩文件移动到备份目录，CompressOnBackup模式下移动之后压缩
+ * @param oldFile：切分文件名
+ * @param backupDir：备份目录
+ */
+func (logger *LoggerInfo) backupFile(oldFile, backupDir, key string) {
+	for _, name := range []string{oldFile, oldFile + gzipSuffix} {
+		stat, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		newFile := filepath.Join(backupDir, stat.Name())
+		if err := renameFile(name, newFile); err != nil {
+			logger.reporter.report("LoggerBackup.Rename", err)
+			continue
+		}
+		if name == oldFile && logger.compression == CompressOnBackup {
+			logger.throttleArchive(stat.Size())
+			if err := compressFile(newFile); err != nil {
+				logger.reporter.report("LoggerBackup.Compress", err)
+			} else {
+				newFile += gzipSuffix
 			}
 		}
+		logger.rotateHooks.notify(logger.reporter, name, newFile)
+		if logger.uploader != nil {
+			logger.uploader.enqueue(newFile, key+"/"+filepath.Base(newFile))
+		}
 	}
 }
 
 func NewLoggerBuffer() *LoggerBuffer {
-	return &LoggerBuffer{
-		bufferContent: bytes.NewBuffer(make([]byte, 0, defaultBufferSize)),
+	return newLoggerBuffer(int(defaultBufferSize))
+}
+
+/*
+ * 创建指定初始容量的buffer
+ * @param size：初始容量，<=0时使用默认值
+ */
+func newLoggerBuffer(size int) *LoggerBuffer {
+	if size <= 0 {
+		size = int(defaultBufferSize)
 	}
+	buffer := &LoggerBuffer{size: size}
+	buffer.reset()
+	return buffer
+}
+
+/*
+ * 从pool中获取新的buffer，原有的buffer已经交给写入队列或者放回pool
+ */
+func (logger *LoggerBuffer) reset() {
+	logger.bufferContent = getBuffer(logger.size)
 }
 
 func (logger *LoggerBuffer) WriteString(str string) {
 	logger.bufferContent.WriteString(str)
 }
 
-func (logger *LoggerBuffer) WriteBuffer(bufferQueue chan LoggerBuffer) {
+func (logger *LoggerBuffer) WriteBuffer(bufferQueue chan queuedBuffer) {
 	logger.bufferLock.Lock()
 	if logger.bufferContent.Len() > 0 {
-		bufferQueue <- *logger
-		logger.bufferContent = bytes.NewBuffer(make([]byte, 0, defaultBufferSize))
+		bufferQueue <- logger.queued()
+		logger.reset()
 	}
 	logger.bufferLock.Unlock()
 }
 
-func getDatetime() string {
-	return time.Now().Format("2006-01-02 15:04:05.000")
-}
-
+// Format formats args into a pipe-delimited log record
+/*
+ * 将参数格式化为一条文本格式的日志记录，格式为 时间|参数1|参数2...[|后缀信息]\n
+ * 常见类型(整数、浮点数、bool、string、[]byte、error、time.Time、fmt.Stringer)直接追加到pool中的buffer，不经过fmt.Sprintf，
+ * 常见类型的参数只有返回的string一次内存分配
+ * @param suffix：是否追加后缀信息
+ * @param suffixInfo：后缀信息
+ * @param args：日志内容
+ * @return 格式化后的日志记录
+ */
 func Format(suffix bool, suffixInfo string, args ...interface{}) string {
-	var content string
-	for _, arg := range args {
-		switch arg.(type) {
-		case int:
-			content = content + "|" + strconv.Itoa(arg.(int))
-			break
-		case string:
-			content = content + "|" + strings.TrimRight(arg.(string), "\n")
-			break
-		case int64:
-			str := strconv.FormatInt(arg.(int64), 10)
-			content = content + "|" + str
-			break
-		default:
-			content = content + "|" + fmt.Sprintf("%v", arg)
-			break
-		}
-	}
-	if suffix {
-		content = getDatetime() + content + "|" + suffixInfo + "\n"
-	} else {
-		content = getDatetime() + content + "\n"
-	}
+	entry := Entry{Time: time.Now(), Args: args, Suffix: suffixInfo, WithSuffix: suffix}
+	scratch := getScratch()
+	*scratch = TextEncoder{}.appendEntry(*scratch, &entry)
+	content := string(*scratch)
+	putScratch(scratch)
 	return content
 }
 
+// GetInnerIp returns the primary IPv4 address of the host, see netutil.InnerIP
+/*
+ * 获取本机内网IPv4地址，与netutil.InnerIP相同；需要选择网卡、网段或者IPv6地址时使用netutil.PickIP
+ * @return 地址，没有可用地址时返回空字符串
+ */
 func GetInnerIp() string {
-	info, err := net.InterfaceAddrs()
-	if err != nil {
-		return ""
-	}
-	for _, addr := range info {
-		ipMask := strings.Split(addr.String(), "/")
-		if ipMask[0] != "127.0.0.1" && ipMask[0] != "24" {
-			return ipMask[0]
-		}
-	}
-	return ""
+	return netutil.InnerIP()
 }
@@ -0,0 +1,176 @@
+package loggertest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// LoggedEntry is a record captured by ObservedLogs
+type LoggedEntry struct {
+	Time    time.Time
+	Level   string              // 日志级别，通过Write写入的自定义文件为空
+	Name    string              // 模块名称，参考logger.Named
+	Caller  string              // 调用位置，未记录时为空
+	Message string              // 所有参数以"|"连接的结果，*f函数为格式化之后的内容
+	Args    []interface{}       // 原始参数
+	Fields  logger.Fields       // 附加字段
+	Stack   []logger.StackFrame // 调用栈，参考logger.WithStackTrace
+}
+
+// ObservedLogs holds the entries recorded by an observer
+/*
+ * 内存中的日志记录，可以被多个协程同时写入以及读取
+ * Filter*函数返回新的ObservedLogs，不影响原来的记录
+ */
+type ObservedLogs struct {
+	mu      sync.RWMutex
+	entries []LoggedEntry
+}
+
+// New creates a logger that keeps records in memory instead of files
+/*
+ * 创建只在内存中记录日志的Logger，不写日志文件，用于单元测试中检查组件输出的日志
+ * 例如：
+ *     log, logs, err := loggertest.New()
+ *     defer log.Close()
+ *     component.Run(log)
+ *     if logs.FilterLevel("error").Len() != 0 { t.Fatal(logs.All()) }
+ * 记录在hook中保存，与其他hook按照注册顺序执行，被之前的hook丢弃的记录不会被保存
+ * @param opts：额外的选项，例如logger.WithCaller("error")
+ * @return (日志对象, 记录, error)
+ */
+func New(opts ...logger.Option) (*logger.Logger, *ObservedLogs, error) {
+	logs := &ObservedLogs{}
+	opts = append([]logger.Option{logger.WithoutFiles()}, opts...)
+	opts = append(opts, logger.WithHook(logs.Hook()))
+	log, err := logger.NewLogger("loggertest", "", "", opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return log, logs, nil
+}
+
+// Hook returns a hook recording every entry into logs, for attaching to an existing logger
+func (logs *ObservedLogs) Hook() logger.Hook {
+	return func(entry logger.Entry) (logger.Entry, bool) {
+		logs.add(LoggedEntry{
+			Time:    entry.Time,
+			Level:   entry.Level,
+			Name:    entry.Name,
+			Caller:  entry.Caller,
+			Message: message(entry.Args),
+			Args:    entry.Args,
+			Fields:  entry.Fields,
+			Stack:   entry.Stack,
+		})
+		return entry, true
+	}
+}
+
+// Len returns the number of recorded entries
+func (logs *ObservedLogs) Len() int {
+	logs.mu.RLock()
+	defer logs.mu.RUnlock()
+	return len(logs.entries)
+}
+
+// All returns a copy of the recorded entries
+func (logs *ObservedLogs) All() []LoggedEntry {
+	logs.mu.RLock()
+	defer logs.mu.RUnlock()
+	return append([]LoggedEntry(nil), logs.entries...)
+}
+
+// TakeAll returns the recorded entries and clears them
+func (logs *ObservedLogs) TakeAll() []LoggedEntry {
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	entries := logs.entries
+	logs.entries = nil
+	return entries
+}
+
+// Messages returns the messages of the recorded entries in order
+func (logs *ObservedLogs) Messages() []string {
+	logs.mu.RLock()
+	defer logs.mu.RUnlock()
+	messages := make([]string, len(logs.entries))
+	for i, entry := range logs.entries {
+		messages[i] = entry.Message
+	}
+	return messages
+}
+
+// Filter returns the entries for which keep returns true
+func (logs *ObservedLogs) Filter(keep func(entry LoggedEntry) bool) *ObservedLogs {
+	logs.mu.RLock()
+	defer logs.mu.RUnlock()
+	filtered := &ObservedLogs{}
+	for _, entry := range logs.entries {
+		if keep(entry) {
+			filtered.entries = append(filtered.entries, entry)
+		}
+	}
+	return filtered
+}
+
+// FilterLevel returns the entries of a level
+func (logs *ObservedLogs) FilterLevel(level string) *ObservedLogs {
+	return logs.Filter(func(entry LoggedEntry) bool {
+		return entry.Level == level
+	})
+}
+
+// FilterName returns the entries of a module, see logger.Named
+func (logs *ObservedLogs) FilterName(name string) *ObservedLogs {
+	return logs.Filter(func(entry LoggedEntry) bool {
+		return entry.Name == name
+	})
+}
+
+// FilterMessage returns the entries whose message equals msg
+func (logs *ObservedLogs) FilterMessage(msg string) *ObservedLogs {
+	return logs.Filter(func(entry LoggedEntry) bool {
+		return entry.Message == msg
+	})
+}
+
+// FilterMessageContains returns the entries whose message contains sub
+func (logs *ObservedLogs) FilterMessageContains(sub string) *ObservedLogs {
+	return logs.Filter(func(entry LoggedEntry) bool {
+		return strings.Contains(entry.Message, sub)
+	})
+}
+
+// FilterField returns the entries carrying the field key with the given value
+func (logs *ObservedLogs) FilterField(key string, value interface{}) *ObservedLogs {
+	return logs.Filter(func(entry LoggedEntry) bool {
+		v, ok := entry.Fields[key]
+		return ok && reflect.DeepEqual(v, value)
+	})
+}
+
+/*
+ * 保存一条记录
+ */
+func (logs *ObservedLogs) add(entry LoggedEntry) {
+	logs.mu.Lock()
+	logs.entries = append(logs.entries, entry)
+	logs.mu.Unlock()
+}
+
+/*
+ * 将参数以"|"连接，与文本格式中参数的分隔方式相同
+ */
+func message(args []interface{}) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = fmt.Sprint(arg)
+	}
+	return strings.Join(parts, "|")
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrExpvarExists is returned when publishing stats under a name already in use
+var ErrExpvarExists = errors.New("logger: expvar name already in use")
+
+// PublishExpvar publishes Stats under name in expvar, served at /debug/vars
+/*
+ * 将日志健康状态发布到expvar，引入expvar的程序在/debug/vars中可以看到，每次读取时重新计算
+ * 例如: logger.PublishExpvar("logger")
+ * @param name：expvar名称
+ * @return 名称已经被使用时返回ErrExpvarExists
+ */
+func (logger *Logger) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return ErrExpvarExists
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return logger.Stats()
+	}))
+	return nil
+}
+
+// MetricsHandler returns an http.Handler serving Stats in the Prometheus text format
+/*
+ * 返回Prometheus文本格式的指标接口，不依赖Prometheus客户端库，挂载到管理端口后由Prometheus直接抓取
+ * 例如: curl http://127.0.0.1:8080/admin/log/metrics
+ * 指标名称统一以logger_开头，计数器以_total结尾，级别作为level标签；远程sink使用压缩时另外输出按照codec标签区分的压缩统计
+ */
+func (logger *Logger) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(appendCompressionMetrics(logger.Stats().appendMetrics(nil)))
+	})
+}
+
+/*
+ * 按照Prometheus文本格式输出指标
+ */
+func (stats Stats) appendMetrics(buf []byte) []byte {
+	buf = appendMetricHeader(buf, "logger_records_total", "counter", "Records written per level.")
+	for _, level := range stats.levels() {
+		buf = append(buf, `logger_records_total{level="`...)
+		buf = append(buf, escapeLabel(level)...)
+		buf = append(buf, `"} `...)
+		buf = strconv.AppendUint(buf, stats.Records[level], 10)
+		buf = append(buf, '\n')
+	}
+	buf = appendMetric(buf, "logger_dropped_records_total", "counter", "Records dropped before reaching a buffer.", stats.Dropped)
+	buf = appendMetric(buf, "logger_dropped_buffers_total", "counter", "Buffers dropped by the overflow policy.", stats.DroppedBuffers)
+	buf = appendMetric(buf, "logger_failed_writes_total", "counter", "Buffers that failed to be written to the log file.", stats.FailedWrites)
+	buf = appendMetric(buf, "logger_errors_total", "counter", "Internal errors, including failed writes.", stats.Errors)
+	buf = appendMetric(buf, "logger_flushed_bytes_total", "counter", "Bytes written to log files.", stats.BytesFlushed)
+	buf = appendMetric(buf, "logger_rotations_total", "counter", "Log file rotations.", stats.Rotations)
+	buf = appendMetric(buf, "logger_queue_depth", "gauge", "Buffers waiting in the write queues.", uint64(stats.QueueDepth))
+	buf = appendMetric(buf, "logger_queue_capacity", "gauge", "Total capacity of the write queues.", uint64(stats.QueueCapacity))
+	buf = appendMetric(buf, "logger_slow_flushes_total", "counter", "Buffers slower than the slow flush threshold from queue to disk.", stats.SlowFlushes)
+	buf = appendMetric(buf, "logger_truncated_records_total", "counter", "Records exceeding the maximum record size.", stats.Truncated)
+	return stats.FlushLatency.appendMetrics(buf, "logger_flush_latency_seconds", "Latency of buffers from the write queue to disk.")
+}
+
+/*
+ * 按照Prometheus histogram格式输出延迟分布，分桶数量累计
+ */
+func (h LatencyHistogram) appendMetrics(buf []byte, name, help string) []byte {
+	buf = appendMetricHeader(buf, name, "histogram", help)
+	var cumulative uint64
+	for i, bound := range h.Buckets {
+		cumulative += h.Counts[i]
+		buf = append(buf, name+`_bucket{le="`...)
+		buf = strconv.AppendFloat(buf, bound.Seconds(), 'g', -1, 64)
+		buf = append(buf, `"} `...)
+		buf = strconv.AppendUint(buf, cumulative, 10)
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, name+`_bucket{le="+Inf"} `...)
+	buf = strconv.AppendUint(buf, h.Count, 10)
+	buf = append(buf, '\n')
+	buf = append(buf, name+"_sum "...)
+	buf = strconv.AppendFloat(buf, h.Sum.Seconds(), 'g', -1, 64)
+	buf = append(buf, '\n')
+	buf = append(buf, name+"_count "...)
+	buf = strconv.AppendUint(buf, h.Count, 10)
+	return append(buf, '\n')
+}
+
+/*
+ * 输出指标的HELP以及TYPE
+ */
+func appendMetricHeader(buf []byte, name, kind, help string) []byte {
+	buf = append(buf, "# HELP "+name+" "+help+"\n"...)
+	return append(buf, "# TYPE "+name+" "+kind+"\n"...)
+}
+
+/*
+ * 输出不带标签的指标
+ */
+func appendMetric(buf []byte, name, kind, help string, value uint64) []byte {
+	buf = appendMetricHeader(buf, name, kind, help)
+	buf = append(buf, name+" "...)
+	buf = strconv.AppendUint(buf, value, 10)
+	return append(buf, '\n')
+}
+
+// labelEscaper Prometheus标签值的转义
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+/*
+ * 转义标签值
+ */
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
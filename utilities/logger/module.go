@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+)
+
+// Named returns a child logger for a component, nested names are joined with "."
+/*
+ * 创建模块子日志对象，例如logger.Named("storage")，每条记录都会带上模块名称：
+ * 文本格式中输出在时间(以及级别标记)之后，例如 时间|[storage]|...；JSON格式中为"logger"字段
+ * 子对象与父对象共享日志文件、写入协程以及其他配置，可以通过SetModuleLevel单独设置记录级别
+ * 多次调用时名称以"."连接，例如logger.Named("rpc").Named("client")的名称为rpc.client
+ * @param name：模块名称，为空时返回与当前对象相同的子对象
+ * @return 子日志对象
+ */
+func (logger *Logger) Named(name string) *Logger {
+	child := *logger
+	switch {
+	case name == "":
+	case child.name == "":
+		child.name = name
+	default:
+		child.name = child.name + "." + name
+	}
+	return &child
+}
+
+// Name returns the module name of the logger, empty for the root logger
+func (logger *Logger) Name() string {
+	return logger.name
+}
+
+// SetModuleLevel sets the minimum level of a module, overriding the logger's level
+/*
+ * 单独设置模块的记录级别，例如只为rpc开启debug：
+ *     logger.SetMinLevel("warn")
+ *     logger.SetModuleLevel("rpc", "debug")
+ * 模块没有单独设置时使用上级模块的级别，例如rpc.client使用rpc的级别，都没有设置时使用SetLevel/SetMinLevel的级别
+ * 磁盘空间不足降级时(参考WithDiskGuard)模块级别同样只记录error及以上
+ * @param module：模块名称，与Named的名称相同
+ * @param level：级别名称，all表示记录所有级别
+ * @return 模块名称为空返回error；级别不存在返回ErrUnknownLevel
+ */
+func (logger *Logger) SetModuleLevel(module, level string) error {
+	if module == "" {
+		return errors.New("logger: empty module name")
+	}
+	logger.Lock()
+	defer logger.Unlock()
+	severity, ok := logger.levels[level]
+	if !ok {
+		if level != levelAll {
+			return ErrUnknownLevel
+		}
+		severity = 0
+	}
+	if logger.modules == nil {
+		logger.modules = make(map[string]int)
+	}
+	logger.modules[module] = severity
+	return nil
+}
+
+// ResetModuleLevel removes the level set by SetModuleLevel for a module
+func (logger *Logger) ResetModuleLevel(module string) {
+	logger.Lock()
+	defer logger.Unlock()
+	delete(logger.modules, module)
+}
+
+/*
+ * 获取当前对象生效的最低严重程度，调用方需要持有读锁
+ * 模块以及上级模块设置了级别时使用模块级别，否则使用全局级别
+ */
+func (logger *Logger) minLevel() int {
+	if logger.name == "" || len(logger.modules) == 0 {
+		return logger.minSeverity
+	}
+	for name := logger.name; ; {
+		if severity, ok := logger.modules[name]; ok {
+			if severity < SeverityError && logger.DiskLow() {
+				return SeverityError
+			}
+			return severity
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return logger.minSeverity
+		}
+		name = name[:i]
+	}
+}
@@ -0,0 +1,197 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 文件名模板中的占位符
+const (
+	placeholderName  = "{name}"
+	placeholderLevel = "{level}"
+	placeholderDate  = "{date}"
+	placeholderSeq   = "{seq}"
+)
+
+// nameSeparators 占位符之前可以省略的分隔符
+const nameSeparators = "-_."
+
+// fileNaming 日志文件的命名
+type fileNaming struct {
+	filename string // 正在写入的文件名
+	rotated  string // 切分文件名模板，包含{date}以及{seq}，为空表示 filename.时间段.序号
+	subDir   string // 文件名模板中的目录部分，备份时在日期目录下保留，为空表示没有
+}
+
+// WithFileNameTemplate names level and channel files after a template, e.g. "{level}/{name}-{level}-{date}.{seq}.log"
+/*
+ * 按照模板命名级别文件以及通道文件，模板为相对于NewLogger的filename所在目录的路径，支持以下占位符：
+ *   {name}   NewLogger的filename中的文件名部分，例如/data/log/saver中的saver
+ *   {level}  级别名称或者通道名称，必须包含
+ *   {date}   切分文件的时间段(2006010215)
+ *   {seq}    切分文件在时间段内的序号，只切分一次的时间段没有序号
+ * 正在写入的文件名为去掉{date}、{seq}以及各自之前的一个分隔符(-_.)之后的模板，例如：
+ *   "{name}-{level}-{date}.{seq}.log"  ->  saver-error.log，切分为saver-error-2014091010.0.log
+ *   "{level}/{name}.log"               ->  error/saver.log，切分为error/saver.log.2014091010.0
+ * 模板中没有{date}时切分文件名与默认相同，为 正在写入的文件名.时间段.序号
+ * 模板包含目录时每个级别或者通道写入单独的子目录(log/access/、log/error/)，子目录在创建文件时自动创建，权限参考WithFileMode
+ * 单文件模式以及通过Write写入的自定义文件不受影响；reader包只识别默认的切分文件名
+ * @param template：文件名模板，默认相当于 "{name}-{level}.log"
+ */
+func WithFileNameTemplate(template string) Option {
+	return func(logger *Logger) {
+		if err := checkNameTemplate(template); err != nil {
+			logger.initErr = err
+			return
+		}
+		logger.nameTemplate = filepath.ToSlash(template)
+	}
+}
+
+/*
+ * 检查文件名模板
+ */
+func checkNameTemplate(template string) error {
+	if !strings.Contains(template, placeholderLevel) {
+		return fmt.Errorf("logger: file name template %q must contain %s", template, placeholderLevel)
+	}
+	if filepath.IsAbs(template) || strings.HasPrefix(template, "/") {
+		return fmt.Errorf("logger: file name template %q must be relative", template)
+	}
+	base := template
+	if i := strings.LastIndexAny(template, `/\`); i >= 0 {
+		base = template[i+1:]
+		for _, dir := range strings.FieldsFunc(template[:i], func(r rune) bool { return r == '/' || r == '\\' }) {
+			if dir == ".." {
+				return fmt.Errorf("logger: file name template %q must not leave the log directory", template)
+			}
+		}
+	}
+	for _, placeholder := range []string{placeholderDate, placeholderSeq} {
+		if n := strings.Count(template, placeholder); n > 1 || n != strings.Count(base, placeholder) {
+			return fmt.Errorf("logger: %s must appear at most once and only in the last element of template %q", placeholder, template)
+		}
+	}
+	if strings.Contains(template, placeholderSeq) && !strings.Contains(template, placeholderDate) {
+		return fmt.Errorf("logger: file name template %q has %s without %s", template, placeholderSeq, placeholderDate)
+	}
+	if active := stripPlaceholder(stripPlaceholder(base, placeholderDate), placeholderSeq); active == "" {
+		return fmt.Errorf("logger: file name template %q has an empty file name", template)
+	}
+	return nil
+}
+
+/*
+ * 计算级别文件或者通道文件的命名
+ * @param filename：NewLogger的filename，租户文件为租户目录下的同名文件
+ * @param level：级别名称或者通道名称
+ * @return 未设置模板时为 filename-级别.log，切分文件名模板为空
+ */
+func (logger *logCore) levelFile(filename, level string) fileNaming {
+	if logger.nameTemplate == "" {
+		return fileNaming{filename: filename + "-" + level + ".log"}
+	}
+	name := filepath.FromSlash(strings.NewReplacer(placeholderName, filepath.Base(filename), placeholderLevel, level).Replace(logger.nameTemplate))
+	rotated := filepath.Join(filepath.Dir(filename), name)
+	naming := fileNaming{
+		filename: stripPlaceholder(stripPlaceholder(rotated, placeholderDate), placeholderSeq),
+		rotated:  rotated,
+	}
+	if !strings.Contains(rotated, placeholderDate) {
+		naming.rotated = naming.filename + "." + placeholderDate + "." + placeholderSeq
+	}
+	if dir := filepath.Dir(name); dir != "." {
+		naming.subDir = dir
+	}
+	return naming
+}
+
+/*
+ * 删除占位符以及之前的一个分隔符
+ */
+func stripPlaceholder(s, placeholder string) string {
+	i := strings.Index(s, placeholder)
+	if i < 0 {
+		return s
+	}
+	start := i
+	if start > 0 && strings.IndexByte(nameSeparators, s[start-1]) >= 0 {
+		start--
+	}
+	return s[:start] + s[i+len(placeholder):]
+}
+
+/*
+ * 切分文件名
+ * @param hour：时间段
+ * @param seq：时间段内的序号，<0表示没有序号
+ * @return 默认为 filename.2014091010.0
+ */
+func (logger *LoggerInfo) rotatedName(hour time.Time, seq int) string {
+	if logger.naming == "" {
+		name := logger.filename + "." + hour.Format(HOURFORMAT)
+		if seq >= 0 {
+			name += "." + strconv.Itoa(seq)
+		}
+		return name
+	}
+	name := strings.Replace(logger.naming, placeholderDate, hour.Format(HOURFORMAT), 1)
+	if seq < 0 {
+		return stripPlaceholder(name, placeholderSeq)
+	}
+	return strings.Replace(name, placeholderSeq, strconv.Itoa(seq), 1)
+}
+
+/*
+ * 获取时间段内已经切分出来的文件，压缩文件以及正在压缩的文件按照原文件名返回
+ * @param hour：时间段
+ * @return 切分文件名
+ */
+func (logger *LoggerInfo) rotatedFilesOf(hour time.Time) []string {
+	if logger.naming == "" {
+		return rotatedFiles(logger.filename + "." + hour.Format(HOURFORMAT))
+	}
+	name := strings.Replace(logger.naming, placeholderDate, hour.Format(HOURFORMAT), 1)
+	pattern := regexp.QuoteMeta(name)
+	glob := name
+	if i := strings.Index(name, placeholderSeq); i >= 0 {
+		start := i
+		if start > 0 && strings.IndexByte(nameSeparators, name[start-1]) >= 0 {
+			start--
+		}
+		pattern = regexp.QuoteMeta(name[:start]) + "(?:" + regexp.QuoteMeta(name[start:i]) + `\d+)?` + regexp.QuoteMeta(name[i+len(placeholderSeq):])
+		glob = name[:start] + "*" + name[i+len(placeholderSeq):]
+	}
+	matcher := regexp.MustCompile("^" + pattern + "$")
+	matches, _ := filepath.Glob(glob + "*")
+	files := make([]string, 0, len(matches))
+	seen := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimSuffix(match, gzipTmpSuffix), gzipSuffix)
+		if !seen[name] && matcher.MatchString(name) {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+/*
+ * 删除上次进程退出时残留的压缩临时文件
+ */
+func (logger *LoggerInfo) removePartialArchives() {
+	if logger.naming == "" {
+		removePartialArchives(logger.filename)
+		return
+	}
+	glob := stripPlaceholder(strings.Replace(logger.naming, placeholderDate, "*", 1), placeholderSeq)
+	matches, _ := filepath.Glob(glob + "*" + gzipTmpSuffix)
+	for _, match := range matches {
+		os.Remove(match)
+	}
+}
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"io/ioutil"
+)
+
+// FieldLogger is the logging interface accepted by libraries built on nano-legion
+/*
+ * 组件只需要记录日志时可以依赖该接口而不是*Logger，调用方可以传入*Logger、NopLogger或者自行实现
+ * With返回携带字段的子对象，对应*Logger的WithFields
+ * 参数较多或者构造代价较高时可以先通过CheckLevel判断级别是否开启
+ */
+type FieldLogger interface {
+	Debug(args ...interface{})
+	Debugf(format string, args ...interface{})
+	Trace(args ...interface{})
+	Tracef(format string, args ...interface{})
+	Warn(args ...interface{})
+	Warnf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorf(format string, args ...interface{})
+	With(fields Fields) FieldLogger
+	CheckLevel(logType string) bool
+}
+
+var (
+	_ FieldLogger = (*Logger)(nil)
+	_ FieldLogger = NopLogger{}
+)
+
+// With returns a child logger carrying fields, implementing FieldLogger
+/*
+ * 与WithFields相同，返回值为FieldLogger
+ * @param fields：附加字段
+ * @return 子日志对象
+ */
+func (logger *Logger) With(fields Fields) FieldLogger {
+	return logger.WithFields(fields)
+}
+
+// NopLogger is a FieldLogger that does nothing
+/*
+ * 所有函数直接返回，不检查级别也不格式化参数，零值可以直接使用，例如：
+ *     component.New(logger.NopLogger{})
+ */
+type NopLogger struct{}
+
+// Debug does nothing
+func (NopLogger) Debug(args ...interface{}) {}
+
+// Debugf does nothing
+func (NopLogger) Debugf(format string, args ...interface{}) {}
+
+// Trace does nothing
+func (NopLogger) Trace(args ...interface{}) {}
+
+// Tracef does nothing
+func (NopLogger) Tracef(format string, args ...interface{}) {}
+
+// Warn does nothing
+func (NopLogger) Warn(args ...interface{}) {}
+
+// Warnf does nothing
+func (NopLogger) Warnf(format string, args ...interface{}) {}
+
+// Error does nothing
+func (NopLogger) Error(args ...interface{}) {}
+
+// Errorf does nothing
+func (NopLogger) Errorf(format string, args ...interface{}) {}
+
+// With returns the NopLogger itself
+func (l NopLogger) With(fields Fields) FieldLogger { return l }
+
+// CheckLevel always returns false
+func (NopLogger) CheckLevel(logType string) bool { return false }
+
+// NewDiscardLogger creates a logger that formats records and throws them away
+/*
+ * 创建不写日志文件的Logger，记录经过级别检查、编码以及hook等完整流程之后输出到ioutil.Discard
+ * 与NopLogger不同，适用于需要*Logger或者需要计入格式化开销的基准测试
+ * 使用完之后需要调用Close
+ * @param opts：额外的选项，例如WithEncoder
+ * @return 成功则返回(*Logger, nil)；否则返回 (nil, error)
+ */
+func NewDiscardLogger(opts ...Option) (*Logger, error) {
+	opts = append([]Option{WithoutFiles()}, opts...)
+	logger, err := NewLogger("discard", "", "", opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := logger.AddSink("discard", ioutil.Discard); err != nil {
+		logger.Close()
+		return nil, err
+	}
+	return logger, nil
+}
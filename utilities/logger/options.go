@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// 日志文件以及目录的默认权限
+const (
+	defaultFileMode os.FileMode = 0777
+	defaultDirMode  os.FileMode = 0777
+)
+
+// Config groups the tuning knobs of a Logger, usually loaded from a configuration file
+/*
+ * 日志调优参数，便于按照部署环境通过配置文件调整，零值字段使用默认值
+ * 通过WithConfig传给NewLogger，与单独的Option效果相同，后传入的Option覆盖先传入的
+ */
+type Config struct {
+	FlushInterval  time.Duration `json:"flush_interval" yaml:"flush_interval"`     // buffer写入队列的间隔，默认1秒
+	BufferSize     int           `json:"buffer_size" yaml:"buffer_size"`           // buffer的初始容量(字节)，默认2KB
+	QueueSize      int           `json:"queue_size" yaml:"queue_size"`             // 写入队列长度，默认50000
+	FileMode       os.FileMode   `json:"file_mode" yaml:"file_mode"`               // 日志文件权限，默认0777(受umask影响)
+	DirMode        os.FileMode   `json:"dir_mode" yaml:"dir_mode"`                 // 备份、租户以及文件名模板中子目录的权限，默认0777(受umask影响)
+	SyncEveryWrite bool          `json:"sync_every_write" yaml:"sync_every_write"` // 每条记录立即写入并fsync，参考WithSyncEveryWrite
+	CreateDirs     bool          `json:"create_dirs" yaml:"create_dirs"`           // 自动创建日志文件所在目录，参考WithCreateDirs
+}
+
+// fileOwner 新建日志文件以及目录的属主，-1表示不修改
+type fileOwner struct {
+	uid int
+	gid int
+}
+
+// WithConfig applies every non-zero field of config
+/*
+ * 按照Config设置日志参数，零值字段保持默认值
+ * @param config：调优参数
+ */
+func WithConfig(config Config) Option {
+	return func(logger *Logger) {
+		if config.FlushInterval > 0 {
+			logger.flushEvery = config.FlushInterval
+		}
+		if config.BufferSize > 0 {
+			logger.bufferSize = config.BufferSize
+		}
+		if config.QueueSize > 0 {
+			logger.queueSize = config.QueueSize
+		}
+		if config.FileMode != 0 {
+			logger.fileMode = config.FileMode
+		}
+		if config.DirMode != 0 {
+			logger.dirMode = config.DirMode
+		}
+		if config.SyncEveryWrite {
+			logger.syncWrites = true
+		}
+		if config.CreateDirs {
+			logger.createDirs = true
+		}
+	}
+}
+
+// WithBufferSize sets the initial capacity of the in-memory buffer of every log file
+/*
+ * 设置每个日志文件buffer的初始容量，默认2KB，写入量大时适当调大可以减少扩容
+ * buffer在每次写入队列之后重新分配
+ * @param n：容量(字节)，<=0时使用默认值
+ */
+func WithBufferSize(n int) Option {
+	return func(logger *Logger) {
+		logger.bufferSize = n
+	}
+}
+
+// WithFileMode sets the permissions of log files and the directories created for them
+/*
+ * 设置日志文件以及备份、租户、文件名模板中子目录的权限，默认都为0777，实际权限受umask影响
+ * 只影响新创建的文件以及目录
+ * @param fileMode：日志文件权限，为0时使用默认值
+ * @param dirMode：目录权限，为0时使用默认值
+ */
+func WithFileMode(fileMode, dirMode os.FileMode) Option {
+	return func(logger *Logger) {
+		logger.fileMode = fileMode
+		logger.dirMode = dirMode
+	}
+}
+
+// WithSyncEveryWrite hands every record to the writer goroutine as soon as it is logged
+/*
+ * 每条记录写入buffer之后立即进入写入队列，由写入协程写入文件并fsync，不再等待flush间隔
+ * 日志落盘更及时，代价是每条记录一次write+fsync；调用返回时记录不保证已经落盘
+ * @param enabled：是否开启
+ */
+func WithSyncEveryWrite(enabled bool) Option {
+	return func(logger *Logger) {
+		logger.syncWrites = enabled
+	}
+}
+
+// WithCreateDirs creates the directory of every log file when it does not exist
+/*
+ * 创建日志文件时自动创建所在目录(MkdirAll)，目录权限参考WithFileMode，默认要求目录已经存在
+ * 运行过程中目录被删除时，切分或者重建日志文件时同样会重新创建
+ * @param enabled：是否开启
+ */
+func WithCreateDirs(enabled bool) Option {
+	return func(logger *Logger) {
+		logger.createDirs = enabled
+	}
+}
+
+// WithOwner sets the owner of log files and directories created by the logger (unix only)
+/*
+ * 设置新建的日志文件、切分后重新创建的文件以及备份、租户目录的属主，
+ * 用于以root启动之后降低权限运行的服务，保证降权之后仍然可以切分以及备份日志
+ * 修改属主失败时通过错误回调上报，文件仍然正常写入；windows下不生效
+ * @param uid：属主用户id，-1表示不修改
+ * @param gid：属主组id，-1表示不修改
+ */
+func WithOwner(uid, gid int) Option {
+	return func(logger *Logger) {
+		if runtime.GOOS == "windows" || (uid < 0 && gid < 0) {
+			logger.owner = nil
+			return
+		}
+		logger.owner = &fileOwner{uid: uid, gid: gid}
+	}
+}
+
+/*
+ * 打开日志文件，设置了属主时修改属主
+ * @param filename：文件名
+ * @param mode：新建文件的权限
+ * @param owner：属主，为nil时不修改
+ * @return 打开失败时返回error；修改属主失败时文件正常返回，同时返回error
+ */
+func openLogFile(filename string, mode os.FileMode, owner *fileOwner) (*os.File, error) {
+	file, err := openAppend(filename, mode)
+	if err != nil || owner == nil {
+		return file, err
+	}
+	return file, file.Chown(owner.uid, owner.gid)
+}
+
+/*
+ * 创建目录以及不存在的上级目录，设置了属主时修改新建目录的属主，已经存在的目录不修改
+ * @param dir：目录
+ * @param mode：目录权限
+ * @param owner：属主，为nil时不修改
+ */
+func mkdirAll(dir string, mode os.FileMode, owner *fileOwner) error {
+	if owner == nil {
+		return os.MkdirAll(dir, mode)
+	}
+	if info, err := os.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: os.ErrExist}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := mkdirAll(parent, mode, owner); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, mode); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.Chown(dir, owner.uid, owner.gid)
+}
+
+/*
+ * 日志文件权限，未设置时使用默认值
+ */
+func (logger *logCore) logFileMode() os.FileMode {
+	if logger.fileMode == 0 {
+		return defaultFileMode
+	}
+	return logger.fileMode
+}
+
+/*
+ * 目录权限，未设置时使用默认值
+ */
+func (logger *logCore) logDirMode() os.FileMode {
+	if logger.dirMode == 0 {
+		return defaultDirMode
+	}
+	return logger.dirMode
+}
@@ -0,0 +1,636 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLPExporter默认参数
+const (
+	defaultOTLPEndpoint    = "http://localhost:4318/v1/logs"
+	defaultOTLPQueue       = 10000
+	defaultOTLPBatchSize   = 512
+	defaultOTLPLinger      = time.Second
+	defaultOTLPTimeout     = 10 * time.Second
+	defaultOTLPAttempts    = 3
+	defaultOTLPMinBackoff  = 500 * time.Millisecond
+	defaultOTLPMaxBackoff  = 10 * time.Second
+	defaultOTLPTraceField  = "trace_id"
+	defaultOTLPSpanField   = "span_id"
+	otlpInstrumentationLib = "github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// OpenTelemetry SeverityNumber，每个区间取第一个值
+const (
+	otlpSeverityDebug = 5
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+	otlpSeverityFatal = 21
+)
+
+// ErrOTLPQueueFull is returned when a record is dropped because the export queue is full
+var ErrOTLPQueueFull = errors.New("logger: otlp queue full, record dropped")
+
+// errOTLPEncoding 收集端不支持请求的Content-Encoding
+var errOTLPEncoding = errors.New("otlp export: unsupported content encoding")
+
+// OTLPConfig configures the OpenTelemetry log exporter, see AddOTLPSink
+type OTLPConfig struct {
+	Endpoint     string            // OTLP/HTTP日志接口地址，默认http://localhost:4318/v1/logs
+	Headers      map[string]string // 附加的请求头，例如鉴权信息
+	Resource     Fields            // 资源属性，例如{"service.name": "order"}
+	TraceIDField string            // 作为trace id输出的字段，默认trace_id
+	SpanIDField  string            // 作为span id输出的字段，默认span_id
+	QueueSize    int               // 发送队列长度(条数)，默认10000，队列满时丢弃记录
+	BatchSize    int               // 每批最大条数，默认512
+	Linger       time.Duration     // 凑批的最长等待时间，默认1s
+	Timeout      time.Duration     // 单次请求的超时时间，默认10s
+	MaxAttempts  int               // 每批最多尝试次数，默认3，只有网络错误以及429/502/503/504会重试
+	MinBackoff   time.Duration     // 重试的初始等待时间，默认500ms，之后每次失败翻倍
+	MaxBackoff   time.Duration     // 重试的最长等待时间，默认10s
+	DrainTimeout time.Duration     // Close时等待剩余记录发送的最长时间，0表示一直等待
+	Client       *http.Client      // 为nil时使用http.DefaultClient
+	Compression  []string          // 按照优先顺序排列的请求压缩算法(Content-Encoding)，例如{"zstd", "gzip"}，为空表示不压缩
+}
+
+// OTLPExporter is a sink exporting records as OpenTelemetry log records over OTLP/HTTP
+/*
+ * OpenTelemetry日志输出：每条记录转换为一条LogRecord，按照BatchSize/Linger凑批之后以OTLP/HTTP JSON格式发送
+ * 日志文件照常写入，服务可以在保留文件日志的同时接入OpenTelemetry Collector
+ * 发送失败以及队列满丢弃的记录通过Logger的错误回调上报，并计入Stats
+ */
+type OTLPExporter struct {
+	config   OTLPConfig
+	reporter *reporter
+	resource []byte     // 编码后的资源属性
+	codec    Compressor // 请求内容的压缩算法，nil表示不压缩
+	fallback []string   // 收集端返回415时依次尝试的算法
+	queue    chan []byte
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// otlpEncoder encodes an entry as an OTLP JSON LogRecord
+type otlpEncoder struct {
+	traceField string
+	spanField  string
+	severity   func(level string) (int, bool) // 获取级别的严重程度
+}
+
+// AddOTLPSink exports records of the given levels to an OpenTelemetry collector
+/*
+ * 添加OpenTelemetry日志输出，错误通过该Logger的错误回调上报，例如：
+ *   log, _ := logger.NewLogger(name, suffix, backupDir, logger.WithContextExtractor(func(ctx context.Context) logger.Fields {
+ *       sc := trace.SpanContextFromContext(ctx)
+ *       if !sc.IsValid() {
+ *           return nil
+ *       }
+ *       return logger.Fields{"trace_id": sc.TraceID().String(), "span_id": sc.SpanID().String()}
+ *   }))
+ *   exporter, err := log.AddOTLPSink("otel", logger.OTLPConfig{Resource: logger.Fields{"service.name": "order"}})
+ *   ...
+ *   log.Close()
+ *   exporter.Close()
+ * 记录的转换规则：
+ *   severityText为级别名称，severityNumber按照级别的严重程度映射：低于trace为DEBUG，低于warn为INFO，
+ *   低于error为WARN，其余为ERROR，fatal/panic为FATAL，没有注册的级别为UNSPECIFIED
+ *   body为所有参数以"|"连接的结果；附加字段(包括WithContext/WriteCtx从context中提取的字段)输出为attributes
+ *   TraceIDField/SpanIDField字段为32/16位十六进制字符串或者[16]byte/[8]byte时输出为traceId/spanId，否则保留为attribute
+ *   模块名称、调用位置、调用栈以及后缀信息分别输出为logger.name、code.*、exception.stacktrace以及log.suffix
+ * @param name：sink名称，用于RemoveSink
+ * @param config：导出配置
+ * @param levels：接收的日志级别，为空表示所有级别
+ * Compression中的算法按照顺序协商：使用第一个已注册的算法，收集端返回415 Unsupported Media Type时改用下一个，最后不压缩
+ * @return 名称已经存在时返回ErrSinkExists；Compression中没有已注册的算法时返回ErrNoCompressor
+ */
+func (logger *Logger) AddOTLPSink(name string, config OTLPConfig, levels ...string) (*OTLPExporter, error) {
+	if config.Endpoint == "" {
+		config.Endpoint = defaultOTLPEndpoint
+	}
+	if config.TraceIDField == "" {
+		config.TraceIDField = defaultOTLPTraceField
+	}
+	if config.SpanIDField == "" {
+		config.SpanIDField = defaultOTLPSpanField
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultOTLPQueue
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultOTLPBatchSize
+	}
+	if config.Linger <= 0 {
+		config.Linger = defaultOTLPLinger
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultOTLPTimeout
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultOTLPAttempts
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultOTLPMinBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = defaultOTLPMaxBackoff
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	codec, err := negotiateCompression(config.Compression, nil)
+	if err != nil {
+		return nil, err
+	}
+	e := &OTLPExporter{
+		codec:    codec,
+		fallback: remainingCompressions(config.Compression, codec),
+		config:   config,
+		reporter: logger.reporter,
+		resource: appendOTLPAttributes(nil, config.Resource),
+		queue:    make(chan []byte, config.QueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	encoder := &otlpEncoder{
+		traceField: config.TraceIDField,
+		spanField:  config.SpanIDField,
+		severity: func(level string) (int, bool) {
+			logger.RLock()
+			defer logger.RUnlock()
+			severity, ok := logger.levels[level]
+			return severity, ok
+		},
+	}
+	if err := logger.AddEncodedSink(name, e, encoder, levels...); err != nil {
+		return nil, err
+	}
+	go e.run()
+	return e, nil
+}
+
+// Encode implements Encoder
+func (encoder *otlpEncoder) Encode(entry *Entry) []byte {
+	buf := make([]byte, 0, 256+32*len(entry.Fields))
+	buf = append(buf, `{"timeUnixNano":"`...)
+	buf = strconv.AppendInt(buf, entry.Time.UnixNano(), 10)
+	buf = append(buf, `","observedTimeUnixNano":"`...)
+	buf = strconv.AppendInt(buf, time.Now().UnixNano(), 10)
+	buf = append(buf, `","severityNumber":`...)
+	buf = strconv.AppendInt(buf, int64(encoder.severityNumber(entry.Level)), 10)
+	buf = append(buf, `,"severityText":`...)
+	buf = appendJSONString(buf, entry.Level)
+
+	msg := getScratch()
+	for i, arg := range entry.Args {
+		if i > 0 {
+			*msg = append(*msg, '|')
+		}
+		*msg = appendArg(*msg, arg)
+	}
+	buf = append(buf, `,"body":{"stringValue":`...)
+	buf = appendJSONBytes(buf, *msg)
+	buf = append(buf, '}')
+	putScratch(msg)
+
+	attributes := make(Fields, len(entry.Fields)+5)
+	for key, value := range entry.Fields {
+		attributes[key] = value
+	}
+	if traceID, ok := otlpID(entry.Fields[encoder.traceField], 16); ok {
+		buf = append(buf, `,"traceId":"`...)
+		buf = append(buf, traceID...)
+		buf = append(buf, '"')
+		delete(attributes, encoder.traceField)
+	}
+	if spanID, ok := otlpID(entry.Fields[encoder.spanField], 8); ok {
+		buf = append(buf, `,"spanId":"`...)
+		buf = append(buf, spanID...)
+		buf = append(buf, '"')
+		delete(attributes, encoder.spanField)
+	}
+	if entry.Name != "" {
+		attributes["logger.name"] = entry.Name
+	}
+	if entry.Caller != "" {
+		// 调用位置的格式为 file,line:function
+		file, rest := entry.Caller, ""
+		if i := strings.IndexByte(file, ','); i >= 0 {
+			file, rest = file[:i], file[i+1:]
+		}
+		attributes["code.filepath"] = file
+		if i := strings.IndexByte(rest, ':'); i >= 0 {
+			if line, err := strconv.Atoi(rest[:i]); err == nil {
+				attributes["code.lineno"] = line
+			}
+			if rest[i+1:] != "" {
+				attributes["code.function"] = rest[i+1:]
+			}
+		}
+	}
+	if len(entry.Stack) > 0 {
+		attributes["exception.stacktrace"] = formatStack(entry.Stack)
+	}
+	if entry.WithSuffix && entry.Suffix != "" {
+		attributes["log.suffix"] = entry.Suffix
+	}
+	if len(attributes) > 0 {
+		buf = append(buf, `,"attributes":`...)
+		buf = appendOTLPAttributes(buf, attributes)
+	}
+	return append(buf, '}', '\n')
+}
+
+/*
+ * 将级别映射为OpenTelemetry的SeverityNumber
+ */
+func (encoder *otlpEncoder) severityNumber(level string) int {
+	switch level {
+	case "fatal", "panic":
+		return otlpSeverityFatal
+	case "recover":
+		return otlpSeverityError
+	}
+	severity, ok := encoder.severity(level)
+	switch {
+	case !ok:
+		return 0
+	case severity < SeverityTrace:
+		return otlpSeverityDebug
+	case severity < SeverityWarn:
+		return otlpSeverityInfo
+	case severity < SeverityError:
+		return otlpSeverityWarn
+	}
+	return otlpSeverityError
+}
+
+/*
+ * 将trace id/span id转换为十六进制字符串
+ * @param value：字段的值，十六进制字符串、fmt.Stringer(例如OpenTelemetry的TraceID)或者字节数组
+ * @param size：id的字节数
+ * @return (十六进制字符串, 是否为合法的非零id)
+ */
+func otlpID(value interface{}, size int) (string, bool) {
+	var id string
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		id = v
+	case [16]byte:
+		id = hex.EncodeToString(v[:])
+	case [8]byte:
+		id = hex.EncodeToString(v[:])
+	case []byte:
+		id = hex.EncodeToString(v)
+	case fmt.Stringer:
+		id = safeString(v, v.String)
+	default:
+		return "", false
+	}
+	if len(id) != size*2 || strings.Trim(id, "0") == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", false
+	}
+	return strings.ToLower(id), true
+}
+
+/*
+ * 将字段以OTLP KeyValue数组的形式追加到buf，按照key排序
+ */
+func appendOTLPAttributes(buf []byte, fields Fields) []byte {
+	buf = append(buf, '[')
+	for i, key := range sortedFieldKeys(fields) {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `{"key":`...)
+		buf = appendJSONString(buf, key)
+		buf = append(buf, `,"value":`...)
+		buf = appendOTLPValue(buf, fields[key])
+		buf = append(buf, '}')
+	}
+	return append(buf, ']')
+}
+
+/*
+ * 将值以OTLP AnyValue的形式追加到buf，整数按照proto3 JSON的规则输出为字符串，其余类型输出为字符串
+ */
+func appendOTLPValue(buf []byte, v interface{}) []byte {
+	switch value := v.(type) {
+	case bool:
+		buf = append(buf, `{"boolValue":`...)
+		buf = strconv.AppendBool(buf, value)
+	case int:
+		buf = append(buf, `{"intValue":"`...)
+		buf = strconv.AppendInt(buf, int64(value), 10)
+		buf = append(buf, '"')
+	case int64:
+		buf = append(buf, `{"intValue":"`...)
+		buf = strconv.AppendInt(buf, value, 10)
+		buf = append(buf, '"')
+	case int32:
+		buf = append(buf, `{"intValue":"`...)
+		buf = strconv.AppendInt(buf, int64(value), 10)
+		buf = append(buf, '"')
+	case uint32:
+		buf = append(buf, `{"intValue":"`...)
+		buf = strconv.AppendUint(buf, uint64(value), 10)
+		buf = append(buf, '"')
+	case float64:
+		buf = append(buf, `{"doubleValue":`...)
+		buf = appendJSONFloat(buf, value, 64)
+	case float32:
+		buf = append(buf, `{"doubleValue":`...)
+		buf = appendJSONFloat(buf, float64(value), 32)
+	default:
+		buf = append(buf, `{"stringValue":`...)
+		buf = appendJSONBytes(buf, appendArg(nil, v))
+	}
+	return append(buf, '}')
+}
+
+// Write implements io.Writer, each line is an encoded LogRecord
+func (e *OTLPExporter) Write(p []byte) (int, error) {
+	select {
+	case <-e.stop:
+		return 0, ErrClosed
+	default:
+	}
+	n := len(p)
+	for len(p) > 0 {
+		record := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			record, p = p[:i], p[i+1:]
+		} else {
+			p = nil
+		}
+		if len(record) == 0 {
+			continue
+		}
+		select {
+		case e.queue <- append([]byte(nil), record...):
+		default:
+			e.reporter.drop()
+			return 0, ErrOTLPQueueFull
+		}
+	}
+	return n, nil
+}
+
+// Close exports the queued records and stops the exporting goroutine
+/*
+ * 发送队列中剩余的记录之后停止发送协程，超过DrainTimeout时放弃剩余记录，重复调用是安全的
+ * 需要在RemoveSink或者Logger.Close之后调用，之后写入的记录返回ErrClosed
+ */
+func (e *OTLPExporter) Close() error {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+	<-e.done
+	return nil
+}
+
+/*
+ * 发送协程：凑够BatchSize条或者等待Linger之后发送一批
+ */
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+	batch := make([][]byte, 0, e.config.BatchSize)
+	linger := time.NewTimer(e.config.Linger)
+	linger.Stop()
+	for {
+		select {
+		case record := <-e.queue:
+			if len(batch) == 0 {
+				linger.Reset(e.config.Linger)
+			}
+			if batch = append(batch, record); len(batch) >= e.config.BatchSize {
+				linger.Stop()
+				batch = e.export(batch, nil)
+			}
+		case <-linger.C:
+			batch = e.export(batch, nil)
+		case <-e.stop:
+			linger.Stop()
+			e.drain(batch)
+			return
+		}
+	}
+}
+
+/*
+ * Close时发送剩余的记录
+ * @param batch：尚未发送的一批
+ */
+func (e *OTLPExporter) drain(batch [][]byte) {
+	var deadline <-chan time.Time
+	if e.config.DrainTimeout > 0 {
+		timer := time.NewTimer(e.config.DrainTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case <-deadline:
+			e.abandon(len(batch) + len(e.queue))
+			return
+		default:
+		}
+	fill:
+		for len(batch) < e.config.BatchSize {
+			select {
+			case record := <-e.queue:
+				batch = append(batch, record)
+			default:
+				break fill
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+		batch = e.export(batch, deadline)
+	}
+}
+
+/*
+ * 发送一批记录，可以重试的错误按照指数退避重试，最终失败时上报错误并计入丢弃数
+ * @param deadline：Close超时时停止重试，为nil表示不限制
+ * @return 清空之后的batch，可以继续复用
+ */
+func (e *OTLPExporter) export(batch [][]byte, deadline <-chan time.Time) [][]byte {
+	if len(batch) == 0 {
+		return batch
+	}
+	raw := e.request(batch)
+	body, encoding := e.compress(raw)
+	backoff := e.config.MinBackoff
+	var err error
+retry:
+	for attempt := 1; ; attempt++ {
+		var retryable bool
+		retryable, err = e.post(body, encoding)
+		if errors.Is(err, errOTLPEncoding) && e.downgrade(err) {
+			// 改用下一个压缩算法之后立即重新发送，不计入重试次数
+			body, encoding = e.compress(raw)
+			attempt--
+			continue
+		}
+		if err == nil || !retryable || attempt >= e.config.MaxAttempts {
+			break
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-deadline:
+			timer.Stop()
+			break retry
+		}
+		if backoff *= 2; backoff > e.config.MaxBackoff {
+			backoff = e.config.MaxBackoff
+		}
+	}
+	if err != nil {
+		e.reporter.writeFailed("OTLP.Export", err)
+		for range batch {
+			e.reporter.drop()
+		}
+	}
+	for i := range batch {
+		batch[i] = nil
+	}
+	return batch[:0]
+}
+
+/*
+ * 生成ExportLogsServiceRequest的JSON内容
+ */
+func (e *OTLPExporter) request(batch [][]byte) []byte {
+	size := 128 + len(e.resource)
+	for _, record := range batch {
+		size += len(record) + 1
+	}
+	body := make([]byte, 0, size)
+	body = append(body, `{"resourceLogs":[{"resource":{"attributes":`...)
+	body = append(body, e.resource...)
+	body = append(body, `},"scopeLogs":[{"scope":{"name":"`+otlpInstrumentationLib+`"},"logRecords":[`...)
+	for i, record := range batch {
+		if i > 0 {
+			body = append(body, ',')
+		}
+		body = append(body, record...)
+	}
+	return append(body, `]}]}]}`...)
+}
+
+/*
+ * 发送一次请求
+ * @return (是否可以重试, error)
+ */
+func (e *OTLPExporter) post(body []byte, encoding string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	for key, value := range e.config.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		switch resp.StatusCode {
+		case http.StatusUnsupportedMediaType:
+			if encoding != "" {
+				return false, fmt.Errorf("%w %s: %s", errOTLPEncoding, encoding, strings.TrimSpace(string(message)))
+			}
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true, fmt.Errorf("otlp export: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+		}
+		return false, fmt.Errorf("otlp export: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return false, nil
+}
+
+/*
+ * 按照当前的压缩算法压缩请求内容，压缩失败时不压缩
+ * @return (请求内容, Content-Encoding)，不压缩时Content-Encoding为空
+ */
+func (e *OTLPExporter) compress(raw []byte) ([]byte, string) {
+	if e.codec == nil {
+		return raw, ""
+	}
+	body, err := compressBatch(e.codec, nil, raw)
+	if err != nil {
+		e.reporter.report("OTLP.Compress", err)
+		return raw, ""
+	}
+	return body, e.codec.Name()
+}
+
+/*
+ * 收集端不支持当前的压缩算法时改用下一个，只在发送协程中调用
+ * @return 是否还有可以尝试的算法(包括不压缩)
+ */
+func (e *OTLPExporter) downgrade(cause error) bool {
+	if e.codec == nil {
+		return false
+	}
+	e.reporter.report("OTLP.Compression", cause)
+	codec, err := negotiateCompression(e.fallback, nil)
+	if err != nil {
+		codec = nil
+	}
+	e.codec, e.fallback = codec, remainingCompressions(e.fallback, codec)
+	return true
+}
+
+/*
+ * 返回优先顺序中排在codec之后的算法，codec为nil时返回空
+ */
+func remainingCompressions(preferred []string, codec Compressor) []string {
+	if codec == nil {
+		return nil
+	}
+	for i, name := range preferred {
+		if name == codec.Name() {
+			return preferred[i+1:]
+		}
+	}
+	return nil
+}
+
+/*
+ * 记录Close超时放弃的记录
+ */
+func (e *OTLPExporter) abandon(n int) {
+	for i := 0; i < n; i++ {
+		e.reporter.drop()
+	}
+	if n > 0 {
+		e.reporter.report("OTLP.Close", errors.New("logger: otlp drain timed out, records dropped"))
+	}
+}
@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"fmt"
+)
+
+// OverflowPolicy decides what happens to a buffer when the write queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the write queue has room, the default
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued buffer to make room
+	OverflowDropOldest
+	// OverflowDropNewest discards the buffer being queued
+	OverflowDropNewest
+)
+
+// String returns the name of the policy
+func (policy OverflowPolicy) String() string {
+	switch policy {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDropNewest:
+		return "drop-newest"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(policy))
+}
+
+// ParseOverflowPolicy parses block, drop-oldest or drop-newest
+/*
+ * 解析写入队列满时的处理方式，用于配置文件以及命令行参数
+ * @param name：block、drop-oldest或者drop-newest
+ * @return 名称不合法时返回error
+ */
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	for _, policy := range []OverflowPolicy{OverflowBlock, OverflowDropOldest, OverflowDropNewest} {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+	return OverflowBlock, fmt.Errorf("logger: unknown overflow policy %q", name)
+}
+
+// WithOverflowPolicy sets how a full write queue is handled
+/*
+ * 设置写入队列满时的处理方式，默认阻塞等待，此时磁盘变慢会导致buffer在内存中堆积
+ * 丢弃的buffer数计入Stats().DroppedBuffers，可以据此对日志丢失告警
+ * 同时开启WithSpool时先写入溢出文件，溢出文件已满时再按照该方式处理
+ * @param policy：OverflowBlock、OverflowDropOldest或者OverflowDropNewest
+ */
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(logger *Logger) {
+		logger.overflow = policy
+	}
+}
+
+/*
+ * 按照处理方式将buffer放入写入队列，调用方需要持有bufferLock，之后调用reset获取新的buffer
+ * @param bufferQueue：写入队列
+ * @param policy：写入队列满时的处理方式
+ * @param r：丢弃buffer时计数
+ */
+func (logger *LoggerBuffer) sendBuffer(bufferQueue chan queuedBuffer, policy OverflowPolicy, r *reporter) {
+	switch policy {
+	case OverflowDropNewest:
+		select {
+		case bufferQueue <- logger.queued():
+		default:
+			r.dropBuffer()
+			putBuffer(logger.bufferContent)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case bufferQueue <- logger.queued():
+				return
+			default:
+			}
+			select {
+			case dropped := <-bufferQueue:
+				r.dropBuffer()
+				putBuffer(dropped.buffer)
+			default:
+			}
+		}
+	default:
+		bufferQueue <- logger.queued()
+	}
+}
+
+/*
+ * 将当前buffer按照处理方式写入队列并重新分配buffer
+ */
+func (logger *LoggerBuffer) writeBufferWithPolicy(bufferQueue chan queuedBuffer, policy OverflowPolicy, r *reporter) {
+	logger.bufferLock.Lock()
+	defer logger.bufferLock.Unlock()
+	if logger.bufferContent.Len() == 0 {
+		return
+	}
+	logger.sendBuffer(bufferQueue, policy, r)
+	logger.reset()
+}
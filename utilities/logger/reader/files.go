@@ -0,0 +1,146 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 与logger包中的命名保持一致
+const (
+	hourFormat = "2006010215"
+	dateFormat = "2006-01-02"
+	gzipSuffix = ".gz"
+)
+
+// logFile 一个日志文件以及从文件名中解析出的时间段
+type logFile struct {
+	path   string
+	period time.Time // 切分文件所属时间段的开始时间，正在写入的文件为零值
+	order  int       // 同一时间段内的切分序号，没有序号时为-1
+	live   bool      // 正在写入的文件
+}
+
+// Files lists the live file, rotated files and backups of a log file in write order
+/*
+ * 获取一个日志文件(级别文件、Channel或者单文件模式的共用文件)的所有文件，按照写入顺序排列：
+ * 备份目录(backupDir/2006-01-02/)以及日志目录中的切分文件按照时间段、切分序号排序，正在写入的文件在最后
+ * 压缩文件(.gz)与未压缩文件同名时只返回压缩之前的文件，正在压缩的临时文件不返回
+ * @param path：日志文件路径，例如/data/log/saver/saver-error.log
+ * @param backupDir：备份目录，为空表示不查找备份
+ * @return (文件路径, error)；读取目录失败时返回error
+ */
+func Files(path, backupDir string) ([]string, error) {
+	files, err := listFiles(path, backupDir)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
+
+/*
+ * 查找日志文件的所有文件并排序
+ */
+func listFiles(path, backupDir string) ([]logFile, error) {
+	base := filepath.Base(path)
+	dirs := []string{filepath.Dir(path)}
+	if backupDir != "" {
+		days, err := filepath.Glob(filepath.Join(backupDir, "[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]"))
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, days...)
+		dirs = append(dirs, backupDir)
+	}
+
+	seen := make(map[string]bool)
+	var files []logFile
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			f, ok := parseRotated(base, entry.Name())
+			if !ok || f.live && dir != dirs[0] {
+				continue
+			}
+			f.path = filepath.Join(dir, entry.Name())
+			// 压缩中断时可能同时存在压缩文件以及原文件，只读取原文件
+			key := strings.TrimSuffix(entry.Name(), gzipSuffix)
+			if seen[key] {
+				if strings.HasSuffix(entry.Name(), gzipSuffix) {
+					continue
+				}
+				files = removeFile(files, key)
+			}
+			seen[key] = true
+			files = append(files, f)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.live != b.live {
+			return b.live
+		}
+		if !a.period.Equal(b.period) {
+			return a.period.Before(b.period)
+		}
+		return a.order < b.order
+	})
+	return files, nil
+}
+
+/*
+ * 按照文件名(去掉.gz之后)删除已经加入的文件
+ */
+func removeFile(files []logFile, key string) []logFile {
+	for i, f := range files {
+		if strings.TrimSuffix(filepath.Base(f.path), gzipSuffix) == key {
+			return append(files[:i], files[i+1:]...)
+		}
+	}
+	return files
+}
+
+/*
+ * 解析切分文件名 base.2006010215[.N][.gz]，base本身为正在写入的文件
+ * @return (文件信息, 是否属于该日志文件)
+ */
+func parseRotated(base, name string) (logFile, bool) {
+	if name == base {
+		return logFile{order: -1, live: true}, true
+	}
+	if !strings.HasPrefix(name, base+".") {
+		return logFile{}, false
+	}
+	rest := strings.TrimSuffix(name[len(base)+1:], gzipSuffix)
+	parts := strings.Split(rest, ".")
+	if len(parts) > 2 || len(parts[0]) != len(hourFormat) {
+		return logFile{}, false
+	}
+	period, err := time.ParseInLocation(hourFormat, parts[0], time.Local)
+	if err != nil {
+		return logFile{}, false
+	}
+	f := logFile{period: period, order: -1}
+	if len(parts) == 2 {
+		if f.order, err = strconv.Atoi(parts[1]); err != nil {
+			return logFile{}, false
+		}
+	}
+	return f, true
+}
@@ -0,0 +1,451 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"container/heap"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// 与logger包默认的时间格式保持一致
+const (
+	textTimeLayout = "2006-01-02 15:04:05.000"
+	jsonTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// orderSlack 同一个文件中记录时间允许的乱序范围
+const orderSlack = time.Minute
+
+// Config describes one log file and how its records are written
+type Config struct {
+	Path       string           // 日志文件路径，例如/data/log/saver/saver-error.log
+	BackupDir  string           // 备份目录，与NewLogger的backupDir相同，为空表示不读取备份
+	Level      string           // 没有级别标记的文本记录的级别，为空时从文件名 xxx-级别.log 中获取
+	TimeLayout string           // 记录的时间格式，与WithTimeFormat相同，为空时使用编码器的默认格式
+	Location   *time.Location   // 文本记录时间的时区，为nil时使用本地时间，WithUTC写入的日志需要设置为time.UTC
+	Keys       logger.KeyLookup // 加密日志的密钥查找，参考WithEncryption，为nil表示日志没有加密
+}
+
+// Query selects the records returned by Reader.Query
+/*
+ * 查询条件，零值表示所有记录
+ */
+type Query struct {
+	Start    time.Time // 开始时间(包含)，零值表示不限制
+	End      time.Time // 结束时间(不包含)，零值表示不限制
+	Levels   []string  // 级别，不区分大小写，为空表示所有级别
+	Contains string    // 记录中包含的字符串，区分大小写，为空表示不限制
+}
+
+// Record is a log record read from a file
+type Record struct {
+	Time  time.Time
+	Level string // 级别，小写；无法判断时为空
+	Line  string // 原始记录，不包含换行符
+	File  string // 记录所在的文件
+}
+
+// Reader reads the records of a set of log files merged in time order
+type Reader struct {
+	sources []source
+}
+
+// source 一个日志文件的配置以及所有文件
+type source struct {
+	config Config
+	files  []logFile
+}
+
+// Open collects the files of the given log files for querying
+/*
+ * 打开一个或者多个日志文件，例如同一个Logger的多个级别文件，查询时所有文件的记录按照时间合并
+ * 只查找文件，不打开文件；文件在Query返回的迭代器第一次调用Next时打开
+ * 例如查询14:00到14:05之间的错误：
+ *     r, _ := reader.Open(reader.Config{Path: "/data/log/saver/saver-error.log", BackupDir: "/data/log/saver/backup"})
+ *     it := r.Query(reader.Query{Start: start, End: start.Add(5 * time.Minute)})
+ *     defer it.Close()
+ *     for it.Next() { fmt.Println(it.Record().Line) }
+ *     if err := it.Err(); err != nil { ... }
+ * @param configs：日志文件
+ * @return (Reader, error)；读取目录失败时返回error
+ */
+func Open(configs ...Config) (*Reader, error) {
+	r := &Reader{}
+	for _, config := range configs {
+		files, err := listFiles(config.Path, config.BackupDir)
+		if err != nil {
+			return nil, err
+		}
+		if config.Level == "" {
+			config.Level = levelFromPath(config.Path)
+		}
+		if config.Location == nil {
+			config.Location = time.Local
+		}
+		r.sources = append(r.sources, source{config: config, files: files})
+	}
+	return r, nil
+}
+
+// Files returns the paths of all files that Query may read
+func (r *Reader) Files() []string {
+	var paths []string
+	for _, s := range r.sources {
+		for _, f := range s.files {
+			paths = append(paths, f.path)
+		}
+	}
+	return paths
+}
+
+// Query iterates the records matching q in time order
+/*
+ * 按照时间顺序迭代满足条件的记录，时间相同时按照文件的写入顺序
+ * 根据文件名中的时间段以及文件修改时间跳过时间范围之外的文件；同一个文件内时间超过End之后不再继续读取
+ * 无法解析时间的行(例如TimeNone写入的记录)会被跳过；读取文件失败时迭代结束并通过Err返回
+ * @param q：查询条件
+ * @return 迭代器，使用完之后需要调用Close
+ */
+func (r *Reader) Query(q Query) *Iterator {
+	it := &Iterator{query: q}
+	if len(q.Levels) > 0 {
+		it.levels = make(map[string]bool, len(q.Levels))
+		for _, level := range q.Levels {
+			it.levels[strings.ToLower(level)] = true
+		}
+	}
+	for _, s := range r.sources {
+		for _, f := range s.files {
+			if !q.End.IsZero() && !f.live && !f.period.IsZero() && !f.period.Before(q.End) {
+				continue
+			}
+			if !q.Start.IsZero() {
+				if stat, err := os.Stat(f.path); err == nil && stat.ModTime().Before(q.Start) {
+					continue
+				}
+			}
+			it.pending = append(it.pending, &cursor{config: s.config, file: f, seq: len(it.pending)})
+		}
+	}
+	return it
+}
+
+// Iterator walks the records selected by Reader.Query
+type Iterator struct {
+	query   Query
+	levels  map[string]bool
+	pending []*cursor // 尚未打开的文件
+	cursors cursorHeap
+	started bool
+	record  Record
+	err     error
+}
+
+// Next advances to the next matching record, returning false at the end or on error
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		for _, c := range it.pending {
+			if err := c.open(); err != nil {
+				it.err = err
+				return false
+			}
+			if c.advance(it.query.End) {
+				it.cursors = append(it.cursors, c)
+			} else if c.err != nil {
+				it.err = c.err
+				return false
+			}
+		}
+		it.pending = nil
+		heap.Init(&it.cursors)
+	}
+	for len(it.cursors) > 0 {
+		c := it.cursors[0]
+		record := c.record
+		if c.advance(it.query.End) {
+			heap.Fix(&it.cursors, 0)
+		} else {
+			heap.Pop(&it.cursors)
+			if c.err != nil {
+				it.err = c.err
+				return false
+			}
+		}
+		if it.match(&record) {
+			it.record = record
+			return true
+		}
+	}
+	return false
+}
+
+// Record returns the current record
+func (it *Iterator) Record() Record {
+	return it.record
+}
+
+// Err returns the error that stopped the iteration, nil at the normal end
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close closes the files opened by the iterator
+func (it *Iterator) Close() error {
+	for _, c := range it.cursors {
+		c.close()
+	}
+	it.cursors = nil
+	return nil
+}
+
+/*
+ * 判断记录是否满足查询条件，结束时间在读取时已经判断
+ */
+func (it *Iterator) match(record *Record) bool {
+	if !it.query.Start.IsZero() && record.Time.Before(it.query.Start) {
+		return false
+	}
+	if it.levels != nil && !it.levels[record.Level] {
+		return false
+	}
+	return it.query.Contains == "" || strings.Contains(record.Line, it.query.Contains)
+}
+
+// cursor 一个文件的读取位置
+type cursor struct {
+	config Config
+	file   logFile
+	seq    int // 文件的写入顺序，时间相同时排序使用
+	f      *os.File
+	gz     *gzip.Reader
+	r      *bufio.Reader
+	record Record // 当前记录
+	err    error
+}
+
+/*
+ * 打开文件，压缩文件以及加密文件使用对应的Reader
+ */
+func (c *cursor) open() error {
+	f, err := os.Open(c.file.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 查找之后被切分、备份或者清理，按照空文件处理
+			c.r = bufio.NewReader(bytes.NewReader(nil))
+			return nil
+		}
+		return err
+	}
+	c.f = f
+	var r io.Reader = f
+	if strings.HasSuffix(c.file.path, gzipSuffix) {
+		if c.gz, err = gzip.NewReader(f); err != nil {
+			f.Close()
+			return err
+		}
+		r = c.gz
+	}
+	if c.config.Keys != nil {
+		r = logger.NewDecryptReader(r, c.config.Keys)
+	}
+	c.r = bufio.NewReaderSize(r, 64*1024)
+	return nil
+}
+
+/*
+ * 读取下一条可以解析时间并且早于结束时间的记录
+ * 同一个文件中的记录基本按照时间排列，多个协程同时写入以及WithCascade转发的记录可能稍有乱序，
+ * 因此超过结束时间orderSlack之后才停止读取该文件
+ * @param end：结束时间，零值表示不限制
+ * @return 读取到记录时返回true；文件结束、超过结束时间或者出错时返回false，并关闭文件
+ */
+func (c *cursor) advance(end time.Time) bool {
+	for {
+		line, err := c.r.ReadString('\n')
+		if len(line) > 0 {
+			line = strings.TrimRight(line, "\r\n")
+			if record, ok := c.parse(line); ok {
+				if end.IsZero() || record.Time.Before(end) {
+					c.record = record
+					return true
+				}
+				if !record.Time.Before(end.Add(orderSlack)) {
+					c.close()
+					return false
+				}
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				c.err = err
+			}
+			c.close()
+			return false
+		}
+	}
+}
+
+/*
+ * 关闭文件
+ */
+func (c *cursor) close() {
+	if c.gz != nil {
+		c.gz.Close()
+		c.gz = nil
+	}
+	if c.f != nil {
+		c.f.Close()
+		c.f = nil
+	}
+}
+
+/*
+ * 解析一行记录的时间以及级别，JSON记录以{开头，其余按照文本格式解析
+ */
+func (c *cursor) parse(line string) (Record, bool) {
+	record := Record{Line: line, File: c.file.path}
+	var ok bool
+	if strings.HasPrefix(line, "{") {
+		record.Time, record.Level, ok = c.parseJSON(line)
+	} else {
+		record.Time, record.Level, ok = c.parseText(line)
+	}
+	return record, ok
+}
+
+/*
+ * 文本格式：时间|[级别标记]|...，级别标记为全大写的[XXX]，模块名称包含小写字母时不会被当作级别
+ */
+func (c *cursor) parseText(line string) (time.Time, string, bool) {
+	fields := strings.SplitN(line, "|", 3)
+	t, ok := c.parseTime(fields[0])
+	if !ok {
+		return time.Time{}, "", false
+	}
+	level := c.config.Level
+	if len(fields) > 1 {
+		tag := fields[1]
+		if len(tag) > 2 && tag[0] == '[' && tag[len(tag)-1] == ']' && strings.ToUpper(tag) == tag {
+			level = tag[1 : len(tag)-1]
+		}
+	}
+	return t, strings.ToLower(level), true
+}
+
+/*
+ * JSON格式：time字段为字符串或者毫秒时间戳，level字段为级别
+ */
+func (c *cursor) parseJSON(line string) (time.Time, string, bool) {
+	var head struct {
+		Time  json.RawMessage `json:"time"`
+		Level string          `json:"level"`
+	}
+	if err := json.Unmarshal([]byte(line), &head); err != nil || len(head.Time) == 0 {
+		return time.Time{}, "", false
+	}
+	var t time.Time
+	var ok bool
+	if head.Time[0] == '"' {
+		var s string
+		if json.Unmarshal(head.Time, &s) != nil {
+			return time.Time{}, "", false
+		}
+		layout := c.config.TimeLayout
+		if layout == "" {
+			layout = jsonTimeLayout
+		}
+		t, ok = c.parseLayout(layout, s)
+	} else {
+		t, ok = parseMillis(string(head.Time))
+	}
+	level := head.Level
+	if level == "" {
+		level = c.config.Level
+	}
+	return t, strings.ToLower(level), ok
+}
+
+/*
+ * 按照文本格式的时间格式解析
+ */
+func (c *cursor) parseTime(s string) (time.Time, bool) {
+	switch c.config.TimeLayout {
+	case logger.TimeNone:
+		return time.Time{}, false
+	case logger.TimeEpochMillis:
+		return parseMillis(s)
+	case "":
+		return c.parseLayout(textTimeLayout, s)
+	}
+	return c.parseLayout(c.config.TimeLayout, s)
+}
+
+/*
+ * 按照layout解析时间，没有时区信息时使用配置的时区
+ */
+func (c *cursor) parseLayout(layout, s string) (time.Time, bool) {
+	t, err := time.ParseInLocation(layout, s, c.config.Location)
+	return t, err == nil
+}
+
+/*
+ * 解析毫秒时间戳
+ */
+func parseMillis(s string) (time.Time, bool) {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+/*
+ * 从文件名 xxx-级别.log 中获取级别，不满足格式时为空
+ */
+func levelFromPath(path string) string {
+	base := filepath.Base(path)
+	if !strings.HasSuffix(base, ".log") {
+		return ""
+	}
+	name := strings.TrimSuffix(base, ".log")
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+// cursorHeap 按照当前记录的时间排序的文件
+type cursorHeap []*cursor
+
+func (h cursorHeap) Len() int { return len(h) }
+
+func (h cursorHeap) Less(i, j int) bool {
+	if !h[i].record.Time.Equal(h[j].record.Time) {
+		return h[i].record.Time.Before(h[j].record.Time)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h cursorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *cursorHeap) Push(x interface{}) { *h = append(*h, x.(*cursor)) }
+
+func (h *cursorHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,63 @@
+package reader
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// fuzzLevels 生成记录使用的级别
+var fuzzLevels = []string{"debug", "trace", "warn", "error"}
+
+/*
+ * 任意一行内容解析时不能panic；logger编码的记录解析出的时间以及级别与写入时相同
+ */
+func FuzzParse(f *testing.F) {
+	f.Add("2024-05-06 07:08:09.123|[WARN]|upstream slow", "msg|a=b", int64(1714979289123), uint8(2))
+	f.Add(`{"schema":1,"time":1714979289123,"level":"error","msg":"x"}`, "", int64(0), uint8(3))
+	f.Add(`{"time":"2024-05-06T07:08:09.123+08:00"`, "\n\r\xff", int64(-1), uint8(0))
+	f.Add("|[]|", "[ERROR]", int64(1<<40), uint8(1))
+	f.Fuzz(func(t *testing.T, line, msg string, millis int64, level uint8) {
+		for _, layout := range []string{"", logger.TimeEpochMillis, logger.TimeNone, time.RFC3339} {
+			c := &cursor{config: Config{Level: "info", TimeLayout: layout, Location: time.UTC}}
+			if record, ok := c.parse(line); ok && record.Level != strings.ToLower(record.Level) {
+				t.Fatalf("layout %q: level %q is not lower case for %q", layout, record.Level, line)
+			}
+		}
+
+		entry := &logger.Entry{
+			Time:  time.UnixMilli(millis % (1 << 40)).UTC(),
+			Level: fuzzLevels[int(level)%len(fuzzLevels)],
+			Args:  []interface{}{msg},
+		}
+		c := &cursor{config: Config{Location: time.UTC}}
+		for name, encoder := range map[string]logger.Encoder{"text": logger.TextEncoder{LevelTag: true}, "json": logger.JSONEncoder{}} {
+			encoded := strings.TrimRight(string(encoder.Encode(entry)), "\n")
+			record, ok := c.parse(encoded)
+			if !ok {
+				t.Fatalf("%s: cannot parse %q", name, encoded)
+			}
+			if !record.Time.Equal(entry.Time) || record.Level != entry.Level {
+				t.Fatalf("%s: got %v %q from %q, want %v %q", name, record.Time, record.Level, encoded, entry.Time, entry.Level)
+			}
+		}
+	})
+}
+
+func FuzzParseRotated(f *testing.F) {
+	f.Add("app-error.log.2024050607")
+	f.Add("app-error.log.2024050607.3.gz")
+	f.Add("app-error.log.2024050607.x")
+	f.Add("app-error.log.")
+	f.Fuzz(func(t *testing.T, name string) {
+		file, ok := parseRotated("app-error.log", name)
+		if !ok {
+			return
+		}
+		if !file.live && !strings.HasPrefix(name, "app-error.log."+file.period.Format(hourFormat)) {
+			t.Fatalf("%q: period %v does not match the name", name, file.period)
+		}
+	})
+}
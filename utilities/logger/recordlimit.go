@@ -0,0 +1,258 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// RecordPolicy selects how records larger than the maximum record size are handled
+type RecordPolicy int
+
+const (
+	// RecordTruncate shortens the largest arguments and fields, the default
+	RecordTruncate RecordPolicy = iota
+	// RecordSplit splits the largest argument across several records
+	RecordSplit
+	// RecordDrop discards the record
+	RecordDrop
+)
+
+// 记录大小限制的参数
+const (
+	minRecordSize     = 256 // WithMaxRecordSize允许的最小值
+	minSplitChunk     = 128 // 拆分时每条记录至少包含的内容，不足时改为截断
+	maxTruncateRounds = 16  // 每次截断一个参数或者字段，最多截断的次数
+)
+
+// String returns the name of the policy
+func (policy RecordPolicy) String() string {
+	switch policy {
+	case RecordTruncate:
+		return "truncate"
+	case RecordSplit:
+		return "split"
+	case RecordDrop:
+		return "drop"
+	}
+	return fmt.Sprintf("RecordPolicy(%d)", int(policy))
+}
+
+// ParseRecordPolicy parses truncate, split or drop
+/*
+ * 解析超长记录的处理方式，用于配置文件以及命令行参数
+ * @param name：truncate、split或者drop
+ * @return 名称不合法时返回error
+ */
+func ParseRecordPolicy(name string) (RecordPolicy, error) {
+	for _, policy := range []RecordPolicy{RecordTruncate, RecordSplit, RecordDrop} {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+	return RecordTruncate, fmt.Errorf("logger: unknown record policy %q", name)
+}
+
+// WithMaxRecordSize limits the encoded size of a single record
+/*
+ * 限制单条记录编码之后的大小，避免一个很大的参数(例如打印了整个请求体)撑大buffer以及下游的解析程序：
+ *   RecordTruncate：从最长的参数以及字段开始截断，截断处追加 ...[truncated 1.2MB] 标记被截掉的大小
+ *   RecordSplit：将最长的参数拆分到多条记录中，每一段追加 ...[part 1/3] 标记，其余内容在每条记录中重复；
+ *                其余内容已经接近上限或者最长的是字段时改为截断
+ *   RecordDrop：丢弃记录，计入Stats().Dropped
+ * 截断以及拆分在编码之前对参数进行，JSON等格式仍然合法；转义导致仍然超过上限时直接截断编码结果
+ * 超过上限的记录数计入Stats().Truncated，包括被丢弃的记录；通道以及Write写入的自定义文件同样生效
+ * @param max：单条记录的最大字节数(包括换行符)，<=0表示不限制，小于256时NewLogger返回error
+ * @param policy：超过上限时的处理方式
+ */
+func WithMaxRecordSize(max int, policy RecordPolicy) Option {
+	return func(logger *Logger) {
+		if max > 0 && max < minRecordSize {
+			logger.initErr = fmt.Errorf("logger: max record size %d below %d", max, minRecordSize)
+			return
+		}
+		logger.maxRecord = max
+		logger.recordPolicy = policy
+	}
+}
+
+/*
+ * 处理超过大小上限的记录，执行过hook的记录不会再次执行hook
+ * @param entry：记录
+ * @param size：编码之后的大小
+ * @return 处理之后的编码结果，拆分时包含多条记录，丢弃时返回空字符串
+ */
+func (logger *Logger) limitRecord(entry *Entry, size int) string {
+	logger.reporter.truncate()
+	var entries []Entry
+	switch logger.recordPolicy {
+	case RecordDrop:
+		logger.reporter.drop()
+		return ""
+	case RecordSplit:
+		entries = splitEntry(entry, size, logger.maxRecord)
+	}
+	if entries == nil {
+		// 转义会放大编码之后的大小，每次只截断一个参数或者字段，重新编码之后再判断
+		entries = []Entry{*entry}
+		for round := 0; round < maxTruncateRounds && size > logger.maxRecord; round++ {
+			truncated, ok := truncateEntry(&entries[0], size-logger.maxRecord)
+			if !ok {
+				break
+			}
+			entries[0] = truncated
+			size = len(logger.encoder.Encode(&entries[0]))
+		}
+	}
+
+	var content []byte
+	for i := range entries {
+		encoded := logger.encoder.Encode(&entries[i])
+		if len(encoded) > logger.maxRecord {
+			encoded = cutRecord(encoded, logger.maxRecord)
+		}
+		logger.encodeSinks(&entries[i], encoded)
+		content = append(content, encoded...)
+	}
+	return string(content)
+}
+
+// limitValue 记录中可以截断的一个参数或者字段
+type limitValue struct {
+	arg   int    // 参数下标，字段时为-1
+	field string // 字段名
+	text  string // 文本形式
+}
+
+/*
+ * 截断最长的参数或者字段，截断处追加被截掉的大小
+ * @param entry：记录，Args以及Fields可能与其他记录共享，不会修改
+ * @param excess：需要减少的字节数
+ * @return (截断之后的记录, 是否截断)，所有参数以及字段都已经无法截断时返回false
+ */
+func truncateEntry(entry *Entry, excess int) (Entry, bool) {
+	longest := limitValue{arg: -1}
+	for i, arg := range entry.Args {
+		if text := string(appendArg(nil, arg)); len(text) > len(longest.text) {
+			longest = limitValue{arg: i, text: text}
+		}
+	}
+	for key, value := range entry.Fields {
+		if text := string(appendArg(nil, value)); len(text) > len(longest.text) {
+			longest = limitValue{arg: -1, field: key, text: text}
+		}
+	}
+
+	// 标记本身同样占用长度，预留最长的标记
+	cut := utf8Prefix(longest.text, len(longest.text)-excess-len("...[truncated 1023.9MB]"))
+	text := cut + "...[truncated " + formatByteSize(len(longest.text)-len(cut)) + "]"
+	if len(text) >= len(longest.text) {
+		return *entry, false
+	}
+	truncated := *entry
+	if longest.arg >= 0 {
+		truncated.Args = append([]interface{}(nil), entry.Args...)
+		truncated.Args[longest.arg] = text
+		return truncated, true
+	}
+	truncated.Fields = make(Fields, len(entry.Fields))
+	for key, value := range entry.Fields {
+		truncated.Fields[key] = value
+	}
+	truncated.Fields[longest.field] = text
+	return truncated, true
+}
+
+/*
+ * 将最长的参数拆分到多条记录中
+ * @param entry：记录
+ * @param size：编码之后的大小
+ * @param max：单条记录的最大字节数
+ * @return 拆分之后的记录，最长的是字段或者剩余空间不足时返回nil
+ */
+func splitEntry(entry *Entry, size, max int) []Entry {
+	longest, text := -1, ""
+	for i, arg := range entry.Args {
+		if s := string(appendArg(nil, arg)); len(s) > len(text) {
+			longest, text = i, s
+		}
+	}
+	for _, value := range entry.Fields {
+		if len(appendArg(nil, value)) > len(text) {
+			return nil
+		}
+	}
+	if longest < 0 {
+		return nil
+	}
+	// 其余内容的大小，标记预留到9999段
+	chunk := max - (size - len(text)) - len("...[part 9999/9999]")
+	if chunk < minSplitChunk {
+		return nil
+	}
+
+	var parts []string
+	for rest := text; rest != ""; {
+		part := utf8Prefix(rest, chunk)
+		if part == "" {
+			// chunk不足一个字符时至少取一个字符
+			_, n := utf8.DecodeRuneInString(rest)
+			part = rest[:n]
+		}
+		parts = append(parts, part)
+		rest = rest[len(part):]
+	}
+	total := strconv.Itoa(len(parts))
+	entries := make([]Entry, len(parts))
+	for i, part := range parts {
+		entries[i] = *entry
+		entries[i].Args = append([]interface{}(nil), entry.Args...)
+		entries[i].Args[longest] = part + "...[part " + strconv.Itoa(i+1) + "/" + total + "]"
+	}
+	return entries
+}
+
+/*
+ * 直接截断编码结果，保留换行符，用于截断参数之后仍然超过上限的记录
+ */
+func cutRecord(content []byte, max int) []byte {
+	body := content
+	if n := len(body); n > 0 && body[n-1] == '\n' {
+		body = body[:n-1]
+	}
+	// 预留最长的标记
+	cut := utf8Prefix(string(body), max-len("...[truncated 1023.9MB]\n"))
+	return append([]byte(cut), "...[truncated "+formatByteSize(len(body)-len(cut))+"]\n"...)
+}
+
+/*
+ * 获取不超过n字节的前缀，不会截断多字节字符
+ */
+func utf8Prefix(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+/*
+ * 格式化字节数，例如512B、3.4KB、1.2MB
+ */
+func formatByteSize(n int) string {
+	size := int64(n)
+	switch {
+	case size >= GB:
+		return strconv.FormatFloat(float64(size)/float64(GB), 'f', 1, 64) + "GB"
+	case size >= MB:
+		return strconv.FormatFloat(float64(size)/float64(MB), 'f', 1, 64) + "MB"
+	case size >= KB:
+		return strconv.FormatFloat(float64(size)/float64(KB), 'f', 1, 64) + "KB"
+	}
+	return strconv.Itoa(n) + "B"
+}
@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultRedactMask 脱敏之后的替换内容
+const defaultRedactMask = "***"
+
+// defaultRedactKeys 内置的敏感字段名(小写)
+var defaultRedactKeys = []string{
+	"password", "passwd", "pwd", "secret", "token", "access_token", "refresh_token",
+	"api_key", "apikey", "authorization", "cookie", "card_number", "cvv",
+}
+
+// 内置的敏感内容规则
+var (
+	// key=value或者key: value形式的密码以及密钥，保留key
+	redactKeyValue = regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|token|access_token|api[_-]?key)(\s*[=:]\s*)("[^"]*"|[^\s&|,;"]+)`)
+	// Authorization头中的凭证，保留认证方式
+	redactBearer = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`)
+	// 13到19位的卡号，允许空格以及横线分隔，通过Luhn校验才会替换
+	redactCardNumber = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// RedactConfig configures a Redactor
+type RedactConfig struct {
+	Keys       []string // 敏感字段名，大小写不敏感，分组字段(a.b)按照最后一段匹配，值整体替换
+	Patterns   []string // 正则表达式，参数以及字符串字段中匹配的内容整体替换
+	Mask       string   // 替换内容，默认"***"
+	NoDefaults bool     // 不使用内置的字段名以及规则(密码、token、Authorization凭证、卡号)
+}
+
+// Redactor masks sensitive values in records before they are encoded
+/*
+ * 日志脱敏：在编码之前处理记录，文本以及JSON编码、所有sink收到的都是脱敏之后的内容
+ * 字段名匹配时替换整个值；参数以及字符串类型的字段值按照规则替换匹配的内容
+ * 内置规则：key=value形式的密码以及密钥、Bearer/Basic凭证、通过Luhn校验的卡号(保留后4位)
+ * 调用位置以及后缀信息不做处理
+ */
+type Redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+	mask     string
+	defaults bool
+}
+
+// NewRedactor compiles the patterns of config
+/*
+ * 创建脱敏组件，通过WithRedactor添加到Logger，例如：
+ *   r, err := NewRedactor(RedactConfig{Keys: []string{"id_card"}, Patterns: []string{`1[3-9]\d{9}`}})
+ *   logger, err := NewLogger(filename, suffix, backupDir, WithRedactor(r))
+ * @param config：脱敏配置
+ * @return 正则表达式不合法时返回error
+ */
+func NewRedactor(config RedactConfig) (*Redactor, error) {
+	r := &Redactor{keys: make(map[string]bool), mask: config.Mask, defaults: !config.NoDefaults}
+	if r.mask == "" {
+		r.mask = defaultRedactMask
+	}
+	keys := config.Keys
+	if r.defaults {
+		keys = append(append([]string(nil), defaultRedactKeys...), keys...)
+	}
+	for _, key := range keys {
+		r.keys[strings.ToLower(key)] = true
+	}
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// WithRedactor masks sensitive values of every record, see NewRedactor
+/*
+ * 开启日志脱敏，作为第一个hook注册，之后注册的hook看到的是脱敏之后的记录
+ * @param r：脱敏组件，为nil时不开启
+ */
+func WithRedactor(r *Redactor) Option {
+	return func(logger *Logger) {
+		if r != nil {
+			logger.AddHook(r.Hook())
+		}
+	}
+}
+
+// Hook returns the redactor as a Hook, for registering it at a chosen position
+func (r *Redactor) Hook() Hook {
+	return func(entry Entry) (Entry, bool) {
+		r.redactEntry(&entry)
+		return entry, true
+	}
+}
+
+// RedactString masks the sensitive content of s
+func (r *Redactor) RedactString(s string) string {
+	if r.defaults {
+		s = redactKeyValue.ReplaceAllString(s, "${1}${2}"+r.mask)
+		s = redactBearer.ReplaceAllString(s, "${1} "+r.mask)
+		s = redactCardNumber.ReplaceAllStringFunc(s, r.maskCardNumber)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, r.mask)
+	}
+	return s
+}
+
+/*
+ * 处理记录的参数以及字段，有修改时复制，不修改调用方的参数以及共享的字段
+ */
+func (r *Redactor) redactEntry(entry *Entry) {
+	var args []interface{}
+	for i, arg := range entry.Args {
+		if redacted, ok := r.redactValue(arg); ok {
+			if args == nil {
+				args = append([]interface{}(nil), entry.Args...)
+			}
+			args[i] = redacted
+		}
+	}
+	if args != nil {
+		entry.Args = args
+	}
+
+	var fields Fields
+	for key, value := range entry.Fields {
+		redacted, ok := r.mask, r.sensitiveKey(key)
+		if !ok {
+			redacted, ok = r.redactValue(value)
+		}
+		if ok {
+			if fields == nil {
+				fields = make(Fields, len(entry.Fields))
+				for k, v := range entry.Fields {
+					fields[k] = v
+				}
+			}
+			fields[key] = redacted
+		}
+	}
+	if fields != nil {
+		entry.Fields = fields
+	}
+}
+
+/*
+ * 处理单个值，数字、bool以及时间不会包含敏感内容，直接跳过
+ * @return (脱敏之后的字符串, 是否有修改)
+ */
+func (r *Redactor) redactValue(value interface{}) (string, bool) {
+	var s string
+	switch v := value.(type) {
+	case nil, bool, int, int8, int16, int32, uint, uint8, uint16, uint32, float32, float64, time.Time, time.Duration:
+		return "", false
+	case string:
+		s = v
+	default:
+		// int64/uint64可能是卡号，与其他类型一样渲染之后处理
+		s = string(appendArg(nil, v))
+	}
+	if redacted := r.RedactString(s); redacted != s {
+		return redacted, true
+	}
+	return "", false
+}
+
+/*
+ * 判断字段名是否敏感，分组字段按照最后一段判断
+ */
+func (r *Redactor) sensitiveKey(key string) bool {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	return r.keys[strings.ToLower(key)]
+}
+
+/*
+ * 通过Luhn校验的卡号替换为掩码并保留后4位，否则保持不变
+ */
+func (r *Redactor) maskCardNumber(s string) string {
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i])
+		}
+	}
+	if !luhnValid(digits) {
+		return s
+	}
+	return r.mask + string(digits[len(digits)-4:])
+}
+
+/*
+ * Luhn校验
+ */
+func luhnValid(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
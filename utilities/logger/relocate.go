@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// relocateRequest asks the flush goroutine to switch to a new file
+type relocateRequest struct {
+	file      *os.File // 已经打开的新文件
+	filename  string
+	naming    string // 切分文件名模板，参考WithFileNameTemplate
+	backupDir string
+	done      chan struct{}
+}
+
+// Relocate moves logging to a new directory without restarting
+/*
+ * 将日志切换到新的目录，适用于日志所在磁盘快满时在线迁移
+ * 位于原日志目录(NewLogger的filename所在目录)下的日志文件、备份目录以及租户目录按照相对路径迁移到newDir，
+ * 原目录之外的自定义文件以及备份目录保持不变
+ * 所有新文件打开成功之后才会切换，任何一个失败时不做任何修改；切换前已经进入写入队列的日志写入原文件，其余写入新文件
+ * 原目录中已有的日志以及切分文件不会移动
+ * @param newDir：新的日志目录
+ * @return 创建目录或者打开文件失败时返回error
+ */
+func (logger *Logger) Relocate(newDir string) error {
+	logger.Lock()
+	defer logger.Unlock()
+
+	oldDir := filepath.Dir(logger.filename)
+	if err := mkdirAll(newDir, logger.logDirMode(), logger.owner); err != nil {
+		return err
+	}
+
+	type move struct {
+		key string
+		req relocateRequest
+	}
+	var moves []move
+	closeAll := func() {
+		for _, m := range moves {
+			m.req.file.Close()
+		}
+	}
+	seen := make(map[*LoggerInfo]bool, len(logger.logMap))
+	for key, loggerInfo := range logger.logMap {
+		filename, ok := rebasePath(oldDir, newDir, loggerInfo.filename)
+		if !ok || loggerInfo.noFile || seen[loggerInfo] {
+			continue
+		}
+		seen[loggerInfo] = true
+		if err := mkdirAll(filepath.Dir(filename), loggerInfo.dirMode, loggerInfo.owner); err != nil {
+			closeAll()
+			return err
+		}
+		file, err := openLogFile(filename, loggerInfo.fileMode, loggerInfo.owner)
+		if file == nil {
+			closeAll()
+			return err
+		}
+		if err != nil {
+			logger.reporter.report("Relocate.Chown", err)
+		}
+		backupDir := loggerInfo.backupDir
+		if dir, ok := rebasePath(oldDir, newDir, backupDir); ok {
+			backupDir = dir
+		}
+		naming := loggerInfo.naming
+		if rebased, ok := rebasePath(oldDir, newDir, naming); ok {
+			naming = rebased
+		}
+		moves = append(moves, move{key: key, req: relocateRequest{file: file, filename: filename, naming: naming, backupDir: backupDir}})
+	}
+
+	var tenantDir string
+	if logger.tenancy != nil {
+		tenantDir = logger.tenancy.dir()
+	}
+	for _, m := range moves {
+		loggerInfo := logger.logMap[m.key]
+		oldFilename := loggerInfo.filename
+		loggerInfo.relocate(m.req)
+		// 租户文件以文件路径为key，需要使用新路径，自定义文件保持调用方传入的文件名
+		if tenantDir != "" && m.key == oldFilename && isSubPath(tenantDir, oldFilename) {
+			delete(logger.logMap, m.key)
+			logger.logMap[m.req.filename] = loggerInfo
+		}
+	}
+
+	if dir, ok := rebasePath(oldDir, newDir, tenantDir); ok {
+		logger.tenancy.setDir(dir)
+	}
+	if dir, ok := rebasePath(oldDir, newDir, logger.backupDir); ok {
+		logger.backupDir = dir
+		if logger.retention != nil {
+			logger.retention.SetDir(dir)
+		}
+	}
+	logger.filename = filepath.Join(newDir, filepath.Base(logger.filename))
+	return nil
+}
+
+/*
+ * 通知flush协程切换文件，阻塞直到切换完成，已经关闭时直接关闭新文件
+ */
+func (logger *LoggerInfo) relocate(req relocateRequest) {
+	req.done = make(chan struct{})
+	select {
+	case logger.relocateQueue <- req:
+		<-req.done
+	case <-logger.flusherDone:
+		req.file.Close()
+	}
+}
+
+/*
+ * 将队列中的数据写入原文件之后切换到新文件，只能在FlushBufferQueue协程中调用
+ */
+func (logger *LoggerInfo) switchFile(req relocateRequest) {
+	logger.drainQueue()
+	logFile := logger.logFile
+	logger.doIO(logFile.Sync)
+	logFile.Close()
+	logger.logFile = req.file
+	logger.filename = req.filename
+	logger.naming = req.naming
+	logger.backupDir = req.backupDir
+	close(req.done)
+}
+
+/*
+ * 将位于oldDir下的路径按照相对路径迁移到newDir
+ * @return (迁移后的路径, true)；path不在oldDir下时返回("", false)
+ */
+func rebasePath(oldDir, newDir, path string) (string, bool) {
+	if !isSubPath(oldDir, path) {
+		return "", false
+	}
+	rel, _ := filepath.Rel(oldDir, path)
+	return filepath.Join(newDir, rel), true
+}
+
+/*
+ * 判断path是否位于dir目录下(包括dir本身)
+ */
+func isSubPath(dir, path string) bool {
+	if path == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
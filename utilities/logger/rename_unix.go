@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"os"
+)
+
+/*
+ * 重命名文件，unix下打开的文件可以直接重命名
+ */
+func renameFile(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+/*
+ * 以追加方式打开或者创建日志文件
+ */
+func openAppend(filename string, mode os.FileMode) (*os.File, error) {
+	return os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, mode)
+}
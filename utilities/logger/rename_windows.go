@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// renameRetries 文件被其他进程占用时重命名的重试次数，重试间隔从10ms开始翻倍，总共约1.3秒
+const renameRetries = 7
+
+/*
+ * 重命名文件，windows下文件被其他进程(例如杀毒软件、tail、备份工具)打开时重命名会失败，
+ * 遇到共享冲突以及拒绝访问时等待之后重试
+ */
+func renameFile(oldpath, newpath string) error {
+	wait := 10 * time.Millisecond
+	for i := 0; ; i++ {
+		err := os.Rename(oldpath, newpath)
+		if err == nil || i == renameRetries || !isSharingViolation(err) {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+/*
+ * 判断是否为文件被占用导致的错误
+ */
+func isSharingViolation(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == errorSharingViolation || errno == errorLockViolation || errno == syscall.ERROR_ACCESS_DENIED
+}
+
+/*
+ * 以追加方式打开或者创建日志文件，共享方式包含FILE_SHARE_DELETE，
+ * 其他进程读取日志文件时不会阻止切分时的重命名，本进程打开的文件也可以被其他工具重命名(例如logrotate类工具)
+ * mode只有只读属性有效，与os.OpenFile相同
+ */
+func openAppend(filename string, mode os.FileMode) (*os.File, error) {
+	path, err := syscall.UTF16PtrFromString(filename)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+	attrs := uint32(syscall.FILE_ATTRIBUTE_NORMAL)
+	if mode&0200 == 0 {
+		attrs = syscall.FILE_ATTRIBUTE_READONLY
+	}
+	handle, err := syscall.CreateFile(path,
+		syscall.FILE_APPEND_DATA,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_ALWAYS, attrs, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+	return os.NewFile(uintptr(handle), filename), nil
+}
@@ -0,0 +1,158 @@
+//go:build windows
+// +build windows
+
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+/*
+ * 以不包含FILE_SHARE_DELETE的共享方式打开文件，模拟杀毒软件、备份工具等占用文件的进程
+ */
+func holdFile(t *testing.T, filename string) syscall.Handle {
+	t.Helper()
+	path, err := syscall.UTF16PtrFromString(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := syscall.CreateFile(path, syscall.GENERIC_READ, syscall.FILE_SHARE_READ, nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		t.Fatalf("hold %s: %v", filename, err)
+	}
+	return handle
+}
+
+func writeTestFile(t *testing.T, filename, content string) {
+	t.Helper()
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, filename string) string {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestIsSharingViolation(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{&os.LinkError{Op: "rename", Err: errorSharingViolation}, true},
+		{&os.LinkError{Op: "rename", Err: errorLockViolation}, true},
+		{&os.LinkError{Op: "rename", Err: syscall.ERROR_ACCESS_DENIED}, true},
+		{&os.LinkError{Op: "rename", Err: syscall.ERROR_FILE_NOT_FOUND}, false},
+		{errors.New("sharing violation"), false},
+	} {
+		if got := isSharingViolation(c.err); got != c.want {
+			t.Errorf("isSharingViolation(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+// 本进程以openAppend打开的日志文件可以直接重命名，重命名之后的写入进入新文件名
+func TestRenameOpenLogFile(t *testing.T) {
+	dir := t.TempDir()
+	live, rotated := filepath.Join(dir, "app-error.log"), filepath.Join(dir, "app-error.log.2024050607")
+	f, err := openAppend(live, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("before\n")
+
+	if err := renameFile(live, rotated); err != nil {
+		t.Fatalf("rename open log file: %v", err)
+	}
+	f.WriteString("after\n")
+	if got := readTestFile(t, rotated); got != "before\nafter\n" {
+		t.Errorf("rotated file: %q", got)
+	}
+	if _, err := os.Stat(live); !os.IsNotExist(err) {
+		t.Errorf("live file still exists: %v", err)
+	}
+
+	// 重新创建日志文件不受旧文件句柄影响
+	g, err := openAppend(live, 0644)
+	if err != nil {
+		t.Fatalf("recreate: %v", err)
+	}
+	g.WriteString("new\n")
+	g.Close()
+	if got := readTestFile(t, live); got != "new\n" {
+		t.Errorf("recreated file: %q", got)
+	}
+}
+
+// 其他进程占用源文件时重试，占用在重试期间释放之后重命名成功
+func TestRenameRetriesWhileSourceHeld(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "app-error.log"), filepath.Join(dir, "app-error.log.2024050607")
+	writeTestFile(t, src, "record\n")
+	handle := holdFile(t, src)
+	time.AfterFunc(100*time.Millisecond, func() { syscall.CloseHandle(handle) })
+
+	if err := renameFile(src, dst); err != nil {
+		t.Fatalf("rename after release: %v", err)
+	}
+	if got := readTestFile(t, dst); got != "record\n" {
+		t.Errorf("renamed file: %q", got)
+	}
+}
+
+// 目标文件被占用时覆盖失败并重试，占用释放之后覆盖成功
+func TestRenameOverHeldTarget(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "app-error.log"), filepath.Join(dir, "app-error.log.2024050607")
+	writeTestFile(t, src, "new\n")
+	writeTestFile(t, dst, "old\n")
+	handle := holdFile(t, dst)
+	time.AfterFunc(100*time.Millisecond, func() { syscall.CloseHandle(handle) })
+
+	if err := renameFile(src, dst); err != nil {
+		t.Fatalf("rename over held target: %v", err)
+	}
+	if got := readTestFile(t, dst); got != "new\n" {
+		t.Errorf("target: %q", got)
+	}
+}
+
+// 一直被占用时重试结束之后返回共享冲突错误，源文件保留
+func TestRenameGivesUpWhileHeld(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "app-error.log"), filepath.Join(dir, "app-error.log.2024050607")
+	writeTestFile(t, src, "record\n")
+	handle := holdFile(t, src)
+	defer syscall.CloseHandle(handle)
+
+	start := time.Now()
+	err := renameFile(src, dst)
+	if err == nil || !isSharingViolation(err) {
+		t.Fatalf("want sharing violation, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("gave up after %v, want about 1.3s of retries", elapsed)
+	}
+	if got := readTestFile(t, src); got != "record\n" {
+		t.Errorf("source file: %q", got)
+	}
+}
+
+// 不是共享冲突的错误直接返回
+func TestRenameMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := renameFile(filepath.Join(dir, "missing.log"), filepath.Join(dir, "missing.log.1")); !os.IsNotExist(err) {
+		t.Fatalf("want not exist, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Reopen closes and reopens all log files at their current paths
+/*
+ * 重新打开所有日志文件，用于配合外部logrotate：logrotate重命名日志文件之后调用，
+ * 后续日志写入新创建的文件，而不是继续写入已经被重命名的旧文件
+ * 所有文件打开成功之后才会切换，任何一个失败时不做任何修改；切换前已经进入写入队列的日志写入旧文件
+ * @return 打开文件失败时返回error
+ */
+func (logger *Logger) Reopen() error {
+	logger.Lock()
+	defer logger.Unlock()
+
+	type reopen struct {
+		info *LoggerInfo
+		req  relocateRequest
+	}
+	var reopens []reopen
+	for _, loggerInfo := range logger.uniqueInfos() {
+		if loggerInfo.noFile {
+			continue
+		}
+		file, err := openLogFile(loggerInfo.filename, loggerInfo.fileMode, loggerInfo.owner)
+		if file == nil {
+			for _, r := range reopens {
+				r.req.file.Close()
+			}
+			return err
+		}
+		if err != nil {
+			logger.reporter.report("Reopen.Chown", err)
+		}
+		reopens = append(reopens, reopen{info: loggerInfo, req: relocateRequest{
+			file:      file,
+			filename:  loggerInfo.filename,
+			backupDir: loggerInfo.backupDir,
+		}})
+	}
+	for _, r := range reopens {
+		r.info.relocate(r.req)
+	}
+	return nil
+}
+
+// ReopenOnSignal reopens all log files whenever one of sigs is received
+/*
+ * 监听信号，收到信号时调用Reopen重新打开日志文件，失败时通过错误处理函数上报
+ * 一般在logrotate的postrotate中执行 kill -HUP <pid>
+ * @param sigs：监听的信号，为空时监听SIGHUP(windows下不会收到该信号)
+ * @return 停止监听的函数，Close之前调用
+ */
+func (logger *Logger) ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := logger.Reopen(); err != nil {
+					logger.reporter.report("Reopen", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
@@ -0,0 +1,171 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrorHandler receives internal failures of the logger
+/*
+ * 日志内部错误回调，op为出错的操作，例如"FlushBufferQueue.Write"、"NeedSplit.CreateFile"
+ * 回调在日志写入协程中同步执行，不能阻塞，也不能再通过同一个Logger写日志，避免死循环
+ */
+type ErrorHandler func(op string, err error)
+
+// Stats reports the health counters of the logger
+/*
+ * 日志健康状态，计数器从创建Logger开始累计，队列深度为调用时的瞬时值
+ * 可以通过PublishExpvar或者MetricsHandler暴露给监控系统
+ */
+type Stats struct {
+	Dropped      uint64 // 丢弃的记录数：关闭后写入、租户超过配额、WriteCtx超时
+	FailedWrites uint64 // 写入文件失败的buffer数，buffer中的记录已经丢失
+	Errors       uint64 // 内部错误总数，包括写入失败
+	// 写入队列满时按照WithOverflowPolicy丢弃的buffer数，一个buffer包含一次flush间隔内的多条记录
+	DroppedBuffers uint64
+	Records        map[string]uint64 // 每个级别写入的记录数，不包括通过Write写入的自定义文件
+	BytesFlushed   uint64            // 写入日志文件的字节数
+	Rotations      uint64            // 日志文件切分次数
+	QueueDepth     int               // 所有日志文件写入队列中等待写入的buffer数
+	QueueCapacity  int               // 所有日志文件写入队列的总长度
+	SlowFlushes    uint64            // 从进入写入队列到写入文件超过WithSlowFlushThreshold阈值的buffer数
+	Truncated      uint64            // 超过WithMaxRecordSize上限的记录数，包括截断、拆分以及丢弃的记录
+	FlushLatency   LatencyHistogram  // buffer从进入写入队列到写入文件的延迟分布
+}
+
+// reporter 日志对象共享的错误回调以及计数
+type reporter struct {
+	handler      atomic.Value // ErrorHandler
+	dropped      uint64
+	failedWrites uint64
+	errors       uint64
+	droppedBufs  uint64
+	flushed      uint64
+	rotations    uint64
+	slowFlushes  uint64
+	truncated    uint64
+	records      sync.Map // 级别 -> *uint64
+	latency      latencyHistogram
+}
+
+/*
+ * 默认的错误回调，输出到标准错误
+ */
+func printError(op string, err error) {
+	println("[" + op + "] " + err.Error())
+}
+
+/*
+ * 上报内部错误
+ * @param op：出错的操作
+ * @param err：错误
+ */
+func (r *reporter) report(op string, err error) {
+	atomic.AddUint64(&r.errors, 1)
+	if handler, ok := r.handler.Load().(ErrorHandler); ok && handler != nil {
+		handler(op, err)
+		return
+	}
+	printError(op, err)
+}
+
+/*
+ * 上报写入文件失败
+ */
+func (r *reporter) writeFailed(op string, err error) {
+	atomic.AddUint64(&r.failedWrites, 1)
+	r.report(op, err)
+}
+
+/*
+ * 记录丢弃的记录数
+ */
+func (r *reporter) drop() {
+	atomic.AddUint64(&r.dropped, 1)
+}
+
+/*
+ * 记录超过大小上限的记录数
+ */
+func (r *reporter) truncate() {
+	atomic.AddUint64(&r.truncated, 1)
+}
+
+/*
+ * 记录写入队列满时丢弃的buffer数
+ */
+func (r *reporter) dropBuffer() {
+	atomic.AddUint64(&r.droppedBufs, 1)
+}
+
+/*
+ * 记录级别写入的记录数
+ */
+func (r *reporter) record(level string) {
+	counter, ok := r.records.Load(level)
+	if !ok {
+		counter, _ = r.records.LoadOrStore(level, new(uint64))
+	}
+	atomic.AddUint64(counter.(*uint64), 1)
+}
+
+/*
+ * 记录写入日志文件的字节数
+ */
+func (r *reporter) flush(n int) {
+	atomic.AddUint64(&r.flushed, uint64(n))
+}
+
+/*
+ * 记录日志文件切分次数
+ */
+func (r *reporter) rotate() {
+	atomic.AddUint64(&r.rotations, 1)
+}
+
+// SetErrorHandler routes internal failures to handler instead of stderr
+/*
+ * 设置内部错误回调，例如上报到监控系统，为nil时恢复为输出到标准错误
+ * @param handler：错误回调
+ */
+func (logger *Logger) SetErrorHandler(handler ErrorHandler) {
+	logger.reporter.handler.Store(handler)
+}
+
+// Stats returns the health counters of the logger
+func (logger *Logger) Stats() Stats {
+	stats := Stats{
+		Dropped:        atomic.LoadUint64(&logger.reporter.dropped),
+		FailedWrites:   atomic.LoadUint64(&logger.reporter.failedWrites),
+		Errors:         atomic.LoadUint64(&logger.reporter.errors),
+		DroppedBuffers: atomic.LoadUint64(&logger.reporter.droppedBufs),
+		Records:        make(map[string]uint64),
+		BytesFlushed:   atomic.LoadUint64(&logger.reporter.flushed),
+		Rotations:      atomic.LoadUint64(&logger.reporter.rotations),
+		SlowFlushes:    atomic.LoadUint64(&logger.reporter.slowFlushes),
+		Truncated:      atomic.LoadUint64(&logger.reporter.truncated),
+		FlushLatency:   logger.reporter.latency.snapshot(),
+	}
+	logger.reporter.records.Range(func(level, counter interface{}) bool {
+		stats.Records[level.(string)] = atomic.LoadUint64(counter.(*uint64))
+		return true
+	})
+	for _, loggerInfo := range logger.infos() {
+		stats.QueueDepth += len(loggerInfo.bufferQueue)
+		stats.QueueCapacity += cap(loggerInfo.bufferQueue)
+	}
+	return stats
+}
+
+/*
+ * 按照名称排序的级别，输出指标时保持顺序稳定
+ */
+func (stats Stats) levels() []string {
+	levels := make([]string, 0, len(stats.Records))
+	for level := range stats.Records {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+	return levels
+}
@@ -0,0 +1,248 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultRetentionInterval 备份目录的默认检查间隔
+const defaultRetentionInterval = time.Hour
+
+// RetentionPolicy controls how long backups under backupDir/DATE are kept
+type RetentionPolicy struct {
+	MaxAge     time.Duration // 备份保留时间，按照日期目录计算，0表示不限制
+	MaxSize    int64         // 所有备份的总大小上限(字节)，超过时从最旧的日期开始清理，0表示不限制
+	Interval   time.Duration // 检查间隔，默认1小时
+	ArchiveDir string        // 不为空时将过期的日期目录移动到该目录而不是删除，例如挂载的冷存储
+	// OnRemove 每清理一个日期目录调用一次，archived表示是否移动到了ArchiveDir
+	OnRemove func(dir string, size int64, archived bool)
+}
+
+// RetentionManager periodically removes old backup directories
+/*
+ * 备份清理，backupDir下的日期目录(2006-01-02)满足以下任一条件时被删除或者归档：
+ * 1. 日期早于 当前时间-MaxAge
+ * 2. 所有日期目录总大小超过MaxSize，从最旧的开始清理，当天的目录不清理
+ * 名称不是日期格式的目录以及文件不会被处理
+ */
+type RetentionManager struct {
+	mu       sync.Mutex
+	dir      string
+	policy   RetentionPolicy
+	stop     chan struct{}
+	stopOnce sync.Once
+	report   func(op string, err error) // 清理失败时的错误回调
+	throttle Throttle                   // 定期清理的节流，nil表示不开启
+}
+
+// NewRetentionManager creates a retention manager for backupDir
+/*
+ * 创建备份清理对象，需要调用Start启动定期清理
+ * @param backupDir：备份目录，与NewLogger的backupDir相同
+ * @param policy：清理策略
+ * @return 备份清理对象
+ */
+func NewRetentionManager(backupDir string, policy RetentionPolicy) *RetentionManager {
+	if policy.Interval <= 0 {
+		policy.Interval = defaultRetentionInterval
+	}
+	return &RetentionManager{dir: backupDir, policy: policy, stop: make(chan struct{}), report: printError}
+}
+
+// WithRetention starts a retention manager for the logger's backup directory
+/*
+ * 开启备份清理，NewLogger的backupDir不为空时生效，Close时停止
+ * @param policy：清理策略
+ */
+func WithRetention(policy RetentionPolicy) Option {
+	return func(logger *Logger) {
+		if logger.backupDir != "" {
+			logger.retention = NewRetentionManager(logger.backupDir, policy)
+			logger.retention.report = logger.reporter.report
+		}
+	}
+}
+
+// Start runs Cleanup immediately and then every Interval until Stop
+func (manager *RetentionManager) Start() {
+	go func() {
+		ticker := time.NewTicker(manager.policy.Interval)
+		defer ticker.Stop()
+		manager.Cleanup()
+		for {
+			select {
+			case <-ticker.C:
+				manager.Cleanup()
+			case <-manager.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic cleanup, it is safe to call more than once
+func (manager *RetentionManager) Stop() {
+	manager.stopOnce.Do(func() {
+		close(manager.stop)
+	})
+}
+
+// SetDir changes the backup directory being managed
+func (manager *RetentionManager) SetDir(backupDir string) {
+	manager.mu.Lock()
+	manager.dir = backupDir
+	manager.mu.Unlock()
+}
+
+// SetThrottle paces the periodic cleanup through throttle, nil disables throttling
+/*
+ * 设置定期清理的节流，每个日期目录清理之前等待；紧急清理(磁盘空间不足)不受影响
+ */
+func (manager *RetentionManager) SetThrottle(throttle Throttle) {
+	manager.mu.Lock()
+	manager.throttle = throttle
+	manager.mu.Unlock()
+}
+
+// backupDay 一个日期备份目录
+type backupDay struct {
+	path string
+	day  time.Time
+	size int64
+}
+
+// Cleanup removes or archives expired backup directories once
+/*
+ * 执行一次清理
+ * @return 读取备份目录失败时返回error，单个目录清理失败只输出错误
+ */
+func (manager *RetentionManager) Cleanup() error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	days, total, err := listBackupDays(manager.dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	today, _ := time.ParseInLocation(DATEFORMAT, now.Format(DATEFORMAT), time.Local)
+	for _, day := range days {
+		expired := manager.policy.MaxAge > 0 && now.Sub(day.day.AddDate(0, 0, 1)) > manager.policy.MaxAge
+		overSize := manager.policy.MaxSize > 0 && total > manager.policy.MaxSize && day.day.Before(today)
+		if !expired && !overSize {
+			continue
+		}
+		if manager.throttle != nil {
+			// 等待时释放锁，紧急清理不会被节流阻塞；期间已经被清理的目录跳过
+			manager.mu.Unlock()
+			waitThrottle(manager.throttle, manager.stop, day.size)
+			manager.mu.Lock()
+			if _, err := os.Stat(day.path); err != nil {
+				total -= day.size
+				continue
+			}
+		}
+		if manager.remove(day) {
+			total -= day.size
+		}
+	}
+	return nil
+}
+
+/*
+ * 紧急清理：不考虑MaxAge以及MaxSize，从最旧的日期目录开始清理，直到enough返回true，当天的目录不清理
+ * 配置了ArchiveDir时同样是归档，归档目录与日志在同一个分区时不会释放空间
+ * @param enough：清理每个目录之前调用，返回true时停止
+ * @return (清理的字节数, 读取备份目录失败时的error)
+ */
+func (manager *RetentionManager) purge(enough func() bool) (int64, error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	days, _, err := listBackupDays(manager.dir)
+	if err != nil {
+		return 0, err
+	}
+	today, _ := time.ParseInLocation(DATEFORMAT, time.Now().Format(DATEFORMAT), time.Local)
+	var freed int64
+	for _, day := range days {
+		if enough() || !day.day.Before(today) {
+			break
+		}
+		if manager.remove(day) {
+			freed += day.size
+		}
+	}
+	return freed, nil
+}
+
+/*
+ * 读取备份目录下的日期目录，按照日期从旧到新排序
+ * @return (日期目录, 总大小, error)
+ */
+func listBackupDays(dir string) ([]backupDay, int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var days []backupDay
+	var total int64
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		day, err := time.ParseInLocation(DATEFORMAT, entry.Name(), time.Local)
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		size := dirSize(path)
+		days = append(days, backupDay{path: path, day: day, size: size})
+		total += size
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].day.Before(days[j].day)
+	})
+	return days, total, nil
+}
+
+/*
+ * 删除或者归档一个日期目录
+ * @return 成功返回true
+ */
+func (manager *RetentionManager) remove(day backupDay) bool {
+	archived := manager.policy.ArchiveDir != ""
+	var err error
+	if archived {
+		if err = os.MkdirAll(manager.policy.ArchiveDir, 0777); err == nil {
+			err = renameFile(day.path, filepath.Join(manager.policy.ArchiveDir, filepath.Base(day.path)))
+		}
+	} else {
+		err = os.RemoveAll(day.path)
+	}
+	if err != nil {
+		manager.report("RetentionManager.Remove", err)
+		return false
+	}
+	if manager.policy.OnRemove != nil {
+		manager.policy.OnRemove(day.path, day.size, archived)
+	}
+	return true
+}
+
+/*
+ * 计算目录下所有文件的大小
+ */
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
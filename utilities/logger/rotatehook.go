@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// RotateHook is called after a log file was rotated or moved to the backup directory
+/*
+ * 日志文件切分或者备份完成之后的回调，可以用于上传到对象存储、通知采集程序等
+ * 切分时oldPath为当前日志文件名，newPath为切分出来的文件(CompressOnRotate时为压缩完成之后的.gz文件)
+ * 备份时oldPath为切分文件原来的路径，newPath为备份目录中的路径(CompressOnBackup时为压缩完成之后的.gz文件)
+ * 回调在归档协程中按照顺序执行，执行时文件已经不会再被日志对象修改；
+ * 耗时的处理需要另起协程，否则归档队列满之后会阻塞日志切分
+ */
+type RotateHook func(oldPath, newPath string)
+
+// rotateHooks 日志对象共享的切分回调
+type rotateHooks struct {
+	hooks atomic.Value // []RotateHook，写时复制
+}
+
+// WithRotateHook registers a rotation callback at creation time, see OnRotate
+func WithRotateHook(fn RotateHook) Option {
+	return func(logger *Logger) {
+		logger.OnRotate(fn)
+	}
+}
+
+// OnRotate registers fn to be called after every rotation and backup of a log file
+/*
+ * 注册切分回调，按照注册顺序执行，回调panic时通过错误回调上报，不影响其他回调以及之后的切分
+ * @param fn：切分回调
+ */
+func (logger *Logger) OnRotate(fn RotateHook) {
+	logger.Lock()
+	defer logger.Unlock()
+	hooks, _ := logger.rotateHooks.hooks.Load().([]RotateHook)
+	updated := make([]RotateHook, len(hooks), len(hooks)+1)
+	copy(updated, hooks)
+	logger.rotateHooks.hooks.Store(append(updated, fn))
+}
+
+/*
+ * 是否注册了切分回调
+ */
+func (r *rotateHooks) active() bool {
+	hooks, _ := r.hooks.Load().([]RotateHook)
+	return len(hooks) > 0
+}
+
+/*
+ * 依次执行切分回调，只在归档协程中调用
+ * @param reporter：回调panic时上报
+ * @param oldPath：原文件名
+ * @param newPath：新文件名
+ */
+func (r *rotateHooks) notify(reporter *reporter, oldPath, newPath string) {
+	hooks, _ := r.hooks.Load().([]RotateHook)
+	for _, fn := range hooks {
+		func() {
+			defer func() {
+				if v := recover(); v != nil {
+					reporter.report("RotateHook", fmt.Errorf("panic: %v", v))
+				}
+			}()
+			fn(oldPath, newPath)
+		}()
+	}
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"time"
+)
+
+// Schedule is the time based rotation schedule
+type Schedule int
+
+const (
+	// RotateHourly rotates and backs up log files every hour (default)
+	RotateHourly Schedule = iota
+	// RotateDaily rotates and backs up log files every day
+	RotateDaily
+	// RotateNever disables time based rotation, only size based rotation applies
+	RotateNever
+)
+
+// RotationPolicy controls when log files are rotated
+/*
+ * 日志切分策略
+ * 按时间切分：到达新的周期时将当前文件重命名为 文件名.周期开始时间(2006010215) 并备份到backupDir
+ * 按大小切分：文件超过MaxSize时重命名为 文件名.周期开始时间.序号，序号对MaxFiles取模，超过后覆盖最旧的文件
+ * 不按时间切分时，周期开始时间为文件创建时所在的小时
+ */
+type RotationPolicy struct {
+	Schedule Schedule // 按时间切分的周期，默认按小时
+	MaxSize  int64    // 按大小切分的阈值，0表示默认值2GB，<0表示不按大小切分
+	MaxFiles int      // 每个周期内按大小切分保留的文件数，<=0表示默认值10
+}
+
+// rotationClock 按时间切分使用的当前时间，测试中替换以模拟跨过整点
+var rotationClock = time.Now
+
+// WithRotation sets the rotation policy of every log file of the logger
+func WithRotation(policy RotationPolicy) Option {
+	return func(logger *Logger) {
+		logger.rotation = policy
+	}
+}
+
+/*
+ * 获取t所在周期的开始时间，不按时间切分时返回零值
+ */
+func (policy RotationPolicy) period(t time.Time) time.Time {
+	switch policy.Schedule {
+	case RotateDaily:
+		day, _ := time.Parse(DATEFORMAT, t.Format(DATEFORMAT))
+		return day
+	case RotateNever:
+		return time.Time{}
+	default:
+		hour, _ := time.Parse(HOURFORMAT, t.Format(HOURFORMAT))
+		return hour
+	}
+}
+
+/*
+ * 按大小切分的阈值，返回0表示不按大小切分
+ */
+func (policy RotationPolicy) maxSize() int64 {
+	switch {
+	case policy.MaxSize < 0:
+		return 0
+	case policy.MaxSize == 0:
+		return maxFileSize
+	default:
+		return policy.MaxSize
+	}
+}
+
+/*
+ * 每个周期内按大小切分保留的文件数
+ */
+func (policy RotationPolicy) maxFiles() int {
+	if policy.MaxFiles <= 0 {
+		return maxFileCount
+	}
+	return policy.MaxFiles
+}
@@ -0,0 +1,175 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+ * 替换rotationClock，返回将时间推进一小时的函数，测试结束时恢复
+ */
+func steppedClock(t *testing.T) func() {
+	t.Helper()
+	var offset int64
+	saved := rotationClock
+	rotationClock = func() time.Time {
+		return time.Now().Add(time.Duration(atomic.LoadInt64(&offset)))
+	}
+	t.Cleanup(func() { rotationClock = saved })
+	return func() { atomic.AddInt64(&offset, int64(time.Hour)) }
+}
+
+/*
+ * 读取目录下(包括备份目录)所有日志文件以及切分文件的内容，.gz文件解压之后返回
+ */
+func readRotated(t *testing.T, dir string) (string, int) {
+	t.Helper()
+	var all strings.Builder
+	files := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(path, gzipTmpSuffix) {
+			t.Errorf("partial archive left: %s", path)
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var r io.Reader = f
+		if strings.HasSuffix(path, gzipSuffix) {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			r = zr
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		all.Write(data)
+		files++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk %s: %v", dir, err)
+	}
+	return all.String(), files
+}
+
+func TestRotateConcurrentWriters(t *testing.T) {
+	for _, mode := range []Compression{CompressNone, CompressOnRotate, CompressOnBackup} {
+		t.Run(fmt.Sprint("compression=", mode), func(t *testing.T) {
+			testRotateConcurrentWriters(t, mode)
+		})
+	}
+}
+
+/*
+ * 多个协程并发写入，同时按大小切分(4KB)、推进时钟触发按小时切分以及调用Rotate强制切分，
+ * 所有记录在日志文件、切分文件以及备份文件中恰好出现一次
+ */
+func testRotateConcurrentWriters(t *testing.T, mode Compression) {
+	const (
+		writers = 8
+		records = 2000
+	)
+	nextHour := steppedClock(t)
+	dir := t.TempDir()
+	log, err := NewLogger(filepath.Join(dir, "app"), "", filepath.Join(dir, "backup"),
+		WithRotation(RotationPolicy{MaxSize: 4 << 10, MaxFiles: 1 << 20}),
+		WithCompression(mode),
+		WithFlushInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	var errMu sync.Mutex
+	var errs []string
+	log.SetErrorHandler(func(op string, err error) {
+		errMu.Lock()
+		errs = append(errs, op+": "+err.Error())
+		errMu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; n < records; n++ {
+				log.Debug(fmt.Sprintf("rec-%d-%d", w, n))
+				if n%50 == 49 {
+					// 分散写入，保证切分发生在写入过程中
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}(w)
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	hours, forced := 0, 0
+	for running := true; running; {
+		select {
+		case <-finished:
+			running = false
+		default:
+			if hours == forced {
+				nextHour()
+				hours++
+				time.Sleep(time.Millisecond)
+			} else {
+				log.Rotate()
+				forced++
+			}
+		}
+	}
+	log.Close()
+
+	errMu.Lock()
+	if len(errs) > 0 {
+		t.Errorf("errors reported: %v", errs)
+	}
+	errMu.Unlock()
+
+	content, files := readRotated(t, dir)
+	seen := make(map[string]int, writers*records)
+	for _, match := range regexp.MustCompile(`rec-\d+-\d+`).FindAllString(content, -1) {
+		seen[match]++
+	}
+	for w := 0; w < writers; w++ {
+		for n := 0; n < records; n++ {
+			key := fmt.Sprintf("rec-%d-%d", w, n)
+			switch seen[key] {
+			case 1:
+			case 0:
+				t.Errorf("%s lost", key)
+			default:
+				t.Errorf("%s written %d times", key, seen[key])
+			}
+		}
+	}
+	if len(seen) != writers*records {
+		t.Errorf("want %d distinct records, got %d", writers*records, len(seen))
+	}
+	// 每次推进时钟至少产生一个按小时切分的文件
+	if forced == 0 || files <= hours {
+		t.Errorf("rotation did not happen while writing: %d files, %d hour steps, %d forced rotations", files, hours, forced)
+	}
+	t.Logf("%d files, %d hour steps, %d forced rotations", files, hours, forced)
+}
@@ -0,0 +1,237 @@
+package logger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3 签名相关常量
+const (
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3DateFormat    = "20060102"
+	s3TimeFormat    = "20060102T150405Z"
+	defaultS3Region = "us-east-1"
+)
+
+// ErrUploadChecksum is returned when the stored object does not match the local file
+var ErrUploadChecksum = errors.New("logger: uploaded object checksum mismatch")
+
+// S3Config configures an S3Uploader
+type S3Config struct {
+	Endpoint     string       // 服务地址，例如 http://minio:9000，为空时使用 https://s3.<Region>.amazonaws.com
+	Region       string       // 区域，默认us-east-1
+	Bucket       string       // 桶名称
+	AccessKey    string       // 访问密钥
+	SecretKey    string       // 私有访问密钥
+	SessionToken string       // 临时凭证的token，为空表示长期凭证
+	PathStyle    bool         // 使用 endpoint/bucket/key 形式的地址，MinIO等自建服务通常需要开启
+	StorageClass string       // 存储类型，例如STANDARD_IA，为空时使用桶的默认值
+	Client       *http.Client // 为nil时使用http.DefaultClient
+}
+
+// S3Uploader uploads backups to S3 or an S3-compatible object storage
+/*
+ * S3兼容对象存储的上传实现，使用AWS Signature V4签名的单次PUT，不依赖SDK
+ * 请求携带Content-MD5以及x-amz-content-sha256，服务端校验不一致时拒绝写入；
+ * 响应的ETag为MD5格式时再与本地MD5比较，确认远端保存的内容与本地文件一致
+ * 单次PUT最大5GB，日志文件的大小上限为2GB，不需要分片上传
+ */
+type S3Uploader struct {
+	config   S3Config
+	endpoint *url.URL
+}
+
+// NewS3Uploader creates an S3Uploader
+/*
+ * 创建S3上传，例如：
+ *   u, err := NewS3Uploader(S3Config{Region: "ap-southeast-1", Bucket: "logs", AccessKey: ak, SecretKey: sk})
+ *   logger, err := NewLogger(filename, suffix, backupDir, WithBackupUpload(UploadConfig{Uploader: u, Prefix: "app", DeleteLocal: true}))
+ * @param config：S3配置
+ * @return 缺少桶名称、密钥或者地址不合法时返回error
+ */
+func NewS3Uploader(config S3Config) (*S3Uploader, error) {
+	if config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("logger: s3 bucket, access key and secret key required")
+	}
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse s3 endpoint %s: %w", config.Endpoint, err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("logger: invalid s3 endpoint %s", config.Endpoint)
+	}
+	return &S3Uploader{config: config, endpoint: endpoint}, nil
+}
+
+// Upload implements Uploader
+/*
+ * 上传文件到 Bucket/key
+ * @param ctx：取消时中断上传
+ * @param key：对象key
+ * @param path：本地文件
+ * @return 请求失败、服务端返回错误或者ETag与本地MD5不一致时返回error
+ */
+func (u *S3Uploader) Upload(ctx context.Context, key, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), file)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek %s: %w", path, err)
+	}
+	md5Sum := md5Hash.Sum(nil)
+
+	var body io.Reader = http.NoBody
+	if size > 0 {
+		// 由http.Client负责关闭，之后defer中的Close返回的错误忽略
+		body = file
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.objectURL(key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum))
+	req.Header.Set("x-amz-content-sha256", hex.EncodeToString(sha256Hash.Sum(nil)))
+	if u.config.StorageClass != "" {
+		req.Header.Set("x-amz-storage-class", u.config.StorageClass)
+	}
+	u.sign(req, time.Now())
+
+	resp, err := u.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 put %s: %s: %s", key, resp.Status, strings.TrimSpace(string(message)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	// 使用SSE-KMS加密或者分片上传时ETag不是MD5，此时依赖服务端对Content-MD5的校验
+	if etag := strings.Trim(resp.Header.Get("ETag"), `"`); len(etag) == md5.Size*2 && !strings.EqualFold(etag, hex.EncodeToString(md5Sum)) {
+		return fmt.Errorf("s3 put %s: %w: etag %s", key, ErrUploadChecksum, etag)
+	}
+	return nil
+}
+
+/*
+ * 生成对象地址，key按照S3的规则逐段编码
+ */
+func (u *S3Uploader) objectURL(key string) string {
+	target := *u.endpoint
+	path := strings.TrimSuffix(target.Path, "/")
+	if u.config.PathStyle {
+		path += "/" + s3Escape(u.config.Bucket)
+	} else {
+		target.Host = u.config.Bucket + "." + target.Host
+	}
+	target.Path, target.RawPath = "", ""
+	target.RawQuery = ""
+	return target.String() + path + "/" + s3Escape(strings.TrimPrefix(key, "/"))
+}
+
+/*
+ * 按照AWS Signature V4签名请求，设置x-amz-date以及Authorization
+ * @param req：请求，需要已经设置x-amz-content-sha256
+ * @param now：签名时间
+ */
+func (u *S3Uploader) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("x-amz-date", now.Format(s3TimeFormat))
+	if u.config.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", u.config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-md5" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+	scope := now.Format(s3DateFormat) + "/" + u.config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := s3Algorithm + "\n" + now.Format(s3TimeFormat) + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + u.config.SecretKey)
+	for _, part := range []string{now.Format(s3DateFormat), u.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", s3Algorithm+" Credential="+u.config.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+/*
+ * 计算HMAC-SHA256
+ */
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+/*
+ * 按照S3的规则编码路径：保留字母、数字、-_.~以及/，其余字节编码为%XX
+ */
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,245 @@
+package logger
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/ratelimit"
+)
+
+// 抽样默认参数
+const (
+	defaultSampleInterval = time.Minute
+	maxSampleKeys         = 10000 // 每个级别一个汇总周期内记录的最多不同消息数，超过时清空重新计数
+)
+
+// SamplingConfig configures sampling and rate limiting of high-volume records
+/*
+ * 日志抽样以及限流配置，Every与Rate可以同时使用，先抽样再限流
+ * 被丢弃的记录数按照级别累计，每个汇总周期输出一条 "suppressed N similar messages" 记录
+ */
+type SamplingConfig struct {
+	Every    int           // 相同消息每N条只记录1条(第1、N+1、2N+1...条)，<=1表示不抽样
+	Rate     float64       // 每个级别每秒最多记录的条数(令牌桶)，<=0表示不限流
+	Burst    int           // 令牌桶容量，<=0时取Rate(至少为1)
+	Interval time.Duration // 汇总周期，相同消息的计数在每个周期重新开始，默认1分钟
+	Levels   []string      // 生效的级别，为空表示所有级别
+}
+
+// sampler 日志抽样以及限流状态
+type sampler struct {
+	sync.Mutex
+	config SamplingConfig
+	levels map[string]bool           // 生效的级别，nil表示所有级别
+	states map[string]*levelSampling // 每个级别的抽样状态
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// levelSampling 一个级别的抽样状态
+type levelSampling struct {
+	counts     map[uint64]uint64      // 本周期内每条消息出现的次数
+	bucket     *ratelimit.TokenBucket // 限流令牌桶，第一次限流时创建
+	suppressed uint64                 // 本周期内丢弃的记录数
+}
+
+// WithSampling samples and rate limits records to protect the disk from hot loops
+/*
+ * 开启日志抽样以及限流，避免循环中反复输出的日志在几分钟内写满磁盘
+ * 相同消息的判断依据：Debug/Trace/Warn/Error/Log为全部参数，*f函数为format，WriteCtx以及slog为消息内容
+ * Fatal/Panic以及通过Write写入的自定义文件不受影响
+ * @param config：抽样配置
+ */
+func WithSampling(config SamplingConfig) Option {
+	return func(logger *Logger) {
+		if config.Interval <= 0 {
+			config.Interval = defaultSampleInterval
+		}
+		if config.Burst <= 0 {
+			config.Burst = int(config.Rate)
+			if config.Burst < 1 {
+				config.Burst = 1
+			}
+		}
+		s := &sampler{
+			config: config,
+			states: make(map[string]*levelSampling),
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		if len(config.Levels) > 0 {
+			s.levels = make(map[string]bool, len(config.Levels))
+			for _, level := range config.Levels {
+				s.levels[level] = true
+			}
+		}
+		logger.sampler = s
+	}
+}
+
+/*
+ * 判断参数形式的记录是否需要写入
+ */
+func (logger *Logger) sampleArgs(level string, args []interface{}) bool {
+	if logger.sampler == nil {
+		return true
+	}
+	return logger.sampler.sample(level, argsKey(args))
+}
+
+/*
+ * 将参数渲染为用于判断相同消息的内容
+ */
+func argsKey(args []interface{}) []byte {
+	var buf []byte
+	for _, arg := range args {
+		buf = appendArg(buf, arg)
+		buf = append(buf, '|')
+	}
+	return buf
+}
+
+/*
+ * 判断消息形式的记录是否需要写入
+ */
+func (logger *Logger) sampleMessage(level, msg string) bool {
+	if logger.sampler == nil {
+		return true
+	}
+	return logger.sampler.sample(level, []byte(msg))
+}
+
+/*
+ * 按照抽样以及限流规则判断记录是否需要写入，不写入时计入丢弃数
+ * @param level：级别
+ * @param key：用于判断相同消息的内容
+ * @return 需要写入时返回true
+ */
+func (s *sampler) sample(level string, key []byte) bool {
+	if s.levels != nil && !s.levels[level] {
+		return true
+	}
+	s.Lock()
+	defer s.Unlock()
+	state := s.states[level]
+	if state == nil {
+		state = &levelSampling{counts: make(map[uint64]uint64)}
+		s.states[level] = state
+	}
+
+	if s.config.Every > 1 {
+		hash := fnv.New64a()
+		hash.Write(key)
+		sum := hash.Sum64()
+		if len(state.counts) >= maxSampleKeys {
+			state.counts = make(map[uint64]uint64)
+		}
+		count := state.counts[sum]
+		state.counts[sum] = count + 1
+		if count%uint64(s.config.Every) != 0 {
+			state.suppressed++
+			return false
+		}
+	}
+
+	if s.config.Rate > 0 {
+		if state.bucket == nil {
+			state.bucket = ratelimit.NewTokenBucket(s.config.Rate, s.config.Burst)
+		}
+		if !state.bucket.Allow() {
+			state.suppressed++
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * 热更新抽样比例以及限流速率，汇总周期以及生效的级别不变
+ */
+func (s *sampler) reconfigure(config SamplingConfig) {
+	if config.Burst <= 0 {
+		config.Burst = int(config.Rate)
+		if config.Burst < 1 {
+			config.Burst = 1
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.config.Every = config.Every
+	s.config.Rate = config.Rate
+	s.config.Burst = config.Burst
+	for _, state := range s.states {
+		if state.bucket != nil {
+			state.bucket.SetRate(config.Rate, config.Burst)
+		}
+	}
+}
+
+/*
+ * 取出每个级别本周期丢弃的记录数，并开始新的周期
+ * @return 级别 -> 丢弃数，只包含有丢弃的级别
+ */
+func (s *sampler) rotate() map[string]uint64 {
+	s.Lock()
+	defer s.Unlock()
+	suppressed := make(map[string]uint64)
+	for level, state := range s.states {
+		if state.suppressed > 0 {
+			suppressed[level] = state.suppressed
+		}
+		state.suppressed = 0
+		state.counts = make(map[uint64]uint64)
+	}
+	return suppressed
+}
+
+/*
+ * 汇总协程，每个周期输出一次丢弃的记录数，Close时输出最后一次
+ */
+func (logger *Logger) runSampler() {
+	s := logger.sampler
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			logger.writeSuppressed(s.rotate())
+		case <-s.stop:
+			logger.writeSuppressed(s.rotate())
+			return
+		}
+	}
+}
+
+/*
+ * 在对应的级别中输出丢弃的记录数，不经过抽样
+ */
+func (logger *Logger) writeSuppressed(suppressed map[string]uint64) {
+	for level, n := range suppressed {
+		loggerInfo, enabled, err := logger.levelInfo(level)
+		if err != nil || !enabled {
+			continue
+		}
+		if loggerInfo = logger.route(level, loggerInfo, nil); loggerInfo == nil {
+			continue
+		}
+		msg := "suppressed " + strconv.FormatUint(n, 10) + " similar messages in " + logger.sampler.config.Interval.String()
+		loggerInfo.Write(logger.encode(level, "", true, []interface{}{msg}, nil))
+	}
+}
+
+/*
+ * 停止汇总协程，Close时在关闭日志文件之前调用
+ */
+func (s *sampler) close() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+}
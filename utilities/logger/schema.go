@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// JSONSchemaVersion is the record schema version written by JSONEncoder
+/*
+ * JSON日志记录格式版本，字段改名、新增必填字段等不兼容的变化需要递增，
+ * 并且通过SchemaMigrator.Register提供从上一个版本升级的函数
+ * 版本1之前的记录没有schema字段，视为版本0
+ */
+const JSONSchemaVersion = 1
+
+// schemaKey 记录中的版本字段
+const schemaKey = "schema"
+
+// ErrSchemaTooNew is returned when a record was written by a newer schema than supported
+var ErrSchemaTooNew = errors.New("logger: record schema is newer than supported")
+
+// Record is a decoded JSON log record
+type Record map[string]interface{}
+
+// Migration upgrades a record by one schema version in place
+type Migration func(record Record) error
+
+// SchemaMigrator upgrades historical JSON records to the current schema
+/*
+ * 日志记录升级，读取历史日志的程序先通过Decode/Migrate升级到JSONSchemaVersion，
+ * 再按照当前格式处理，避免为每个历史版本编写解析逻辑
+ */
+type SchemaMigrator struct {
+	mu         sync.RWMutex
+	migrations map[int]Migration
+}
+
+// NewSchemaMigrator creates a migrator with the built-in migrations registered
+func NewSchemaMigrator() *SchemaMigrator {
+	migrator := &SchemaMigrator{migrations: make(map[int]Migration)}
+	// 版本0到1只增加了schema字段，记录格式不变
+	migrator.Register(0, func(record Record) error { return nil })
+	return migrator
+}
+
+// Register sets the migration from version `from` to `from+1`
+/*
+ * 注册升级函数，已经存在时覆盖
+ * @param from：升级前的版本
+ * @param migration：升级函数，直接修改record，不需要设置schema字段
+ */
+func (migrator *SchemaMigrator) Register(from int, migration Migration) {
+	migrator.mu.Lock()
+	migrator.migrations[from] = migration
+	migrator.mu.Unlock()
+}
+
+// Migrate upgrades record to JSONSchemaVersion in place
+/*
+ * 将记录逐个版本升级到JSONSchemaVersion
+ * @param record：待升级的记录
+ * @return 记录版本比当前版本新时返回ErrSchemaTooNew；缺少升级函数或者升级失败返回error
+ */
+func (migrator *SchemaMigrator) Migrate(record Record) error {
+	version, err := recordVersion(record)
+	if err != nil {
+		return err
+	}
+	if version > JSONSchemaVersion {
+		return ErrSchemaTooNew
+	}
+	migrator.mu.RLock()
+	defer migrator.mu.RUnlock()
+	for ; version < JSONSchemaVersion; version++ {
+		migration, ok := migrator.migrations[version]
+		if !ok {
+			return fmt.Errorf("logger: no migration from schema %d", version)
+		}
+		if err := migration(record); err != nil {
+			return fmt.Errorf("logger: migrate schema %d: %w", version, err)
+		}
+		record[schemaKey] = json.Number(fmt.Sprint(version + 1))
+	}
+	return nil
+}
+
+// Decode parses a JSON log line and upgrades it to JSONSchemaVersion
+/*
+ * 解析一行JSON日志并升级，数字以json.Number保存，避免大整数丢失精度
+ * @param line：一行日志，可以带换行符
+ * @return 升级后的记录；解析或者升级失败返回error
+ */
+func (migrator *SchemaMigrator) Decode(line []byte) (Record, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	record := make(Record)
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	if err := migrator.Migrate(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+/*
+ * 获取记录的版本，没有schema字段时为0
+ */
+func recordVersion(record Record) (int, error) {
+	value, ok := record[schemaKey]
+	if !ok {
+		return 0, nil
+	}
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	case float64:
+		return int(v), nil
+	case int:
+		return v, nil
+	}
+	return 0, fmt.Errorf("logger: invalid schema field %v", value)
+}
@@ -0,0 +1,422 @@
+package logger
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShipFraming is the framing of records sent by ShipWriter
+type ShipFraming int
+
+const (
+	// FrameNewline sends records delimited by newlines, e.g. NDJSON with JSONEncoder
+	FrameNewline ShipFraming = iota
+	// FrameLengthPrefix prefixes each record with its length as a 4-byte big-endian integer
+	FrameLengthPrefix
+)
+
+// ShipWriter默认参数
+const (
+	defaultShipQueue       = 1024
+	defaultShipMinBackoff  = 100 * time.Millisecond
+	defaultShipMaxBackoff  = 30 * time.Second
+	defaultShipDialTimeout = 5 * time.Second
+	defaultShipSpoolSize   = 256 * MB
+	maxCodecReply          = 64 // 协商压缩算法时收集端回复的最大长度
+)
+
+// ErrShipDropped is returned when a batch is dropped because the queue and the spool are full
+var ErrShipDropped = errors.New("logger: ship queue and spool full, batch dropped")
+
+// ShipConfig configures a ShipWriter
+type ShipConfig struct {
+	Network     string        // tcp/udp
+	Address     string        // 日志收集服务地址
+	Framing     ShipFraming   // 分帧方式，默认按换行分帧
+	QueueSize   int           // 发送队列长度(批数)，默认1024
+	MinBackoff  time.Duration // 重连的初始等待时间，默认100ms，之后每次失败翻倍
+	MaxBackoff  time.Duration // 重连的最长等待时间，默认30s
+	DialTimeout time.Duration // 连接超时，默认5s
+	SpoolDir    string        // 溢出文件目录，为空时使用os.TempDir()
+	SpoolSize   int64         // 溢出文件大小上限，默认256MB
+	OnError     ErrorHandler  // 连接以及发送失败的回调，为nil时输出到标准错误
+	Binary      bool          // 记录由MsgpackEncoder/ProtoEncoder编码，按照长度前缀切分，分帧方式固定为FrameLengthPrefix
+	Compression []string      // 按照优先顺序排列的压缩算法，例如{"zstd", "gzip"}，为空表示不压缩，参考RegisterCompressor
+	Negotiate   bool          // 连接之后与收集端协商压缩算法，只用于tcp，协议参考NewShipWriter
+	BatchBytes  int           // 发送之前合并队列中的批次，直到达到该字节数，合并越多压缩率越高；0表示不合并
+	Linger      time.Duration // 合并时等待更多批次的最长时间，0表示只合并队列中已有的批次
+}
+
+// ShipWriter is a sink shipping records to a central collector over the network
+/*
+ * 网络日志投递：Write只将一批记录放入发送队列，由后台协程发送，不会阻塞日志的flush协程
+ * 连接断开时按照指数退避重连；连接不可用或者队列已满时记录写入溢出文件，重连之后优先重放溢出文件
+ * 溢出文件中有数据时新的记录也写入溢出文件，尽量保持顺序；发送失败的一批会重新写入溢出文件，远端可能收到重复记录
+ * Close时没有发送的记录保留在溢出文件中，下次使用相同地址创建ShipWriter时重放
+ */
+type ShipWriter struct {
+	config   ShipConfig
+	queue    chan []byte
+	spool    *spool
+	reporter *reporter
+	codec    Compressor // 当前连接使用的压缩算法，nil表示不压缩
+	conn     net.Conn
+	backoff  time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewShipWriter creates a ShipWriter and starts the sending goroutine
+/*
+ * 创建网络日志投递，通过AddSink添加到Logger，例如：
+ *   w, err := NewShipWriter(ShipConfig{Network: "tcp", Address: "10.0.0.1:5170"})
+ *   logger.AddSink("ship", w)
+ * 远端不可用时同样创建成功，记录写入溢出文件等待重连
+ * 开启压缩时tcp的每一批为 4字节大端长度+压缩后的内容，解压之后为按照Framing分帧的记录；udp的每个数据报单独压缩
+ * Negotiate为true时每次连接之后先发送一行 "CODECS zstd,gzip,none\n"(本地已注册的Compression)，
+ * 收集端回复一行选中的算法名称，回复none表示不压缩；没有开启协商时使用Compression中第一个已注册的算法
+ * @param config：投递配置
+ * @return 打开溢出文件失败或者Compression中没有已注册的算法时返回error
+ */
+func NewShipWriter(config ShipConfig) (*ShipWriter, error) {
+	if config.Address == "" {
+		return nil, errors.New("logger: ship address required")
+	}
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	if config.Binary {
+		config.Framing = FrameLengthPrefix
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultShipQueue
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultShipMinBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = defaultShipMaxBackoff
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaultShipDialTimeout
+	}
+	if config.SpoolDir == "" {
+		config.SpoolDir = os.TempDir()
+	}
+	if config.SpoolSize <= 0 {
+		config.SpoolSize = defaultShipSpoolSize
+	}
+
+	codec, err := negotiateCompression(config.Compression, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &reporter{}
+	if config.OnError != nil {
+		r.handler.Store(config.OnError)
+	}
+	name := "ship-" + strings.NewReplacer(":", "_", "/", "_", `\`, "_").Replace(config.Network+"-"+config.Address)
+	s, err := openSpool(config.SpoolDir, name, config.SpoolSize, r)
+	if err != nil {
+		return nil, err
+	}
+	w := &ShipWriter{
+		config:   config,
+		queue:    make(chan []byte, config.QueueSize),
+		spool:    s,
+		reporter: r,
+		codec:    codec,
+		backoff:  config.MinBackoff,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write queues a batch of records, spilling to the spool when the queue is full
+func (w *ShipWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.stop:
+		return 0, ErrClosed
+	default:
+	}
+	if !w.spool.pending() {
+		select {
+		case w.queue <- append([]byte(nil), p...):
+			return len(p), nil
+		default:
+		}
+	}
+	if !w.spool.write(p) {
+		return 0, ErrShipDropped
+	}
+	return len(p), nil
+}
+
+// Close stops the sending goroutine, unsent records stay in the spool
+/*
+ * 停止发送协程：连接可用时发送队列中剩余的记录，其余记录保留在溢出文件中，重复调用是安全的
+ * 需要在RemoveSink或者Logger.Close之后调用
+ */
+func (w *ShipWriter) Close() error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+	return nil
+}
+
+/*
+ * 发送协程：建立连接，重放溢出文件，然后发送队列中的记录
+ */
+func (w *ShipWriter) run() {
+	defer close(w.done)
+	for {
+		if w.conn == nil && !w.connect() {
+			if !w.wait() {
+				w.shutdown()
+				return
+			}
+			continue
+		}
+		if content := w.spool.take(); len(content) > 0 {
+			w.send(content)
+			continue
+		}
+		select {
+		case batch := <-w.queue:
+			w.send(w.coalesce(batch))
+		case <-w.spool.ready:
+		case <-w.stop:
+			w.shutdown()
+			return
+		}
+	}
+}
+
+/*
+ * 建立连接，成功时重置退避时间
+ * @return 连接成功返回true
+ */
+func (w *ShipWriter) connect() bool {
+	conn, err := net.DialTimeout(w.config.Network, w.config.Address, w.config.DialTimeout)
+	if err != nil {
+		w.reporter.report("Ship.Dial", err)
+		return false
+	}
+	if w.config.Negotiate && !strings.HasPrefix(w.config.Network, "udp") {
+		codec, err := w.negotiate(conn)
+		if err != nil {
+			w.reporter.report("Ship.Negotiate", err)
+			conn.Close()
+			return false
+		}
+		w.codec = codec
+	}
+	w.conn = conn
+	w.backoff = w.config.MinBackoff
+	return true
+}
+
+/*
+ * 与收集端协商压缩算法：发送本地可用的算法列表，读取收集端选中的算法
+ * @return (压缩算法，none时为nil, error)
+ */
+func (w *ShipWriter) negotiate(conn net.Conn) (Compressor, error) {
+	conn.SetDeadline(time.Now().Add(w.config.DialTimeout))
+	defer conn.SetDeadline(time.Time{})
+	offered := availableCompressions(w.config.Compression)
+	if _, err := conn.Write([]byte("CODECS " + strings.Join(offered, ",") + "\n")); err != nil {
+		return nil, err
+	}
+	// 逐字节读取，不读取回复之后的内容
+	var reply []byte
+	var b [1]byte
+	for len(reply) <= maxCodecReply {
+		if _, err := conn.Read(b[:]); err != nil {
+			return nil, err
+		}
+		if b[0] == '\n' {
+			chosen := strings.TrimSpace(string(reply))
+			return negotiateCompression([]string{chosen}, func(name string) bool {
+				for _, offer := range offered {
+					if offer == name {
+						return true
+					}
+				}
+				return false
+			})
+		}
+		reply = append(reply, b[0])
+	}
+	return nil, errors.New("logger: codec negotiation reply too long")
+}
+
+/*
+ * 开启BatchBytes时合并队列中的批次，最多等待Linger
+ * @param batch：从队列中取出的第一批
+ * @return 合并之后的一批
+ */
+func (w *ShipWriter) coalesce(batch []byte) []byte {
+	if len(batch) >= w.config.BatchBytes {
+		return batch
+	}
+	var linger <-chan time.Time
+	if w.config.Linger > 0 {
+		timer := time.NewTimer(w.config.Linger)
+		defer timer.Stop()
+		linger = timer.C
+	}
+	for len(batch) < w.config.BatchBytes {
+		select {
+		case more := <-w.queue:
+			batch = append(batch, more...)
+			continue
+		default:
+		}
+		if linger == nil {
+			break
+		}
+		select {
+		case more := <-w.queue:
+			batch = append(batch, more...)
+		case <-linger:
+			return batch
+		case <-w.stop:
+			return batch
+		}
+	}
+	return batch
+}
+
+/*
+ * 等待退避时间后重连，等待期间将队列中的记录转入溢出文件
+ * @return Close时返回false
+ */
+func (w *ShipWriter) wait() bool {
+	timer := time.NewTimer(w.backoff)
+	defer timer.Stop()
+	if w.backoff *= 2; w.backoff > w.config.MaxBackoff {
+		w.backoff = w.config.MaxBackoff
+	}
+	for {
+		select {
+		case batch := <-w.queue:
+			w.spill(batch)
+		case <-timer.C:
+			return true
+		case <-w.stop:
+			return false
+		}
+	}
+}
+
+/*
+ * 发送一批记录，失败时关闭连接并将这一批写入溢出文件
+ */
+func (w *ShipWriter) send(batch []byte) {
+	if err := w.writeFrames(batch); err != nil {
+		w.reporter.report("Ship.Write", err)
+		w.conn.Close()
+		w.conn = nil
+		w.spill(batch)
+	}
+}
+
+/*
+ * 将一批记录按照配置的方式分帧并写入连接，udp时每条记录一个数据报
+ */
+func (w *ShipWriter) writeFrames(batch []byte) error {
+	datagram := strings.HasPrefix(w.config.Network, "udp")
+	var buf []byte
+	for len(batch) > 0 {
+		var record []byte
+		record, batch = nextRecord(batch, w.config.Binary)
+		if len(record) == 0 {
+			continue
+		}
+		if w.config.Framing == FrameLengthPrefix {
+			var size [4]byte
+			binary.BigEndian.PutUint32(size[:], uint32(len(record)))
+			buf = append(buf, size[:]...)
+			buf = append(buf, record...)
+		} else {
+			buf = append(buf, record...)
+			buf = append(buf, '\n')
+		}
+		if datagram {
+			if err := w.writeCompressed(buf, false); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	return w.writeCompressed(buf, true)
+}
+
+/*
+ * 开启压缩时压缩之后写入连接
+ * @param prefixed：压缩之后的内容前是否加4字节长度，tcp时需要
+ */
+func (w *ShipWriter) writeCompressed(buf []byte, prefixed bool) error {
+	if w.codec != nil {
+		var dst []byte
+		if prefixed {
+			dst = make([]byte, 4, 4+len(buf)/2)
+		}
+		compressed, err := compressBatch(w.codec, dst, buf)
+		if err != nil {
+			return err
+		}
+		if prefixed {
+			binary.BigEndian.PutUint32(compressed, uint32(len(compressed)-4))
+		}
+		buf = compressed
+	}
+	_, err := w.conn.Write(buf)
+	return err
+}
+
+/*
+ * 将一批记录写入溢出文件，溢出文件已满时丢弃
+ */
+func (w *ShipWriter) spill(batch []byte) {
+	if !w.spool.write(batch) {
+		w.reporter.drop()
+		w.reporter.report("Ship.Spool", ErrShipDropped)
+	}
+}
+
+/*
+ * Close时处理剩余的记录：连接可用时发送，否则写入溢出文件，最后关闭连接以及溢出文件
+ */
+func (w *ShipWriter) shutdown() {
+	for {
+		select {
+		case batch := <-w.queue:
+			if w.conn != nil {
+				w.send(batch)
+			} else {
+				w.spill(batch)
+			}
+			continue
+		default:
+		}
+		break
+	}
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	w.spool.close()
+}
@@ -0,0 +1,49 @@
+package logger
+
+// WithSingleFile writes all levels to one file with the level tagged in each record
+/*
+ * 单文件模式：所有级别(包括自定义级别)写入同一个文件 filename.log，默认仍然是每个级别一个文件
+ * 使用TextEncoder时每条记录带有级别标记，例如 时间|[WARN]|...；JSONEncoder本身带有level字段
+ * 开启租户隔离时每个租户同样只有一个文件；通过Write写入的自定义文件不受影响
+ * 共用文件中一批记录包含多个级别，因此只有不限制级别的sink会收到记录，指定Level的告警规则不会匹配
+ */
+func WithSingleFile() Option {
+	return func(logger *Logger) {
+		logger.singleFile = true
+	}
+}
+
+/*
+ * 创建所有内置级别共用的LoggerInfo，调用方需要持有写锁或者在NewLogger中调用
+ * @return 创建文件失败时返回error
+ */
+func (logger *logCore) startSingleFile() error {
+	if encoder, ok := logger.encoder.(TextEncoder); ok {
+		encoder.LevelTag = true
+		logger.encoder = encoder
+	}
+	loggerInfo, err := logger.startCombinedInfo(logger.filename+".log", logger.backupDir)
+	if err != nil {
+		return err
+	}
+	for _, level := range logLevel {
+		logger.logMap[level] = loggerInfo
+	}
+	return nil
+}
+
+/*
+ * 创建多个级别共用的LoggerInfo
+ * @param filename：日志文件名
+ * @param backupDir：备份目录
+ * @return 成功则返回(*LoggerInfo, nil)；否则返回(nil, error)
+ */
+func (logger *logCore) startCombinedInfo(filename, backupDir string) (*LoggerInfo, error) {
+	loggerInfo, err := logger.startLoggerInfo(filename, "", backupDir)
+	if err != nil {
+		return nil, err
+	}
+	loggerInfo.combined = true
+	loggerInfo.setSinks(logger.sinks)
+	return loggerInfo, nil
+}
@@ -0,0 +1,295 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// ErrSinkExists is returned when adding a sink with a name already in use
+var ErrSinkExists = errors.New("logger: sink already exists")
+
+// sink is an extra destination of the records of some levels
+type sink struct {
+	name    string
+	writer  io.Writer
+	levels  map[string]bool // 为空表示所有级别
+	encoder Encoder         // 单独的编码器，nil表示与日志文件的内容相同
+	mu      sync.Mutex
+	pending []sinkRecords // 使用单独编码器时等待flush协程写入的记录，受mu保护
+}
+
+// sinkRecords 使用单独编码器的sink中同一个级别的连续记录
+type sinkRecords struct {
+	level    string // 写入的日志文件的级别
+	severity int
+	content  []byte
+}
+
+/*
+ * 判断sink是否接收该级别的记录，自定义文件(level为空)不输出到sink
+ */
+func (s *sink) accepts(level string) bool {
+	return level != "" && (len(s.levels) == 0 || s.levels[level])
+}
+
+// AddSink writes records of the given levels to w in addition to the log files
+/*
+ * 添加额外的输出，例如标准输出或者网络连接，一个级别可以同时输出到多个sink
+ * sink在flush协程中写入，每次写入一批完整的记录；写入失败通过错误回调上报，不影响日志文件
+ * sink写入较慢时会拖慢对应日志文件的写入，网络输出建议自行缓冲
+ * Logger不会关闭w，RemoveSink或者Close之后由调用方关闭
+ * @param name：sink名称，用于RemoveSink
+ * @param w：输出
+ * @param levels：接收的日志级别，为空表示所有级别(包括之后注册的自定义级别)
+ * @return 名称已经存在返回ErrSinkExists
+ */
+func (logger *Logger) AddSink(name string, w io.Writer, levels ...string) error {
+	return logger.addSink(newSink(name, w, nil, levels))
+}
+
+// AddEncodedSink is AddSink with a separate encoder for the sink
+/*
+ * 添加使用单独编码器的输出，例如日志文件使用JSONEncoder供采集系统解析，终端使用TextEncoder方便本地查看：
+ *     log, _ := logger.NewLogger(name, suffix, backupDir, logger.WithEncoder(logger.JSONEncoder{}))
+ *     log.AddEncodedSink("console", logger.NewConsoleWriter(logger.ColorAuto), logger.TextEncoder{LevelTag: true})
+ * 每条记录只构建一次Entry(hook也只执行一次)，每种编码器只编码一次，编码器与日志文件相同的sink直接使用日志文件的内容
+ * 记录在对应级别的日志文件flush时写入sink，与AddSink相同；单文件模式下同样可以限制级别
+ * 写入队列满时被丢弃的buffer、同步写入失败的记录仍然会输出到该sink；通过Write写入的自定义文件以及Channel不输出到sink
+ * @param name：sink名称，用于RemoveSink
+ * @param w：输出
+ * @param encoder：sink使用的编码器，为nil时与AddSink相同
+ * @param levels：接收的日志级别，为空表示所有级别
+ * @return 名称已经存在返回ErrSinkExists
+ */
+func (logger *Logger) AddEncodedSink(name string, w io.Writer, encoder Encoder, levels ...string) error {
+	return logger.addSink(newSink(name, w, encoder, levels))
+}
+
+/*
+ * 创建sink
+ */
+func newSink(name string, w io.Writer, encoder Encoder, levels []string) *sink {
+	s := &sink{name: name, writer: w, encoder: encoder}
+	if len(levels) > 0 {
+		s.levels = make(map[string]bool, len(levels))
+		for _, level := range levels {
+			s.levels[level] = true
+		}
+	}
+	return s
+}
+
+/*
+ * 添加sink
+ * @return 名称已经存在返回ErrSinkExists
+ */
+func (logger *Logger) addSink(s *sink) error {
+	logger.Lock()
+	defer logger.Unlock()
+	for _, existing := range logger.sinks {
+		if existing.name == s.name {
+			return ErrSinkExists
+		}
+	}
+	logger.sinks = append(logger.sinks, s)
+	logger.updateSinks()
+	return nil
+}
+
+// RemoveSink stops writing to the named sink
+/*
+ * 移除sink，返回之后flush协程可能还会完成正在进行的一次写入
+ * @param name：sink名称
+ * @return 返回true表示sink存在并已移除
+ */
+func (logger *Logger) RemoveSink(name string) bool {
+	logger.Lock()
+	defer logger.Unlock()
+	for i, existing := range logger.sinks {
+		if existing.name == name {
+			sinks := make([]*sink, 0, len(logger.sinks)-1)
+			sinks = append(sinks, logger.sinks[:i]...)
+			logger.sinks = append(sinks, logger.sinks[i+1:]...)
+			logger.updateSinks()
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * 重新计算所有LoggerInfo的sink列表，调用方需要持有写锁
+ */
+func (logger *logCore) updateSinks() {
+	for _, loggerInfo := range logger.logMap {
+		loggerInfo.setSinks(logger.sinks)
+	}
+	logger.storeEncodedSinks()
+}
+
+/*
+ * 保存使用单独编码器的sink，编码时不需要加锁读取，调用方需要持有写锁或者在NewLogger中调用
+ */
+func (logger *logCore) storeEncodedSinks() {
+	var encoded []*sink
+	for _, s := range logger.sinks {
+		if s.encoder != nil {
+			encoded = append(encoded, s)
+		}
+	}
+	logger.encodedSinks.Store(encoded)
+}
+
+/*
+ * 设置LoggerInfo的sink列表
+ * @param sinks：Logger的所有sink，只保留接收该级别的sink
+ */
+func (logger *LoggerInfo) setSinks(sinks []*sink) {
+	var accepted []*sink
+	for _, s := range sinks {
+		// 使用单独编码器的sink按照记录的级别缓存，共用文件时由共用文件写入所有级别的记录
+		if s.accepts(logger.level) || logger.combined && (len(s.levels) == 0 || s.encoder != nil) {
+			accepted = append(accepted, s)
+		}
+	}
+	logger.sinks.Store(accepted)
+}
+
+/*
+ * 将一批记录写入所有sink，只能在FlushBufferQueue协程中调用
+ * @param content：写入日志文件的内容
+ */
+func (logger *LoggerInfo) writeSinks(content []byte) {
+	sinks, _ := logger.sinks.Load().([]*sink)
+	for _, s := range sinks {
+		if s.encoder != nil {
+			logger.writeEncodedSink(s)
+			continue
+		}
+		var err error
+		if w, ok := s.writer.(LevelWriter); ok {
+			_, err = w.WriteLevel(logger.level, logger.severity, content)
+		} else {
+			_, err = s.writer.Write(content)
+		}
+		if err != nil {
+			logger.reporter.report("Sink."+s.name, err)
+		}
+	}
+}
+
+/*
+ * 将sink中等待写入的该日志文件级别的记录写入sink，共用文件时写入所有级别，只能在FlushBufferQueue协程中调用
+ * 连续的同一级别记录一次写入
+ */
+func (logger *LoggerInfo) writeEncodedSink(s *sink) {
+	s.mu.Lock()
+	var taken []sinkRecords
+	if logger.combined {
+		taken, s.pending = s.pending, nil
+	} else {
+		kept := s.pending[:0]
+		for _, records := range s.pending {
+			if records.level == logger.level {
+				taken = append(taken, records)
+			} else {
+				kept = append(kept, records)
+			}
+		}
+		// 清空尾部避免持有已经取出的内容
+		for i := len(kept); i < len(s.pending); i++ {
+			s.pending[i] = sinkRecords{}
+		}
+		s.pending = kept
+	}
+	s.mu.Unlock()
+
+	for _, records := range taken {
+		var err error
+		if w, ok := s.writer.(LevelWriter); ok {
+			_, err = w.WriteLevel(records.level, records.severity, records.content)
+		} else {
+			_, err = s.writer.Write(records.content)
+		}
+		if err != nil {
+			logger.reporter.report("Sink."+s.name, err)
+		}
+	}
+}
+
+/*
+ * 将记录追加到sink的等待队列，与上一批记录级别相同时合并
+ */
+func (s *sink) push(level string, severity int, content []byte) {
+	s.mu.Lock()
+	if n := len(s.pending); n > 0 && s.pending[n-1].level == level {
+		s.pending[n-1].content = append(s.pending[n-1].content, content...)
+	} else {
+		s.pending = append(s.pending, sinkRecords{level: level, severity: severity, content: append([]byte(nil), content...)})
+	}
+	s.mu.Unlock()
+}
+
+/*
+ * 按照使用单独编码器的sink编码记录，每种编码器只编码一次，编码器与日志文件相同时使用日志文件的内容
+ * @param entry：执行过hook的记录
+ * @param content：日志文件的内容
+ */
+func (logger *Logger) encodeSinks(entry *Entry, content []byte) {
+	sinks, _ := logger.encodedSinks.Load().([]*sink)
+	if len(sinks) == 0 || entry.Level == "" {
+		return
+	}
+	level := sinkLevel(entry.Level)
+	severity := -1
+	type encoded struct {
+		encoder Encoder
+		content []byte
+	}
+	formats := []encoded{{logger.encoder, content}}
+	for _, s := range sinks {
+		if !s.accepts(level) {
+			continue
+		}
+		var data []byte
+		for _, format := range formats {
+			if sameEncoder(format.encoder, s.encoder) {
+				data = format.content
+				break
+			}
+		}
+		if data == nil {
+			data = s.encoder.Encode(entry)
+			formats = append(formats, encoded{s.encoder, data})
+		}
+		if severity < 0 {
+			logger.RLock()
+			severity = logger.levels[level]
+			logger.RUnlock()
+		}
+		s.push(level, severity, data)
+	}
+}
+
+/*
+ * 记录写入的日志文件级别，Fatal/Panic以及Recover的记录写入error日志文件
+ */
+func sinkLevel(level string) string {
+	switch level {
+	case "fatal", "panic", "recover":
+		return "error"
+	}
+	return level
+}
+
+/*
+ * 判断两个编码器是否相同，不可比较的编码器视为不同
+ */
+func sameEncoder(a, b Encoder) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
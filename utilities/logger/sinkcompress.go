@@ -0,0 +1,239 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 内置的压缩算法名称
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// ErrNoCompressor is returned when none of the preferred compression codecs is registered
+var ErrNoCompressor = errors.New("logger: no registered compression codec in preference list")
+
+// Compressor compresses batches sent by remote sinks
+/*
+ * 远程sink的压缩算法，内置gzip；snappy、zstd等由调用方基于所使用的库实现并通过RegisterCompressor注册，例如：
+ *   type zstdCompressor struct{ enc *zstd.Encoder } // github.com/klauspost/compress/zstd
+ *   func (zstdCompressor) Name() string { return "zstd" }
+ *   func (c zstdCompressor) Compress(dst, src []byte) ([]byte, error) { return c.enc.EncodeAll(src, dst), nil }
+ *   logger.RegisterCompressor(zstdCompressor{enc})
+ * Compress将压缩结果追加到dst之后，会被多个协程同时调用，实现需要保证并发安全
+ */
+type Compressor interface {
+	Name() string // 算法名称，与协商以及HTTP Content-Encoding使用的名称相同
+	Compress(dst, src []byte) ([]byte, error)
+}
+
+// CompressionStats counts the work done by one compression codec across all sinks
+type CompressionStats struct {
+	Batches         uint64        // 压缩的批次数
+	InputBytes      uint64        // 压缩之前的字节数
+	OutputBytes     uint64        // 压缩之后的字节数，与InputBytes的比值即为压缩率
+	Errors          uint64        // 压缩失败的批次数，失败的批次不压缩发送
+	CompressionTime time.Duration // 压缩累计耗时
+}
+
+// compressionCounters 一种压缩算法的计数，原子操作访问
+type compressionCounters struct {
+	batches     uint64
+	inputBytes  uint64
+	outputBytes uint64
+	errors      uint64
+	nanos       int64
+}
+
+// 已注册的压缩算法以及各算法的计数
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{CompressionGzip: NewGzipCompressor(gzip.DefaultCompression)}
+	compressions  sync.Map // 算法名称 -> *compressionCounters
+)
+
+// RegisterCompressor makes a compression codec available to the remote sinks
+/*
+ * 注册压缩算法，名称相同时替换，例如使用最高压缩级别的gzip：RegisterCompressor(NewGzipCompressor(gzip.BestCompression))
+ * 需要在创建sink之前注册，已经协商完成的sink不受影响
+ * @param c：压缩算法，名称不能为none
+ */
+func RegisterCompressor(c Compressor) {
+	if c.Name() == CompressionNone {
+		return
+	}
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Name()] = c
+}
+
+// SinkCompressionStats returns the counters of every compression codec used by the remote sinks
+func SinkCompressionStats() map[string]CompressionStats {
+	stats := make(map[string]CompressionStats)
+	compressions.Range(func(key, value interface{}) bool {
+		c := value.(*compressionCounters)
+		stats[key.(string)] = CompressionStats{
+			Batches:         atomic.LoadUint64(&c.batches),
+			InputBytes:      atomic.LoadUint64(&c.inputBytes),
+			OutputBytes:     atomic.LoadUint64(&c.outputBytes),
+			Errors:          atomic.LoadUint64(&c.errors),
+			CompressionTime: time.Duration(atomic.LoadInt64(&c.nanos)),
+		}
+		return true
+	})
+	return stats
+}
+
+// NewGzipCompressor returns a gzip Compressor with the given level, reusing gzip writers
+/*
+ * 创建gzip压缩，内部复用gzip.Writer
+ * @param level：压缩级别，参考compress/gzip，级别不合法时使用gzip.DefaultCompression
+ */
+func NewGzipCompressor(level int) Compressor {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		level = gzip.DefaultCompression
+	}
+	c := &gzipCompressor{}
+	c.pool.New = func() interface{} {
+		zw, _ := gzip.NewWriterLevel(nil, level)
+		return zw
+	}
+	return c
+}
+
+// gzipCompressor 复用gzip.Writer的gzip压缩
+type gzipCompressor struct {
+	pool sync.Pool
+}
+
+func (c *gzipCompressor) Name() string { return CompressionGzip }
+
+func (c *gzipCompressor) Compress(dst, src []byte) ([]byte, error) {
+	w := &appendWriter{buf: dst}
+	zw := c.pool.Get().(*gzip.Writer)
+	zw.Reset(w)
+	_, err := zw.Write(src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	zw.Reset(nil)
+	c.pool.Put(zw)
+	return w.buf, err
+}
+
+// appendWriter 追加到切片的io.Writer
+type appendWriter struct {
+	buf []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+/*
+ * 按照优先顺序选择第一个已注册并且对端接受的压缩算法，未注册的名称跳过
+ * @param preferred：优先顺序，为空表示不压缩
+ * @param accepts：对端是否接受该算法，为nil表示都接受
+ * @return (压缩算法，none时为nil, error)，没有可用的算法时返回ErrNoCompressor
+ */
+func negotiateCompression(preferred []string, accepts func(name string) bool) (Compressor, error) {
+	if len(preferred) == 0 {
+		return nil, nil
+	}
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	for _, name := range preferred {
+		if accepts != nil && !accepts(name) {
+			continue
+		}
+		if name == CompressionNone {
+			return nil, nil
+		}
+		if c, ok := compressors[name]; ok {
+			return c, nil
+		}
+	}
+	return nil, ErrNoCompressor
+}
+
+/*
+ * 返回优先顺序中已注册的算法名称，用于向对端提供可选的算法
+ */
+func availableCompressions(preferred []string) []string {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	names := make([]string, 0, len(preferred))
+	for _, name := range preferred {
+		if _, ok := compressors[name]; ok || name == CompressionNone {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+/*
+ * 压缩一批数据并计入该算法的统计
+ * @return 压缩失败时返回error，调用方发送原始数据
+ */
+func compressBatch(c Compressor, dst, src []byte) ([]byte, error) {
+	value, ok := compressions.Load(c.Name())
+	if !ok {
+		value, _ = compressions.LoadOrStore(c.Name(), &compressionCounters{})
+	}
+	counters := value.(*compressionCounters)
+	start := time.Now()
+	out, err := c.Compress(dst, src)
+	atomic.AddInt64(&counters.nanos, int64(time.Since(start)))
+	if err != nil {
+		atomic.AddUint64(&counters.errors, 1)
+		return dst, err
+	}
+	atomic.AddUint64(&counters.batches, 1)
+	atomic.AddUint64(&counters.inputBytes, uint64(len(src)))
+	atomic.AddUint64(&counters.outputBytes, uint64(len(out)-len(dst)))
+	return out, nil
+}
+
+/*
+ * 按照Prometheus文本格式输出各压缩算法的统计，没有使用过压缩时不输出
+ */
+func appendCompressionMetrics(buf []byte) []byte {
+	stats := SinkCompressionStats()
+	if len(stats) == 0 {
+		return buf
+	}
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, metric := range []struct {
+		name, help string
+		value      func(CompressionStats) string
+	}{
+		{"logger_sink_compressed_batches_total", "Batches compressed by remote sinks per codec.", func(s CompressionStats) string { return strconv.FormatUint(s.Batches, 10) }},
+		{"logger_sink_compression_input_bytes_total", "Bytes before compression per codec.", func(s CompressionStats) string { return strconv.FormatUint(s.InputBytes, 10) }},
+		{"logger_sink_compression_output_bytes_total", "Bytes after compression per codec.", func(s CompressionStats) string { return strconv.FormatUint(s.OutputBytes, 10) }},
+		{"logger_sink_compression_errors_total", "Batches failing to compress per codec.", func(s CompressionStats) string { return strconv.FormatUint(s.Errors, 10) }},
+		{"logger_sink_compression_seconds_total", "Time spent compressing per codec.", func(s CompressionStats) string {
+			return strconv.FormatFloat(s.CompressionTime.Seconds(), 'g', -1, 64)
+		}},
+	} {
+		buf = appendMetricHeader(buf, metric.name, "counter", metric.help)
+		for _, name := range names {
+			buf = append(buf, metric.name+`{codec="`...)
+			buf = append(buf, escapeLabel(name)...)
+			buf = append(buf, `"} `...)
+			buf = append(buf, metric.value(stats[name])...)
+			buf = append(buf, '\n')
+		}
+	}
+	return buf
+}
@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+)
+
+// SlogHandler is a slog.Handler writing to the per-level files of a Logger
+/*
+ * log/slog适配，slog的级别映射为内置级别：Error及以上为error，Warn为warn，Info为trace，低于Info为debug
+ * slog的属性作为附加字段输出，分组以"."连接为字段名，例如 slog.Group("req", "id", 1) 输出为req.id=1
+ * debug/trace记录带有调用位置，与Debug/Trace一致；级别过滤、租户隔离以及编码方式与Logger相同
+ * 调用slog的*Context函数时，context中的字段(参考WithContext)同样会输出
+ */
+type SlogHandler struct {
+	logger *Logger
+	fields Fields // WithAttrs添加的字段
+	prefix string // WithGroup添加的分组前缀，以"."结尾
+}
+
+// SlogHandler returns a slog.Handler backed by the logger
+/*
+ * 创建slog适配，例如：
+ *     slog.SetDefault(slog.New(logger.SlogHandler()))
+ * @return slog.Handler
+ */
+func (logger *Logger) SlogHandler() *SlogHandler {
+	return &SlogHandler{logger: logger}
+}
+
+// Enabled implements slog.Handler
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	h.logger.RLock()
+	defer h.logger.RUnlock()
+	return h.logger.CheckLevel(slogLevelName(level))
+}
+
+// Handle implements slog.Handler
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	level := slogLevelName(record.Level)
+	h.logger.RLock()
+	loggerInfo := h.logger.logMap[level]
+	enabled := h.logger.CheckLevel(level)
+	h.logger.RUnlock()
+	if !enabled {
+		return nil
+	}
+
+	fields := mergeFields(h.logger.contextFields(ctx), h.fields)
+	if record.NumAttrs() > 0 {
+		base := fields
+		fields = make(Fields, len(base)+record.NumAttrs())
+		for key, value := range base {
+			fields[key] = value
+		}
+		record.Attrs(func(attr slog.Attr) bool {
+			addSlogAttr(fields, h.prefix, attr)
+			return true
+		})
+	}
+	// 租户字段可能来自slog属性
+	if loggerInfo = h.logger.route(level, loggerInfo, fields); loggerInfo == nil || !h.logger.dedupe(loggerInfo, level, []interface{}{record.Message}, fields) || !h.logger.sampleMessage(level, record.Message) {
+		return nil
+	}
+
+	var at string
+	if h.logger.captureCaller(level) && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		at = formatCaller(frame.File, frame.Line, frame.Function)
+	}
+	h.logger.write(loggerInfo, level, h.logger.encode(level, at, true, []interface{}{record.Message}, fields))
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make(Fields, len(h.fields)+len(attrs))
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+	for _, attr := range attrs {
+		addSlogAttr(fields, h.prefix, attr)
+	}
+	return &SlogHandler{logger: h.logger, fields: fields, prefix: h.prefix}
+}
+
+// WithGroup implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{logger: h.logger, fields: h.fields, prefix: h.prefix + name + "."}
+}
+
+/*
+ * 将slog属性展开为字段，分组递归展开，key为空的分组直接展开到当前层级，空属性忽略
+ * @param fields：输出字段
+ * @param prefix：分组前缀
+ * @param attr：slog属性
+ */
+func addSlogAttr(fields Fields, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if attr.Key == "" && value.Kind() != slog.KindGroup {
+		return
+	}
+	switch value.Kind() {
+	case slog.KindGroup:
+		group := value.Group()
+		if len(group) == 0 {
+			return
+		}
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range group {
+			addSlogAttr(fields, prefix, a)
+		}
+	case slog.KindTime:
+		fields[prefix+attr.Key] = value.Time().Format(datetimeFormat)
+	default:
+		fields[prefix+attr.Key] = value.Any()
+	}
+}
+
+/*
+ * slog级别映射为内置级别名称
+ */
+func slogLevelName(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warn"
+	case level >= slog.LevelInfo:
+		return "trace"
+	}
+	return "debug"
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
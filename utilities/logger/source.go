@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// shortCommitLength 记录中提交号的长度
+const shortCommitLength = 12
+
+// SourceInfo identifies the process writing the records
+type SourceInfo struct {
+	Hostname string // 主机名
+	PID      int    // 进程号
+	Binary   string // 可执行文件名
+	Version  string // 主模块版本，go build时没有版本号(devel)为空
+	Commit   string // 编译时的vcs.revision，取前12位
+}
+
+// DetectSource collects the hostname, PID, binary name and build info of the process
+/*
+ * 采集进程的来源信息，版本以及提交号来自debug.ReadBuildInfo，
+ * 需要使用go 1.18以上版本在模块中编译，GOPATH模式或者-buildvcs=false编译时为空
+ * @return 来源信息
+ */
+func DetectSource() SourceInfo {
+	hostname, _ := os.Hostname()
+	source := SourceInfo{
+		Hostname: hostname,
+		PID:      os.Getpid(),
+		Binary:   filepath.Base(os.Args[0]),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Version != "(devel)" {
+			source.Version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				source.Commit = setting.Value
+				if len(source.Commit) > shortCommitLength {
+					source.Commit = source.Commit[:shortCommitLength]
+				}
+			}
+		}
+	}
+	return source
+}
+
+// Fields returns the non-empty source information as fields host, pid, bin, version and commit
+func (source SourceInfo) Fields() Fields {
+	fields := make(Fields, 5)
+	if source.Hostname != "" {
+		fields["host"] = source.Hostname
+	}
+	if source.PID != 0 {
+		fields["pid"] = source.PID
+	}
+	if source.Binary != "" {
+		fields["bin"] = source.Binary
+	}
+	if source.Version != "" {
+		fields["version"] = source.Version
+	}
+	if source.Commit != "" {
+		fields["commit"] = source.Commit
+	}
+	return fields
+}
+
+// String formats the source information as host=...|pid=...|bin=...|version=...|commit=...
+func (source SourceInfo) String() string {
+	parts := make([]string, 0, 5)
+	if source.Hostname != "" {
+		parts = append(parts, "host="+source.Hostname)
+	}
+	if source.PID != 0 {
+		parts = append(parts, "pid="+strconv.Itoa(source.PID))
+	}
+	if source.Binary != "" {
+		parts = append(parts, "bin="+source.Binary)
+	}
+	if source.Version != "" {
+		parts = append(parts, "version="+source.Version)
+	}
+	if source.Commit != "" {
+		parts = append(parts, "commit="+source.Commit)
+	}
+	return strings.Join(parts, "|")
+}
+
+// WithSourceFields attaches the source information of DetectSource to every record as fields
+/*
+ * 每条记录都附带host、pid、bin、version、commit字段，用于多实例部署时区分日志来源，JSON格式时便于检索
+ * 通过WithFields等设置的同名字段优先
+ */
+func WithSourceFields() Option {
+	return func(logger *Logger) {
+		logger.fields = mergeFields(DetectSource().Fields(), logger.fields)
+	}
+}
+
+// WithSourceSuffix appends the source information of DetectSource to the suffix of the logger
+/*
+ * 将来源信息追加到NewLogger的后缀信息之后，格式为 host=...|pid=...|bin=...|version=...|commit=...，
+ * 只有写入后缀的记录(级别函数以及suffix为true的Write)才会带上，比WithSourceFields的开销小
+ */
+func WithSourceSuffix() Option {
+	return func(logger *Logger) {
+		source := DetectSource().String()
+		if logger.suffixInfo == "" {
+			logger.suffixInfo = source
+		} else {
+			logger.suffixInfo += "|" + source
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package logger
+
+import (
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// defaultSpoolSize 溢出文件默认大小上限
+const defaultSpoolSize = 256 * MB
+
+// spool is a bounded overflow file for buffers that do not fit in bufferQueue
+/*
+ * 写入队列满时buffer会被追加到溢出文件，flush协程追上之后按顺序重放
+ * 溢出文件中有数据时，后续buffer也写入溢出文件，保证日志顺序
+ */
+type spool struct {
+	sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	size     int64         // 等待重放的数据大小
+	ready    chan struct{} // 有数据写入时通知flush协程
+	reporter *reporter
+}
+
+// WithSpool spills buffers to a disk spool when the write queue is full
+/*
+ * 开启溢出文件(<dir>/<日志文件名>.<路径hash>.spool)，写入队列满时将buffer写入溢出文件而不是阻塞等待，
+ * 文件写入恢复之后再按顺序重放，用延迟换取磁盘或者下游短暂卡住时不丢日志
+ * 进程异常退出时溢出文件中残留的数据会在下次启动时重放
+ * @param dir：溢出文件目录，为空时使用os.TempDir()
+ * @param maxBytes：每个日志文件对应的溢出文件大小上限，<=0时使用默认值256MB；
+ *                  超过上限之后按照WithOverflowPolicy的方式处理，默认阻塞等待写入队列
+ */
+func WithSpool(dir string, maxBytes int64) Option {
+	return func(logger *Logger) {
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		if maxBytes <= 0 {
+			maxBytes = defaultSpoolSize
+		}
+		logger.spoolDir = dir
+		logger.spoolSize = maxBytes
+	}
+}
+
+/*
+ * 打开溢出文件，文件中已有的数据会在flush协程启动后重放
+ * @param dir：溢出文件目录
+ * @param filename：对应的日志文件名
+ * @param maxBytes：大小上限
+ * @param reporter：错误上报
+ * @return 成功则返回(*spool, nil)；否则返回(nil, error)
+ */
+func openSpool(dir, filename string, maxBytes int64, reporter *reporter) (*spool, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	// 不同目录下可能存在同名日志文件(例如租户日志)，文件名中加入完整路径的hash
+	hash := fnv.New32a()
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	hash.Write([]byte(filename))
+	path := filepath.Join(dir, filepath.Base(filename)+"."+strconv.FormatUint(uint64(hash.Sum32()), 16)+".spool")
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s := &spool{
+		path:     path,
+		maxBytes: maxBytes,
+		file:     file,
+		size:     stat.Size(),
+		ready:    make(chan struct{}, 1),
+		reporter: reporter,
+	}
+	if s.size > 0 {
+		s.ready <- struct{}{}
+	}
+	return s, nil
+}
+
+/*
+ * 追加数据到溢出文件
+ * @param content：buffer内容
+ * @return 写入成功返回true；超过大小上限或者写入失败返回false
+ */
+func (s *spool) write(content []byte) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.size+int64(len(content)) > s.maxBytes {
+		return false
+	}
+	n, err := s.file.WriteAt(content, s.size)
+	s.size += int64(n)
+	if err != nil {
+		s.reporter.report("spool.Write", err)
+		return false
+	}
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+/*
+ * 溢出文件中是否有等待重放的数据
+ */
+func (s *spool) pending() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.size > 0
+}
+
+/*
+ * 取出溢出文件中的全部数据并清空文件
+ * @return 等待重放的数据，读取失败时数据保留在文件中
+ */
+func (s *spool) take() []byte {
+	s.Lock()
+	defer s.Unlock()
+	if s.size == 0 {
+		return nil
+	}
+	content := make([]byte, s.size)
+	if _, err := s.file.ReadAt(content, 0); err != nil && err != io.EOF {
+		s.reporter.report("spool.Read", err)
+		return nil
+	}
+	if err := s.file.Truncate(0); err != nil {
+		s.reporter.report("spool.Truncate", err)
+		return nil
+	}
+	s.size = 0
+	return content
+}
+
+/*
+ * 关闭并删除溢出文件，调用前需要已经重放所有数据
+ */
+func (s *spool) close() {
+	s.Lock()
+	defer s.Unlock()
+	s.file.Close()
+	if s.size == 0 {
+		os.Remove(s.path)
+	}
+}
+
+/*
+ * 将buffer写入队列，队列已满或者溢出文件中有数据时写入溢出文件
+ * 溢出文件超过大小上限时按照policy处理
+ * @param bufferQueue：写入队列
+ * @param s：溢出文件
+ * @param policy：溢出文件已满时的处理方式
+ * @param r：丢弃buffer时计数
+ */
+func (logger *LoggerBuffer) writeBufferOrSpool(bufferQueue chan queuedBuffer, s *spool, policy OverflowPolicy, r *reporter) {
+	logger.bufferLock.Lock()
+	defer logger.bufferLock.Unlock()
+	if logger.bufferContent.Len() == 0 {
+		return
+	}
+	if !s.pending() {
+		select {
+		case bufferQueue <- logger.queued():
+			logger.reset()
+			return
+		default:
+		}
+	}
+	if !s.write(logger.bufferContent.Bytes()) {
+		logger.sendBuffer(bufferQueue, policy, r)
+	} else {
+		putBuffer(logger.bufferContent)
+	}
+	logger.reset()
+}
+
+/*
+ * 写入队列为空时重放溢出文件中的数据，只能在FlushBufferQueue协程中调用
+ */
+func (logger *LoggerInfo) replaySpool() {
+	if logger.spool == nil || len(logger.bufferQueue) > 0 {
+		return
+	}
+	if content := logger.spool.take(); len(content) > 0 {
+		logger.flushBuffer(content)
+	}
+}
+
+/*
+ * 返回溢出文件的通知channel，未开启溢出文件时返回nil，select时永远不会被选中
+ */
+func (logger *LoggerInfo) spoolReady() <-chan struct{} {
+	if logger.spool == nil {
+		return nil
+	}
+	return logger.spool.ready
+}
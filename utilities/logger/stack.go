@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultStackDepth 未设置StackConfig.Depth时记录的最大调用层数
+const defaultStackDepth = 32
+
+// 日志包自身的函数名前缀，截取调用栈时跳过，第一次使用时初始化
+var (
+	stackPackageOnce sync.Once
+	stackPackage     string
+)
+
+// StackFrame is one frame of a captured call stack
+type StackFrame struct {
+	Function string // 完整函数名，例如github.com/a/b/pkg.(*T).Method
+	File     string // 文件路径，与调用位置相同的截取规则
+	Line     int    // 行号
+}
+
+// StackConfig configures WithStackTrace
+type StackConfig struct {
+	Levels []string // 记录调用栈的级别，为空时只有error
+	Depth  int      // 记录的最大调用层数，<=0时为32
+}
+
+// stackTrace 调用栈记录配置
+type stackTrace struct {
+	levels map[string]bool
+	depth  int
+}
+
+// WithStackTrace appends the call stack of the caller to records of the given levels
+/*
+ * 在指定级别的记录中附带调用栈，用于定位错误日志的调用链路，例如WithStackTrace(StackConfig{Depth: 16})
+ * 文本格式在字段之后输出一行 stack=pkg.Func(file.go:12)<-pkg.Caller(file.go:34)...，
+ * JSON格式输出stack字段，内容与panic时的调用栈格式相同(函数名换行后跟文件:行号)
+ * 调用栈从调用日志函数的位置开始，AddCallerSkip的层数同样会跳过；开启后Fatal/Panic也会附带调用栈
+ * 获取调用栈需要runtime.Callers，只建议用于error等低频级别
+ * @param config：记录的级别以及层数
+ */
+func WithStackTrace(config StackConfig) Option {
+	return func(logger *Logger) {
+		levels := config.Levels
+		if len(levels) == 0 {
+			levels = []string{"error"}
+		}
+		trace := &stackTrace{levels: make(map[string]bool, len(levels)+2), depth: config.Depth}
+		for _, level := range levels {
+			trace.levels[level] = true
+		}
+		trace.levels["fatal"] = true
+		trace.levels["panic"] = true
+		if trace.depth <= 0 {
+			trace.depth = defaultStackDepth
+		}
+		logger.stack = trace
+	}
+}
+
+// Recover logs a recovered panic with its stack to the error log, for use as `defer logger.Recover()`
+/*
+ * 捕获当前协程的panic，将panic的值以及panic位置的调用栈写入error日志文件并同步flush，之后协程继续正常返回
+ * 必须直接通过defer调用，例如 defer log.Recover()，在其他函数中调用时recover不生效
+ * 不受SetLevel影响；记录的级别为recover，调用位置为panic的位置，未开启WithStackTrace时同样附带调用栈
+ * 需要panic继续向上传递时在Recover之后自行处理，或者使用Panic
+ */
+func (logger *Logger) Recover() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	depth := defaultStackDepth
+	if logger.stack != nil {
+		depth = logger.stack.depth
+	}
+	// 调用栈为 Recover <- runtime.gopanic <- panic的位置，跳过日志包以及runtime的函数之后即为panic的位置
+	stack := captureStack(0, depth)
+	at := ""
+	if len(stack) > 0 {
+		at = formatCaller(stack[0].File, stack[0].Line, stack[0].Function)
+	}
+	logger.RLock()
+	loggerInfo := logger.logMap["error"]
+	logger.RUnlock()
+	if loggerInfo = logger.route("error", loggerInfo, nil); loggerInfo != nil {
+		logger.reporter.record("recover")
+		args := []interface{}{"panic recovered", fmt.Sprint(value)}
+		loggerInfo.Write(logger.encodeStack("recover", at, true, args, nil, stack))
+	}
+	logger.Flush()
+}
+
+/*
+ * 级别需要记录调用栈时获取调用栈，运行时开关优先于WithStackTrace的配置
+ * @param level：级别
+ * @return 调用栈，不需要记录时返回nil
+ */
+func (logger *Logger) stackAt(level string) []StackFrame {
+	depth, ok := logger.stackDepth(level)
+	if !ok {
+		return nil
+	}
+	return captureStack(logger.callerSkip, depth)
+}
+
+/*
+ * 获取调用栈，跳过开头的日志包以及runtime包的函数，第一层为调用日志函数的位置
+ * @param skip：在此基础上额外跳过的层数
+ * @param depth：最大层数
+ * @return 调用栈
+ */
+func captureStack(skip, depth int) []StackFrame {
+	stackPackageOnce.Do(loadStackPackage)
+	pcs := make([]uintptr, depth+skip+16)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	stack := make([]StackFrame, 0, depth)
+	leading := true
+	for len(stack) < depth {
+		frame, more := frames.Next()
+		if leading && (strings.HasPrefix(frame.Function, stackPackage) || strings.HasPrefix(frame.Function, "runtime.")) {
+			if !more {
+				break
+			}
+			continue
+		}
+		leading = false
+		if skip > 0 {
+			skip--
+		} else {
+			stack = append(stack, StackFrame{Function: frame.Function, File: trimCallerPath(frame.File), Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+/*
+ * 从本包函数的名称中获取包路径，函数名格式为 包路径.函数名
+ */
+func loadStackPackage() {
+	name := runtime.FuncForPC(reflect.ValueOf(captureStack).Pointer()).Name()
+	stackPackage = name[:strings.LastIndexByte(name, '.')+1]
+}
+
+/*
+ * 紧凑的一行格式，例如 pkg.Func(file.go:12)<-pkg.Caller(file.go:34)，函数名只保留最后一级包名，文件只保留文件名
+ */
+func appendStackCompact(buf []byte, stack []StackFrame) []byte {
+	for i, frame := range stack {
+		if i > 0 {
+			buf = append(buf, "<-"...)
+		}
+		function := frame.Function
+		if i := strings.LastIndexByte(function, '/'); i >= 0 {
+			function = function[i+1:]
+		}
+		file := frame.File
+		if i := strings.LastIndexByte(file, '/'); i >= 0 {
+			file = file[i+1:]
+		}
+		buf = append(buf, function...)
+		buf = append(buf, '(')
+		buf = append(buf, file...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(frame.Line), 10)
+		buf = append(buf, ')')
+	}
+	return buf
+}
+
+/*
+ * 与panic输出相同的多行格式，每层为 函数名\n\t文件:行号\n
+ */
+func formatStack(stack []StackFrame) string {
+	buf := make([]byte, 0, 96*len(stack))
+	for _, frame := range stack {
+		buf = append(buf, frame.Function...)
+		buf = append(buf, "\n\t"...)
+		buf = append(buf, frame.File...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(frame.Line), 10)
+		buf = append(buf, '\n')
+	}
+	return string(buf)
+}
@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"log"
+)
+
+// levelWriter is an io.Writer writing each line as a record of a level
+type levelWriter struct {
+	logger *Logger
+	level  string
+}
+
+// Writer returns an io.Writer which writes every line as a record of level
+/*
+ * 返回写入指定级别的io.Writer，用于接收第三方库的输出
+ * 每次Write的内容按照换行拆分，每行一条记录，空行忽略；记录进入正常的buffer写入流程
+ * @param level：级别名称，包括自定义级别；级别不存在时Write返回ErrUnknownLevel
+ * @return io.Writer
+ */
+func (logger *Logger) Writer(level string) io.Writer {
+	return &levelWriter{logger: logger, level: level}
+}
+
+// StdLogger returns a standard library *log.Logger writing records of level
+/*
+ * 返回写入指定级别的*log.Logger，例如 http.Server{ErrorLog: logger.StdLogger("error")}
+ * 记录自带时间，返回的log.Logger不再输出时间前缀
+ * @param level：级别名称
+ * @return *log.Logger
+ */
+func (logger *Logger) StdLogger(level string) *log.Logger {
+	return log.New(logger.Writer(level), "", 0)
+}
+
+// Write implements io.Writer
+func (w *levelWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line, p = p[:i], p[i+1:]
+		} else {
+			p = nil
+		}
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if err := w.logger.Log(w.level, string(line)); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
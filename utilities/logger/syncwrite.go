@@ -0,0 +1,95 @@
+package logger
+
+// syncRequest 同步写入请求，由FlushBufferQueue协程写入文件并fsync之后返回结果
+type syncRequest struct {
+	content []byte
+	done    chan error
+}
+
+// WithSyncLevels writes records of the given levels synchronously
+/*
+ * 指定级别的记录不经过buffer以及写入队列，调用返回时已经写入文件并fsync，适用于审计等不能丢失的日志
+ * 对Debug/Trace/Warn/Error及其*f函数、Log、WriteCtx以及slog生效，写入失败通过错误回调上报；
+ * 需要获取写入结果时使用LogSync
+ * 每条记录一次write+fsync，吞吐远低于异步写入，只用于低频的关键日志
+ * @param levels：同步写入的级别
+ */
+func WithSyncLevels(levels ...string) Option {
+	return func(logger *Logger) {
+		logger.syncLevels = make(map[string]bool, len(levels))
+		for _, level := range levels {
+			logger.syncLevels[level] = true
+		}
+	}
+}
+
+// LogSync writes a record and returns once it is on disk
+/*
+ * 同步写入一条记录，返回时记录已经写入文件并fsync，不受抽样以及重复消息合并影响
+ * @param level：级别名称，内置级别以及自定义级别均可
+ * @param args：写入的具体内容数组
+ * @return 级别不存在返回ErrUnknownLevel；已经关闭返回ErrClosed；租户超过配额返回ErrDropped；
+ *         写入或者fsync失败返回对应的error；级别被过滤时返回nil
+ */
+func (logger *Logger) LogSync(level string, args ...interface{}) error {
+	loggerInfo, enabled, err := logger.levelInfo(level)
+	if err != nil || !enabled {
+		return err
+	}
+	if loggerInfo = logger.route(level, loggerInfo, nil); loggerInfo == nil {
+		return ErrDropped
+	}
+	content := logger.encode(level, logger.callerAt(level, 1), true, args, nil)
+	if err = loggerInfo.WriteSync(content); err == nil && content != "" {
+		logger.reporter.record(level)
+	}
+	return err
+}
+
+/*
+ * 按照级别写入记录，WithSyncLevels指定的级别同步写入
+ * @param loggerInfo：写入的LoggerInfo
+ * @param level：级别
+ * @param content：编码后的日志记录
+ */
+func (logger *logCore) write(loggerInfo *LoggerInfo, level, content string) {
+	if content == "" {
+		// 被hook丢弃
+		return
+	}
+	logger.reporter.record(level)
+	if logger.syncLevels[level] {
+		loggerInfo.WriteSync(content)
+		return
+	}
+	loggerInfo.Write(content)
+}
+
+// WriteSync writes content to the log file and fsyncs before returning
+/*
+ * 同步写入：先将buffer中已有的内容放入写入队列，再由FlushBufferQueue协程写完队列之后写入content并fsync，
+ * 保证与异步写入的记录顺序一致，并且与文件切分在同一个协程中执行
+ * @param content：格式化后的日志记录
+ * @return 已经关闭返回ErrClosed；写入或者fsync失败返回对应的error
+ */
+func (logger *LoggerInfo) WriteSync(content string) error {
+	if content == "" {
+		return nil
+	}
+	logger.bufferInfoLock.RLock()
+	closed := logger.closed
+	logger.bufferInfoLock.RUnlock()
+	if closed {
+		logger.reporter.drop()
+		return ErrClosed
+	}
+	logger.enqueueBuffer()
+	req := syncRequest{content: []byte(content), done: make(chan error, 1)}
+	select {
+	case logger.syncQueue <- req:
+		return <-req.done
+	case <-logger.flusherDone:
+		logger.reporter.drop()
+		return ErrClosed
+	}
+}
@@ -0,0 +1,252 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SyslogFormat is the framing of syslog messages
+type SyslogFormat int
+
+const (
+	// SyslogRFC3164 is the traditional BSD syslog format
+	SyslogRFC3164 SyslogFormat = iota
+	// SyslogRFC5424 is the structured syslog format
+	SyslogRFC5424
+)
+
+// syslog facility
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+	FacilityLocal7 = 23
+)
+
+// syslog严重程度
+const (
+	syslogErr     = 3
+	syslogWarning = 4
+	syslogInfo    = 6
+	syslogDebug   = 7
+)
+
+// defaultReconnectInterval 连接断开之后两次重连之间的最小间隔
+const defaultReconnectInterval = time.Second
+
+// localSyslogPaths 本机syslog的unix socket路径
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// errSyslogReconnect 距离上次连接失败的时间小于重连间隔
+var errSyslogReconnect = errors.New("logger: syslog not connected, waiting to reconnect")
+
+// SyslogConfig configures a SyslogWriter
+type SyslogConfig struct {
+	Network           string        // udp/tcp/unix/unixgram，为空表示本机syslog(/dev/log等)
+	Address           string        // 地址，例如127.0.0.1:514或者socket路径，Network为空时忽略
+	Format            SyslogFormat  // 消息格式，默认RFC3164
+	Facility          int           // facility，默认FacilityUser
+	Tag               string        // 程序名，默认为进程文件名
+	Hostname          string        // 主机名，默认os.Hostname
+	ReconnectInterval time.Duration // 重连间隔，默认1秒
+}
+
+// SyslogWriter is a sink forwarding records to a syslog daemon
+/*
+ * syslog输出，每条记录作为一条syslog消息发送，严重程度根据级别映射：
+ * error及以上为err，warn为warning，trace为info，debug为debug，不带级别的记录(单文件模式)为info
+ * 使用tcp/unix流式连接时RFC5424按照RFC6587的长度前缀分帧，RFC3164使用换行分帧
+ * 写入失败时关闭连接，之后的写入在重连间隔之后自动重连，syslog重启之后可以恢复投递；未连接期间的记录丢弃并返回error
+ */
+type SyslogWriter struct {
+	mu        sync.Mutex
+	config    SyslogConfig
+	pid       string
+	conn      net.Conn
+	stream    bool      // 是否为流式连接
+	local     bool      // 是否为本机syslog
+	lastRetry time.Time // 上一次连接失败的时间
+}
+
+// NewSyslogWriter connects to the syslog daemon described by config
+/*
+ * 创建syslog输出，通过AddSink添加到Logger，例如：
+ *   w, err := NewSyslogWriter(SyslogConfig{Network: "udp", Address: "10.0.0.1:514", Format: SyslogRFC5424})
+ *   logger.AddSink("syslog", w, "warn", "error")
+ * @param config：syslog配置
+ * @return 首次连接失败时返回error
+ */
+func NewSyslogWriter(config SyslogConfig) (*SyslogWriter, error) {
+	if config.Facility <= 0 {
+		config.Facility = FacilityUser
+	}
+	if config.Tag == "" {
+		config.Tag = filepath.Base(os.Args[0])
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.ReconnectInterval <= 0 {
+		config.ReconnectInterval = defaultReconnectInterval
+	}
+	w := &SyslogWriter{config: config, pid: strconv.Itoa(os.Getpid())}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write implements io.Writer, records are sent with the info severity
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel("", 0, p)
+}
+
+// WriteLevel implements LevelWriter
+func (w *SyslogWriter) WriteLevel(level string, severity int, p []byte) (int, error) {
+	n := len(p)
+	pri := w.config.Facility*8 + syslogSeverity(severity)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		if time.Since(w.lastRetry) < w.config.ReconnectInterval {
+			return 0, errSyslogReconnect
+		}
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+	}
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line, p = p[:i], p[i+1:]
+		} else {
+			p = nil
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if _, err := w.conn.Write(w.frame(pri, line)); err != nil {
+			w.conn.Close()
+			w.conn = nil
+			w.lastRetry = time.Now()
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close closes the connection to the syslog daemon
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+/*
+ * 连接syslog，调用方需要持有锁；失败时记录时间，重连间隔之内不再尝试
+ */
+func (w *SyslogWriter) connect() error {
+	var err error
+	if w.config.Network == "" {
+		w.local = true
+		for _, path := range localSyslogPaths {
+			for _, network := range []string{"unixgram", "unix"} {
+				if w.conn, err = net.Dial(network, path); err == nil {
+					w.stream = network == "unix"
+					return nil
+				}
+			}
+		}
+	} else if w.conn, err = net.Dial(w.config.Network, w.config.Address); err == nil {
+		w.stream = w.config.Network != "udp" && w.config.Network != "udp4" && w.config.Network != "udp6" && w.config.Network != "unixgram"
+		return nil
+	}
+	w.conn = nil
+	w.lastRetry = time.Now()
+	return err
+}
+
+/*
+ * 按照配置的格式构建一条syslog消息
+ * @param pri：facility*8+severity
+ * @param msg：日志记录，不含换行符
+ */
+func (w *SyslogWriter) frame(pri int, msg []byte) []byte {
+	now := time.Now()
+	buf := make([]byte, 0, len(msg)+len(w.config.Hostname)+len(w.config.Tag)+64)
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(pri), 10)
+	buf = append(buf, '>')
+	if w.config.Format == SyslogRFC5424 {
+		buf = append(buf, "1 "...)
+		buf = now.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+		buf = append(buf, ' ')
+		buf = append(buf, syslogField(w.config.Hostname)...)
+		buf = append(buf, ' ')
+		buf = append(buf, syslogField(w.config.Tag)...)
+		buf = append(buf, ' ')
+		buf = append(buf, w.pid...)
+		buf = append(buf, " - - "...)
+	} else {
+		buf = now.AppendFormat(buf, time.Stamp)
+		buf = append(buf, ' ')
+		if !w.local {
+			// 本机syslog自行补充主机名
+			buf = append(buf, w.config.Hostname...)
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, w.config.Tag...)
+		buf = append(buf, '[')
+		buf = append(buf, w.pid...)
+		buf = append(buf, "]: "...)
+	}
+	buf = append(buf, msg...)
+	if !w.stream {
+		return buf
+	}
+	if w.config.Format == SyslogRFC5424 {
+		// RFC6587 octet counting
+		framed := strconv.AppendInt(make([]byte, 0, len(buf)+8), int64(len(buf)), 10)
+		framed = append(framed, ' ')
+		return append(framed, buf...)
+	}
+	return append(buf, '\n')
+}
+
+/*
+ * 将日志级别的严重程度映射为syslog严重程度
+ */
+func syslogSeverity(severity int) int {
+	switch {
+	case severity <= 0:
+		return syslogInfo
+	case severity >= SeverityError:
+		return syslogErr
+	case severity >= SeverityWarn:
+		return syslogWarning
+	case severity >= SeverityTrace:
+		return syslogInfo
+	}
+	return syslogDebug
+}
+
+/*
+ * RFC5424头部字段不能为空或者包含空格，为空时使用"-"
+ */
+func syslogField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return string(bytes.Replace([]byte(s), []byte{' '}, []byte{'_'}, -1))
+}
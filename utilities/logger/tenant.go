@@ -0,0 +1,429 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 租户日志的默认参数
+const (
+	defaultTenantCheckInterval = time.Minute      // 配额以及保留时间的检查间隔
+	defaultMaxTenants          = 256              // 同时打开的租户数上限
+	defaultTenantIdleTimeout   = 10 * time.Minute // 租户文件空闲多久之后关闭
+	defaultOverflowTenant      = "_overflow"      // 超过租户数上限时写入的租户目录
+)
+
+// TenancyConfig configures per-tenant log segregation
+type TenancyConfig struct {
+	Field         string        // 租户字段名，默认为tenant
+	Dir           string        // 租户日志根目录，默认为日志文件所在目录下的tenants
+	Quota         int64         // 每个租户目录的大小上限(字节)，0表示不限制
+	Retention     time.Duration // 已切分文件的保留时间，0表示不限制
+	CheckInterval time.Duration // 配额以及保留时间的检查间隔，默认1分钟
+	MaxTenants    int           // 同时打开文件的租户数上限，默认256，超过时新租户的日志写入OverflowTenant
+	IdleTimeout   time.Duration // 租户超过该时间没有写入时关闭其文件以及协程，默认10分钟，不小于CheckInterval
+	Overflow      string        // 超过租户数上限时写入的租户目录，默认_overflow，不计入MaxTenants
+}
+
+// tenancy routes records carrying a tenant field to per-tenant files
+type tenancy struct {
+	config   TenancyConfig
+	mu       sync.Mutex
+	blocked  map[string]bool         // 超过配额的租户，其日志会被丢弃
+	open     map[string]*tenantFiles // 已经打开文件的租户
+	overflow bool                    // 是否已经上报过超过租户数上限，有租户关闭之后重新上报
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// tenantFiles 一个租户已经打开的LoggerInfo
+type tenantFiles struct {
+	used  time.Time     // 最近一次写入的时间
+	infos []*LoggerInfo // 单文件模式下只有一个
+}
+
+// ErrTooManyTenants is reported when the tenant limit is reached and records go to the overflow tenant
+var ErrTooManyTenants = errors.New("logger: too many tenants, records written to the overflow tenant")
+
+// WithTenancy routes records carrying a tenant field to per-tenant files
+/*
+ * 开启租户日志隔离，携带租户字段(WithFields或者WriteCtx的fields)的记录写入
+ * Dir/<租户>/<文件名>-<级别>.log，备份到Dir/<租户>/backup，不再写入公共日志文件
+ * 后台协程定期删除超过保留时间的已切分文件；租户目录超过配额时从最旧的已切分文件开始删除，
+ * 仍然超过配额时丢弃该租户的日志，直到占用降到配额以下
+ * 每个租户的每个级别都会打开文件并启动写入协程，租户字段的取值很多时(例如来自请求头)：
+ * 超过IdleTimeout没有写入的租户在检查时关闭；同时打开的租户达到MaxTenants之后，新租户的日志写入Overflow目录，
+ * 记录中仍然包含租户字段，并通过错误回调上报ErrTooManyTenants
+ * @param config：租户配置
+ */
+func WithTenancy(config TenancyConfig) Option {
+	return func(logger *Logger) {
+		if config.Field == "" {
+			config.Field = "tenant"
+		}
+		if config.Dir == "" {
+			config.Dir = filepath.Join(filepath.Dir(logger.filename), "tenants")
+		}
+		if config.CheckInterval <= 0 {
+			config.CheckInterval = defaultTenantCheckInterval
+		}
+		if config.MaxTenants <= 0 {
+			config.MaxTenants = defaultMaxTenants
+		}
+		if config.IdleTimeout <= 0 {
+			config.IdleTimeout = defaultTenantIdleTimeout
+		}
+		if config.IdleTimeout < config.CheckInterval {
+			config.IdleTimeout = config.CheckInterval
+		}
+		if config.Overflow = tenantName(config.Overflow); config.Overflow == "" {
+			config.Overflow = defaultOverflowTenant
+		}
+		logger.tenancy = &tenancy{
+			config:  config,
+			blocked: make(map[string]bool),
+			open:    make(map[string]*tenantFiles),
+			stop:    make(chan struct{}),
+		}
+	}
+}
+
+/*
+ * 根据记录携带的租户字段选择写入的LoggerInfo
+ * @param level：日志级别
+ * @param loggerInfo：公共日志文件
+ * @param fields：本条记录的附加字段，可以为nil
+ * @return 写入的LoggerInfo，租户超过配额或者文件创建失败时返回nil
+ */
+func (logger *Logger) route(level string, loggerInfo *LoggerInfo, fields Fields) *LoggerInfo {
+	if logger.tenancy == nil {
+		return loggerInfo
+	}
+	value, ok := fields[logger.tenancy.config.Field]
+	if !ok {
+		if value, ok = logger.fields[logger.tenancy.config.Field]; !ok {
+			return loggerInfo
+		}
+	}
+	tenant := tenantName(value)
+	if tenant == "" {
+		return loggerInfo
+	}
+	tenant, ok, overflow := logger.tenancy.admit(tenant)
+	if overflow {
+		logger.reporter.report("route.MaxTenants", ErrTooManyTenants)
+	}
+	if !ok {
+		logger.reporter.drop()
+		return nil
+	}
+
+	dir := filepath.Join(logger.tenancy.dir(), tenant)
+	filename := filepath.Join(dir, filepath.Base(logger.filename))
+	key := logger.levelFile(filename, level).filename
+	if logger.singleFile {
+		// 单文件模式下租户的所有级别写入同一个文件
+		key = filename + ".log"
+	}
+	logger.RLock()
+	tenantInfo := logger.logMap[key]
+	logger.RUnlock()
+	if tenantInfo != nil {
+		return tenantInfo
+	}
+
+	logger.Lock()
+	defer logger.Unlock()
+	if tenantInfo = logger.logMap[key]; tenantInfo != nil {
+		return tenantInfo
+	}
+	if err := mkdirAll(dir, logger.logDirMode(), logger.owner); err != nil {
+		logger.reporter.report("route.MkdirAll", err)
+		logger.reporter.drop()
+		return nil
+	}
+	var err error
+	if logger.singleFile {
+		tenantInfo, err = logger.startCombinedInfo(key, filepath.Join(dir, "backup"))
+	} else {
+		tenantInfo, err = logger.startLoggerInfo(filename, level, filepath.Join(dir, "backup"))
+	}
+	if err != nil {
+		logger.reporter.report("route.NewLoggerInfo", err)
+		logger.reporter.drop()
+		return nil
+	}
+	logger.logMap[key] = tenantInfo
+	logger.tenancy.track(tenant, tenantInfo)
+	return tenantInfo
+}
+
+/*
+ * 将租户字段的值转换为目录名，只保留字母、数字以及._-，其余字符替换为_
+ * @return 目录名，值为空或者为./..时返回空
+ */
+func tenantName(value interface{}) string {
+	name := []byte(string(appendArg(nil, value)))
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			name[i] = '_'
+		}
+	}
+	if s := string(name); s != "." && s != ".." {
+		return s
+	}
+	return ""
+}
+
+/*
+ * 获取租户日志根目录，Relocate之后会发生变化
+ */
+func (t *tenancy) dir() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.config.Dir
+}
+
+func (t *tenancy) setDir(dir string) {
+	t.mu.Lock()
+	t.config.Dir = dir
+	t.mu.Unlock()
+}
+
+/*
+ * 记录租户的写入时间，达到租户数上限时改为写入溢出租户
+ * @param tenant：租户名
+ * @return (实际写入的租户, 是否写入, 是否需要上报ErrTooManyTenants)，租户超过配额时不写入
+ */
+func (t *tenancy) admit(tenant string) (string, bool, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	overflow := false
+	if t.open[tenant] == nil && tenant != t.config.Overflow && t.openCount() >= t.config.MaxTenants {
+		tenant = t.config.Overflow
+		overflow, t.overflow = !t.overflow, true
+	}
+	if t.blocked[tenant] {
+		return tenant, false, overflow
+	}
+	files := t.open[tenant]
+	if files == nil {
+		files = &tenantFiles{}
+		t.open[tenant] = files
+	}
+	files.used = time.Now()
+	return tenant, true, overflow
+}
+
+/*
+ * 获取已经打开的租户数，不包括溢出租户；调用方需要持有锁
+ */
+func (t *tenancy) openCount() int {
+	if t.open[t.config.Overflow] != nil {
+		return len(t.open) - 1
+	}
+	return len(t.open)
+}
+
+/*
+ * 记录租户新打开的LoggerInfo，关闭租户时一起关闭
+ */
+func (t *tenancy) track(tenant string, loggerInfo *LoggerInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	files := t.open[tenant]
+	if files == nil {
+		files = &tenantFiles{used: time.Now()}
+		t.open[tenant] = files
+	}
+	files.infos = append(files.infos, loggerInfo)
+}
+
+/*
+ * 关闭超过IdleTimeout没有写入的租户，关闭的文件从logMap中移除，之后再次写入时重新打开
+ * 先持有Logger的锁再持有租户的锁，与route的加锁顺序一致，移除之后route不会再获取到已经关闭的LoggerInfo
+ * @return 关闭的租户数
+ */
+func (logger *Logger) closeIdleTenants() int {
+	t := logger.tenancy
+	deadline := time.Now().Add(-t.config.IdleTimeout)
+	closing := make(map[*LoggerInfo]bool)
+	logger.Lock()
+	t.mu.Lock()
+	closed := 0
+	for tenant, files := range t.open {
+		if files.used.After(deadline) {
+			continue
+		}
+		for _, loggerInfo := range files.infos {
+			closing[loggerInfo] = true
+		}
+		delete(t.open, tenant)
+		closed++
+	}
+	if closed > 0 {
+		t.overflow = false
+	}
+	t.mu.Unlock()
+	for key, loggerInfo := range logger.logMap {
+		if closing[loggerInfo] {
+			delete(logger.logMap, key)
+		}
+	}
+	logger.Unlock()
+
+	// Close会等待剩余数据写入文件，不能持有锁
+	for loggerInfo := range closing {
+		loggerInfo.Close()
+	}
+	return closed
+}
+
+/*
+ * 定期检查所有租户目录的保留时间以及配额，Close时退出
+ * @param logger：用于输出租户超过配额的警告
+ */
+func (t *tenancy) run(logger *Logger) {
+	ticker := time.NewTicker(t.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			logger.closeIdleTenants()
+			t.check(logger)
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+func (t *tenancy) close() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+}
+
+/*
+ * 检查所有租户目录
+ */
+func (t *tenancy) check(logger *Logger) {
+	root := t.dir()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	isActive := logger.activeFiles()
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tenant := entry.Name()
+		usage := t.cleanup(filepath.Join(root, tenant), isActive)
+		over := t.config.Quota > 0 && usage > t.config.Quota
+
+		t.mu.Lock()
+		changed := t.blocked[tenant] != over
+		if over {
+			t.blocked[tenant] = true
+		} else {
+			delete(t.blocked, tenant)
+		}
+		t.mu.Unlock()
+		if changed && over {
+			logger.Warn("tenant_quota_exceeded", tenant, usage, t.config.Quota)
+		} else if changed {
+			logger.Warn("tenant_quota_recovered", tenant, usage, t.config.Quota)
+		}
+	}
+}
+
+// tenantFile 租户目录下的日志文件
+type tenantFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+/*
+ * 删除超过保留时间的已切分文件，超过配额时从最旧的已切分文件开始删除
+ * 正在写入的文件不会被删除
+ * @param dir：租户目录
+ * @param isActive：判断是否为正在写入的文件
+ * @return 清理之后的目录大小
+ */
+func (t *tenancy) cleanup(dir string, isActive func(path string) bool) int64 {
+	var usage int64
+	var rotated []tenantFile
+	now := time.Now()
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if isActive(path) {
+			usage += info.Size()
+			return nil
+		}
+		if t.config.Retention > 0 && now.Sub(info.ModTime()) > t.config.Retention {
+			if os.Remove(path) == nil {
+				return nil
+			}
+		}
+		usage += info.Size()
+		rotated = append(rotated, tenantFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+
+	if t.config.Quota <= 0 || usage <= t.config.Quota {
+		return usage
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].modTime.Before(rotated[j].modTime)
+	})
+	for _, file := range rotated {
+		if usage <= t.config.Quota {
+			break
+		}
+		if os.Remove(file.path) == nil {
+			usage -= file.size
+		}
+	}
+	return usage
+}
+
+/*
+ * 获取判断正在写入的文件的函数
+ * 已经打开的日志文件都是正在写入的文件；进程重启之后尚未重新打开的租户文件按照文件名判断：
+ * 默认命名时为租户目录第一层以.log结尾的文件，设置文件名模板时为符合模板的文件
+ */
+func (logger *Logger) activeFiles() func(path string) bool {
+	logger.RLock()
+	active := make(map[string]bool, len(logger.logMap))
+	for _, loggerInfo := range logger.logMap {
+		active[loggerInfo.filename] = true
+	}
+	logger.RUnlock()
+	root := logger.tenancy.dir()
+	var matcher *regexp.Regexp
+	if logger.nameTemplate != "" {
+		// 租户名以及级别名替换为任意的单层目录名
+		const tenantMark, levelMark = "\x00tenant", "\x00level"
+		name := logger.levelFile(filepath.Join(root, tenantMark, filepath.Base(logger.filename)), levelMark).filename
+		pattern := strings.NewReplacer(tenantMark, `[^/\\]+`, levelMark, `[^/\\]+`).Replace(regexp.QuoteMeta(name))
+		matcher = regexp.MustCompile("^" + pattern + "$")
+	}
+	return func(path string) bool {
+		if active[path] {
+			return true
+		}
+		if matcher != nil {
+			return matcher.MatchString(path)
+		}
+		return filepath.Dir(filepath.Dir(path)) == root && strings.HasSuffix(path, ".log")
+	}
+}
@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+ * 创建开启租户隔离的Logger，错误回调收集到返回的列表中
+ */
+func newTenantLogger(t *testing.T, config TenancyConfig) (*Logger, string, func() []error) {
+	t.Helper()
+	dir := t.TempDir()
+	log, err := NewLogger(filepath.Join(dir, "app"), "", filepath.Join(dir, "backup"), WithTenancy(config))
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	var mu sync.Mutex
+	var errs []error
+	log.SetErrorHandler(func(op string, err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+	return log, dir, func() []error {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]error(nil), errs...)
+	}
+}
+
+func readTenantFile(t *testing.T, dir, tenant string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "tenants", tenant, "app-debug.log"))
+	if err != nil {
+		t.Fatalf("read tenant %s: %v", tenant, err)
+	}
+	return string(data)
+}
+
+func TestTenantLimitOverflow(t *testing.T) {
+	log, dir, errs := newTenantLogger(t, TenancyConfig{MaxTenants: 2, CheckInterval: time.Hour})
+	defer log.Close()
+
+	for _, tenant := range []string{"a", "b", "c", "d", "a"} {
+		log.WithFields(Fields{"tenant": tenant}).Debug("hello", tenant)
+	}
+	log.Flush()
+
+	if got := readTenantFile(t, dir, "a"); strings.Count(got, "hello") != 2 {
+		t.Fatalf("tenant a: want 2 records, got %q", got)
+	}
+	overflow := readTenantFile(t, dir, defaultOverflowTenant)
+	for _, tenant := range []string{"c", "d"} {
+		if !strings.Contains(overflow, "hello|"+tenant) {
+			t.Errorf("overflow tenant missing record of %s: %q", tenant, overflow)
+		}
+	}
+	for _, tenant := range []string{"c", "d"} {
+		if _, err := os.Stat(filepath.Join(dir, "tenants", tenant)); !os.IsNotExist(err) {
+			t.Errorf("tenant %s over the limit got its own directory", tenant)
+		}
+	}
+
+	reported := 0
+	for _, err := range errs() {
+		if errors.Is(err, ErrTooManyTenants) {
+			reported++
+		}
+	}
+	if reported != 1 {
+		t.Errorf("ErrTooManyTenants reported %d times, want 1", reported)
+	}
+}
+
+func TestTenantIdleEviction(t *testing.T) {
+	log, dir, _ := newTenantLogger(t, TenancyConfig{MaxTenants: 1, CheckInterval: time.Hour})
+	defer log.Close()
+
+	log.WithFields(Fields{"tenant": "a"}).Debug("first")
+	log.RLock()
+	var opened *LoggerInfo
+	for _, loggerInfo := range log.logMap {
+		if strings.Contains(loggerInfo.filename, filepath.Join("tenants", "a")) {
+			opened = loggerInfo
+		}
+	}
+	log.RUnlock()
+	if opened == nil {
+		t.Fatal("tenant a was not opened")
+	}
+
+	// 没有超过IdleTimeout的租户不会被关闭
+	if closed := log.closeIdleTenants(); closed != 0 {
+		t.Fatalf("closed %d active tenants", closed)
+	}
+	log.tenancy.mu.Lock()
+	log.tenancy.open["a"].used = time.Now().Add(-2 * time.Hour)
+	log.tenancy.mu.Unlock()
+	if closed := log.closeIdleTenants(); closed != 1 {
+		t.Fatalf("closed %d idle tenants, want 1", closed)
+	}
+	select {
+	case <-opened.flusherDone:
+	default:
+		t.Fatal("idle tenant file was not closed")
+	}
+	log.RLock()
+	for _, loggerInfo := range log.logMap {
+		if loggerInfo == opened {
+			t.Error("closed tenant file still in logMap")
+		}
+	}
+	log.RUnlock()
+
+	// 关闭之后腾出名额，新租户不再写入溢出租户；原租户再次写入时重新打开并追加
+	log.WithFields(Fields{"tenant": "b"}).Debug("second")
+	log.tenancy.mu.Lock()
+	log.tenancy.open["b"].used = time.Now().Add(-2 * time.Hour)
+	log.tenancy.mu.Unlock()
+	log.closeIdleTenants()
+	log.WithFields(Fields{"tenant": "a"}).Debug("third")
+	log.Flush()
+
+	if got := readTenantFile(t, dir, "a"); !strings.Contains(got, "first") || !strings.Contains(got, "third") {
+		t.Errorf("tenant a lost records across reopen: %q", got)
+	}
+	if got := readTenantFile(t, dir, "b"); !strings.Contains(got, "second") {
+		t.Errorf("tenant b: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tenants", defaultOverflowTenant)); !os.IsNotExist(err) {
+		t.Error("records went to the overflow tenant although idle tenants were closed")
+	}
+	if dropped := log.Stats().Dropped; dropped != 0 {
+		t.Errorf("dropped %d records", dropped)
+	}
+}
+
+func TestTenantHighCardinalityBounded(t *testing.T) {
+	log, _, _ := newTenantLogger(t, TenancyConfig{MaxTenants: 4, CheckInterval: time.Hour})
+	defer log.Close()
+
+	log.WithFields(Fields{"tenant": "warmup"}).Debug("x")
+	base := runtime.NumGoroutine()
+	for i := 0; i < 500; i++ {
+		log.WithFields(Fields{"tenant": fmt.Sprintf("req-%d", i)}).Debug("x")
+	}
+
+	log.tenancy.mu.Lock()
+	open := len(log.tenancy.open)
+	log.tenancy.mu.Unlock()
+	if open > 5 {
+		t.Errorf("%d tenants open, want at most MaxTenants plus the overflow tenant", open)
+	}
+	// 每个租户文件有固定数量的协程，500个租户不受限制时会多出上千个
+	if grown := runtime.NumGoroutine() - base; grown > 100 {
+		t.Errorf("goroutines grew by %d for 500 tenants", grown)
+	}
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"context"
+	"os"
+)
+
+// Throttle paces background work such as compression, uploads and retention cleanup
+/*
+ * 后台任务的节流接口，例如process.Throttler：进程繁忙时暂停压缩、上传以及备份清理，避免与业务争抢CPU以及磁盘
+ * Wait在每个任务开始之前调用，只会推迟任务，返回error时任务照常执行
+ */
+type Throttle interface {
+	// Wait blocks until a background task of size bytes may start, or ctx is done
+	Wait(ctx context.Context, size int64) error
+}
+
+// WithThrottle paces compression, uploads and retention cleanup through throttle
+/*
+ * 开启后台任务节流，以下任务开始之前调用throttle.Wait：
+ *   切分文件以及备份文件的压缩、备份文件的上传、WithRetention的定期清理
+ * 磁盘空间不足时的紧急清理不受影响；Close时不再等待，剩余任务立即执行
+ * 归档队列积压超过一半时压缩不再等待，避免阻塞写入协程
+ * @param throttle：节流对象，为nil时不开启
+ */
+func WithThrottle(throttle Throttle) Option {
+	return func(logger *Logger) {
+		logger.throttle = throttle
+	}
+}
+
+/*
+ * 等待节流，stop关闭时立即返回
+ * @param throttle：节流对象，为nil时直接返回
+ * @param stop：停止信号，例如LoggerInfo关闭
+ * @param size：任务处理的字节数
+ */
+func waitThrottle(throttle Throttle, stop <-chan struct{}, size int64) {
+	if throttle == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	throttle.Wait(ctx, size)
+}
+
+/*
+ * 获取文件大小，用于节流，失败时为0
+ */
+func fileSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
+}
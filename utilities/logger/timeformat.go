@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 特殊的时间格式，其余取值按照time.Time.Format的layout处理
+const (
+	// TimeEpochMillis writes the timestamp as milliseconds since the unix epoch
+	TimeEpochMillis = "epoch_millis"
+	// TimeNone omits the timestamp, for collectors that add their own
+	TimeNone = "none"
+)
+
+// WithTimeFormat sets the layout of record timestamps
+/*
+ * 设置日志记录中的时间格式，例如time.RFC3339Nano、TimeEpochMillis(毫秒时间戳)或者TimeNone(不输出时间)
+ * 默认文本格式为"2006-01-02 15:04:05.000"，JSON格式为带时区的ISO8601
+ * 只影响记录的时间，不影响文件切分以及备份目录的命名
+ * @param layout：时间格式，为空时使用编码器的默认格式
+ */
+func WithTimeFormat(layout string) Option {
+	return func(logger *Logger) {
+		logger.timeLayout = layout
+	}
+}
+
+// WithUTC writes record timestamps in UTC instead of local time
+/*
+ * 日志记录中的时间使用UTC，默认使用本地时间
+ * 只影响记录的时间，文件切分仍然按照本地时间进行
+ */
+func WithUTC() Option {
+	return func(logger *Logger) {
+		logger.utc = true
+	}
+}
+
+/*
+ * 获取记录的时间，WithUTC时转换为UTC
+ */
+func (logger *logCore) now() time.Time {
+	if logger.utc {
+		return time.Now().UTC()
+	}
+	return time.Now()
+}
+
+/*
+ * 按照记录的时间格式追加时间
+ * @param buf：输出
+ * @param entry：日志记录
+ * @param layout：记录没有指定时间格式时使用的默认格式
+ * @param quote：是否在时间两侧加双引号，毫秒时间戳不加
+ * @return (追加之后的buf, 是否输出了时间)
+ */
+func appendEntryTime(buf []byte, entry *Entry, layout string, quote bool) ([]byte, bool) {
+	if entry.TimeLayout != "" {
+		layout = entry.TimeLayout
+	}
+	switch layout {
+	case TimeNone:
+		return buf, false
+	case TimeEpochMillis:
+		return strconv.AppendInt(buf, entry.Time.UnixMilli(), 10), true
+	}
+	if quote {
+		buf = append(buf, '"')
+	}
+	if layout == datetimeFormat {
+		buf = appendDatetime(buf, entry.Time)
+	} else {
+		buf = entry.Time.AppendFormat(buf, layout)
+	}
+	if quote {
+		buf = append(buf, '"')
+	}
+	return buf, true
+}
+
+// datetimeSecond 默认文本时间格式中精确到秒的部分，同一秒内的记录复用
+type datetimeSecond struct {
+	unix   int64
+	loc    *time.Location
+	prefix []byte // 2006-01-02 15:04:05.
+}
+
+// cachedSecond 最近一次格式化的秒
+var cachedSecond atomic.Value // *datetimeSecond
+
+/*
+ * 按照datetimeFormat追加时间，同一秒内只格式化一次，之后只追加毫秒
+ */
+func appendDatetime(buf []byte, t time.Time) []byte {
+	unix := t.Unix()
+	cached, _ := cachedSecond.Load().(*datetimeSecond)
+	if cached == nil || cached.unix != unix || cached.loc != t.Location() {
+		cached = &datetimeSecond{unix: unix, loc: t.Location(), prefix: t.AppendFormat(nil, "2006-01-02 15:04:05.")}
+		cachedSecond.Store(cached)
+	}
+	buf = append(buf, cached.prefix...)
+	ms := t.Nanosecond() / int(time.Millisecond)
+	return append(buf, byte('0'+ms/100), byte('0'+ms/10%10), byte('0'+ms%10))
+}
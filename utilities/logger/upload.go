@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 备份上传默认参数
+const (
+	defaultUploadQueue      = 1024
+	defaultUploadAttempts   = 5
+	defaultUploadMinBackoff = time.Second
+	defaultUploadMaxBackoff = time.Minute
+	defaultUploadTimeout    = 10 * time.Minute
+)
+
+// Uploader stores a backed-up log file in remote storage
+/*
+ * 备份文件上传接口，内置S3Uploader，其他存储(HDFS、OSS SDK等)由调用方实现
+ * Upload返回nil表示远端已经完整保存该文件，实现需要自行校验内容(例如校验和)
+ * Upload只会在上传协程中串行调用，ctx在单次上传超时或者Logger.Close放弃等待时取消
+ */
+type Uploader interface {
+	Upload(ctx context.Context, key, path string) error
+}
+
+// UploadConfig configures the upload of backups, see WithBackupUpload
+type UploadConfig struct {
+	Uploader     Uploader
+	Prefix       string        // 对象key的前缀，例如 "logs/app"，key为 前缀/日期/文件名
+	DeleteLocal  bool          // 上传成功之后删除本地的备份文件
+	MaxAttempts  int           // 每个文件最多尝试次数，默认5
+	MinBackoff   time.Duration // 重试的初始等待时间，默认1s，之后每次失败翻倍
+	MaxBackoff   time.Duration // 重试的最长等待时间，默认1min
+	Timeout      time.Duration // 单次上传的超时时间，默认10min
+	QueueSize    int           // 等待上传的文件数，默认1024，队列满时文件只保留在本地
+	DrainTimeout time.Duration // Close时等待剩余文件上传的最长时间，0表示一直等待
+
+	// OnUpload 每个文件上传结束时调用，err为最后一次尝试的错误，可以用于通知采集程序
+	OnUpload func(path, key string, err error)
+}
+
+// uploadTask 等待上传的备份文件
+type uploadTask struct {
+	path string
+	key  string
+}
+
+// backupUploader 备份文件的上传协程
+type backupUploader struct {
+	config   UploadConfig
+	reporter *reporter
+	lock     sync.Mutex
+	queue    chan uploadTask
+	closed   bool // 受lock保护，关闭之后不再接收新的文件
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	throttle Throttle // 上传之前的节流，nil表示不开启
+}
+
+// WithBackupUpload uploads every file moved into the backup directory
+/*
+ * 开启备份上传，NewLogger的backupDir不为空时生效：
+ * 切分文件移动到 backupDir/日期 之后(CompressOnBackup时为压缩之后)加入上传队列，
+ * 由独立协程按照顺序上传，失败时按照指数退避重试，最终失败时通过错误回调上报，文件保留在本地
+ * 开启DeleteLocal时上传成功之后删除本地文件，之后RetentionPolicy不再统计该文件
+ * Close时在关闭所有日志文件之后等待队列中剩余的文件上传，参考UploadConfig.DrainTimeout
+ * @param config：上传配置，Uploader不能为nil
+ */
+func WithBackupUpload(config UploadConfig) Option {
+	return func(logger *Logger) {
+		if config.Uploader == nil {
+			logger.initErr = errors.New("logger: backup upload requires an Uploader")
+			return
+		}
+		if logger.backupDir != "" {
+			logger.uploader = newBackupUploader(config, logger.reporter)
+		}
+	}
+}
+
+/*
+ * 创建上传对象并启动上传协程
+ */
+func newBackupUploader(config UploadConfig, r *reporter) *backupUploader {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultUploadAttempts
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultUploadMinBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = defaultUploadMaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultUploadTimeout
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultUploadQueue
+	}
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &backupUploader{
+		config:   config,
+		reporter: r,
+		queue:    make(chan uploadTask, config.QueueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go u.run()
+	return u
+}
+
+/*
+ * 将备份文件加入上传队列，队列满或者已经关闭时上报错误，文件保留在本地
+ * @param path：备份文件路径
+ * @param key：相对于备份目录的路径，例如 2014-09-10/saver-error.log.2014091010
+ */
+func (u *backupUploader) enqueue(path, key string) {
+	if u.config.Prefix != "" {
+		key = u.config.Prefix + "/" + key
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.closed {
+		u.reporter.report("Upload.Enqueue", fmt.Errorf("upload %s: %w", path, ErrClosed))
+		return
+	}
+	select {
+	case u.queue <- uploadTask{path: path, key: key}:
+	default:
+		u.reporter.report("Upload.Enqueue", fmt.Errorf("upload %s: queue full", path))
+	}
+}
+
+/*
+ * 上传协程：按照顺序上传队列中的文件，队列关闭之后退出
+ */
+func (u *backupUploader) run() {
+	defer close(u.done)
+	for task := range u.queue {
+		if u.ctx.Err() != nil {
+			// Close放弃等待，剩余的文件保留在本地
+			continue
+		}
+		if u.throttle != nil {
+			u.throttle.Wait(u.ctx, fileSize(task.path))
+		}
+		u.upload(task)
+	}
+}
+
+/*
+ * 上传一个文件，失败时按照指数退避重试，成功时按照配置删除本地文件
+ */
+func (u *backupUploader) upload(task uploadTask) {
+	var err error
+	backoff := u.config.MinBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(u.ctx, u.config.Timeout)
+		err = u.config.Uploader.Upload(ctx, task.key, task.path)
+		cancel()
+		if err == nil || attempt >= u.config.MaxAttempts || !u.wait(backoff) {
+			break
+		}
+		if backoff *= 2; backoff > u.config.MaxBackoff {
+			backoff = u.config.MaxBackoff
+		}
+	}
+	if err != nil {
+		u.reporter.report("Upload", fmt.Errorf("upload %s: %w", task.path, err))
+	} else if u.config.DeleteLocal {
+		if removeErr := os.Remove(task.path); removeErr != nil {
+			u.reporter.report("Upload.Remove", removeErr)
+		}
+	}
+	if u.config.OnUpload != nil {
+		u.config.OnUpload(task.path, task.key, err)
+	}
+}
+
+/*
+ * 等待重试间隔
+ * @return Close放弃等待时返回false
+ */
+func (u *backupUploader) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-u.ctx.Done():
+		return false
+	}
+}
+
+/*
+ * 停止接收新的文件并等待队列中的文件上传，超过DrainTimeout时取消正在进行的上传
+ */
+func (u *backupUploader) close() {
+	u.lock.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.queue)
+	}
+	u.lock.Unlock()
+	if u.config.DrainTimeout > 0 {
+		timer := time.NewTimer(u.config.DrainTimeout)
+		select {
+		case <-u.done:
+		case <-timer.C:
+			u.cancel()
+		}
+		timer.Stop()
+	}
+	<-u.done
+	u.cancel()
+}
@@ -0,0 +1,393 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 预写日志默认参数
+const (
+	defaultWALInterval = time.Second
+	walSuffix          = ".wal"
+	walHeaderSize      = 8       // 记录长度以及CRC32各4字节
+	maxWALRecord       = 64 * MB // 单条记录的最大长度，读取时超过认为段尾损坏
+)
+
+// ErrWALUnavailable is returned by WriteAck when the channel file cannot be created
+var ErrWALUnavailable = errors.New("logger: channel file unavailable")
+
+// WALConfig configures the write-ahead journal of channels, see WithWAL
+type WALConfig struct {
+	Dir                string        // 预写日志目录，默认为日志文件所在目录下的wal
+	Channels           []string      // 开启预写日志的通道名称
+	CheckpointInterval time.Duration // 确认通道文件写入并删除预写日志的间隔，默认1s
+}
+
+// walSet 所有开启预写日志的通道
+type walSet struct {
+	config WALConfig
+	wals   map[string]*wal // 通道名称 -> 预写日志
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// wal 一个通道的预写日志，由多个段文件组成：<dir>/<通道名>.<段序号>.wal
+type wal struct {
+	lock     sync.Mutex
+	dir      string
+	name     string
+	reporter *reporter
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	opened   bool     // 已经扫描过上次运行残留的段
+	leftover []string // 上次运行残留的段，等待ReplayWAL
+	segment  int      // 当前段序号
+	file     *os.File // 当前段，没有记录时为nil
+	failures uint64   // 当前段创建时的写入失败以及丢弃buffer计数
+}
+
+// WithWAL journals the records of the given channels before they are acknowledged
+/*
+ * 开启通道的预写日志，用于计费等要求进程崩溃也不能丢失的记录(至少一次)：
+ *   通道的每条记录先追加到预写日志并fsync，再进入通道文件的写入队列；WriteAck在fsync之后返回
+ *   后台每隔CheckpointInterval切换到新的段，将通道文件flush并fsync之后删除之前的段；
+ *   期间有日志写入失败或者丢弃的buffer时保留这些段，下次启动时重放
+ *   启动时调用Channel.ReplayWAL将上次运行残留的段重新写入通道文件，进程在flush之前崩溃时记录可能重复
+ * 每条记录一次write+fsync，只用于低频的关键通道；通道文件使用WithOverflowPolicy的丢弃策略时记录仍然保存在预写日志中
+ * @param config：预写日志配置
+ */
+func WithWAL(config WALConfig) Option {
+	return func(logger *Logger) {
+		if config.Dir == "" {
+			config.Dir = filepath.Join(filepath.Dir(logger.filename), "wal")
+		}
+		if config.CheckpointInterval <= 0 {
+			config.CheckpointInterval = defaultWALInterval
+		}
+		set := &walSet{
+			config: config,
+			wals:   make(map[string]*wal, len(config.Channels)),
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		for _, name := range config.Channels {
+			if name == "" || strings.ContainsAny(name, `/\`) {
+				logger.initErr = fmt.Errorf("logger: invalid wal channel name %q", name)
+				return
+			}
+			set.wals[name] = &wal{dir: config.Dir, name: name, reporter: logger.reporter}
+		}
+		logger.wals = set
+	}
+}
+
+// WriteAck writes a record to the channel and returns once it is durable
+/*
+ * 写入一条记录，返回时记录已经持久化：开启WithWAL的通道写入预写日志并fsync，其他通道同步写入通道文件并fsync
+ * @param args：写入的内容
+ * @return 创建通道文件失败返回ErrWALUnavailable；已经关闭返回ErrClosed；写入或者fsync失败返回对应的error
+ */
+func (c *Channel) WriteAck(args ...interface{}) error {
+	loggerInfo := c.info()
+	if loggerInfo == nil {
+		return ErrWALUnavailable
+	}
+	content := c.logger.encode("", "", false, args, nil)
+	if content == "" {
+		return nil
+	}
+	if w := c.logger.wal(c.name); w != nil {
+		return w.append(content, loggerInfo)
+	}
+	return loggerInfo.WriteSync(content)
+}
+
+// ReplayWAL writes the records left in the journal by a previous run to the channel file
+/*
+ * 将上次运行残留的预写日志重新写入通道文件，一般在启动之后、开始处理请求之前调用一次
+ * 通道文件flush成功之后删除残留的段；损坏的段尾(写入过程中崩溃)被忽略
+ * @return (重放的记录数, error)，通道没有开启WithWAL时返回(0, nil)
+ */
+func (c *Channel) ReplayWAL() (int, error) {
+	w := c.logger.wal(c.name)
+	if w == nil {
+		return 0, nil
+	}
+	loggerInfo := c.info()
+	if loggerInfo == nil {
+		return 0, ErrWALUnavailable
+	}
+	w.lock.Lock()
+	if err := w.open(); err != nil {
+		w.lock.Unlock()
+		return 0, err
+	}
+	segments := w.leftover
+	w.leftover = nil
+	w.lock.Unlock()
+
+	failures := w.reporter.failures()
+	count := 0
+	for _, path := range segments {
+		n, err := readWALSegment(path, func(record []byte) {
+			loggerInfo.Write(string(record))
+		})
+		count += n
+		if err != nil {
+			w.reporter.report("ReplayWAL.Read", err)
+		}
+	}
+	loggerInfo.Flush()
+	if w.reporter.failures() != failures {
+		return count, fmt.Errorf("logger: replay of channel %s not confirmed, journal kept", c.name)
+	}
+	for _, path := range segments {
+		if err := os.Remove(path); err != nil {
+			w.reporter.report("ReplayWAL.Remove", err)
+		}
+	}
+	return count, nil
+}
+
+/*
+ * 获取通道的预写日志，没有开启时返回nil
+ */
+func (logger *logCore) wal(name string) *wal {
+	if logger.wals == nil {
+		return nil
+	}
+	return logger.wals.wals[name]
+}
+
+/*
+ * 写入通道记录，开启预写日志时先写入预写日志
+ * @param name：通道名称
+ * @param loggerInfo：通道文件
+ * @param content：编码后的记录
+ */
+func (logger *logCore) writeChannel(name string, loggerInfo *LoggerInfo, content string) {
+	if w := logger.wal(name); w != nil {
+		if err := w.append(content, loggerInfo); err != nil {
+			w.reporter.report("WAL.Append", err)
+		}
+		return
+	}
+	loggerInfo.Write(content)
+}
+
+/*
+ * 扫描上次运行残留的段，当前段从最大序号之后开始，需要持有锁
+ */
+func (w *wal) open() error {
+	if w.opened {
+		return nil
+	}
+	if err := os.MkdirAll(w.dir, w.dirMode); err != nil {
+		return err
+	}
+	matches, _ := filepath.Glob(filepath.Join(w.dir, w.name+".*"+walSuffix))
+	type segment struct {
+		path string
+		seq  int
+	}
+	segments := make([]segment, 0, len(matches))
+	for _, match := range matches {
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), w.name+"."), walSuffix))
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: match, seq: seq})
+		if seq > w.segment {
+			w.segment = seq
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].seq < segments[j].seq
+	})
+	for _, s := range segments {
+		w.leftover = append(w.leftover, s.path)
+	}
+	w.segment++
+	w.opened = true
+	return nil
+}
+
+/*
+ * 段文件路径
+ */
+func (w *wal) path(segment int) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s.%010d%s", w.name, segment, walSuffix))
+}
+
+/*
+ * 追加一条记录并fsync，然后写入通道文件
+ * 持有锁直到写入通道文件，保证checkpoint切换段时之前的记录都已经进入通道文件的buffer
+ */
+func (w *wal) append(content string, loggerInfo *LoggerInfo) error {
+	if int64(len(content)) > maxWALRecord {
+		/* 超过预写日志记录长度上限的记录直接同步写入通道文件 */
+		return loggerInfo.WriteSync(content)
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.file == nil {
+		file, err := os.OpenFile(w.path(w.segment), os.O_WRONLY|os.O_CREATE|os.O_APPEND, w.fileMode)
+		if err != nil {
+			return err
+		}
+		w.file = file
+		w.failures = w.reporter.failures()
+	}
+	frame := make([]byte, walHeaderSize+len(content))
+	binary.BigEndian.PutUint32(frame, uint32(len(content)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE([]byte(content)))
+	copy(frame[walHeaderSize:], content)
+	if _, err := w.file.Write(frame); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	loggerInfo.Write(content)
+	return nil
+}
+
+/*
+ * 切换到新的段，之前的段在通道文件flush成功之后删除
+ * @param loggerInfo：通道文件，为nil表示通道文件已经关闭，此时之前的记录已经全部写入
+ */
+func (w *wal) checkpoint(loggerInfo *LoggerInfo) {
+	w.lock.Lock()
+	if w.file == nil {
+		w.lock.Unlock()
+		return
+	}
+	if err := w.file.Close(); err != nil {
+		w.reporter.report("WAL.Close", err)
+	}
+	sealed, failures := w.segment, w.failures
+	w.file = nil
+	w.segment++
+	w.lock.Unlock()
+
+	if loggerInfo != nil {
+		loggerInfo.Flush()
+	}
+	if w.reporter.failures() != failures {
+		// 保留该段，下次启动时由ReplayWAL重放
+		w.reporter.report("WAL.Checkpoint", fmt.Errorf("channel %s: write errors or dropped buffers during flush, keeping %s", w.name, w.path(sealed)))
+		return
+	}
+	if err := os.Remove(w.path(sealed)); err != nil {
+		w.reporter.report("WAL.Remove", err)
+	}
+}
+
+/*
+ * 所有日志文件写入失败以及丢弃的buffer总数
+ * 计数是所有日志文件共用的，其他文件的失败同样视为未确认，预写日志中的记录可能重复但不会丢失
+ */
+func (r *reporter) failures() uint64 {
+	return atomic.LoadUint64(&r.failedWrites) + atomic.LoadUint64(&r.droppedBufs)
+}
+
+/*
+ * 读取段中的记录，遇到不完整或者校验失败的记录时停止
+ * @param path：段文件
+ * @param fn：每条记录调用一次
+ * @return (读取的记录数, error)，段尾损坏时返回error
+ */
+func readWALSegment(path string, fn func(record []byte)) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	header := make([]byte, walHeaderSize)
+	count := 0
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return count, fmt.Errorf("read wal %s: truncated record %d", path, count+1)
+		}
+		size := binary.BigEndian.Uint32(header)
+		if int64(size) > maxWALRecord {
+			return count, fmt.Errorf("read wal %s: record %d length %d exceeds %d", path, count+1, size, maxWALRecord)
+		}
+		record := make([]byte, size)
+		if _, err = io.ReadFull(reader, record); err != nil {
+			return count, fmt.Errorf("read wal %s: truncated record %d", path, count+1)
+		}
+		if crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(header[4:]) {
+			return count, fmt.Errorf("read wal %s: checksum mismatch at record %d", path, count+1)
+		}
+		fn(record)
+		count++
+	}
+}
+
+/*
+ * 按照日志文件的权限设置预写日志，并启动定期checkpoint
+ */
+func (logger *Logger) startWAL() {
+	for _, w := range logger.wals.wals {
+		w.fileMode = logger.logFileMode()
+		w.dirMode = logger.logDirMode()
+	}
+	go logger.runWAL()
+}
+
+/*
+ * 定期checkpoint所有通道的预写日志，Close时退出
+ */
+func (logger *Logger) runWAL() {
+	set := logger.wals
+	defer close(set.done)
+	ticker := time.NewTicker(set.config.CheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			logger.checkpointWAL()
+		case <-set.stop:
+			return
+		}
+	}
+}
+
+/*
+ * checkpoint所有通道的预写日志
+ */
+func (logger *Logger) checkpointWAL() {
+	for name, w := range logger.wals.wals {
+		w.checkpoint((&Channel{logger: logger, name: name}).lookup())
+	}
+}
+
+/*
+ * 停止定期checkpoint，在关闭通道文件之前做最后一次checkpoint
+ */
+func (logger *Logger) closeWAL() {
+	set := logger.wals
+	close(set.stop)
+	<-set.done
+	logger.checkpointWAL()
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrUnknownLevel is returned when the level name is not registered
+	ErrUnknownLevel = errors.New("logger: unknown level")
+	// ErrDropped is returned when a record is not accepted before the context expires
+	ErrDropped = errors.New("logger: record dropped")
+	// ErrClosed is returned when writing to a closed logger
+	ErrClosed = errors.New("logger: closed")
+)
+
+// maxAcceptWait WriteCtx等待buffer时的最大重试间隔
+const maxAcceptWait = 50 * time.Millisecond
+
+// WriteCtx writes a record and waits until it is accepted by the pipeline
+/*
+ * 写入一条日志记录，阻塞直到记录进入写入buffer或者ctx结束
+ * 写入队列积压时buffer会被flush协程占用，普通的Debug/Error等调用会一直等待，
+ * 需要确认关键日志至少已经进入写入流程的调用方可以使用本函数设置等待期限
+ * @param ctx：控制等待期限
+ * @param level：日志级别，内置级别或者通过RegisterLevel注册的自定义级别
+ * @param msg：日志内容
+ * @param fields：附加字段，按照key排序以key=value的形式输出，可以为nil；ctx中的字段(参考WithContext)同样会输出
+ * @return 记录进入buffer、级别被过滤或者被合并、抽样丢弃(参考WithDedup、WithSampling)时返回nil；级别不存在返回ErrUnknownLevel；日志文件创建失败返回error；
+ *         ctx结束前没有进入buffer时返回包装了ErrDropped的错误；租户超过配额时返回ErrDropped
+ */
+func (logger *Logger) WriteCtx(ctx context.Context, level, msg string, fields Fields) error {
+	loggerInfo, enabled, err := logger.levelInfo(level)
+	if err != nil || !enabled {
+		return err
+	}
+	fields = mergeFields(logger.contextFields(ctx), fields)
+	if loggerInfo = logger.route(level, loggerInfo, fields); loggerInfo == nil {
+		return ErrDropped
+	}
+	if !logger.dedupe(loggerInfo, level, []interface{}{msg}, fields) || !logger.sampleMessage(level, msg) {
+		return nil
+	}
+
+	content := logger.encode(level, logger.callerAt(level, 1), true, []interface{}{msg}, fields)
+	if content == "" {
+		return nil
+	}
+	if logger.syncLevels[level] {
+		err = loggerInfo.WriteSync(content)
+	} else {
+		err = loggerInfo.WriteCtx(ctx, content)
+	}
+	if err == nil {
+		logger.reporter.record(level)
+	}
+	return err
+}
+
+// WriteCtx appends content to the buffer unless ctx expires first
+/*
+ * 写入buffer，buffer被占用时以指数退避的方式重试，直到写入成功或者ctx结束
+ * @param ctx：控制等待期限
+ * @param content：格式化后的日志记录
+ * @return 写入成功返回nil；已经关闭返回ErrClosed；否则返回包装了ErrDropped的错误
+ */
+func (logger *LoggerInfo) WriteCtx(ctx context.Context, content string) error {
+	wait := time.Millisecond
+	for {
+		if err := ctx.Err(); err != nil {
+			logger.reporter.drop()
+			return fmt.Errorf("%w: %v", ErrDropped, err)
+		}
+		if logger.bufferInfoLock.TryLock() {
+			closed := logger.closed
+			if !closed {
+				logger.buffer.WriteString(content)
+			}
+			logger.bufferInfoLock.Unlock()
+			if closed {
+				logger.reporter.drop()
+				return ErrClosed
+			}
+			if logger.syncWrites {
+				logger.enqueueBuffer()
+			}
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.reporter.drop()
+			return fmt.Errorf("%w: %v", ErrDropped, ctx.Err())
+		case <-timer.C:
+		}
+		if wait < maxAcceptWait {
+			wait *= 2
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// State is the maintenance mode state of the process
+type State struct {
+	Enabled bool      `json:"enabled"`
+	Reason  string    `json:"reason,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+}
+
+var (
+	stateLock sync.RWMutex
+	state     State
+	listeners []func(State)
+	// notifyLock 保证回调按照状态切换的顺序执行
+	notifyLock sync.Mutex
+)
+
+// Enable turns read-only maintenance mode on
+/*
+ * 进入只读维护模式，组件应当拒绝写操作，适用于备份、迁移等场景
+ * 重复调用只会更新原因，不会更新开始时间
+ * @param reason: 进入维护模式的原因
+ */
+func Enable(reason string) {
+	notifyLock.Lock()
+	defer notifyLock.Unlock()
+	stateLock.Lock()
+	if !state.Enabled {
+		state.Since = time.Now()
+	}
+	state.Enabled = true
+	state.Reason = reason
+	current := state
+	callbacks := listeners
+	stateLock.Unlock()
+
+	notify(callbacks, current)
+}
+
+// Disable turns maintenance mode off
+/*
+ * 退出维护模式，恢复正常读写
+ */
+func Disable() {
+	notifyLock.Lock()
+	defer notifyLock.Unlock()
+	stateLock.Lock()
+	wasEnabled := state.Enabled
+	state = State{}
+	callbacks := listeners
+	stateLock.Unlock()
+
+	if wasEnabled {
+		notify(callbacks, State{})
+	}
+}
+
+// Enabled reports whether maintenance mode is on
+func Enabled() bool {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	return state.Enabled
+}
+
+// Current returns a copy of current maintenance state
+func Current() State {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	return state
+}
+
+// OnChange registers a callback invoked whenever the maintenance state changes
+/*
+ * 注册维护模式变化回调，回调在切换维护模式的协程中同步执行，多次切换的回调按照切换顺序依次执行
+ * 回调中不能调用Enable/Disable
+ * @param f: 回调函数，参数为切换后的状态
+ */
+func OnChange(f func(State)) {
+	stateLock.Lock()
+	listeners = append(listeners, f)
+	stateLock.Unlock()
+}
+
+func notify(callbacks []func(State), current State) {
+	for _, f := range callbacks {
+		f(current)
+	}
+}
+
+// Middleware rejects write requests with 503 while maintenance mode is on
+/*
+ * HTTP中间件：维护模式下GET/HEAD/OPTIONS请求正常处理，其余请求返回503
+ * @param next: 被包装的handler
+ * @return 包装后的handler
+ */
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if current := Current(); current.Enabled {
+				w.Header().Set("Retry-After", "60")
+				msg := "service in maintenance mode"
+				if current.Reason != "" {
+					msg += ": " + current.Reason
+				}
+				http.Error(w, msg, http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handler returns an admin http.Handler to query and toggle maintenance mode
+/*
+ * 维护模式管理接口
+ * GET 返回当前状态
+ * PUT/POST ?enabled=true&reason=xxx 进入维护模式；enabled=false 退出维护模式
+ */
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			switch r.FormValue("enabled") {
+			case "true", "1", "on":
+				Enable(r.FormValue("reason"))
+			case "false", "0", "off":
+				Disable()
+			default:
+				http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Current())
+	})
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are the default histogram bounds in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in fixed buckets
+/*
+ * 直方图，按照递增的分桶上限计数，记录时只有原子操作，没有锁
+ * 大于最后一个上限的值只计入总数(对应Prometheus的+Inf桶)
+ */
+type Histogram struct {
+	name    string
+	help    string
+	bounds  []float64
+	counts  []uint64 // 每个桶单独的计数(不累计)
+	count   uint64
+	sumBits uint64 // 总和float64的二进制表示
+}
+
+func newHistogram(name, help string, buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	return &Histogram{
+		name:   name,
+		help:   help,
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// Name implements Metric
+func (h *Histogram) Name() string { return h.name }
+
+// Help implements Metric
+func (h *Histogram) Help() string { return h.help }
+
+// Kind implements Metric
+func (h *Histogram) Kind() string { return KindHistogram }
+
+// Observe records a value
+func (h *Histogram) Observe(v float64) {
+	if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	addFloat(&h.sumBits, v)
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Since records the seconds elapsed since start
+/*
+ * 记录从start到现在的耗时(秒)，例如：
+ *     defer latency.Since(time.Now())
+ */
+func (h *Histogram) Since(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// HistogramSnapshot is a point in time copy of a histogram
+type HistogramSnapshot struct {
+	Bounds []float64 // 分桶上限
+	Counts []uint64  // 每个桶单独的计数(不累计)
+	Count  uint64
+	Sum    float64
+}
+
+// Snapshot returns a copy of the current counts
+/*
+ * 复制当前的计数，各个字段分别原子读取，并发记录时Count与各桶之和可能有细微差别
+ */
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.counts)),
+		Count:  atomic.LoadUint64(&h.count),
+		Sum:    math.Float64frombits(atomic.LoadUint64(&h.sumBits)),
+	}
+	for i := range h.counts {
+		s.Counts[i] = atomic.LoadUint64(&h.counts[i])
+	}
+	return s
+}
+
+// Sub returns the observations made between prev and s
+func (s HistogramSnapshot) Sub(prev HistogramSnapshot) HistogramSnapshot {
+	delta := HistogramSnapshot{
+		Bounds: s.Bounds,
+		Counts: make([]uint64, len(s.Counts)),
+		Count:  s.Count - prev.Count,
+		Sum:    s.Sum - prev.Sum,
+	}
+	for i := range s.Counts {
+		if i < len(prev.Counts) {
+			delta.Counts[i] = s.Counts[i] - prev.Counts[i]
+		} else {
+			delta.Counts[i] = s.Counts[i]
+		}
+	}
+	return delta
+}
+
+// Quantile estimates the q-quantile (0 <= q <= 1) by linear interpolation within buckets
+/*
+ * 估算分位数，在分位数所在的桶内线性插值，精度取决于分桶
+ * 落在最后一个上限之外时返回最后一个上限；没有数据时返回0
+ */
+func (s HistogramSnapshot) Quantile(q float64) float64 {
+	if s.Count == 0 || len(s.Bounds) == 0 {
+		return 0
+	}
+	rank := q * float64(s.Count)
+	var cumulative uint64
+	lower := 0.0
+	for i, bound := range s.Bounds {
+		next := cumulative + s.Counts[i]
+		if float64(next) >= rank && s.Counts[i] > 0 {
+			return lower + (bound-lower)*(rank-float64(cumulative))/float64(s.Counts[i])
+		}
+		cumulative = next
+		lower = bound
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
+
+// Mean returns the average of the observations, 0 without data
+func (s HistogramSnapshot) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
@@ -0,0 +1,275 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// 指标类型，与Prometheus的TYPE一致
+const (
+	KindCounter   = "counter"
+	KindGauge     = "gauge"
+	KindHistogram = "histogram"
+)
+
+// nameRegexp Prometheus指标名称的格式
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Metric is a named metric held by a Registry
+type Metric interface {
+	// Name 指标名称
+	Name() string
+	// Help 指标说明
+	Help() string
+	// Kind 指标类型，KindCounter、KindGauge或者KindHistogram
+	Kind() string
+}
+
+// Registry holds named metrics
+/*
+ * 指标注册表，通常每个进程使用Default()返回的全局注册表
+ * 同一个名称只能注册一种类型，重复获取返回同一个指标，可以在包级别变量中初始化
+ */
+type Registry struct {
+	sync.RWMutex
+	metrics map[string]Metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]Metric)}
+}
+
+/*
+ * 获取或者创建指标，名称非法或者已经注册为其他类型时panic，属于编程错误
+ */
+func (reg *Registry) getOrCreate(name, kind string, create func() Metric) Metric {
+	reg.RLock()
+	m, ok := reg.metrics[name]
+	reg.RUnlock()
+	if !ok {
+		if !nameRegexp.MatchString(name) {
+			panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+		}
+		reg.Lock()
+		if m, ok = reg.metrics[name]; !ok {
+			m = create()
+			reg.metrics[name] = m
+		}
+		reg.Unlock()
+	}
+	if m.Kind() != kind {
+		panic(fmt.Sprintf("metrics: %s already registered as %s", name, m.Kind()))
+	}
+	return m
+}
+
+// Counter returns the counter with name, creating it if needed
+/*
+ * 获取计数器，不存在时创建
+ * @param name：指标名称，按照Prometheus的习惯以_total结尾，例如 http_requests_total
+ * @param help：指标说明，只在第一次创建时使用
+ * @return 计数器
+ */
+func (reg *Registry) Counter(name, help string) *Counter {
+	return reg.getOrCreate(name, KindCounter, func() Metric {
+		return &Counter{name: name, help: help}
+	}).(*Counter)
+}
+
+// CounterFunc registers a counter whose value is read from fn on every snapshot
+/*
+ * 注册由函数计算的计数器，每次快照时调用fn，适合缓存命中数等已经由其他模块累计的值
+ * 名称已经注册时替换原来的函数
+ * @param name：指标名称
+ * @param help：指标说明
+ * @param fn：返回当前累计值，需要并发安全、单调递增并且尽快返回
+ */
+func (reg *Registry) CounterFunc(name, help string, fn func() uint64) {
+	reg.Counter(name, help).setFunc(fn)
+}
+
+// Gauge returns the gauge with name, creating it if needed
+func (reg *Registry) Gauge(name, help string) *Gauge {
+	return reg.getOrCreate(name, KindGauge, func() Metric {
+		return &Gauge{name: name, help: help}
+	}).(*Gauge)
+}
+
+// GaugeFunc registers a gauge whose value is read from fn on every snapshot
+/*
+ * 注册由函数计算的仪表，每次快照时调用fn，适合队列长度、连接数等已经由其他模块维护的值
+ * 名称已经注册时替换原来的函数
+ * @param name：指标名称
+ * @param help：指标说明
+ * @param fn：返回当前值，需要并发安全并且尽快返回
+ */
+func (reg *Registry) GaugeFunc(name, help string, fn func() float64) {
+	reg.Gauge(name, help).setFunc(fn)
+}
+
+// Histogram returns the histogram with name, creating it with buckets if needed
+/*
+ * 获取直方图，不存在时按照buckets创建
+ * @param name：指标名称，耗时按照Prometheus的习惯使用秒为单位并以_seconds结尾
+ * @param help：指标说明
+ * @param buckets：递增的分桶上限，为空时使用DefaultBuckets；只在第一次创建时使用
+ * @return 直方图
+ */
+func (reg *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	return reg.getOrCreate(name, KindHistogram, func() Metric {
+		return newHistogram(name, help, buckets)
+	}).(*Histogram)
+}
+
+// Unregister removes the metric with name
+func (reg *Registry) Unregister(name string) {
+	reg.Lock()
+	delete(reg.metrics, name)
+	reg.Unlock()
+}
+
+// Metrics returns all metrics sorted by name
+func (reg *Registry) Metrics() []Metric {
+	reg.RLock()
+	metrics := make([]Metric, 0, len(reg.metrics))
+	for _, m := range reg.metrics {
+		metrics = append(metrics, m)
+	}
+	reg.RUnlock()
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name() < metrics[j].Name()
+	})
+	return metrics
+}
+
+// Counter is a monotonically increasing counter
+type Counter struct {
+	value uint64
+	name  string
+	help  string
+	fn    atomic.Value // CounterFunc注册的函数
+}
+
+// Name implements Metric
+func (c *Counter) Name() string { return c.name }
+
+// Help implements Metric
+func (c *Counter) Help() string { return c.help }
+
+// Kind implements Metric
+func (c *Counter) Kind() string { return KindCounter }
+
+// Add adds n to the counter
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Value returns the current count, calling the function of CounterFunc if set
+func (c *Counter) Value() uint64 {
+	if fn, ok := c.fn.Load().(func() uint64); ok {
+		return fn()
+	}
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) setFunc(fn func() uint64) {
+	c.fn.Store(fn)
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	bits uint64 // float64的二进制表示
+	name string
+	help string
+	fn   atomic.Value // GaugeFunc注册的函数
+}
+
+// Name implements Metric
+func (g *Gauge) Name() string { return g.name }
+
+// Help implements Metric
+func (g *Gauge) Help() string { return g.help }
+
+// Kind implements Metric
+func (g *Gauge) Kind() string { return KindGauge }
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add adds delta, which may be negative, to the gauge
+func (g *Gauge) Add(delta float64) {
+	addFloat(&g.bits, delta)
+}
+
+// Inc adds one to the gauge
+func (g *Gauge) Inc() {
+	addFloat(&g.bits, 1)
+}
+
+// Dec subtracts one from the gauge
+func (g *Gauge) Dec() {
+	addFloat(&g.bits, -1)
+}
+
+// Value returns the current value, calling the function of GaugeFunc if set
+func (g *Gauge) Value() float64 {
+	if fn, ok := g.fn.Load().(func() float64); ok {
+		return fn()
+	}
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *Gauge) setFunc(fn func() float64) {
+	g.fn.Store(fn)
+}
+
+/*
+ * 原子地给float64加上delta
+ */
+func addFloat(bits *uint64, delta float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		if atomic.CompareAndSwapUint64(bits, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// defaultRegistry 进程级别的全局注册表
+var defaultRegistry = NewRegistry()
+
+// Default returns the process wide registry
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// NewCounter returns the named counter of the default registry
+/*
+ * 获取全局注册表中的计数器，一般在包级别变量中使用：
+ *     var requests = metrics.NewCounter("http_requests_total", "HTTP requests served.")
+ */
+func NewCounter(name, help string) *Counter {
+	return defaultRegistry.Counter(name, help)
+}
+
+// NewGauge returns the named gauge of the default registry
+func NewGauge(name, help string) *Gauge {
+	return defaultRegistry.Gauge(name, help)
+}
+
+// NewHistogram returns the named histogram of the default registry
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return defaultRegistry.Histogram(name, help, buckets)
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Handler serves all metrics of reg in the Prometheus text exposition format
+/*
+ * 返回Prometheus文本格式的指标接口，不依赖Prometheus客户端库，挂载到管理端口后由Prometheus直接抓取，例如：
+ *     mux.Handle("/metrics", metrics.Default().Handler())
+ * 计数器以及直方图输出进程启动以来的累计值
+ */
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(reg.AppendText(nil))
+	})
+}
+
+// AppendText appends all metrics of reg in the Prometheus text format to buf
+func (reg *Registry) AppendText(buf []byte) []byte {
+	for _, m := range reg.Metrics() {
+		buf = appendHeader(buf, m)
+		switch m := m.(type) {
+		case *Counter:
+			buf = append(buf, m.name+" "...)
+			buf = strconv.AppendUint(buf, m.Value(), 10)
+			buf = append(buf, '\n')
+		case *Gauge:
+			buf = append(buf, m.name+" "...)
+			buf = appendFloat(buf, m.Value())
+			buf = append(buf, '\n')
+		case *Histogram:
+			buf = appendHistogram(buf, m.name, m.Snapshot())
+		}
+	}
+	return buf
+}
+
+/*
+ * 输出指标的HELP以及TYPE
+ */
+func appendHeader(buf []byte, m Metric) []byte {
+	if help := m.Help(); help != "" {
+		buf = append(buf, "# HELP "+m.Name()+" "+helpEscaper.Replace(help)+"\n"...)
+	}
+	return append(buf, "# TYPE "+m.Name()+" "+m.Kind()+"\n"...)
+}
+
+/*
+ * 按照Prometheus histogram格式输出，分桶数量累计
+ */
+func appendHistogram(buf []byte, name string, s HistogramSnapshot) []byte {
+	var cumulative uint64
+	for i, bound := range s.Bounds {
+		cumulative += s.Counts[i]
+		buf = append(buf, name+`_bucket{le="`...)
+		buf = appendFloat(buf, bound)
+		buf = append(buf, `"} `...)
+		buf = strconv.AppendUint(buf, cumulative, 10)
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, name+`_bucket{le="+Inf"} `...)
+	buf = strconv.AppendUint(buf, s.Count, 10)
+	buf = append(buf, '\n')
+	buf = append(buf, name+"_sum "...)
+	buf = appendFloat(buf, s.Sum)
+	buf = append(buf, '\n')
+	buf = append(buf, name+"_count "...)
+	buf = strconv.AppendUint(buf, s.Count, 10)
+	return append(buf, '\n')
+}
+
+/*
+ * 输出浮点数，无穷以及NaN使用Prometheus的写法
+ */
+func appendFloat(buf []byte, v float64) []byte {
+	switch {
+	case math.IsInf(v, 1):
+		return append(buf, "+Inf"...)
+	case math.IsInf(v, -1):
+		return append(buf, "-Inf"...)
+	case math.IsNaN(v):
+		return append(buf, "NaN"...)
+	}
+	return strconv.AppendFloat(buf, v, 'g', -1, 64)
+}
+
+// helpEscaper Prometheus HELP文本的转义
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// Report periodically writes a snapshot of reg as one line to channel
+/*
+ * 定期将注册表中的指标写入日志通道，每个周期一行，例如：
+ *     process.OnExit(metrics.Default().Report(log.Channel("metrics"), time.Minute))
+ * 行内按名称排序，格式为 name=value，以空格分隔：
+ *   计数器为本周期内的增量
+ *   仪表为当前值
+ *   直方图为本周期内的 name.count、name.avg、name.p50、name.p99
+ * 本周期内没有变化的计数器以及没有记录的直方图省略；全部省略时不写入
+ * @param channel：日志通道，建议使用单独的通道便于采集
+ * @param interval：输出间隔，<=0时为1分钟
+ * @return 停止输出的函数，停止时输出最后一个周期
+ */
+func (reg *Registry) Report(channel *logger.Channel, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	r := &reporter{
+		reg:        reg,
+		counters:   make(map[string]uint64),
+		histograms: make(map[string]HistogramSnapshot),
+	}
+	// 记录初始值，第一行即为第一个周期的增量
+	r.line()
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if line := r.line(); line != "" {
+					channel.Write(line)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			if line := r.line(); line != "" {
+				channel.Write(line)
+			}
+		})
+	}
+}
+
+// reporter 记录上一个周期的计数，用于计算增量
+type reporter struct {
+	lock       sync.Mutex
+	reg        *Registry
+	counters   map[string]uint64
+	histograms map[string]HistogramSnapshot
+}
+
+/*
+ * 生成一个周期的输出，并记录当前计数
+ */
+func (r *reporter) line() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var b strings.Builder
+	field := func(name string, value string) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(value)
+	}
+	for _, m := range r.reg.Metrics() {
+		switch m := m.(type) {
+		case *Counter:
+			value := m.Value()
+			if delta := value - r.counters[m.name]; delta > 0 {
+				field(m.name, strconv.FormatUint(delta, 10))
+			}
+			r.counters[m.name] = value
+		case *Gauge:
+			field(m.name, formatFloat(m.Value()))
+		case *Histogram:
+			snapshot := m.Snapshot()
+			delta := snapshot.Sub(r.histograms[m.name])
+			r.histograms[m.name] = snapshot
+			if delta.Count == 0 {
+				continue
+			}
+			field(m.name+".count", strconv.FormatUint(delta.Count, 10))
+			field(m.name+".avg", formatFloat(delta.Mean()))
+			field(m.name+".p50", formatFloat(delta.Quantile(0.5)))
+			field(m.name+".p99", formatFloat(delta.Quantile(0.99)))
+		}
+	}
+	return b.String()
+}
+
+/*
+ * 格式化浮点数，最多保留6位有效数字，避免日志中出现过长的数字
+ */
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
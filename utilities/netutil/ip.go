@@ -0,0 +1,206 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// Family selects the IP version of the addresses returned
+type Family int
+
+const (
+	// AnyFamily accepts both IPv4 and IPv6 addresses
+	AnyFamily Family = iota
+	// IPv4 accepts only IPv4 addresses
+	IPv4
+	// IPv6 accepts only IPv6 addresses
+	IPv6
+)
+
+// outboundProbe 获取出口地址时"连接"的地址，UDP不会真正发送数据，只用于选路
+var outboundProbe = map[Family]string{
+	IPv4: "8.8.8.8:80",
+	IPv6: "[2001:4860:4860::8888]:80",
+}
+
+// ErrNoAddress is returned when no interface address matches the filter
+var ErrNoAddress = errors.New("netutil: no matching address")
+
+// Addr is an address configured on a network interface
+type Addr struct {
+	Interface string     // 网卡名称，例如eth0
+	IP        net.IP     // 地址
+	Net       *net.IPNet // 地址所在的网段
+}
+
+// Filter selects interface addresses
+/*
+ * 地址过滤条件，零值表示所有已启用网卡上的非回环、非链路本地地址
+ */
+type Filter struct {
+	Family           Family   // 地址类型，默认IPv4以及IPv6都可以
+	Interfaces       []string // 只使用这些网卡，为空表示所有网卡
+	Prefer           []string // 优先选择的网段，按照顺序，例如[]string{"10.0.0.0/8", "172.16.0.0/12"}
+	IncludeLoopback  bool     // 包括回环地址(127.0.0.1、::1)
+	IncludeLinkLocal bool     // 包括链路本地地址(169.254.0.0/16、fe80::/10)
+}
+
+// Addrs lists the addresses of the enabled interfaces matching filter
+/*
+ * 获取已启用网卡上满足条件的地址，按照Prefer中网段的顺序排序，不在Prefer中的地址排在最后，其余保持系统返回的顺序
+ * @param filter：过滤条件
+ * @return (地址列表, error)；Prefer中的网段格式错误或者获取网卡失败时返回error
+ */
+func Addrs(filter Filter) ([]Addr, error) {
+	prefer, err := parseCIDRs(filter.Prefer)
+	if err != nil {
+		return nil, err
+	}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var addrs []Addr
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || !filter.matchInterface(iface.Name) {
+			continue
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range ifaceAddrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || !filter.matchIP(ipNet.IP) {
+				continue
+			}
+			addrs = append(addrs, Addr{Interface: iface.Name, IP: ipNet.IP, Net: ipNet})
+		}
+	}
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return preference(prefer, addrs[i].IP) < preference(prefer, addrs[j].IP)
+	})
+	return addrs, nil
+}
+
+// PickIP returns the best address matching filter
+/*
+ * 按照Addrs的顺序选择第一个地址；没有满足条件的网卡地址，并且没有限定网卡时，使用出口地址(参考OutboundIP)
+ * @param filter：过滤条件
+ * @return (地址, error)；没有可用地址时返回ErrNoAddress
+ */
+func PickIP(filter Filter) (net.IP, error) {
+	addrs, err := Addrs(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) > 0 {
+		return addrs[0].IP, nil
+	}
+	if len(filter.Interfaces) > 0 {
+		return nil, ErrNoAddress
+	}
+	ip, err := OutboundIP(filter.Family)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoAddress, err)
+	}
+	return ip, nil
+}
+
+// OutboundIP returns the local address used to reach the default route
+/*
+ * 获取访问外网时使用的本机地址(主地址)：创建一个UDP"连接"，由系统选路之后读取本地地址，不会发送任何数据
+ * 没有默认路由时返回error
+ * @param family：地址类型，AnyFamily时先尝试IPv4
+ * @return (地址, error)
+ */
+func OutboundIP(family Family) (net.IP, error) {
+	families := []Family{family}
+	if family == AnyFamily {
+		families = []Family{IPv4, IPv6}
+	}
+	var err error
+	for _, f := range families {
+		var conn net.Conn
+		if conn, err = net.Dial("udp", outboundProbe[f]); err != nil {
+			continue
+		}
+		ip := conn.LocalAddr().(*net.UDPAddr).IP
+		conn.Close()
+		return ip, nil
+	}
+	return nil, err
+}
+
+// InnerIP returns the primary IPv4 address as a string, empty when there is none
+/*
+ * 获取本机内网IPv4地址，优先选择10.0.0.0/8、172.16.0.0/12、192.168.0.0/16中的地址，都没有时使用出口地址
+ * @return 地址，没有可用地址时返回空字符串
+ */
+func InnerIP() string {
+	ip, err := PickIP(Filter{Family: IPv4, Prefer: []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}})
+	if err != nil {
+		return ""
+	}
+	return ip.String()
+}
+
+/*
+ * 判断网卡是否在过滤条件中
+ */
+func (filter *Filter) matchInterface(name string) bool {
+	if len(filter.Interfaces) == 0 {
+		return true
+	}
+	for _, n := range filter.Interfaces {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * 判断地址是否满足地址类型、回环以及链路本地的条件
+ */
+func (filter *Filter) matchIP(ip net.IP) bool {
+	isV4 := ip.To4() != nil
+	switch {
+	case filter.Family == IPv4 && !isV4, filter.Family == IPv6 && isV4:
+		return false
+	case ip.IsLoopback() && !filter.IncludeLoopback:
+		return false
+	case (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) && !filter.IncludeLinkLocal:
+		return false
+	}
+	return !ip.IsUnspecified()
+}
+
+/*
+ * 解析网段列表
+ */
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("netutil: prefer %q: %w", cidr, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+/*
+ * 地址在优先网段中的位置，不在任何网段中时返回len(prefer)
+ */
+func preference(prefer []*net.IPNet, ip net.IP) int {
+	for i, ipNet := range prefer {
+		if ipNet.Contains(ip) {
+			return i
+		}
+	}
+	return len(prefer)
+}
@@ -0,0 +1,112 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// 等待端口时的重试间隔
+const (
+	minPortRetry = 20 * time.Millisecond
+	maxPortRetry = time.Second
+)
+
+var (
+	// ErrPortTimeout is returned when the port does not accept connections before the deadline
+	ErrPortTimeout = errors.New("netutil: port not ready")
+	// ErrReuseUnsupported is returned when a socket option is not available on this platform
+	ErrReuseUnsupported = errors.New("netutil: socket option not supported on this platform")
+)
+
+// ReuseOptions selects the socket options set by ListenWithReuse
+type ReuseOptions struct {
+	ReuseAddr bool // SO_REUSEADDR：允许绑定仍有TIME_WAIT连接的地址，Windows上允许其他套接字抢占该地址，谨慎使用
+	ReusePort bool // SO_REUSEPORT：允许多个进程绑定同一个地址，由内核分发连接，Windows不支持
+}
+
+// WaitForPort waits until addr accepts TCP connections
+/*
+ * 等待TCP地址可以连接，用于启动子服务之后等待其开始监听，以及集成测试等待被测服务就绪
+ * 连接失败时以指数退避的方式重试，间隔从20ms开始，最长1s
+ * @param addr：地址，例如127.0.0.1:8080
+ * @param timeout：最长等待时间
+ * @return 超时返回包装了ErrPortTimeout的错误，包含最后一次连接的错误
+ */
+func WaitForPort(addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return WaitForPortContext(ctx, addr)
+}
+
+// WaitForPortContext is WaitForPort bounded by ctx
+/*
+ * 等待TCP地址可以连接，直到ctx结束
+ * @param ctx：控制等待期限
+ * @param addr：地址
+ * @return ctx结束前没有连接成功时返回包装了ErrPortTimeout的错误
+ */
+func WaitForPortContext(ctx context.Context, addr string) error {
+	var dialer net.Dialer
+	wait := minPortRetry
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %s: %v", ErrPortTimeout, addr, err)
+		}
+		if wait *= 2; wait > maxPortRetry {
+			wait = maxPortRetry
+		}
+	}
+}
+
+// GetFreePort returns a TCP port that is currently free on all local addresses
+/*
+ * 获取一个当前空闲的TCP端口，由系统分配
+ * 端口在返回之前已经释放，使用之前可能被其他进程占用，集成测试中可以在监听失败时重新获取
+ * @return (端口, error)
+ */
+func GetFreePort() (int, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// ListenWithReuse listens on a stream address with SO_REUSEADDR/SO_REUSEPORT set
+/*
+ * 设置端口复用选项之后监听，用于多个进程共享同一个端口，或者重启时绑定仍有TIME_WAIT连接的端口
+ * 例如热升级时新进程与旧进程同时监听：
+ *     listener, err := netutil.ListenWithReuse("tcp", ":8080", netutil.ReuseOptions{ReusePort: true})
+ * @param network：tcp/tcp4/tcp6
+ * @param addr：监听地址
+ * @param opts：复用选项，都为false时与net.Listen相同
+ * @return (listener, error)；平台不支持选项时返回包装了ErrReuseUnsupported的错误
+ */
+func ListenWithReuse(network, addr string, opts ReuseOptions) (net.Listener, error) {
+	config := net.ListenConfig{
+		Control: func(network, address string, conn syscall.RawConn) error {
+			var optErr error
+			if err := conn.Control(func(fd uintptr) {
+				optErr = setReuse(fd, opts)
+			}); err != nil {
+				return err
+			}
+			return optErr
+		},
+	}
+	return config.Listen(context.Background(), network, addr)
+}
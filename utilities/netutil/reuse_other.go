@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package netutil
+
+/*
+ * 其他平台不支持设置复用选项
+ */
+func setReuse(fd uintptr, opts ReuseOptions) error {
+	if opts.ReuseAddr || opts.ReusePort {
+		return ErrReuseUnsupported
+	}
+	return nil
+}
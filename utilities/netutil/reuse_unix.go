@@ -0,0 +1,26 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package netutil
+
+import (
+	"fmt"
+	"syscall"
+)
+
+/*
+ * 设置套接字的复用选项
+ */
+func setReuse(fd uintptr, opts ReuseOptions) error {
+	if opts.ReuseAddr {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+			return fmt.Errorf("set SO_REUSEADDR: %w", err)
+		}
+	}
+	if opts.ReusePort {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1); err != nil {
+			return fmt.Errorf("set SO_REUSEPORT: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package netutil
+
+import (
+	"fmt"
+	"syscall"
+)
+
+/*
+ * 设置套接字的复用选项，Windows没有SO_REUSEPORT
+ */
+func setReuse(fd uintptr, opts ReuseOptions) error {
+	if opts.ReusePort {
+		return fmt.Errorf("%w: SO_REUSEPORT", ErrReuseUnsupported)
+	}
+	if opts.ReuseAddr {
+		if err := syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+			return fmt.Errorf("set SO_REUSEADDR: %w", err)
+		}
+	}
+	return nil
+}
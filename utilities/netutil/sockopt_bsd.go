@@ -0,0 +1,9 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package netutil
+
+import "syscall"
+
+// soReusePort SO_REUSEPORT选项
+const soReusePort = syscall.SO_REUSEPORT
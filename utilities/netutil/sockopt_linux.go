@@ -0,0 +1,7 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le
+
+package netutil
+
+// soReusePort SO_REUSEPORT选项，syscall包没有定义
+const soReusePort = 0xf
@@ -0,0 +1,92 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the rendering format of command output
+type Format string
+
+const (
+	// FormatTable renders data as an aligned text table
+	FormatTable Format = "table"
+	// FormatJSON renders data as indented JSON
+	FormatJSON Format = "json"
+	// FormatYAML renders data as YAML
+	FormatYAML Format = "yaml"
+)
+
+// ParseFormat parses a format name given on the command line
+/*
+ * 解析命令行中指定的输出格式，忽略大小写
+ * @param s：格式名称，为空时使用table
+ * @return 成功返回(Format, nil)；否则返回("", error)
+ */
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case "", FormatTable:
+		return FormatTable, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatYAML, "yml":
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("output: unknown format %q, expect table/json/yaml", s)
+}
+
+// Render writes v to w in the given format
+/*
+ * 按指定格式输出数据，运维命令统一使用本函数输出状态以及健康检查信息
+ * table格式下，v可以是*Table、struct切片或者map切片；单个struct或者map输出为key/value两列的表格
+ * @param w：输出目标
+ * @param format：输出格式
+ * @param v：待输出的数据
+ * @return 失败时返回error
+ */
+func Render(w io.Writer, format Format, v interface{}) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(v)
+	case FormatYAML:
+		return WriteYAML(w, v)
+	case FormatTable, "":
+		table, err := toTable(v)
+		if err != nil {
+			return err
+		}
+		return table.Render(w)
+	}
+	return fmt.Errorf("output: unknown format %q", format)
+}
+
+func toTable(v interface{}) (*Table, error) {
+	if table, ok := v.(*Table); ok {
+		return table, nil
+	}
+	if table, err := TableOf(v); err == nil {
+		return table, nil
+	}
+
+	// 单个对象按照YAML的层级展开为key/value两列
+	var sb strings.Builder
+	if err := WriteYAML(&sb, v); err != nil {
+		return nil, err
+	}
+	table := NewTable("KEY", "VALUE")
+	for _, line := range strings.Split(strings.TrimRight(sb.String(), "\n"), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := line[:len(line)-len(trimmed)]
+		if i := strings.Index(trimmed, ":"); i > 0 && !strings.HasPrefix(trimmed, "-") {
+			table.AddRow(indent+trimmed[:i], strings.TrimSpace(trimmed[i+1:]))
+		} else {
+			table.AddRow(line, "")
+		}
+	}
+	return table, nil
+}
@@ -0,0 +1,259 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Align is the horizontal alignment of a table column
+type Align int
+
+const (
+	// AlignLeft aligns cell content to the left
+	AlignLeft Align = iota
+	// AlignRight aligns cell content to the right
+	AlignRight
+)
+
+// Table is a text table rendered with aligned columns
+/*
+ * 文本表格，按列对齐输出，支持中文等宽字符以及单元格内换行
+ */
+type Table struct {
+	Headers []string
+	Rows    [][]string
+	Aligns  []Align // 各列的对齐方式，未设置的列左对齐
+	Border  bool    // 是否输出边框
+}
+
+// NewTable creates a table with given headers
+func NewTable(headers ...string) *Table {
+	return &Table{Headers: headers}
+}
+
+// AddRow appends a row, values are converted by fmt.Sprint
+func (t *Table) AddRow(values ...interface{}) *Table {
+	row := make([]string, len(values))
+	for i, v := range values {
+		row[i] = fmt.Sprint(v)
+	}
+	t.Rows = append(t.Rows, row)
+	return t
+}
+
+// Render writes the table to w
+/*
+ * 输出表格
+ * 单元格中的换行符会将单元格拆分成多行，同一行其他单元格补齐空白
+ * @param w：输出目标
+ * @return 写入失败时返回error
+ */
+func (t *Table) Render(w io.Writer) error {
+	columns := len(t.Headers)
+	for _, row := range t.Rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	if columns == 0 {
+		return nil
+	}
+
+	rows := make([][]string, 0, len(t.Rows)+1)
+	if len(t.Headers) > 0 {
+		rows = append(rows, t.Headers)
+	}
+	rows = append(rows, t.Rows...)
+
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			for _, line := range splitLines(cell) {
+				if width := StringWidth(line); width > widths[i] {
+					widths[i] = width
+				}
+			}
+		}
+	}
+
+	var sb strings.Builder
+	separator := t.separator(widths)
+	if t.Border {
+		sb.WriteString(separator)
+	}
+	for n, row := range rows {
+		t.renderRow(&sb, row, widths)
+		if n == 0 && len(t.Headers) > 0 {
+			if t.Border {
+				sb.WriteString(separator)
+			} else {
+				t.renderRow(&sb, t.underline(widths), widths)
+			}
+		}
+	}
+	if t.Border && len(rows) > 1 {
+		sb.WriteString(separator)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// String renders the table into a string
+func (t *Table) String() string {
+	var sb strings.Builder
+	t.Render(&sb)
+	return sb.String()
+}
+
+/*
+ * 输出一行数据，多行单元格会输出为多个物理行
+ */
+func (t *Table) renderRow(sb *strings.Builder, row []string, widths []int) {
+	cells := make([][]string, len(widths))
+	height := 1
+	for i := range widths {
+		if i < len(row) {
+			cells[i] = splitLines(row[i])
+		}
+		if len(cells[i]) > height {
+			height = len(cells[i])
+		}
+	}
+
+	for l := 0; l < height; l++ {
+		var line strings.Builder
+		if t.Border {
+			line.WriteString("| ")
+		}
+		for i, width := range widths {
+			cell := ""
+			if l < len(cells[i]) {
+				cell = cells[i][l]
+			}
+			pad := strings.Repeat(" ", width-StringWidth(cell))
+			if i < len(t.Aligns) && t.Aligns[i] == AlignRight {
+				line.WriteString(pad + cell)
+			} else {
+				line.WriteString(cell + pad)
+			}
+			if i < len(widths)-1 {
+				if t.Border {
+					line.WriteString(" | ")
+				} else {
+					line.WriteString("  ")
+				}
+			}
+		}
+		if t.Border {
+			line.WriteString(" |")
+			sb.WriteString(line.String())
+		} else {
+			// 无边框时去掉行尾空白
+			sb.WriteString(strings.TrimRight(line.String(), " "))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+func (t *Table) separator(widths []int) string {
+	parts := make([]string, len(widths))
+	for i, width := range widths {
+		parts[i] = strings.Repeat("-", width+2)
+	}
+	return "+" + strings.Join(parts, "+") + "+\n"
+}
+
+func (t *Table) underline(widths []int) []string {
+	row := make([]string, len(widths))
+	for i, width := range widths {
+		row[i] = strings.Repeat("-", width)
+	}
+	return row
+}
+
+func splitLines(cell string) []string {
+	cell = strings.Replace(cell, "\r\n", "\n", -1)
+	cell = strings.Replace(cell, "\t", "    ", -1)
+	return strings.Split(cell, "\n")
+}
+
+// TableOf builds a table from a slice of structs or maps
+/*
+ * 将struct切片或者map切片转换为表格
+ * struct切片以导出字段为列，列名优先使用`table`标签，标签为"-"的字段不输出
+ * map切片以所有key的并集为列，按照第一次出现的顺序排列
+ * @param v：struct切片、struct指针切片或者map[string]T切片
+ * @return 成功返回(*Table, nil)；否则返回(nil, error)
+ */
+func TableOf(v interface{}) (*Table, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("output: table requires a slice, got %T", v)
+	}
+
+	elemType := rv.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	table := &Table{}
+	switch elemType.Kind() {
+	case reflect.Struct:
+		var fields []int
+		for i := 0; i < elemType.NumField(); i++ {
+			field := elemType.Field(i)
+			name := field.Tag.Get("table")
+			if field.PkgPath != "" || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fields = append(fields, i)
+			table.Headers = append(table.Headers, name)
+		}
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflect.Indirect(rv.Index(i))
+			row := make([]string, len(fields))
+			if elem.IsValid() {
+				for n, index := range fields {
+					row[n] = fmt.Sprint(elem.Field(index).Interface())
+				}
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	case reflect.Map:
+		if elemType.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("output: table requires string map keys, got %v", elemType.Key())
+		}
+		columns := make(map[string]int)
+		var records []map[string]string
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflect.Indirect(rv.Index(i))
+			record := make(map[string]string)
+			if elem.IsValid() {
+				for _, key := range sortedKeys(elem) {
+					name := key.String()
+					if _, ok := columns[name]; !ok {
+						columns[name] = len(table.Headers)
+						table.Headers = append(table.Headers, name)
+					}
+					record[name] = fmt.Sprint(elem.MapIndex(key).Interface())
+				}
+			}
+			records = append(records, record)
+		}
+		for _, record := range records {
+			row := make([]string, len(table.Headers))
+			for name, value := range record {
+				row[columns[name]] = value
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	default:
+		return nil, fmt.Errorf("output: unsupported table element type %v", elemType)
+	}
+	return table, nil
+}
@@ -0,0 +1,65 @@
+package output
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// wideRanges 终端中占用两个字符宽度的unicode区间(东亚宽字符以及全角字符)
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},   // 谚文字母
+	{0x2E80, 0x303E},   // CJK部首、康熙部首、CJK符号和标点
+	{0x3041, 0x33FF},   // 平假名、片假名、注音、CJK兼容字符
+	{0x3400, 0x4DBF},   // CJK扩展A
+	{0x4E00, 0x9FFF},   // CJK统一汉字
+	{0xA000, 0xA4CF},   // 彝文
+	{0xAC00, 0xD7A3},   // 谚文音节
+	{0xF900, 0xFAFF},   // CJK兼容汉字
+	{0xFE30, 0xFE4F},   // CJK兼容形式
+	{0xFF00, 0xFF60},   // 全角ASCII、全角标点
+	{0xFFE0, 0xFFE6},   // 全角符号
+	{0x1F300, 0x1F64F}, // emoji
+	{0x1F900, 0x1F9FF}, // emoji补充
+	{0x20000, 0x2FFFD}, // CJK扩展B-F
+	{0x30000, 0x3FFFD}, // CJK扩展G
+}
+
+// RuneWidth returns the number of terminal columns occupied by r
+/*
+ * 计算字符在终端中占用的列数
+ * @param r：字符
+ * @return 控制字符以及组合字符返回0，东亚宽字符返回2，其余返回1
+ */
+func RuneWidth(r rune) int {
+	if r == 0 || r < 32 || (r >= 0x7F && r < 0xA0) {
+		return 0
+	}
+	if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || r == 0x200B {
+		return 0
+	}
+	if r < 0x1100 {
+		return 1
+	}
+	for _, wide := range wideRanges {
+		if r >= wide.lo && r <= wide.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// StringWidth returns the number of terminal columns occupied by s
+/*
+ * 计算字符串在终端中占用的列数，中文等宽字符按两列计算
+ * @param s：字符串
+ * @return 占用的列数
+ */
+func StringWidth(s string) int {
+	width := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		width += RuneWidth(r)
+		s = s[size:]
+	}
+	return width
+}
@@ -0,0 +1,215 @@
+package output
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteYAML renders v as a YAML document
+/*
+ * 将数据输出为YAML格式，仅支持输出，不依赖第三方库
+ * struct字段名优先使用`yaml`标签，其次使用`json`标签，标签为"-"的字段不输出，支持omitempty
+ * map按照key排序输出，保证输出稳定
+ * @param w：输出目标
+ * @param v：待输出的数据
+ * @return 写入失败时返回error
+ */
+func WriteYAML(w io.Writer, v interface{}) error {
+	var sb strings.Builder
+	writeYAMLValue(&sb, reflect.ValueOf(v), 0, false)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+/*
+ * 输出一个YAML值
+ * @param indent：当前缩进层级
+ * @param inline：当前值是否跟在"key:"或者"- "之后
+ */
+func writeYAMLValue(sb *strings.Builder, v reflect.Value, indent int, inline bool) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	if scalar, ok := yamlScalar(v); ok {
+		if inline {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(scalar)
+		sb.WriteString("\n")
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := yamlStructFields(v)
+		if len(fields) == 0 {
+			writeInlineEmpty(sb, inline, "{}")
+			return
+		}
+		if inline {
+			sb.WriteString("\n")
+		}
+		for _, field := range fields {
+			writeYAMLKey(sb, field.name, indent)
+			writeYAMLValue(sb, field.value, indent+1, true)
+		}
+	case reflect.Map:
+		if v.Len() == 0 {
+			writeInlineEmpty(sb, inline, "{}")
+			return
+		}
+		if inline {
+			sb.WriteString("\n")
+		}
+		for _, key := range sortedKeys(v) {
+			writeYAMLKey(sb, fmt.Sprint(key.Interface()), indent)
+			writeYAMLValue(sb, v.MapIndex(key), indent+1, true)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			writeInlineEmpty(sb, inline, "[]")
+			return
+		}
+		if inline {
+			sb.WriteString("\n")
+		}
+		for i := 0; i < v.Len(); i++ {
+			sb.WriteString(strings.Repeat("  ", indent))
+			sb.WriteString("-")
+			writeYAMLValue(sb, v.Index(i), indent+1, true)
+		}
+	default:
+		writeInlineEmpty(sb, inline, quoteYAML(fmt.Sprint(v.Interface())))
+	}
+}
+
+func writeInlineEmpty(sb *strings.Builder, inline bool, s string) {
+	if inline {
+		sb.WriteString(" ")
+	}
+	sb.WriteString(s)
+	sb.WriteString("\n")
+}
+
+func writeYAMLKey(sb *strings.Builder, key string, indent int) {
+	sb.WriteString(strings.Repeat("  ", indent))
+	sb.WriteString(quoteYAML(key))
+	sb.WriteString(":")
+}
+
+/*
+ * 将标量转换为YAML表示
+ * @return (YAML表示, true)；非标量返回("", false)
+ */
+func yamlScalar(v reflect.Value) (string, bool) {
+	if !v.IsValid() {
+		return "null", true
+	}
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface || v.Kind() == reflect.Map || v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			return "null", true
+		}
+	}
+	if v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case time.Time:
+			return value.Format(time.RFC3339Nano), true
+		case time.Duration:
+			return value.String(), true
+		case encoding.TextMarshaler:
+			if text, err := value.MarshalText(); err == nil {
+				return quoteYAML(string(text)), true
+			}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), true
+	case reflect.String:
+		return quoteYAML(v.String()), true
+	}
+	return "", false
+}
+
+type yamlField struct {
+	name  string
+	value reflect.Value
+}
+
+func yamlStructFields(v reflect.Value) []yamlField {
+	var fields []yamlField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get("yaml")
+		if tag == "" {
+			tag = field.Tag.Get("json")
+		}
+		parts := strings.Split(tag, ",")
+		if parts[0] == "-" {
+			continue
+		}
+		name := parts[0]
+		if name == "" {
+			name = field.Name
+		}
+		omitEmpty := false
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		value := v.Field(i)
+		if omitEmpty && value.IsZero() {
+			continue
+		}
+		fields = append(fields, yamlField{name: name, value: value})
+	}
+	return fields
+}
+
+/*
+ * 必要时为字符串添加引号，避免被YAML解析为其他类型或者破坏文档结构
+ */
+func quoteYAML(s string) string {
+	if s == "" {
+		return `""`
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	if strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`\n\t\\") || strings.TrimSpace(s) != s || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "?") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
@@ -0,0 +1,70 @@
+package pool
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// maxAdminBody Handler请求内容的最大长度
+const maxAdminBody = 256
+
+// Handler returns an admin http.Handler to query and resize the pool
+/*
+ * 协程池管理接口，参数可以放在URL或者表单中
+ * GET返回JSON格式的Stats；PUT/POST调整容量：workers为工作协程数，参考Resize；queue为等待队列长度，参考ResizeQueue；
+ * 两个参数都可以省略，省略时不调整
+ * 可以挂载到日志的控制socket，例如：
+ *   log.ServeControl("/run/app/control.sock", logger.ControlHandler{Pattern: "/pool/rpc", Handler: p.Handler()})
+ *   curl --unix-socket /run/app/control.sock -X PUT 'http://localhost/pool/rpc?workers=64&queue=8192'
+ */
+func (p *Pool) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, maxAdminBody)
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			workers, err := parseSize(r.Form.Get("workers"))
+			if err != nil {
+				http.Error(w, "invalid workers "+strconv.Quote(r.Form.Get("workers")), http.StatusBadRequest)
+				return
+			}
+			queue, err := parseSize(r.Form.Get("queue"))
+			if err != nil {
+				http.Error(w, "invalid queue "+strconv.Quote(r.Form.Get("queue")), http.StatusBadRequest)
+				return
+			}
+			if workers > 0 {
+				p.Resize(workers)
+			}
+			if queue > 0 {
+				p.ResizeQueue(queue)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Stats())
+	})
+}
+
+/*
+ * 解析Handler的容量参数
+ * @return 为空时返回0；不是正整数时返回error
+ */
+func parseSize(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err == nil && n <= 0 {
+		err = strconv.ErrRange
+	}
+	return n, err
+}
@@ -0,0 +1,49 @@
+package pool
+
+import (
+	"fmt"
+)
+
+// OverflowPolicy decides what Submit does when the queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the queue has room, the default
+	OverflowBlock OverflowPolicy = iota
+	// OverflowReject fails the submit with ErrQueueFull
+	OverflowReject
+	// OverflowDropOldest discards the oldest queued task to make room
+	OverflowDropOldest
+	// OverflowCallerRuns runs the task in the goroutine calling Submit
+	OverflowCallerRuns
+)
+
+// String returns the name of the policy
+func (policy OverflowPolicy) String() string {
+	switch policy {
+	case OverflowBlock:
+		return "block"
+	case OverflowReject:
+		return "reject"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowCallerRuns:
+		return "caller-runs"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(policy))
+}
+
+// ParseOverflowPolicy parses block, reject, drop-oldest or caller-runs
+/*
+ * 解析队列满时的处理方式，用于配置文件以及命令行参数
+ * @param name：block、reject、drop-oldest或者caller-runs
+ * @return 名称不合法时返回error
+ */
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	for _, policy := range []OverflowPolicy{OverflowBlock, OverflowReject, OverflowDropOldest, OverflowCallerRuns} {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+	return OverflowBlock, fmt.Errorf("pool: unknown overflow policy %q", name)
+}
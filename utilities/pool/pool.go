@@ -0,0 +1,410 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+	"github.com/lucifinil-long/nano-legion/utilities/metrics"
+)
+
+var (
+	// ErrStopped is returned by Submit after Stop
+	ErrStopped = errors.New("pool: stopped")
+	// ErrQueueFull is returned by Submit when the queue is full and the policy is OverflowReject
+	ErrQueueFull = errors.New("pool: queue full")
+	// ErrDropped is returned by SubmitWait when its task was discarded by OverflowDropOldest or Stop
+	ErrDropped = errors.New("pool: task dropped")
+)
+
+// PanicError is returned by SubmitWait when the task panicked
+type PanicError struct {
+	Value interface{} // panic的值
+	Stack []byte      // panic位置的调用栈
+}
+
+// Error implements error
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("pool: task panic: %v", e.Value)
+}
+
+// Options configures a Pool
+type Options struct {
+	Name      string            // 名称，用于日志以及指标名称 pool_<Name>_xxx，只能包含字母、数字以及下划线，为空时为default
+	Workers   int               // 工作协程数，<=0时为CPU核数
+	QueueSize int               // 等待队列长度，<=0时为Workers*64
+	Overflow  OverflowPolicy    // 队列满时的处理方式，默认阻塞等待
+	Logger    *logger.Logger    // 记录任务panic以及丢弃的任务，可以为nil
+	Metrics   *metrics.Registry // 注册指标的注册表，为nil时使用metrics.Default()
+}
+
+// Stats is a snapshot of the pool counters
+type Stats struct {
+	Workers   int    // 当前工作协程数，Resize减少协程数之后包括尚未退出的协程
+	Target    int    // Resize设置的工作协程数
+	Queued    int    // 等待执行的任务数
+	QueueSize int    // 等待队列长度
+	Running   int    // 正在执行的任务数
+	Completed uint64 // 执行完成的任务数，包括panic的任务
+	Panics    uint64 // panic的任务数
+	Dropped   uint64 // 被拒绝或者丢弃的任务数
+}
+
+// task 队列中的任务
+type task struct {
+	fn       func() error
+	done     chan error // SubmitWait等待的结果，Submit时为nil
+	enqueued time.Time
+}
+
+// Pool is a fixed size goroutine pool with a bounded queue
+/*
+ * 协程池：固定数量的工作协程从有界队列中取任务执行，每个任务的panic单独捕获，不影响工作协程以及其他任务
+ * 指标(注册到Options.Metrics)：
+ *   pool_<name>_queue_depth    等待执行的任务数
+ *   pool_<name>_workers        工作协程数
+ *   pool_<name>_wait_seconds   任务在队列中的等待时间
+ *   pool_<name>_run_seconds    任务的执行时间
+ *   pool_<name>_tasks_total    执行完成的任务数
+ *   pool_<name>_panics_total   panic的任务数
+ *   pool_<name>_dropped_total  被拒绝或者丢弃的任务数
+ * 同名的协程池共用指标
+ */
+type Pool struct {
+	opts Options
+
+	lock     sync.Mutex
+	notEmpty *sync.Cond // 有新任务或者需要减少工作协程
+	notFull  *sync.Cond // 队列有空位，OverflowBlock的Submit等待
+	queue    []*task
+	workers  int // 当前工作协程数
+	target   int // Resize设置的工作协程数
+	running  int
+	stopped  bool
+	exited   chan struct{} // 全部工作协程退出时关闭
+
+	completed uint64
+	panics    uint64
+	dropped   uint64
+
+	waitTime *metrics.Histogram
+	runTime  *metrics.Histogram
+	tasks    *metrics.Counter
+	panicked *metrics.Counter
+	drops    *metrics.Counter
+}
+
+// New creates a pool and starts its workers
+/*
+ * 创建协程池并启动工作协程，不再使用时需要调用Stop，例如注册为关闭钩子：process.OnShutdown(p.Stop)
+ * @param opts：配置
+ * @return 协程池
+ */
+func New(opts Options) *Pool {
+	if opts.Name == "" {
+		opts.Name = "default"
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = opts.Workers * 64
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = metrics.Default()
+	}
+	p := &Pool{
+		opts:   opts,
+		target: opts.Workers,
+		exited: make(chan struct{}),
+	}
+	p.notEmpty = sync.NewCond(&p.lock)
+	p.notFull = sync.NewCond(&p.lock)
+
+	prefix := "pool_" + opts.Name + "_"
+	reg := opts.Metrics
+	reg.GaugeFunc(prefix+"queue_depth", "Tasks waiting in the queue.", func() float64 {
+		return float64(p.Stats().Queued)
+	})
+	reg.GaugeFunc(prefix+"workers", "Worker goroutines.", func() float64 {
+		return float64(p.Stats().Workers)
+	})
+	p.waitTime = reg.Histogram(prefix+"wait_seconds", "Time tasks spent in the queue.", nil)
+	p.runTime = reg.Histogram(prefix+"run_seconds", "Time tasks spent running.", nil)
+	p.tasks = reg.Counter(prefix+"tasks_total", "Tasks completed, including panicked ones.")
+	p.panicked = reg.Counter(prefix+"panics_total", "Tasks that panicked.")
+	p.drops = reg.Counter(prefix+"dropped_total", "Tasks rejected or dropped.")
+
+	p.lock.Lock()
+	p.spawn(opts.Workers)
+	p.lock.Unlock()
+	return p
+}
+
+// Submit queues fn for execution
+/*
+ * 提交任务，不等待执行结果；队列满时按照Options.Overflow处理
+ * @param fn：任务
+ * @return 已经Stop时返回ErrStopped；队列满并且为OverflowReject时返回ErrQueueFull
+ */
+func (p *Pool) Submit(fn func()) error {
+	return p.submit(&task{fn: func() error {
+		fn()
+		return nil
+	}})
+}
+
+// SubmitWait queues fn and waits for its result
+/*
+ * 提交任务并等待执行完成
+ * @param ctx：等待的context，取消时返回ctx.Err()，任务已经在队列中时仍会执行
+ * @param fn：任务
+ * @return 任务的返回值；任务panic时返回*PanicError；任务被丢弃时返回ErrDropped；以及Submit的错误
+ */
+func (p *Pool) SubmitWait(ctx context.Context, fn func() error) error {
+	t := &task{fn: fn, done: make(chan error, 1)}
+	if err := p.submit(t); err != nil {
+		return err
+	}
+	select {
+	case err := <-t.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+ * 将任务加入队列
+ */
+func (p *Pool) submit(t *task) error {
+	p.lock.Lock()
+	for !p.stopped && len(p.queue) >= p.opts.QueueSize {
+		switch p.opts.Overflow {
+		case OverflowReject:
+			p.lock.Unlock()
+			p.drop(t, ErrQueueFull)
+			return ErrQueueFull
+		case OverflowDropOldest:
+			oldest := p.queue[0]
+			p.queue[0] = nil
+			p.queue = p.queue[1:]
+			p.lock.Unlock()
+			p.drop(oldest, ErrDropped)
+			p.lock.Lock()
+		case OverflowCallerRuns:
+			p.lock.Unlock()
+			t.enqueued = time.Now()
+			p.run(t)
+			return nil
+		default:
+			p.notFull.Wait()
+		}
+	}
+	if p.stopped {
+		p.lock.Unlock()
+		return ErrStopped
+	}
+	t.enqueued = time.Now()
+	p.queue = append(p.queue, t)
+	p.notEmpty.Signal()
+	p.lock.Unlock()
+	return nil
+}
+
+/*
+ * 记录被拒绝或者丢弃的任务，通知SubmitWait
+ */
+func (p *Pool) drop(t *task, err error) {
+	p.lock.Lock()
+	p.dropped++
+	p.lock.Unlock()
+	p.drops.Inc()
+	if t.done != nil {
+		t.done <- err
+	}
+	if p.opts.Logger != nil && err == ErrDropped {
+		p.opts.Logger.Warn("pool", p.opts.Name, "task dropped")
+	}
+}
+
+// Resize changes the number of workers
+/*
+ * 调整工作协程数，增加时立即启动；减少时多余的协程执行完当前任务之后退出
+ * 已经Stop时不做任何操作。运行时调整可以通过Handler挂载到控制socket，或者在配置热加载时调用，例如：
+ *   store.Subscribe(func(old, new *Config) {
+ *       p.Resize(new.Workers)
+ *       p.ResizeQueue(new.QueueSize)
+ *   })
+ * @param workers：工作协程数，<=0时为1
+ */
+func (p *Pool) Resize(workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopped {
+		return
+	}
+	p.target = workers
+	if workers > p.workers {
+		p.spawn(workers - p.workers)
+	} else {
+		p.notEmpty.Broadcast()
+	}
+}
+
+// ResizeQueue changes the queue bound
+/*
+ * 调整等待队列长度，增加时唤醒因为队列满而阻塞的Submit；
+ * 减少时已经在队列中的任务不受影响，队列中的任务数降到新长度以下之前，新的Submit按照Options.Overflow处理
+ * 已经Stop时不做任何操作
+ * @param size：队列长度，<=0时为Resize设置的工作协程数*64
+ */
+func (p *Pool) ResizeQueue(size int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopped {
+		return
+	}
+	if size <= 0 {
+		size = p.target * 64
+	}
+	p.opts.QueueSize = size
+	p.notFull.Broadcast()
+}
+
+/*
+ * 启动n个工作协程，需要持有锁
+ */
+func (p *Pool) spawn(n int) {
+	p.workers += n
+	for i := 0; i < n; i++ {
+		go p.worker()
+	}
+}
+
+/*
+ * 工作协程：取任务执行，队列为空时等待；Stop之后执行完队列中的任务再退出
+ */
+func (p *Pool) worker() {
+	p.lock.Lock()
+	for {
+		for len(p.queue) == 0 && !p.stopped && p.workers <= p.target {
+			p.notEmpty.Wait()
+		}
+		if p.workers > p.target || len(p.queue) == 0 {
+			// Resize减少了协程数，或者已经Stop并且队列为空
+			p.workers--
+			if p.workers == 0 && p.stopped {
+				close(p.exited)
+			}
+			p.lock.Unlock()
+			return
+		}
+		t := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.running++
+		p.notFull.Signal()
+		p.lock.Unlock()
+
+		p.run(t)
+
+		p.lock.Lock()
+		p.running--
+	}
+}
+
+/*
+ * 执行一个任务，捕获panic并记录指标
+ */
+func (p *Pool) run(t *task) {
+	start := time.Now()
+	p.waitTime.Observe(start.Sub(t.enqueued).Seconds())
+	err := p.call(t.fn)
+	p.runTime.Since(start)
+	p.tasks.Inc()
+	p.lock.Lock()
+	p.completed++
+	p.lock.Unlock()
+	if t.done != nil {
+		t.done <- err
+	}
+}
+
+/*
+ * 调用任务函数，panic转换为*PanicError并写入error日志
+ */
+func (p *Pool) call(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr := &PanicError{Value: r, Stack: debug.Stack()}
+			err = panicErr
+			p.lock.Lock()
+			p.panics++
+			p.lock.Unlock()
+			p.panicked.Inc()
+			if p.opts.Logger != nil {
+				p.opts.Logger.Error("pool", p.opts.Name, "task panic", fmt.Sprint(r), string(panicErr.Stack))
+			}
+		}
+	}()
+	return fn()
+}
+
+// Stop stops accepting tasks and waits for the queued ones to finish
+/*
+ * 停止协程池：之后Submit返回ErrStopped，阻塞中的Submit同样返回ErrStopped；
+ * 工作协程执行完队列中的全部任务之后退出。签名与关闭钩子一致，可以直接注册：process.OnShutdown(p.Stop)
+ * @param ctx：等待的context，取消时丢弃队列中尚未开始的任务(SubmitWait返回ErrDropped)，不再等待正在执行的任务
+ * @return 全部任务完成时返回nil；否则返回ctx.Err()
+ */
+func (p *Pool) Stop(ctx context.Context) error {
+	p.lock.Lock()
+	if !p.stopped {
+		p.stopped = true
+		if p.workers == 0 {
+			close(p.exited)
+		}
+		p.notEmpty.Broadcast()
+		p.notFull.Broadcast()
+	}
+	p.lock.Unlock()
+
+	select {
+	case <-p.exited:
+		return nil
+	case <-ctx.Done():
+	}
+	p.lock.Lock()
+	queue := p.queue
+	p.queue = nil
+	p.lock.Unlock()
+	for _, t := range queue {
+		p.drop(t, ErrDropped)
+	}
+	return ctx.Err()
+}
+
+// Stats returns a snapshot of the pool counters
+func (p *Pool) Stats() Stats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return Stats{
+		Workers:   p.workers,
+		Target:    p.target,
+		Queued:    len(p.queue),
+		QueueSize: p.opts.QueueSize,
+		Running:   p.running,
+		Completed: p.completed,
+		Panics:    p.panics,
+		Dropped:   p.dropped,
+	}
+}
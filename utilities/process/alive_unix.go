@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"syscall"
+)
+
+/*
+ * 检查进程是否存在
+ * @param pid：进程id
+ * @return 返回true表示进程存在
+ */
+func pidAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	// EPERM表示进程存在，只是没有权限向其发送信号
+	return err == nil || err == syscall.EPERM
+}
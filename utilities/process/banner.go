@@ -0,0 +1,266 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+	"github.com/lucifinil-long/nano-legion/utilities/netutil"
+)
+
+// redactedValue 替换敏感配置项时使用的占位内容
+const redactedValue = "******"
+
+// secretKeywords 配置项名称中包含以下关键字(忽略大小写)时视为敏感信息
+var secretKeywords = []string{"password", "passwd", "secret", "token", "credential", "privatekey", "apikey"}
+
+// BannerItem is a key/value pair of the startup banner
+type BannerItem struct {
+	Key   string
+	Value string
+}
+
+// StartupBanner collects the state snapshot logged when the process starts
+type StartupBanner struct {
+	Identity []BannerItem // 进程身份信息：主机名、pid、二进制目录、内网IP等
+	Build    []BannerItem // 版本以及编译信息
+	Limits   []BannerItem // 探测到的资源限制
+	Config   []BannerItem // 生效的配置，敏感项已脱敏
+	Env      []BannerItem // 选择输出的环境变量，敏感项已脱敏，参考StartupOptions
+}
+
+// NewStartupBanner builds the startup banner
+/*
+ * 采集进程启动时的状态快照
+ * @param cfg: 当前生效的配置对象(一般为struct或者其指针)，可以为nil;
+ *             字段名包含password/secret/token等关键字，或者带有`banner:"secret"`标签的字段会被脱敏
+ * @return 采集到的启动信息
+ */
+func NewStartupBanner(cfg interface{}) *StartupBanner {
+	banner := &StartupBanner{}
+
+	hostname, _ := os.Hostname()
+	binDir, err := GetProcessBinaryDir()
+	if err != nil {
+		binDir = "unknown(" + err.Error() + ")"
+	}
+	banner.Identity = []BannerItem{
+		{"hostname", hostname},
+		{"pid", fmt.Sprint(os.Getpid())},
+		{"binary_dir", binDir},
+		{"binary", filepath.Base(os.Args[0])},
+		{"inner_ip", netutil.InnerIP()},
+		{"args", redactArgs(os.Args)},
+	}
+
+	banner.Build = []BannerItem{
+		{"go_version", runtime.Version()},
+		{"os_arch", runtime.GOOS + "/" + runtime.GOARCH},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		banner.Build = append(banner.Build, BannerItem{"module", info.Main.Path}, BannerItem{"version", info.Main.Version})
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision", "vcs.time", "vcs.modified":
+				banner.Build = append(banner.Build, BannerItem{setting.Key, setting.Value})
+			}
+		}
+	}
+
+	banner.Limits = []BannerItem{
+		{"num_cpu", fmt.Sprint(runtime.NumCPU())},
+		{"gomaxprocs", fmt.Sprint(runtime.GOMAXPROCS(0))},
+	}
+	banner.Limits = append(banner.Limits, rlimitItems()...)
+	banner.Limits = append(banner.Limits, cgroupItems()...)
+
+	if cfg != nil {
+		flattenConfig("", reflect.ValueOf(cfg), false, &banner.Config)
+	}
+	return banner
+}
+
+// Log writes the banner to l through the trace level
+/*
+ * 以结构化的形式输出启动信息，每个分组一条记录，格式为 section|key=value|key=value...
+ * @param l: 日志对象
+ */
+func (banner *StartupBanner) Log(l *logger.Logger) {
+	sections := []struct {
+		name  string
+		items []BannerItem
+	}{
+		{"identity", banner.Identity},
+		{"build", banner.Build},
+		{"limits", banner.Limits},
+		{"config", banner.Config},
+		{"env", banner.Env},
+	}
+	for _, section := range sections {
+		if section.name == "env" && len(section.items) == 0 {
+			continue
+		}
+		args := []interface{}{"startup", section.name}
+		for _, item := range section.items {
+			args = append(args, item.Key+"="+item.Value)
+		}
+		l.Trace(args...)
+	}
+}
+
+// LogStartupBanner collects and logs the startup banner
+/*
+ * 采集并输出启动信息，通常在main函数完成配置加载之后调用
+ * @param l: 日志对象
+ * @param cfg: 当前生效的配置对象，可以为nil
+ */
+func LogStartupBanner(l *logger.Logger, cfg interface{}) {
+	NewStartupBanner(cfg).Log(l)
+}
+
+/*
+ * 将配置对象展开为key=value列表，切片、数组以及map中的元素同样展开，例如 Upstreams[0].Password
+ * time.Time以及实现了fmt.Stringer的类型作为一个整体输出
+ * @param prefix: 当前字段路径前缀
+ * @param v: 当前字段值
+ * @param secret: 上层字段是否已经被判定为敏感字段
+ * @param items: 输出列表
+ */
+func flattenConfig(prefix string, v reflect.Value, secret bool, items *[]BannerItem) {
+	for !isConfigLeaf(v) && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			*items = append(*items, BannerItem{prefix, "<nil>"})
+			return
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		*items = append(*items, BannerItem{prefix, "<nil>"})
+		return
+	}
+
+	switch {
+	case isConfigLeaf(v):
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				// 未导出字段不输出
+				continue
+			}
+			name := field.Name
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			fieldSecret := secret || field.Tag.Get("banner") == "secret" || isSecretKey(field.Name)
+			flattenConfig(name, v.Field(i), fieldSecret, items)
+		}
+		return
+	case v.Kind() == reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			keyName := fmt.Sprint(key.Interface())
+			name := keyName
+			if prefix != "" {
+				name = prefix + "." + keyName
+			}
+			flattenConfig(name, v.MapIndex(key), secret || isSecretKey(keyName), items)
+		}
+		return
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8:
+		if v.Len() == 0 {
+			// 空切片同样输出，便于确认配置已经加载
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			flattenConfig(fmt.Sprintf("%s[%d]", prefix, i), v.Index(i), secret, items)
+		}
+		return
+	}
+
+	if secret {
+		*items = append(*items, BannerItem{prefix, redactedValue})
+		return
+	}
+	*items = append(*items, BannerItem{prefix, fmt.Sprintf("%v", v.Interface())})
+}
+
+// stringerType fmt.Stringer的类型，用于判断配置项是否直接输出
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+/*
+ * 判断配置项是否作为一个整体输出，不再展开
+ * @param v: 字段值
+ * @return time.Time以及实现了fmt.Stringer的类型返回true
+ */
+func isConfigLeaf(v reflect.Value) bool {
+	if !v.IsValid() || !v.CanInterface() {
+		return false
+	}
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		// nil指针调用String会panic，由调用方按照<nil>输出
+		if v.IsNil() {
+			return false
+		}
+	}
+	t := v.Type()
+	return t == reflect.TypeOf(time.Time{}) || t.Implements(stringerType)
+}
+
+/*
+ * 判断配置项名称是否为敏感信息
+ * @param name: 配置项名称
+ * @return 返回true表示需要脱敏
+ */
+func isSecretKey(name string) bool {
+	lower := strings.ToLower(strings.Replace(strings.Replace(name, "_", "", -1), "-", "", -1))
+	for _, keyword := range secretKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * 对命令行参数脱敏，名称为敏感信息的flag取值替换为占位内容
+ * 支持 -name=value、--name=value 以及 -name value、--name value 的形式，"--"之后的参数不再处理
+ * @param args: 命令行参数，第一个为程序名
+ * @return 以空格连接的参数
+ */
+func redactArgs(args []string) string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 1; i < len(redacted); i++ {
+		arg := redacted[i]
+		if arg == "--" {
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if eq := strings.IndexByte(name, '='); eq >= 0 {
+			if isSecretKey(name[:eq]) {
+				redacted[i] = arg[:len(arg)-len(name)+eq+1] + redactedValue
+			}
+			continue
+		}
+		if isSecretKey(name) && i+1 < len(redacted) && !strings.HasPrefix(redacted[i+1], "-") {
+			i++
+			redacted[i] = redactedValue
+		}
+	}
+	return strings.Join(redacted, " ")
+}
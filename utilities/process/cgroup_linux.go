@@ -0,0 +1,257 @@
+package process
+
+import (
+	"bufio"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// cgroupMount 当前进程所在的一个cgroup层级
+type cgroupMount struct {
+	version     int      // 1或者2
+	mountPoint  string   // 挂载点，例如/sys/fs/cgroup/memory
+	root        string   // 挂载的层级根路径，容器内一般为/
+	controllers []string // v1层级的控制器，例如cpu,cpuacct
+}
+
+/*
+ * 读取cgroup v1/v2的CPU配额以及内存上限，v2挂载点启用了对应控制器时优先使用v2
+ * 同时检查当前cgroup及其所有上级目录，取最小的限制
+ */
+func detectLimits(limits *Limits) {
+	if info := new(syscall.Sysinfo_t); syscall.Sysinfo(info) == nil {
+		limits.MemoryTotal = int64(info.Totalram) * int64(info.Unit)
+	}
+	mounts := cgroupMounts()
+	paths := cgroupPaths()
+
+	if dir, mount := cgroupDir(mounts, paths, "cpu"); dir != "" {
+		limits.Cgroup = mount.version
+		limits.CPUQuota = walkCgroup(dir, mount.mountPoint, math.Inf(1), func(dir string) (float64, bool) {
+			if mount.version == 2 {
+				return cpuQuotaV2(dir)
+			}
+			return cpuQuotaV1(dir)
+		})
+		if math.IsInf(limits.CPUQuota, 1) {
+			limits.CPUQuota = 0
+		}
+	}
+	if dir, mount := cgroupDir(mounts, paths, "memory"); dir != "" {
+		limits.Cgroup = mount.version
+		memory := walkCgroup(dir, mount.mountPoint, math.Inf(1), func(dir string) (float64, bool) {
+			if mount.version == 2 {
+				return memoryLimitV2(dir)
+			}
+			return memoryLimitV1(dir)
+		})
+		if !math.IsInf(memory, 1) {
+			limits.MemoryLimit = int64(memory)
+		}
+	}
+}
+
+/*
+ * 从当前cgroup目录向上直到挂载点，取所有限制中最小的
+ * @param dir：当前cgroup目录
+ * @param top：挂载点
+ * @param min：初始值
+ * @param read：读取一个目录的限制，没有限制时返回false
+ */
+func walkCgroup(dir, top string, min float64, read func(dir string) (float64, bool)) float64 {
+	for {
+		if value, ok := read(dir); ok && value < min {
+			min = value
+		}
+		if dir == top || len(dir) <= len(top) {
+			return min
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+/*
+ * 获取控制器所在的cgroup目录
+ * @return (目录, 层级)，进程不受该控制器管理时目录为空
+ */
+func cgroupDir(mounts []cgroupMount, paths map[string]string, controller string) (string, cgroupMount) {
+	var found *cgroupMount
+	for i := range mounts {
+		mount := &mounts[i]
+		if mount.version == 2 && strings.Contains(" "+readCgroupFile(filepath.Join(mount.mountPoint, "cgroup.controllers"))+" ", " "+controller+" ") {
+			found = mount
+			break
+		}
+	}
+	if found == nil {
+		for i := range mounts {
+			if mounts[i].version == 1 && hasController(mounts[i].controllers, controller) {
+				found = &mounts[i]
+				break
+			}
+		}
+	}
+	if found == nil {
+		return "", cgroupMount{}
+	}
+
+	key := ""
+	if found.version == 1 {
+		key = controller
+	}
+	path, ok := paths[key]
+	if !ok {
+		return found.mountPoint, *found
+	}
+	// 路径是相对于层级根路径的，容器内挂载的就是自己的cgroup时根路径与路径相同
+	rel := path
+	if found.root != "/" {
+		rel = strings.TrimPrefix(path, found.root)
+	}
+	dir := filepath.Join(found.mountPoint, rel)
+	if _, err := os.Stat(dir); err != nil {
+		dir = found.mountPoint
+	}
+	return dir, *found
+}
+
+/*
+ * 解析/proc/self/mountinfo中的cgroup挂载点
+ */
+func cgroupMounts() []cgroupMount {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var mounts []cgroupMount
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 36 32 0:32 / /sys/fs/cgroup/memory rw,relatime - cgroup cgroup rw,memory
+		line := scanner.Text()
+		sep := strings.Index(line, " - ")
+		if sep < 0 {
+			continue
+		}
+		fields, tail := strings.Fields(line[:sep]), strings.Fields(line[sep+3:])
+		if len(fields) < 5 || len(tail) < 3 {
+			continue
+		}
+		mount := cgroupMount{root: fields[3], mountPoint: fields[4]}
+		switch tail[0] {
+		case "cgroup2":
+			mount.version = 2
+		case "cgroup":
+			mount.version = 1
+			mount.controllers = strings.Split(tail[2], ",")
+		default:
+			continue
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
+
+/*
+ * 解析/proc/self/cgroup，获取每个控制器所在的cgroup路径，v2的key为空字符串
+ */
+func cgroupPaths() map[string]string {
+	data, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return nil
+	}
+	paths := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		// 4:memory:/docker/abc 或者 0::/system.slice/app.service
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" {
+			paths[""] = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths
+}
+
+func hasController(controllers []string, name string) bool {
+	for _, controller := range controllers {
+		if controller == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * cgroup v2的CPU配额，cpu.max格式为"quota period"，没有限制时quota为max
+ */
+func cpuQuotaV2(dir string) (float64, bool) {
+	fields := strings.Fields(readCgroupFile(filepath.Join(dir, "cpu.max")))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false
+	}
+	return cpuQuota(fields[0], fields[1])
+}
+
+/*
+ * cgroup v1的CPU配额，没有限制时cfs_quota_us为-1
+ */
+func cpuQuotaV1(dir string) (float64, bool) {
+	quota := readCgroupFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if quota == "" || strings.HasPrefix(quota, "-") {
+		return 0, false
+	}
+	return cpuQuota(quota, readCgroupFile(filepath.Join(dir, "cpu.cfs_period_us")))
+}
+
+func cpuQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+/*
+ * cgroup v2的内存上限，没有限制时为max
+ */
+func memoryLimitV2(dir string) (float64, bool) {
+	limit, err := strconv.ParseInt(readCgroupFile(filepath.Join(dir, "memory.max")), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return float64(limit), true
+}
+
+/*
+ * cgroup v1的内存上限，没有限制时为接近int64上限的页对齐数值
+ */
+func memoryLimitV1(dir string) (float64, bool) {
+	limit, err := strconv.ParseInt(readCgroupFile(filepath.Join(dir, "memory.limit_in_bytes")), 10, 64)
+	if err != nil || limit >= 1<<62 {
+		return 0, false
+	}
+	return float64(limit), true
+}
+
+func readCgroupFile(name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+/*
+ * 非linux系统没有cgroup，只使用机器的CPU核数
+ */
+func detectLimits(limits *Limits) {
+}
@@ -0,0 +1,156 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/fileutil"
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// maxCrashStackSize 崩溃报告中所有协程调用栈的最大长度
+const maxCrashStackSize = 64 * 1024 * 1024
+
+// CrashHandler turns a panic into a crash report, a synchronous error log and an exit
+type CrashHandler struct {
+	ExitCode ExitCode          // 写入崩溃报告之后的退出码，默认为ExitPanic
+	Repanic  bool              // 为true时写入崩溃报告之后重新panic，由runtime输出调用栈并以退出码2退出，ExitCode不生效
+	OnCrash  func(file string) // 写入崩溃报告之后、退出之前的回调，例如上报告警，file为崩溃报告路径，写入失败时为空
+
+	log     *logger.Logger
+	dumpDir string
+}
+
+// InstallCrashHandler creates the crash handler used by Recover and Go
+/*
+ * 崩溃处理：捕获panic之后
+ *   1. 在dumpDir中写入崩溃报告crash-20060102-150405-<pid>.log，包括panic的值、所有协程的调用栈、
+ *      编译信息、进程信息以及环境变量(名称包含password/secret/token等关键字的值已脱敏)
+ *   2. 同步写入error日志并flush
+ *   3. 以ExitCode退出，或者Repanic为true时重新panic
+ * 退出时不执行OnExit钩子，保留pid文件，下一次启动时CheckPreviousRun(CrashDir: dumpDir)可以找到崩溃报告
+ * go不能捕获其他协程中的panic，main函数以及每个协程都需要通过Recover或者Go保护，例如：
+ *     crash := process.InstallCrashHandler(log, "/data/log/saver/crash")
+ *     defer crash.Recover()
+ *     crash.Go(consume)
+ * @param l：日志对象，可以为nil
+ * @param dumpDir：崩溃报告目录，不存在时在崩溃时创建
+ * @return 崩溃处理对象，可以在返回之后修改ExitCode等字段
+ */
+func InstallCrashHandler(l *logger.Logger, dumpDir string) *CrashHandler {
+	return &CrashHandler{ExitCode: ExitPanic, log: l, dumpDir: dumpDir}
+}
+
+// Recover handles a panic of the current goroutine, for use as `defer crash.Recover()`
+/*
+ * 捕获当前协程的panic并按照崩溃处理，必须直接通过defer调用；没有panic时不做任何操作
+ */
+func (h *CrashHandler) Recover() {
+	if value := recover(); value != nil {
+		h.crash(value)
+	}
+}
+
+// Go runs fn in a new goroutine protected by the crash handler
+func (h *CrashHandler) Go(fn func()) {
+	go func() {
+		defer h.Recover()
+		fn()
+	}()
+}
+
+/*
+ * 写入崩溃报告、日志，之后退出或者重新panic
+ */
+func (h *CrashHandler) crash(value interface{}) {
+	file, err := h.writeReport(value)
+	if h.log != nil {
+		if err != nil {
+			h.log.Error("crash", "panic", value, "write crash report failed", err)
+		} else {
+			h.log.Error("crash", "panic", value, "report", file)
+		}
+		h.log.Flush()
+	}
+	if h.OnCrash != nil {
+		h.OnCrash(file)
+	}
+	if h.Repanic {
+		panic(value)
+	}
+	os.Exit(int(h.ExitCode))
+}
+
+/*
+ * 写入崩溃报告
+ * @return (崩溃报告路径, error)
+ */
+func (h *CrashHandler) writeReport(value interface{}) (string, error) {
+	if err := os.MkdirAll(h.dumpDir, DefaultPidDirMode); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "panic: %v\n", value)
+	fmt.Fprintf(&buf, "time: %s\n", now.Format(time.RFC3339Nano))
+
+	banner := NewStartupBanner(nil)
+	for _, section := range []struct {
+		name  string
+		items []BannerItem
+	}{{"process", banner.Identity}, {"build", banner.Build}, {"limits", banner.Limits}, {"environment", crashEnviron()}} {
+		fmt.Fprintf(&buf, "\n[%s]\n", section.name)
+		for _, item := range section.items {
+			fmt.Fprintf(&buf, "%s=%s\n", item.Key, item.Value)
+		}
+	}
+	buf.WriteString("\n[goroutines]\n")
+	buf.Write(allStacks())
+
+	name := fmt.Sprintf("%s%s-%d.log", crashFilePrefix, now.Format("20060102-150405"), os.Getpid())
+	file := filepath.Join(h.dumpDir, name)
+	if err := fileutil.AtomicWriteFile(file, buf.Bytes(), DefaultPidFileMode); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+/*
+ * 所有协程的调用栈，缓冲区不够时加倍，最大64MB
+ */
+func allStacks() []byte {
+	buf := make([]byte, 1024*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxCrashStackSize {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+/*
+ * 环境变量，敏感项脱敏，按照名称排序
+ */
+func crashEnviron() []BannerItem {
+	env := os.Environ()
+	items := make([]BannerItem, 0, len(env))
+	for _, kv := range env {
+		key, value := kv, ""
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			key, value = kv[:i], kv[i+1:]
+		}
+		if isSecretKey(key) {
+			value = redactedValue
+		}
+		items = append(items, BannerItem{key, value})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items
+}
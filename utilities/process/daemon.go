@@ -0,0 +1,44 @@
+package process
+
+import (
+	"errors"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// daemonEnv 后台进程重新执行自身时传递阶段的环境变量，1为中间进程，2为最终的后台进程
+const daemonEnv = "NANO_LEGION_DAEMON"
+
+// daemonReadyFd 通知前台进程后台进程已经就绪的管道，通过ExtraFiles传递，固定为3
+const daemonReadyFd = 3
+
+// defaultDaemonUmask 未设置DaemonOptions.Umask时使用的umask
+const defaultDaemonUmask = 0022
+
+// ErrDaemonUnsupported is returned by Daemonize on platforms other than linux
+var ErrDaemonUnsupported = errors.New("daemonize is only supported on linux")
+
+// DaemonOptions configures Daemonize
+type DaemonOptions struct {
+	PidFile string // pid文件，通过LockPidFile加锁写入，为空表示不写
+	WorkDir string // 工作目录，为空时为"/"
+	Umask   int    // 文件创建掩码，0表示0022，-1表示不修改
+	Stdout  string // 标准输出重定向的文件，以追加方式打开，为空表示/dev/null
+	Stderr  string // 标准错误重定向的文件，为空表示/dev/null
+	// 不为nil时标准输出以及标准错误的每一行写入日志，优先于Stdout/Stderr，用于记录第三方库以及runtime的输出
+	Logger      *logger.Logger
+	StdoutLevel string   // 标准输出写入的级别，默认trace
+	StderrLevel string   // 标准错误写入的级别，默认error
+	Env         []string // 后台进程额外的环境变量，格式为key=value
+}
+
+// daemonized 当前进程是否为Daemonize启动的后台进程
+var daemonized bool
+
+// IsDaemon reports whether the current process was started in the background by Daemonize
+/*
+ * 判断当前进程是否为Daemonize启动的后台进程，需要在Daemonize返回之后调用
+ */
+func IsDaemon() bool {
+	return daemonized
+}
@@ -0,0 +1,216 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// daemonReady 后台进程就绪时写入通知管道的内容，其余内容为错误信息
+const daemonReady = "ok"
+
+// Daemonize moves the process into the background
+/*
+ * 后台运行：通过两次重新执行自身(与double fork等价，Go程序不能安全地fork)使进程脱离控制终端：
+ *   1. 前台进程以新会话启动中间进程，等待后台进程就绪之后以退出码0退出；后台进程启动失败时返回error，前台进程继续运行
+ *   2. 中间进程(会话首进程)启动最终的后台进程之后立即退出，后台进程不是会话首进程，不会再获得控制终端
+ *   3. 后台进程设置umask、工作目录，重定向标准输入输出，写入pid文件，之后通知前台进程并返回
+ * 需要在main函数开头、创建协程以及打开文件之前调用，三个进程都会从main函数开始执行到Daemonize；
+ * 命令行参数原样传递，相对路径的参数在工作目录修改之后会失效，建议在调用之前转换为绝对路径
+ * 例如:
+ *     lock, err := process.Daemonize(process.DaemonOptions{PidFile: "/var/run/saver.pid", Stderr: "/data/log/saver/stderr.log"})
+ *     if err != nil { fmt.Fprintln(os.Stderr, err); os.Exit(int(process.ExitFailure)) }
+ *     defer lock.Release()
+ * @param opts：后台运行选项
+ * @return 在后台进程中返回(pid文件锁, nil)，未设置PidFile时锁为nil；pid文件已经被其他实例锁定时前台进程返回*AlreadyRunningError
+ */
+func Daemonize(opts DaemonOptions) (*PidLock, error) {
+	switch os.Getenv(daemonEnv) {
+	case "1":
+		return nil, daemonIntermediate()
+	case "2":
+		return daemonFinal(opts)
+	}
+	return nil, daemonParent(opts)
+}
+
+/*
+ * 前台进程：启动中间进程，等待后台进程就绪之后退出
+ */
+func daemonParent(opts DaemonOptions) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("daemonize: %w", err)
+	}
+	stdout, err := openDaemonOutput(opts.Stdout)
+	if err != nil {
+		return err
+	}
+	defer stdout.Close()
+	stderr, err := openDaemonOutput(opts.Stderr)
+	if err != nil {
+		return err
+	}
+	defer stderr.Close()
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		return fmt.Errorf("daemonize: %w", err)
+	}
+	defer stdin.Close()
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("daemonize: %w", err)
+	}
+	defer readyReader.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(append(os.Environ(), opts.Env...), daemonEnv+"=1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	cmd.ExtraFiles = []*os.File{readyWriter}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err = cmd.Start()
+	readyWriter.Close()
+	if err != nil {
+		return fmt.Errorf("daemonize: %w", err)
+	}
+	// 中间进程启动后台进程之后立即退出，回收避免成为僵尸进程
+	cmd.Wait()
+
+	msg, _ := ioutil.ReadAll(readyReader)
+	switch {
+	case string(msg) == daemonReady:
+		os.Exit(int(ExitOK))
+	case len(msg) == 0:
+		return errors.New("daemonize: background process exited before it was ready")
+	}
+	var running AlreadyRunningError
+	if _, scanErr := fmt.Sscanf(string(msg), "running %d %s", &running.PID, &running.Path); scanErr == nil {
+		return &running
+	}
+	return errors.New("daemonize: " + string(msg))
+}
+
+/*
+ * 中间进程：启动后台进程之后退出，标准输入输出以及通知管道原样传递
+ */
+func daemonIntermediate() error {
+	ready := os.NewFile(daemonReadyFd, "daemon-ready")
+	executable, err := os.Executable()
+	if err == nil {
+		cmd := exec.Command(executable, os.Args[1:]...)
+		cmd.Env = append(os.Environ(), daemonEnv+"=2")
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.ExtraFiles = []*os.File{ready}
+		err = cmd.Start()
+	}
+	if err != nil {
+		ready.WriteString(err.Error())
+		os.Exit(int(ExitFailure))
+	}
+	os.Exit(int(ExitOK))
+	return nil
+}
+
+/*
+ * 后台进程：设置运行环境，写入pid文件并通知前台进程
+ * 失败时将错误通知前台进程并退出
+ */
+func daemonFinal(opts DaemonOptions) (*PidLock, error) {
+	ready := os.NewFile(daemonReadyFd, "daemon-ready")
+	fail := func(err error) (*PidLock, error) {
+		var running *AlreadyRunningError
+		if errors.As(err, &running) {
+			fmt.Fprintf(ready, "running %d %s", running.PID, running.Path)
+		} else {
+			ready.WriteString(err.Error())
+		}
+		ready.Close()
+		os.Exit(int(ExitFailure))
+		return nil, err
+	}
+	// 后台进程再启动的子进程不应当被当作Daemonize的阶段
+	os.Unsetenv(daemonEnv)
+	syscall.CloseOnExec(daemonReadyFd)
+
+	umask := opts.Umask
+	if umask == 0 {
+		umask = defaultDaemonUmask
+	}
+	if umask > 0 {
+		syscall.Umask(umask)
+	}
+	workDir := opts.WorkDir
+	if workDir == "" {
+		workDir = "/"
+	}
+	if err := os.Chdir(workDir); err != nil {
+		return fail(fmt.Errorf("daemonize: %w", err))
+	}
+	if opts.Logger != nil {
+		if err := redirectToLogger(opts); err != nil {
+			return fail(err)
+		}
+	}
+	var lock *PidLock
+	if opts.PidFile != "" {
+		var err error
+		if lock, err = LockPidFile(opts.PidFile); err != nil {
+			return fail(err)
+		}
+	}
+	daemonized = true
+	ready.WriteString(daemonReady)
+	ready.Close()
+	return lock, nil
+}
+
+/*
+ * 打开重定向的文件，为空时打开/dev/null；相对路径按照前台进程的工作目录处理
+ */
+func openDaemonOutput(path string) (*os.File, error) {
+	if path == "" {
+		path = os.DevNull
+	}
+	path, err := filepath.Abs(path)
+	if err == nil {
+		var file *os.File
+		if file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, DefaultPidFileMode); err == nil {
+			return file, nil
+		}
+	}
+	return nil, fmt.Errorf("daemonize: %w", err)
+}
+
+/*
+ * 将标准输出以及标准错误(文件描述符1、2)替换为管道，每一行写入日志，runtime输出的panic信息同样会写入
+ */
+func redirectToLogger(opts DaemonOptions) error {
+	stdoutLevel, stderrLevel := opts.StdoutLevel, opts.StderrLevel
+	if stdoutLevel == "" {
+		stdoutLevel = "trace"
+	}
+	if stderrLevel == "" {
+		stderrLevel = "error"
+	}
+	for _, redirect := range []struct {
+		fd    int
+		level string
+	}{{1, stdoutLevel}, {2, stderrLevel}} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("daemonize: %w", err)
+		}
+		if err = syscall.Dup3(int(w.Fd()), redirect.fd, 0); err != nil {
+			r.Close()
+			w.Close()
+			return fmt.Errorf("daemonize: redirect fd %d: %w", redirect.fd, err)
+		}
+		w.Close()
+		go copyLines(r, opts.Logger.Writer(redirect.level))
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+// Daemonize is only supported on linux, other platforms return ErrDaemonUnsupported
+/*
+ * 其他平台请使用launchd、Windows服务等系统的服务管理
+ */
+func Daemonize(opts DaemonOptions) (*PidLock, error) {
+	return nil, ErrDaemonUnsupported
+}
@@ -0,0 +1,143 @@
+package process
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// ExitCode is the documented exit status of the process
+/*
+ * 进程退出码，取值参考sysexits.h，便于监控进程以及脚本根据退出原因做不同处理
+ */
+type ExitCode int
+
+// 进程退出码
+const (
+	ExitOK          ExitCode = 0  // 正常退出
+	ExitFailure     ExitCode = 1  // 未分类的错误
+	ExitUsage       ExitCode = 2  // 命令行参数错误
+	ExitUnavailable ExitCode = 69 // 依赖的服务不可用(EX_UNAVAILABLE)，可以稍后重启
+	ExitPanic       ExitCode = 70 // 未恢复的panic或者内部错误(EX_SOFTWARE)
+	ExitUpgrade     ExitCode = 75 // 请求升级或者重启(EX_TEMPFAIL)，监控进程应当立即重新拉起
+	ExitConfig      ExitCode = 78 // 配置错误(EX_CONFIG)，重启无法恢复
+)
+
+// ExitHookTimeout 退出钩子的最长执行时间，超时之后不再等待直接退出
+var ExitHookTimeout = 5 * time.Second
+
+var (
+	exitLock   sync.Mutex
+	exitHooks  []func()
+	exitLogger *logger.Logger
+	exitOnce   sync.Once
+)
+
+// String returns the name of the exit code
+func (code ExitCode) String() string {
+	switch code {
+	case ExitOK:
+		return "ok"
+	case ExitFailure:
+		return "failure"
+	case ExitUsage:
+		return "usage"
+	case ExitUnavailable:
+		return "dependency_unavailable"
+	case ExitPanic:
+		return "fatal_panic"
+	case ExitUpgrade:
+		return "upgrade_requested"
+	case ExitConfig:
+		return "config_error"
+	}
+	return "exit_" + strconv.Itoa(int(code))
+}
+
+// OnExit registers a hook run by Exit before the process terminates
+/*
+ * 注册退出钩子，Exit时按照注册的相反顺序执行，例如关闭监听、删除pid文件
+ * 钩子执行之后才会flush日志，钩子中仍然可以写日志
+ * @param hook：退出钩子
+ */
+func OnExit(hook func()) {
+	exitLock.Lock()
+	exitHooks = append(exitHooks, hook)
+	exitLock.Unlock()
+}
+
+// SetExitLogger sets the logger which Exit records the reason to and flushes
+/*
+ * 设置Exit使用的日志对象，为nil时Exit不写日志
+ * @param l：日志对象
+ */
+func SetExitLogger(l *logger.Logger) {
+	exitLock.Lock()
+	exitLogger = l
+	exitLock.Unlock()
+}
+
+// Exit runs the exit hooks, logs the reason, flushes the logs and exits with code
+/*
+ * 按照约定的退出码退出进程：
+ *   1. 按照注册的相反顺序执行OnExit钩子，全部执行时间超过ExitHookTimeout时不再等待
+ *   2. 将退出码以及原因写入日志(ExitOK写入trace日志，其余写入error日志)，并同步flush所有日志文件
+ *   3. 调用os.Exit
+ * 钩子中再次调用Exit时直接以新的退出码退出，不会重复执行钩子
+ * @param code：退出码
+ * @param reason：退出原因
+ */
+func Exit(code ExitCode, reason string) {
+	first := false
+	exitOnce.Do(func() { first = true })
+	if !first {
+		os.Exit(int(code))
+	}
+
+	exitLock.Lock()
+	hooks := append([]func(){}, exitHooks...)
+	l := exitLogger
+	exitLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := len(hooks) - 1; i >= 0; i-- {
+			runExitHook(hooks[i], l)
+		}
+	}()
+	timer := time.NewTimer(ExitHookTimeout)
+	select {
+	case <-done:
+		timer.Stop()
+	case <-timer.C:
+		if l != nil {
+			l.Error("exit", "hooks timed out", ExitHookTimeout.String())
+		}
+	}
+
+	if l != nil {
+		if code == ExitOK {
+			l.Trace("exit", int(code), code.String(), reason)
+		} else {
+			l.Error("exit", int(code), code.String(), reason)
+		}
+		l.Flush()
+	}
+	os.Exit(int(code))
+}
+
+/*
+ * 执行一个退出钩子，钩子panic时记录日志并继续执行其余钩子
+ */
+func runExitHook(hook func(), l *logger.Logger) {
+	defer func() {
+		if r := recover(); r != nil && l != nil {
+			l.Error("exit", "hook panic", r)
+		}
+	}()
+	hook()
+}
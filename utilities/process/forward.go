@@ -0,0 +1,90 @@
+package process
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Forward sends sig to every running child, translated per child
+/*
+ * 向所有运行中的子进程(所在的进程组)转发信号，按照ChildSpec.Signals转换，转换为0的子进程跳过
+ * SIGTERM以及SIGINT视为停止请求：子进程按照Stop的流程停止，不再重启，超过StopTimeout之后发送SIGKILL；
+ * 其余信号(例如SIGHUP)只转发，子进程因此退出时仍然按照重启策略处理
+ * 停止在后台进行，Forward不等待子进程退出，需要等待时之后调用Shutdown
+ * windows下停止请求直接结束子进程，其余信号无法转发
+ * @param sig：父进程收到的信号
+ * @return 转发失败时返回第一个error，其余子进程仍然会转发
+ */
+func (s *Supervisor) Forward(sig syscall.Signal) error {
+	var firstErr error
+	for _, child := range s.all() {
+		translated := child.spec.translateSignal(sig)
+		if translated == 0 {
+			continue
+		}
+		child.mu.Lock()
+		p := child.process
+		child.mu.Unlock()
+		if p == nil {
+			continue
+		}
+		s.logf("trace", child, "forward signal", sig.String(), translated.String())
+		if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+			go s.stopChild(child, translated)
+			continue
+		}
+		if err := SignalGroup(p.Pid, translated); err != nil {
+			s.logf("warn", child, "forward signal failed", translated.String(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// ForwardSignals forwards sigs received by the current process to the children
+/*
+ * 监听信号并通过Forward转发给所有子进程，kill父进程即可停止整棵进程树，例如：
+ *     stop := sup.ForwardSignals()
+ *     defer stop()
+ * 与OnShutdown(sup.Shutdown)同时使用时，SIGTERM既会触发关闭钩子也会转发，两者的停止流程相同，重复停止是安全的
+ * @param sigs：监听的信号，为空时监听SIGTERM、SIGINT以及SIGHUP
+ * @return 停止监听的函数
+ */
+func (s *Supervisor) ForwardSignals(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, len(sigs))
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				if sysSig, ok := sig.(syscall.Signal); ok {
+					s.Forward(sysSig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+/*
+ * 按照Signals转换转发的信号
+ * @return 转换之后的信号，0表示不转发
+ */
+func (spec ChildSpec) translateSignal(sig syscall.Signal) syscall.Signal {
+	if translated, ok := spec.Signals[sig]; ok {
+		return translated
+	}
+	return sig
+}
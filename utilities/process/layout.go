@@ -0,0 +1,167 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 默认的目录名称以及搜索层数
+const (
+	defaultLayoutDepth   = 8
+	defaultLayoutDirMode = 0755
+	defaultLayoutMarker  = "bin"
+	defaultLayoutLog     = "log"
+	defaultLayoutData    = "data"
+	defaultLayoutTmp     = "tmp"
+	defaultLayoutConf    = "conf"
+)
+
+// ErrRootNotFound is returned by Layout.RootDir when no ancestor contains the marker directories
+var ErrRootNotFound = errors.New("process: project root not found")
+
+// Layout describes the directory structure of a deployed project
+/*
+ * 项目目录结构，GetProjectRootDir要求二进制文件直接位于root/bin下，Layout通过向上查找标记目录确定root，
+ * 二进制文件位于root/bin/linux-amd64/等更深的子目录时同样可以找到，例如：
+ *     layout := &process.Layout{Markers: []string{"bin", "conf"}}
+ *     logDir, err := layout.LogDir()
+ * 零值可以直接使用，等价于GetProjectRootDir的结构；只用bin作为标记时可能匹配到/usr/local等系统目录，建议同时声明conf等项目特有的目录
+ * 找到的root目录会被缓存，Layout不能复制
+ */
+type Layout struct {
+	Markers  []string    // 根目录下必须全部存在的子目录，为空时为bin
+	Log      string      // 日志目录，相对root，为空时为log
+	Data     string      // 数据目录，相对root，为空时为data
+	Tmp      string      // 临时文件目录，相对root，为空时为tmp
+	Conf     string      // 配置目录，相对root，为空时为conf
+	StartDir string      // 开始查找的目录，为空时为二进制文件所在目录
+	MaxDepth int         // 向上查找的最大层数(包括StartDir本身)，<=0时为8
+	DirMode  os.FileMode // 创建目录使用的权限，为0时为0755
+
+	mu   sync.Mutex
+	root string
+}
+
+// RootDir searches upward from StartDir for the directory containing all Markers
+/*
+ * 从StartDir开始逐级向上查找同时包含所有标记目录的目录，即项目root目录
+ * @return (root目录的绝对路径, error)；没有找到时返回包装ErrRootNotFound的error
+ */
+func (l *Layout) RootDir() (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.root != "" {
+		return l.root, nil
+	}
+	start := l.StartDir
+	if start == "" {
+		binDir, err := GetProcessBinaryDir()
+		if err != nil {
+			return "", err
+		}
+		start = binDir
+	}
+	start, err := filepath.Abs(start)
+	if err != nil {
+		return "", err
+	}
+	markers := l.Markers
+	if len(markers) == 0 {
+		markers = []string{defaultLayoutMarker}
+	}
+	depth := l.MaxDepth
+	if depth <= 0 {
+		depth = defaultLayoutDepth
+	}
+	for dir, i := start, 0; i < depth; i++ {
+		if hasDirs(dir, markers) {
+			l.root = dir
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return "", fmt.Errorf("find root with %v from %s: %w", markers, start, ErrRootNotFound)
+}
+
+// LogDir returns the log directory, creating it if missing
+func (l *Layout) LogDir() (string, error) {
+	return l.Dir(orDefault(l.Log, defaultLayoutLog))
+}
+
+// DataDir returns the data directory, creating it if missing
+func (l *Layout) DataDir() (string, error) {
+	return l.Dir(orDefault(l.Data, defaultLayoutData))
+}
+
+// TmpDir returns the temporary file directory, creating it if missing
+func (l *Layout) TmpDir() (string, error) {
+	return l.Dir(orDefault(l.Tmp, defaultLayoutTmp))
+}
+
+// ConfDir returns the configuration directory without creating it
+/*
+ * 获取配置目录，配置目录应当随部署一起发布，不存在时返回error而不是创建空目录
+ * @return (配置目录的绝对路径, error)
+ */
+func (l *Layout) ConfDir() (string, error) {
+	root, err := l.RootDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, orDefault(l.Conf, defaultLayoutConf))
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Dir returns the subdirectory name of the root, creating it if missing
+/*
+ * 获取root下的子目录，不存在时使用DirMode创建，用于Layout没有列出的目录，例如layout.Dir("run")
+ * @param name：相对root的目录，可以包含多级
+ * @return (目录的绝对路径, error)
+ */
+func (l *Layout) Dir(name string) (string, error) {
+	root, err := l.RootDir()
+	if err != nil {
+		return "", err
+	}
+	mode := l.DirMode
+	if mode == 0 {
+		mode = defaultLayoutDirMode
+	}
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+/*
+ * 判断目录下是否存在所有子目录
+ */
+func hasDirs(dir string, names []string) bool {
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * 为空时返回默认值
+ */
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,111 @@
+package process
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// Limits describes the CPU and memory available to the process
+type Limits struct {
+	CPU         float64 // 可用的CPU核数，cgroup配额与机器核数中较小的
+	CPUQuota    float64 // cgroup的CPU配额(核数)，0表示不限制
+	Memory      int64   // 可用内存(字节)，cgroup上限与物理内存中较小的，都未知时为0
+	MemoryLimit int64   // cgroup的内存上限(字节)，0表示不限制
+	MemoryTotal int64   // 物理内存(字节)，未知时为0
+	Cgroup      int     // 生效的cgroup版本，1或者2，不受cgroup管理或者非linux系统时为0
+}
+
+// DetectLimits returns the CPU and memory limits effective for the process
+/*
+ * 探测当前进程实际可用的CPU以及内存，容器内运行时以cgroup的限制为准
+ * linux下读取cgroup v1/v2的CPU配额(cpu.max、cpu.cfs_quota_us)以及内存上限(memory.max、memory.limit_in_bytes)，
+ * 当前cgroup以及所有上级cgroup的限制都会生效，取其中最小的；其他系统只返回机器的CPU核数
+ * @return 探测结果
+ */
+func DetectLimits() Limits {
+	limits := Limits{}
+	detectLimits(&limits)
+	limits.CPU = float64(runtime.NumCPU())
+	if limits.CPUQuota > 0 && limits.CPUQuota < limits.CPU {
+		limits.CPU = limits.CPUQuota
+	}
+	limits.Memory = limits.MemoryTotal
+	if limits.MemoryLimit > 0 && (limits.Memory == 0 || limits.MemoryLimit < limits.Memory) {
+		limits.Memory = limits.MemoryLimit
+	}
+	return limits
+}
+
+// MaxProcs returns the GOMAXPROCS value matching the CPU limit
+/*
+ * 按照可用的CPU核数计算GOMAXPROCS，向下取整，最小为1；例如配额1.5核时为1
+ */
+func (limits Limits) MaxProcs() int {
+	if n := int(limits.CPU); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// AdjustMaxProcs sets GOMAXPROCS to the CPU limit of the process and logs the result
+/*
+ * 按照cgroup的CPU配额设置GOMAXPROCS，避免容器内配额很小时按照宿主机核数创建过多的P，导致频繁被限流
+ * 设置了GOMAXPROCS环境变量时以环境变量为准，不做修改
+ * 结果写入trace日志，格式为 maxprocs|gomaxprocs=2|previous=64|cpu_quota=2|memory_limit=4294967296|source=cgroup
+ * 一般在main函数开始时调用；Go 1.25及以上版本的运行时已经按照cgroup配额设置默认值，此时只用于输出探测结果
+ * @param l：日志对象，为nil时不输出
+ * @return 设置之后的GOMAXPROCS
+ */
+func AdjustMaxProcs(l *logger.Logger) int {
+	limits := DetectLimits()
+	previous := runtime.GOMAXPROCS(0)
+	procs, source := previous, "env"
+	if os.Getenv("GOMAXPROCS") == "" {
+		procs, source = limits.MaxProcs(), "cgroup"
+		if limits.CPUQuota == 0 {
+			source = "num_cpu"
+		}
+		runtime.GOMAXPROCS(procs)
+	}
+	if l != nil {
+		l.Trace("maxprocs",
+			"gomaxprocs="+strconv.Itoa(procs),
+			"previous="+strconv.Itoa(previous),
+			"cpu_quota="+formatQuota(limits.CPUQuota),
+			"memory_limit="+formatMemoryLimit(limits.MemoryLimit),
+			"source="+source)
+	}
+	return procs
+}
+
+/*
+ * 获取cgroup的CPU以及内存限制，用于启动信息输出，没有限制时为max
+ * @return 限制列表，不受cgroup管理时为空
+ */
+func cgroupItems() []BannerItem {
+	limits := DetectLimits()
+	if limits.Cgroup == 0 {
+		return nil
+	}
+	return []BannerItem{
+		{"cgroup_cpu", formatQuota(limits.CPUQuota)},
+		{"cgroup_memory", formatMemoryLimit(limits.MemoryLimit)},
+	}
+}
+
+func formatQuota(quota float64) string {
+	if quota <= 0 {
+		return "max"
+	}
+	return strconv.FormatFloat(quota, 'f', -1, 64)
+}
+
+func formatMemoryLimit(limit int64) string {
+	if limit <= 0 {
+		return "max"
+	}
+	return strconv.FormatInt(limit, 10)
+}
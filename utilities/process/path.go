@@ -1,19 +1,5 @@
 package process
 
-import (
-	"os"
-	"path/filepath"
-)
-
-/*
- * 获取二进制文件绝对目录
- @return (absolute path, nil)表示成功;否则返回("", error)
-*/
-func GetProcessBinaryDir() (string, error) {
-	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-	return dir, err
-}
-
 /*
  * 通常我们按照下面的结构部署项目
  * root
@@ -22,7 +8,7 @@ func GetProcessBinaryDir() (string, error) {
  *   |___data		// data目录存放本地数据
  *   |___tmp		// tmp目录存放临时文件
  *   ...
- * 本函数依据此结构获取root目录
+ * 本函数依据此结构获取root目录，二进制文件不直接位于bin下或者需要自定义目录时使用Layout
  * @return 获取到的root目录
  * @exception 如果获取二进制所在目录失败会产生panic
  */
@@ -0,0 +1,34 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// /proc/self/exe指向的文件被删除之后返回原来所在的目录，不带 (deleted) 后缀
+func TestGetProcessBinaryDirDeleted(t *testing.T) {
+	dir := t.TempDir()
+	exe := copyTestBinary(t, dir)
+	got := runBinaryDirHelper(t, exe, helperDeleteSelf)
+	if strings.Contains(got, "(deleted)") || !sameDir(t, got, dir) {
+		t.Errorf("got %q, want %q", got, dir)
+	}
+	if _, err := os.Stat(exe); !os.IsNotExist(err) {
+		t.Errorf("helper did not delete its binary: %v", err)
+	}
+}
+
+// 通过符号链接启动时返回链接目标所在的目录
+func TestGetProcessBinaryDirSymlink(t *testing.T) {
+	real, links := t.TempDir(), t.TempDir()
+	exe := copyTestBinary(t, real)
+	link := filepath.Join(links, "app")
+	if err := os.Symlink(exe, link); err != nil {
+		t.Fatal(err)
+	}
+	if got := runBinaryDirHelper(t, link, "1"); !sameDir(t, got, real) {
+		t.Errorf("got %q, want %q", got, real)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package process
+
+import (
+	"os"
+	"path/filepath"
+)
+
+/*
+ * 获取二进制文件绝对目录
+ * darwin通过_NSGetExecutablePath，FreeBSD通过sysctl kern.proc.pathname获取(os.Executable)，
+ * 与linux读取/proc/self/exe一致，返回解析符号链接之后的真实目录，不受启动时的工作目录以及argv[0]影响
+ * @return (absolute path, nil)表示成功;否则返回("", error)
+ */
+func GetProcessBinaryDir() (string, error) {
+	p, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	return filepath.Dir(p), nil
+}
@@ -0,0 +1,27 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 通过符号链接启动时返回解析符号链接之后的真实目录，与linux读取/proc/self/exe一致
+func TestGetProcessBinaryDirSymlink(t *testing.T) {
+	real, links := t.TempDir(), t.TempDir()
+	exe := copyTestBinary(t, real)
+	link := filepath.Join(links, "app")
+	if err := os.Symlink(exe, link); err != nil {
+		t.Fatal(err)
+	}
+	got := runBinaryDirHelper(t, link, "1")
+	if !sameDir(t, got, real) {
+		t.Errorf("got %q, want %q", got, real)
+	}
+	if resolved, err := filepath.EvalSymlinks(got); err != nil || resolved != got {
+		t.Errorf("%q is not fully resolved (%q, %v)", got, resolved, err)
+	}
+}
@@ -0,0 +1,130 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// binaryDirHelperEnv 设置时TestBinaryDirHelper作为子进程运行，输出GetProcessBinaryDir的结果
+const binaryDirHelperEnv = "PROCESS_TEST_BINARY_DIR_HELPER"
+
+// 取值为delete时先删除自身的二进制文件，模拟运行期间二进制文件被替换
+const helperDeleteSelf = "delete"
+
+func TestBinaryDirHelper(t *testing.T) {
+	mode := os.Getenv(binaryDirHelperEnv)
+	if mode == "" {
+		t.Skip("helper process only")
+	}
+	if mode == helperDeleteSelf {
+		exe, err := os.Executable()
+		if err == nil {
+			err = os.Remove(exe)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	dir, err := GetProcessBinaryDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Print(dir)
+	os.Exit(0)
+}
+
+/*
+ * 运行测试二进制的副本或者链接，返回子进程中GetProcessBinaryDir的结果
+ * @param exe：可执行文件
+ * @param mode：binaryDirHelperEnv的取值
+ */
+func runBinaryDirHelper(t *testing.T, exe, mode string) string {
+	t.Helper()
+	cmd := exec.Command(exe, "-test.run=^TestBinaryDirHelper$")
+	cmd.Env = append(os.Environ(), binaryDirHelperEnv+"="+mode)
+	cmd.Dir = t.TempDir()
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("helper %s: %v: %s", exe, err, stderr.String())
+	}
+	return string(out)
+}
+
+/*
+ * 将测试二进制复制到dir中
+ * @return 副本路径
+ */
+func copyTestBinary(t *testing.T, dir string) string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst := filepath.Join(dir, filepath.Base(exe))
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.Copy(f, src); err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		f.Close()
+		t.Fatal(err)
+	}
+	return dst
+}
+
+/*
+ * 比较两个目录是否相同，解析符号链接(例如macOS的/var -> /private/var)之后比较
+ */
+func sameDir(t *testing.T, got, want string) bool {
+	t.Helper()
+	resolve := func(dir string) string {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		return filepath.Clean(filepath.FromSlash(dir))
+	}
+	return resolve(got) == resolve(want)
+}
+
+func TestGetProcessBinaryDir(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := GetProcessBinaryDir()
+	if err != nil {
+		t.Fatalf("GetProcessBinaryDir: %v", err)
+	}
+	if !filepath.IsAbs(filepath.FromSlash(dir)) {
+		t.Errorf("%q is not absolute", dir)
+	}
+	if !sameDir(t, dir, filepath.Dir(exe)) {
+		t.Errorf("got %q, want the directory of %q", dir, exe)
+	}
+}
+
+// 结果与启动时的工作目录无关
+func TestGetProcessBinaryDirCopy(t *testing.T) {
+	dir := t.TempDir()
+	exe := copyTestBinary(t, dir)
+	if got := runBinaryDirHelper(t, exe, "1"); !sameDir(t, got, dir) {
+		t.Errorf("got %q, want %q", got, dir)
+	}
+}
@@ -10,8 +10,8 @@ import (
 
 /*
  * 获取二进制文件绝对目录
- @return (absolute path, nil)表示成功;否则返回("", error)
-*/
+ * @return (absolute path, nil)表示成功;否则返回("", error)
+ */
 func GetProcessBinaryDir() (string, error) {
 	var dir, p string
 	var err error
@@ -24,26 +24,6 @@ func GetProcessBinaryDir() (string, error) {
 	return dir, nil
 }
 
-/*
- * 通常我们按照下面的结构部署项目
- * root
- *   |___bin		// bin目录存放二进制组件文件
- *   |___log		// log目录存放日志
- *   |___data		// data目录存放本地数据
- *   |___tmp		// tmp目录存放临时文件
- *   ...
- * 本函数依据此结构获取root目录
- * @return 获取到的root目录
- * @exception 如果获取二进制所在目录失败会产生panic
- */
-func GetProjectRootDir() string {
-	binDir, err := GetProcessBinaryDir()
-	if err != nil {
-		panic(err.Error())
-	}
-	return binDir + "/.."
-}
-
 func getWindowsProcessBinaryPath() (string, error) {
 	b := make([]uint16, 300)
 	n, e := getModuleFileName(uint32(len(b)), &b[0])
@@ -0,0 +1,68 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// NewSession makes the current process the leader of a new session
+/*
+ * 创建新会话，当前进程成为会话首进程以及进程组组长，并脱离控制终端
+ * @return 新会话的id，当前进程已经是进程组组长时返回error(EPERM)
+ */
+func NewSession() (int, error) {
+	return syscall.Setsid()
+}
+
+// SetPGID moves process pid into process group pgid
+/*
+ * 设置进程的进程组
+ * @param pid：进程id，0表示当前进程
+ * @param pgid：进程组id，0表示使用pid作为进程组id(即创建新进程组)
+ * @return 失败时返回error
+ */
+func SetPGID(pid, pgid int) error {
+	return syscall.Setpgid(pid, pgid)
+}
+
+// GetPGID returns the process group id of pid
+/*
+ * 获取进程的进程组id
+ * @param pid：进程id，0表示当前进程
+ * @return 进程组id以及error
+ */
+func GetPGID(pid int) (int, error) {
+	return syscall.Getpgid(pid)
+}
+
+// SignalGroup sends sig to every process of the group pgid
+/*
+ * 向整个进程组发送信号
+ * @param pgid：进程组id
+ * @param sig：信号
+ * @return 失败时返回error
+ */
+func SignalGroup(pgid int, sig syscall.Signal) error {
+	if pgid <= 0 {
+		return syscall.EINVAL
+	}
+	return syscall.Kill(-pgid, sig)
+}
+
+// StartInNewGroup makes cmd start its child in a process group of its own
+/*
+ * 设置子进程启动后使用独立的进程组(进程组id等于子进程pid)，
+ * 需要在cmd.Start之前调用；之后可以通过SignalGroup(cmd.Process.Pid, sig)
+ * 向子进程及其派生的整条管道发送信号，终端产生的信号也不会直接传递给子进程
+ * @param cmd：待启动的命令
+ */
+func StartInNewGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.SysProcAttr.Pgid = 0
+}
@@ -0,0 +1,42 @@
+package process
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+/*
+ * windows下没有会话以及unix风格的进程组，以下接口均返回EWINDOWS
+ */
+
+// NewSession is not supported on windows
+func NewSession() (int, error) {
+	return 0, syscall.EWINDOWS
+}
+
+// SetPGID is not supported on windows
+func SetPGID(pid, pgid int) error {
+	return syscall.EWINDOWS
+}
+
+// GetPGID is not supported on windows
+func GetPGID(pid int) (int, error) {
+	return 0, syscall.EWINDOWS
+}
+
+// SignalGroup is not supported on windows
+func SignalGroup(pgid int, sig syscall.Signal) error {
+	return syscall.EWINDOWS
+}
+
+// StartInNewGroup makes cmd start its child in a new console process group
+/*
+ * 使用CREATE_NEW_PROCESS_GROUP启动子进程，控制台的Ctrl+C不会传递给子进程
+ * @param cmd：待启动的命令
+ */
+func StartInNewGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
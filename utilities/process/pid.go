@@ -1,19 +1,83 @@
 package process
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
+
+	"github.com/lucifinil-long/nano-legion/utilities/fileutil"
+)
+
+// 默认的pid文件以及目录权限
+const (
+	DefaultPidFileMode os.FileMode = 0644
+	DefaultPidDirMode  os.FileMode = 0755
 )
 
+// SavePid writes the current pid to pidFile with the default permissions
+/*
+ * 保存当前进程id，文件权限0644，目录权限0755
+ * 只覆盖文件，不检查是否已经有实例在运行，需要单实例保护时使用LockPidFile
+ * @param pidFile：pid文件路径
+ * @return 失败时返回包含文件路径的error
+ */
 func SavePid(pidFile string) error {
-	dir := path.Dir(pidFile)
-	os.MkdirAll(dir, 0744)
+	return SavePidWithMode(pidFile, DefaultPidFileMode, DefaultPidDirMode)
+}
+
+// SavePidWithMode writes the current pid to pidFile atomically
+/*
+ * 保存当前进程id：先写入同目录下的临时文件并fsync，再重命名为pidFile，
+ * 其他进程不会读到写了一半的pid文件；写入之后读取校验内容
+ * @param pidFile：pid文件路径
+ * @param fileMode：pid文件权限
+ * @param dirMode：目录不存在时创建目录使用的权限
+ * @return 失败时返回包含文件路径的error
+ */
+func SavePidWithMode(pidFile string, fileMode, dirMode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(pidFile), dirMode); err != nil {
+		return fmt.Errorf("save pid %s: %w", pidFile, err)
+	}
 
 	pid := os.Getpid()
-	pidString := strconv.Itoa(pid)
-	if err := ioutil.WriteFile(pidFile, []byte(pidString), 0777); err != nil {
+	if err := fileutil.AtomicWriteFile(pidFile, []byte(strconv.Itoa(pid)), fileMode); err != nil {
+		return fmt.Errorf("save pid %s: %w", pidFile, err)
+	}
+	saved, err := ReadPid(pidFile)
+	if err != nil {
+		return fmt.Errorf("verify pid %s: %w", pidFile, err)
+	}
+	if saved != pid {
+		return fmt.Errorf("verify pid %s: found %d, expected %d", pidFile, saved, pid)
+	}
+	return nil
+}
+
+// ReadPid reads the pid recorded in pidFile
+/*
+ * 读取pid文件中记录的进程id
+ * @param pidFile：pid文件路径
+ * @return 成功返回(pid, nil)；否则返回(0, error)
+ */
+func ReadPid(pidFile string) (int, error) {
+	content, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(content)))
+}
+
+// RemovePid removes the pid file on clean shutdown
+/*
+ * 进程正常退出时删除pid文件
+ * 启动时残留的pid文件会被CheckPreviousRun视为上一次运行异常退出的证据
+ * @param pidFile：pid文件路径
+ */
+func RemovePid(pidFile string) error {
+	if err := os.Remove(pidFile); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
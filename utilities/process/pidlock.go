@@ -0,0 +1,158 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pidLockAttempts 加锁之后发现pid文件已经被替换(例如旧实例Release时删除)时的重试次数
+const pidLockAttempts = 3
+
+// ErrAlreadyRunning matches AlreadyRunningError with errors.Is
+var ErrAlreadyRunning = errors.New("process already running")
+
+// AlreadyRunningError is returned by LockPidFile when another instance owns the pid file
+type AlreadyRunningError struct {
+	Path string // pid文件路径
+	PID  int    // 正在运行的实例的进程id，pid文件内容无法解析时为0
+}
+
+// Error implements error
+func (e *AlreadyRunningError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("lock pid %s: %v", e.Path, ErrAlreadyRunning)
+	}
+	return fmt.Sprintf("lock pid %s: %v with pid %d", e.Path, ErrAlreadyRunning, e.PID)
+}
+
+// Is reports whether target is ErrAlreadyRunning
+func (e *AlreadyRunningError) Is(target error) bool {
+	return target == ErrAlreadyRunning
+}
+
+// PidLock is a pid file held with an exclusive lock, see LockPidFile
+type PidLock struct {
+	path string
+	file *os.File
+}
+
+// LockPidFile makes sure only one instance runs by locking the pid file
+/*
+ * 单实例保护：对pid文件加排他锁(unix为flock，windows为LockFileEx)，成功之后写入当前进程id，进程存活期间一直持有锁
+ * 锁由操作系统在进程退出时自动释放，进程异常退出留下的pid文件不会阻止下一次启动；
+ * 加锁成功但是文件中记录的进程仍然存在时(例如旧版本通过SavePid写入、或者文件系统不支持锁)，同样视为已经运行
+ * 记录的进程不存在时视为残留的pid文件，直接覆盖
+ * 其他实例持有锁时返回*AlreadyRunningError，可以通过errors.Is(err, ErrAlreadyRunning)判断，其中PID为正在运行的实例
+ * 例如:
+ *     lock, err := process.LockPidFile("/var/run/saver.pid")
+ *     if err != nil { log.Fatal(err) }
+ *     defer lock.Release()
+ * @param path：pid文件路径，目录不存在时使用DefaultPidDirMode创建
+ * @return (*PidLock, error)
+ */
+func LockPidFile(path string) (*PidLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), DefaultPidDirMode); err != nil {
+		return nil, fmt.Errorf("lock pid %s: %w", path, err)
+	}
+	for attempt := 0; attempt < pidLockAttempts; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, DefaultPidFileMode)
+		if err != nil {
+			return nil, fmt.Errorf("lock pid %s: %w", path, err)
+		}
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("lock pid %s: %w", path, err)
+		}
+		if !locked {
+			pid, _ := readLockedPid(file)
+			file.Close()
+			return nil, &AlreadyRunningError{Path: path, PID: pid}
+		}
+		if !sameFile(file, path) {
+			// 加锁之前文件已经被持有者删除，新的pid文件可能已经被其他实例创建，重新打开
+			file.Close()
+			continue
+		}
+		if pid, err := readLockedPid(file); err == nil && pid != os.Getpid() && pidAlive(pid) {
+			file.Close()
+			return nil, &AlreadyRunningError{Path: path, PID: pid}
+		}
+		if err = writeLockedPid(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("lock pid %s: %w", path, err)
+		}
+		return &PidLock{path: path, file: file}, nil
+	}
+	return nil, fmt.Errorf("lock pid %s: file keeps being replaced", path)
+}
+
+// Path returns the path of the pid file
+func (l *PidLock) Path() string {
+	return l.path
+}
+
+// Release removes the pid file and releases the lock
+/*
+ * 进程正常退出时调用：删除pid文件并释放锁，重复调用直接返回nil
+ * pid文件已经被替换为其他文件时不删除
+ * @return 删除pid文件失败时返回error，锁仍然会被释放
+ */
+func (l *PidLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	file := l.file
+	l.file = nil
+	var err error
+	if sameFile(file, l.path) {
+		err = removeLockedFile(file, l.path)
+	} else {
+		file.Close()
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("release pid %s: %w", l.path, err)
+	}
+	return nil
+}
+
+/*
+ * 判断打开的文件与路径是否为同一个文件
+ */
+func sameFile(file *os.File, path string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	return err == nil && os.SameFile(opened, current)
+}
+
+/*
+ * 从已经打开的pid文件中读取进程id
+ */
+func readLockedPid(file *os.File) (int, error) {
+	buf := make([]byte, 32)
+	n, err := file.ReadAt(buf, 0)
+	if n == 0 && err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+}
+
+/*
+ * 覆盖pid文件的内容为当前进程id，不能使用重命名，否则锁会留在旧文件上
+ */
+func writeLockedPid(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
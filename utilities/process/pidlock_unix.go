@@ -0,0 +1,30 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+/*
+ * 对文件加非阻塞的排他锁
+ * @return (是否加锁成功, error)；其他进程持有锁时返回(false, nil)
+ */
+func tryLockFile(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+/*
+ * 持有锁时删除文件再关闭，避免其他实例在删除之前加锁成功
+ */
+func removeLockedFile(file *os.File, path string) error {
+	err := os.Remove(path)
+	file.Close()
+	return err
+}
@@ -0,0 +1,39 @@
+package process
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+/*
+ * 对文件加非阻塞的排他锁，锁定文件末尾之后很远的一个字节，不影响其他进程读取文件中的pid
+ * @return (是否加锁成功, error)；其他进程持有锁时返回(false, nil)
+ */
+func tryLockFile(file *os.File) (bool, error) {
+	overlapped := syscall.Overlapped{OffsetHigh: 0x7fffffff}
+	r, _, err := procLockFileEx.Call(file.Fd(), lockfileFailImmediately|lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r != 0 {
+		return true, nil
+	}
+	if err == errorLockViolation || err == syscall.ERROR_IO_PENDING {
+		return false, nil
+	}
+	return false, err
+}
+
+/*
+ * windows下打开的文件不能删除，先关闭(同时释放锁)再删除
+ */
+func removeLockedFile(file *os.File, path string) error {
+	file.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,131 @@
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// crashFilePrefix 崩溃报告文件名前缀，崩溃报告文件命名为crash-*.log
+const crashFilePrefix = "crash-"
+
+// PostMortem describes how the previous instance ended
+type PostMortem struct {
+	Abnormal    bool      // 上一次运行是否异常退出
+	Reason      string    // 异常退出原因: stale_pid/crash_file/oom_killed
+	PreviousPid int       // 上一个实例的pid
+	StartedAt   time.Time // 上一个实例的启动时间(pid文件修改时间)
+	CrashFile   string    // 上一个实例留下的崩溃报告文件
+	Detail      string    // 补充信息，如journald中的OOM记录
+}
+
+// PostMortemOptions configures CheckPreviousRun
+type PostMortemOptions struct {
+	PidFile    string            // 上一个实例写入的pid文件，正常退出时应当调用RemovePid删除
+	CrashDir   string            // 崩溃报告目录，为空时不检查
+	OnAbnormal func(*PostMortem) // 检测到异常退出时的回调，可以用于上报告警
+}
+
+// CheckPreviousRun detects whether the previous run ended abnormally
+/*
+ * 在启动时(调用SavePid之前)检查上一次运行是否异常退出，依据包括：
+ *   1. pid文件残留，且记录的进程已经不存在
+ *   2. 崩溃报告目录中存在上一个实例启动之后生成的崩溃报告
+ *   3. journald中存在上一个实例被OOM killer杀掉的记录(仅linux)
+ * 检测到异常退出时会调用OnAbnormal回调
+ * @param opts：检查选项
+ * @return 检查结果，上一次正常退出时Abnormal为false
+ */
+func CheckPreviousRun(opts PostMortemOptions) *PostMortem {
+	pm := &PostMortem{}
+
+	stat, err := os.Stat(opts.PidFile)
+	if err != nil {
+		// pid文件不存在，说明上一次正常退出或者是第一次运行
+		return pm
+	}
+	pm.StartedAt = stat.ModTime()
+	pid, err := ReadPid(opts.PidFile)
+	if err != nil || pid == os.Getpid() || pidAlive(pid) {
+		// pid文件损坏或者记录的进程仍在运行，无法判定上一次运行的结局
+		return pm
+	}
+	pm.Abnormal = true
+	pm.Reason = "stale_pid"
+	pm.PreviousPid = pid
+
+	if opts.CrashDir != "" {
+		if crashFile := latestCrashFile(opts.CrashDir, pm.StartedAt); crashFile != "" {
+			pm.Reason = "crash_file"
+			pm.CrashFile = crashFile
+		}
+	}
+	if record := findOOMRecord(pid, pm.StartedAt); record != "" {
+		pm.Reason = "oom_killed"
+		pm.Detail = record
+	}
+
+	if opts.OnAbnormal != nil {
+		opts.OnAbnormal(pm)
+	}
+	return pm
+}
+
+// Log writes the post-mortem record through the error level when the previous run was abnormal
+/*
+ * 输出结构化的上一个实例异常退出记录，格式为 previous instance died|reason=xxx|pid=xxx|...
+ * 上一次正常退出时不输出
+ * @param l：日志对象
+ */
+func (pm *PostMortem) Log(l *logger.Logger) {
+	if !pm.Abnormal {
+		return
+	}
+	args := []interface{}{
+		"previous instance died",
+		"reason=" + pm.Reason,
+		"pid=" + strconv.Itoa(pm.PreviousPid),
+		"started_at=" + pm.StartedAt.Format(time.RFC3339),
+	}
+	if pm.CrashFile != "" {
+		args = append(args, "crash_file="+pm.CrashFile)
+	}
+	if pm.Detail != "" {
+		args = append(args, "detail="+pm.Detail)
+	}
+	l.Error(args...)
+}
+
+/*
+ * 获取崩溃报告目录中since之后生成的最新的崩溃报告
+ * @param dir：崩溃报告目录
+ * @param since：上一个实例的启动时间
+ * @return 崩溃报告文件路径，不存在时返回空字符串
+ */
+func latestCrashFile(dir string, since time.Time) string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	var latest os.FileInfo
+	for _, f := range files {
+		if f.IsDir() || !strings.HasPrefix(f.Name(), crashFilePrefix) {
+			continue
+		}
+		if f.ModTime().Before(since) {
+			continue
+		}
+		if latest == nil || f.ModTime().After(latest.ModTime()) {
+			latest = f
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return filepath.Join(dir, latest.Name())
+}
@@ -0,0 +1,34 @@
+package process
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalctlTimeout 查询journald的超时时间，避免journald异常时阻塞启动
+const journalctlTimeout = 3 * time.Second
+
+/*
+ * 在内核日志中查找pid被OOM killer杀掉的记录
+ * @param pid：上一个实例的pid
+ * @param since：上一个实例的启动时间
+ * @return 找到的记录，不存在或者journalctl不可用时返回空字符串
+ */
+func findOOMRecord(pid int, since time.Time) string {
+	ctx, cancel := context.WithTimeout(context.Background(), journalctlTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "journalctl", "-k", "-q", "--no-pager",
+		"--since", since.Format("2006-01-02 15:04:05"), "--grep", "Killed process "+strconv.Itoa(pid)+" ").Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.Contains(line, "Killed process "+strconv.Itoa(pid)+" ") {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import (
+	"time"
+)
+
+/*
+ * 非linux系统没有journald，不检查OOM记录
+ */
+func findOOMRecord(pid int, since time.Time) string {
+	return ""
+}
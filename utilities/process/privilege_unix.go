@@ -0,0 +1,160 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+// darwinOpenMax darwin下RLIMIT_NOFILE的软限制不能超过OPEN_MAX
+const darwinOpenMax = 10240
+
+// DropPrivileges switches the process from root to the given user and group
+/*
+ * 降低权限：以root启动、完成绑定低端口以及创建目录等需要权限的操作之后，切换到服务账号运行
+ * 依次设置附加组、gid、uid，之后确认无法再切换回root；go1.16之后setuid对进程的所有线程生效
+ * 切换之前打开的文件以及监听的端口仍然可用，之后创建的日志文件等属于新的账号，需要提前设置好目录的属主
+ * 不是root运行时，目标账号与当前账号相同则直接返回nil，否则返回EPERM
+ * @param username：用户名或者uid
+ * @param group：组名或者gid，为空时使用用户的主组
+ * @return 失败时返回error，此时进程可能已经切换了部分身份，调用方应当直接退出
+ */
+func DropPrivileges(username, group string) error {
+	u, err := lookupUser(username)
+	if err != nil {
+		return fmt.Errorf("drop privileges: %w", err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("drop privileges: uid %q: %w", u.Uid, err)
+	}
+	gidText := u.Gid
+	if group != "" {
+		g, err := lookupGroup(group)
+		if err != nil {
+			return fmt.Errorf("drop privileges: %w", err)
+		}
+		gidText = g.Gid
+	}
+	gid, err := strconv.Atoi(gidText)
+	if err != nil {
+		return fmt.Errorf("drop privileges: gid %q: %w", gidText, err)
+	}
+
+	if os.Geteuid() != 0 {
+		if os.Geteuid() == uid && os.Getegid() == gid {
+			return nil
+		}
+		return fmt.Errorf("drop privileges to %s: %w", username, syscall.EPERM)
+	}
+	if err := syscall.Setgroups(supplementaryGroups(u, gid)); err != nil {
+		return fmt.Errorf("drop privileges: setgroups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("drop privileges: setgid %d: %w", gid, err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("drop privileges: setuid %d: %w", uid, err)
+	}
+	if uid != 0 && syscall.Setuid(0) == nil {
+		return fmt.Errorf("drop privileges: root privileges regained after setuid %d", uid)
+	}
+	return nil
+}
+
+// SetNoFileLimit raises the soft limit of open files to n
+/*
+ * 设置最大打开文件数(RLIMIT_NOFILE)的软限制，服务启动时通常需要提高默认的1024
+ * 超过硬限制时：root运行时同时提高硬限制，否则设置为硬限制；darwin下不超过OPEN_MAX(10240)
+ * @param n：期望的软限制，为0时设置为硬限制
+ * @return (实际生效的软限制, error)
+ */
+func SetNoFileLimit(n uint64) (uint64, error) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, fmt.Errorf("getrlimit nofile: %w", err)
+	}
+	if n == 0 {
+		n = uint64(limit.Max)
+	}
+	max := uint64(limit.Max)
+	if n > max {
+		if os.Geteuid() == 0 {
+			max = n
+		} else {
+			n = max
+		}
+	}
+	if runtime.GOOS == "darwin" && n > darwinOpenMax {
+		n = darwinOpenMax
+	}
+	setRlimit(&limit, n, max)
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, fmt.Errorf("setrlimit nofile %d: %w", n, err)
+	}
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return n, nil
+	}
+	return uint64(limit.Cur), nil
+}
+
+// SetUmask sets the file mode creation mask and returns the previous one
+/*
+ * 设置umask，例如SetUmask(0027)使新建的日志文件不被其他用户读取
+ * @param mask：新的umask
+ * @return 之前的umask
+ */
+func SetUmask(mask int) int {
+	return syscall.Umask(mask)
+}
+
+/*
+ * 按照用户名查找，找不到时按照uid查找
+ */
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+	if _, convErr := strconv.Atoi(name); convErr == nil {
+		return user.LookupId(name)
+	}
+	return nil, err
+}
+
+/*
+ * 按照组名查找，找不到时按照gid查找
+ */
+func lookupGroup(name string) (*user.Group, error) {
+	g, err := user.LookupGroup(name)
+	if err == nil {
+		return g, nil
+	}
+	if _, convErr := strconv.Atoi(name); convErr == nil {
+		return user.LookupGroupId(name)
+	}
+	return nil, err
+}
+
+/*
+ * 用户所属的附加组，包括gid；无法读取组信息时只包含gid
+ */
+func supplementaryGroups(u *user.User, gid int) []int {
+	groups := []int{gid}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return groups
+	}
+	for _, id := range ids {
+		if n, err := strconv.Atoi(id); err == nil && n != gid {
+			groups = append(groups, n)
+		}
+	}
+	return groups
+}
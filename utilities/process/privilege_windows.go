@@ -0,0 +1,25 @@
+package process
+
+import (
+	"syscall"
+)
+
+/*
+ * windows下没有uid/gid、rlimit以及umask：DropPrivileges、SetNoFileLimit返回EWINDOWS，SetUmask不做任何操作
+ * 需要以低权限运行时应当在服务配置中指定运行账号
+ */
+
+// DropPrivileges is not supported on windows
+func DropPrivileges(username, group string) error {
+	return syscall.EWINDOWS
+}
+
+// SetNoFileLimit is not supported on windows
+func SetNoFileLimit(n uint64) (uint64, error) {
+	return 0, syscall.EWINDOWS
+}
+
+// SetUmask does nothing on windows and returns 0
+func SetUmask(mask int) int {
+	return 0
+}
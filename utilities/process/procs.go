@@ -0,0 +1,170 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// killPollInterval Kill等待进程退出时检查的间隔
+const killPollInterval = 50 * time.Millisecond
+
+// ErrStalePidFile is returned by VerifyPidFile when the recorded process is gone or is another program
+var ErrStalePidFile = errors.New("stale pid file")
+
+// ProcessEntry describes a running process
+type ProcessEntry struct {
+	PID  int    // 进程id
+	PPID int    // 父进程id
+	Name string // 可执行文件名，例如saver、saver.exe
+	Path string // 可执行文件的完整路径，没有权限读取时为空
+}
+
+// IsRunning reports whether a process with the given pid exists
+/*
+ * 检查进程是否存在，没有权限向进程发送信号时同样返回true
+ * @param pid：进程id
+ * @return 返回true表示进程存在
+ */
+func IsRunning(pid int) bool {
+	return pidAlive(pid)
+}
+
+// FindByName returns the running processes whose executable name is name
+/*
+ * 按照可执行文件名查找进程，不包括当前进程；windows下不区分大小写，并且可以省略.exe
+ * @param name：可执行文件名，例如saver，也可以是完整路径，此时只比较文件名
+ * @return (匹配的进程, error)；无法枚举进程时返回error
+ */
+func FindByName(name string) ([]ProcessEntry, error) {
+	entries, err := ListProcesses()
+	if err != nil {
+		return nil, err
+	}
+	name = filepath.Base(name)
+	self := os.Getpid()
+	var found []ProcessEntry
+	for _, entry := range entries {
+		if entry.PID != self && matchProcessName(entry, name) {
+			found = append(found, entry)
+		}
+	}
+	return found, nil
+}
+
+// Signal sends sig to the process pid
+/*
+ * 向进程发送信号，windows下只支持os.Kill
+ * @param pid：进程id
+ * @param sig：信号
+ * @return 进程不存在或者没有权限时返回error
+ */
+func Signal(pid int, sig os.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// Kill asks the process to terminate and forces it after grace
+/*
+ * 结束进程：先发送SIGTERM，进程在grace时间内没有退出时发送SIGKILL；windows下没有SIGTERM，直接结束进程
+ * 只能确认进程id已经不存在，不能区分进程id是否被复用
+ * @param pid：进程id
+ * @param grace：等待进程自行退出的时间
+ * @return 进程已经不存在时返回nil；发送信号失败或者SIGKILL之后仍然存在时返回error
+ */
+func Kill(pid int, grace time.Duration) error {
+	if !pidAlive(pid) {
+		return nil
+	}
+	if runtime.GOOS != "windows" {
+		if err := Signal(pid, syscall.SIGTERM); err != nil {
+			if !pidAlive(pid) {
+				return nil
+			}
+			return fmt.Errorf("kill %d: %w", pid, err)
+		}
+		if waitExited(pid, grace) {
+			return nil
+		}
+	}
+	if err := Signal(pid, os.Kill); err != nil && pidAlive(pid) {
+		return fmt.Errorf("kill %d: %w", pid, err)
+	}
+	if !waitExited(pid, time.Second) {
+		return fmt.Errorf("kill %d: process still running", pid)
+	}
+	return nil
+}
+
+// VerifyPidFile reads a peer's pid file and checks that the process is alive
+/*
+ * 读取其他进程的pid文件并校验，例如在管理脚本中向正在运行的实例发送信号之前确认实例存在
+ * @param pidFile：pid文件路径
+ * @param name：期望的可执行文件名，为空时不检查；用于识别进程id已经被其他程序复用的残留pid文件
+ * @return (pid, nil)；进程不存在或者可执行文件名不匹配时返回(pid, 包装ErrStalePidFile的error)，
+ *         文件不存在或者内容无法解析时返回(0, error)
+ */
+func VerifyPidFile(pidFile, name string) (int, error) {
+	pid, err := ReadPid(pidFile)
+	if err != nil {
+		return 0, fmt.Errorf("verify pid %s: %w", pidFile, err)
+	}
+	if !pidAlive(pid) {
+		return pid, fmt.Errorf("verify pid %s: process %d not running: %w", pidFile, pid, ErrStalePidFile)
+	}
+	if name == "" {
+		return pid, nil
+	}
+	entry, err := lookupProcess(pid)
+	if err != nil {
+		// 无法读取进程信息(例如没有权限)时只能以进程存在为准
+		return pid, nil
+	}
+	if !matchProcessName(entry, filepath.Base(name)) {
+		return pid, fmt.Errorf("verify pid %s: process %d is %s: %w", pidFile, pid, entry.Name, ErrStalePidFile)
+	}
+	return pid, nil
+}
+
+/*
+ * 等待进程退出
+ * @return 在timeout之内退出返回true
+ */
+func waitExited(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for pidAlive(pid) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(killPollInterval)
+	}
+	return true
+}
+
+/*
+ * 比较可执行文件名，windows下不区分大小写并忽略.exe
+ */
+func matchProcessName(entry ProcessEntry, name string) bool {
+	candidates := []string{entry.Name}
+	if entry.Path != "" {
+		candidates = append(candidates, filepath.Base(entry.Path))
+	}
+	for _, candidate := range candidates {
+		if runtime.GOOS == "windows" {
+			if strings.TrimSuffix(strings.ToLower(candidate), ".exe") == strings.TrimSuffix(strings.ToLower(name), ".exe") {
+				return true
+			}
+		} else if candidate == name {
+			return true
+		}
+	}
+	return false
+}
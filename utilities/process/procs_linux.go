@@ -0,0 +1,60 @@
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ListProcesses returns the running processes read from /proc
+/*
+ * 枚举所有进程，读取/proc/<pid>/stat以及/proc/<pid>/exe；枚举过程中退出的进程忽略
+ * @return (进程列表, error)；无法读取/proc时返回error
+ */
+func ListProcesses() ([]ProcessEntry, error) {
+	dirs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ProcessEntry, 0, len(dirs))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil || !dir.IsDir() {
+			continue
+		}
+		if entry, err := lookupProcess(pid); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+/*
+ * 读取一个进程的信息，stat格式为 pid (comm) state ppid ...，comm可能包含空格以及括号
+ * comm最多15个字符，能读取/proc/<pid>/exe时使用可执行文件的文件名
+ */
+func lookupProcess(pid int) (ProcessEntry, error) {
+	procDir := "/proc/" + strconv.Itoa(pid)
+	stat, err := ioutil.ReadFile(procDir + "/stat")
+	if err != nil {
+		return ProcessEntry{}, err
+	}
+	open, end := strings.IndexByte(string(stat), '('), strings.LastIndexByte(string(stat), ')')
+	if open < 0 || end < open {
+		return ProcessEntry{}, fmt.Errorf("parse %s/stat: malformed", procDir)
+	}
+	entry := ProcessEntry{PID: pid, Name: string(stat[open+1 : end])}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) > 1 {
+		entry.PPID, _ = strconv.Atoi(fields[1])
+	}
+	if exe, err := os.Readlink(procDir + "/exe"); err == nil {
+		// 可执行文件被替换或者删除之后链接带有 (deleted) 后缀
+		entry.Path = strings.TrimSuffix(exe, " (deleted)")
+		entry.Name = filepath.Base(entry.Path)
+	}
+	return entry, nil
+}
@@ -0,0 +1,71 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ListProcesses returns the running processes reported by ps
+/*
+ * 枚举所有进程，darwin以及BSD没有/proc，通过ps -A获取
+ * @return (进程列表, error)；ps执行失败时返回error
+ */
+func ListProcesses() ([]ProcessEntry, error) {
+	return listPs("-A")
+}
+
+/*
+ * 读取一个进程的信息
+ */
+func lookupProcess(pid int) (ProcessEntry, error) {
+	entries, err := listPs("-p", strconv.Itoa(pid))
+	if err != nil {
+		return ProcessEntry{}, err
+	}
+	if len(entries) == 0 {
+		return ProcessEntry{}, fmt.Errorf("process %d not found", pid)
+	}
+	return entries[0], nil
+}
+
+/*
+ * 执行ps并解析 pid ppid comm 三列，comm为可执行文件路径，可能包含空格
+ */
+func listPs(args ...string) ([]ProcessEntry, error) {
+	out, err := exec.Command("ps", append(args, "-o", "pid=", "-o", "ppid=", "-o", "comm=")...).Output()
+	if err != nil && len(out) == 0 {
+		// ps -p 找不到进程时退出码为1，输出为空
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []ProcessEntry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		comm := strings.Join(fields[2:], " ")
+		entry := ProcessEntry{PID: pid, PPID: ppid, Name: filepath.Base(comm)}
+		if filepath.IsAbs(comm) {
+			entry.Path = comm
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
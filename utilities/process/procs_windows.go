@@ -0,0 +1,72 @@
+package process
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var procQueryFullProcessImageName = syscall.NewLazyDLL("kernel32.dll").NewProc("QueryFullProcessImageNameW")
+
+// ListProcesses returns the running processes from a toolhelp snapshot
+/*
+ * 枚举所有进程，通过CreateToolhelp32Snapshot获取进程快照，完整路径通过QueryFullProcessImageName获取
+ * @return (进程列表, error)；创建快照失败时返回error
+ */
+func ListProcesses() ([]ProcessEntry, error) {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(snapshot)
+
+	var entries []ProcessEntry
+	var pe syscall.ProcessEntry32
+	pe.Size = uint32(unsafe.Sizeof(pe))
+	for err = syscall.Process32First(snapshot, &pe); err == nil; err = syscall.Process32Next(snapshot, &pe) {
+		entry := ProcessEntry{
+			PID:  int(pe.ProcessID),
+			PPID: int(pe.ParentProcessID),
+			Name: syscall.UTF16ToString(pe.ExeFile[:]),
+		}
+		entry.Path = processImagePath(pe.ProcessID)
+		entries = append(entries, entry)
+	}
+	if err != syscall.ERROR_NO_MORE_FILES {
+		return nil, err
+	}
+	return entries, nil
+}
+
+/*
+ * 读取一个进程的信息
+ */
+func lookupProcess(pid int) (ProcessEntry, error) {
+	entries, err := ListProcesses()
+	if err != nil {
+		return ProcessEntry{}, err
+	}
+	for _, entry := range entries {
+		if entry.PID == pid {
+			return entry, nil
+		}
+	}
+	return ProcessEntry{}, fmt.Errorf("process %d not found", pid)
+}
+
+/*
+ * 获取进程的可执行文件完整路径，没有权限打开进程时返回空
+ */
+func processImagePath(pid uint32) string {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer syscall.CloseHandle(h)
+	buf := make([]uint16, syscall.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if r, _, _ := procQueryFullProcessImageName.Call(uintptr(h), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); r == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf[:size])
+}
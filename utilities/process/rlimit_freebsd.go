@@ -0,0 +1,12 @@
+package process
+
+import (
+	"syscall"
+)
+
+/*
+ * 设置rlimit的软限制以及硬限制，freebsd下rlimit的字段为int64
+ */
+func setRlimit(limit *syscall.Rlimit, cur, max uint64) {
+	limit.Cur, limit.Max = int64(cur), int64(max)
+}
@@ -0,0 +1,15 @@
+//go:build !windows && !freebsd
+// +build !windows,!freebsd
+
+package process
+
+import (
+	"syscall"
+)
+
+/*
+ * 设置rlimit的软限制以及硬限制
+ */
+func setRlimit(limit *syscall.Rlimit, cur, max uint64) {
+	limit.Cur, limit.Max = cur, max
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"strconv"
+	"syscall"
+)
+
+/*
+ * 获取当前进程的rlimit信息，用于启动信息输出
+ * @return rlimit列表
+ */
+func rlimitItems() []BannerItem {
+	var items []BannerItem
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err == nil {
+		items = append(items, BannerItem{"rlimit_nofile", formatRlimit(limit)})
+	}
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &limit); err == nil {
+		items = append(items, BannerItem{"rlimit_core", formatRlimit(limit)})
+	}
+	return items
+}
+
+func formatRlimit(limit syscall.Rlimit) string {
+	return strconv.FormatUint(uint64(limit.Cur), 10) + "/" + strconv.FormatUint(uint64(limit.Max), 10)
+}
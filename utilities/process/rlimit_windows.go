@@ -0,0 +1,8 @@
+package process
+
+/*
+ * windows下没有rlimit
+ */
+func rlimitItems() []BannerItem {
+	return nil
+}
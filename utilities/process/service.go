@@ -0,0 +1,93 @@
+package process
+
+import (
+	"context"
+	"time"
+)
+
+// Service describes the lifecycle callbacks of a program run by RunService
+type Service struct {
+	Name     string                          // windows服务名称，与sc create注册的名称一致；systemd以及前台运行时不使用
+	Start    func() error                    // 启动，返回nil之后视为就绪(systemd的READY=1、windows的SERVICE_RUNNING)，不能阻塞
+	Stop     func(ctx context.Context) error // 停止，作为关闭钩子在其他关闭钩子之前执行，可以为nil
+	Pause    func() error                    // windows服务暂停，Pause以及Continue都不为nil时才接受暂停请求
+	Continue func() error                    // windows服务从暂停中恢复
+}
+
+// RunService runs svc in the foreground, as a systemd unit or as a windows service
+/*
+ * 服务运行入口，同一个main函数可以在终端前台运行、作为systemd unit运行或者作为windows服务运行：
+ *   - windows服务：由服务控制管理器启动时注册控制处理函数，响应停止、关机、暂停以及恢复请求
+ *   - systemd：Start成功之后发送READY=1，设置了WatchdogSec时定期发送WATCHDOG=1，退出时发送STOPPING=1；
+ *     Type=notify以外的unit以及前台运行时没有NOTIFY_SOCKET，通知不做任何操作
+ *   - 前台运行：等待SIGINT/SIGTERM(windows下为Ctrl+C)
+ * 收到停止请求之后通过Shutdown执行关闭钩子，Service.Stop最先执行，日志最后flush
+ * 例如:
+ *     process.Exit(process.RunService(process.Service{Name: "saver", Start: start, Stop: server.Shutdown}), "service stopped")
+ * @param svc：服务回调
+ * @return 退出码，Start失败时为ExitFailure
+ */
+func RunService(svc Service) ExitCode {
+	if svc.Stop != nil {
+		OnShutdown(svc.Stop)
+	}
+	if handled, code := runWindowsService(svc); handled {
+		return code
+	}
+	if svc.Start != nil {
+		if err := svc.Start(); err != nil {
+			logServiceError("start failed", err)
+			SdNotify("STATUS=start failed: " + err.Error())
+			return ExitFailure
+		}
+	}
+	// 注册在最后，最先执行
+	OnShutdown(func(ctx context.Context) error {
+		SdNotify("STOPPING=1")
+		return nil
+	})
+	SdNotify("READY=1")
+	stop := startWatchdog()
+	code := WaitForShutdown()
+	stop()
+	return code
+}
+
+/*
+ * 设置了systemd watchdog时按照一半的间隔发送WATCHDOG=1
+ * @return 停止发送的函数
+ */
+func startWatchdog() (stop func()) {
+	interval := SdWatchdogInterval()
+	if interval <= 0 {
+		return func() {}
+	}
+	ticker := time.NewTicker(interval / 2)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				SdNotify("WATCHDOG=1")
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+/*
+ * 服务运行过程中的错误写入SetExitLogger设置的日志
+ */
+func logServiceError(args ...interface{}) {
+	exitLock.Lock()
+	l := exitLogger
+	exitLock.Unlock()
+	if l != nil {
+		l.Error(append([]interface{}{"service"}, args...)...)
+	}
+}
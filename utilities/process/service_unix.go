@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+/*
+ * 只有windows有服务控制管理器
+ */
+func runWindowsService(svc Service) (bool, ExitCode) {
+	return false, ExitOK
+}
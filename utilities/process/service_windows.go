@@ -0,0 +1,197 @@
+package process
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// 服务控制管理器的常量，参考winsvc.h
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped         = 1
+	serviceStartPending    = 2
+	serviceStopPending     = 3
+	serviceRunning         = 4
+	serviceContinuePending = 5
+	servicePausePending    = 6
+	servicePaused          = 7
+
+	serviceAcceptStop           = 0x1
+	serviceAcceptPauseContinue  = 0x2
+	serviceAcceptShutdown       = 0x4
+	serviceControlStop          = 1
+	serviceControlPause         = 2
+	serviceControlContinue      = 3
+	serviceControlInterrogate   = 4
+	serviceControlShutdown      = 5
+	errorServiceSpecificError   = 1066
+	errorFailedServiceConnect   = syscall.Errno(1063)
+	serviceStartWaitHintMillis  = 30000
+	serviceControlQueueCapacity = 8
+)
+
+var (
+	procStartServiceCtrlDispatcher   = syscall.NewLazyDLL("advapi32.dll").NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerEx = syscall.NewLazyDLL("advapi32.dll").NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus             = syscall.NewLazyDLL("advapi32.dll").NewProc("SetServiceStatus")
+)
+
+// serviceStatus SERVICE_STATUS
+type serviceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+// serviceTableEntry SERVICE_TABLE_ENTRYW
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// windowsService 服务运行状态，ServiceMain以及控制处理函数由服务控制管理器在其他线程中回调
+type windowsService struct {
+	svc      Service
+	name     *uint16
+	handle   uintptr
+	status   serviceStatus
+	controls chan uint32
+	exit     chan ExitCode
+}
+
+// runningService 当前运行的服务，回调函数通过它访问服务状态
+var runningService *windowsService
+
+/*
+ * 作为windows服务运行：连接服务控制管理器并阻塞到服务停止
+ * @return (是否由服务控制管理器启动, 退出码)；在控制台中运行时返回(false, ExitOK)，由调用方按照前台方式运行
+ */
+func runWindowsService(svc Service) (bool, ExitCode) {
+	name, err := syscall.UTF16PtrFromString(svc.Name)
+	if err != nil {
+		return false, ExitOK
+	}
+	runningService = &windowsService{
+		svc:      svc,
+		name:     name,
+		controls: make(chan uint32, serviceControlQueueCapacity),
+		exit:     make(chan ExitCode, 1),
+	}
+	table := []serviceTableEntry{{name: name, proc: syscall.NewCallback(serviceMain)}, {}}
+	r, _, err := procStartServiceCtrlDispatcher.Call(uintptr(unsafe.Pointer(&table[0])))
+	if r == 0 {
+		if err == errorFailedServiceConnect {
+			// 不是由服务控制管理器启动
+			return false, ExitOK
+		}
+		logServiceError("StartServiceCtrlDispatcher", err)
+		return true, ExitFailure
+	}
+	select {
+	case code := <-runningService.exit:
+		return true, code
+	default:
+		return true, ExitFailure
+	}
+}
+
+/*
+ * ServiceMain：注册控制处理函数，启动服务并处理控制请求，返回之后服务控制管理器认为服务已经结束
+ */
+func serviceMain(argc, argv uintptr) uintptr {
+	s := runningService
+	handle, _, err := procRegisterServiceCtrlHandlerEx.Call(uintptr(unsafe.Pointer(s.name)), syscall.NewCallback(serviceHandler), 0)
+	if handle == 0 {
+		logServiceError("RegisterServiceCtrlHandlerEx", err)
+		s.exit <- ExitFailure
+		return 0
+	}
+	s.handle = handle
+	s.status.serviceType = serviceWin32OwnProcess
+	s.setState(serviceStartPending, 0, serviceStartWaitHintMillis)
+
+	if s.svc.Start != nil {
+		if err := s.svc.Start(); err != nil {
+			logServiceError("start failed", err)
+			s.stopped(ExitFailure)
+			return 0
+		}
+	}
+	s.status.controlsAccepted = serviceAcceptStop | serviceAcceptShutdown
+	if s.svc.Pause != nil && s.svc.Continue != nil {
+		s.status.controlsAccepted |= serviceAcceptPauseContinue
+	}
+	s.setState(serviceRunning, 0, 0)
+
+	for control := range s.controls {
+		switch control {
+		case serviceControlStop, serviceControlShutdown:
+			s.status.controlsAccepted = 0
+			s.setState(serviceStopPending, 0, uint32(ShutdownTimeout.Milliseconds()))
+			s.stopped(Shutdown("service stop"))
+			return 0
+		case serviceControlPause:
+			s.setState(servicePausePending, 0, 0)
+			if err := s.svc.Pause(); err != nil {
+				logServiceError("pause failed", err)
+				s.setState(serviceRunning, 0, 0)
+			} else {
+				s.setState(servicePaused, 0, 0)
+			}
+		case serviceControlContinue:
+			s.setState(serviceContinuePending, 0, 0)
+			if err := s.svc.Continue(); err != nil {
+				logServiceError("continue failed", err)
+				s.setState(servicePaused, 0, 0)
+			} else {
+				s.setState(serviceRunning, 0, 0)
+			}
+		case serviceControlInterrogate:
+			s.setState(s.status.currentState, 0, 0)
+		}
+	}
+	return 0
+}
+
+/*
+ * HandlerEx：在服务控制管理器的线程中调用，只转发请求，不能阻塞
+ */
+func serviceHandler(control, eventType, eventData, context uintptr) uintptr {
+	select {
+	case runningService.controls <- uint32(control):
+	default:
+	}
+	return 0
+}
+
+/*
+ * 报告服务已经停止以及退出码
+ */
+func (s *windowsService) stopped(code ExitCode) {
+	s.status.controlsAccepted = 0
+	if code == ExitOK {
+		s.status.win32ExitCode = 0
+	} else {
+		s.status.win32ExitCode = errorServiceSpecificError
+		s.status.serviceSpecificExitCode = uint32(code)
+	}
+	s.exit <- code
+	s.setState(serviceStopped, 0, 0)
+}
+
+/*
+ * 报告服务状态
+ */
+func (s *windowsService) setState(state, checkPoint, waitHint uint32) {
+	s.status.currentState = state
+	s.status.checkPoint = checkPoint
+	s.status.waitHint = waitHint
+	if r, _, err := procSetServiceStatus.Call(s.handle, uintptr(unsafe.Pointer(&s.status))); r == 0 {
+		logServiceError("SetServiceStatus", err)
+	}
+}
@@ -0,0 +1,141 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ShutdownTimeout 全部关闭钩子的最长执行时间，超时之后取消钩子的context并且不再等待
+var ShutdownTimeout = 30 * time.Second
+
+// shutdownHook 关闭钩子
+type shutdownHook func(ctx context.Context) error
+
+var (
+	shutdownLock  sync.Mutex
+	shutdownHooks []shutdownHook
+	shutdownOnce  sync.Once
+	shutdownCode  ExitCode
+)
+
+// OnShutdown registers a hook run by Shutdown for graceful draining
+/*
+ * 注册关闭钩子，Shutdown时按照注册的相反顺序依次执行，例如先停止接收请求，再等待处理中的请求结束，最后关闭存储连接
+ * 钩子应当在ctx取消时尽快返回；返回error或者panic时记录日志并继续执行其余钩子，最终的退出码为ExitFailure
+ * 与OnExit的区别：关闭钩子在退出之前执行，可以使用context控制时间，适合耗时的优雅退出；OnExit适合删除pid文件等收尾工作
+ * @param hook：关闭钩子
+ */
+func OnShutdown(hook func(ctx context.Context) error) {
+	shutdownLock.Lock()
+	shutdownHooks = append(shutdownHooks, hook)
+	shutdownLock.Unlock()
+}
+
+// WaitForShutdown blocks until a termination signal is received, then runs Shutdown
+/*
+ * 阻塞直到收到退出信号，之后调用Shutdown并返回退出码，一般在main函数的最后调用，例如
+ *     process.Exit(process.WaitForShutdown(), "shutdown")
+ * 关闭过程中再次收到信号时不再等待钩子，写入日志之后直接以ExitFailure退出
+ * @param sigs：监听的信号，为空时监听SIGINT以及SIGTERM(windows下为Ctrl+C)
+ * @return 退出码
+ */
+func WaitForShutdown(sigs ...os.Signal) ExitCode {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, sigs...)
+	sig := <-ch
+	go func() {
+		sig := <-ch
+		exitLock.Lock()
+		l := exitLogger
+		exitLock.Unlock()
+		if l != nil {
+			l.Error("shutdown", "forced by signal", sig.String())
+			l.Flush()
+		}
+		os.Exit(int(ExitFailure))
+	}()
+	return Shutdown("signal " + sig.String())
+}
+
+// Shutdown runs the shutdown hooks within ShutdownTimeout and flushes the exit logger
+/*
+ * 优雅退出：
+ *   1. 按照注册的相反顺序执行OnShutdown钩子，所有钩子共用一个ShutdownTimeout的context，超时之后不再等待
+ *   2. 将原因以及结果写入SetExitLogger设置的日志，最后同步flush日志
+ * 只会执行一次，重复调用返回第一次的退出码；不会退出进程，由调用方根据退出码调用Exit或者os.Exit
+ * @param reason：退出原因，例如收到的信号
+ * @return 全部钩子成功时返回ExitOK，否则返回ExitFailure
+ */
+func Shutdown(reason string) ExitCode {
+	shutdownOnce.Do(func() {
+		shutdownCode = runShutdown(reason)
+	})
+	return shutdownCode
+}
+
+/*
+ * 执行关闭钩子并flush日志
+ */
+func runShutdown(reason string) ExitCode {
+	shutdownLock.Lock()
+	hooks := append([]shutdownHook{}, shutdownHooks...)
+	shutdownLock.Unlock()
+	exitLock.Lock()
+	l := exitLogger
+	exitLock.Unlock()
+	if l != nil {
+		l.Trace("shutdown", reason, len(hooks))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	var code ExitCode
+	// 超时之后钩子协程仍可能在执行，结果通过channel传递
+	result := make(chan ExitCode, 1)
+	go func() {
+		code := ExitOK
+		for i := len(hooks) - 1; i >= 0; i-- {
+			if err := runShutdownHook(ctx, hooks[i]); err != nil {
+				code = ExitFailure
+				if l != nil {
+					l.Error("shutdown", "hook failed", err)
+				}
+			}
+		}
+		result <- code
+	}()
+	select {
+	case code = <-result:
+	case <-ctx.Done():
+		code = ExitFailure
+		if l != nil {
+			l.Error("shutdown", "hooks timed out", ShutdownTimeout.String())
+		}
+	}
+
+	if l != nil {
+		l.Trace("shutdown", "finished", int(code), code.String())
+		l.Flush()
+	}
+	return code
+}
+
+/*
+ * 执行一个关闭钩子，panic转换为error
+ */
+func runShutdownHook(ctx context.Context, hook shutdownHook) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("hook panic: %v", r)
+		}
+	}()
+	return hook(ctx)
+}
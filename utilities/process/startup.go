@@ -0,0 +1,107 @@
+package process
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// StartupOptions configures StartupSnapshot and LogStartup
+type StartupOptions struct {
+	Version string      // 服务版本，例如通过-ldflags注入的版本号，为空时只输出编译信息中的版本
+	Config  interface{} // 当前生效的配置对象，可以为nil，脱敏规则参考NewStartupBanner
+	Env     []string    // 输出的环境变量名称，以*结尾表示前缀，例如[]string{"APP_*", "TZ"}
+	Redact  []string    // 需要脱敏的环境变量，格式与Env相同；名称包含password/token等关键字的变量总是脱敏
+}
+
+// StartupSnapshot collects the startup banner as flat log fields
+/*
+ * 采集启动信息(参考NewStartupBanner)并展开为日志字段：
+ * 身份、编译以及资源限制信息直接使用名称作为key，配置项以config.为前缀，环境变量以env.为前缀
+ * @param opts：采集选项
+ * @return 启动信息字段
+ */
+func StartupSnapshot(opts StartupOptions) logger.Fields {
+	banner := NewStartupBanner(opts.Config)
+	if opts.Version != "" {
+		banner.Build = append([]BannerItem{{"app_version", opts.Version}}, banner.Build...)
+	}
+	banner.Env = envItems(opts.Env, opts.Redact)
+
+	fields := make(logger.Fields, len(banner.Identity)+len(banner.Build)+len(banner.Limits)+len(banner.Config)+len(banner.Env))
+	for _, items := range [][]BannerItem{banner.Identity, banner.Build, banner.Limits} {
+		for _, item := range items {
+			fields[item.Key] = item.Value
+		}
+	}
+	for _, item := range banner.Config {
+		fields["config."+item.Key] = item.Value
+	}
+	for _, item := range banner.Env {
+		fields["env."+item.Key] = item.Value
+	}
+	return fields
+}
+
+// LogStartup writes the startup snapshot as a single structured record
+/*
+ * 在启动时输出一条包含启动信息的记录，所有服务的第一条日志格式一致，便于检索以及对比：
+ *     process.LogStartup(log, process.StartupOptions{Version: version, Env: []string{"APP_*"}})
+ * 记录通过trace级别输出，内容为startup，启动信息作为附加字段输出，参考StartupSnapshot
+ * 需要按照分组多行输出时使用LogStartupBanner
+ * @param l：日志对象
+ * @param opts：采集选项
+ */
+func LogStartup(l *logger.Logger, opts StartupOptions) {
+	l.WithFields(StartupSnapshot(opts)).Trace("startup")
+}
+
+/*
+ * 选择需要输出的环境变量并脱敏，按照名称排序
+ * @param names：输出的环境变量
+ * @param redact：需要脱敏的环境变量
+ * @return 环境变量列表
+ */
+func envItems(names, redact []string) []BannerItem {
+	if len(names) == 0 {
+		return nil
+	}
+	var items []BannerItem
+	for _, env := range os.Environ() {
+		i := strings.IndexByte(env, '=')
+		if i <= 0 {
+			// windows上以=开头的变量为驱动器的当前目录
+			continue
+		}
+		name, value := env[:i], env[i+1:]
+		if !matchEnv(names, name) {
+			continue
+		}
+		if isSecretKey(name) || matchEnv(redact, name) {
+			value = redactedValue
+		}
+		items = append(items, BannerItem{name, value})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+	return items
+}
+
+/*
+ * 判断环境变量名称是否匹配，以*结尾的模式按照前缀匹配
+ */
+func matchEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
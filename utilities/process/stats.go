@@ -0,0 +1,123 @@
+package process
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// ResourceStats is a sample of the resource usage of a process
+type ResourceStats struct {
+	PID        int       // 进程id
+	CPUPercent float64   // 自上一次对同一进程采样以来的平均CPU使用率，100表示占满一个核；第一次采样为进程启动以来的平均值
+	RSS        uint64    // 常驻内存，单位字节(windows为工作集)
+	FDs        int       // 打开的文件描述符数量(windows为句柄数)，没有权限读取时为-1
+	Threads    int       // 线程数量
+	SampledAt  time.Time // 采样时间
+}
+
+// processUsage 平台相关的原始采样数据
+type processUsage struct {
+	cpu     time.Duration // 累计的用户态以及内核态CPU时间
+	started time.Time     // 进程启动时间
+	rss     uint64
+	fds     int
+	threads int
+}
+
+// cpuSample 上一次采样的CPU时间，用于计算两次采样之间的CPU使用率
+type cpuSample struct {
+	cpu time.Duration
+	at  time.Time
+}
+
+var (
+	cpuSampleLock sync.Mutex
+	cpuSamples    = make(map[int]cpuSample)
+)
+
+// SelfStats samples the resource usage of the current process
+/*
+ * 采样当前进程的资源使用情况，参考Stats
+ * @return (采样结果, error)
+ */
+func SelfStats() (ResourceStats, error) {
+	return Stats(os.Getpid())
+}
+
+// Stats samples the resource usage of process pid
+/*
+ * 采样进程的CPU使用率、常驻内存、打开的文件描述符数量以及线程数量，linux读取/proc，windows调用Win32接口
+ * CPU使用率为与上一次对同一进程采样之间的平均值，定期采样时即为每个周期的使用率
+ * @param pid：进程id
+ * @return (采样结果, error)；进程不存在、没有权限或者平台不支持时返回error
+ */
+func Stats(pid int) (ResourceStats, error) {
+	usage, err := readUsage(pid)
+	now := time.Now()
+	cpuSampleLock.Lock()
+	defer cpuSampleLock.Unlock()
+	if err != nil {
+		delete(cpuSamples, pid)
+		return ResourceStats{}, err
+	}
+	last, ok := cpuSamples[pid]
+	if !ok || usage.cpu < last.cpu {
+		// 第一次采样或者进程id被复用，从进程启动开始计算
+		last = cpuSample{at: usage.started}
+	}
+	cpuSamples[pid] = cpuSample{cpu: usage.cpu, at: now}
+
+	stats := ResourceStats{PID: pid, RSS: usage.rss, FDs: usage.fds, Threads: usage.threads, SampledAt: now}
+	if elapsed := now.Sub(last.at); elapsed > 0 {
+		stats.CPUPercent = float64(usage.cpu-last.cpu) / float64(elapsed) * 100
+	}
+	return stats, nil
+}
+
+// LogStats periodically writes the resource usage of the current process to l
+/*
+ * 定期采样当前进程的资源使用情况写入trace日志，内容为stats，附带cpu、rss、fds、threads字段
+ * 采样失败时写入warn日志
+ * @param l：日志对象
+ * @param interval：采样间隔，<=0时为1分钟
+ * @return 停止采样的函数，可以注册为退出钩子：process.OnExit(process.LogStats(log, time.Minute))
+ */
+func LogStats(l *logger.Logger, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	// 初始化CPU采样，第一次输出即为第一个周期的使用率
+	SelfStats()
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				stats, err := SelfStats()
+				if err != nil {
+					l.Warn("stats", err)
+					continue
+				}
+				l.WithFields(logger.Fields{
+					"cpu":     float64(int(stats.CPUPercent*10)) / 10,
+					"rss":     stats.RSS,
+					"fds":     stats.FDs,
+					"threads": stats.Threads,
+				}).Trace("stats")
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks /proc中CPU时间的单位(USER_HZ)，linux各架构均为100
+const clockTicks = 100
+
+// 系统启动时间，第一次使用时从/proc/stat读取
+var (
+	bootTimeOnce sync.Once
+	bootTime     time.Time
+)
+
+/*
+ * 从/proc/<pid>/stat读取CPU时间、线程数、启动时间以及常驻内存，统计/proc/<pid>/fd中的文件描述符
+ */
+func readUsage(pid int) (processUsage, error) {
+	procDir := "/proc/" + strconv.Itoa(pid)
+	stat, err := ioutil.ReadFile(procDir + "/stat")
+	if err != nil {
+		return processUsage{}, err
+	}
+	// comm可能包含空格以及括号，从最后一个)之后开始解析，fields[0]为第3列state
+	end := strings.LastIndexByte(string(stat), ')')
+	fields := strings.Fields(string(stat[end+1:]))
+	if end < 0 || len(fields) < 22 {
+		return processUsage{}, fmt.Errorf("parse %s/stat: malformed", procDir)
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+	startTicks, _ := strconv.ParseUint(fields[19], 10, 64)
+	rssPages, _ := strconv.ParseUint(fields[21], 10, 64)
+
+	bootTimeOnce.Do(loadBootTime)
+	usage := processUsage{
+		cpu:     time.Duration(utime+stime) * time.Second / clockTicks,
+		started: bootTime.Add(time.Duration(startTicks) * time.Second / clockTicks),
+		rss:     rssPages * uint64(os.Getpagesize()),
+		fds:     -1,
+		threads: threads,
+	}
+	if fds, err := ioutil.ReadDir(procDir + "/fd"); err == nil {
+		usage.fds = len(fds)
+	}
+	return usage, nil
+}
+
+/*
+ * 从/proc/stat的btime行读取系统启动时间
+ */
+func loadBootTime() {
+	stat, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			if sec, err := strconv.ParseInt(strings.TrimSpace(line[len("btime "):]), 10, 64); err == nil {
+				bootTime = time.Unix(sec, 0)
+			}
+			return
+		}
+	}
+}
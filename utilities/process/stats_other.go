@@ -0,0 +1,15 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package process
+
+import (
+	"errors"
+)
+
+/*
+ * darwin以及BSD暂不支持资源采样
+ */
+func readUsage(pid int) (processUsage, error) {
+	return processUsage{}, errors.New("process: resource stats not supported on this platform")
+}
@@ -0,0 +1,89 @@
+package process
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	procGetProcessMemoryInfo  = syscall.NewLazyDLL("psapi.dll").NewProc("GetProcessMemoryInfo")
+	procGetProcessHandleCount = syscall.NewLazyDLL("kernel32.dll").NewProc("GetProcessHandleCount")
+)
+
+// processMemoryCounters PROCESS_MEMORY_COUNTERS
+type processMemoryCounters struct {
+	cb                         uint32
+	pageFaultCount             uint32
+	peakWorkingSetSize         uintptr
+	workingSetSize             uintptr
+	quotaPeakPagedPoolUsage    uintptr
+	quotaPagedPoolUsage        uintptr
+	quotaPeakNonPagedPoolUsage uintptr
+	quotaNonPagedPoolUsage     uintptr
+	pagefileUsage              uintptr
+	peakPagefileUsage          uintptr
+}
+
+/*
+ * 通过GetProcessTimes、GetProcessMemoryInfo、GetProcessHandleCount采样，线程数从进程快照中获取
+ */
+func readUsage(pid int) (processUsage, error) {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return processUsage{}, err
+	}
+	defer syscall.CloseHandle(h)
+
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return processUsage{}, err
+	}
+	usage := processUsage{
+		// FILETIME的单位为100纳秒
+		cpu:     time.Duration(filetimeTicks(kernel)+filetimeTicks(user)) * 100,
+		started: time.Unix(0, creation.Nanoseconds()),
+		fds:     -1,
+	}
+
+	var counters processMemoryCounters
+	counters.cb = uint32(unsafe.Sizeof(counters))
+	if r, _, err := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&counters)), uintptr(counters.cb)); r == 0 {
+		return processUsage{}, fmt.Errorf("GetProcessMemoryInfo: %w", err)
+	}
+	usage.rss = uint64(counters.workingSetSize)
+
+	var handles uint32
+	if r, _, _ := procGetProcessHandleCount.Call(uintptr(h), uintptr(unsafe.Pointer(&handles))); r != 0 {
+		usage.fds = int(handles)
+	}
+	usage.threads, err = processThreads(uint32(pid))
+	return usage, err
+}
+
+/*
+ * FILETIME转换为100纳秒的计数
+ */
+func filetimeTicks(ft syscall.Filetime) int64 {
+	return int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime)
+}
+
+/*
+ * 从进程快照中获取线程数
+ */
+func processThreads(pid uint32) (int, error) {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.CloseHandle(snapshot)
+	var pe syscall.ProcessEntry32
+	pe.Size = uint32(unsafe.Sizeof(pe))
+	for err = syscall.Process32First(snapshot, &pe); err == nil; err = syscall.Process32Next(snapshot, &pe) {
+		if pe.ProcessID == pid {
+			return int(pe.Threads), nil
+		}
+	}
+	return 0, fmt.Errorf("process %d not found", pid)
+}
@@ -0,0 +1,543 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// RestartPolicy decides whether the supervisor restarts a child after it exits
+type RestartPolicy int
+
+// 重启策略
+const (
+	RestartNever     RestartPolicy = iota // 退出后不再重启
+	RestartAlways                         // 无论退出码是什么都重启
+	RestartOnFailure                      // 退出码不为0或者被信号终止时重启
+)
+
+// 子进程状态
+const (
+	ChildStopped  = "stopped"  // 未启动或者已经通过Stop停止
+	ChildRunning  = "running"  // 运行中
+	ChildBackoff  = "backoff"  // 等待重启
+	ChildExited   = "exited"   // 退出之后按照重启策略不再重启
+	ChildFatal    = "fatal"    // 连续重启次数超过MaxRetries，不再重启
+	ChildStopping = "stopping" // 正在停止
+)
+
+// 子进程配置的默认值
+const (
+	defaultRestartBackoff    = time.Second
+	defaultRestartMaxBackoff = time.Minute
+	defaultStableTime        = time.Minute
+	defaultStopTimeout       = 10 * time.Second
+	maxChildLineSize         = 1024 * 1024
+)
+
+// 子进程管理的错误
+var (
+	ErrChildNotFound = errors.New("process: child not found")
+	ErrChildRunning  = errors.New("process: child already running")
+)
+
+// ChildSpec describes a child command managed by a Supervisor
+type ChildSpec struct {
+	Name        string        // 名称，同一个Supervisor中唯一，同时作为默认的日志通道名称
+	Path        string        // 可执行文件，不包含路径分隔符时从PATH中查找
+	Args        []string      // 命令行参数，不包括Path本身
+	Dir         string        // 工作目录，为空时使用当前目录
+	Env         []string      // 追加的环境变量，格式为 key=value
+	Restart     RestartPolicy // 重启策略
+	MaxRetries  int           // 连续重启的最大次数，<=0表示不限制
+	Backoff     time.Duration // 第一次重启之前的等待时间，之后每次加倍，<=0时为1秒
+	MaxBackoff  time.Duration // 重启等待时间的上限，<=0时为1分钟
+	StableTime  time.Duration // 运行超过该时间之后退出不计入连续重启次数，<=0时为1分钟
+	StopTimeout time.Duration // Stop发送SIGTERM之后等待退出的时间，超时之后强制结束，<=0时为10秒
+	Channel     string        // 标准输出以及标准错误写入的日志通道，为空时使用Name
+
+	// Signals 转发信号时的转换，例如{SIGHUP: SIGUSR1}表示父进程收到SIGHUP时向该子进程发送SIGUSR1；
+	// 转换为0表示不向该子进程转发，没有配置的信号原样转发，参考Supervisor.Forward
+	Signals map[syscall.Signal]syscall.Signal
+}
+
+// ChildStatus is a snapshot of the state of a supervised child
+type ChildStatus struct {
+	Name      string    // 名称
+	State     string    // 状态，取值为Child开头的常量
+	PID       int       // 运行中的进程id，未运行时为0
+	Restarts  int       // 累计重启次数
+	Retries   int       // 连续重启次数，运行超过StableTime之后清零
+	StartedAt time.Time // 最近一次启动的时间
+	ExitedAt  time.Time // 最近一次退出的时间
+	ExitCode  int       // 最近一次的退出码，被信号终止时为-1
+	LastError string    // 最近一次启动失败或者异常退出的原因
+}
+
+// Supervisor starts child commands and restarts them according to their policy
+/*
+ * 子进程管理：启动子命令，将子进程的标准输出以及标准错误按行写入日志通道，退出后按照重启策略重启，
+ * 适用于agent类部署中由一个进程拉起多个组件，例如：
+ *     sup := process.NewSupervisor(log)
+ *     sup.Add(process.ChildSpec{Name: "exporter", Path: "/usr/local/bin/exporter", Restart: process.RestartOnFailure, MaxRetries: 5})
+ *     sup.Start("exporter")
+ *     process.OnShutdown(sup.Shutdown)
+ * 子进程在独立的进程组中启动，Stop时向整个进程组发送信号；windows下Stop直接结束子进程
+ * 通过ForwardSignals将父进程收到的SIGTERM/SIGINT/SIGHUP转发给子进程，可以按子进程转换信号，参考Forward
+ * 可以在多个协程中同时使用
+ */
+type Supervisor struct {
+	log      *logger.Logger
+	mu       sync.Mutex
+	children map[string]*supervisedChild
+}
+
+// supervisedChild 一个被管理的子进程
+type supervisedChild struct {
+	spec   ChildSpec
+	output *logger.Channel
+
+	mu      sync.Mutex
+	status  ChildStatus
+	process *os.Process
+	stop    chan struct{} // Stop时关闭，运行循环退出重启等待
+	done    chan struct{} // 运行循环退出时关闭，未运行时为nil
+}
+
+// NewSupervisor creates a supervisor logging to l
+/*
+ * 创建子进程管理对象
+ * @param l：日志对象，子进程的启动、退出以及重启写入级别日志，输出写入通道；为nil时不写日志并丢弃子进程输出
+ * @return 子进程管理对象
+ */
+func NewSupervisor(l *logger.Logger) *Supervisor {
+	return &Supervisor{log: l, children: make(map[string]*supervisedChild)}
+}
+
+// Add registers a child without starting it
+/*
+ * 注册子进程，之后通过Start启动
+ * @param spec：子进程配置
+ * @return 名称为空、Path为空或者名称重复时返回error
+ */
+func (s *Supervisor) Add(spec ChildSpec) error {
+	if spec.Name == "" || spec.Path == "" {
+		return errors.New("process: child name and path are required")
+	}
+	if spec.Backoff <= 0 {
+		spec.Backoff = defaultRestartBackoff
+	}
+	if spec.MaxBackoff <= 0 {
+		spec.MaxBackoff = defaultRestartMaxBackoff
+	}
+	if spec.StableTime <= 0 {
+		spec.StableTime = defaultStableTime
+	}
+	if spec.StopTimeout <= 0 {
+		spec.StopTimeout = defaultStopTimeout
+	}
+	if spec.Channel == "" {
+		spec.Channel = spec.Name
+	}
+	child := &supervisedChild{spec: spec, status: ChildStatus{Name: spec.Name, State: ChildStopped}}
+	if s.log != nil {
+		child.output = s.log.Channel(spec.Channel)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.children[spec.Name]; ok {
+		return fmt.Errorf("process: child %q already added", spec.Name)
+	}
+	s.children[spec.Name] = child
+	return nil
+}
+
+// Start starts a registered child and keeps it running according to its policy
+/*
+ * 启动子进程，之后由后台协程按照重启策略维持运行
+ * 第一次启动失败(例如可执行文件不存在)同样按照重启策略处理，可以通过Status查看原因
+ * @param name：子进程名称
+ * @return 未注册时返回ErrChildNotFound，已经在运行(包括等待重启)时返回ErrChildRunning
+ */
+func (s *Supervisor) Start(name string) error {
+	child, err := s.child(name)
+	if err != nil {
+		return err
+	}
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	if child.done != nil {
+		return ErrChildRunning
+	}
+	child.stop = make(chan struct{})
+	child.done = make(chan struct{})
+	child.status.Retries = 0
+	go s.run(child, child.stop, child.done)
+	return nil
+}
+
+// Stop stops a child and disables its restart
+/*
+ * 停止子进程：向子进程所在的进程组发送SIGTERM，超过StopTimeout之后发送SIGKILL，等待子进程退出之后返回
+ * 子进程未运行时直接返回
+ * @param name：子进程名称
+ * @return 未注册时返回ErrChildNotFound
+ */
+func (s *Supervisor) Stop(name string) error {
+	child, err := s.child(name)
+	if err != nil {
+		return err
+	}
+	s.stopChild(child, syscall.SIGTERM)
+	return nil
+}
+
+// Status returns the status of a child
+/*
+ * 获取子进程状态
+ * @param name：子进程名称
+ * @return (状态, error)；未注册时返回ErrChildNotFound
+ */
+func (s *Supervisor) Status(name string) (ChildStatus, error) {
+	child, err := s.child(name)
+	if err != nil {
+		return ChildStatus{}, err
+	}
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	return child.status, nil
+}
+
+// Statuses returns the status of every child ordered by name
+func (s *Supervisor) Statuses() []ChildStatus {
+	children := s.all()
+	statuses := make([]ChildStatus, 0, len(children))
+	for _, child := range children {
+		child.mu.Lock()
+		statuses = append(statuses, child.status)
+		child.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Shutdown stops every child, usable as an OnShutdown hook
+/*
+ * 同时停止所有子进程，可以直接注册为关闭钩子：process.OnShutdown(sup.Shutdown)
+ * @param ctx：等待的最长时间，到期时不再等待，子进程仍会在各自的StopTimeout之后被强制结束
+ * @return ctx到期时返回ctx.Err()
+ */
+func (s *Supervisor) Shutdown(ctx context.Context) error {
+	children := s.all()
+	var wg sync.WaitGroup
+	for _, child := range children {
+		wg.Add(1)
+		go func(child *supervisedChild) {
+			defer wg.Done()
+			s.stopChild(child, syscall.SIGTERM)
+		}(child)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+ * 按照名称查找子进程
+ */
+func (s *Supervisor) child(name string) (*supervisedChild, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	child, ok := s.children[name]
+	if !ok {
+		return nil, ErrChildNotFound
+	}
+	return child, nil
+}
+
+/*
+ * 获取所有子进程的快照，避免在持有锁的情况下操作子进程
+ */
+func (s *Supervisor) all() []*supervisedChild {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	children := make([]*supervisedChild, 0, len(s.children))
+	for _, child := range s.children {
+		children = append(children, child)
+	}
+	return children
+}
+
+/*
+ * 停止子进程并等待运行循环退出
+ * @param sig：停止时发送的信号，超过StopTimeout之后发送SIGKILL
+ */
+func (s *Supervisor) stopChild(child *supervisedChild, sig syscall.Signal) {
+	child.mu.Lock()
+	done := child.done
+	if done == nil {
+		child.mu.Unlock()
+		return
+	}
+	select {
+	case <-child.stop:
+	default:
+		close(child.stop)
+	}
+	p := child.process
+	if p != nil {
+		child.status.State = ChildStopping
+	}
+	child.mu.Unlock()
+
+	if p != nil {
+		terminateChild(p, sig)
+		timer := time.NewTimer(child.spec.StopTimeout)
+		select {
+		case <-done:
+			timer.Stop()
+		case <-timer.C:
+			s.logf("warn", child, "stop timed out, killing", child.spec.StopTimeout.String())
+			terminateChild(p, syscall.SIGKILL)
+		}
+	}
+	<-done
+}
+
+/*
+ * 运行循环：启动子进程，等待退出，按照重启策略决定是否重启
+ */
+func (s *Supervisor) run(child *supervisedChild, stop, done chan struct{}) {
+	defer func() {
+		child.mu.Lock()
+		child.process = nil
+		child.done = nil
+		child.mu.Unlock()
+		close(done)
+	}()
+	spec := child.spec
+	for {
+		started := time.Now()
+		exitCode, err := s.runOnce(child, stop)
+
+		child.mu.Lock()
+		status := &child.status
+		status.PID = 0
+		status.ExitedAt = time.Now()
+		status.ExitCode = exitCode
+		status.LastError = ""
+		if err != nil {
+			status.LastError = err.Error()
+		}
+		select {
+		case <-stop:
+			status.State = ChildStopped
+			child.mu.Unlock()
+			s.logf("trace", child, "stopped", exitCode)
+			return
+		default:
+		}
+		if spec.Restart == RestartNever || spec.Restart == RestartOnFailure && err == nil {
+			status.State = ChildExited
+			child.mu.Unlock()
+			s.logf("trace", child, "exited", exitCode)
+			return
+		}
+		if time.Since(started) >= spec.StableTime {
+			status.Retries = 0
+		}
+		if spec.MaxRetries > 0 && status.Retries >= spec.MaxRetries {
+			status.State = ChildFatal
+			retries := status.Retries
+			child.mu.Unlock()
+			s.logf("error", child, "too many restarts, giving up", retries, err)
+			return
+		}
+		status.Retries++
+		status.Restarts++
+		status.State = ChildBackoff
+		backoff := restartBackoff(spec, status.Retries)
+		child.mu.Unlock()
+		s.logf("warn", child, "exited, restarting", exitCode, err, backoff.String())
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			child.mu.Lock()
+			child.status.State = ChildStopped
+			child.mu.Unlock()
+			return
+		}
+	}
+}
+
+/*
+ * 启动一次子进程并等待退出
+ * @return (退出码, error)；正常退出时error为nil，启动失败时退出码为-1
+ */
+func (s *Supervisor) runOnce(child *supervisedChild, stop chan struct{}) (int, error) {
+	spec := child.spec
+	cmd := exec.Command(spec.Path, spec.Args...)
+	cmd.Dir = spec.Dir
+	if len(spec.Env) > 0 {
+		cmd.Env = append(os.Environ(), spec.Env...)
+	}
+	StartInNewGroup(cmd)
+
+	// 使用管道而不是io.Writer，子进程派生的后台进程持有输出时Wait不会被阻塞
+	var pipes []*os.File
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return -1, err
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		return -1, err
+	}
+	pipes = append(pipes, stdoutW, stderrW)
+	cmd.Stdout, cmd.Stderr = stdoutW, stderrW
+
+	child.mu.Lock()
+	select {
+	case <-stop:
+		child.mu.Unlock()
+		stdoutR.Close()
+		stderrR.Close()
+		for _, w := range pipes {
+			w.Close()
+		}
+		return 0, nil
+	default:
+	}
+	err = cmd.Start()
+	for _, w := range pipes {
+		w.Close()
+	}
+	if err != nil {
+		child.mu.Unlock()
+		stdoutR.Close()
+		stderrR.Close()
+		s.logf("error", child, "start failed", err)
+		return -1, err
+	}
+	child.process = cmd.Process
+	child.status.State = ChildRunning
+	child.status.PID = cmd.Process.Pid
+	child.status.StartedAt = time.Now()
+	child.mu.Unlock()
+	s.logf("trace", child, "started", cmd.Process.Pid)
+
+	go copyLines(stdoutR, &childWriter{output: child.output, stream: "stdout"})
+	go copyLines(stderrR, &childWriter{output: child.output, stream: "stderr"})
+	err = cmd.Wait()
+
+	child.mu.Lock()
+	child.process = nil
+	child.mu.Unlock()
+	if cmd.ProcessState != nil {
+		return cmd.ProcessState.ExitCode(), err
+	}
+	return -1, err
+}
+
+/*
+ * 写入级别日志，未设置日志对象时忽略
+ */
+func (s *Supervisor) logf(level string, child *supervisedChild, args ...interface{}) {
+	if s.log == nil {
+		return
+	}
+	s.log.Log(level, append([]interface{}{"supervisor", child.spec.Name}, args...)...)
+}
+
+/*
+ * 第retries次重启之前的等待时间：Backoff * 2^(retries-1)，不超过MaxBackoff
+ */
+func restartBackoff(spec ChildSpec, retries int) time.Duration {
+	backoff := spec.Backoff
+	for i := 1; i < retries && backoff < spec.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > spec.MaxBackoff {
+		backoff = spec.MaxBackoff
+	}
+	return backoff
+}
+
+/*
+ * 向子进程所在的进程组发送信号，不支持进程组(windows)时直接结束子进程
+ */
+func terminateChild(p *os.Process, sig syscall.Signal) {
+	if runtime.GOOS == "windows" {
+		p.Kill()
+		return
+	}
+	SignalGroup(p.Pid, sig)
+}
+
+// childWriter 将子进程的一行输出写入日志通道，记录为 stdout|内容 或者 stderr|内容
+type childWriter struct {
+	output *logger.Channel
+	stream string
+}
+
+// Write implements io.Writer
+func (w *childWriter) Write(p []byte) (int, error) {
+	if w.output != nil {
+		w.output.Write(w.stream, string(p))
+	}
+	return len(p), nil
+}
+
+/*
+ * 按行复制，保证一行不会被拆分为多条记录，超过1MB的行截断
+ */
+func copyLines(r io.ReadCloser, w io.Writer) {
+	defer r.Close()
+	reader := bufio.NewReaderSize(r, 4096)
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if room := maxChildLineSize - len(line); room > 0 {
+			if len(chunk) > room {
+				chunk = chunk[:room]
+			}
+			line = append(line, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == nil || len(line) > 0 {
+			w.Write(bytes.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+		line = line[:0]
+	}
+}
@@ -0,0 +1,119 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// listenFdsStart systemd传递的第一个socket的文件描述符(SD_LISTEN_FDS_START)
+const listenFdsStart = 3
+
+// SdNotify sends a state notification to systemd
+/*
+ * 向systemd发送状态通知(sd_notify)，例如READY=1、STOPPING=1、WATCHDOG=1、STATUS=...，多个状态用换行分隔
+ * 只有Type=notify的unit才会设置NOTIFY_SOCKET，没有设置时不做任何操作
+ * @param state：通知内容
+ * @return (是否已经发送, error)；没有NOTIFY_SOCKET时返回(false, nil)
+ */
+func SdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// 以@开头的抽象socket由net包转换为\0
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SdWatchdogInterval returns the watchdog timeout systemd expects WATCHDOG=1 within
+/*
+ * 获取systemd的watchdog超时时间(WatchdogSec)，需要在超时之内发送WATCHDOG=1，RunService会自动发送
+ * @return 超时时间，没有开启watchdog或者WATCHDOG_PID不是当前进程时返回0
+ */
+func SdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// ActivationFiles returns the sockets passed by systemd socket activation
+/*
+ * 获取systemd socket激活传递的文件描述符(sd_listen_fds)，文件名称为FileDescriptorName，没有设置时为LISTEN_FD_<fd>
+ * 获取之后清除LISTEN_PID、LISTEN_FDS、LISTEN_FDNAMES环境变量并设置close-on-exec，
+ * 因此只有第一次调用返回结果，子进程也不会误用
+ * @return 文件列表，不是socket激活启动时返回nil
+ */
+func ActivationFiles() []*os.File {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	files := make([]*os.File, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		syscall.CloseOnExec(fd)
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		files = append(files, os.NewFile(uintptr(fd), name))
+	}
+	return files
+}
+
+// ActivationListeners returns the stream sockets passed by systemd socket activation as listeners
+/*
+ * 获取systemd socket激活传递的监听socket，例如：
+ *     listeners, err := process.ActivationListeners()
+ *     if len(listeners) > 0 { server.Serve(listeners[0]) }
+ * 与ActivationFiles相同只有第一次调用返回结果；不是监听socket(例如ListenDatagram)的文件描述符被跳过，并返回第一个转换错误
+ * @return (监听列表, error)；不是socket激活启动时返回(nil, nil)
+ */
+func ActivationListeners() ([]net.Listener, error) {
+	files := ActivationFiles()
+	listeners := make([]net.Listener, 0, len(files))
+	var firstErr error
+	for _, f := range files {
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		listeners = append(listeners, l)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return listeners, firstErr
+}
@@ -0,0 +1,31 @@
+package process
+
+import (
+	"net"
+	"os"
+	"time"
+)
+
+/*
+ * windows下没有systemd：通知不做任何操作，没有watchdog以及socket激活
+ */
+
+// SdNotify does nothing on windows
+func SdNotify(state string) (bool, error) {
+	return false, nil
+}
+
+// SdWatchdogInterval returns 0 on windows
+func SdWatchdogInterval() time.Duration {
+	return 0
+}
+
+// ActivationFiles returns nil on windows
+func ActivationFiles() []*os.File {
+	return nil
+}
+
+// ActivationListeners returns no listeners on windows
+func ActivationListeners() ([]net.Listener, error) {
+	return nil, nil
+}
@@ -0,0 +1,215 @@
+package process
+
+import (
+	"context"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+	"github.com/lucifinil-long/nano-legion/utilities/ratelimit"
+)
+
+// Throttler的默认参数
+const (
+	defaultThrottleInterval = time.Second
+	defaultThrottleMaxPause = time.Minute
+)
+
+var _ logger.Throttle = (*Throttler)(nil)
+
+// ThrottleConfig configures NewThrottler
+type ThrottleConfig struct {
+	MaxCPU    float64       // 进程CPU使用率上限，100表示占满一个核，超过时暂停后台任务；<=0表示不检查
+	Busy      func() bool   // 额外的繁忙判断，例如磁盘IO等待较高，返回true时暂停；可以为nil
+	QPS       float64       // 每秒最多开始的后台任务数，<=0表示不限制
+	Bandwidth int64         // 后台任务每秒最多处理的字节数，<=0表示不限制
+	Interval  time.Duration // CPU采样以及繁忙时重新检查的间隔，<=0时为1秒
+	MaxPause  time.Duration // 每个任务最多等待的时间，超过之后照常执行，避免后台任务一直得不到执行；<=0时为1分钟
+}
+
+// ThrottleStats reports how much background work a Throttler has held back
+type ThrottleStats struct {
+	Waits     uint64        // Wait的调用次数
+	Paused    uint64        // 因为繁忙暂停过的任务数
+	PauseTime time.Duration // 因为繁忙以及限速累计等待的时间
+	Overruns  uint64        // 等待超过MaxPause之后照常执行的任务数
+}
+
+// Throttler subordinates background jobs to the load of the current process
+/*
+ * 后台任务节流：日志压缩、备份上传、数据清理等维护任务开始之前调用Wait，
+ * 当前进程CPU使用率超过MaxCPU(或者Busy返回true)时暂停，直到负载降下来，同时按照QPS以及Bandwidth限速，
+ * 保证维护任务不与业务流量争抢资源，例如：
+ *     throttle := process.NewThrottler(process.ThrottleConfig{MaxCPU: 150, Bandwidth: 20 * logger.MB})
+ *     log, err := logger.NewLogger(name, suffix, backupDir, logger.WithThrottle(throttle), logger.WithCompression(logger.CompressOnRotate))
+ * CPU使用率与SelfStats的采样方式相同，但是使用单独的采样点，不影响SelfStats以及LogStats的统计周期
+ * 不支持资源采样的平台上MaxCPU不生效；可以在多个协程中同时使用，实现了logger.Throttle
+ */
+type Throttler struct {
+	config    ThrottleConfig
+	qps       *ratelimit.TokenBucket // 为nil表示不限制
+	bandwidth *ratelimit.TokenBucket // 为nil表示不限制
+
+	mu        sync.Mutex
+	last      cpuSample // 上一次CPU采样
+	busy      bool      // 最近一次检查的结果
+	checkedAt time.Time // 最近一次检查的时间
+
+	waits     uint64 // 原子操作访问，以下相同
+	paused    uint64
+	pauseTime int64
+	overruns  uint64
+}
+
+// NewThrottler creates a background job throttler
+/*
+ * 创建后台任务节流对象
+ * @param config：节流配置
+ * @return 节流对象
+ */
+func NewThrottler(config ThrottleConfig) *Throttler {
+	if config.Interval <= 0 {
+		config.Interval = defaultThrottleInterval
+	}
+	if config.MaxPause <= 0 {
+		config.MaxPause = defaultThrottleMaxPause
+	}
+	t := &Throttler{config: config}
+	if config.QPS > 0 {
+		t.qps = ratelimit.NewTokenBucket(config.QPS, 0)
+	}
+	if config.Bandwidth > 0 {
+		t.bandwidth = ratelimit.NewTokenBucket(float64(config.Bandwidth), int(config.Bandwidth))
+	}
+	return t
+}
+
+// Wait blocks until a background task of size bytes may start
+/*
+ * 等待后台任务可以开始：先等待进程不再繁忙，再按照QPS以及Bandwidth限速
+ * 总等待时间不超过MaxPause，超过之后直接返回nil，任务照常执行
+ * @param ctx：取消时返回ctx.Err()
+ * @param size：任务处理的字节数，用于带宽限制，<=0表示不限制
+ * @return ctx取消时返回ctx.Err()，否则返回nil
+ */
+func (t *Throttler) Wait(ctx context.Context, size int64) error {
+	atomic.AddUint64(&t.waits, 1)
+	start := time.Now()
+	waitCtx, cancel := context.WithDeadline(ctx, start.Add(t.config.MaxPause))
+	defer cancel()
+	defer func() {
+		atomic.AddInt64(&t.pauseTime, int64(time.Since(start)))
+	}()
+
+	paused := false
+	for t.Busy(waitCtx) {
+		if !paused {
+			paused = true
+			atomic.AddUint64(&t.paused, 1)
+		}
+		if !sleepContext(waitCtx, t.config.Interval) {
+			break
+		}
+	}
+	if t.qps != nil {
+		t.qps.Wait(waitCtx)
+	}
+	if t.bandwidth != nil && size > 0 {
+		if size > math.MaxInt32 {
+			size = math.MaxInt32
+		}
+		t.bandwidth.WaitN(waitCtx, int(size))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if waitCtx.Err() != nil {
+		atomic.AddUint64(&t.overruns, 1)
+	}
+	return nil
+}
+
+// Busy reports whether the process is too busy for background work
+/*
+ * 判断当前进程是否繁忙，结果在Interval之内复用
+ * 距离上一次采样超过两个Interval时先采样一次再等待一个Interval，使用最近一个周期的CPU使用率而不是长时间的平均值
+ * @param ctx：采样等待期间取消时视为不繁忙
+ * @return CPU使用率超过MaxCPU或者Busy返回true时返回true
+ */
+func (t *Throttler) Busy(ctx context.Context) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if !t.checkedAt.IsZero() && now.Sub(t.checkedAt) < t.config.Interval {
+		return t.busy
+	}
+
+	busy := false
+	if t.config.MaxCPU > 0 {
+		if percent, ok := t.cpuPercent(ctx); ok && percent > t.config.MaxCPU {
+			busy = true
+		}
+	}
+	if !busy && t.config.Busy != nil && t.config.Busy() {
+		busy = true
+	}
+	t.busy, t.checkedAt = busy, time.Now()
+	return busy
+}
+
+// Stats returns the counters of the throttler
+func (t *Throttler) Stats() ThrottleStats {
+	return ThrottleStats{
+		Waits:     atomic.LoadUint64(&t.waits),
+		Paused:    atomic.LoadUint64(&t.paused),
+		PauseTime: time.Duration(atomic.LoadInt64(&t.pauseTime)),
+		Overruns:  atomic.LoadUint64(&t.overruns),
+	}
+}
+
+/*
+ * 计算最近一个周期的CPU使用率，需要持有锁
+ * @return (CPU使用率, 是否成功)，平台不支持或者采样失败时返回false
+ */
+func (t *Throttler) cpuPercent(ctx context.Context) (float64, bool) {
+	pid := os.Getpid()
+	if t.last.at.IsZero() || time.Since(t.last.at) > 2*t.config.Interval {
+		usage, err := readUsage(pid)
+		if err != nil {
+			return 0, false
+		}
+		t.last = cpuSample{cpu: usage.cpu, at: time.Now()}
+		if !sleepContext(ctx, t.config.Interval) {
+			return 0, false
+		}
+	}
+	usage, err := readUsage(pid)
+	if err != nil {
+		return 0, false
+	}
+	now := time.Now()
+	last := t.last
+	t.last = cpuSample{cpu: usage.cpu, at: now}
+	elapsed := now.Sub(last.at)
+	if elapsed <= 0 || usage.cpu < last.cpu {
+		return 0, false
+	}
+	return float64(usage.cpu-last.cpu) / float64(elapsed) * 100, true
+}
+
+/*
+ * 等待d，ctx取消时提前返回false
+ */
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,225 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 升级时父子进程之间约定的环境变量以及文件描述符
+const (
+	upgradeEnv           = "NANO_LEGION_UPGRADE"
+	upgradeReadyFd       = 3 // 子进程就绪通知管道
+	upgradeListenFdStart = 4 // 第一个继承的监听socket
+	upgradeReady         = "ok"
+	defaultUpgradeWait   = 30 * time.Second
+)
+
+// ErrUpgradeUnsupported is returned by Upgrade on windows
+var ErrUpgradeUnsupported = errors.New("process: upgrade not supported on this platform")
+
+// 通过Listen创建或者继承的监听socket，以及子进程的就绪通知管道
+var (
+	upgradeLock      sync.Mutex
+	upgradeListeners []upgradeListener
+	inherited        map[string]net.Listener
+	upgradeNotify    *os.File
+	upgraded         bool
+)
+
+// upgradeListener 一个可以传递给新进程的监听socket
+type upgradeListener struct {
+	key      string // network|address，与Listen的参数一致
+	listener net.Listener
+}
+
+// filer 可以获取文件描述符的监听socket，*net.TCPListener以及*net.UnixListener
+type filer interface {
+	File() (*os.File, error)
+}
+
+// TakeOver adopts the listening sockets passed by the old process during Upgrade
+/*
+ * 在新进程中接管旧进程传递的监听socket，需要在main函数开头、调用Listen之前调用
+ * 之后通过Listen获取与旧进程相同参数的监听socket时直接返回继承的socket，不会出现端口占用或者连接被拒绝
+ * 接管之后需要在开始处理请求时调用Ready通知旧进程停止接收新连接
+ * @return (是否为Upgrade启动的新进程, error)；不是时返回(false, nil)
+ */
+func TakeOver() (bool, error) {
+	spec, ok := os.LookupEnv(upgradeEnv)
+	if !ok {
+		return false, nil
+	}
+	os.Unsetenv(upgradeEnv)
+	upgradeLock.Lock()
+	defer upgradeLock.Unlock()
+	upgradeNotify = os.NewFile(upgradeReadyFd, "upgrade-ready")
+	closeOnExec(upgradeReadyFd)
+	inherited = make(map[string]net.Listener)
+	if spec == "" {
+		return true, nil
+	}
+	for i, key := range strings.Split(spec, ";") {
+		fd := upgradeListenFdStart + i
+		closeOnExec(fd)
+		file := os.NewFile(uintptr(fd), key)
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return true, fmt.Errorf("take over %s: %w", key, err)
+		}
+		inherited[key] = l
+	}
+	return true, nil
+}
+
+// Listen returns an inherited listener for network and address, or creates a new one
+/*
+ * 创建监听socket，参数与net.Listen相同；TakeOver接管了相同参数的socket时直接返回该socket
+ * 通过Listen创建的socket在Upgrade时传递给新进程，需要升级的服务应当使用Listen代替net.Listen
+ * @param network：tcp、tcp4、tcp6或者unix
+ * @param address：监听地址
+ * @return (监听socket, error)
+ */
+func Listen(network, address string) (net.Listener, error) {
+	key := network + "|" + address
+	upgradeLock.Lock()
+	defer upgradeLock.Unlock()
+	l, ok := inherited[key]
+	if ok {
+		delete(inherited, key)
+	} else {
+		var err error
+		if l, err = net.Listen(network, address); err != nil {
+			return nil, err
+		}
+	}
+	upgradeListeners = append(upgradeListeners, upgradeListener{key: key, listener: l})
+	return l, nil
+}
+
+// Ready tells the old process that the new one is serving, see Upgrade
+/*
+ * 新进程已经开始处理请求，通知旧进程的Upgrade返回；没有接管时不做任何操作
+ * 没有被Listen取走的继承socket在此时关闭
+ * @return 通知失败时返回error，此时旧进程会在超时之后结束新进程
+ */
+func Ready() error {
+	upgradeLock.Lock()
+	notify := upgradeNotify
+	upgradeNotify = nil
+	for key, l := range inherited {
+		l.Close()
+		delete(inherited, key)
+	}
+	upgradeLock.Unlock()
+	if notify == nil {
+		return nil
+	}
+	defer notify.Close()
+	_, err := notify.WriteString(upgradeReady)
+	return err
+}
+
+// Upgrade starts the current binary as a new process that takes over the listeners
+/*
+ * 原地升级：替换二进制文件之后，以相同的参数启动新的二进制，通过继承的文件描述符传递Listen创建的监听socket，
+ * 等待新进程调用Ready之后返回；之后旧进程应当停止接收新连接、等待处理中的请求结束并退出，例如：
+ *     if err := process.Upgrade(0); err != nil { log.Error("upgrade failed", err); return }
+ *     process.Exit(process.Shutdown("upgrade"), "upgraded")
+ * 新进程在timeout之内没有就绪或者提前退出时结束新进程并返回error，旧进程继续正常服务
+ * 升级期间新旧进程同时运行：单实例保护的pid文件锁仍由旧进程持有，新进程需要在旧进程退出之后再写入pid文件，
+ * 旧进程的退出钩子也不应当删除新进程写入的pid文件
+ * windows下返回ErrUpgradeUnsupported
+ * @param timeout：等待新进程就绪的时间，<=0时为30秒
+ * @return 新进程已经就绪时返回nil
+ */
+func Upgrade(timeout time.Duration) error {
+	if runtime.GOOS == "windows" {
+		return ErrUpgradeUnsupported
+	}
+	if timeout <= 0 {
+		timeout = defaultUpgradeWait
+	}
+	upgradeLock.Lock()
+	defer upgradeLock.Unlock()
+	if upgraded {
+		return errors.New("upgrade: already upgraded")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("upgrade: %w", err)
+	}
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("upgrade: %w", err)
+	}
+	defer readyReader.Close()
+	files := []*os.File{readyWriter}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	keys := make([]string, 0, len(upgradeListeners))
+	for _, ul := range upgradeListeners {
+		f, ok := ul.listener.(filer)
+		if !ok {
+			return fmt.Errorf("upgrade: listener %s (%T) cannot be passed", ul.key, ul.listener)
+		}
+		file, err := f.File()
+		if err != nil {
+			return fmt.Errorf("upgrade: listener %s: %w", ul.key, err)
+		}
+		files = append(files, file)
+		keys = append(keys, ul.key)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), upgradeEnv+"="+strings.Join(keys, ";"))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("upgrade: %w", err)
+	}
+	readyWriter.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	ready := make(chan string, 1)
+	go func() {
+		msg, _ := ioutil.ReadAll(readyReader)
+		ready <- string(msg)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case msg := <-ready:
+		if msg == upgradeReady {
+			break
+		}
+		// 新进程没有调用Ready就关闭了通知管道，一般是已经退出
+		cmd.Process.Kill()
+		return fmt.Errorf("upgrade: new process %d exited before ready: %v", cmd.Process.Pid, <-exited)
+	case <-timer.C:
+		cmd.Process.Kill()
+		return fmt.Errorf("upgrade: new process %d not ready within %s", cmd.Process.Pid, timeout)
+	}
+
+	upgraded = true
+	// 旧进程关闭unix socket时不能删除socket文件，新进程仍在使用
+	for _, ul := range upgradeListeners {
+		if unix, ok := ul.listener.(*net.UnixListener); ok {
+			unix.SetUnlinkOnClose(false)
+		}
+	}
+	return nil
+}
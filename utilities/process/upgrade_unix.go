@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import "syscall"
+
+/*
+ * 设置继承的文件描述符在exec时关闭，避免再传递给子进程
+ */
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
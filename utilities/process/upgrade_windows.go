@@ -0,0 +1,6 @@
+package process
+
+/*
+ * windows不支持Upgrade，不会继承文件描述符
+ */
+func closeOnExec(fd int) {}
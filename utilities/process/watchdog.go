@@ -0,0 +1,293 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/fileutil"
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// Watchdog的默认参数
+const (
+	defaultWatchdogInterval = 10 * time.Second
+	defaultProbeTimeout     = 5 * time.Second
+)
+
+// WatchdogOptions configures NewWatchdog
+type WatchdogOptions struct {
+	HeartbeatFile string         // 心跳文件，所有探针都正常时每个周期更新，内容为 pid unix时间戳；为空时不写文件
+	Interval      time.Duration  // 检查周期，<=0时为10秒
+	ProbeTimeout  time.Duration  // 单个探针的超时时间，<=0时为5秒
+	Logger        *logger.Logger // 探针状态变化写入日志，可以为nil
+}
+
+// Watchdog periodically runs liveness probes and publishes the result as a heartbeat file and an HTTP handler
+/*
+ * 存活检测：定期执行注册的探针，全部正常时更新心跳文件，外部监控进程根据心跳文件的修改时间判断进程是否卡住；
+ * Handler可以作为kubernetes的livenessProbe，例如：
+ *     wd := process.NewWatchdog(process.WatchdogOptions{HeartbeatFile: "/data/run/saver.heartbeat", Logger: log})
+ *     wd.Expect("consumer", time.Minute)   // 消费协程每处理一批调用wd.Beat("consumer")
+ *     wd.Register("db", db.PingContext)
+ *     wd.Start()
+ *     adminMux.Handle("/healthz", wd.Handler())
+ * 探针在同一个协程中依次执行，某个探针卡住超过ProbeTimeout时视为失败并继续检查其他探针
+ */
+type Watchdog struct {
+	opts WatchdogOptions
+
+	mu       sync.Mutex
+	probes   map[string]func(ctx context.Context) error
+	beats    map[string]*beat
+	results  map[string]error
+	checked  time.Time
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// beat 通过Beat上报进度的子系统
+type beat struct {
+	maxSilence time.Duration
+	last       time.Time
+}
+
+// NewWatchdog creates a watchdog, call Start to begin checking
+func NewWatchdog(opts WatchdogOptions) *Watchdog {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWatchdogInterval
+	}
+	if opts.ProbeTimeout <= 0 {
+		opts.ProbeTimeout = defaultProbeTimeout
+	}
+	return &Watchdog{
+		opts:    opts,
+		probes:  make(map[string]func(ctx context.Context) error),
+		beats:   make(map[string]*beat),
+		results: make(map[string]error),
+	}
+}
+
+// Register adds a liveness probe, a nil error means healthy
+/*
+ * 注册探针，同名时替换
+ * @param name：探针名称
+ * @param probe：探针，返回nil表示正常；需要在ctx取消时返回
+ */
+func (w *Watchdog) Register(name string, probe func(ctx context.Context) error) {
+	w.mu.Lock()
+	w.probes[name] = probe
+	w.mu.Unlock()
+}
+
+// Expect declares a subsystem that must call Beat at least once every maxSilence
+/*
+ * 声明需要定期上报进度的子系统，用于发现卡住的循环，例如消费协程、定时任务
+ * 超过maxSilence没有调用Beat时探针失败；声明时视为刚上报过一次
+ * @param name：子系统名称，不能与Register的探针同名
+ * @param maxSilence：两次Beat之间允许的最长间隔
+ */
+func (w *Watchdog) Expect(name string, maxSilence time.Duration) {
+	w.mu.Lock()
+	w.beats[name] = &beat{maxSilence: maxSilence, last: time.Now()}
+	w.mu.Unlock()
+}
+
+// Beat records progress of a subsystem declared with Expect
+func (w *Watchdog) Beat(name string) {
+	w.mu.Lock()
+	if b := w.beats[name]; b != nil {
+		b.last = time.Now()
+	}
+	w.mu.Unlock()
+}
+
+// Start runs the first check synchronously and then checks every Interval
+func (w *Watchdog) Start() {
+	w.mu.Lock()
+	if w.stop != nil {
+		w.mu.Unlock()
+		return
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	w.mu.Unlock()
+
+	w.Check(context.Background())
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.Check(context.Background())
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic check, the heartbeat file is left untouched from then on
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	w.stopOnce.Do(func() { close(stop) })
+	<-done
+}
+
+// Check runs every probe once and updates the heartbeat file when all of them pass
+/*
+ * 立即执行一次所有探针，全部正常时更新心跳文件；状态变化(正常与失败之间)写入日志
+ * @param ctx：检查的上下文，每个探针在此基础上附加ProbeTimeout
+ * @return 失败的探针以及原因，全部正常时返回空map
+ */
+func (w *Watchdog) Check(ctx context.Context) map[string]error {
+	w.mu.Lock()
+	probes := make(map[string]func(ctx context.Context) error, len(w.probes)+len(w.beats))
+	for name, probe := range w.probes {
+		probes[name] = probe
+	}
+	now := time.Now()
+	for name, b := range w.beats {
+		silence, maxSilence := now.Sub(b.last), b.maxSilence
+		probes[name] = func(context.Context) error {
+			if silence > maxSilence {
+				return fmt.Errorf("no beat for %s, limit %s", silence.Truncate(time.Millisecond), maxSilence)
+			}
+			return nil
+		}
+	}
+	w.mu.Unlock()
+
+	failed := make(map[string]error)
+	for name, probe := range probes {
+		if err := w.runProbe(ctx, probe); err != nil {
+			failed[name] = err
+		}
+	}
+
+	w.mu.Lock()
+	previous := w.results
+	w.results = failed
+	w.checked = time.Now()
+	w.mu.Unlock()
+	w.logChanges(previous, failed)
+
+	if len(failed) == 0 && w.opts.HeartbeatFile != "" {
+		if err := w.touchHeartbeat(); err != nil && w.opts.Logger != nil {
+			w.opts.Logger.Warn("watchdog", "heartbeat", err)
+		}
+	}
+	return failed
+}
+
+// Healthy reports whether every probe passed in the last check
+func (w *Watchdog) Healthy() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.results) == 0
+}
+
+// Handler returns an HTTP handler reporting the result of the last check
+/*
+ * 存活检测接口：最近一次检查全部正常时返回200 ok，否则返回503以及失败的探针，每行为 名称: 原因
+ * 返回的是最近一次周期检查的结果，请求本身不执行探针；超过3个周期没有检查(检查协程卡住)时同样返回503
+ * @return http.Handler
+ */
+func (w *Watchdog) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.Lock()
+		failed := make(map[string]error, len(w.results))
+		for name, err := range w.results {
+			failed[name] = err
+		}
+		checked := w.checked
+		w.mu.Unlock()
+		if checked.IsZero() || time.Since(checked) > 3*w.opts.Interval {
+			failed["watchdog"] = errors.New("no check since " + checked.Format(time.RFC3339))
+		}
+
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(failed) == 0 {
+			rw.Write([]byte("ok\n"))
+			return
+		}
+		names := make([]string, 0, len(failed))
+		for name := range failed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		for _, name := range names {
+			fmt.Fprintf(rw, "%s: %v\n", name, failed[name])
+		}
+	})
+}
+
+/*
+ * 执行探针，超时或者panic时返回error
+ */
+func (w *Watchdog) runProbe(ctx context.Context, probe func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, w.opts.ProbeTimeout)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- fmt.Errorf("probe panic: %v", r)
+			}
+		}()
+		result <- probe(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+ * 探针状态变化时写入日志：新失败的写入error，恢复的写入trace
+ */
+func (w *Watchdog) logChanges(previous, current map[string]error) {
+	l := w.opts.Logger
+	if l == nil {
+		return
+	}
+	for name, err := range current {
+		if _, ok := previous[name]; !ok {
+			l.Error("watchdog", name, "unhealthy", err)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			l.Trace("watchdog", name, "recovered")
+		}
+	}
+}
+
+/*
+ * 更新心跳文件，原子替换，外部读取时不会读到写了一半的内容
+ */
+func (w *Watchdog) touchHeartbeat() error {
+	if err := os.MkdirAll(filepath.Dir(w.opts.HeartbeatFile), DefaultPidDirMode); err != nil {
+		return err
+	}
+	content := strconv.Itoa(os.Getpid()) + " " + strconv.FormatInt(time.Now().Unix(), 10) + "\n"
+	return fileutil.AtomicWriteFile(w.opts.HeartbeatFile, []byte(content), DefaultPidFileMode)
+}
@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// Config is the reverse proxy configuration
+type Config struct {
+	MaxRetries      int          `json:"max_retries"`      // 连接失败时最多重试次数(更换上游)
+	RequestHeaders  []HeaderRule `json:"request_headers"`  // 转发到上游之前的请求头改写规则
+	ResponseHeaders []HeaderRule `json:"response_headers"` // 返回给客户端之前的响应头改写规则
+	Cookies         []CookieRule `json:"cookies"`          // 响应cookie改写规则
+	PreserveHost    bool         `json:"preserve_host"`    // 是否保留客户端请求的Host头
+}
+
+// Proxy is a reverse proxy with retries, header rewriting and logging
+type Proxy struct {
+	upstreams Upstreams
+	config    Config
+	logger    *logger.Logger
+	rp        *httputil.ReverseProxy
+}
+
+// attempt 记录单次请求最终使用的上游，用于输出访问日志
+type attempt struct {
+	upstream string
+	retries  int
+}
+
+type attemptKey struct{}
+
+// New creates a reverse proxy
+/*
+ * 创建反向代理
+ * @param upstreams：上游选择器
+ * @param config：代理配置
+ * @param l：日志对象，访问日志使用trace级别，错误使用error级别；为nil时不输出日志
+ * @return 反向代理，实现了http.Handler
+ */
+func New(upstreams Upstreams, config Config, l *logger.Logger) *Proxy {
+	p := &Proxy{upstreams: upstreams, config: config, logger: l}
+	p.rp = &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			if _, ok := req.Header["User-Agent"]; !ok {
+				// 避免使用go默认的User-Agent
+				req.Header.Set("User-Agent", "")
+			}
+			applyHeaderRules(req.Header, config.RequestHeaders)
+		},
+		Transport:      &retryTransport{proxy: p, base: http.DefaultTransport},
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.errorHandler,
+	}
+	return p
+}
+
+// SetTransport replaces the underlying transport used to reach upstreams
+func (p *Proxy) SetTransport(rt http.RoundTripper) {
+	p.rp.Transport = &retryTransport{proxy: p, base: rt}
+}
+
+// ServeHTTP implements http.Handler
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := &attempt{}
+	r = r.WithContext(context.WithValue(r.Context(), attemptKey{}, a))
+	rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	p.rp.ServeHTTP(rw, r)
+
+	if p.logger != nil {
+		p.logger.Trace("proxy_access", r.RemoteAddr, r.Method, r.Host, r.URL.RequestURI(), rw.status,
+			rw.bytes, time.Since(start).String(), "upstream="+a.upstream, "retries="+strconv.Itoa(a.retries))
+	}
+}
+
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	applyHeaderRules(resp.Header, p.config.ResponseHeaders)
+	applyCookieRules(resp, p.config.Cookies)
+	return nil
+}
+
+func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if p.logger != nil {
+		upstream := ""
+		if a, ok := r.Context().Value(attemptKey{}).(*attempt); ok {
+			upstream = a.upstream
+		}
+		p.logger.Error("proxy_error", r.Method, r.Host, r.URL.RequestURI(), "upstream="+upstream, err.Error())
+	}
+	status := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	w.WriteHeader(status)
+}
+
+// retryTransport 为每次尝试选择上游，连接失败时更换上游重试
+type retryTransport struct {
+	proxy *Proxy
+	base  http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	a, _ := req.Context().Value(attemptKey{}).(*attempt)
+	var lastErr error
+	for i := 0; i <= t.proxy.config.MaxRetries; i++ {
+		target, err := t.proxy.upstreams.Next()
+		if err != nil {
+			return nil, err
+		}
+		out, err := t.rewrite(req, target, i)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil {
+			a.upstream = target.Host
+			a.retries = i
+		}
+		resp, err := t.base.RoundTrip(out)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !isConnectError(err) {
+			return nil, err
+		}
+		t.proxy.upstreams.MarkFailed(target)
+		if t.proxy.logger != nil {
+			t.proxy.logger.Warn("proxy_retry", req.Method, req.URL.Path, "upstream="+target.Host, err.Error())
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			// 请求体无法重放，不能重试
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+/*
+ * 生成发往指定上游的请求
+ * @param attempt：第几次尝试，重试时需要重新获取请求体
+ */
+func (t *retryTransport) rewrite(req *http.Request, target *url.URL, attempt int) (*http.Request, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = target.Scheme
+	out.URL.Host = target.Host
+	out.URL.Path, out.URL.RawPath = joinURLPath(target, req.URL)
+	if target.RawQuery != "" {
+		if out.URL.RawQuery == "" {
+			out.URL.RawQuery = target.RawQuery
+		} else {
+			out.URL.RawQuery = target.RawQuery + "&" + out.URL.RawQuery
+		}
+	}
+	if !t.proxy.config.PreserveHost {
+		out.Host = target.Host
+	}
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		out.Body = body
+	}
+	return out, nil
+}
+
+/*
+ * 判断是否为连接阶段的错误，只有连接失败时请求才确定没有到达上游，可以安全重试
+ */
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Op == "dial"
+	}
+	return false
+}
+
+func joinURLPath(a, b *url.URL) (path, rawpath string) {
+	if a.RawPath == "" && b.RawPath == "" {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+	apath := a.EscapedPath()
+	bpath := b.EscapedPath()
+	aslash := strings.HasSuffix(apath, "/")
+	bslash := strings.HasPrefix(bpath, "/")
+	switch {
+	case aslash && bslash:
+		return a.Path + b.Path[1:], apath + bpath[1:]
+	case !aslash && !bslash:
+		return a.Path + "/" + b.Path, apath + "/" + bpath
+	}
+	return a.Path + b.Path, apath + bpath
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// responseRecorder 记录响应状态码以及字节数
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Flush 支持流式响应
+func (rw *responseRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
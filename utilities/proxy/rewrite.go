@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderRule rewrites a header of requests or responses
+/*
+ * 头部改写规则
+ * Action取值：set(覆盖)、add(追加)、del(删除)
+ */
+type HeaderRule struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+}
+
+// CookieRule rewrites Set-Cookie attributes of upstream responses
+/*
+ * Cookie改写规则，只改写名称匹配的cookie，Name为空时匹配所有cookie
+ * Domain/Path为空时不改写对应属性
+ */
+type CookieRule struct {
+	Name   string `json:"name"`
+	Domain string `json:"domain"`
+	Path   string `json:"path"`
+}
+
+func applyHeaderRules(header http.Header, rules []HeaderRule) {
+	for _, rule := range rules {
+		switch strings.ToLower(rule.Action) {
+		case "set":
+			header.Set(rule.Name, rule.Value)
+		case "add":
+			header.Add(rule.Name, rule.Value)
+		case "del", "delete":
+			header.Del(rule.Name)
+		}
+	}
+}
+
+/*
+ * 按照规则改写响应中的Set-Cookie
+ */
+func applyCookieRules(resp *http.Response, rules []CookieRule) {
+	if len(rules) == 0 {
+		return
+	}
+	cookies := resp.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	resp.Header.Del("Set-Cookie")
+	for _, cookie := range cookies {
+		for _, rule := range rules {
+			if rule.Name != "" && rule.Name != cookie.Name {
+				continue
+			}
+			if rule.Domain != "" {
+				cookie.Domain = rule.Domain
+			}
+			if rule.Path != "" {
+				cookie.Path = rule.Path
+			}
+		}
+		if v := cookie.String(); v != "" {
+			resp.Header.Add("Set-Cookie", v)
+		}
+	}
+}
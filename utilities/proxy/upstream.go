@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNoUpstream is returned when no healthy upstream is available
+var ErrNoUpstream = errors.New("proxy: no upstream available")
+
+// Upstreams selects the backend for each attempt
+/*
+ * 上游选择接口，负载均衡器实现该接口即可接入代理
+ */
+type Upstreams interface {
+	// Next 选择一个上游
+	Next() (*url.URL, error)
+	// MarkFailed 上游连接失败时调用
+	MarkFailed(target *url.URL)
+}
+
+// RoundRobin is a simple round-robin upstream pool with failure cooldown
+/*
+ * 轮询上游池，连接失败的上游在冷却时间内不参与选择
+ * 所有上游都处于冷却中时仍然按照轮询选择，避免全部失败后无法恢复
+ */
+type RoundRobin struct {
+	sync.Mutex
+	targets  []*url.URL
+	failedAt []time.Time
+	next     int
+	cooldown time.Duration
+	nowFunc  func() time.Time
+}
+
+// NewRoundRobin creates a round-robin pool from upstream addresses
+/*
+ * 创建轮询上游池
+ * @param addrs：上游地址列表，例如 http://10.0.0.1:8080
+ * @param cooldown：连接失败后的冷却时间，0表示不冷却
+ * @return 成功返回(*RoundRobin, nil)；地址非法时返回(nil, error)
+ */
+func NewRoundRobin(addrs []string, cooldown time.Duration) (*RoundRobin, error) {
+	if len(addrs) == 0 {
+		return nil, ErrNoUpstream
+	}
+	pool := &RoundRobin{cooldown: cooldown, nowFunc: time.Now}
+	for _, addr := range addrs {
+		target, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		if target.Scheme == "" || target.Host == "" {
+			return nil, errors.New("proxy: invalid upstream " + addr)
+		}
+		pool.targets = append(pool.targets, target)
+	}
+	pool.failedAt = make([]time.Time, len(pool.targets))
+	return pool, nil
+}
+
+// Next selects the next upstream
+func (pool *RoundRobin) Next() (*url.URL, error) {
+	pool.Lock()
+	defer pool.Unlock()
+	now := pool.nowFunc()
+	for i := 0; i < len(pool.targets); i++ {
+		index := (pool.next + i) % len(pool.targets)
+		if now.Sub(pool.failedAt[index]) >= pool.cooldown {
+			pool.next = index + 1
+			return pool.targets[index], nil
+		}
+	}
+	index := pool.next % len(pool.targets)
+	pool.next = index + 1
+	return pool.targets[index], nil
+}
+
+// MarkFailed puts the upstream into cooldown
+func (pool *RoundRobin) MarkFailed(target *url.URL) {
+	pool.Lock()
+	defer pool.Unlock()
+	for i, t := range pool.targets {
+		if t == target || t.String() == target.String() {
+			pool.failedAt[i] = pool.nowFunc()
+		}
+	}
+}
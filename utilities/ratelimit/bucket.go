@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket limiter, safe for concurrent use
+/*
+ * 令牌桶限流：令牌按照rate每秒的速度补充，最多积累burst个，每次请求消耗令牌
+ * 平均速率不超过rate，同时允许最多burst个请求的突发
+ */
+type TokenBucket struct {
+	lock   sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64
+	tokens float64
+	last   time.Time // 上一次补充令牌的时间
+}
+
+// NewTokenBucket creates a full bucket
+/*
+ * 创建令牌桶，初始为满
+ * @param rate：每秒补充的令牌数，<=0时不补充，用完之后全部拒绝
+ * @param burst：桶的容量，<=0时取rate(至少为1)
+ * @return 令牌桶
+ */
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	b := &TokenBucket{last: time.Now()}
+	b.setRate(rate, burst)
+	b.tokens = b.burst
+	return b
+}
+
+// SetRate changes the rate and burst, keeping the tokens already in the bucket
+func (b *TokenBucket) SetRate(rate float64, burst int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.refill(time.Now())
+	b.setRate(rate, burst)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+/*
+ * 设置速率以及容量，需要持有锁
+ */
+func (b *TokenBucket) setRate(rate float64, burst int) {
+	if rate < 0 {
+		rate = 0
+	}
+	if burst <= 0 {
+		burst = int(rate)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	b.rate, b.burst = rate, float64(burst)
+}
+
+// Allow takes one token, reporting whether one was available
+func (b *TokenBucket) Allow() bool {
+	return b.AllowN(1)
+}
+
+// AllowN takes n tokens if all of them are available
+func (b *TokenBucket) AllowN(n int) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.refill(time.Now())
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// Wait blocks until a token is available or ctx is done
+/*
+ * 等待并取得一个令牌
+ * @param ctx：取消时返回ctx.Err()，已经预留的令牌归还
+ * @return 成功取得令牌时返回nil；速率为0并且没有令牌时返回error
+ */
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	return b.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens are available or ctx is done
+/*
+ * 等待并取得n个令牌，用于按照字节数限制带宽，n可以大于burst，按照欠缺的令牌数等待
+ * @param ctx：取消时返回ctx.Err()，已经预留的令牌归还；截止时间早于需要等待的时间时直接返回context.DeadlineExceeded
+ * @param n：令牌数，<=0时直接返回
+ * @return 成功取得令牌时返回nil；速率为0并且令牌不足时返回error
+ */
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	b.lock.Lock()
+	now := time.Now()
+	b.refill(now)
+	// 先预留令牌(可以为负数)，按照欠缺的令牌数计算等待时间，多个等待者按照到达顺序排队
+	b.tokens -= float64(n)
+	var wait time.Duration
+	if b.tokens < 0 {
+		if b.rate <= 0 {
+			b.tokens += float64(n)
+			b.lock.Unlock()
+			return fmt.Errorf("ratelimit: rate is zero")
+		}
+		wait = time.Duration(math.Ceil(-b.tokens / b.rate * float64(time.Second)))
+	}
+	b.lock.Unlock()
+	if wait == 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		b.cancel(n)
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel(n)
+		return ctx.Err()
+	}
+}
+
+/*
+ * 归还WaitN预留的令牌
+ */
+func (b *TokenBucket) cancel(n int) {
+	b.lock.Lock()
+	b.tokens += float64(n)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lock.Unlock()
+}
+
+// Tokens returns the tokens currently available, negative while waiters are queued
+func (b *TokenBucket) Tokens() float64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.refill(time.Now())
+	return b.tokens
+}
+
+/*
+ * 按照经过的时间补充令牌，需要持有锁
+ */
+func (b *TokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
@@ -0,0 +1,218 @@
+package clientlimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+	"github.com/lucifinil-long/nano-legion/utilities/metrics"
+	"github.com/lucifinil-long/nano-legion/utilities/ratelimit"
+)
+
+// Limiter的默认参数
+const (
+	defaultMaxClients    = 10000
+	defaultClientIdle    = 10 * time.Minute
+	overflowClient       = "\x00overflow"  // 超过MaxClients之后的新客户端共用的key
+	clientCleanupPerCall = 2 * time.Second // 两次清理之间的最短间隔
+)
+
+// Config configures New
+type Config struct {
+	Rate        float64           // 每个客户端每秒允许的请求(连接)数
+	Burst       int               // 每个客户端允许的突发，<=0时取Rate(至少为1)
+	BanAfter    int               // 连续被拒绝的次数达到该值时封禁，<=0表示不封禁
+	BanDuration time.Duration     // 封禁时长，封禁期间的请求全部拒绝，BanAfter>0时必须大于0
+	MaxClients  int               // 同时跟踪的客户端上限，默认10000，超过时新客户端共用一个令牌桶
+	IdleTimeout time.Duration     // 客户端超过该时间没有请求时不再跟踪，默认10分钟
+	Metrics     *metrics.Registry // 拒绝、封禁以及客户端数的指标，nil表示不统计
+	Logger      *logger.Logger    // 被拒绝以及被封禁的客户端写入warn日志，nil表示不输出
+}
+
+// Limiter throttles requests per client identity with bans for repeat offenders
+/*
+ * 按照客户端标识(IP、token等)限流，每个客户端一个令牌桶：
+ *   请求超过速率时拒绝，连续被拒绝BanAfter次之后封禁BanDuration，期间所有请求直接拒绝
+ *   客户端从正常变为被拒绝以及被封禁时各写入一条warn日志，不会每次拒绝都写日志
+ * 配置Metrics时注册以下指标：
+ *   ratelimit_client_rejected_total 被拒绝的请求数(包括封禁期间)
+ *   ratelimit_client_banned_total   封禁次数
+ *   ratelimit_clients               正在跟踪的客户端数
+ * 通过Middleware用于HTTP服务，通过Listener用于TCP服务；可以在多个协程中同时使用
+ */
+type Limiter struct {
+	config   Config
+	lock     sync.Mutex
+	clients  map[string]*clientState
+	lastGC   time.Time
+	rejected *metrics.Counter
+	banned   *metrics.Counter
+}
+
+// clientState 一个客户端的限流状态
+type clientState struct {
+	bucket      *ratelimit.TokenBucket
+	rejections  int       // 连续被拒绝的次数
+	bannedUntil time.Time // 封禁的结束时间，零值表示未封禁
+	lastSeen    time.Time
+}
+
+// New creates a per-client limiter
+/*
+ * 创建按客户端限流的对象
+ * @param config：限流配置
+ * @return 限流对象
+ */
+func New(config Config) *Limiter {
+	if config.MaxClients <= 0 {
+		config.MaxClients = defaultMaxClients
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultClientIdle
+	}
+	if config.BanDuration <= 0 {
+		config.BanAfter = 0
+	}
+	l := &Limiter{config: config, clients: make(map[string]*clientState), lastGC: time.Now()}
+	if reg := config.Metrics; reg != nil {
+		l.rejected = reg.Counter("ratelimit_client_rejected_total", "Requests rejected by the per-client rate limiter")
+		l.banned = reg.Counter("ratelimit_client_banned_total", "Clients banned by the per-client rate limiter")
+		reg.GaugeFunc("ratelimit_clients", "Clients tracked by the per-client rate limiter", func() float64 {
+			return float64(l.Len())
+		})
+	}
+	return l
+}
+
+// Allow reports whether a request from client may proceed
+/*
+ * 判断客户端的请求是否允许，拒绝时返回建议的重试等待时间
+ * @param client：客户端标识，为空时不限流
+ * @return (是否允许, 拒绝时建议等待的时间)
+ */
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	if client == "" {
+		return true, 0
+	}
+	now := time.Now()
+	l.lock.Lock()
+	l.gc(now)
+	state := l.client(client, now)
+	state.lastSeen = now
+
+	if now.Before(state.bannedUntil) {
+		wait := state.bannedUntil.Sub(now)
+		l.lock.Unlock()
+		l.reject()
+		return false, wait
+	}
+	if state.bucket.Allow() {
+		state.rejections = 0
+		l.lock.Unlock()
+		return true, 0
+	}
+
+	state.rejections++
+	first := state.rejections == 1
+	ban := l.config.BanAfter > 0 && state.rejections >= l.config.BanAfter
+	wait := time.Second
+	if l.config.Rate > 0 {
+		wait = time.Duration(float64(time.Second) / l.config.Rate)
+	}
+	if ban {
+		state.bannedUntil = now.Add(l.config.BanDuration)
+		state.rejections = 0
+		wait = l.config.BanDuration
+	}
+	l.lock.Unlock()
+
+	l.reject()
+	if ban {
+		if l.banned != nil {
+			l.banned.Inc()
+		}
+		l.logf("ratelimit_ban", client, l.config.BanDuration.String())
+	} else if first {
+		l.logf("ratelimit_reject", client)
+	}
+	return false, wait
+}
+
+// Unban lifts the ban and resets the rejections of client
+func (l *Limiter) Unban(client string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if state, ok := l.clients[client]; ok {
+		state.bannedUntil = time.Time{}
+		state.rejections = 0
+	}
+}
+
+// Banned returns the clients currently banned
+func (l *Limiter) Banned() []string {
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var banned []string
+	for client, state := range l.clients {
+		if client != overflowClient && now.Before(state.bannedUntil) {
+			banned = append(banned, client)
+		}
+	}
+	return banned
+}
+
+// Len returns the number of clients being tracked
+func (l *Limiter) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.clients)
+}
+
+/*
+ * 获取客户端的状态，超过MaxClients时返回共用的状态，需要持有锁
+ */
+func (l *Limiter) client(client string, now time.Time) *clientState {
+	if state, ok := l.clients[client]; ok {
+		return state
+	}
+	if len(l.clients) >= l.config.MaxClients {
+		if state, ok := l.clients[overflowClient]; ok {
+			return state
+		}
+		client = overflowClient
+	}
+	state := &clientState{bucket: ratelimit.NewTokenBucket(l.config.Rate, l.config.Burst), lastSeen: now}
+	l.clients[client] = state
+	return state
+}
+
+/*
+ * 删除超过IdleTimeout没有请求并且未被封禁的客户端，需要持有锁
+ */
+func (l *Limiter) gc(now time.Time) {
+	if now.Sub(l.lastGC) < clientCleanupPerCall {
+		return
+	}
+	for client, state := range l.clients {
+		if now.Sub(state.lastSeen) >= l.config.IdleTimeout && !now.Before(state.bannedUntil) {
+			delete(l.clients, client)
+		}
+	}
+	l.lastGC = now
+}
+
+func (l *Limiter) reject() {
+	if l.rejected != nil {
+		l.rejected.Inc()
+	}
+}
+
+/*
+ * 写入warn日志，未设置日志对象时忽略
+ */
+func (l *Limiter) logf(args ...interface{}) {
+	if l.config.Logger != nil {
+		l.config.Logger.Warn(args...)
+	}
+}
@@ -0,0 +1,121 @@
+package clientlimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// KeyFunc extracts the client identity of an HTTP request
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP identifies clients by the remote address of the connection
+func KeyByIP(r *http.Request) string {
+	return hostOf(r.RemoteAddr)
+}
+
+// KeyByForwardedIP identifies clients by X-Forwarded-For/X-Real-IP, falling back to the remote address
+/*
+ * 优先使用X-Forwarded-For中的第一个地址以及X-Real-IP，只在前面有可信的反向代理时使用，否则客户端可以伪造
+ */
+func KeyByForwardedIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		if i := strings.IndexByte(forwarded, ','); i >= 0 {
+			forwarded = forwarded[:i]
+		}
+		if ip := strings.TrimSpace(forwarded); ip != "" {
+			return ip
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	return KeyByIP(r)
+}
+
+// KeyByToken identifies clients by the bearer token, falling back to the remote address
+/*
+ * 使用"Authorization: Bearer <token>"中的token作为客户端标识，没有token时使用远端IP
+ * key为token:加上token的sha256前16位，与IP区分，并且日志以及Banned中不会出现token原文
+ */
+func KeyByToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		if token := strings.TrimSpace(header[7:]); token != "" {
+			sum := sha256.Sum256([]byte(token))
+			return "token:" + hex.EncodeToString(sum[:8])
+		}
+	}
+	return KeyByIP(r)
+}
+
+// Middleware rejects requests of throttled clients with 429 Too Many Requests
+/*
+ * HTTP限流中间件，被拒绝的请求返回429并设置Retry-After，例如：
+ *     limiter := clientlimit.New(clientlimit.Config{Rate: 20, Burst: 40, BanAfter: 100, BanDuration: 10 * time.Minute})
+ *     http.ListenAndServe(addr, limiter.Middleware(clientlimit.KeyByIP)(mux))
+ * @param key：获取客户端标识，为nil时使用KeyByIP；返回空字符串时不限流
+ * @return 中间件
+ */
+func (l *Limiter) Middleware(key KeyFunc) func(http.Handler) http.Handler {
+	if key == nil {
+		key = KeyByIP
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, wait := l.Allow(key(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Listener wraps ln so that connections from throttled client IPs are closed on accept
+/*
+ * TCP限流：按照远端IP限制新建连接的速率，被拒绝的连接在Accept时直接关闭，不会返回给调用方
+ * 只限制建立连接的速率，不限制连接上的请求；例如：
+ *     ln, _ := net.Listen("tcp", addr)
+ *     server.Serve(limiter.Listener(ln))
+ * @param ln：原始监听
+ * @return 限流之后的监听
+ */
+func (l *Limiter) Listener(ln net.Listener) net.Listener {
+	return &limitedListener{Listener: ln, limiter: l}
+}
+
+// limitedListener 按照远端IP限制连接速率的监听
+type limitedListener struct {
+	net.Listener
+	limiter *Limiter
+}
+
+// Accept implements net.Listener, closing connections of throttled clients
+func (ln *limitedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if ok, _ := ln.limiter.Allow(hostOf(conn.RemoteAddr().String())); ok {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
+
+/*
+ * 去掉地址中的端口
+ */
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
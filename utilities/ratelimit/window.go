@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// SlidingWindow limits events per key within a sliding time window
+/*
+ * 按key限流的滑动窗口计数，例如每个用户每分钟最多100次请求
+ * 使用两个固定窗口加权近似滑动窗口：计数 = 上一窗口计数 * 上一窗口在滑动窗口内的比例 + 当前窗口计数
+ * 每个key只保存两个计数，内存与key的数量成正比；超过两个窗口没有访问的key在Cleanup时删除
+ */
+type SlidingWindow struct {
+	lock   sync.Mutex
+	limit  int
+	window time.Duration
+	keys   map[string]*windowCounter
+	lastGC time.Time // 上一次清理过期key的时间
+}
+
+// windowCounter 一个key的计数
+type windowCounter struct {
+	start    time.Time // 当前窗口的开始时间
+	current  int
+	previous int
+}
+
+// NewSlidingWindow creates a limiter allowing limit events per key within window
+/*
+ * 创建滑动窗口限流
+ * @param limit：每个key在window内允许的次数
+ * @param window：窗口长度
+ * @return 限流对象
+ */
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	if window <= 0 {
+		window = time.Second
+	}
+	return &SlidingWindow{
+		limit:  limit,
+		window: window,
+		keys:   make(map[string]*windowCounter),
+		lastGC: time.Now(),
+	}
+}
+
+// Allow records an event for key if it is within the limit
+func (w *SlidingWindow) Allow(key string) bool {
+	return w.AllowN(key, 1)
+}
+
+// AllowN records n events for key if all of them are within the limit
+func (w *SlidingWindow) AllowN(key string, n int) bool {
+	now := time.Now()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.gc(now)
+	counter := w.counter(key, now)
+	if counter.estimate(now, w.window)+float64(n) > float64(w.limit) {
+		return false
+	}
+	counter.current += n
+	return true
+}
+
+// Count returns the estimated events of key within the window
+func (w *SlidingWindow) Count(key string) float64 {
+	now := time.Now()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	counter, ok := w.keys[key]
+	if !ok {
+		return 0
+	}
+	counter.advance(now, w.window)
+	return counter.estimate(now, w.window)
+}
+
+// Len returns the number of keys being tracked
+func (w *SlidingWindow) Len() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return len(w.keys)
+}
+
+// Cleanup removes keys without events in the last two windows
+func (w *SlidingWindow) Cleanup() {
+	now := time.Now()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.cleanup(now)
+}
+
+/*
+ * 获取key的计数并推进到now所在的窗口，需要持有锁
+ */
+func (w *SlidingWindow) counter(key string, now time.Time) *windowCounter {
+	counter, ok := w.keys[key]
+	if !ok {
+		counter = &windowCounter{start: now.Truncate(w.window)}
+		w.keys[key] = counter
+	}
+	counter.advance(now, w.window)
+	return counter
+}
+
+/*
+ * 每隔两个窗口清理一次过期的key，需要持有锁
+ */
+func (w *SlidingWindow) gc(now time.Time) {
+	if now.Sub(w.lastGC) >= 2*w.window {
+		w.cleanup(now)
+	}
+}
+
+/*
+ * 删除两个窗口内没有访问的key，需要持有锁
+ */
+func (w *SlidingWindow) cleanup(now time.Time) {
+	for key, counter := range w.keys {
+		if now.Sub(counter.start) >= 2*w.window {
+			delete(w.keys, key)
+		}
+	}
+	w.lastGC = now
+}
+
+/*
+ * 推进到now所在的窗口
+ */
+func (c *windowCounter) advance(now time.Time, window time.Duration) {
+	start := now.Truncate(window)
+	switch elapsed := start.Sub(c.start); {
+	case elapsed <= 0:
+	case elapsed == window:
+		c.previous, c.current = c.current, 0
+		c.start = start
+	default:
+		c.previous, c.current = 0, 0
+		c.start = start
+	}
+}
+
+/*
+ * 估算滑动窗口内的次数
+ */
+func (c *windowCounter) estimate(now time.Time, window time.Duration) float64 {
+	weight := 1 - float64(now.Sub(c.start))/float64(window)
+	return float64(c.previous)*weight + float64(c.current)
+}
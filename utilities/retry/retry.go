@@ -0,0 +1,184 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+)
+
+// 默认的重试参数
+const (
+	defaultMaxAttempts = 3
+	defaultInitial     = 100 * time.Millisecond
+	defaultMax         = 10 * time.Second
+	defaultMultiplier  = 2
+	defaultJitter      = 0.2
+)
+
+// Options configures Do, a zero value uses the defaults
+/*
+ * 重试参数，零值使用默认值：最多3次，等待时间从100ms开始每次加倍，不超过10秒，±20%抖动
+ */
+type Options struct {
+	MaxAttempts int           // 最多执行次数(包括第一次)，<=0时为3
+	Initial     time.Duration // 第一次重试之前的等待时间，<=0时为100ms
+	Max         time.Duration // 等待时间的上限，<=0时为10秒
+	Multiplier  float64       // 每次重试等待时间的倍数，<=1时为2
+	Jitter      float64       // 等待时间随机浮动的比例(0~1)，避免大量客户端同时重试，0时为0.2，<0时不浮动
+
+	// RetryIf 判断错误是否需要重试，为nil时除Permanent包装的错误以及context错误之外都重试
+	RetryIf func(err error) bool
+	// OnRetry 每次失败并且将要重试时调用，attempt从1开始，wait为重试之前的等待时间
+	OnRetry func(attempt int, err error, wait time.Duration)
+	// Logger 每次失败写入warn日志，最终失败写入error日志，可以为nil
+	Logger *logger.Logger
+	// Name 日志中的操作名称，例如 "fetch config"
+	Name string
+}
+
+// permanentError 不需要重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Do returns it without retrying
+/*
+ * 包装不需要重试的错误，例如参数错误、权限错误，Do直接返回原始错误
+ * @param err：错误，为nil时返回nil
+ */
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls fn until it succeeds, fails permanently, runs out of attempts or ctx is done
+/*
+ * 按照指数退避重试fn，例如：
+ *     err := retry.Do(ctx, func(ctx context.Context) error {
+ *         return client.Ping(ctx)
+ *     }, retry.Options{MaxAttempts: 5, Logger: log, Name: "ping"})
+ * @param ctx：取消时不再重试，等待中也会立即返回
+ * @param fn：执行的操作，返回Permanent包装的错误时不再重试
+ * @param opts：重试参数
+ * @return 成功返回nil；不需要重试或者次数用完时返回最后一次的错误；ctx结束时返回包装了ctx.Err()以及最后一次错误的error
+ */
+func Do(ctx context.Context, fn func(ctx context.Context) error, opts Options) error {
+	_, err := DoValue(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, opts)
+	return err
+}
+
+// DoValue is Do for operations returning a value
+func DoValue[T any](ctx context.Context, fn func(ctx context.Context) (T, error), opts Options) (T, error) {
+	opts = withDefaults(opts)
+	var zero T
+	for attempt := 1; ; attempt++ {
+		value, err := fn(ctx)
+		if err == nil {
+			return value, nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			opts.logFinal(attempt, permanent.err)
+			return zero, permanent.err
+		}
+		if attempt >= opts.MaxAttempts || !opts.retryable(err) {
+			opts.logFinal(attempt, err)
+			return zero, err
+		}
+
+		wait := opts.Backoff(attempt)
+		if opts.Logger != nil {
+			opts.Logger.Warn("retry", opts.Name, "attempt", attempt, "failed", err, "retry in", wait.String())
+		}
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, err, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("retry: %w, last error: %v", ctx.Err(), err)
+			opts.logFinal(attempt, err)
+			return zero, err
+		}
+	}
+}
+
+// Backoff returns the wait before the retry following the given attempt
+/*
+ * 第attempt次失败之后的等待时间：Initial * Multiplier^(attempt-1)，不超过Max，再按照Jitter随机浮动，零值字段使用默认值
+ * @param attempt：失败的次数，从1开始
+ */
+func (opts Options) Backoff(attempt int) time.Duration {
+	opts = withDefaults(opts)
+	wait := float64(opts.Initial)
+	for i := 1; i < attempt && wait < float64(opts.Max); i++ {
+		wait *= opts.Multiplier
+	}
+	if wait > float64(opts.Max) {
+		wait = float64(opts.Max)
+	}
+	if opts.Jitter > 0 {
+		wait += wait * opts.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+/*
+ * 填充默认值
+ */
+func withDefaults(opts Options) Options {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Initial <= 0 {
+		opts.Initial = defaultInitial
+	}
+	if opts.Max <= 0 {
+		opts.Max = defaultMax
+	}
+	if opts.Multiplier <= 1 {
+		opts.Multiplier = defaultMultiplier
+	}
+	if opts.Jitter == 0 {
+		opts.Jitter = defaultJitter
+	} else if opts.Jitter > 1 {
+		opts.Jitter = 1
+	}
+	return opts
+}
+
+/*
+ * 判断错误是否需要重试，context错误不重试
+ */
+func (opts Options) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if opts.RetryIf != nil {
+		return opts.RetryIf(err)
+	}
+	return true
+}
+
+/*
+ * 最终失败写入error日志
+ */
+func (opts Options) logFinal(attempts int, err error) {
+	if opts.Logger != nil {
+		opts.Logger.Error("retry", opts.Name, "gave up after", attempts, "attempts", err)
+	}
+}
@@ -0,0 +1,229 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the run times of a job
+type Schedule interface {
+	// Next 返回t之后的下一次执行时间，没有下一次时返回零值
+	Next(t time.Time) time.Time
+	// String 用于日志以及状态接口
+	String() string
+}
+
+// every 固定间隔
+type every time.Duration
+
+// Every returns a schedule running every d, d is rounded up to one second
+func Every(d time.Duration) Schedule {
+	if d < time.Second {
+		d = time.Second
+	}
+	return every(d)
+}
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+func (e every) String() string {
+	return "@every " + time.Duration(e).String()
+}
+
+// cron 解析之后的cron表达式，每个字段为允许取值的位图
+type cron struct {
+	spec     string
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	anyDom   bool // 日期字段以*开头
+	anyDow   bool // 星期字段以*开头
+	location *time.Location
+}
+
+// cronField 字段的取值范围以及名称
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	domField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors 预定义的表达式
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression or an @every/@daily style descriptor
+/*
+ * 解析执行计划，支持：
+ *   标准5字段cron表达式：分 时 日 月 星期，例如 "0 * * * *"、"30 3 * * mon-fri"
+ *   字段支持 *、数字、名称(jan、mon等)、列表(1,15)、范围(1-5)以及步长(0-30/5，*后加/10表示每10)，星期中0和7都表示周日
+ *   日与星期都不是*时满足任意一个即执行，与cron相同
+ *   @yearly、@monthly、@weekly、@daily、@hourly
+ *   @every <duration>，例如 "@every 30s"
+ * cron表达式按照本地时区计算
+ * @param spec：表达式
+ * @return 表达式不合法时返回error
+ */
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("scheduler: invalid interval in %q", spec)
+		}
+		return Every(d), nil
+	}
+	expr := spec
+	if descriptor, ok := descriptors[spec]; ok {
+		expr = descriptor
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("scheduler: %q must have 5 fields", spec)
+	}
+	c := &cron{
+		spec:     spec,
+		anyDom:   strings.HasPrefix(fields[2], "*"),
+		anyDow:   strings.HasPrefix(fields[4], "*"),
+		location: time.Local,
+	}
+	var err error
+	for i, target := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&c.minute, minuteField}, {&c.hour, hourField}, {&c.dom, domField}, {&c.month, monthField}, {&c.dow, dowField},
+	} {
+		if *target.bits, err = parseField(fields[i], target.field); err != nil {
+			return nil, fmt.Errorf("scheduler: %q: %w", spec, err)
+		}
+	}
+	// 7与0都表示周日
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+/*
+ * 解析一个字段为取值位图
+ */
+func parseField(expr string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+		low, high := field.min, field.max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if low, err = field.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = field.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 例如 5/15 表示从5开始每15
+				high = field.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+/*
+ * 解析字段中的单个取值，数字或者名称
+ */
+func (field cronField) value(s string) (int, error) {
+	if v, ok := field.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < field.min || v > field.max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", s, field.min, field.max)
+	}
+	return v, nil
+}
+
+// Next implements Schedule
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.In(c.location).Truncate(time.Minute).Add(time.Minute)
+	// 最多查找5年，例如2月30日这样永远不会满足的表达式返回零值
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+/*
+ * 判断日期是否满足日以及星期字段
+ */
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.anyDom || c.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c *cron) String() string {
+	return c.spec
+}
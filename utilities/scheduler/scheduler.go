@@ -0,0 +1,368 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucifinil-long/nano-legion/utilities/logger"
+	"github.com/lucifinil-long/nano-legion/utilities/process"
+)
+
+// DefaultChannel is the logger channel job runs are written to
+const DefaultChannel = "scheduler"
+
+var (
+	// ErrJobExists is returned by Add when a job with the same name is registered
+	ErrJobExists = errors.New("scheduler: job already exists")
+	// ErrNoJob is returned by RunNow for an unknown job
+	ErrNoJob = errors.New("scheduler: no such job")
+)
+
+// JobOptions configures a job
+type JobOptions struct {
+	Timeout      time.Duration // 单次执行的超时时间，超时之后取消job的context，<=0时不限制
+	AllowOverlap bool          // 上一次执行尚未结束时是否开始新的执行，默认跳过本次
+	RunOnStart   bool          // Start时立即执行一次
+}
+
+// JobStatus is a snapshot of a job
+type JobStatus struct {
+	Name         string
+	Schedule     string
+	Next         time.Time     // 下一次执行时间
+	LastStart    time.Time     // 上一次开始时间
+	LastDuration time.Duration // 上一次执行耗时
+	LastError    string        // 上一次执行的错误，成功时为空
+	Runs         uint64        // 执行次数
+	Failures     uint64        // 返回error、超时或者panic的次数
+	Skipped      uint64        // 因为上一次尚未结束而跳过的次数
+	Running      int           // 正在执行的数量
+}
+
+// job 注册的任务
+type job struct {
+	name     string
+	schedule Schedule
+	fn       func(ctx context.Context) error
+	opts     JobOptions
+	trigger  chan struct{} // RunNow
+	remove   chan struct{} // Remove
+	status   JobStatus
+}
+
+// Scheduler runs registered jobs on their schedules
+/*
+ * 定时任务：按照固定间隔或者cron表达式执行注册的任务
+ *   默认上一次执行尚未结束时跳过本次，避免慢任务堆积
+ *   每次执行可以设置超时，超时之后取消任务的context
+ *   任务panic时记录到error日志，不影响其他任务以及之后的执行
+ *   每次执行写入一行到日志通道(默认scheduler)：任务名 结果(ok/failed/timeout/panic/skipped) 耗时 错误
+ * Start时注册为关闭钩子，进程Shutdown时停止调度并等待执行中的任务
+ */
+type Scheduler struct {
+	log     *logger.Logger
+	channel *logger.Channel
+
+	lock    sync.Mutex
+	jobs    map[string]*job
+	started bool
+	stopped bool
+	hooked  bool
+	ctx     context.Context // 任务context的父context，Stop超时之后取消
+	cancel  context.CancelFunc
+	stop    chan struct{}
+	loops   sync.WaitGroup // 调度协程
+	runs    sync.WaitGroup // 执行中的任务
+}
+
+// New creates a scheduler writing job runs to the channel of l
+/*
+ * 创建定时任务调度器
+ * @param l：日志对象，任务panic以及失败写入error日志
+ * @param channel：记录每次执行的日志通道名称，为空时为DefaultChannel
+ * @return 调度器
+ */
+func New(l *logger.Logger, channel string) *Scheduler {
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		log:     l,
+		channel: l.Channel(channel),
+		jobs:    make(map[string]*job),
+		ctx:     ctx,
+		cancel:  cancel,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Add registers a job, it starts with Start or immediately if already started
+/*
+ * 注册任务
+ * @param name：任务名称，唯一
+ * @param schedule：执行计划，Every或者Parse的返回值
+ * @param fn：任务，ctx在超时或者Stop超时时取消
+ * @param opts：选项
+ * @return 名称已经存在时返回ErrJobExists；已经Stop时返回error
+ */
+func (s *Scheduler) Add(name string, schedule Schedule, fn func(ctx context.Context) error, opts JobOptions) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return errors.New("scheduler: stopped")
+	}
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, name)
+	}
+	j := &job{
+		name:     name,
+		schedule: schedule,
+		fn:       fn,
+		opts:     opts,
+		trigger:  make(chan struct{}, 1),
+		remove:   make(chan struct{}),
+		status:   JobStatus{Name: name, Schedule: schedule.String()},
+	}
+	s.jobs[name] = j
+	if s.started {
+		s.startJob(j)
+	}
+	return nil
+}
+
+// AddFunc registers a job with a schedule expression, see Parse
+func (s *Scheduler) AddFunc(name, spec string, fn func(ctx context.Context) error, opts JobOptions) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	return s.Add(name, schedule, fn, opts)
+}
+
+// Remove unregisters a job, a running execution is not interrupted
+func (s *Scheduler) Remove(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if j, ok := s.jobs[name]; ok {
+		delete(s.jobs, name)
+		close(j.remove)
+	}
+}
+
+// RunNow triggers an execution of the job outside its schedule
+func (s *Scheduler) RunNow(name string) error {
+	s.lock.Lock()
+	j, ok := s.jobs[name]
+	started := s.started
+	s.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoJob, name)
+	}
+	if !started {
+		return errors.New("scheduler: not started")
+	}
+	select {
+	case j.trigger <- struct{}{}:
+	default:
+		// 已经有一个未处理的触发
+	}
+	return nil
+}
+
+// Start starts scheduling and registers Stop as a shutdown hook
+/*
+ * 开始调度，重复调用不做任何操作；第一次调用时通过process.OnShutdown注册Stop
+ * RunOnStart的任务在Start时执行一次，Start之后Add的任务在Add时执行一次
+ */
+func (s *Scheduler) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.started || s.stopped {
+		return
+	}
+	s.started = true
+	for _, j := range s.jobs {
+		s.startJob(j)
+	}
+	if !s.hooked {
+		s.hooked = true
+		process.OnShutdown(s.Stop)
+	}
+}
+
+// Stop stops scheduling and waits for running jobs
+/*
+ * 停止调度并等待执行中的任务结束，签名与关闭钩子一致
+ * @param ctx：等待的context，结束时取消执行中任务的context并返回ctx.Err()，不再等待任务返回
+ * @return 全部任务结束时返回nil
+ */
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.lock.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+	s.lock.Unlock()
+	s.loops.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// Jobs returns the status of all jobs sorted by name
+func (s *Scheduler) Jobs() []JobStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	sort.Slice(statuses, func(i, k int) bool {
+		return statuses[i].Name < statuses[k].Name
+	})
+	return statuses
+}
+
+/*
+ * 启动任务的调度协程，需要持有锁
+ */
+func (s *Scheduler) startJob(j *job) {
+	s.loops.Add(1)
+	go s.loop(j)
+}
+
+/*
+ * 任务的调度协程：等待下一次执行时间、RunNow、Remove或者Stop
+ */
+func (s *Scheduler) loop(j *job) {
+	defer s.loops.Done()
+	if j.opts.RunOnStart {
+		s.fire(j)
+	}
+	for {
+		now := time.Now()
+		next := j.schedule.Next(now)
+		s.lock.Lock()
+		j.status.Next = next
+		s.lock.Unlock()
+		// 没有下一次执行时间时只等待RunNow
+		var timer *time.Timer
+		var fired <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(now))
+			fired = timer.C
+		}
+		select {
+		case <-fired:
+			s.fire(j)
+		case <-j.trigger:
+			s.fire(j)
+		case <-j.remove:
+		case <-s.stop:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-j.remove:
+			return
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
+
+/*
+ * 执行一次任务，上一次尚未结束并且不允许重叠时跳过
+ */
+func (s *Scheduler) fire(j *job) {
+	s.lock.Lock()
+	if j.status.Running > 0 && !j.opts.AllowOverlap {
+		j.status.Skipped++
+		s.lock.Unlock()
+		s.channel.Write(j.name, "skipped", "previous run still running")
+		return
+	}
+	j.status.Running++
+	j.status.Runs++
+	j.status.LastStart = time.Now()
+	s.runs.Add(1)
+	s.lock.Unlock()
+	go s.run(j)
+}
+
+/*
+ * 执行任务，记录结果
+ */
+func (s *Scheduler) run(j *job) {
+	defer s.runs.Done()
+	ctx := s.ctx
+	if j.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	result, err := s.call(ctx, j)
+	duration := time.Since(start)
+	if result != "panic" && ctx.Err() == context.DeadlineExceeded {
+		// 超时之后返回的任务，无论是否返回error都记为超时
+		result = "timeout"
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+
+	s.lock.Lock()
+	j.status.Running--
+	j.status.LastDuration = duration
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+	}
+	s.lock.Unlock()
+
+	if err != nil {
+		s.channel.Write(j.name, result, duration.String(), err)
+		if result != "panic" {
+			s.log.Error("scheduler", j.name, result, err)
+		}
+		return
+	}
+	s.channel.Write(j.name, result, duration.String())
+}
+
+/*
+ * 调用任务函数，panic写入error日志并转换为error
+ */
+func (s *Scheduler) call(ctx context.Context, j *job) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = "panic", fmt.Errorf("job panic: %v", r)
+			s.log.Error("scheduler", j.name, "panic", fmt.Sprint(r), string(debug.Stack()))
+		}
+	}()
+	if err = j.fn(ctx); err != nil {
+		return "failed", err
+	}
+	return "ok", nil
+}
@@ -0,0 +1,317 @@
+package state
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Codec is the serialization format of snapshots
+type Codec string
+
+const (
+	// CodecJSON encodes snapshots with encoding/json
+	CodecJSON Codec = "json"
+	// CodecGob encodes snapshots with encoding/gob
+	CodecGob Codec = "gob"
+)
+
+// namePattern 组件名称只允许字母数字以及-_.，用于生成快照文件名
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Component is an in-memory state holder that can be snapshotted
+/*
+ * 需要在重启之间保留状态的组件
+ */
+type Component interface {
+	// SaveState 返回需要保存的状态对象，由Manager负责序列化
+	SaveState() (interface{}, error)
+	// LoadState 恢复状态，decode将快照解码到组件提供的对象中
+	LoadState(decode func(v interface{}) error) error
+}
+
+// Options configures the snapshot manager
+type Options struct {
+	Codec       Codec         // 序列化格式，默认json
+	Interval    time.Duration // 自动保存间隔，0表示只在Stop时保存
+	Generations int           // 保留的历史快照代数(不含当前快照)，默认2
+	OnError     func(name string, err error)
+}
+
+// Manager periodically snapshots registered components into dir
+/*
+ * 状态快照管理
+ * 快照文件为 dir/<name>.<codec>，历史快照为 dir/<name>.<codec>.1、.2 ...，数字越大越旧
+ * 写入时先写临时文件并fsync，再rename为正式文件，保证快照文件不会是写了一半的状态
+ */
+type Manager struct {
+	sync.Mutex
+	dir        string
+	opts       Options
+	components map[string]Component
+	order      []string
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewManager creates a snapshot manager storing files under dir
+/*
+ * 创建快照管理对象，目录不存在时自动创建
+ * @param dir：快照目录，一般为项目data目录下的子目录
+ * @param opts：选项
+ * @return 成功返回(*Manager, nil)；否则返回(nil, error)
+ */
+func NewManager(dir string, opts Options) (*Manager, error) {
+	if opts.Codec == "" {
+		opts.Codec = CodecJSON
+	}
+	if opts.Codec != CodecJSON && opts.Codec != CodecGob {
+		return nil, errors.New("state: unsupported codec " + string(opts.Codec))
+	}
+	if opts.Generations <= 0 {
+		opts.Generations = 2
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Manager{dir: dir, opts: opts, components: make(map[string]Component)}, nil
+}
+
+// Register adds a component under a unique name
+func (m *Manager) Register(name string, c Component) error {
+	if !namePattern.MatchString(name) {
+		return errors.New("state: invalid component name " + name)
+	}
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.components[name]; ok {
+		return errors.New("state: component " + name + " already registered")
+	}
+	m.components[name] = c
+	m.order = append(m.order, name)
+	return nil
+}
+
+// Restore loads the newest readable snapshot of every component
+/*
+ * 启动时恢复所有组件的状态
+ * 当前快照损坏时依次尝试历史快照；没有任何快照的组件跳过
+ * @return 存在快照但全部无法恢复的组件会返回error，其余组件仍然会被恢复
+ */
+func (m *Manager) Restore() error {
+	m.Lock()
+	names := append([]string(nil), m.order...)
+	m.Unlock()
+
+	var errs []string
+	for _, name := range names {
+		if err := m.RestoreOne(name); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("state: restore failed: %v", errs)
+	}
+	return nil
+}
+
+// RestoreOne loads the newest readable snapshot of the named component
+func (m *Manager) RestoreOne(name string) error {
+	m.Lock()
+	c, ok := m.components[name]
+	m.Unlock()
+	if !ok {
+		return errors.New("state: component " + name + " not registered")
+	}
+
+	var lastErr error
+	for generation := 0; generation <= m.opts.Generations; generation++ {
+		content, err := ioutil.ReadFile(m.path(name, generation))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err = c.LoadState(m.decoder(content)); err != nil {
+			lastErr = fmt.Errorf("%s generation %d: %v", name, generation, err)
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// Save snapshots all components
+func (m *Manager) Save() error {
+	m.Lock()
+	names := append([]string(nil), m.order...)
+	m.Unlock()
+
+	var errs []string
+	for _, name := range names {
+		if err := m.SaveOne(name); err != nil {
+			errs = append(errs, err.Error())
+			if m.opts.OnError != nil {
+				m.opts.OnError(name, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("state: save failed: %v", errs)
+	}
+	return nil
+}
+
+// SaveOne snapshots the named component and rotates older generations
+func (m *Manager) SaveOne(name string) error {
+	m.Lock()
+	c, ok := m.components[name]
+	m.Unlock()
+	if !ok {
+		return errors.New("state: component " + name + " not registered")
+	}
+
+	v, err := c.SaveState()
+	if err != nil {
+		return err
+	}
+	content, err := m.encode(v)
+	if err != nil {
+		return err
+	}
+
+	tmp := m.path(name, 0) + ".tmp"
+	if err = writeFileSync(tmp, content); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	m.rotate(name)
+	if err = os.Rename(tmp, m.path(name, 0)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(m.dir)
+	return nil
+}
+
+// Start saves snapshots every Interval in background
+func (m *Manager) Start() {
+	m.Lock()
+	defer m.Unlock()
+	if m.stop != nil || m.opts.Interval <= 0 {
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.loop(m.stop, m.done)
+}
+
+// Stop stops the background saving and takes a final snapshot
+/*
+ * 停止自动保存并保存最后一次快照，计划内重启之前调用
+ * @return 最后一次保存失败时返回error
+ */
+func (m *Manager) Stop() error {
+	m.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return m.Save()
+}
+
+func (m *Manager) loop(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.Save()
+		}
+	}
+}
+
+/*
+ * 历史快照后移一代，最旧的一代被覆盖
+ */
+func (m *Manager) rotate(name string) {
+	for generation := m.opts.Generations; generation > 0; generation-- {
+		older := m.path(name, generation-1)
+		if _, err := os.Stat(older); err == nil {
+			os.Rename(older, m.path(name, generation))
+		}
+	}
+}
+
+func (m *Manager) path(name string, generation int) string {
+	p := filepath.Join(m.dir, name+"."+string(m.opts.Codec))
+	if generation > 0 {
+		p += "." + strconv.Itoa(generation)
+	}
+	return p
+}
+
+func (m *Manager) encode(v interface{}) ([]byte, error) {
+	if m.opts.Codec == CodecGob {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func (m *Manager) decoder(content []byte) func(v interface{}) error {
+	if m.opts.Codec == CodecGob {
+		return func(v interface{}) error {
+			return gob.NewDecoder(bytes.NewReader(content)).Decode(v)
+		}
+	}
+	return func(v interface{}) error {
+		return json.Unmarshal(content, v)
+	}
+}
+
+func writeFileSync(path string, content []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+/*
+ * fsync目录，保证rename持久化；部分平台不支持对目录fsync，忽略错误
+ */
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}